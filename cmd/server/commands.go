@@ -12,6 +12,7 @@ import (
 	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v3"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/service"
 )
@@ -24,6 +25,36 @@ func generateKeys(_ *cli.Context) error {
 	return nil
 }
 
+func validateConfig(conf *config.Config) error {
+	if warnings := conf.Warnings(); len(warnings) > 0 {
+		fmt.Printf("found %d warning(s):\n", len(warnings))
+		for _, w := range warnings {
+			fmt.Println(" -", w)
+		}
+	}
+
+	errs := conf.Validate()
+	if len(errs) == 0 {
+		fmt.Println("configuration is valid")
+		return nil
+	}
+
+	fmt.Printf("found %d problem(s) with the configuration:\n", len(errs))
+	for _, err := range errs {
+		fmt.Println(" -", err)
+	}
+	return cli.Exit("invalid configuration", 1)
+}
+
+func dumpConfig(conf *config.Config) error {
+	out, err := conf.DumpYAML()
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
 func printPorts(c *cli.Context) error {
 	conf, err := getConfig(c)
 	if err != nil {
@@ -74,7 +105,13 @@ func createToken(c *cli.Context) error {
 	}
 
 	// use the first API key from config
-	if len(conf.Keys) == 0 {
+	if len(conf.Keys) == 0 && conf.KeyFile == "" && conf.Development {
+		apiKey, apiSecret, deterministic := config.DevelopmentKey()
+		if !deterministic {
+			return fmt.Errorf("could not derive development key from hostname, keys must be configured")
+		}
+		conf.Keys = map[string]string{apiKey: apiSecret}
+	} else if len(conf.Keys) == 0 {
 		// try to load from file
 		if _, err := os.Stat(conf.KeyFile); err != nil {
 			return err
@@ -31,15 +31,20 @@ func main() {
 		Usage:       "High performance WebRTC server",
 		Description: "run without subcommands to start the server",
 		Flags: []cli.Flag{
-			&cli.StringFlag{
+			&cli.StringSliceFlag{
 				Name:  "config",
-				Usage: "path to LiveKit config file",
+				Usage: "path to LiveKit config file. Can be repeated, later files override earlier ones",
 			},
 			&cli.StringFlag{
 				Name:    "config-body",
 				Usage:   "LiveKit config in YAML, typically passed in as an environment var in a container",
 				EnvVars: []string{"LIVEKIT_CONFIG"},
 			},
+			&cli.BoolFlag{
+				Name:    "disable-strict-config",
+				Usage:   "ignore unknown fields in the config instead of failing to start",
+				EnvVars: []string{"LIVEKIT_DISABLE_STRICT_CONFIG"},
+			},
 			&cli.StringFlag{
 				Name:  "key-file",
 				Usage: "path to file that contains API keys/secrets",
@@ -64,6 +69,27 @@ func main() {
 				Usage:   "Single UDP port to use for WebRTC traffic",
 				EnvVars: []string{"UDP_PORT"},
 			},
+			&cli.IntFlag{
+				Name:    "tcp-port",
+				Usage:   "TCP port to use for WebRTC traffic when UDP isn't available",
+				EnvVars: []string{"TCP_PORT"},
+			},
+			&cli.IntFlag{
+				Name:  "port-range-start",
+				Usage: "start of the UDP port range to use for WebRTC traffic. Takes precedence over udp-port",
+			},
+			&cli.IntFlag{
+				Name:  "port-range-end",
+				Usage: "end of the UDP port range to use for WebRTC traffic",
+			},
+			&cli.IntFlag{
+				Name:  "port",
+				Usage: "port for the HTTP server (RoomService and RTC endpoint)",
+			},
+			&cli.StringFlag{
+				Name:  "bind",
+				Usage: "address for the HTTP server to listen on, defaults to all interfaces",
+			},
 			&cli.StringFlag{
 				Name:    "redis-host",
 				Usage:   "host (incl. port) to redis server",
@@ -95,7 +121,15 @@ func main() {
 			},
 			&cli.BoolFlag{
 				Name:  "dev",
-				Usage: "sets log-level to debug, and console formatter",
+				Usage: "sets log-level to debug, console formatter, and generates an API key when none are configured",
+			},
+			&cli.BoolFlag{
+				Name:  "validate-config",
+				Usage: "validates the configuration, prints any problems found and exits",
+			},
+			&cli.BoolFlag{
+				Name:  "dump-config",
+				Usage: "prints the effective configuration with secrets redacted and exits",
 			},
 		},
 		Action: startServer,
@@ -147,12 +181,12 @@ func main() {
 }
 
 func getConfig(c *cli.Context) (*config.Config, error) {
-	confString, err := getConfigString(c.String("config"), c.String("config-body"))
+	confStrings, err := getConfigStrings(c.StringSlice("config"), c.String("config-body"))
 	if err != nil {
 		return nil, err
 	}
 
-	return config.NewConfig(confString, c)
+	return config.NewConfigFromSources(confStrings, c)
 }
 
 func startServer(c *cli.Context) error {
@@ -166,8 +200,22 @@ func startServer(c *cli.Context) error {
 		return err
 	}
 
+	if c.Bool("validate-config") {
+		return validateConfig(conf)
+	}
+	if c.Bool("dump-config") {
+		return dumpConfig(conf)
+	}
+
 	serverlogger.InitFromConfig(conf.Logging)
 
+	for _, warning := range conf.Warnings() {
+		logger.Warnw(warning.Message, nil, "field", warning.Field, "kind", warning.Kind)
+	}
+	if conf.RTC.NodeIPStrategy != "" {
+		logger.Infow("determined node IP", "nodeIP", conf.RTC.NodeIP, "strategy", conf.RTC.NodeIPStrategy)
+	}
+
 	if cpuProfile != "" {
 		if f, err := os.Create(cpuProfile); err != nil {
 			return err
@@ -212,18 +260,34 @@ func startServer(c *cli.Context) error {
 		server.Stop(false)
 	}()
 
+	// an empty list would relay every signal
+	if len(drainSignals) > 0 {
+		drainChan := make(chan os.Signal, 1)
+		signal.Notify(drainChan, drainSignals...)
+		go func() {
+			for sig := range drainChan {
+				logger.Infow("drain requested", "signal", sig)
+				server.Drain()
+			}
+		}()
+	}
+
 	return server.Start()
 }
 
-func getConfigString(configFile string, inConfigBody string) (string, error) {
-	if inConfigBody != "" || configFile == "" {
-		return inConfigBody, nil
+func getConfigStrings(configFiles []string, inConfigBody string) ([]string, error) {
+	if inConfigBody != "" || len(configFiles) == 0 {
+		return []string{inConfigBody}, nil
 	}
 
-	outConfigBody, err := ioutil.ReadFile(configFile)
-	if err != nil {
-		return "", err
+	outConfigBodies := make([]string, 0, len(configFiles))
+	for _, configFile := range configFiles {
+		outConfigBody, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		outConfigBodies = append(outConfigBodies, string(outConfigBody))
 	}
 
-	return string(outConfigBody), nil
+	return outConfigBodies, nil
 }
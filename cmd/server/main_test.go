@@ -27,17 +27,36 @@ func TestGetConfigString(t *testing.T) {
 			writeConfigFile(test, t)
 			defer os.Remove(test.configFileName)
 
-			configBody, err := getConfigString(test.configFileName, test.configBody)
+			var configFiles []string
+			if test.configFileName != "" {
+				configFiles = []string{test.configFileName}
+			}
+			configBodies, err := getConfigStrings(configFiles, test.configBody)
 			require.Equal(t, test.expectedError, err)
-			require.Equal(t, test.expectedConfigBody, configBody)
+			require.Equal(t, []string{test.expectedConfigBody}, configBodies)
 		}()
 	}
 }
 
+func TestGetConfigStringMultipleFiles(t *testing.T) {
+	files := []testStruct{
+		{configFileName: "base", expectedConfigBody: "baseContent"},
+		{configFileName: "override", expectedConfigBody: "overrideContent"},
+	}
+	for _, test := range files {
+		writeConfigFile(test, t)
+		defer os.Remove(test.configFileName)
+	}
+
+	configBodies, err := getConfigStrings([]string{"base", "override"}, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"baseContent", "overrideContent"}, configBodies)
+}
+
 func TestShouldReturnErrorIfConfigFileDoesNotExist(t *testing.T) {
-	configBody, err := getConfigString("notExistingFile", "")
+	configBodies, err := getConfigStrings([]string{"notExistingFile"}, "")
 	require.Error(t, err)
-	require.Empty(t, configBody)
+	require.Empty(t, configBodies)
 }
 
 func writeConfigFile(test testStruct, t *testing.T) {
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// signals that start draining the node
+var drainSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+)
+
+// there is no SIGUSR1 on windows, nodes can only be drained through the /drain admin endpoint
+var drainSignals []os.Signal
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"flag"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
 )
 
 func TestConfig_UnmarshalKeys(t *testing.T) {
@@ -20,5 +24,251 @@ func TestConfig_DefaultsKept(t *testing.T) {
 	conf, err := NewConfig(content, nil)
 	require.NoError(t, err)
 	require.Equal(t, true, conf.Room.AutoCreate)
-	require.Equal(t, uint32(10), conf.Room.EmptyTimeout)
+	require.Equal(t, 10*time.Second, conf.Room.EmptyTimeout.Duration())
+}
+
+func TestConfig_EnvOverrides(t *testing.T) {
+	const content = `rtc:
+  udp_port: 7882
+redis:
+  address: localhost:6379`
+	t.Setenv("LIVEKIT_RTC_UDP_PORT", "9000")
+	t.Setenv("LIVEKIT_RTC_STUN_SERVERS", "stun1:3478, stun2:3478")
+	t.Setenv("LIVEKIT_RTC_PLI_THROTTLE_LOW_QUALITY", "250ms")
+	t.Setenv("LIVEKIT_REDIS_PASSWORD", "secret")
+	t.Setenv("LIVEKIT_ROOM_AUTO_CREATE", "false")
+	t.Setenv("LIVEKIT_NODE_SELECTOR_SYSLOAD_LIMIT", "0.5")
+	t.Setenv("LIVEKIT_KEYS", "{key1: secret1, key2: secret2}")
+
+	conf, err := NewConfig(content, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint32(9000), conf.RTC.UDPPort)
+	require.Equal(t, []string{"stun1:3478", "stun2:3478"}, conf.RTC.STUNServers)
+	require.Equal(t, 250*time.Millisecond, conf.RTC.PLIThrottle.LowQuality.Duration())
+	require.Equal(t, "localhost:6379", conf.Redis.Address)
+	require.Equal(t, "secret", conf.Redis.Password)
+	require.False(t, conf.Room.AutoCreate)
+	require.Equal(t, float32(0.5), conf.NodeSelector.SysloadLimit)
+	require.Equal(t, map[string]string{"key1": "secret1", "key2": "secret2"}, conf.Keys)
+}
+
+func TestConfig_EnvOverridePointer(t *testing.T) {
+	t.Setenv("LIVEKIT_RTC_DATA_CHANNEL_LOSSY_MAX_RETRANSMITS", "3")
+
+	conf, err := NewConfig("", nil)
+	require.NoError(t, err)
+	require.NotNil(t, conf.RTC.DataChannel.Lossy.MaxRetransmits)
+	require.Equal(t, uint16(3), *conf.RTC.DataChannel.Lossy.MaxRetransmits)
+	require.Nil(t, conf.RTC.DataChannel.Lossy.MaxPacketLifeTime)
+
+	t.Setenv("LIVEKIT_RTC_DATA_CHANNEL_LOSSY_MAX_RETRANSMITS", "-1")
+	_, err = NewConfig("", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "LIVEKIT_RTC_DATA_CHANNEL_LOSSY_MAX_RETRANSMITS")
+}
+
+func TestConfig_EnvOverrideInvalid(t *testing.T) {
+	t.Setenv("LIVEKIT_RTC_UDP_PORT", "not-a-port")
+
+	_, err := NewConfig("", nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "LIVEKIT_RTC_UDP_PORT")
+}
+
+func TestConfig_StrictUnknownFields(t *testing.T) {
+	const content = `port: 7880
+turn:
+  enabled: true
+  use_external_ip: true
+rtc:
+  turn_servers:
+    - host: turn.example.com
+      hostname: turn.example.com
+keys:
+  any_key_name: secret
+`
+	_, err := NewConfig(content, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line 4: unknown field turn.use_external_ip")
+	require.Contains(t, err.Error(), "line 8: unknown field rtc.turn_servers[0].hostname")
+	require.NotContains(t, err.Error(), "any_key_name")
+
+	conf := &Config{}
+	require.NoError(t, conf.unmarshal(content, false))
+	require.True(t, conf.TURN.Enabled)
+	require.Equal(t, "secret", conf.Keys["any_key_name"])
+}
+
+func TestConfig_NAT1To1(t *testing.T) {
+	const content = `rtc:
+  use_external_ip: true
+  nat_1to1_ips:
+    - 203.0.113.10/10.0.0.10
+  nat_1to1_candidate_type: srflx`
+	conf, err := NewConfig(content, nil)
+	require.NoError(t, err)
+	// node ip is taken from local interfaces instead of querying STUN
+	require.NotEmpty(t, conf.RTC.NodeIP)
+	require.Equal(t, []string{"203.0.113.10/10.0.0.10"}, conf.RTC.NAT1To1IPs)
+	require.Empty(t, conf.Validate())
+
+	conf.RTC.NAT1To1IPs = []string{"203.0.113.10/not-an-ip"}
+	conf.RTC.NAT1To1CandidateType = "relay"
+	require.Len(t, conf.Validate(), 2)
+}
+
+func TestConfig_PortsFromCLI(t *testing.T) {
+	set := flag.NewFlagSet("test", 0)
+	set.Int("port", 0, "")
+	set.Int("tcp-port", 0, "")
+	set.Int("port-range-start", 0, "")
+	set.Int("port-range-end", 0, "")
+	set.String("bind", "", "")
+	require.NoError(t, set.Parse([]string{
+		"--port", "8880",
+		"--tcp-port", "8881",
+		"--port-range-start", "40000",
+		"--port-range-end", "40100",
+		"--bind", "127.0.0.1",
+	}))
+	c := cli.NewContext(nil, set, nil)
+
+	conf, err := NewConfig("rtc:\n  udp_port: 7882", c)
+	require.NoError(t, err)
+	require.Equal(t, uint32(8880), conf.Port)
+	require.Equal(t, uint32(8881), conf.RTC.TCPPort)
+	require.Equal(t, uint32(40000), conf.RTC.ICEPortRangeStart)
+	require.Equal(t, uint32(40100), conf.RTC.ICEPortRangeEnd)
+	require.Equal(t, "127.0.0.1", conf.BindAddress)
+	require.True(t, conf.UDPPortConflict())
+}
+
+func TestConfig_PrometheusPortAlias(t *testing.T) {
+	conf, err := NewConfig("prometheus_port: 6789", nil)
+	require.NoError(t, err)
+	require.Equal(t, uint32(6789), conf.Prometheus.Port)
+	require.Empty(t, conf.Validate())
+
+	conf, err = NewConfig("prometheus:\n  port: 9000", nil)
+	require.NoError(t, err)
+	require.Equal(t, uint32(9000), conf.Prometheus.Port)
+}
+
+func TestConfig_NonStringSecrets(t *testing.T) {
+	t.Run("numeric secrets are converted with a warning", func(t *testing.T) {
+		conf, err := NewConfig("keys:\n  key1: 123456\n  key2: secret2", nil)
+		require.NoError(t, err)
+		require.Equal(t, "123456", conf.Keys["key1"])
+		require.Equal(t, "secret2", conf.Keys["key2"])
+		require.Len(t, conf.Warnings(), 1)
+		require.Contains(t, conf.Warnings()[0].Message, "key1")
+
+		require.NoError(t, conf.unmarshalKeys("key3: 1.5"))
+		require.Equal(t, "1.5", conf.Keys["key3"])
+	})
+
+	for name, content := range map[string]string{
+		"boolean":    "mykey: true",
+		"nested map": "mykey:\n  nested: secret",
+		"null":       "mykey: null",
+		"sequence":   "mykey: [a, b]",
+	} {
+		t.Run(name, func(t *testing.T) {
+			conf := &Config{}
+			err := conf.unmarshalKeys(content)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "secret for key mykey must be a string")
+
+			_, err = NewConfig("keys:\n  "+strings.ReplaceAll(content, "\n", "\n  "), nil)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "mykey")
+		})
+	}
+}
+
+func TestConfig_StreamTracker(t *testing.T) {
+	const content = `rtc:
+  stream_tracker:
+    base_layer:
+      cycle_duration: 5s
+    screen_share:
+      base_layer:
+        cycle_duration: 10s
+      upper_layers:
+        samples_required: 1
+    hysteresis:
+      readd_after: 10s
+    frozen_timeout: 5s
+`
+	conf, err := NewConfig(content, nil)
+	require.NoError(t, err)
+
+	st := conf.RTC.StreamTracker
+	require.Equal(t, 5*time.Second, st.BaseLayer.CycleDuration.Duration())
+	// defaults kept
+	require.Equal(t, uint32(1), st.BaseLayer.SamplesRequired)
+	require.Equal(t, uint64(60), st.UpperLayers.CyclesRequired)
+	require.Equal(t, 10*time.Second, st.Hysteresis.ReAddAfter.Duration())
+	require.Zero(t, st.Hysteresis.RemovalGrace)
+	require.Equal(t, 2*time.Second, st.BitrateWindow.Duration())
+	require.Equal(t, 5*time.Second, st.FrozenTimeout.Duration())
+
+	screenShare := st.WithOverride(st.ScreenShare)
+	require.Equal(t, 10*time.Second, screenShare.BaseLayer.CycleDuration.Duration())
+	require.Equal(t, uint32(1), screenShare.UpperLayers.SamplesRequired)
+	require.Equal(t, uint64(60), screenShare.UpperLayers.CyclesRequired)
+	require.Equal(t, 500*time.Millisecond, screenShare.UpperLayers.CycleDuration.Duration())
+	require.Equal(t, StreamTrackerOverride{}, screenShare.ScreenShare)
+	require.Equal(t, st.Hysteresis, screenShare.Hysteresis)
+	require.Equal(t, st.BitrateWindow, screenShare.BitrateWindow)
+	require.Equal(t, st.FrozenTimeout, screenShare.FrozenTimeout)
+}
+
+func TestConfig_StreamTrackerInvalid(t *testing.T) {
+	const content = `rtc:
+  stream_tracker:
+    upper_layers:
+      cycles_required: 0
+`
+	_, err := NewConfig(content, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rtc.stream_tracker.upper_layers.cycles_required")
+}
+
+func TestConfig_WebHookURLs(t *testing.T) {
+	const content = `keys:
+  key1: secret1
+webhook:
+  api_key: key1
+  urls:
+    - https://example.com/all
+    - url: https://example.com/tracks
+      events: [track_published]
+      headers:
+        X-Route: tracks
+`
+	conf, err := NewConfig(content, nil)
+	require.NoError(t, err)
+	require.Equal(t, []WebHookURL{
+		{URL: "https://example.com/all"},
+		{
+			URL:     "https://example.com/tracks",
+			Events:  []string{"track_published"},
+			Headers: map[string]string{"X-Route": "tracks"},
+		},
+	}, conf.WebHook.URLs)
+
+	out, err := conf.DumpYAML()
+	require.NoError(t, err)
+	require.Contains(t, out, "- https://example.com/all")
+	require.Contains(t, out, "url: https://example.com/tracks")
+	require.NotContains(t, out, "X-Route: tracks")
+
+	_, err = NewConfig(`webhook:
+  urls:
+    - url: https://example.com/tracks
+      event: [track_published]
+`, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line 4: unknown field webhook.urls[0].event")
 }
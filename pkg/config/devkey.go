@@ -0,0 +1,26 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+)
+
+// DevelopmentKey returns the API key pair used in development mode when no keys are configured.
+// It is derived from the hostname, so tokens created with `create-join-token --dev` on the same host are
+// accepted by the server. When the hostname is unavailable a random pair is returned and deterministic is false
+func DevelopmentKey() (apiKey, apiSecret string, deterministic bool) {
+	seed, err := os.Hostname()
+	if err != nil || seed == "" {
+		b := make([]byte, 32)
+		_, _ = rand.Read(b)
+		seed = hex.EncodeToString(b)
+	} else {
+		deterministic = true
+	}
+
+	keySum := sha256.Sum256([]byte("livekit-dev-key:" + seed))
+	secretSum := sha256.Sum256([]byte("livekit-dev-secret:" + seed))
+	return "dev" + hex.EncodeToString(keySum[:6]), hex.EncodeToString(secretSum[:]), deterministic
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevelopmentKey(t *testing.T) {
+	apiKey, apiSecret, deterministic := DevelopmentKey()
+	require.True(t, deterministic)
+	require.NotEmpty(t, apiKey)
+	require.GreaterOrEqual(t, len(apiSecret), 32)
+
+	// the same host always produces the same pair
+	apiKey2, apiSecret2, _ := DevelopmentKey()
+	require.Equal(t, apiKey, apiKey2)
+	require.Equal(t, apiSecret, apiSecret2)
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration accepts either a Go duration string, i.e. "250ms", or a bare number of nanoseconds
+type Duration time.Duration
+
+// DurationSeconds accepts either a Go duration string, i.e. "5m", or a bare number of seconds
+type DurationSeconds time.Duration
+
+// DurationMilliseconds accepts either a Go duration string, i.e. "400ms", or a bare number of milliseconds
+type DurationMilliseconds time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := parseDuration(value, time.Nanosecond)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d DurationSeconds) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d *DurationSeconds) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := parseDuration(value, time.Second)
+	if err != nil {
+		return err
+	}
+	*d = DurationSeconds(parsed)
+	return nil
+}
+
+func (d DurationSeconds) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d DurationMilliseconds) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d *DurationMilliseconds) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := parseDuration(value, time.Millisecond)
+	if err != nil {
+		return err
+	}
+	*d = DurationMilliseconds(parsed)
+	return nil
+}
+
+func (d DurationMilliseconds) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+// parseDuration interprets bare numbers in the given unit, and anything else as a Go duration string
+func parseDuration(value *yaml.Node, bareUnit time.Duration) (time.Duration, error) {
+	if value.Kind != yaml.ScalarNode {
+		return 0, fmt.Errorf("line %d: expected a duration", value.Line)
+	}
+	if n, err := strconv.ParseInt(value.Value, 10, 64); err == nil {
+		return time.Duration(n) * bareUnit, nil
+	}
+	d, err := time.ParseDuration(value.Value)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: invalid duration %q", value.Line, value.Value)
+	}
+	return d, nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Durations(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        string
+		emptyTimeout   time.Duration
+		updateInterval time.Duration
+		pliLowQuality  time.Duration
+	}{
+		{
+			name:           "defaults",
+			content:        "",
+			emptyTimeout:   5 * time.Minute,
+			updateInterval: 400 * time.Millisecond,
+			pliLowQuality:  500 * time.Millisecond,
+		},
+		{
+			name: "bare numbers keep their legacy units",
+			content: `room:
+  empty_timeout: 30
+audio:
+  update_interval: 250
+rtc:
+  pli_throttle:
+    low_quality: 100000000`,
+			emptyTimeout:   30 * time.Second,
+			updateInterval: 250 * time.Millisecond,
+			pliLowQuality:  100 * time.Millisecond,
+		},
+		{
+			name: "duration strings",
+			content: `room:
+  empty_timeout: 5m
+audio:
+  update_interval: 1s
+rtc:
+  pli_throttle:
+    low_quality: 250ms`,
+			emptyTimeout:   5 * time.Minute,
+			updateInterval: time.Second,
+			pliLowQuality:  250 * time.Millisecond,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := NewConfig(test.content, nil)
+			require.NoError(t, err)
+			require.Equal(t, test.emptyTimeout, conf.Room.EmptyTimeout.Duration())
+			require.Equal(t, test.updateInterval, conf.Audio.UpdateInterval.Duration())
+			require.Equal(t, test.pliLowQuality, conf.RTC.PLIThrottle.LowQuality.Duration())
+		})
+	}
+}
+
+func TestConfig_InvalidDuration(t *testing.T) {
+	_, err := NewConfig(`room:
+  empty_timeout: 5 minutes`, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid duration")
+
+	t.Setenv("LIVEKIT_ROOM_EMPTY_TIMEOUT", "90")
+	conf, err := NewConfig("", nil)
+	require.NoError(t, err)
+	require.Equal(t, 90*time.Second, conf.Room.EmptyTimeout.Duration())
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "LIVEKIT"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// updateFromEnv overrides config fields with environment variables. Variable names are derived from yaml tags,
+// i.e. rtc.udp_port can be set with LIVEKIT_RTC_UDP_PORT
+func (conf *Config) updateFromEnv() error {
+	return setFromEnv(reflect.ValueOf(conf).Elem(), envPrefix)
+}
+
+func setFromEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			if err := setFromEnv(fv, name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setFieldFromString(fv, value); err != nil {
+			return fmt.Errorf("could not parse environment variable %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setFieldFromString(fv reflect.Value, value string) error {
+	if reflect.PtrTo(fv.Type()).Implements(yamlUnmarshalerType) {
+		// custom types, i.e. durations, handle their own parsing
+		return setFieldFromYAML(fv, value)
+	}
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.Ptr:
+		// optional values, i.e. rtc.data_channel.lossy.max_retransmits
+		elem := reflect.New(fv.Type().Elem())
+		if err := setFieldFromString(elem.Elem(), value); err != nil {
+			return err
+		}
+		fv.Set(elem)
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.String {
+			// comma separated list, i.e. stun_servers
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			slice := reflect.MakeSlice(fv.Type(), len(items), len(items))
+			for i, item := range items {
+				slice.Index(i).SetString(item)
+			}
+			fv.Set(slice)
+			return nil
+		}
+		return setFieldFromYAML(fv, value)
+	case reflect.Map:
+		// maps (i.e. keys) are expected in yaml form, "key1: secret1" or "{key1: secret1, key2: secret2}"
+		return setFieldFromYAML(fv, value)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+func setFieldFromYAML(fv reflect.Value, value string) error {
+	ptr := reflect.New(fv.Type())
+	if err := yaml.Unmarshal([]byte(value), ptr.Interface()); err != nil {
+		return err
+	}
+	fv.Set(ptr.Elem())
+	return nil
+}
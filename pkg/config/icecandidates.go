@@ -0,0 +1,46 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ICECandidateTypes are the values accepted in rtc.ice_candidate_types
+var ICECandidateTypes = []string{"host", "srflx", "prflx", "relay"}
+
+var ErrRelayWithoutTURN = errors.New("only relay candidates are allowed, but no TURN server is configured")
+
+// ValidateICECandidateTypes checks that every entry is a known candidate type
+func ValidateICECandidateTypes(types []string) error {
+	for _, t := range types {
+		valid := false
+		for _, known := range ICECandidateTypes {
+			if t == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unsupported ICE candidate type: %s", t)
+		}
+	}
+	return nil
+}
+
+// IsRelayOnly returns true when clients can only connect through a TURN server
+func IsRelayOnly(types []string) bool {
+	if len(types) == 0 {
+		return false
+	}
+	for _, t := range types {
+		if t != "relay" {
+			return false
+		}
+	}
+	return true
+}
+
+// HasTURNServer returns true when the embedded TURN server or an external one is available to clients
+func (conf *Config) HasTURNServer() bool {
+	return conf.TURN.Enabled || len(conf.RTC.TURNServers) > 0
+}
@@ -3,33 +3,120 @@ package config
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/pion/stun"
 	"github.com/pkg/errors"
 )
 
-func (conf *Config) determineIP() (string, error) {
-	if conf.RTC.UseExternalIP {
+const (
+	IPDiscoveryStrategySTUN      = "stun"
+	IPDiscoveryStrategyHTTP      = "http"
+	IPDiscoveryStrategyInterface = "interface"
+	IPDiscoveryStrategyDisabled  = "disabled"
+)
+
+const defaultIPDiscoveryTimeout = 5 * time.Second
+
+// determineIP returns the IP to advertise along with the strategy used to find it.
+// When use_external_ip is set, strategies in rtc.ip_discovery are tried in order, falling through on failure
+func (conf *Config) determineIP() (string, string, error) {
+	if !conf.RTC.UseExternalIP {
+		// use local ip instead
+		ip, err := getFirstLocalIPAddress()
+		return ip, IPDiscoveryStrategyInterface, err
+	}
+
+	strategies := conf.RTC.IPDiscovery.Strategies
+	if len(strategies) == 0 {
+		strategies = []string{IPDiscoveryStrategySTUN}
+	}
+	timeout := conf.RTC.IPDiscovery.Timeout.Duration()
+	if timeout <= 0 {
+		timeout = defaultIPDiscoveryTimeout
+	}
+
+	var tried []string
+	var errs []string
+	for _, strategy := range strategies {
+		if strategy == IPDiscoveryStrategyDisabled {
+			// discovery turned off, advertise the local address
+			ip, err := getFirstLocalIPAddress()
+			return ip, strategy, err
+		}
+
+		tried = append(tried, strategy)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		ip, err := conf.discoverIP(ctx, strategy)
+		cancel()
+		if err == nil {
+			return ip, strategy, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", strategy, err))
+	}
+	return "", "", errors.Errorf("could not resolve external IP, tried strategies %s: %s",
+		strings.Join(tried, ", "), strings.Join(errs, "; "))
+}
+
+func (conf *Config) discoverIP(ctx context.Context, strategy string) (string, error) {
+	switch strategy {
+	case IPDiscoveryStrategySTUN:
 		stunServers := conf.RTC.STUNServers
 		if len(stunServers) == 0 {
 			stunServers = DefaultStunServers
 		}
-		var err error
-		for i := 0; i < 3; i++ {
-			var ip string
-			ip, err = GetExternalIP(stunServers)
-			if err == nil {
-				return ip, nil
-			} else {
-				time.Sleep(500 * time.Millisecond)
-			}
+		return getExternalIP(ctx, stunServers)
+	case IPDiscoveryStrategyHTTP:
+		return getIPFromHTTP(ctx, conf.RTC.IPDiscovery.HTTPURL)
+	case IPDiscoveryStrategyInterface:
+		addresses, err := GetLocalIPAddresses()
+		if err != nil {
+			return "", err
+		}
+		ip := net.ParseIP(addresses[0])
+		if ip == nil || ip.IsLoopback() {
+			return "", errors.New("no non-loopback address found")
 		}
-		return "", errors.Errorf("could not resolve external IP: %v", err)
+		return addresses[0], nil
+	default:
+		return "", fmt.Errorf("unknown strategy")
+	}
+}
+
+// getIPFromHTTP queries an echo service that responds with the caller's IP as plain text
+func getIPFromHTTP(ctx context.Context, url string) (string, error) {
+	if url == "" {
+		return "", errors.New("rtc.ip_discovery.http_url is not set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
 	}
+	defer res.Body.Close()
 
-	// use local ip instead
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("invalid IPv4 address in response: %q", strings.TrimSpace(string(body)))
+	}
+	return ip.To4().String(), nil
+}
+
+func getFirstLocalIPAddress() (string, error) {
 	addresses, err := GetLocalIPAddresses()
 	if len(addresses) > 0 {
 		return addresses[0], err
@@ -79,7 +166,34 @@ func GetLocalIPAddresses() ([]string, error) {
 	return nil, fmt.Errorf("could not find local IP address")
 }
 
+// localInterfaceAddrs returns the IPv4 addresses of each local interface, replaceable for tests
+var localInterfaceAddrs = func() (map[string][]net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	addrsByInterface := make(map[string][]net.IP, len(ifaces))
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				addrsByInterface[iface.Name] = append(addrsByInterface[iface.Name], ipNet.IP)
+			}
+		}
+	}
+	return addrsByInterface, nil
+}
+
 func GetExternalIP(stunServers []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultIPDiscoveryTimeout)
+	defer cancel()
+	return getExternalIP(ctx, stunServers)
+}
+
+func getExternalIP(ctx context.Context, stunServers []string) (string, error) {
 	if len(stunServers) == 0 {
 		return "", errors.New("STUN servers are required but not defined")
 	}
@@ -117,8 +231,6 @@ func GetExternalIP(stunServers []string) (string, error) {
 		return "", err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
 	select {
 	case nodeIP := <-ipChan:
 		return nodeIP, nil
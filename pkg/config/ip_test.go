@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetermineIP_FallsThrough(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintln(w, "203.0.113.10")
+	}))
+	defer server.Close()
+
+	conf := &Config{
+		RTC: RTCConfig{
+			UseExternalIP: true,
+			// unreachable STUN server, should fall through to http
+			STUNServers: []string{"127.0.0.1:1"},
+			IPDiscovery: IPDiscoveryConfig{
+				Strategies: []string{IPDiscoveryStrategySTUN, IPDiscoveryStrategyHTTP},
+				HTTPURL:    server.URL,
+				Timeout:    Duration(200 * time.Millisecond),
+			},
+		},
+	}
+	ip, strategy, err := conf.determineIP()
+	require.NoError(t, err)
+	require.Equal(t, "203.0.113.10", ip)
+	require.Equal(t, IPDiscoveryStrategyHTTP, strategy)
+}
+
+func TestDetermineIP_AllFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	conf := &Config{
+		RTC: RTCConfig{
+			UseExternalIP: true,
+			STUNServers:   []string{"127.0.0.1:1"},
+			IPDiscovery: IPDiscoveryConfig{
+				Strategies: []string{IPDiscoveryStrategySTUN, IPDiscoveryStrategyHTTP},
+				HTTPURL:    server.URL,
+				Timeout:    Duration(200 * time.Millisecond),
+			},
+		},
+	}
+	_, _, err := conf.determineIP()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "tried strategies stun, http")
+}
+
+func TestDetermineIP_Disabled(t *testing.T) {
+	conf := &Config{
+		RTC: RTCConfig{
+			UseExternalIP: true,
+			IPDiscovery: IPDiscoveryConfig{
+				Strategies: []string{IPDiscoveryStrategyDisabled},
+			},
+		},
+	}
+	ip, strategy, err := conf.determineIP()
+	require.NoError(t, err)
+	require.NotEmpty(t, ip)
+	require.Equal(t, IPDiscoveryStrategyDisabled, strategy)
+}
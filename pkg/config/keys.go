@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseKeys decodes "key: secret" pairs. Numeric secrets are converted to strings and reported as warnings,
+// any other non-string value is rejected with an error naming the offending key
+func ParseKeys(data []byte) (map[string]string, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 {
+		return map[string]string{}, nil, nil
+	}
+	return decodeKeysNode(doc.Content[0])
+}
+
+func decodeKeysNode(node *yaml.Node) (map[string]string, []string, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("keys must be a map of key: secret, got %s", yamlTypeName(node))
+	}
+
+	keys := make(map[string]string, len(node.Content)/2)
+	var warnings []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		value := node.Content[i+1]
+		if value.Kind == yaml.AliasNode && value.Alias != nil {
+			value = value.Alias
+		}
+
+		switch typeName := yamlTypeName(value); typeName {
+		case "string":
+			keys[key] = value.Value
+		case "int", "float":
+			keys[key] = value.Value
+			warnings = append(warnings, fmt.Sprintf("secret for key %s is a YAML %s, treating it as a string. Quote it to silence this warning", key, typeName))
+		default:
+			return nil, nil, fmt.Errorf("secret for key %s must be a string, got YAML %s", key, typeName)
+		}
+	}
+	return keys, warnings, nil
+}
+
+func yamlTypeName(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "map"
+	case yaml.SequenceNode:
+		return "sequence"
+	case yaml.ScalarNode:
+		if tag := node.ShortTag(); tag == "!!str" {
+			return "string"
+		} else {
+			return strings.TrimPrefix(tag, "!!")
+		}
+	default:
+		return "value"
+	}
+}
+
+// checkKeys validates the keys section of a config document
+func checkKeys(confString string) ([]string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(confString), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "keys" {
+			if root.Content[i+1].ShortTag() == "!!null" {
+				return nil, nil
+			}
+			_, warnings, err := decodeKeysNode(root.Content[i+1])
+			return warnings, err
+		}
+	}
+	return nil, nil
+}
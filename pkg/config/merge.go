@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// mergeConfigSources deep-merges yaml sources in order. Mappings are merged key by key (including keys),
+// while scalars and lists such as stun_servers are replaced by later sources. Only fields that are present
+// in a source override earlier values, so an explicit `use_external_ip: false` is distinguishable from unset.
+func mergeConfigSources(sources []string, strict bool) (string, error) {
+	var nonEmpty []string
+	for _, source := range sources {
+		if strings.TrimSpace(source) != "" {
+			nonEmpty = append(nonEmpty, source)
+		}
+	}
+	switch len(nonEmpty) {
+	case 0:
+		return "", nil
+	case 1:
+		// decode directly to keep line numbers in errors accurate
+		return nonEmpty[0], nil
+	}
+
+	var merged interface{}
+	for i, source := range nonEmpty {
+		if strict {
+			unknown, err := findUnknownFields(source)
+			if err != nil {
+				return "", fmt.Errorf("could not parse config #%d: %v", i+1, err)
+			}
+			if len(unknown) > 0 {
+				return "", fmt.Errorf("could not parse config #%d: %s", i+1, strings.Join(unknown, "; "))
+			}
+		}
+
+		var values interface{}
+		if err := yaml.Unmarshal([]byte(source), &values); err != nil {
+			return "", fmt.Errorf("could not parse config #%d: %v", i+1, err)
+		}
+		merged = mergeYAMLValues(merged, values)
+	}
+
+	out, err := yaml.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func mergeYAMLValues(dst, src interface{}) interface{} {
+	if src == nil {
+		// null or missing values don't override
+		return dst
+	}
+
+	switch s := src.(type) {
+	case map[string]interface{}:
+		d, ok := dst.(map[string]interface{})
+		if !ok {
+			return s
+		}
+		for k, v := range s {
+			d[k] = mergeYAMLValues(d[k], v)
+		}
+		return d
+	case map[interface{}]interface{}:
+		d, ok := dst.(map[interface{}]interface{})
+		if !ok {
+			return s
+		}
+		for k, v := range s {
+			d[k] = mergeYAMLValues(d[k], v)
+		}
+		return d
+	default:
+		return src
+	}
+}
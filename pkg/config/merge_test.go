@@ -0,0 +1,95 @@
+package config
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+func TestConfig_MergeSources(t *testing.T) {
+	const base = `port: 7880
+rtc:
+  use_external_ip: true
+  stun_servers:
+    - stun1:3478
+    - stun2:3478
+  nat_1to1_ips:
+    - 203.0.113.10
+keys:
+  base: basesecret
+  shared: basesecret
+room:
+  empty_timeout: 60
+  max_participants: 10
+`
+	const override = `port: 7890
+rtc:
+  use_external_ip: false
+  stun_servers:
+    - stun3:3478
+keys:
+  shared: overridesecret
+  extra: extrasecret
+room:
+  empty_timeout:
+`
+
+	conf, err := NewConfigFromSources([]string{base, override}, nil)
+	require.NoError(t, err)
+
+	// later file overrides scalars
+	require.Equal(t, uint32(7890), conf.Port)
+	// explicit false overrides true
+	require.False(t, conf.RTC.UseExternalIP)
+	// lists are replaced
+	require.Equal(t, []string{"stun3:3478"}, conf.RTC.STUNServers)
+	// keys are merged
+	require.Equal(t, map[string]string{
+		"base":   "basesecret",
+		"shared": "overridesecret",
+		"extra":  "extrasecret",
+	}, conf.Keys)
+	// fields missing or null in the later file are kept
+	require.Equal(t, uint32(10), conf.Room.MaxParticipants)
+	require.Equal(t, 60, int(conf.Room.EmptyTimeout.Duration().Seconds()))
+	// defaults are kept when no file sets them
+	require.True(t, conf.Room.AutoCreate)
+}
+
+func TestConfig_MergePrecedence(t *testing.T) {
+	const first = `port: 7880
+rtc:
+  nat_1to1_ips:
+    - 203.0.113.10
+  udp_port: 7000
+  tcp_port: 7001`
+	const last = `rtc:
+  udp_port: 8000`
+
+	set := flag.NewFlagSet("test", 0)
+	set.Int("udp-port", 0, "")
+	require.NoError(t, set.Parse([]string{"--udp-port", "9000"}))
+	c := cli.NewContext(nil, set, nil)
+
+	// CLI > last file
+	conf, err := NewConfigFromSources([]string{first, last}, c)
+	require.NoError(t, err)
+	require.Equal(t, uint32(9000), conf.RTC.UDPPort)
+
+	// last file > first file > defaults
+	conf, err = NewConfigFromSources([]string{first, last}, nil)
+	require.NoError(t, err)
+	require.Equal(t, uint32(8000), conf.RTC.UDPPort)
+	require.Equal(t, uint32(7001), conf.RTC.TCPPort)
+	require.Equal(t, uint32(7880), conf.Port)
+	require.Equal(t, "error", conf.Logging.PionLevel)
+}
+
+func TestConfig_MergeStrict(t *testing.T) {
+	_, err := NewConfigFromSources([]string{"port: 7880", "rtc:\n  unknown_field: 1"}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "config #2")
+	require.Contains(t, err.Error(), "line 2: unknown field rtc.unknown_field")
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redactedValue = "****"
+
+// Redacted returns a copy of the config with every field tagged `secret:"true"` replaced by ****.
+// For maps such as keys, the map keys are kept and only the values are redacted
+func (conf *Config) Redacted() *Config {
+	redacted := redactValue(reflect.ValueOf(*conf)).Interface().(Config)
+	return &redacted
+}
+
+// DumpYAML marshals the redacted config back to yaml
+func (conf *Config) DumpYAML() (string, error) {
+	out, err := yaml.Marshal(conf.Redacted())
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+func redactValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			if field.Tag.Get("secret") == "true" {
+				out.Field(i).Set(redactSecret(v.Field(i)))
+			} else {
+				out.Field(i).Set(redactValue(v.Field(i)))
+			}
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), redactValue(iter.Value()))
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func redactSecret(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 {
+			return v
+		}
+		return reflect.ValueOf(redactedValue).Convert(v.Type())
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), redactSecret(iter.Value()))
+		}
+		return out
+	default:
+		// secrets of other types are dropped entirely
+		return reflect.Zero(v.Type())
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_DumpRedactsSecrets(t *testing.T) {
+	const content = `redis:
+  address: redis.host:6379
+  password: redispassword
+rtc:
+  nat_1to1_ips:
+    - 203.0.113.10
+  turn_servers:
+    - host: turn.example.com
+      port: 443
+      protocol: tls
+      username: turnuser
+      credential: turncredential
+keys:
+  apikey1: apisecret1
+  apikey2: apisecret2
+webhook:
+  api_key: apikey1
+  urls:
+    - https://example.com/webhook
+`
+	conf, err := NewConfig(content, nil)
+	require.NoError(t, err)
+
+	out, err := conf.DumpYAML()
+	require.NoError(t, err)
+	for _, secret := range []string{"redispassword", "turncredential", "apisecret1", "apisecret2", "api_key: apikey1"} {
+		require.NotContains(t, out, secret)
+	}
+	require.Contains(t, out, "redis.host:6379")
+	require.Contains(t, out, "apikey2: '****'")
+	require.Contains(t, out, "username: turnuser")
+
+	// original config is untouched
+	require.Equal(t, "redispassword", conf.Redis.Password)
+	require.Equal(t, "apisecret1", conf.Keys["apikey1"])
+	require.Equal(t, "turncredential", conf.RTC.TURNServers[0].Credential)
+
+	// dumped config can be parsed again
+	_, err = NewConfig(out, nil)
+	require.NoError(t, err)
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RegionAuto detects the region from the cloud provider's metadata service at startup
+const RegionAuto = "auto"
+
+const regionDetectionTimeout = time.Second
+
+// metadata endpoints, replaceable for tests
+var (
+	ec2MetadataURL   = "http://169.254.169.254/latest"
+	gceMetadataURL   = "http://metadata.google.internal/computeMetadata/v1"
+	azureMetadataURL = "http://169.254.169.254/metadata"
+)
+
+type cloudLocation struct {
+	provider string
+	// zone within the region, if the provider has one, i.e. us-east-1a
+	zone   string
+	region string
+}
+
+type regionDetector func(ctx context.Context) (*cloudLocation, error)
+
+// resolveAutoRegion replaces region: auto with the region reported by the cloud provider, translated
+// through region_mapping. Detection failures leave the region empty rather than blocking startup
+func (conf *Config) resolveAutoRegion() {
+	if conf.Region != RegionAuto {
+		return
+	}
+	conf.Region = ""
+
+	location, err := detectCloudLocation(regionDetectionTimeout)
+	if err != nil {
+		conf.addWarning(ConfigWarningDefaulted, "region", "could not detect region from cloud metadata: %v", err)
+		return
+	}
+	conf.Region = conf.mapRegion(location)
+	if len(conf.NodeSelector.Regions) > 0 && !conf.hasSelectorRegion(conf.Region) {
+		conf.addWarning(ConfigWarningDefaulted, "region",
+			"detected %s region %s is not listed in node_selector.regions, add it to region_mapping", location.provider, conf.Region)
+	}
+}
+
+func (conf *Config) mapRegion(location *cloudLocation) string {
+	for _, name := range []string{location.zone, location.region} {
+		if name == "" {
+			continue
+		}
+		if mapped, ok := conf.RegionMapping[name]; ok {
+			return mapped
+		}
+	}
+	return location.region
+}
+
+func (conf *Config) hasSelectorRegion(name string) bool {
+	for _, region := range conf.NodeSelector.Regions {
+		if region.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func detectCloudLocation(timeout time.Duration) (*cloudLocation, error) {
+	var errs []string
+	for _, detect := range []regionDetector{detectEC2, detectGCE, detectAzure} {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		location, err := detect(ctx)
+		cancel()
+		if err == nil {
+			return location, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, errors.New(strings.Join(errs, "; "))
+}
+
+func detectEC2(ctx context.Context) (*cloudLocation, error) {
+	// IMDSv2 requires a session token
+	token, err := metadataRequest(ctx, http.MethodPut, ec2MetadataURL+"/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if err != nil {
+		return nil, fmt.Errorf("ec2: %v", err)
+	}
+	header := map[string]string{"X-aws-ec2-metadata-token": token}
+	zone, err := metadataRequest(ctx, http.MethodGet, ec2MetadataURL+"/meta-data/placement/availability-zone", header)
+	if err != nil {
+		return nil, fmt.Errorf("ec2: %v", err)
+	}
+	region, err := metadataRequest(ctx, http.MethodGet, ec2MetadataURL+"/meta-data/placement/region", header)
+	if err != nil {
+		return nil, fmt.Errorf("ec2: %v", err)
+	}
+	return &cloudLocation{provider: "ec2", zone: zone, region: region}, nil
+}
+
+func detectGCE(ctx context.Context) (*cloudLocation, error) {
+	// projects/<project number>/zones/us-central1-a
+	zone, err := metadataRequest(ctx, http.MethodGet, gceMetadataURL+"/instance/zone",
+		map[string]string{"Metadata-Flavor": "Google"})
+	if err != nil {
+		return nil, fmt.Errorf("gce: %v", err)
+	}
+	zone = zone[strings.LastIndex(zone, "/")+1:]
+	idx := strings.LastIndex(zone, "-")
+	if idx <= 0 {
+		return nil, fmt.Errorf("gce: unexpected zone %s", zone)
+	}
+	return &cloudLocation{provider: "gce", zone: zone, region: zone[:idx]}, nil
+}
+
+func detectAzure(ctx context.Context) (*cloudLocation, error) {
+	region, err := metadataRequest(ctx, http.MethodGet, azureMetadataURL+"/instance/compute/location?api-version=2021-02-01&format=text",
+		map[string]string{"Metadata": "true"})
+	if err != nil {
+		return nil, fmt.Errorf("azure: %v", err)
+	}
+	return &cloudLocation{provider: "azure", region: region}, nil
+}
+
+func metadataRequest(ctx context.Context, method, url string, header map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d from %s", res.StatusCode, url)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimSpace(string(body))
+	if value == "" {
+		return "", fmt.Errorf("empty response from %s", url)
+	}
+	return value, nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveAutoRegion(t *testing.T) {
+	unavailable := httptest.NewServer(http.NotFoundHandler())
+	defer unavailable.Close()
+	gce := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Path != "/instance/zone" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("projects/123/zones/us-central1-a"))
+	}))
+	defer gce.Close()
+
+	setMetadataURLs := func(ec2, gceURL, azure string) {
+		origEC2, origGCE, origAzure := ec2MetadataURL, gceMetadataURL, azureMetadataURL
+		ec2MetadataURL, gceMetadataURL, azureMetadataURL = ec2, gceURL, azure
+		t.Cleanup(func() {
+			ec2MetadataURL, gceMetadataURL, azureMetadataURL = origEC2, origGCE, origAzure
+		})
+	}
+
+	t.Run("maps detected region", func(t *testing.T) {
+		setMetadataURLs(unavailable.URL, gce.URL, unavailable.URL)
+		conf := &Config{
+			Region:        RegionAuto,
+			RegionMapping: map[string]string{"us-central1": "us-central"},
+			NodeSelector: NodeSelectorConfig{
+				Regions: []RegionConfig{{Name: "us-central"}},
+			},
+		}
+		conf.resolveAutoRegion()
+		require.Equal(t, "us-central", conf.Region)
+		require.Empty(t, conf.Warnings())
+	})
+
+	t.Run("zone mapping takes precedence", func(t *testing.T) {
+		setMetadataURLs(unavailable.URL, gce.URL, unavailable.URL)
+		conf := &Config{
+			Region:        RegionAuto,
+			RegionMapping: map[string]string{"us-central1": "us-central", "us-central1-a": "us-central-a"},
+		}
+		conf.resolveAutoRegion()
+		require.Equal(t, "us-central-a", conf.Region)
+	})
+
+	t.Run("falls back to empty region", func(t *testing.T) {
+		setMetadataURLs(unavailable.URL, unavailable.URL, unavailable.URL)
+		conf := &Config{Region: RegionAuto}
+		conf.resolveAutoRegion()
+		require.Empty(t, conf.Region)
+		require.Len(t, conf.Warnings(), 1)
+		require.Contains(t, conf.Warnings()[0].Message, "could not detect region")
+	})
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// findUnknownFields walks the parsed yaml alongside the Config struct and reports every key that doesn't map to
+// a field, using the full path of the key so that misplaced fields are easy to locate
+func findUnknownFields(confString string) ([]string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(confString), &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+
+	var unknown []string
+	walkYAMLNode(root.Content[0], reflect.TypeOf(Config{}), "", &unknown)
+	return unknown, nil
+}
+
+func walkYAMLNode(node *yaml.Node, t reflect.Type, path string, unknown *[]string) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(yamlUnmarshalerType) && (t.Kind() != reflect.Struct || node.Kind != yaml.MappingNode) {
+		// custom types handle their own decoding, structs written out as mappings are still checked
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			if keyNode.Value == "<<" {
+				// merge key
+				walkYAMLNode(valueNode, t, path, unknown)
+				continue
+			}
+			fieldPath := joinYAMLPath(path, keyNode.Value)
+			field, ok := fields[keyNode.Value]
+			if !ok {
+				*unknown = append(*unknown, fmt.Sprintf("line %d: unknown field %s", keyNode.Line, fieldPath))
+				continue
+			}
+			walkYAMLNode(valueNode, field.Type, fieldPath, unknown)
+		}
+	case reflect.Map:
+		// arbitrary keys are accepted, i.e. keys
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			walkYAMLNode(node.Content[i+1], t.Elem(), joinYAMLPath(path, node.Content[i].Value), unknown)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			walkYAMLNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), unknown)
+		}
+	}
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := field.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+func joinYAMLPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
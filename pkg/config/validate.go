@@ -0,0 +1,860 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livekit/protocol/webhook"
+)
+
+var supportedCodecs = map[string]bool{
+	"audio/opus": true,
+	"video/vp8":  true,
+	"video/h264": true,
+	"video/vp9":  true,
+	"video/av1":  true,
+}
+
+var validNodeSelectorKinds = map[string]bool{
+	"":             true,
+	"random":       true,
+	"sysload":      true,
+	"regionaware":  true,
+	"least_loaded": true,
+}
+
+var validNodeStats = map[string]bool{
+	NodeStatCPU:             true,
+	NodeStatMemory:          true,
+	NodeStatGoroutines:      true,
+	NodeStatPeerConnections: true,
+	NodeStatParticipants:    true,
+	NodeStatTracks:          true,
+	NodeStatBandwidth:       true,
+}
+
+// nodes whose stats are 5s old aren't selected, leaving time for an update to be late
+const maxNodeStatsInterval = 3 * time.Second
+
+// webhook events sent by the server, the protocol's along with the ones defined in pkg/telemetry
+var validWebHookEvents = map[string]bool{
+	webhook.EventRoomStarted:       true,
+	webhook.EventRoomFinished:      true,
+	webhook.EventParticipantJoined: true,
+	webhook.EventParticipantLeft:   true,
+	webhook.EventRecordingStarted:  true,
+	webhook.EventRecordingFinished: true,
+	webhook.EventEgressStarted:     true,
+	webhook.EventEgressEnded:       true,
+	"room_metadata_changed":        true,
+	"participant_updated":          true,
+	"participant_pending":          true,
+	"track_published":              true,
+	"track_unpublished":            true,
+	"track_muted":                  true,
+	"track_unmuted":                true,
+	"track_silenced":               true,
+	"track_unsilenced":             true,
+	"room_reassigned":              true,
+}
+
+type portUsage struct {
+	port uint32
+	name string
+}
+
+// Validate performs a full validation pass over the configuration and returns all problems found
+func (conf *Config) Validate() []error {
+	var errs []error
+	errs = append(errs, conf.validatePorts()...)
+	errs = append(errs, conf.validateNAT1To1()...)
+	errs = append(errs, conf.validateIPDiscovery()...)
+	errs = append(errs, conf.validateICEFilters()...)
+	errs = append(errs, conf.validateDTLS()...)
+	errs = append(errs, conf.validateDataChannels()...)
+	errs = append(errs, conf.validateCodecs()...)
+	errs = append(errs, conf.validateInterceptors()...)
+	errs = append(errs, conf.validateCongestionControl()...)
+	errs = append(errs, conf.validatePacer()...)
+	errs = append(errs, conf.validatePacketBuffer()...)
+	errs = append(errs, conf.validateConnectionQuality()...)
+	errs = append(errs, conf.validatePLIThrottle()...)
+	errs = append(errs, conf.validateKeyFrameCache()...)
+	errs = append(errs, conf.validateMaxSpatialLayers()...)
+	errs = append(errs, conf.validateAudio()...)
+	errs = append(errs, conf.validatePrometheus()...)
+	errs = append(errs, conf.validateTURN()...)
+	errs = append(errs, conf.validateStorage()...)
+	errs = append(errs, conf.validateNodeSelector()...)
+	errs = append(errs, conf.validateNodeStats()...)
+	errs = append(errs, conf.validateKeys()...)
+	errs = append(errs, conf.validateLimits()...)
+	errs = append(errs, conf.validateWebHook()...)
+	errs = append(errs, conf.validateTelemetry()...)
+	return errs
+}
+
+func (conf *Config) validatePorts() []error {
+	var errs []error
+
+	tcpPorts := []portUsage{
+		{conf.Port, "port"},
+		{conf.Prometheus.Port, "prometheus.port"},
+		{conf.RTC.TCPPort, "rtc.tcp_port"},
+	}
+	udpPorts := []portUsage{
+		{conf.RTC.UDPPort, "rtc.udp_port"},
+	}
+	if conf.TURN.Enabled {
+		tcpPorts = append(tcpPorts, portUsage{uint32(conf.TURN.TLSPort), "turn.tls_port"})
+		udpPorts = append(udpPorts, portUsage{uint32(conf.TURN.UDPPort), "turn.udp_port"})
+	}
+	errs = append(errs, findPortCollisions("TCP", tcpPorts)...)
+	errs = append(errs, findPortCollisions("UDP", udpPorts)...)
+
+	start, end := conf.RTC.ICEPortRangeStart, conf.RTC.ICEPortRangeEnd
+	if start != 0 || end != 0 {
+		if start == 0 || end == 0 {
+			errs = append(errs, fmt.Errorf("rtc.port_range_start and rtc.port_range_end must be set together"))
+		} else if start > end {
+			errs = append(errs, fmt.Errorf("rtc.port_range_start (%d) is greater than rtc.port_range_end (%d)", start, end))
+		} else {
+			for _, p := range udpPorts {
+				if p.port >= start && p.port <= end {
+					errs = append(errs, fmt.Errorf("%s (%d) is within the ICE port range %d-%d", p.name, p.port, start, end))
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+func (conf *Config) validateNAT1To1() []error {
+	var errs []error
+	switch conf.RTC.NAT1To1CandidateType {
+	case "", "host", "srflx":
+	default:
+		errs = append(errs, fmt.Errorf("rtc.nat_1to1_candidate_type must be host or srflx, got %s", conf.RTC.NAT1To1CandidateType))
+	}
+	for _, mapping := range conf.RTC.NAT1To1IPs {
+		ips := strings.Split(mapping, "/")
+		if len(ips) > 2 {
+			errs = append(errs, fmt.Errorf("invalid rtc.nat_1to1_ips entry %s, expected external or external/internal", mapping))
+			continue
+		}
+		for _, ip := range ips {
+			if net.ParseIP(ip) == nil {
+				errs = append(errs, fmt.Errorf("invalid IP %s in rtc.nat_1to1_ips entry %s", ip, mapping))
+			}
+		}
+	}
+	return errs
+}
+
+func (conf *Config) validateIPDiscovery() []error {
+	var errs []error
+	for _, strategy := range conf.RTC.IPDiscovery.Strategies {
+		switch strategy {
+		case IPDiscoveryStrategySTUN, IPDiscoveryStrategyInterface, IPDiscoveryStrategyDisabled:
+		case IPDiscoveryStrategyHTTP:
+			if conf.RTC.IPDiscovery.HTTPURL == "" {
+				errs = append(errs, fmt.Errorf("rtc.ip_discovery.http_url is required for the http strategy"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unsupported rtc.ip_discovery strategy: %s", strategy))
+		}
+	}
+	return errs
+}
+
+func (conf *Config) validateICEFilters() []error {
+	var errs []error
+	for name, patterns := range map[string][]string{
+		"rtc.interfaces.include": conf.RTC.Interfaces.Include,
+		"rtc.interfaces.exclude": conf.RTC.Interfaces.Exclude,
+	} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("invalid pattern %s in %s", pattern, name))
+			}
+		}
+	}
+	ipNets := make(map[string][]*net.IPNet)
+	for name, cidrs := range map[string][]string{
+		"rtc.ips.include": conf.RTC.IPs.Include,
+		"rtc.ips.exclude": conf.RTC.IPs.Exclude,
+	} {
+		for _, cidr := range cidrs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid CIDR %s in %s", cidr, name))
+				continue
+			}
+			ipNets[name] = append(ipNets[name], ipNet)
+		}
+	}
+	if len(errs) == 0 && len(ipNets) > 0 {
+		errs = append(errs, conf.validateIPsPerInterface(ipNets["rtc.ips.include"], ipNets["rtc.ips.exclude"])...)
+	}
+	if err := ValidateICECandidateTypes(conf.RTC.ICECandidateTypes); err != nil {
+		errs = append(errs, fmt.Errorf("rtc.ice_candidate_types: %v", err))
+	} else if IsRelayOnly(conf.RTC.ICECandidateTypes) && !conf.HasTURNServer() {
+		errs = append(errs, fmt.Errorf("rtc.ice_candidate_types: %w", ErrRelayWithoutTURN))
+	}
+	return errs
+}
+
+// validateIPsPerInterface rejects rtc.ips rules that can't be applied on this host. pion can't filter single
+// addresses at this version, only whole interfaces, so the disallowed addresses of an interface in use would still be
+// gathered as candidates
+func (conf *Config) validateIPsPerInterface(include, exclude []*net.IPNet) []error {
+	addrsByInterface, err := localInterfaceAddrs()
+	if err != nil {
+		return []error{fmt.Errorf("rtc.ips: could not list interfaces: %v", err)}
+	}
+	var errs []error
+	for name, ips := range addrsByInterface {
+		if len(conf.RTC.Interfaces.Include) > 0 && !matchesAny(conf.RTC.Interfaces.Include, name) ||
+			matchesAny(conf.RTC.Interfaces.Exclude, name) {
+			continue
+		}
+		var allowed, disallowed []string
+		for _, ip := range ips {
+			if (len(include) == 0 || containsIP(include, ip)) && !containsIP(exclude, ip) {
+				allowed = append(allowed, ip.String())
+			} else {
+				disallowed = append(disallowed, ip.String())
+			}
+		}
+		if len(allowed) > 0 && len(disallowed) > 0 {
+			errs = append(errs, fmt.Errorf("rtc.ips: interface %s has addresses both allowed (%s) and not allowed (%s), "+
+				"interfaces are filtered as a whole. Exclude the interface with rtc.interfaces instead",
+				name, strings.Join(allowed, ", "), strings.Join(disallowed, ", ")))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return errs
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(ipNets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (conf *Config) validateDTLS() []error {
+	var errs []error
+	if (conf.RTC.DTLSCertFile == "") != (conf.RTC.DTLSKeyFile == "") {
+		errs = append(errs, fmt.Errorf("rtc.dtls_cert_file and rtc.dtls_key_file must be set together"))
+	}
+	if conf.RTC.DTLSCertFile != "" && conf.RTC.DTLSPerConnectionCert {
+		errs = append(errs, fmt.Errorf("rtc.dtls_cert_file cannot be used with rtc.dtls_per_connection_cert"))
+	}
+	return errs
+}
+
+func (conf *Config) validateCodecs() []error {
+	var errs []error
+	for _, codec := range conf.Room.EnabledCodecs {
+		if !supportedCodecs[strings.ToLower(codec.Mime)] {
+			errs = append(errs, fmt.Errorf("unsupported room.enabled_codecs mime: %s", codec.Mime))
+		}
+	}
+	if conf.Room.MaxMetadataSize < 0 {
+		errs = append(errs, fmt.Errorf("room.max_metadata_size cannot be negative"))
+	}
+	if conf.Room.BlockRejoinDuration < 0 {
+		errs = append(errs, fmt.Errorf("room.block_rejoin_duration cannot be negative"))
+	}
+	if conf.Room.MaxDataSize < 0 {
+		errs = append(errs, fmt.Errorf("room.max_data_size cannot be negative"))
+	}
+	if conf.Room.ApprovalTimeout < 0 {
+		errs = append(errs, fmt.Errorf("room.approval_timeout cannot be negative"))
+	}
+	return errs
+}
+
+func (conf *Config) validateInterceptors() []error {
+	var errs []error
+	for direction, interceptors := range map[string]DirectionInterceptorsConfig{
+		"publisher":  conf.RTC.Interceptors.Publisher,
+		"subscriber": conf.RTC.Interceptors.Subscriber,
+	} {
+		for name, interceptor := range map[string]IntervalInterceptorConfig{
+			"receiver_reports": interceptors.ReceiverReports,
+			"sender_reports":   interceptors.SenderReports,
+			"twcc_feedback":    interceptors.TWCCFeedback,
+			"nack_generator":   interceptors.NACKGenerator,
+		} {
+			if interceptor.Interval < 0 {
+				errs = append(errs, fmt.Errorf("rtc.interceptors.%s.%s.interval cannot be negative", direction, name))
+			}
+		}
+	}
+	return errs
+}
+
+func (conf *Config) validateCongestionControl() []error {
+	var errs []error
+	cc := conf.RTC.CongestionControl
+	if cc.ProbeDuration < 0 {
+		errs = append(errs, fmt.Errorf("rtc.congestion_control.probe_duration cannot be negative"))
+	}
+	if cc.ProbeInterval < 0 {
+		errs = append(errs, fmt.Errorf("rtc.congestion_control.probe_interval cannot be negative"))
+	}
+	if cc.ProbeBackoffFactor != 0 && cc.ProbeBackoffFactor < 1 {
+		errs = append(errs, fmt.Errorf("rtc.congestion_control.probe_backoff_factor (%g) must be at least 1", cc.ProbeBackoffFactor))
+	}
+	switch cc.AllocationStrategy {
+	case "", CongestionControlAllocationStrategyEqual, CongestionControlAllocationStrategyPriorityBySize, CongestionControlAllocationStrategyPinned:
+	default:
+		errs = append(errs, fmt.Errorf("rtc.congestion_control.allocation_strategy %q is not one of equal, priority_by_size, pinned", cc.AllocationStrategy))
+	}
+	return errs
+}
+
+func (conf *Config) validatePacer() []error {
+	var errs []error
+	pacer := conf.RTC.Pacer
+	if pacer.Interval < 0 || pacer.Interval.Duration() > 100*time.Millisecond {
+		errs = append(errs, fmt.Errorf("rtc.pacer.interval (%s) must be between 0 and 100ms", pacer.Interval.Duration()))
+	}
+	if pacer.BurstSize < 0 {
+		errs = append(errs, fmt.Errorf("rtc.pacer.burst_size cannot be negative"))
+	}
+	if pacer.PacingFactor != 0 && pacer.PacingFactor < 1 {
+		errs = append(errs, fmt.Errorf("rtc.pacer.pacing_factor (%g) must be at least 1", pacer.PacingFactor))
+	}
+	if pacer.MaxQueueDelay < 0 {
+		errs = append(errs, fmt.Errorf("rtc.pacer.max_queue_delay cannot be negative"))
+	}
+	return errs
+}
+
+func (conf *Config) validatePacketBuffer() []error {
+	var errs []error
+	pb := conf.RTC.PacketBuffer
+	for _, size := range []struct {
+		name    string
+		packets int
+	}{
+		{"audio", pb.Audio},
+		{"video_low_quality", pb.VideoLowQuality},
+		{"video_mid_quality", pb.VideoMidQuality},
+		{"video_high_quality", pb.VideoHighQuality},
+	} {
+		if size.packets < 0 {
+			errs = append(errs, fmt.Errorf("rtc.packet_buffer.%s cannot be negative", size.name))
+		}
+	}
+	if pb.MaxParticipantBytes < 0 {
+		errs = append(errs, fmt.Errorf("rtc.packet_buffer.max_participant_bytes cannot be negative"))
+	}
+	return errs
+}
+
+func (conf *Config) validateConnectionQuality() []error {
+	var errs []error
+	cq := conf.RTC.ConnectionQuality
+	if cq.UpdateInterval < 0 {
+		errs = append(errs, fmt.Errorf("rtc.connection_quality.update_interval cannot be negative"))
+	}
+	if cq.GoodScore < 0 || cq.ExcellentScore > 5 || cq.GoodScore >= cq.ExcellentScore {
+		errs = append(errs, fmt.Errorf("rtc.connection_quality: good_score (%g) and excellent_score (%g) must be "+
+			"increasing, between 0 and 5", cq.GoodScore, cq.ExcellentScore))
+	}
+	return errs
+}
+
+func (conf *Config) validatePLIThrottle() []error {
+	var errs []error
+	pt := conf.RTC.PLIThrottle
+	throttles := []struct {
+		name     string
+		duration Duration
+	}{
+		{"low_quality", pt.LowQuality},
+		{"mid_quality", pt.MidQuality},
+		{"high_quality", pt.HighQuality},
+		{"camera.low_quality", pt.Camera.LowQuality},
+		{"camera.mid_quality", pt.Camera.MidQuality},
+		{"camera.high_quality", pt.Camera.HighQuality},
+		{"screen_share.low_quality", pt.ScreenShare.LowQuality},
+		{"screen_share.mid_quality", pt.ScreenShare.MidQuality},
+		{"screen_share.high_quality", pt.ScreenShare.HighQuality},
+	}
+	for _, throttle := range throttles {
+		if throttle.duration < 0 {
+			errs = append(errs, fmt.Errorf("rtc.pli_throttle.%s cannot be negative", throttle.name))
+		}
+	}
+	return errs
+}
+
+// validateStreamTracker is run when loading the config, a layer that cannot be tracked
+// would be declared stopped, or never be declared stopped, right away
+func (conf *Config) validateStreamTracker() []error {
+	var errs []error
+	st := conf.RTC.StreamTracker
+	layers := []struct {
+		name string
+		conf StreamTrackerLayerConfig
+	}{
+		{"base_layer", st.BaseLayer},
+		{"upper_layers", st.UpperLayers},
+	}
+	for _, layer := range layers {
+		if layer.conf.SamplesRequired == 0 {
+			errs = append(errs, fmt.Errorf("rtc.stream_tracker.%s.samples_required must be at least 1", layer.name))
+		}
+		if layer.conf.CyclesRequired == 0 {
+			errs = append(errs, fmt.Errorf("rtc.stream_tracker.%s.cycles_required must be at least 1", layer.name))
+		}
+		if layer.conf.CycleDuration <= 0 {
+			errs = append(errs, fmt.Errorf("rtc.stream_tracker.%s.cycle_duration must be positive", layer.name))
+		}
+	}
+	if st.ScreenShare.BaseLayer.CycleDuration < 0 {
+		errs = append(errs, fmt.Errorf("rtc.stream_tracker.screen_share.base_layer.cycle_duration cannot be negative"))
+	}
+	if st.ScreenShare.UpperLayers.CycleDuration < 0 {
+		errs = append(errs, fmt.Errorf("rtc.stream_tracker.screen_share.upper_layers.cycle_duration cannot be negative"))
+	}
+	if st.Hysteresis.ReAddAfter < 0 {
+		errs = append(errs, fmt.Errorf("rtc.stream_tracker.hysteresis.readd_after cannot be negative"))
+	}
+	if st.Hysteresis.RemovalGrace < 0 {
+		errs = append(errs, fmt.Errorf("rtc.stream_tracker.hysteresis.removal_grace cannot be negative"))
+	}
+	if st.BitrateWindow <= 0 {
+		errs = append(errs, fmt.Errorf("rtc.stream_tracker.bitrate_window must be positive"))
+	}
+	if st.FrozenTimeout < 0 {
+		errs = append(errs, fmt.Errorf("rtc.stream_tracker.frozen_timeout cannot be negative"))
+	}
+	return errs
+}
+
+func (conf *Config) validateKeyFrameCache() []error {
+	var errs []error
+	if conf.RTC.KeyFrameCache.MaxFrameSize < 0 {
+		errs = append(errs, fmt.Errorf("rtc.key_frame_cache.max_frame_size cannot be negative"))
+	}
+	return errs
+}
+
+// the VP9 spatial layer id has 3 bits
+const maxSpatialLayersLimit = 8
+
+func (conf *Config) validateMaxSpatialLayers() []error {
+	var errs []error
+	if conf.RTC.MaxSpatialLayers < 1 || conf.RTC.MaxSpatialLayers > maxSpatialLayersLimit {
+		errs = append(errs, fmt.Errorf("rtc.max_spatial_layers must be between 1 and %d", maxSpatialLayersLimit))
+	}
+	return errs
+}
+
+func (conf *Config) validateAudio() []error {
+	var errs []error
+	if conf.Audio.SilenceTimeout < 0 {
+		errs = append(errs, fmt.Errorf("audio.silence_timeout cannot be negative"))
+	}
+	if conf.Audio.SilenceWebhook && conf.Audio.SilenceTimeout == 0 {
+		errs = append(errs, fmt.Errorf("audio.silence_webhook requires audio.silence_timeout"))
+	}
+	if conf.Audio.ActiveSpeakers.TopN < 0 {
+		errs = append(errs, fmt.Errorf("audio.active_speakers.top_n cannot be negative"))
+	}
+	if conf.Audio.ActiveSpeakers.HoldDuration < 0 {
+		errs = append(errs, fmt.Errorf("audio.active_speakers.hold_duration cannot be negative"))
+	}
+	if conf.Audio.MaxForwardedTracks < 0 {
+		errs = append(errs, fmt.Errorf("audio.max_forwarded_tracks cannot be negative"))
+	}
+	return errs
+}
+
+func (conf *Config) validateDataChannels() []error {
+	var errs []error
+	channels := []struct {
+		name string
+		opts DataChannelOptions
+	}{
+		{"reliable", conf.RTC.DataChannel.Reliable},
+		{"lossy", conf.RTC.DataChannel.Lossy},
+	}
+	for _, c := range channels {
+		if c.opts.MaxRetransmits != nil && c.opts.MaxPacketLifeTime != nil {
+			errs = append(errs, fmt.Errorf("rtc.data_channel.%s: max_retransmits and max_packet_life_time cannot both be set", c.name))
+		}
+	}
+	if conf.RTC.SCTP.MaxMessageSize > MaxSCTPMessageSize {
+		errs = append(errs, fmt.Errorf("rtc.sctp.max_message_size (%d) cannot exceed %d", conf.RTC.SCTP.MaxMessageSize, MaxSCTPMessageSize))
+	}
+	return errs
+}
+
+func (conf *Config) validatePrometheus() []error {
+	var errs []error
+	prom := conf.Prometheus
+	if conf.PrometheusPort != 0 && prom.Port != conf.PrometheusPort {
+		errs = append(errs, fmt.Errorf("prometheus_port (%d) and prometheus.port (%d) are both set", conf.PrometheusPort, prom.Port))
+	}
+	if (prom.TLS.CertFile == "") != (prom.TLS.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("prometheus.tls.cert_file and prometheus.tls.key_file must be set together"))
+	}
+	if (prom.Username == "") != (prom.Password == "") {
+		errs = append(errs, fmt.Errorf("prometheus.username and prometheus.password must be set together"))
+	}
+	if prom.MaxRoomLabels < 0 {
+		errs = append(errs, fmt.Errorf("prometheus.max_room_labels cannot be negative"))
+	}
+	for i, bucket := range prom.JoinLatencyBuckets {
+		if bucket <= 0 || (i > 0 && bucket <= prom.JoinLatencyBuckets[i-1]) {
+			errs = append(errs, fmt.Errorf("prometheus.join_latency_buckets must be positive and increasing"))
+			break
+		}
+	}
+	return errs
+}
+
+func findPortCollisions(protocol string, ports []portUsage) []error {
+	var errs []error
+	used := make(map[uint32]string)
+	for _, p := range ports {
+		if p.port == 0 {
+			continue
+		}
+		if other, ok := used[p.port]; ok {
+			errs = append(errs, fmt.Errorf("%s port %d is used by both %s and %s", protocol, p.port, other, p.name))
+			continue
+		}
+		used[p.port] = p.name
+	}
+	return errs
+}
+
+func (conf *Config) validateTURN() []error {
+	if !conf.TURN.Enabled {
+		return nil
+	}
+
+	var errs []error
+	if conf.TURN.TLSPort <= 0 && conf.TURN.UDPPort <= 0 {
+		errs = append(errs, fmt.Errorf("turn is enabled but neither turn.tls_port nor turn.udp_port is set"))
+	}
+	if conf.TURN.TLSPort > 0 {
+		if conf.TURN.Domain == "" {
+			errs = append(errs, fmt.Errorf("turn.domain is required when turn.tls_port is set"))
+		}
+		if !conf.TURN.ExternalTLS && !conf.TURN.ACME.Enabled() && (conf.TURN.CertFile == "" || conf.TURN.KeyFile == "") {
+			errs = append(errs, fmt.Errorf("turn.cert_file and turn.key_file are required unless turn.external_tls or turn.acme is set"))
+		}
+	}
+	if conf.TURN.ACME.Enabled() {
+		if conf.TURN.TLSPort <= 0 {
+			errs = append(errs, fmt.Errorf("turn.acme requires turn.tls_port"))
+		}
+		if conf.TURN.ExternalTLS {
+			errs = append(errs, fmt.Errorf("turn.acme cannot be used with turn.external_tls"))
+		}
+		if conf.TURN.CertFile != "" || conf.TURN.KeyFile != "" {
+			errs = append(errs, fmt.Errorf("turn.acme cannot be used with turn.cert_file and turn.key_file"))
+		}
+	} else if conf.TURN.ACME.Email != "" || conf.TURN.ACME.DirectoryURL != "" {
+		errs = append(errs, fmt.Errorf("turn.acme.cache_dir is required"))
+	}
+
+	start, end := conf.TURN.RelayPortRangeStart, conf.TURN.RelayPortRangeEnd
+	if start == 0 || end == 0 {
+		errs = append(errs, fmt.Errorf("turn.relay_port_range_start and turn.relay_port_range_end must be set"))
+	} else if start > end {
+		errs = append(errs, fmt.Errorf("turn.relay_port_range_start (%d) is greater than turn.relay_port_range_end (%d)", start, end))
+	} else if end > 65535 {
+		errs = append(errs, fmt.Errorf("turn.relay_port_range_end (%d) is not a valid port", end))
+	} else if iceStart, iceEnd := conf.RTC.ICEPortRangeStart, conf.RTC.ICEPortRangeEnd; iceStart != 0 && iceEnd != 0 && start <= iceEnd && iceStart <= end {
+		errs = append(errs, fmt.Errorf("turn relay port range %d-%d overlaps with the ICE port range %d-%d", start, end, iceStart, iceEnd))
+	}
+	if conf.TURN.RelayAddress != "" && net.ParseIP(conf.TURN.RelayAddress) == nil {
+		errs = append(errs, fmt.Errorf("turn.relay_address %s is not an IP address", conf.TURN.RelayAddress))
+	}
+	if conf.TURN.AuthSecret == "" {
+		if conf.TURN.PreviousAuthSecret != "" {
+			errs = append(errs, fmt.Errorf("turn.previous_auth_secret is set without turn.auth_secret"))
+		}
+	} else if conf.TURN.CredentialTTL <= 0 {
+		errs = append(errs, fmt.Errorf("turn.credential_ttl must be positive"))
+	}
+	return errs
+}
+
+func (conf *Config) validateNodeSelector() []error {
+	if !validNodeSelectorKinds[conf.NodeSelector.Kind] {
+		return []error{fmt.Errorf("unsupported node_selector.kind: %s", conf.NodeSelector.Kind)}
+	}
+
+	var errs []error
+	if conf.NodeSelector.SysloadLimit < 0 {
+		errs = append(errs, fmt.Errorf("node_selector.sysload_limit cannot be negative"))
+	}
+	if conf.NodeSelector.Kind == "least_loaded" {
+		if conf.NodeSelector.StatsMaxAge <= 0 {
+			errs = append(errs, fmt.Errorf("node_selector.stats_max_age must be positive"))
+		}
+		w := conf.NodeSelector.Weights
+		if w.CPU < 0 || w.Participants < 0 || w.Bandwidth < 0 {
+			errs = append(errs, fmt.Errorf("node_selector.weights cannot be negative"))
+		} else if w.CPU+w.Participants+w.Bandwidth == 0 {
+			errs = append(errs, fmt.Errorf("node_selector.weights must have at least one positive weight"))
+		}
+	}
+	if conf.NodeSelector.Kind == "regionaware" {
+		if conf.Region == "" {
+			errs = append(errs, fmt.Errorf("region is required when using the regionaware node selector"))
+		} else {
+			found := false
+			for _, region := range conf.NodeSelector.Regions {
+				if region.Name == conf.Region {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("region %s is not listed in node_selector.regions", conf.Region))
+			}
+		}
+		errs = append(errs, conf.validateRegionFallbacks()...)
+		for _, proxy := range conf.NodeSelector.ClientLocation.TrustedProxies {
+			if _, err := ParseIPNet(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("invalid node_selector.client_location.trusted_proxies entry %s", proxy))
+			}
+		}
+	}
+	return errs
+}
+
+func (conf *Config) validateNodeStats() []error {
+	var errs []error
+	interval := conf.NodeStats.Interval
+	if interval <= 0 || interval.Duration() > maxNodeStatsInterval {
+		errs = append(errs, fmt.Errorf("node_stats.interval must be positive and at most %s", maxNodeStatsInterval))
+	} else if conf.NodeSelector.Kind == "least_loaded" && conf.NodeSelector.StatsMaxAge > 0 && interval >= conf.NodeSelector.StatsMaxAge {
+		errs = append(errs, fmt.Errorf("node_stats.interval must be shorter than node_selector.stats_max_age"))
+	}
+	for _, stat := range conf.NodeStats.Include {
+		if !validNodeStats[stat] {
+			errs = append(errs, fmt.Errorf("unsupported node_stats.include entry %s", stat))
+		}
+	}
+	return errs
+}
+
+func (conf *Config) validateRegionFallbacks() []error {
+	regions := make(map[string]bool, len(conf.NodeSelector.Regions))
+	for _, region := range conf.NodeSelector.Regions {
+		regions[region.Name] = true
+	}
+
+	var errs []error
+	for _, region := range conf.NodeSelector.Regions {
+		seen := make(map[string]bool, len(region.Fallback))
+		for _, fallback := range region.Fallback {
+			switch {
+			case fallback == region.Name:
+				errs = append(errs, fmt.Errorf("region %s cannot fall back to itself", region.Name))
+			case !regions[fallback]:
+				errs = append(errs, fmt.Errorf("region %s falls back to %s, which is not listed in node_selector.regions", region.Name, fallback))
+			case seen[fallback]:
+				errs = append(errs, fmt.Errorf("region %s lists fallback %s more than once", region.Name, fallback))
+			}
+			seen[fallback] = true
+		}
+	}
+	return errs
+}
+
+func (conf *Config) validateKeys() []error {
+	var errs []error
+	if conf.KeyRemovalGrace < 0 {
+		errs = append(errs, fmt.Errorf("key_removal_grace cannot be negative"))
+	}
+	if (conf.Admin.APIKey == "") != (conf.Admin.APISecret == "") {
+		errs = append(errs, fmt.Errorf("admin.api_key and admin.api_secret must be set together"))
+	}
+	return errs
+}
+
+func (conf *Config) validateLimits() []error {
+	var errs []error
+	if conf.Limit.NumTracks < 0 {
+		errs = append(errs, fmt.Errorf("limit.num_tracks cannot be negative"))
+	}
+	if conf.Limit.BytesPerSec < 0 {
+		errs = append(errs, fmt.Errorf("limit.bytes_per_sec cannot be negative"))
+	}
+	if conf.DrainTimeout < 0 {
+		errs = append(errs, fmt.Errorf("drain_timeout cannot be negative"))
+	}
+	for key, limits := range conf.LimitsPerKey {
+		if limits.NumTracks < 0 || limits.BytesPerSec < 0 || limits.MaxRooms < 0 || limits.MaxParticipantsPerRoom < 0 {
+			errs = append(errs, fmt.Errorf("limits_per_key.%s cannot have negative limits", key))
+		}
+		// keys from key_file are only loaded at startup, so they can't be checked here
+		if _, ok := conf.Keys[key]; !ok && conf.KeyFile == "" {
+			errs = append(errs, fmt.Errorf("limits_per_key.%s is not found in keys", key))
+		}
+	}
+	return errs
+}
+
+func (conf *Config) validateWebHook() []error {
+	if len(conf.WebHook.URLs) == 0 {
+		return nil
+	}
+	var errs []error
+	if conf.WebHook.APIKey == "" {
+		errs = append(errs, fmt.Errorf("webhook.api_key is required when webhook urls are set"))
+	} else if conf.KeyFile == "" {
+		// keys from key_file are only loaded at startup, so they can't be checked here
+		if _, ok := conf.Keys[conf.WebHook.APIKey]; !ok {
+			errs = append(errs, fmt.Errorf("webhook.api_key %s is not found in keys", conf.WebHook.APIKey))
+		}
+	}
+
+	for i, u := range conf.WebHook.URLs {
+		if u.URL == "" {
+			errs = append(errs, fmt.Errorf("webhook.urls[%d].url is required", i))
+		}
+		for _, event := range u.Events {
+			if !validWebHookEvents[event] {
+				errs = append(errs, fmt.Errorf("webhook.urls[%d].events: unsupported event %s", i, event))
+			}
+		}
+	}
+
+	retry := conf.WebHook.Retry
+	if retry.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("webhook.retry.max_attempts must be at least 1"))
+	}
+	durations := []struct {
+		name  string
+		value Duration
+	}{
+		{"max_age", retry.MaxAge},
+		{"initial_backoff", retry.InitialBackoff},
+		{"max_backoff", retry.MaxBackoff},
+		{"timeout", retry.Timeout},
+		{"drain_timeout", retry.DrainTimeout},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("webhook.retry.%s cannot be negative", d.name))
+		}
+	}
+	if retry.MaxBackoff < retry.InitialBackoff {
+		errs = append(errs, fmt.Errorf("webhook.retry.max_backoff (%s) cannot be less than initial_backoff (%s)",
+			retry.MaxBackoff.Duration(), retry.InitialBackoff.Duration()))
+	}
+	return errs
+}
+
+func (conf *Config) validateStorage() []error {
+	var errs []error
+	switch conf.StorageType() {
+	case StorageTypeRedis:
+		if !conf.HasRedis() {
+			errs = append(errs, fmt.Errorf("storage.type redis requires redis.address or redis.cluster_addresses"))
+		}
+		if conf.Storage.Redis.ParticipantTTL <= 0 {
+			errs = append(errs, fmt.Errorf("storage.redis.participant_ttl must be positive"))
+		}
+		if conf.Storage.Redis.CleanupInterval <= 0 {
+			errs = append(errs, fmt.Errorf("storage.redis.cleanup_interval must be positive"))
+		}
+	case StorageTypeLocal:
+		if conf.HasRedis() {
+			errs = append(errs, fmt.Errorf("storage.type local cannot be shared by nodes routed through redis, use redis or postgres"))
+		}
+		if conf.Storage.Local.DataDir != "" && conf.Storage.Local.SnapshotInterval <= 0 {
+			errs = append(errs, fmt.Errorf("storage.local.snapshot_interval must be positive"))
+		}
+	case StorageTypePostgres:
+		pg := conf.Storage.Postgres
+		if pg.DSN == "" {
+			errs = append(errs, fmt.Errorf("storage.postgres.dsn is required"))
+		}
+		if pg.MaxOpenConns < 0 {
+			errs = append(errs, fmt.Errorf("storage.postgres.max_open_conns cannot be negative"))
+		}
+		if pg.ParticipantTTL <= 0 {
+			errs = append(errs, fmt.Errorf("storage.postgres.participant_ttl must be positive"))
+		}
+		if pg.CleanupInterval <= 0 {
+			errs = append(errs, fmt.Errorf("storage.postgres.cleanup_interval must be positive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported storage.type: %s", conf.Storage.Type))
+	}
+	return errs
+}
+
+func (conf *Config) validateTelemetry() []error {
+	var errs []error
+	if conf.Telemetry.TrackQuality.Enabled && conf.Telemetry.TrackQuality.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("telemetry.track_quality.interval must be positive"))
+	}
+
+	otlp := conf.Telemetry.OTLP
+	if otlp.Endpoint == "" {
+		return errs
+	}
+	if strings.HasPrefix(otlp.Endpoint, "http://") && !otlp.Insecure {
+		errs = append(errs, fmt.Errorf("telemetry.otlp.endpoint %s is plaintext, set telemetry.otlp.insecure to allow it", otlp.Endpoint))
+	}
+	if otlp.BatchSize < 1 {
+		errs = append(errs, fmt.Errorf("telemetry.otlp.batch_size must be at least 1"))
+	}
+	if otlp.MaxQueueSize < otlp.BatchSize {
+		errs = append(errs, fmt.Errorf("telemetry.otlp.max_queue_size (%d) cannot be less than batch_size (%d)", otlp.MaxQueueSize, otlp.BatchSize))
+	}
+	if otlp.BatchTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("telemetry.otlp.batch_timeout must be positive"))
+	}
+	if otlp.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("telemetry.otlp.timeout cannot be negative"))
+	}
+	return errs
+}
+
+// ParseIPNet parses an address or a CIDR range, addresses being ranges of a single address
+func ParseIPNet(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(s)
+	return ipNet, err
+}
@@ -0,0 +1,425 @@
+package config
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ValidateGood(t *testing.T) {
+	const content = `port: 7880
+rtc:
+  tcp_port: 7881
+  port_range_start: 50000
+  port_range_end: 60000
+  enable_rtx: false
+turn:
+  enabled: true
+  domain: turn.example.com
+  tls_port: 5349
+  udp_port: 3478
+  external_tls: true
+node_selector:
+  kind: sysload
+keys:
+  key1: secret1
+webhook:
+  api_key: key1
+  urls:
+    - https://example.com/webhook
+`
+	conf, err := NewConfig(content, nil)
+	require.NoError(t, err)
+	require.Empty(t, conf.Validate())
+	require.False(t, conf.RTC.EnableRTX)
+
+	// enabled unless turned off
+	conf, err = NewConfig("", nil)
+	require.NoError(t, err)
+	require.True(t, conf.RTC.EnableRTX)
+}
+
+func TestConfig_ValidateBad(t *testing.T) {
+	const content = `port: 7880
+rtc:
+  tcp_port: 7880
+  udp_port: 55000
+  port_range_start: 50000
+  port_range_end: 60000
+  dtls_cert_file: /path/to/dtls.pem
+  ice_candidate_types: [relay, local]
+  max_spatial_layers: 9
+  data_channel:
+    lossy:
+      max_retransmits: 2
+      max_packet_life_time: 500
+  sctp:
+    max_message_size: 262144
+  interceptors:
+    subscriber:
+      sender_reports:
+        enabled: true
+        interval: -1s
+  congestion_control:
+    probe_duration: -1s
+    probe_backoff_factor: 0.5
+    allocation_strategy: largest_first
+  pacer:
+    enabled: true
+    interval: 1s
+    pacing_factor: 0.8
+  packet_buffer:
+    video_high_quality: -1
+  connection_quality:
+    excellent_score: 2
+  pli_throttle:
+    screen_share:
+      high_quality: -5s
+  key_frame_cache:
+    enabled: true
+    max_frame_size: -1
+audio:
+  silence_webhook: true
+  active_speakers:
+    top_n: -1
+  max_forwarded_tracks: -3
+turn:
+  enabled: true
+  domain: turn.example.com
+  tls_port: 7881
+  udp_port: 3478
+  previous_auth_secret: old-secret
+  acme:
+    email: admin@example.com
+  relay_port_range_start: 40000
+  relay_port_range_end: 55000
+  relay_address: turn.example.com
+room:
+  enabled_codecs:
+    - mime: video/vp9
+    - mime: video/hevc
+  max_metadata_size: -1
+  block_rejoin_duration: -1m
+  max_data_size: -1
+  approval_timeout: -1s
+node_selector:
+  kind: closest
+limit:
+  num_tracks: -1
+prometheus:
+  max_room_labels: -1
+  join_latency_buckets: [0.5, 0.1]
+drain_timeout: -1m
+key_removal_grace: -1h
+admin:
+  api_key: admin
+limits_per_key:
+  key3:
+    max_rooms: 5
+keys:
+  key1: secret1
+webhook:
+  api_key: key2
+  urls:
+    - https://example.com/webhook
+    - url: https://example.com/tracks
+      events: [track_published, track_moved]
+  retry:
+    max_attempts: 0
+storage:
+  type: postgres
+  postgres:
+    participant_ttl: -1h
+telemetry:
+  otlp:
+    endpoint: http://collector:4318
+    batch_size: 100
+    max_queue_size: 10
+    timeout: -1s
+  track_quality:
+    enabled: true
+    interval: 0s
+`
+	conf, err := NewConfig(content, nil)
+	require.NoError(t, err)
+
+	errs := conf.Validate()
+	expected := []string{
+		"TCP port 7880 is used by both port and rtc.tcp_port",
+		"rtc.udp_port (55000) is within the ICE port range",
+		"rtc.dtls_cert_file and rtc.dtls_key_file must be set together",
+		"unsupported ICE candidate type: local",
+		"rtc.data_channel.lossy: max_retransmits and max_packet_life_time cannot both be set",
+		"rtc.sctp.max_message_size (262144) cannot exceed 65536",
+		"rtc.interceptors.subscriber.sender_reports.interval cannot be negative",
+		"rtc.congestion_control.probe_duration cannot be negative",
+		"rtc.congestion_control.probe_backoff_factor (0.5) must be at least 1",
+		`rtc.congestion_control.allocation_strategy "largest_first" is not one of equal, priority_by_size, pinned`,
+		"rtc.pacer.interval (1s) must be between 0 and 100ms",
+		"rtc.pacer.pacing_factor (0.8) must be at least 1",
+		"rtc.packet_buffer.video_high_quality cannot be negative",
+		"rtc.connection_quality: good_score (2.5) and excellent_score (2) must be increasing, between 0 and 5",
+		"rtc.pli_throttle.screen_share.high_quality cannot be negative",
+		"rtc.key_frame_cache.max_frame_size cannot be negative",
+		"rtc.max_spatial_layers must be between 1 and 8",
+		"audio.silence_webhook requires audio.silence_timeout",
+		"audio.active_speakers.top_n cannot be negative",
+		"audio.max_forwarded_tracks cannot be negative",
+		"unsupported room.enabled_codecs mime: video/hevc",
+		"room.max_metadata_size cannot be negative",
+		"room.block_rejoin_duration cannot be negative",
+		"room.max_data_size cannot be negative",
+		"room.approval_timeout cannot be negative",
+		"turn.cert_file and turn.key_file are required unless turn.external_tls or turn.acme is set",
+		"unsupported node_selector.kind: closest",
+		"limit.num_tracks cannot be negative",
+		"prometheus.max_room_labels cannot be negative",
+		"prometheus.join_latency_buckets must be positive and increasing",
+		"drain_timeout cannot be negative",
+		"key_removal_grace cannot be negative",
+		"admin.api_key and admin.api_secret must be set together",
+		"webhook.api_key key2 is not found in keys",
+		"webhook.urls[1].events: unsupported event track_moved",
+		"webhook.retry.max_attempts must be at least 1",
+		"turn.previous_auth_secret is set without turn.auth_secret",
+		"turn.acme.cache_dir is required",
+		"storage.postgres.dsn is required",
+		"storage.postgres.participant_ttl must be positive",
+		"turn relay port range 40000-55000 overlaps with the ICE port range 50000-60000",
+		"turn.relay_address turn.example.com is not an IP address",
+		"telemetry.otlp.endpoint http://collector:4318 is plaintext, set telemetry.otlp.insecure to allow it",
+		"telemetry.otlp.max_queue_size (10) cannot be less than batch_size (100)",
+		"telemetry.otlp.timeout cannot be negative",
+		"telemetry.track_quality.interval must be positive",
+		"limits_per_key.key3 is not found in keys",
+	}
+	require.Len(t, errs, len(expected))
+	for _, msg := range expected {
+		found := false
+		for _, err := range errs {
+			if strings.Contains(err.Error(), msg) {
+				found = true
+				break
+			}
+		}
+		require.True(t, found, "missing error: %s", msg)
+	}
+}
+
+func TestConfig_ValidatePortRange(t *testing.T) {
+	conf, err := NewConfig("", nil)
+	require.NoError(t, err)
+
+	conf.RTC.ICEPortRangeStart = 60000
+	conf.RTC.ICEPortRangeEnd = 50000
+	errs := conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "greater than")
+
+	conf.RTC.ICEPortRangeStart = 50000
+	conf.RTC.ICEPortRangeEnd = 60000
+	conf.TURN.Enabled = true
+	conf.TURN.UDPPort = 3478
+	conf.TURN.RelayPortRangeStart = 40000
+	conf.TURN.RelayPortRangeEnd = 49999
+	require.Empty(t, conf.Validate())
+
+	conf.TURN.RelayPortRangeEnd = 50000
+	errs = conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "overlaps with the ICE port range")
+}
+
+func TestConfig_ValidateRelayOnly(t *testing.T) {
+	conf, err := NewConfig("", nil)
+	require.NoError(t, err)
+
+	conf.RTC.ICECandidateTypes = []string{"relay"}
+	errs := conf.Validate()
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], ErrRelayWithoutTURN)
+
+	conf.RTC.TURNServers = []TURNServer{{Host: "turn.example.com", Port: 443, Protocol: "tls"}}
+	require.Empty(t, conf.Validate())
+}
+
+func TestConfig_ValidateStorage(t *testing.T) {
+	conf, err := NewConfig("", nil)
+	require.NoError(t, err)
+	require.Equal(t, StorageTypeLocal, conf.StorageType())
+
+	conf.Storage.Type = StorageTypeRedis
+	errs := conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "requires redis.address")
+
+	conf.Redis.Address = "localhost:6379"
+	require.Empty(t, conf.Validate())
+
+	conf.Storage.Redis.ParticipantTTL = 0
+	errs = conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "storage.redis.participant_ttl must be positive")
+
+	// the default with redis set
+	conf.Storage.Type = ""
+	require.Equal(t, StorageTypeRedis, conf.StorageType())
+	conf.Storage.Type = StorageTypeLocal
+	errs = conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "storage.type local")
+
+	conf.Redis.Address = ""
+	conf.Storage.Local.DataDir = "/var/lib/livekit"
+	require.Empty(t, conf.Validate())
+	conf.Storage.Local.SnapshotInterval = 0
+	errs = conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "storage.local.snapshot_interval must be positive")
+}
+
+func TestConfig_ValidateLeastLoadedSelector(t *testing.T) {
+	conf, err := NewConfig(`node_selector:
+  kind: least_loaded
+  weights:
+    cpu: 2
+`, nil)
+	require.NoError(t, err)
+	require.Empty(t, conf.Validate())
+	// unset weights keep their default
+	require.Equal(t, NodeLoadWeights{CPU: 2, Participants: 1, Bandwidth: 1}, conf.NodeSelector.Weights)
+
+	conf.NodeSelector.Weights.Bandwidth = -1
+	errs := conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "node_selector.weights cannot be negative")
+
+	conf.NodeSelector.Weights = NodeLoadWeights{}
+	errs = conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "at least one positive weight")
+
+	conf.NodeSelector.Weights.Participants = 1
+	conf.NodeSelector.StatsMaxAge = 0
+	errs = conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "node_selector.stats_max_age must be positive")
+}
+
+func TestConfig_ValidateNodeStats(t *testing.T) {
+	conf, err := NewConfig(`node_stats:
+  interval: 1s
+  include: [cpu, memory, participants]
+`, nil)
+	require.NoError(t, err)
+	require.Empty(t, conf.Validate())
+	require.True(t, conf.NodeStats.Includes(NodeStatMemory))
+	require.False(t, conf.NodeStats.Includes(NodeStatBandwidth))
+
+	conf.NodeStats.Include = append(conf.NodeStats.Include, "disk")
+	errs := conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "unsupported node_stats.include entry disk")
+
+	conf.NodeStats.Include = nil
+	require.True(t, conf.NodeStats.Includes(NodeStatBandwidth))
+	conf.NodeStats.Interval = Duration(10 * time.Second)
+	errs = conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "node_stats.interval must be positive and at most 3s")
+
+	conf.NodeStats.Interval = Duration(3 * time.Second)
+	conf.NodeSelector.Kind = "least_loaded"
+	conf.NodeSelector.StatsMaxAge = Duration(2 * time.Second)
+	errs = conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "node_stats.interval must be shorter than node_selector.stats_max_age")
+}
+
+func TestConfig_ValidateRegionFallbacks(t *testing.T) {
+	conf, err := NewConfig(`region: eu-west
+node_selector:
+  kind: regionaware
+  regions:
+    - name: eu-west
+      lat: 53.35
+      lon: -6.26
+      fallback: [eu-central, us-east]
+    - name: eu-central
+      lat: 50.11
+      lon: 8.68
+    - name: us-east
+      lat: 38.99
+      lon: -77.45
+`, nil)
+	require.NoError(t, err)
+	require.Empty(t, conf.Validate())
+	require.Equal(t, []string{"eu-central", "us-east"}, conf.NodeSelector.Regions[0].Fallback)
+
+	conf.NodeSelector.Regions[0].Fallback = []string{"eu-west", "ap-south", "us-east", "us-east"}
+	errs := conf.Validate()
+	require.Len(t, errs, 3)
+	require.Contains(t, errs[0].Error(), "region eu-west cannot fall back to itself")
+	require.Contains(t, errs[1].Error(), "falls back to ap-south, which is not listed")
+	require.Contains(t, errs[2].Error(), "lists fallback us-east more than once")
+}
+
+func TestConfig_ValidateTrustedProxies(t *testing.T) {
+	conf, err := NewConfig(`region: us-east
+node_selector:
+  kind: regionaware
+  regions:
+    - name: us-east
+      lat: 38.99
+      lon: -77.45
+  geoip_db: /etc/livekit/GeoLite2-City.mmdb
+  client_location:
+    trusted_proxies: [10.0.0.0/8, 192.0.2.1, "2001:db8::/32"]
+`, nil)
+	require.NoError(t, err)
+	require.Empty(t, conf.Validate())
+	require.Equal(t, "/etc/livekit/GeoLite2-City.mmdb", conf.NodeSelector.GeoIPDB)
+
+	conf.NodeSelector.ClientLocation.TrustedProxies = []string{"10.0.0.0/33", "proxy.local"}
+	errs := conf.Validate()
+	require.Len(t, errs, 2)
+	require.Contains(t, errs[0].Error(), "invalid node_selector.client_location.trusted_proxies entry 10.0.0.0/33")
+
+	ipNet, err := ParseIPNet("192.0.2.1")
+	require.NoError(t, err)
+	require.True(t, ipNet.Contains(net.ParseIP("192.0.2.1")))
+	require.False(t, ipNet.Contains(net.ParseIP("192.0.2.2")))
+}
+
+func TestConfig_ValidateIPsPerInterface(t *testing.T) {
+	defer func(addrs func() (map[string][]net.IP, error)) { localInterfaceAddrs = addrs }(localInterfaceAddrs)
+	localInterfaceAddrs = func() (map[string][]net.IP, error) {
+		return map[string][]net.IP{
+			"eth0":    {net.ParseIP("10.0.0.5"), net.ParseIP("172.17.0.1")},
+			"eth1":    {net.ParseIP("10.0.1.5")},
+			"docker0": {net.ParseIP("172.18.0.1")},
+		}, nil
+	}
+
+	conf, err := NewConfig(`rtc:
+  ips:
+    exclude: [172.16.0.0/12]
+`, nil)
+	require.NoError(t, err)
+	errs := conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "interface eth0 has addresses both allowed (10.0.0.5) and not allowed (172.17.0.1)")
+
+	// the interface isn't used
+	conf.RTC.Interfaces.Exclude = []string{"eth0"}
+	require.Empty(t, conf.Validate())
+
+	conf.RTC.Interfaces.Exclude = nil
+	conf.RTC.IPs = IPsConfig{Include: []string{"10.0.0.0/24"}}
+	errs = conf.Validate()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "interface eth0 has addresses both allowed (10.0.0.5) and not allowed (172.17.0.1)")
+}
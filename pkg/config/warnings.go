@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+)
+
+// audio levels range from 0 (loudest) to 127 (silence)
+const maxAudioLevel = 127
+
+type ConfigWarningKind string
+
+const (
+	// a deprecated field is still in use
+	ConfigWarningDeprecated ConfigWarningKind = "deprecated"
+	// a value was out of range and has been clamped
+	ConfigWarningClamped ConfigWarningKind = "clamped"
+	// a value was filled in or overridden on the user's behalf
+	ConfigWarningDefaulted ConfigWarningKind = "defaulted"
+	// a value had the wrong type and has been converted
+	ConfigWarningConverted ConfigWarningKind = "converted"
+)
+
+// ConfigWarning describes a problem found while loading the config that did not prevent it from loading
+type ConfigWarning struct {
+	Kind ConfigWarningKind
+	// yaml path of the field, i.e. rtc.udp_port
+	Field   string
+	Message string
+}
+
+func (w ConfigWarning) String() string {
+	return fmt.Sprintf("%s (%s): %s", w.Field, w.Kind, w.Message)
+}
+
+// Warnings returns the warnings recorded while loading the config, in the order they were found
+func (conf *Config) Warnings() []ConfigWarning {
+	return conf.warnings
+}
+
+func (conf *Config) addWarning(kind ConfigWarningKind, field string, format string, args ...interface{}) {
+	w := ConfigWarning{Kind: kind, Field: field, Message: fmt.Sprintf(format, args...)}
+	// the same problem can be found more than once, i.e. keys from both the config and the CLI
+	for _, existing := range conf.warnings {
+		if existing == w {
+			return
+		}
+	}
+	conf.warnings = append(conf.warnings, w)
+}
+
+// checkDeprecated records warnings for deprecated fields that are still set
+func (conf *Config) checkDeprecated() {
+	if conf.LogLevel != "" {
+		conf.addWarning(ConfigWarningDeprecated, "log_level", "log_level is deprecated, use logging.level instead")
+	}
+	if conf.PrometheusPort != 0 {
+		conf.addWarning(ConfigWarningDeprecated, "prometheus_port", "prometheus_port is deprecated, use prometheus.port instead")
+	}
+}
+
+// clampValues brings out of range values back within their documented range
+func (conf *Config) clampValues() {
+	if conf.Audio.ActiveLevel > maxAudioLevel {
+		conf.addWarning(ConfigWarningClamped, "audio.active_level",
+			"active_level %d is above the maximum of %d, using %d", conf.Audio.ActiveLevel, maxAudioLevel, maxAudioLevel)
+		conf.Audio.ActiveLevel = maxAudioLevel
+	}
+	if conf.Audio.MinPercentile > 100 {
+		conf.addWarning(ConfigWarningClamped, "audio.min_percentile",
+			"min_percentile %d is above 100, using 100", conf.Audio.MinPercentile)
+		conf.Audio.MinPercentile = 100
+	}
+}
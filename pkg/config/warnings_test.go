@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Warnings(t *testing.T) {
+	t.Run("deprecated fields", func(t *testing.T) {
+		conf, err := NewConfig("log_level: debug\nprometheus_port: 6789", nil)
+		require.NoError(t, err)
+		require.Equal(t, "debug", conf.Logging.Level)
+		require.Equal(t, uint32(6789), conf.Prometheus.Port)
+
+		require.Equal(t, []ConfigWarning{
+			{Kind: ConfigWarningDeprecated, Field: "log_level", Message: "log_level is deprecated, use logging.level instead"},
+			{Kind: ConfigWarningDeprecated, Field: "prometheus_port", Message: "prometheus_port is deprecated, use prometheus.port instead"},
+		}, conf.Warnings())
+	})
+
+	t.Run("no warnings for defaults", func(t *testing.T) {
+		conf, err := NewConfig("", nil)
+		require.NoError(t, err)
+		require.Empty(t, conf.Warnings())
+	})
+
+	t.Run("clamped values", func(t *testing.T) {
+		conf, err := NewConfig("audio:\n  active_level: 200\n  min_percentile: 150", nil)
+		require.NoError(t, err)
+		require.Equal(t, uint8(127), conf.Audio.ActiveLevel)
+		require.Equal(t, uint8(100), conf.Audio.MinPercentile)
+		require.Len(t, conf.Warnings(), 2)
+		for _, w := range conf.Warnings() {
+			require.Equal(t, ConfigWarningClamped, w.Kind)
+		}
+	})
+
+	t.Run("development single UDP port", func(t *testing.T) {
+		conf, err := NewConfig("development: true", nil)
+		require.NoError(t, err)
+		require.Equal(t, uint32(7882), conf.RTC.UDPPort)
+		require.Len(t, conf.Warnings(), 1)
+		require.Equal(t, ConfigWarningDefaulted, conf.Warnings()[0].Kind)
+		require.Equal(t, "rtc.udp_port", conf.Warnings()[0].Field)
+	})
+
+	t.Run("duplicates are recorded once", func(t *testing.T) {
+		conf := &Config{}
+		conf.addWarning(ConfigWarningDeprecated, "log_level", "deprecated")
+		conf.addWarning(ConfigWarningDeprecated, "log_level", "deprecated")
+		require.Len(t, conf.Warnings(), 1)
+	})
+}
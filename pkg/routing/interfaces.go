@@ -2,12 +2,16 @@ package routing
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
@@ -38,6 +42,11 @@ type ParticipantInit struct {
 	Recorder      bool
 	Client        *livekit.ClientInfo
 	Grants        *auth.ClaimGrants
+	// cap on the bitrate sent to the participant, 0 for none
+	MaxSubscribeBitrate uint64
+	// when the join request reached the signal node and its token was validated, for join latency
+	JoinStartedAt    time.Time
+	TokenValidatedAt time.Time
 }
 
 type NewParticipantCallback func(ctx context.Context, roomName livekit.RoomName, pi ParticipantInit, requestSource MessageSource, responseSink MessageSink)
@@ -53,6 +62,8 @@ type Router interface {
 	RemoveDeadNodes() error
 
 	ListNodes() ([]*livekit.Node, error)
+	// GetNodeStatsReports returns the stats reports of nodes by ID, nodes from before reports were published have none
+	GetNodeStatsReports() (map[string]*prometheus.NodeStatsReport, error)
 
 	GetNodeForRoom(ctx context.Context, roomName livekit.RoomName) (*livekit.Node, error)
 	SetNodeForRoom(ctx context.Context, roomName livekit.RoomName, nodeId livekit.NodeID) error
@@ -78,12 +89,12 @@ type MessageRouter interface {
 	WriteRoomRTC(ctx context.Context, roomName livekit.RoomName, msg *livekit.RTCNodeMessage) error
 }
 
-func CreateRouter(rc *redis.Client, node LocalNode) Router {
+func CreateRouter(conf *config.Config, rc redis.UniversalClient, node LocalNode) Router {
 	if rc != nil {
-		return NewRedisRouter(node, rc)
+		return NewRedisRouter(conf, node, rc)
 	}
 
 	// local routing and store
 	logger.Infow("using single-node routing")
-	return NewLocalRouter(node)
+	return NewLocalRouter(conf, node)
 }
@@ -9,6 +9,9 @@ import (
 	"github.com/livekit/protocol/logger"
 	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
 // a router of messages on the same node, basic implementation for local testing
@@ -21,17 +24,19 @@ type LocalRouter struct {
 	isStarted        atomic.Bool
 
 	rtcMessageChan *MessageChannel
+	stats          *NodeStatsReporter
 
 	onNewParticipant NewParticipantCallback
 	onRTCMessage     RTCMessageCallback
 }
 
-func NewLocalRouter(currentNode LocalNode) *LocalRouter {
+func NewLocalRouter(conf *config.Config, currentNode LocalNode) *LocalRouter {
 	return &LocalRouter{
 		currentNode:      currentNode,
 		requestChannels:  make(map[string]*MessageChannel),
 		responseChannels: make(map[string]*MessageChannel),
 		rtcMessageChan:   NewMessageChannel(),
+		stats:            NewNodeStatsReporter(conf.NodeStats, currentNode),
 	}
 }
 
@@ -76,6 +81,12 @@ func (r *LocalRouter) ListNodes() ([]*livekit.Node, error) {
 	}, nil
 }
 
+func (r *LocalRouter) GetNodeStatsReports() (map[string]*prometheus.NodeStatsReport, error) {
+	return map[string]*prometheus.NodeStatsReport{
+		r.currentNode.Id: r.stats.Report(),
+	}, nil
+}
+
 func (r *LocalRouter) StartParticipantSignal(ctx context.Context, roomName livekit.RoomName, pi ParticipantInit) (connectionID livekit.ConnectionID, reqSink MessageSink, resSource MessageSource, err error) {
 	// treat it as a new participant connecting
 	if r.onNewParticipant == nil {
@@ -169,10 +180,11 @@ func (r *LocalRouter) statsWorker() {
 		if !r.isStarted.Load() {
 			return
 		}
-		// update every 10 seconds
-		<-time.After(statsUpdateInterval)
+		<-time.After(r.stats.Interval())
 		r.lock.Lock()
-		r.currentNode.Stats.UpdatedAt = time.Now().Unix()
+		if err := r.stats.Update(); err != nil {
+			logger.Errorw("could not update node stats", err)
+		}
 		r.lock.Unlock()
 	}
 }
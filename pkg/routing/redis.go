@@ -9,12 +9,20 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+// routing keys share the {livekit} hash tag, so that the keys RedisRouter updates together in a transaction are in
+// the same Redis Cluster slot
 const (
 	// hash of node_id => Node proto
-	NodesKey = "nodes"
+	NodesKey = "{livekit}nodes"
+
+	// hash of node_id => NodeStatsReport JSON, the stats a node has no field for in its Node proto
+	NodeStatsKey = "{livekit}node_stats"
 
 	// hash of room_name => node_id
-	NodeRoomKey = "room_node_map"
+	NodeRoomKey = "{livekit}room_node_map"
+
+	// set of rooms assigned to a node, whose leases it refreshes
+	NodeRoomsPrefix = "{livekit}node_rooms:"
 )
 
 var redisCtx = context.Background()
@@ -29,6 +37,11 @@ func participantSignalKey(connectionID livekit.ConnectionID) string {
 	return "participant_signal:" + string(connectionID)
 }
 
+// exists while the node hosting the room is alive, expires when it stops refreshing it
+func roomNodeLeaseKey(roomName livekit.RoomName, nodeID livekit.NodeID) string {
+	return "{livekit}room_node_lease:" + string(roomName) + ":" + string(nodeID)
+}
+
 func rtcNodeChannel(nodeID livekit.NodeID) string {
 	return "rtc_channel:" + string(nodeID)
 }
@@ -37,7 +50,7 @@ func signalNodeChannel(nodeID livekit.NodeID) string {
 	return "signal_channel:" + string(nodeID)
 }
 
-func publishRTCMessage(rc *redis.Client, nodeID livekit.NodeID, participantKey livekit.ParticipantKey, msg proto.Message) error {
+func publishRTCMessage(rc redis.UniversalClient, nodeID livekit.NodeID, participantKey livekit.ParticipantKey, msg proto.Message) error {
 	rm := &livekit.RTCNodeMessage{
 		ParticipantKey: string(participantKey),
 	}
@@ -66,7 +79,7 @@ func publishRTCMessage(rc *redis.Client, nodeID livekit.NodeID, participantKey l
 	return rc.Publish(redisCtx, rtcNodeChannel(nodeID), data).Err()
 }
 
-func publishSignalMessage(rc *redis.Client, nodeID livekit.NodeID, connectionID livekit.ConnectionID, msg proto.Message) error {
+func publishSignalMessage(rc redis.UniversalClient, nodeID livekit.NodeID, connectionID livekit.ConnectionID, msg proto.Message) error {
 	rm := &livekit.SignalNodeMessage{
 		ConnectionId: string(connectionID),
 	}
@@ -93,14 +106,14 @@ func publishSignalMessage(rc *redis.Client, nodeID livekit.NodeID, connectionID
 }
 
 type RTCNodeSink struct {
-	rc             *redis.Client
+	rc             redis.UniversalClient
 	nodeID         livekit.NodeID
 	participantKey livekit.ParticipantKey
 	isClosed       atomic.Bool
 	onClose        func()
 }
 
-func NewRTCNodeSink(rc *redis.Client, nodeID livekit.NodeID, participantKey livekit.ParticipantKey) *RTCNodeSink {
+func NewRTCNodeSink(rc redis.UniversalClient, nodeID livekit.NodeID, participantKey livekit.ParticipantKey) *RTCNodeSink {
 	return &RTCNodeSink{
 		rc:             rc,
 		nodeID:         nodeID,
@@ -129,14 +142,14 @@ func (s *RTCNodeSink) OnClose(f func()) {
 }
 
 type SignalNodeSink struct {
-	rc           *redis.Client
+	rc           redis.UniversalClient
 	nodeID       livekit.NodeID
 	connectionID livekit.ConnectionID
 	isClosed     atomic.Bool
 	onClose      func()
 }
 
-func NewSignalNodeSink(rc *redis.Client, nodeID livekit.NodeID, connectionID livekit.ConnectionID) *SignalNodeSink {
+func NewSignalNodeSink(rc redis.UniversalClient, nodeID livekit.NodeID, connectionID livekit.ConnectionID) *SignalNodeSink {
 	return &SignalNodeSink{
 		rc:           rc,
 		nodeID:       nodeID,
@@ -14,6 +14,7 @@ import (
 	"go.uber.org/atomic"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing/selector"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
@@ -21,38 +22,61 @@ import (
 const (
 	// expire participant mappings after a day
 	participantMappingTTL = 24 * time.Hour
-	statsUpdateInterval   = 2 * time.Second
+	// the node hosting a room refreshes its lease every stats interval, the room is reassigned after a few missed
+	// refreshes once the node's registration is stale as well
+	roomLeaseRefreshes = 3
 )
 
+// grants sent with StartSession, along with participant settings the message has no field for
+type sessionGrants struct {
+	*auth.ClaimGrants
+	MaxSubscribeBitrate uint64 `json:"maxSubscribeBitrate,omitempty"`
+	// unix nanoseconds on the signal node
+	JoinStartedAt    int64 `json:"joinStartedAt,omitempty"`
+	TokenValidatedAt int64 `json:"tokenValidatedAt,omitempty"`
+}
+
 // RedisRouter uses Redis pub/sub to route signaling messages across different nodes
 // It relies on the RTC node to be the primary driver of the participant connection.
 // Because
 type RedisRouter struct {
 	LocalRouter
 
-	rc        *redis.Client
+	rc        redis.UniversalClient
 	ctx       context.Context
 	isStarted atomic.Bool
 
 	pubsub *redis.PubSub
 	cancel func()
+
+	roomLeaseTTL time.Duration
 }
 
-func NewRedisRouter(currentNode LocalNode, rc *redis.Client) *RedisRouter {
+func NewRedisRouter(conf *config.Config, currentNode LocalNode, rc redis.UniversalClient) *RedisRouter {
 	rr := &RedisRouter{
-		LocalRouter: *NewLocalRouter(currentNode),
-		rc:          rc,
+		LocalRouter:  *NewLocalRouter(conf, currentNode),
+		rc:           rc,
+		roomLeaseTTL: roomLeaseRefreshes * conf.NodeStats.Interval.Duration(),
 	}
 	rr.ctx, rr.cancel = context.WithCancel(context.Background())
 	return rr
 }
 
+// RegisterNode publishes the node along with its stats report. Nodes from before reports were published ignore them
 func (r *RedisRouter) RegisterNode() error {
 	data, err := proto.Marshal((*livekit.Node)(r.currentNode))
 	if err != nil {
 		return err
 	}
-	if err := r.rc.HSet(r.ctx, NodesKey, r.currentNode.Id, data).Err(); err != nil {
+	report, err := json.Marshal(r.stats.Report())
+	if err != nil {
+		return err
+	}
+
+	pp := r.rc.TxPipeline()
+	pp.HSet(r.ctx, NodesKey, r.currentNode.Id, data)
+	pp.HSet(r.ctx, NodeStatsKey, r.currentNode.Id, report)
+	if _, err := pp.Exec(r.ctx); err != nil {
 		return errors.Wrap(err, "could not register node")
 	}
 	return nil
@@ -60,7 +84,11 @@ func (r *RedisRouter) RegisterNode() error {
 
 func (r *RedisRouter) UnregisterNode() error {
 	// could be called after Stop(), so we'd want to use an unrelated context
-	return r.rc.HDel(context.Background(), NodesKey, r.currentNode.Id).Err()
+	pp := r.rc.TxPipeline()
+	pp.HDel(context.Background(), NodesKey, r.currentNode.Id)
+	pp.HDel(context.Background(), NodeStatsKey, r.currentNode.Id)
+	_, err := pp.Exec(context.Background())
+	return err
 }
 
 func (r *RedisRouter) RemoveDeadNodes() error {
@@ -73,11 +101,21 @@ func (r *RedisRouter) RemoveDeadNodes() error {
 			if err := r.rc.HDel(context.Background(), NodesKey, n.Id).Err(); err != nil {
 				return err
 			}
+			if err := r.rc.HDel(context.Background(), NodeStatsKey, n.Id).Err(); err != nil {
+				return err
+			}
+			// its rooms are reassigned once their leases expire
+			if err := r.rc.Del(context.Background(), NodeRoomsPrefix+n.Id).Err(); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// GetNodeForRoom returns the node hosting the room, or ErrRoomNodeGone when the node is dead: it stopped refreshing
+// its lease, and its registration is gone or stale. Rooms assigned before leases have none, their node is only dead
+// once its registration is
 func (r *RedisRouter) GetNodeForRoom(_ context.Context, roomName livekit.RoomName) (*livekit.Node, error) {
 	nodeID, err := r.rc.HGet(r.ctx, NodeRoomKey, string(roomName)).Result()
 	if err == redis.Nil {
@@ -86,20 +124,90 @@ func (r *RedisRouter) GetNodeForRoom(_ context.Context, roomName livekit.RoomNam
 		return nil, errors.Wrap(err, "could not get node for room")
 	}
 
-	return r.GetNode(livekit.NodeID(nodeID))
+	leased, err := r.rc.Exists(r.ctx, roomNodeLeaseKey(roomName, livekit.NodeID(nodeID))).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get node for room")
+	}
+	node, err := r.GetNode(livekit.NodeID(nodeID))
+	if leased == 0 && (err == ErrNotFound || (err == nil && !r.isAlive(node))) {
+		logger.Infow("node hosting room is gone", "room", roomName, "nodeID", nodeID)
+		return nil, ErrRoomNodeGone
+	}
+	return node, err
+}
+
+// isAlive is true for nodes that registered within the lease TTL, give or take a second as UpdatedAt is in seconds
+func (r *RedisRouter) isAlive(node *livekit.Node) bool {
+	if node.Stats == nil {
+		return false
+	}
+	return time.Since(time.Unix(node.Stats.UpdatedAt, 0)) < r.roomLeaseTTL+time.Second
 }
 
+// SetNodeForRoom assigns the room to the node, leased until the node stops refreshing it
 func (r *RedisRouter) SetNodeForRoom(_ context.Context, roomName livekit.RoomName, nodeID livekit.NodeID) error {
-	return r.rc.HSet(r.ctx, NodeRoomKey, string(roomName), string(nodeID)).Err()
+	previous, err := r.rc.HGet(r.ctx, NodeRoomKey, string(roomName)).Result()
+	if err != nil && err != redis.Nil {
+		return errors.Wrap(err, "could not set node for room")
+	}
+
+	pp := r.rc.TxPipeline()
+	if previous != "" && previous != string(nodeID) {
+		pp.SRem(r.ctx, NodeRoomsPrefix+previous, string(roomName))
+		pp.Del(r.ctx, roomNodeLeaseKey(roomName, livekit.NodeID(previous)))
+	}
+	pp.HSet(r.ctx, NodeRoomKey, string(roomName), string(nodeID))
+	pp.Set(r.ctx, roomNodeLeaseKey(roomName, nodeID), 1, r.roomLeaseTTL)
+	pp.SAdd(r.ctx, NodeRoomsPrefix+string(nodeID), string(roomName))
+	if _, err = pp.Exec(r.ctx); err != nil {
+		return errors.Wrap(err, "could not set node for room")
+	}
+	return nil
 }
 
 func (r *RedisRouter) ClearRoomState(_ context.Context, roomName livekit.RoomName) error {
-	if err := r.rc.HDel(r.ctx, NodeRoomKey, string(roomName)).Err(); err != nil {
+	nodeID, err := r.rc.HGet(r.ctx, NodeRoomKey, string(roomName)).Result()
+	if err == redis.Nil {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "could not clear room state")
+	}
+
+	pp := r.rc.TxPipeline()
+	pp.HDel(r.ctx, NodeRoomKey, string(roomName))
+	pp.Del(r.ctx, roomNodeLeaseKey(roomName, livekit.NodeID(nodeID)))
+	pp.SRem(r.ctx, NodeRoomsPrefix+nodeID, string(roomName))
+	if _, err = pp.Exec(r.ctx); err != nil {
 		return errors.Wrap(err, "could not clear room state")
 	}
 	return nil
 }
 
+// refreshRoomLeases renews the leases of rooms assigned to this node. Rooms reassigned while this node couldn't
+// refresh them are forgotten
+func (r *RedisRouter) refreshRoomLeases() error {
+	key := NodeRoomsPrefix + r.currentNode.Id
+	rooms, err := r.rc.SMembers(r.ctx, key).Result()
+	if err != nil || len(rooms) == 0 {
+		return err
+	}
+	owners, err := r.rc.HMGet(r.ctx, NodeRoomKey, rooms...).Result()
+	if err != nil {
+		return err
+	}
+
+	pp := r.rc.Pipeline()
+	for i, room := range rooms {
+		if owner, _ := owners[i].(string); owner == r.currentNode.Id {
+			pp.Set(r.ctx, roomNodeLeaseKey(livekit.RoomName(room), livekit.NodeID(r.currentNode.Id)), 1, r.roomLeaseTTL)
+		} else {
+			pp.SRem(r.ctx, key, room)
+		}
+	}
+	_, err = pp.Exec(r.ctx)
+	return err
+}
+
 func (r *RedisRouter) GetNode(nodeID livekit.NodeID) (*livekit.Node, error) {
 	data, err := r.rc.HGet(r.ctx, NodesKey, string(nodeID)).Result()
 	if err == redis.Nil {
@@ -130,6 +238,25 @@ func (r *RedisRouter) ListNodes() ([]*livekit.Node, error) {
 	return nodes, nil
 }
 
+// GetNodeStatsReports returns the stats reports of nodes by ID. Nodes from before reports were published have none,
+// invalid reports are skipped
+func (r *RedisRouter) GetNodeStatsReports() (map[string]*prometheus.NodeStatsReport, error) {
+	items, err := r.rc.HGetAll(r.ctx, NodeStatsKey).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get node stats reports")
+	}
+	reports := make(map[string]*prometheus.NodeStatsReport, len(items))
+	for nodeID, item := range items {
+		report := &prometheus.NodeStatsReport{}
+		if err := json.Unmarshal([]byte(item), report); err != nil {
+			logger.Warnw("invalid node stats report", err, "nodeID", nodeID)
+			continue
+		}
+		reports[nodeID] = report
+	}
+	return reports, nil
+}
+
 // StartParticipantSignal signal connection sets up paths to the RTC node, and starts to route messages to that message queue
 func (r *RedisRouter) StartParticipantSignal(ctx context.Context, roomName livekit.RoomName, pi ParticipantInit) (connectionID livekit.ConnectionID, reqSink MessageSink, resSource MessageSource, err error) {
 	// find the node where the room is hosted at
@@ -150,7 +277,12 @@ func (r *RedisRouter) StartParticipantSignal(ctx context.Context, roomName livek
 	sink := NewRTCNodeSink(r.rc, livekit.NodeID(rtcNode.Id), pKey)
 
 	// serialize claims
-	claims, err := json.Marshal(pi.Grants)
+	claims, err := json.Marshal(sessionGrants{
+		ClaimGrants:         pi.Grants,
+		MaxSubscribeBitrate: pi.MaxSubscribeBitrate,
+		JoinStartedAt:       unixNano(pi.JoinStartedAt),
+		TokenValidatedAt:    unixNano(pi.TokenValidatedAt),
+	})
 	if err != nil {
 		return
 	}
@@ -248,22 +380,25 @@ func (r *RedisRouter) startParticipantRTC(ss *livekit.StartSession, participantK
 		}
 	}
 
-	claims := &auth.ClaimGrants{}
-	if err := json.Unmarshal([]byte(ss.GrantsJson), claims); err != nil {
+	grants := sessionGrants{ClaimGrants: &auth.ClaimGrants{}}
+	if err := json.Unmarshal([]byte(ss.GrantsJson), &grants); err != nil {
 		return err
 	}
 
 	pi := ParticipantInit{
-		Identity:      livekit.ParticipantIdentity(ss.Identity),
-		Metadata:      ss.Metadata,
-		Name:          livekit.ParticipantName(ss.Name),
-		Reconnect:     ss.Reconnect,
-		Permission:    ss.Permission,
-		Client:        ss.Client,
-		AutoSubscribe: ss.AutoSubscribe,
-		Hidden:        ss.Hidden,
-		Recorder:      ss.Recorder,
-		Grants:        claims,
+		Identity:            livekit.ParticipantIdentity(ss.Identity),
+		Metadata:            ss.Metadata,
+		Name:                livekit.ParticipantName(ss.Name),
+		Reconnect:           ss.Reconnect,
+		Permission:          ss.Permission,
+		Client:              ss.Client,
+		AutoSubscribe:       ss.AutoSubscribe,
+		Hidden:              ss.Hidden,
+		Recorder:            ss.Recorder,
+		Grants:              grants.ClaimGrants,
+		MaxSubscribeBitrate: grants.MaxSubscribeBitrate,
+		JoinStartedAt:       fromUnixNano(grants.JoinStartedAt),
+		TokenValidatedAt:    fromUnixNano(grants.TokenValidatedAt),
 	}
 
 	reqChan := r.getOrCreateMessageChannel(r.requestChannels, string(participantKey))
@@ -349,10 +484,14 @@ func (r *RedisRouter) statsWorker() {
 	for r.ctx.Err() == nil {
 		// update periodically seconds
 		select {
-		case <-time.After(statsUpdateInterval):
+		case <-time.After(r.stats.Interval()):
 			_ = r.WriteNodeRTC(context.Background(), r.currentNode.Id, &livekit.RTCNodeMessage{
 				Message: &livekit.RTCNodeMessage_KeepAlive{},
 			})
+			// refreshed here rather than with the keep alive, so that a stalled pub/sub doesn't get rooms reassigned
+			if err := r.refreshRoomLeases(); err != nil {
+				logger.Errorw("could not refresh room leases", err)
+			}
 		case <-r.ctx.Done():
 			return
 		}
@@ -458,16 +597,14 @@ func (r *RedisRouter) handleRTCMessage(rm *livekit.RTCNodeMessage) error {
 		}
 
 	case *livekit.RTCNodeMessage_KeepAlive:
-		if time.Since(time.Unix(rm.SenderTime, 0)) > statsUpdateInterval {
+		// SenderTime is in seconds
+		if time.Since(time.Unix(rm.SenderTime, 0)) > r.stats.Interval()+time.Second {
 			logger.Infow("keep alive too old, skipping", "senderTime", rm.SenderTime)
 			break
 		}
 
-		updated, err := prometheus.GetUpdatedNodeStats(r.currentNode.Stats)
-		if err != nil {
+		if err := r.stats.Update(); err != nil {
 			logger.Errorw("could not update node stats", err)
-		} else {
-			r.currentNode.Stats = updated
 		}
 
 		// TODO: check stats against config.Limit values
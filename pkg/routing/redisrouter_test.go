@@ -0,0 +1,241 @@
+package routing
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+func TestRoomFailover(t *testing.T) {
+	ctx := context.Background()
+	rc := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+
+	newRouter := func(nodeID string) *RedisRouter {
+		node, err := NewLocalNode(conf)
+		require.NoError(t, err)
+		// in-process nodes share the machine's ID
+		node.Id = nodeID
+		node.Stats.UpdatedAt = time.Now().Unix()
+		r := NewRedisRouter(conf, node, rc)
+		r.roomLeaseTTL = 200 * time.Millisecond
+		require.NoError(t, r.RegisterNode())
+		t.Cleanup(func() {
+			_ = r.UnregisterNode()
+			_ = rc.Del(ctx, NodeRoomsPrefix+node.Id).Err()
+		})
+		return r
+	}
+	owner := newRouter("ND_owner")
+	other := newRouter("ND_other")
+	roomName := livekit.RoomName("failover")
+	t.Cleanup(func() {
+		_ = other.ClearRoomState(ctx, roomName)
+	})
+
+	require.NoError(t, owner.SetNodeForRoom(ctx, roomName, livekit.NodeID(owner.currentNode.Id)))
+
+	// the lease outlives its TTL while the owner refreshes it
+	for i := 0; i < 3; i++ {
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, owner.refreshRoomLeases())
+	}
+	node, err := other.GetNodeForRoom(ctx, roomName)
+	require.NoError(t, err)
+	require.Equal(t, owner.currentNode.Id, node.Id)
+
+	// a stalled owner keeps the room while its registration is fresh
+	time.Sleep(300 * time.Millisecond)
+	owner.currentNode.Stats.UpdatedAt = time.Now().Unix()
+	require.NoError(t, owner.RegisterNode())
+	node, err = other.GetNodeForRoom(ctx, roomName)
+	require.NoError(t, err)
+	require.Equal(t, owner.currentNode.Id, node.Id)
+
+	// the owner is killed, it's still listed until it's considered dead, but stops refreshing
+	owner.currentNode.Stats.UpdatedAt = time.Now().Add(-time.Minute).Unix()
+	require.NoError(t, owner.RegisterNode())
+	_, err = other.GetNodeForRoom(ctx, roomName)
+	require.ErrorIs(t, err, ErrRoomNodeGone)
+
+	require.NoError(t, other.SetNodeForRoom(ctx, roomName, livekit.NodeID(other.currentNode.Id)))
+	node, err = owner.GetNodeForRoom(ctx, roomName)
+	require.NoError(t, err)
+	require.Equal(t, other.currentNode.Id, node.Id)
+
+	// when the old owner comes back, it doesn't take the room back
+	owner.currentNode.Stats.UpdatedAt = time.Now().Unix()
+	require.NoError(t, owner.RegisterNode())
+	require.NoError(t, owner.refreshRoomLeases())
+	require.NoError(t, other.refreshRoomLeases())
+	member, err := rc.SIsMember(ctx, NodeRoomsPrefix+owner.currentNode.Id, string(roomName)).Result()
+	require.NoError(t, err)
+	require.False(t, member)
+	time.Sleep(300 * time.Millisecond)
+	require.NoError(t, other.refreshRoomLeases())
+	node, err = owner.GetNodeForRoom(ctx, roomName)
+	require.NoError(t, err)
+	require.Equal(t, other.currentNode.Id, node.Id)
+}
+
+func TestRoomWithoutLease(t *testing.T) {
+	ctx := context.Background()
+	rc := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+
+	node, err := NewLocalNode(conf)
+	require.NoError(t, err)
+	node.Id = "ND_upgraded"
+	node.Stats.UpdatedAt = time.Now().Unix()
+	r := NewRedisRouter(conf, node, rc)
+	require.NoError(t, r.RegisterNode())
+	roomName := livekit.RoomName("assigned-before-leases")
+	t.Cleanup(func() {
+		_ = r.UnregisterNode()
+		_ = rc.HDel(ctx, NodeRoomKey, string(roomName)).Err()
+	})
+
+	// rooms assigned before leases stay on their node while it is alive
+	require.NoError(t, rc.HSet(ctx, NodeRoomKey, string(roomName), node.Id).Err())
+	found, err := r.GetNodeForRoom(ctx, roomName)
+	require.NoError(t, err)
+	require.Equal(t, node.Id, found.Id)
+
+	node.Stats.UpdatedAt = time.Now().Add(-time.Minute).Unix()
+	require.NoError(t, r.RegisterNode())
+	_, err = r.GetNodeForRoom(ctx, roomName)
+	require.ErrorIs(t, err, ErrRoomNodeGone)
+
+	require.NoError(t, r.UnregisterNode())
+	_, err = r.GetNodeForRoom(ctx, roomName)
+	require.ErrorIs(t, err, ErrRoomNodeGone)
+}
+
+func TestNodeStatsReports(t *testing.T) {
+	ctx := context.Background()
+	rc := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	conf.NodeStats.Include = []string{config.NodeStatCPU, config.NodeStatPeerConnections}
+
+	node, err := NewLocalNode(conf)
+	require.NoError(t, err)
+	node.Id = "ND_reporting"
+	r := NewRedisRouter(conf, node, rc)
+	require.NoError(t, r.stats.Update())
+	require.NoError(t, r.RegisterNode())
+	t.Cleanup(func() {
+		_ = r.UnregisterNode()
+	})
+
+	// a node from before reports were published only registers itself
+	old, err := NewLocalNode(conf)
+	require.NoError(t, err)
+	old.Id = "ND_old"
+	data, err := proto.Marshal((*livekit.Node)(old))
+	require.NoError(t, err)
+	require.NoError(t, rc.HSet(ctx, NodesKey, old.Id, data).Err())
+	t.Cleanup(func() {
+		_ = rc.HDel(ctx, NodesKey, old.Id).Err()
+	})
+
+	reports, err := r.GetNodeStatsReports()
+	require.NoError(t, err)
+	require.Contains(t, reports, node.Id)
+	require.NotContains(t, reports, old.Id)
+	require.Equal(t, prometheus.NodeStatsVersion, reports[node.Id].Version)
+	require.Equal(t, []string{config.NodeStatCPU, config.NodeStatPeerConnections}, reports[node.Id].Reported)
+
+	// reports of newer versions are read, fields this version doesn't know are ignored
+	require.NoError(t, rc.HSet(ctx, NodeStatsKey, old.Id, `{"version":99,"reported":["cpu","disk"],"diskUsed":1}`).Err())
+	t.Cleanup(func() {
+		_ = rc.HDel(ctx, NodeStatsKey, old.Id).Err()
+	})
+	reports, err = r.GetNodeStatsReports()
+	require.NoError(t, err)
+	require.Equal(t, 99, reports[old.Id].Version)
+	require.True(t, reports[old.Id].Reports(config.NodeStatCPU))
+
+	require.NoError(t, r.UnregisterNode())
+	reports, err = r.GetNodeStatsReports()
+	require.NoError(t, err)
+	require.NotContains(t, reports, node.Id)
+}
+
+func TestRedisRouterCluster(t *testing.T) {
+	ctx := context.Background()
+	// an in-memory server that answers as a single node cluster holding all slots
+	m := miniredis.RunT(t)
+	rc := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{m.Addr()}})
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+
+	node, err := NewLocalNode(conf)
+	require.NoError(t, err)
+	node.Id = "ND_cluster"
+	node.Stats.UpdatedAt = time.Now().Unix()
+	r := NewRedisRouter(conf, node, rc)
+	require.NoError(t, r.RegisterNode())
+
+	roomName := livekit.RoomName("cluster_room")
+	require.NoError(t, r.SetNodeForRoom(ctx, roomName, livekit.NodeID(node.Id)))
+	assigned, err := r.GetNodeForRoom(ctx, roomName)
+	require.NoError(t, err)
+	require.Equal(t, node.Id, assigned.Id)
+
+	require.NoError(t, r.ClearRoomState(ctx, roomName))
+	_, err = r.GetNodeForRoom(ctx, roomName)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, r.UnregisterNode())
+	_, err = r.GetNode(livekit.NodeID(node.Id))
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// the keys updated together in a transaction must share a slot, which the in-memory server doesn't check
+	slot := keySlot(NodesKey)
+	for _, key := range []string{
+		NodeStatsKey, NodeRoomKey, NodeRoomsPrefix + node.Id, roomNodeLeaseKey(roomName, livekit.NodeID(node.Id)),
+	} {
+		require.Equal(t, slot, keySlot(key), key)
+	}
+}
+
+// keySlot is the Redis Cluster slot of the key: CRC16 of its hash tag, or of the whole key without one, mod 16384
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return int(crc) % 16384
+}
+
+func TestKeySlot(t *testing.T) {
+	// examples of the cluster specification
+	require.Equal(t, 12182, keySlot("foo"))
+	require.Equal(t, keySlot("user1000"), keySlot("{user1000}.following"))
+	require.Equal(t, keySlot("{user1000}.followers"), keySlot("{user1000}.following"))
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 	"github.com/livekit/protocol/livekit"
 )
 
@@ -40,6 +41,18 @@ type FakeRouter struct {
 		result1 *livekit.Node
 		result2 error
 	}
+	GetNodeStatsReportsStub        func() (map[string]*prometheus.NodeStatsReport, error)
+	getNodeStatsReportsMutex       sync.RWMutex
+	getNodeStatsReportsArgsForCall []struct {
+	}
+	getNodeStatsReportsReturns struct {
+		result1 map[string]*prometheus.NodeStatsReport
+		result2 error
+	}
+	getNodeStatsReportsReturnsOnCall map[int]struct {
+		result1 map[string]*prometheus.NodeStatsReport
+		result2 error
+	}
 	ListNodesStub        func() ([]*livekit.Node, error)
 	listNodesMutex       sync.RWMutex
 	listNodesArgsForCall []struct {
@@ -278,6 +291,8 @@ func (fake *FakeRouter) GetNodeForRoom(arg1 context.Context, arg2 livekit.RoomNa
 func (fake *FakeRouter) GetNodeForRoomCallCount() int {
 	fake.getNodeForRoomMutex.RLock()
 	defer fake.getNodeForRoomMutex.RUnlock()
+	fake.getNodeStatsReportsMutex.RLock()
+	defer fake.getNodeStatsReportsMutex.RUnlock()
 	return len(fake.getNodeForRoomArgsForCall)
 }
 
@@ -320,6 +335,62 @@ func (fake *FakeRouter) GetNodeForRoomReturnsOnCall(i int, result1 *livekit.Node
 	}{result1, result2}
 }
 
+func (fake *FakeRouter) GetNodeStatsReports() (map[string]*prometheus.NodeStatsReport, error) {
+	fake.getNodeStatsReportsMutex.Lock()
+	ret, specificReturn := fake.getNodeStatsReportsReturnsOnCall[len(fake.getNodeStatsReportsArgsForCall)]
+	fake.getNodeStatsReportsArgsForCall = append(fake.getNodeStatsReportsArgsForCall, struct {
+	}{})
+	stub := fake.GetNodeStatsReportsStub
+	fakeReturns := fake.getNodeStatsReportsReturns
+	fake.recordInvocation("GetNodeStatsReports", []interface{}{})
+	fake.getNodeStatsReportsMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRouter) GetNodeStatsReportsCallCount() int {
+	fake.getNodeStatsReportsMutex.RLock()
+	defer fake.getNodeStatsReportsMutex.RUnlock()
+	return len(fake.getNodeStatsReportsArgsForCall)
+}
+
+func (fake *FakeRouter) GetNodeStatsReportsCalls(stub func() (map[string]*prometheus.NodeStatsReport, error)) {
+	fake.getNodeStatsReportsMutex.Lock()
+	defer fake.getNodeStatsReportsMutex.Unlock()
+	fake.GetNodeStatsReportsStub = stub
+}
+
+func (fake *FakeRouter) GetNodeStatsReportsReturns(result1 map[string]*prometheus.NodeStatsReport, result2 error) {
+	fake.getNodeStatsReportsMutex.Lock()
+	defer fake.getNodeStatsReportsMutex.Unlock()
+	fake.GetNodeStatsReportsStub = nil
+	fake.getNodeStatsReportsReturns = struct {
+		result1 map[string]*prometheus.NodeStatsReport
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRouter) GetNodeStatsReportsReturnsOnCall(i int, result1 map[string]*prometheus.NodeStatsReport, result2 error) {
+	fake.getNodeStatsReportsMutex.Lock()
+	defer fake.getNodeStatsReportsMutex.Unlock()
+	fake.GetNodeStatsReportsStub = nil
+	if fake.getNodeStatsReportsReturnsOnCall == nil {
+		fake.getNodeStatsReportsReturnsOnCall = make(map[int]struct {
+			result1 map[string]*prometheus.NodeStatsReport
+			result2 error
+		})
+	}
+	fake.getNodeStatsReportsReturnsOnCall[i] = struct {
+		result1 map[string]*prometheus.NodeStatsReport
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeRouter) ListNodes() ([]*livekit.Node, error) {
 	fake.listNodesMutex.Lock()
 	ret, specificReturn := fake.listNodesReturnsOnCall[len(fake.listNodesArgsForCall)]
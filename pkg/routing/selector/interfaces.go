@@ -6,6 +6,7 @@ import (
 	"github.com/livekit/protocol/livekit"
 
 	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
 var ErrUnsupportedSelector = errors.New("unsupported node selector")
@@ -15,6 +16,26 @@ type NodeSelector interface {
 	SelectNode(nodes []*livekit.Node) (*livekit.Node, error)
 }
 
+// Location is where a client is, in degrees
+type Location struct {
+	Lat float64
+	Lon float64
+}
+
+// LocationAwareSelector selects nodes near the client creating a room
+type LocationAwareSelector interface {
+	NodeSelector
+	// SelectNodeNear selects a node for a client at loc, or like SelectNode when loc is nil
+	SelectNodeNear(nodes []*livekit.Node, loc *Location) (*livekit.Node, error)
+}
+
+// StatsAwareSelector selects nodes using the stats reports they publish with their registration
+type StatsAwareSelector interface {
+	NodeSelector
+	// SelectNodeWithReports selects a node given reports by node ID, nodes without one predate reports
+	SelectNodeWithReports(nodes []*livekit.Node, reports map[string]*prometheus.NodeStatsReport) (*livekit.Node, error)
+}
+
 func CreateNodeSelector(conf *config.Config) (NodeSelector, error) {
 	kind := conf.NodeSelector.Kind
 	if kind == "" {
@@ -32,6 +53,14 @@ func CreateNodeSelector(conf *config.Config) (NodeSelector, error) {
 		}
 		s.SysloadLimit = conf.NodeSelector.SysloadLimit
 		return s, nil
+	case "least_loaded":
+		return &LeastLoadedSelector{
+			SysloadLimit:       conf.NodeSelector.SysloadLimit,
+			StatsMaxAge:        conf.NodeSelector.StatsMaxAge.Duration(),
+			CPUWeight:          conf.NodeSelector.Weights.CPU,
+			ParticipantsWeight: conf.NodeSelector.Weights.Participants,
+			BandwidthWeight:    conf.NodeSelector.Weights.Bandwidth,
+		}, nil
 	case "random":
 		return &RandomSelector{}, nil
 	default:
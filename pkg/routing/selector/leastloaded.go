@@ -0,0 +1,149 @@
+package selector
+
+import (
+	"sort"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+// LeastLoadedSelector selects the node with the lowest load, a weighted sum of its CPU load, participants and
+// bandwidth. Each stat is taken per CPU and relative to the node where it's highest, so that weights compare stats
+// of different units. Nodes whose load per CPU reaches SysloadLimit, or whose stats are older than StatsMaxAge, are
+// never selected
+type LeastLoadedSelector struct {
+	SysloadLimit float32
+	StatsMaxAge  time.Duration
+
+	CPUWeight          float64
+	ParticipantsWeight float64
+	BandwidthWeight    float64
+}
+
+// stats making up a node's load
+const (
+	loadCPU = iota
+	loadParticipants
+	loadBandwidth
+	numLoadStats
+)
+
+var loadStats = [numLoadStats]string{config.NodeStatCPU, config.NodeStatParticipants, config.NodeStatBandwidth}
+
+// nodeLoad holds a node's stats per CPU
+type nodeLoad struct {
+	node     *livekit.Node
+	stats    [numLoadStats]float64
+	reported [numLoadStats]bool
+	score    float64
+}
+
+func (s *LeastLoadedSelector) SelectNode(nodes []*livekit.Node) (*livekit.Node, error) {
+	return s.SelectNodeWithReports(nodes, nil)
+}
+
+// SelectNodeWithReports tells stats nodes don't report from zeros. Stats a node doesn't report count as the average
+// of nodes that do
+func (s *LeastLoadedSelector) SelectNodeWithReports(nodes []*livekit.Node, reports map[string]*prometheus.NodeStatsReport) (*livekit.Node, error) {
+	loads := s.rankNodes(nodes, reports)
+	if len(loads) == 0 {
+		return nil, ErrNoAvailableNodes
+	}
+	return loads[0].node, nil
+}
+
+// RankNodes returns the nodes that can be selected, least loaded first
+func (s *LeastLoadedSelector) RankNodes(nodes []*livekit.Node) []*livekit.Node {
+	loads := s.rankNodes(nodes, nil)
+	ranked := make([]*livekit.Node, 0, len(loads))
+	for _, l := range loads {
+		ranked = append(ranked, l.node)
+	}
+	return ranked
+}
+
+func (s *LeastLoadedSelector) rankNodes(nodes []*livekit.Node, reports map[string]*prometheus.NodeStatsReport) []*nodeLoad {
+	maxAge := s.StatsMaxAge
+	if maxAge <= 0 {
+		maxAge = AvailableSeconds * time.Second
+	}
+	now := time.Now().Unix()
+
+	loads := make([]*nodeLoad, 0, len(nodes))
+	var maxLoad [numLoadStats]float64
+	for _, node := range nodes {
+		stats := node.Stats
+		if node.State != livekit.NodeState_SERVING || stats == nil {
+			continue
+		}
+		if time.Duration(now-stats.UpdatedAt)*time.Second >= maxAge {
+			continue
+		}
+		numCpus := stats.NumCpus
+		if numCpus == 0 {
+			numCpus = node.NumCpus
+		}
+		if numCpus == 0 {
+			numCpus = 1
+		}
+
+		l := &nodeLoad{node: node}
+		for i, stat := range loadStats {
+			l.reported[i] = ReportsStat(node, reports[node.Id], stat)
+		}
+		l.stats[loadCPU] = float64(stats.LoadAvgLast1Min / float32(numCpus))
+		l.stats[loadParticipants] = float64(stats.NumClients) / float64(numCpus)
+		l.stats[loadBandwidth] = float64(stats.BytesInPerSec+stats.BytesOutPerSec) / float64(numCpus)
+		if l.reported[loadCPU] && s.SysloadLimit > 0 && l.stats[loadCPU] >= float64(s.SysloadLimit) {
+			continue
+		}
+
+		for i := range l.stats {
+			if l.reported[i] && l.stats[i] > maxLoad[i] {
+				maxLoad[i] = l.stats[i]
+			}
+		}
+		loads = append(loads, l)
+	}
+
+	weights := [numLoadStats]float64{s.CPUWeight, s.ParticipantsWeight, s.BandwidthWeight}
+	for i := range weights {
+		var sum float64
+		var count int
+		for _, l := range loads {
+			if l.reported[i] {
+				l.stats[i] = relative(l.stats[i], maxLoad[i])
+				sum += l.stats[i]
+				count++
+			}
+		}
+		var average float64
+		if count > 0 {
+			average = sum / float64(count)
+		}
+		for _, l := range loads {
+			if !l.reported[i] {
+				l.stats[i] = average
+			}
+			l.score += weights[i] * l.stats[i]
+		}
+	}
+	// ties go to the lowest node ID, so that selection doesn't depend on the order nodes are listed in
+	sort.Slice(loads, func(i, j int) bool {
+		if loads[i].score != loads[j].score {
+			return loads[i].score < loads[j].score
+		}
+		return loads[i].node.Id < loads[j].node.Id
+	})
+	return loads
+}
+
+func relative(value, max float64) float64 {
+	if max == 0 {
+		return 0
+	}
+	return value / max
+}
@@ -0,0 +1,147 @@
+package selector_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing/selector"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+func fakeNode(id string, numCpus uint32, load float32, clients int32, bytesPerSec float32) *livekit.Node {
+	return &livekit.Node{
+		Id:    id,
+		State: livekit.NodeState_SERVING,
+		Stats: &livekit.NodeStats{
+			UpdatedAt:       time.Now().Unix(),
+			NumCpus:         numCpus,
+			LoadAvgLast1Min: load,
+			NumClients:      clients,
+			BytesInPerSec:   bytesPerSec / 2,
+			BytesOutPerSec:  bytesPerSec / 2,
+		},
+	}
+}
+
+func nodeIDs(nodes []*livekit.Node) []string {
+	ids := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		ids = append(ids, n.Id)
+	}
+	return ids
+}
+
+func TestLeastLoadedSelector(t *testing.T) {
+	sel := &selector.LeastLoadedSelector{
+		SysloadLimit:       0.9,
+		StatsMaxAge:        5 * time.Second,
+		CPUWeight:          1,
+		ParticipantsWeight: 1,
+		BandwidthWeight:    1,
+	}
+
+	t.Run("no nodes", func(t *testing.T) {
+		_, err := sel.SelectNode(nil)
+		require.Equal(t, selector.ErrNoAvailableNodes, err)
+	})
+
+	t.Run("ranks by weighted load", func(t *testing.T) {
+		nodes := []*livekit.Node{
+			// loads per CPU, relative to the highest: 1, 1, 1
+			fakeNode("busy", 1, 0.8, 100, 10000),
+			// 0.5, 0.5, 0.5
+			fakeNode("half", 2, 0.8, 100, 10000),
+			// 0.125, 0.25, 0.25
+			fakeNode("large", 8, 0.8, 200, 20000),
+			// 0.25, 0, 0
+			fakeNode("idle", 1, 0.2, 0, 0),
+		}
+		require.Equal(t, []string{"idle", "large", "half", "busy"}, nodeIDs(sel.RankNodes(nodes)))
+
+		node, err := sel.SelectNode(nodes)
+		require.NoError(t, err)
+		require.Equal(t, "idle", node.Id)
+	})
+
+	t.Run("weights", func(t *testing.T) {
+		nodes := []*livekit.Node{
+			fakeNode("cpu_bound", 1, 0.8, 10, 1000),
+			fakeNode("crowded", 1, 0.1, 100, 1000),
+			fakeNode("streaming", 1, 0.1, 10, 100000),
+		}
+		byCPU := &selector.LeastLoadedSelector{StatsMaxAge: 5 * time.Second, CPUWeight: 1}
+		require.Equal(t, []string{"crowded", "streaming", "cpu_bound"}, nodeIDs(byCPU.RankNodes(nodes)))
+
+		byParticipants := &selector.LeastLoadedSelector{StatsMaxAge: 5 * time.Second, ParticipantsWeight: 1}
+		require.Equal(t, []string{"cpu_bound", "streaming", "crowded"}, nodeIDs(byParticipants.RankNodes(nodes)))
+
+		byBandwidth := &selector.LeastLoadedSelector{StatsMaxAge: 5 * time.Second, BandwidthWeight: 1}
+		require.Equal(t, []string{"cpu_bound", "crowded", "streaming"}, nodeIDs(byBandwidth.RankNodes(nodes)))
+	})
+
+	t.Run("ties go to the lowest node ID", func(t *testing.T) {
+		nodes := []*livekit.Node{
+			fakeNode("c", 1, 0.5, 10, 1000),
+			fakeNode("a", 1, 0.5, 10, 1000),
+			fakeNode("b", 1, 0.5, 10, 1000),
+		}
+		require.Equal(t, []string{"a", "b", "c"}, nodeIDs(sel.RankNodes(nodes)))
+	})
+
+	t.Run("sysload limit is a hard cutoff", func(t *testing.T) {
+		overloaded := fakeNode("overloaded", 1, 0.95, 0, 0)
+		nodes := []*livekit.Node{overloaded, fakeNode("loaded", 1, 0.85, 100, 10000)}
+		require.Equal(t, []string{"loaded"}, nodeIDs(sel.RankNodes(nodes)))
+
+		_, err := sel.SelectNode([]*livekit.Node{overloaded})
+		require.Equal(t, selector.ErrNoAvailableNodes, err)
+	})
+
+	t.Run("stale and draining nodes are not selected", func(t *testing.T) {
+		stale := fakeNode("stale", 1, 0, 0, 0)
+		stale.Stats.UpdatedAt = time.Now().Add(-10 * time.Second).Unix()
+		draining := fakeNode("draining", 1, 0, 0, 0)
+		draining.State = livekit.NodeState_SHUTTING_DOWN
+		noStats := fakeNode("no_stats", 1, 0, 0, 0)
+		noStats.Stats = nil
+
+		nodes := []*livekit.Node{stale, draining, noStats, fakeNode("fresh", 1, 0.5, 50, 5000)}
+		require.Equal(t, []string{"fresh"}, nodeIDs(sel.RankNodes(nodes)))
+
+		_, err := sel.SelectNode([]*livekit.Node{stale, draining, noStats})
+		require.Equal(t, selector.ErrNoAvailableNodes, err)
+	})
+
+	t.Run("stats nodes don't report count as the average", func(t *testing.T) {
+		nodes := []*livekit.Node{
+			// predates reports
+			fakeNode("old", 1, 0.8, 100, 10000),
+			// CPU counts as 0.75
+			fakeNode("no_cpu", 1, 0, 40, 1000),
+			// bandwidth counts as 0.55
+			fakeNode("no_bandwidth", 1, 0.4, 10, 0),
+		}
+		reports := map[string]*prometheus.NodeStatsReport{
+			"no_cpu":       {Version: 1, Reported: []string{config.NodeStatParticipants, config.NodeStatBandwidth}},
+			"no_bandwidth": {Version: 1, Reported: []string{config.NodeStatCPU, config.NodeStatParticipants}},
+		}
+		node, err := sel.SelectNodeWithReports(nodes, reports)
+		require.NoError(t, err)
+		require.Equal(t, "no_bandwidth", node.Id)
+
+		// without reports, missing stats look like an idle node
+		node, err = sel.SelectNode(nodes)
+		require.NoError(t, err)
+		require.Equal(t, "no_cpu", node.Id)
+
+		// nodes that can't read their CPU stats don't report them
+		unknownCPU := fakeNode("unknown_cpu", 0, 0, 40, 1000)
+		node, err = sel.SelectNodeWithReports([]*livekit.Node{nodes[0], unknownCPU, nodes[2]}, reports)
+		require.NoError(t, err)
+		require.Equal(t, "no_bandwidth", node.Id)
+	})
+}
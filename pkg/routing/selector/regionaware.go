@@ -2,30 +2,34 @@ package selector
 
 import (
 	"math"
+	"sort"
 
 	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
 	"github.com/thoas/go-funk"
 
 	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
-// RegionAwareSelector prefers available nodes that are closest to the region of the current instance
+// RegionAwareSelector prefers available nodes in the region closest to the client, or to the current instance when
+// the client's location isn't known. When that region has no node under SysloadLimit, its fallback regions are tried
+// in order, then the remaining regions by distance
 type RegionAwareSelector struct {
 	SystemLoadSelector
-	CurrentRegion   string
-	regionDistances map[string]float64
-	regions         []config.RegionConfig
+	CurrentRegion string
+	// order regions are tried in for clients that can't be located
+	currentRegionOrder []string
+	regions            []config.RegionConfig
 }
 
 func NewRegionAwareSelector(currentRegion string, regions []config.RegionConfig) (*RegionAwareSelector, error) {
 	if currentRegion == "" {
 		return nil, ErrCurrentRegionNotSet
 	}
-	// build internal map of distances
 	s := &RegionAwareSelector{
-		CurrentRegion:   currentRegion,
-		regionDistances: make(map[string]float64),
-		regions:         regions,
+		CurrentRegion: currentRegion,
+		regions:       regions,
 	}
 
 	var currentRC *config.RegionConfig
@@ -42,47 +46,109 @@ func NewRegionAwareSelector(currentRegion string, regions []config.RegionConfig)
 	}
 
 	if currentRC != nil {
-		for _, region := range regions {
-			s.regionDistances[region.Name] = distanceBetween(currentRC.Lat, currentRC.Lon, region.Lat, region.Lon)
-		}
+		s.currentRegionOrder = s.regionOrder(currentRC.Lat, currentRC.Lon)
 	}
 
 	return s, nil
 }
 
 func (s *RegionAwareSelector) SelectNode(nodes []*livekit.Node) (*livekit.Node, error) {
-	nodes, err := s.SystemLoadSelector.filterNodes(nodes)
-	if err != nil {
-		return nil, err
+	return s.selectNode(nodes, s.currentRegionOrder)
+}
+
+func (s *RegionAwareSelector) SelectNodeNear(nodes []*livekit.Node, loc *Location) (*livekit.Node, error) {
+	if loc == nil || len(s.regions) == 0 {
+		return s.SelectNode(nodes)
 	}
+	return s.selectNode(nodes, s.regionOrder(loc.Lat, loc.Lon))
+}
 
-	// find nodes nearest to current region
-	var nearestNodes []*livekit.Node
-	nearestRegion := ""
-	minDist := math.MaxFloat64
-	for _, node := range nodes {
-		if node.Region == nearestRegion {
-			nearestNodes = append(nearestNodes, node)
-			continue
-		}
-		if dist, ok := s.regionDistances[node.Region]; ok {
-			if dist < minDist {
-				minDist = dist
-				nearestRegion = node.Region
-				nearestNodes = nearestNodes[:0]
-				nearestNodes = append(nearestNodes, node)
-			}
+// RegionOrder returns the order regions are tried in for a client at loc, or at the current region when loc is nil
+func (s *RegionAwareSelector) RegionOrder(loc *Location) []string {
+	if loc == nil {
+		return s.currentRegionOrder
+	}
+	return s.regionOrder(loc.Lat, loc.Lon)
+}
+
+// regionOrder lists the region nearest to lat/lon, its fallback regions, then the remaining regions nearest first
+func (s *RegionAwareSelector) regionOrder(lat, lon float64) []string {
+	if len(s.regions) == 0 {
+		return nil
+	}
+	byDistance := make([]config.RegionConfig, len(s.regions))
+	copy(byDistance, s.regions)
+	sort.SliceStable(byDistance, func(i, j int) bool {
+		return distanceBetween(lat, lon, byDistance[i].Lat, byDistance[i].Lon) <
+			distanceBetween(lat, lon, byDistance[j].Lat, byDistance[j].Lon)
+	})
+
+	order := make([]string, 0, len(byDistance))
+	seen := make(map[string]bool, len(byDistance))
+	add := func(region string) {
+		if !seen[region] {
+			seen[region] = true
+			order = append(order, region)
 		}
 	}
+	nearest := byDistance[0]
+	add(nearest.Name)
+	for _, region := range nearest.Fallback {
+		add(region)
+	}
+	for _, region := range byDistance[1:] {
+		add(region.Name)
+	}
+	return order
+}
+
+func (s *RegionAwareSelector) selectNode(nodes []*livekit.Node, order []string) (*livekit.Node, error) {
+	nodes = GetAvailableNodes(nodes)
+	if len(nodes) == 0 {
+		return nil, ErrNoAvailableNodes
+	}
 
-	if len(nearestNodes) > 0 {
-		nodes = nearestNodes
+	nodesLowLoad := s.lowLoadNodes(nodes)
+	if node := s.selectInRegions(nodesLowLoad, order); node != nil {
+		return node, nil
+	}
+	if len(nodesLowLoad) > 0 {
+		// only nodes in regions that aren't configured have capacity
+		nodes = nodesLowLoad
+	} else if node := s.selectInRegions(nodes, order); node != nil {
+		// every node is overloaded
+		return node, nil
 	}
 
 	idx := funk.RandomInt(0, len(nodes))
 	return nodes[idx], nil
 }
 
+// selectInRegions selects a node in the first region of order that has one, reporting when it's not the first
+func (s *RegionAwareSelector) selectInRegions(nodes []*livekit.Node, order []string) *livekit.Node {
+	byRegion := make(map[string][]*livekit.Node)
+	for _, node := range nodes {
+		byRegion[node.Region] = append(byRegion[node.Region], node)
+	}
+
+	for i, region := range order {
+		regionNodes := byRegion[region]
+		if len(regionNodes) == 0 {
+			continue
+		}
+		if i > 0 {
+			logger.Infow("nearest region has no capacity, falling back",
+				"nearestRegion", order[0],
+				"region", region,
+				"skippedRegions", order[:i],
+			)
+			prometheus.IncrementRegionFallback(order[0], region)
+		}
+		return regionNodes[funk.RandomInt(0, len(regionNodes))]
+	}
+	return nil
+}
+
 // haversine(θ) function
 func hsin(theta float64) float64 {
 	return math.Pow(math.Sin(theta/2), 2)
@@ -130,6 +130,101 @@ func TestRegionAwareRouting(t *testing.T) {
 	})
 }
 
+func TestRegionAwareFallback(t *testing.T) {
+	const (
+		regionEUWest    = "eu-west"
+		regionEUCentral = "eu-central"
+		regionUSEast    = "us-east"
+	)
+	rc := []config.RegionConfig{
+		{
+			Name:     regionEUWest,
+			Lat:      53.349805,
+			Lon:      -6.26031,
+			Fallback: []string{regionUSEast},
+		},
+		{
+			Name: regionEUCentral,
+			Lat:  50.110922,
+			Lon:  8.682127,
+		},
+		{
+			Name: regionUSEast,
+			Lat:  38.9940541,
+			Lon:  -77.4524237,
+		},
+	}
+	london := &selector.Location{Lat: 51.507351, Lon: -0.127758}
+	newYork := &selector.Location{Lat: 40.712776, Lon: -74.005974}
+
+	s, err := selector.NewRegionAwareSelector(regionEUCentral, rc)
+	require.NoError(t, err)
+	s.SysloadLimit = loadLimit
+
+	t.Run("orders regions by client distance then fallback", func(t *testing.T) {
+		// us-east is further from London than eu-central, but eu-west falls back to it
+		require.Equal(t, []string{regionEUWest, regionUSEast, regionEUCentral}, s.RegionOrder(london))
+		// fallbacks only apply to the nearest region
+		require.Equal(t, []string{regionUSEast, regionEUWest, regionEUCentral}, s.RegionOrder(newYork))
+		// clients that can't be located are ordered from the current region
+		require.Equal(t, []string{regionEUCentral, regionEUWest, regionUSEast}, s.RegionOrder(nil))
+	})
+
+	t.Run("picks the region nearest to the client", func(t *testing.T) {
+		expectedNode := newTestNodeInRegion(regionEUWest, true)
+		nodes := []*livekit.Node{
+			newTestNodeInRegion(regionEUCentral, true),
+			expectedNode,
+			newTestNodeInRegion(regionUSEast, true),
+		}
+		node, err := s.SelectNodeNear(nodes, london)
+		require.NoError(t, err)
+		require.Equal(t, expectedNode, node)
+
+		// without a location, the current region
+		node, err = s.SelectNodeNear(nodes, nil)
+		require.NoError(t, err)
+		require.Equal(t, regionEUCentral, node.Region)
+	})
+
+	t.Run("skips full regions in fallback order", func(t *testing.T) {
+		expectedNode := newTestNodeInRegion(regionUSEast, true)
+		nodes := []*livekit.Node{
+			newTestNodeInRegion(regionEUWest, false),
+			newTestNodeInRegion(regionEUCentral, true),
+			expectedNode,
+		}
+		node, err := s.SelectNodeNear(nodes, london)
+		require.NoError(t, err)
+		require.Equal(t, expectedNode, node)
+
+		// regions without fresh stats are down
+		expectedNode = newTestNodeInRegion(regionEUCentral, true)
+		down := newTestNodeInRegion(regionUSEast, true)
+		down.Stats.UpdatedAt = time.Now().Add(-time.Minute).Unix()
+		nodes = []*livekit.Node{
+			newTestNodeInRegion(regionEUWest, false),
+			down,
+			expectedNode,
+		}
+		node, err = s.SelectNodeNear(nodes, london)
+		require.NoError(t, err)
+		require.Equal(t, expectedNode, node)
+	})
+
+	t.Run("picks an overloaded node in the nearest region when all are full", func(t *testing.T) {
+		expectedNode := newTestNodeInRegion(regionEUWest, false)
+		nodes := []*livekit.Node{
+			newTestNodeInRegion(regionEUCentral, false),
+			expectedNode,
+			newTestNodeInRegion(regionUSEast, false),
+		}
+		node, err := s.SelectNodeNear(nodes, london)
+		require.NoError(t, err)
+		require.Equal(t, expectedNode, node)
+	})
+}
+
 func newTestNodeInRegion(region string, available bool) *livekit.Node {
 	load := float32(0.4)
 	if !available {
@@ -17,6 +17,14 @@ func (s *SystemLoadSelector) filterNodes(nodes []*livekit.Node) ([]*livekit.Node
 		return nil, ErrNoAvailableNodes
 	}
 
+	nodesLowLoad := s.lowLoadNodes(nodes)
+	if len(nodesLowLoad) > 0 {
+		nodes = nodesLowLoad
+	}
+	return nodes, nil
+}
+
+func (s *SystemLoadSelector) lowLoadNodes(nodes []*livekit.Node) []*livekit.Node {
 	nodesLowLoad := make([]*livekit.Node, 0)
 	for _, node := range nodes {
 		stats := node.Stats
@@ -28,10 +36,7 @@ func (s *SystemLoadSelector) filterNodes(nodes []*livekit.Node) ([]*livekit.Node
 			nodesLowLoad = append(nodesLowLoad, node)
 		}
 	}
-	if len(nodesLowLoad) > 0 {
-		nodes = nodesLowLoad
-	}
-	return nodes, nil
+	return nodesLowLoad
 }
 
 func (s *SystemLoadSelector) SelectNode(nodes []*livekit.Node) (*livekit.Node, error) {
@@ -7,12 +7,16 @@ import (
 	"github.com/thoas/go-funk"
 
 	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
 const AvailableSeconds = 5
 
 // checks if a node has been updated recently to be considered for selection
 func IsAvailable(node *livekit.Node) bool {
+	if node.Stats == nil {
+		return false
+	}
 	delta := time.Now().Unix() - node.Stats.UpdatedAt
 	return int(delta) < AvailableSeconds
 }
@@ -23,6 +27,18 @@ func GetAvailableNodes(nodes []*livekit.Node) []*livekit.Node {
 	}).([]*livekit.Node)
 }
 
+// ReportsStat returns whether the node reports stat, which is zero otherwise. report is nil for nodes from before
+// reports were published, CPU stats are also missing on nodes that can't read them
+func ReportsStat(node *livekit.Node, report *prometheus.NodeStatsReport, stat string) bool {
+	if node.Stats == nil {
+		return false
+	}
+	if stat == config.NodeStatCPU && node.Stats.NumCpus == 0 {
+		return false
+	}
+	return report.Reports(stat)
+}
+
 // TODO: check remote node configured limit, instead of this node's config
 func LimitsReached(limitConfig config.LimitConfig, nodeStats *livekit.NodeStats) bool {
 	if nodeStats == nil {
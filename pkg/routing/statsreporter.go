@@ -0,0 +1,55 @@
+package routing
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+// NodeStatsReporter samples the stats of the current node, which routers publish with its registration every
+// Interval
+type NodeStatsReporter struct {
+	conf        config.NodeStatsConfig
+	currentNode LocalNode
+
+	lock   sync.RWMutex
+	report *prometheus.NodeStatsReport
+}
+
+func NewNodeStatsReporter(conf config.NodeStatsConfig, currentNode LocalNode) *NodeStatsReporter {
+	return &NodeStatsReporter{
+		conf:        conf,
+		currentNode: currentNode,
+		report: &prometheus.NodeStatsReport{
+			Version:  prometheus.NodeStatsVersion,
+			Reported: []string{},
+		},
+	}
+}
+
+func (s *NodeStatsReporter) Interval() time.Duration {
+	return s.conf.Interval.Duration()
+}
+
+// Update samples stats, replacing those of the current node
+func (s *NodeStatsReporter) Update() error {
+	stats, report, err := prometheus.GetUpdatedNodeStats(s.currentNode.Stats, &s.conf)
+	if err != nil {
+		return err
+	}
+	s.currentNode.Stats = stats
+
+	s.lock.Lock()
+	s.report = report
+	s.lock.Unlock()
+	return nil
+}
+
+// Report returns the stats sampled last that livekit.NodeStats has no field for
+func (s *NodeStatsReporter) Report() *prometheus.NodeStatsReport {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.report
+}
@@ -0,0 +1,48 @@
+package routing_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+func TestNodeStatsReporter(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+	startedAt := node.Stats.StartedAt
+
+	s := routing.NewNodeStatsReporter(config.NodeStatsConfig{
+		Interval: config.Duration(time.Second),
+		Include:  []string{config.NodeStatGoroutines, config.NodeStatMemory, config.NodeStatTracks},
+	}, node)
+	require.Equal(t, time.Second, s.Interval())
+	// nothing is reported until stats are sampled
+	require.Empty(t, s.Report().Reported)
+
+	require.NoError(t, s.Update())
+	require.Equal(t, startedAt, node.Stats.StartedAt)
+	require.NotZero(t, node.Stats.UpdatedAt)
+	// stats that aren't included are zero
+	require.Zero(t, node.Stats.NumCpus)
+	require.Zero(t, node.Stats.LoadAvgLast1Min)
+
+	report := s.Report()
+	require.Equal(t, prometheus.NodeStatsVersion, report.Version)
+	require.ElementsMatch(t, []string{config.NodeStatGoroutines, config.NodeStatMemory, config.NodeStatTracks}, report.Reported)
+	require.Positive(t, report.NumGoroutines)
+	require.Positive(t, report.MemoryUsed)
+	require.True(t, report.Reports(config.NodeStatTracks))
+	require.False(t, report.Reports(config.NodeStatCPU))
+
+	// nodes without a report report what livekit.NodeStats holds
+	var old *prometheus.NodeStatsReport
+	require.True(t, old.Reports(config.NodeStatCPU))
+	require.False(t, old.Reports(config.NodeStatMemory))
+}
@@ -3,6 +3,7 @@ package routing
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/livekit/protocol/livekit"
 )
@@ -20,3 +21,18 @@ func parseParticipantKey(pkey livekit.ParticipantKey) (roomName livekit.RoomName
 
 	return livekit.RoomName(parts[0]), livekit.ParticipantIdentity(parts[1]), nil
 }
+
+// unixNano is 0 for the zero time, which has no unix representation
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
@@ -0,0 +1,195 @@
+package rtc
+
+import (
+	"sort"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+type SpeakerLevel struct {
+	ParticipantID livekit.ParticipantID
+	// converted level, 0 when not active
+	Level  float32
+	Active bool
+}
+
+// ActiveSpeakers ranks speakers by their smoothed audio level and keeps the list steady: it holds up to TopN
+// speakers, and a speaker stays for HoldDuration after last being ranked in the top N. Not safe for concurrent use
+type ActiveSpeakers struct {
+	// exponential moving average (EMA) factor, 0 when levels are not smoothed
+	smoothFactor    float32
+	activeThreshold float32
+
+	levels   map[livekit.ParticipantID]float32
+	speakers *heldRanking
+}
+
+func NewActiveSpeakers(audioConfig *config.AudioConfig) *ActiveSpeakers {
+	a := &ActiveSpeakers{
+		activeThreshold: ConvertAudioLevel(audioConfig.ActiveLevel),
+		levels:          make(map[livekit.ParticipantID]float32),
+		speakers: newHeldRanking(
+			audioConfig.ActiveSpeakers.TopN,
+			audioConfig.ActiveSpeakers.HoldDuration.Duration(),
+		),
+	}
+	if ss := audioConfig.SmoothIntervals; ss > 1 {
+		// same center of mass as a simple moving average (SMA) over ss intervals
+		a.smoothFactor = 2 / float32(ss+1)
+	}
+	return a
+}
+
+// Update takes the levels of the participants in the room, and returns the speakers in the list sorted by level,
+// the speakers that dropped out of it, and whether the set of speakers changed
+func (a *ActiveSpeakers) Update(participants []SpeakerLevel, now time.Time) ([]*livekit.SpeakerInfo, []livekit.ParticipantID, bool) {
+	present := make(map[string]bool, len(participants))
+	candidates := make([]*livekit.SpeakerInfo, 0, len(participants))
+	for _, p := range participants {
+		present[string(p.ParticipantID)] = true
+
+		level, isCandidate := a.smooth(p)
+		if !isCandidate {
+			continue
+		}
+		candidates = append(candidates, &livekit.SpeakerInfo{
+			Sid:    string(p.ParticipantID),
+			Level:  level,
+			Active: true,
+		})
+	}
+	for pID := range a.levels {
+		if !present[string(pID)] {
+			delete(a.levels, pID)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Level > candidates[j].Level
+	})
+	ranked := make([]string, 0, len(candidates))
+	for _, speaker := range candidates {
+		ranked = append(ranked, speaker.Sid)
+	}
+
+	// participants that left drop out right away
+	dropped, changed := a.speakers.update(ranked, present, now)
+	removed := make([]livekit.ParticipantID, 0, len(dropped))
+	for _, sid := range dropped {
+		removed = append(removed, livekit.ParticipantID(sid))
+	}
+
+	speakers := make([]*livekit.SpeakerInfo, 0, a.speakers.len())
+	for sid := range a.speakers.entries {
+		speakers = append(speakers, &livekit.SpeakerInfo{
+			Sid:    sid,
+			Level:  a.levels[livekit.ParticipantID(sid)],
+			Active: true,
+		})
+	}
+	sort.Slice(speakers, func(i, j int) bool {
+		return speakers[i].Level > speakers[j].Level
+	})
+
+	return speakers, removed, changed
+}
+
+// Level returns the participant's smoothed level, 0 when not speaking
+func (a *ActiveSpeakers) Level(pID livekit.ParticipantID) float32 {
+	return a.levels[pID]
+}
+
+// smooth returns the participant's smoothed level, and whether it is loud enough to be ranked
+func (a *ActiveSpeakers) smooth(p SpeakerLevel) (float32, bool) {
+	level := p.Level
+	if !p.Active {
+		level = 0
+	}
+
+	if a.smoothFactor == 0 {
+		if p.Active {
+			a.levels[p.ParticipantID] = level
+		} else {
+			delete(a.levels, p.ParticipantID)
+		}
+		return level, p.Active
+	}
+
+	smoothed := a.levels[p.ParticipantID]
+	smoothed += (level - smoothed) * a.smoothFactor
+	// previous speakers decay until they fall below the active threshold
+	if !p.Active && smoothed <= a.activeThreshold {
+		delete(a.levels, p.ParticipantID)
+		return 0, false
+	}
+	a.levels[p.ParticipantID] = smoothed
+	return smoothed, true
+}
+
+// heldRanking keeps the top n entries of a ranking, 0 for no limit. An entry stays for hold after it was last
+// ranked in the top n, higher ranked entries take its place only after
+type heldRanking struct {
+	n    int
+	hold time.Duration
+
+	// entries kept, by when last ranked in the top n
+	entries map[string]time.Time
+}
+
+func newHeldRanking(n int, hold time.Duration) *heldRanking {
+	return &heldRanking{
+		n:       n,
+		hold:    hold,
+		entries: make(map[string]time.Time),
+	}
+}
+
+// update takes the ranking, highest first. Entries no longer eligible drop out right away. Returns the entries that
+// dropped out, and whether any entry was added or dropped
+func (h *heldRanking) update(ranked []string, eligible map[string]bool, now time.Time) ([]string, bool) {
+	topN := ranked
+	if h.n > 0 && len(topN) > h.n {
+		topN = topN[:h.n]
+	}
+	inTopN := make(map[string]bool, len(topN))
+	for _, id := range topN {
+		inTopN[id] = true
+		if _, ok := h.entries[id]; ok {
+			h.entries[id] = now
+		}
+	}
+
+	var dropped []string
+	for id, rankedAt := range h.entries {
+		if !eligible[id] || !inTopN[id] && now.Sub(rankedAt) >= h.hold {
+			delete(h.entries, id)
+			dropped = append(dropped, id)
+		}
+	}
+
+	// free places go to the highest ranked entries not kept yet
+	changed := len(dropped) != 0
+	for _, id := range topN {
+		if h.n > 0 && len(h.entries) >= h.n {
+			break
+		}
+		if _, ok := h.entries[id]; !ok {
+			h.entries[id] = now
+			changed = true
+		}
+	}
+
+	return dropped, changed
+}
+
+func (h *heldRanking) contains(id string) bool {
+	_, ok := h.entries[id]
+	return ok
+}
+
+func (h *heldRanking) len() int {
+	return len(h.entries)
+}
@@ -0,0 +1,139 @@
+package rtc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc"
+)
+
+func speakerSids(speakers []*livekit.SpeakerInfo) []string {
+	sids := make([]string, 0, len(speakers))
+	for _, speaker := range speakers {
+		sids = append(sids, speaker.Sid)
+	}
+	return sids
+}
+
+func speakerLevels(levels map[livekit.ParticipantID]uint8) []rtc.SpeakerLevel {
+	var speakerLevels []rtc.SpeakerLevel
+	for pID, level := range levels {
+		speakerLevels = append(speakerLevels, rtc.SpeakerLevel{
+			ParticipantID: pID,
+			Level:         rtc.ConvertAudioLevel(level),
+			Active:        level < 127,
+		})
+	}
+	return speakerLevels
+}
+
+func TestActiveSpeakersTopN(t *testing.T) {
+	a := rtc.NewActiveSpeakers(&config.AudioConfig{
+		ActiveLevel: 35,
+		ActiveSpeakers: config.ActiveSpeakersConfig{
+			TopN: 2,
+		},
+	})
+
+	now := time.Now()
+	speakers, removed, changed := a.Update(speakerLevels(map[livekit.ParticipantID]uint8{
+		"p1": 30,
+		"p2": 10,
+		"p3": 20,
+		"p4": 127,
+	}), now)
+	require.True(t, changed)
+	require.Empty(t, removed)
+	require.Equal(t, []string{"p2", "p3"}, speakerSids(speakers))
+	require.Equal(t, rtc.ConvertAudioLevel(10), speakers[0].Level)
+
+	// same set with different levels, no update
+	_, _, changed = a.Update(speakerLevels(map[livekit.ParticipantID]uint8{
+		"p1": 30,
+		"p2": 20,
+		"p3": 15,
+		"p4": 127,
+	}), now.Add(time.Second))
+	require.False(t, changed)
+
+	// without a hold, p3 is replaced as soon as it is outranked
+	speakers, removed, changed = a.Update(speakerLevels(map[livekit.ParticipantID]uint8{
+		"p1": 30,
+		"p2": 20,
+		"p3": 127,
+		"p4": 127,
+	}), now.Add(2*time.Second))
+	require.True(t, changed)
+	require.Equal(t, []livekit.ParticipantID{"p3"}, removed)
+	require.Equal(t, []string{"p2", "p1"}, speakerSids(speakers))
+}
+
+func TestActiveSpeakersHold(t *testing.T) {
+	a := rtc.NewActiveSpeakers(&config.AudioConfig{
+		ActiveLevel: 35,
+		ActiveSpeakers: config.ActiveSpeakersConfig{
+			TopN:         1,
+			HoldDuration: config.Duration(time.Second),
+		},
+	})
+
+	now := time.Now()
+	speakers, _, _ := a.Update(speakerLevels(map[livekit.ParticipantID]uint8{
+		"p1": 20,
+		"p2": 127,
+	}), now)
+	require.Equal(t, []string{"p1"}, speakerSids(speakers))
+
+	// a louder speaker waits for the hold to end
+	_, _, changed := a.Update(speakerLevels(map[livekit.ParticipantID]uint8{
+		"p1": 127,
+		"p2": 10,
+	}), now.Add(500*time.Millisecond))
+	require.False(t, changed)
+
+	speakers, removed, changed := a.Update(speakerLevels(map[livekit.ParticipantID]uint8{
+		"p1": 127,
+		"p2": 10,
+	}), now.Add(time.Second))
+	require.True(t, changed)
+	require.Equal(t, []livekit.ParticipantID{"p1"}, removed)
+	require.Equal(t, []string{"p2"}, speakerSids(speakers))
+
+	// participants leaving drop out right away
+	speakers, removed, changed = a.Update(speakerLevels(map[livekit.ParticipantID]uint8{
+		"p1": 127,
+	}), now.Add(1100*time.Millisecond))
+	require.True(t, changed)
+	require.Equal(t, []livekit.ParticipantID{"p2"}, removed)
+	require.Empty(t, speakers)
+}
+
+func TestActiveSpeakersSmoothing(t *testing.T) {
+	a := rtc.NewActiveSpeakers(&config.AudioConfig{
+		ActiveLevel:     35,
+		SmoothIntervals: 3,
+	})
+
+	now := time.Now()
+	level := rtc.ConvertAudioLevel(20)
+	speakers, _, changed := a.Update(speakerLevels(map[livekit.ParticipantID]uint8{"p1": 20}), now)
+	require.True(t, changed)
+	require.InDelta(t, level/2, speakers[0].Level, 1e-6)
+
+	speakers, _, _ = a.Update(speakerLevels(map[livekit.ParticipantID]uint8{"p1": 20}), now.Add(time.Second))
+	require.InDelta(t, level*3/4, speakers[0].Level, 1e-6)
+
+	// decays while above the active level
+	speakers, _, changed = a.Update(speakerLevels(map[livekit.ParticipantID]uint8{"p1": 127}), now.Add(2*time.Second))
+	require.False(t, changed)
+	require.InDelta(t, level*3/8, speakers[0].Level, 1e-6)
+
+	for i := 3; i < 10 && len(speakers) != 0; i++ {
+		speakers, _, _ = a.Update(speakerLevels(map[livekit.ParticipantID]uint8{"p1": 127}), now.Add(time.Duration(i)*time.Second))
+	}
+	require.Empty(t, speakers)
+}
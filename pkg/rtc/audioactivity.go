@@ -0,0 +1,79 @@
+package rtc
+
+import (
+	"go.uber.org/atomic"
+)
+
+// AudioActivity declares an audio track silent once it stays below the active level for a while,
+// e.g. a microphone muted at the OS level or a dead mic, while packets keep flowing.
+// Activity is evaluated once per observe window, as for AudioLevel, so that a silent track
+// is declared active again within one window of speaking
+type AudioActivity struct {
+	levelThreshold uint8
+	// min duration within a window to be considered active
+	minActiveDuration uint32
+	durationToObserve uint32 // ms
+	silenceTimeout    uint32 // ms
+
+	isSilent atomic.Bool
+
+	onSilenceChanged func(silent bool)
+
+	// for Observe goroutine use
+	activeDuration   uint32 // ms
+	observedDuration uint32 // ms
+	silentDuration   uint32 // ms
+}
+
+func NewAudioActivity(activeLevel uint8, minPercentile uint8, observeDuration uint32, silenceTimeout uint32) *AudioActivity {
+	return &AudioActivity{
+		levelThreshold:    activeLevel,
+		minActiveDuration: uint32(minPercentile) * observeDuration / 100,
+		durationToObserve: observeDuration,
+		silenceTimeout:    silenceTimeout,
+	}
+}
+
+// OnSilenceChanged is called from the Observe goroutine when the track is declared silent or active again
+func (a *AudioActivity) OnSilenceChanged(f func(silent bool)) {
+	a.onSilenceChanged = f
+}
+
+// Observes a new frame, must be called from the same thread
+func (a *AudioActivity) Observe(level uint8, durationMs uint32) {
+	a.observedDuration += durationMs
+	if level <= a.levelThreshold {
+		a.activeDuration += durationMs
+	}
+
+	if a.observedDuration < a.durationToObserve {
+		return
+	}
+
+	if a.activeDuration > 0 && a.activeDuration >= a.minActiveDuration {
+		a.silentDuration = 0
+		a.setSilent(false)
+	} else {
+		a.silentDuration += a.observedDuration
+		if a.silentDuration >= a.silenceTimeout {
+			a.setSilent(true)
+		}
+	}
+	a.activeDuration = 0
+	a.observedDuration = 0
+}
+
+// IsSilent returns true when the track has been below the active level for the silence timeout
+func (a *AudioActivity) IsSilent() bool {
+	return a.isSilent.Load()
+}
+
+func (a *AudioActivity) setSilent(silent bool) {
+	if a.isSilent.Swap(silent) == silent {
+		return
+	}
+
+	if a.onSilenceChanged != nil {
+		a.onSilenceChanged(silent)
+	}
+}
@@ -0,0 +1,160 @@
+package rtc_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+const (
+	// 5 windows
+	defaultSilenceTimeout = 2500 // ms
+	audioLevelExtID       = 1
+)
+
+func TestAudioActivity(t *testing.T) {
+	newAudioActivity := func() (*rtc.AudioActivity, *[]bool) {
+		a := rtc.NewAudioActivity(defaultActiveLevel, defaultPercentile, defaultObserveDuration, defaultSilenceTimeout)
+		var changes []bool
+		a.OnSilenceChanged(func(silent bool) {
+			changes = append(changes, silent)
+		})
+		return a, &changes
+	}
+
+	t.Run("silent after the timeout below the active level", func(t *testing.T) {
+		a, changes := newAudioActivity()
+
+		observeActivitySamples(a, 35, 4*samplesPerBatch)
+		require.False(t, a.IsSilent())
+		require.Empty(t, *changes)
+
+		observeActivitySamples(a, 35, samplesPerBatch)
+		require.True(t, a.IsSilent())
+		require.Equal(t, []bool{true}, *changes)
+
+		// no repeats while silent
+		observeActivitySamples(a, 127, 10*samplesPerBatch)
+		require.Equal(t, []bool{true}, *changes)
+	})
+
+	t.Run("active again within one window", func(t *testing.T) {
+		a, changes := newAudioActivity()
+
+		observeActivitySamples(a, 127, 5*samplesPerBatch)
+		require.True(t, a.IsSilent())
+
+		observeActivitySamples(a, 25, samplesPerBatch)
+		require.False(t, a.IsSilent())
+		require.Equal(t, []bool{true, false}, *changes)
+	})
+
+	t.Run("occasional noise does not count as activity", func(t *testing.T) {
+		a, changes := newAudioActivity()
+
+		for i := 0; i < 5; i++ {
+			observeActivitySamples(a, 35, samplesPerBatch-1)
+			observeActivitySamples(a, 25, 1)
+		}
+		require.True(t, a.IsSilent())
+		require.Equal(t, []bool{true}, *changes)
+	})
+
+	t.Run("activity restarts the timeout", func(t *testing.T) {
+		a, _ := newAudioActivity()
+
+		observeActivitySamples(a, 35, 4*samplesPerBatch)
+		observeActivitySamples(a, 25, samplesPerBatch)
+		observeActivitySamples(a, 35, 4*samplesPerBatch)
+		require.False(t, a.IsSilent())
+	})
+}
+
+func TestAudioActivityFromAudioLevelExtension(t *testing.T) {
+	pool := &sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, 1500)
+			return &b
+		},
+	}
+	codec := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		PayloadType:        111,
+	}
+	buff := buffer.NewBuffer(1234, pool, pool)
+	buff.Bind(webrtc.RTPParameters{
+		Codecs:           []webrtc.RTPCodecParameters{codec},
+		HeaderExtensions: []webrtc.RTPHeaderExtensionParameter{{URI: sdp.AudioLevelURI, ID: audioLevelExtID}},
+	}, codec.RTPCodecCapability, buffer.Options{})
+
+	a := rtc.NewAudioActivity(defaultActiveLevel, defaultPercentile, defaultObserveDuration, defaultSilenceTimeout)
+	changes := make(chan bool, 10)
+	a.OnSilenceChanged(func(silent bool) {
+		changes <- silent
+	})
+	buff.OnAudioLevel(a.Observe)
+
+	sn := uint16(1)
+	ts := uint32(0)
+	// writes 20ms opus packets carrying the audio level extension
+	writePackets := func(level uint8, count int) {
+		for i := 0; i < count; i++ {
+			audioLevel := rtp.AudioLevelExtension{Level: level, Voice: level <= defaultActiveLevel}
+			ext, err := audioLevel.Marshal()
+			require.NoError(t, err)
+
+			pkt := rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					PayloadType:    111,
+					SequenceNumber: sn,
+					Timestamp:      ts,
+					SSRC:           1234,
+				},
+				Payload: []byte{0xfc, 0xff, 0xfe},
+			}
+			require.NoError(t, pkt.SetExtension(audioLevelExtID, ext))
+			raw, err := pkt.Marshal()
+			require.NoError(t, err)
+			_, err = buff.Write(raw)
+			require.NoError(t, err)
+
+			sn++
+			ts += 960
+
+			// audio levels are delivered through a bounded queue, do not outrun it
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitChange := func(expected bool) {
+		select {
+		case silent := <-changes:
+			require.Equal(t, expected, silent)
+		case <-time.After(time.Second):
+			t.Fatalf("silence not changed to %v", expected)
+		}
+	}
+
+	// keepalives with silence
+	writePackets(127, 6*samplesPerBatch)
+	waitChange(true)
+	require.True(t, a.IsSilent())
+
+	writePackets(20, samplesPerBatch+1)
+	waitChange(false)
+	require.False(t, a.IsSilent())
+}
+
+func observeActivitySamples(a *rtc.AudioActivity, level uint8, count int) {
+	for i := 0; i < count; i++ {
+		a.Observe(level, 20)
+	}
+}
@@ -0,0 +1,68 @@
+package rtc
+
+import (
+	"sort"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+)
+
+// forwarded audio stays for a while after being outranked, so that speech is not cut off between words
+const audioForwardingHold = 2 * time.Second
+
+// AudioForwarding limits the audio tracks forwarded to a subscriber to the loudest ones, ranked by their publisher's
+// level. Muted tracks, by either side, do not take a place. Tracks stay subscribed, only forwarding is paused.
+// Not safe for concurrent use
+type AudioForwarding struct {
+	forwarded *heldRanking
+}
+
+func NewAudioForwarding(maxTracks int) *AudioForwarding {
+	return &AudioForwarding{
+		forwarded: newHeldRanking(maxTracks, audioForwardingHold),
+	}
+}
+
+// Update pauses forwarding of the subscribed audio tracks that are not among the loudest
+func (f *AudioForwarding) Update(subscribedTracks []types.SubscribedTrack, level func(livekit.ParticipantID) float32, now time.Time) {
+	var tracks []types.SubscribedTrack
+	eligible := make(map[string]bool)
+	levels := make(map[string]float32)
+	for _, st := range subscribedTracks {
+		if st.MediaTrack().Kind() != livekit.TrackType_AUDIO {
+			continue
+		}
+		tracks = append(tracks, st)
+		if st.MediaTrack().IsMuted() || st.IsMuted() {
+			continue
+		}
+
+		trackID := string(st.ID())
+		eligible[trackID] = true
+		levels[trackID] = level(st.PublisherID())
+	}
+
+	ranked := make([]string, 0, len(eligible))
+	for trackID := range eligible {
+		ranked = append(ranked, trackID)
+	}
+	// on equal levels, i.e. silence, tracks forwarded already rank first
+	sort.Slice(ranked, func(i, j int) bool {
+		if levels[ranked[i]] != levels[ranked[j]] {
+			return levels[ranked[i]] > levels[ranked[j]]
+		}
+		if fi, fj := f.forwarded.contains(ranked[i]), f.forwarded.contains(ranked[j]); fi != fj {
+			return fi
+		}
+		return ranked[i] < ranked[j]
+	})
+	f.forwarded.update(ranked, eligible, now)
+
+	// muted tracks are not paused, so that they are heard right away when unmuted
+	for _, st := range tracks {
+		trackID := string(st.ID())
+		st.SetForwardingPaused(eligible[trackID] && !f.forwarded.contains(trackID))
+	}
+}
@@ -0,0 +1,80 @@
+package rtc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/rtc/types/typesfakes"
+)
+
+func newSubscribedAudioTrack(publisherID livekit.ParticipantID) *typesfakes.FakeSubscribedTrack {
+	mt := &typesfakes.FakeMediaTrack{}
+	mt.KindReturns(livekit.TrackType_AUDIO)
+
+	st := &typesfakes.FakeSubscribedTrack{}
+	st.IDReturns(livekit.TrackID("TR_" + publisherID))
+	st.PublisherIDReturns(publisherID)
+	st.MediaTrackReturns(mt)
+	return st
+}
+
+func isForwardingPaused(st *typesfakes.FakeSubscribedTrack) bool {
+	if st.SetForwardingPausedCallCount() == 0 {
+		return false
+	}
+	return st.SetForwardingPausedArgsForCall(st.SetForwardingPausedCallCount() - 1)
+}
+
+func TestAudioForwarding(t *testing.T) {
+	levels := map[livekit.ParticipantID]float32{}
+	level := func(pID livekit.ParticipantID) float32 {
+		return levels[pID]
+	}
+
+	p1 := newSubscribedAudioTrack("p1")
+	p2 := newSubscribedAudioTrack("p2")
+	p3 := newSubscribedAudioTrack("p3")
+	tracks := []types.SubscribedTrack{p1, p2, p3}
+
+	f := rtc.NewAudioForwarding(2)
+	now := time.Now()
+
+	levels["p3"] = 0.5
+	f.Update(tracks, level, now)
+	require.False(t, isForwardingPaused(p3))
+	require.Equal(t, 1, countPaused(p1, p2))
+
+	// the loudest track waits for the hold of a forwarded one to end
+	levels["p1"], levels["p2"], levels["p3"] = 0.3, 0.2, 0.5
+	f.Update(tracks, level, now.Add(time.Second))
+	levels["p1"], levels["p2"], levels["p3"] = 0.3, 0.4, 0.5
+	f.Update(tracks, level, now.Add(2*time.Second))
+	require.True(t, isForwardingPaused(p2))
+
+	f.Update(tracks, level, now.Add(4*time.Second))
+	require.False(t, isForwardingPaused(p2))
+	require.False(t, isForwardingPaused(p3))
+	require.True(t, isForwardingPaused(p1))
+
+	// muted tracks do not take a place, and are not paused
+	p3.MediaTrack().(*typesfakes.FakeMediaTrack).IsMutedReturns(true)
+	f.Update(tracks, level, now.Add(5*time.Second))
+	require.False(t, isForwardingPaused(p1))
+	require.False(t, isForwardingPaused(p2))
+	require.False(t, isForwardingPaused(p3))
+}
+
+func countPaused(tracks ...*typesfakes.FakeSubscribedTrack) int {
+	paused := 0
+	for _, st := range tracks {
+		if isForwardingPaused(st) {
+			paused++
+		}
+	}
+	return paused
+}
@@ -0,0 +1,52 @@
+package rtc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// ParseICECandidateTypes converts rtc.ice_candidate_types entries. An empty list allows every type
+func ParseICECandidateTypes(types []string) ([]webrtc.ICECandidateType, error) {
+	if err := config.ValidateICECandidateTypes(types); err != nil {
+		return nil, err
+	}
+	var parsed []webrtc.ICECandidateType
+	for _, t := range types {
+		typ, err := webrtc.NewICECandidateType(t)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported ICE candidate type: %s", t)
+		}
+		parsed = append(parsed, typ)
+	}
+	return parsed, nil
+}
+
+func candidateTypeAllowed(allowed []webrtc.ICECandidateType, typ string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a.String() == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteCandidateType returns the type of a trickled candidate, false when it cannot be parsed,
+// leaving the error to pion
+func remoteCandidateType(candidate webrtc.ICECandidateInit) (string, bool) {
+	if candidate.Candidate == "" {
+		return "", false
+	}
+	c, err := ice.UnmarshalCandidate(strings.TrimPrefix(candidate.Candidate, "candidate:"))
+	if err != nil {
+		return "", false
+	}
+	return c.Type().String(), true
+}
@@ -0,0 +1,153 @@
+package rtc
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+)
+
+// codecs that only accompany a media codec, they're left for pion to negotiate
+var auxiliaryCodecs = map[string]bool{
+	"red":             true,
+	"ulpfec":          true,
+	"flexfec-03":      true,
+	"cn":              true,
+	"telephone-event": true,
+}
+
+// rejectedMediaSection is an offered m-line without any enabled codec
+type rejectedMediaSection struct {
+	Mid     string
+	TrackID string
+	Codecs  []string
+}
+
+// filterOfferCodecs removes codecs that aren't enabled from a remote offer. pion would otherwise accept a codec whose
+// fmtp doesn't match any enabled one, by falling back to matching on mime type alone. Sections left without an enabled
+// codec are returned, so that they can be rejected in the answer
+func filterOfferCodecs(offer webrtc.SessionDescription, enabledCodecs []*livekit.Codec) (webrtc.SessionDescription, []rejectedMediaSection, error) {
+	parsed, err := offer.Unmarshal()
+	if err != nil {
+		return offer, nil, err
+	}
+
+	var rejected []rejectedMediaSection
+	for _, m := range parsed.MediaDescriptions {
+		kind := m.MediaName.Media
+		if (kind != "audio" && kind != "video") || m.MediaName.Port.Value == 0 {
+			continue
+		}
+		single := &sdp.SessionDescription{MediaDescriptions: []*sdp.MediaDescription{m}}
+
+		kept := make(map[string]bool)
+		var offered []string
+		var rtx []string
+		numEnabled := 0
+		for _, format := range m.MediaName.Formats {
+			payloadType, err := strconv.Atoi(format)
+			if err != nil {
+				continue
+			}
+			codec, err := single.GetCodecForPayloadType(uint8(payloadType))
+			if err != nil {
+				continue
+			}
+			name := strings.ToLower(codec.Name)
+			switch {
+			case name == "rtx":
+				rtx = append(rtx, format)
+			case auxiliaryCodecs[name]:
+				kept[format] = true
+			default:
+				mime := kind + "/" + codec.Name
+				offered = append(offered, mime)
+				if isCodecEnabled(enabledCodecs, webrtc.RTPCodecCapability{MimeType: mime, SDPFmtpLine: codec.Fmtp}) {
+					kept[format] = true
+					numEnabled++
+				}
+			}
+		}
+		for _, format := range rtx {
+			payloadType, _ := strconv.Atoi(format)
+			codec, err := single.GetCodecForPayloadType(uint8(payloadType))
+			if err == nil && kept[parseFmtp(codec.Fmtp)["apt"]] {
+				kept[format] = true
+			}
+		}
+
+		if numEnabled == 0 {
+			mid, _ := m.Attribute(sdp.AttrKeyMID)
+			section := rejectedMediaSection{Mid: mid, Codecs: offered}
+			if msid, ok := m.Attribute(sdp.AttrKeyMsid); ok {
+				if parts := strings.Fields(msid); len(parts) == 2 {
+					section.TrackID = parts[1]
+				}
+			}
+			rejected = append(rejected, section)
+			// payload type 0 without rtpmap is skipped by pion, the same way it rejects sections itself
+			kept = map[string]bool{}
+			m.MediaName.Formats = []string{"0"}
+		} else {
+			formats := m.MediaName.Formats[:0]
+			for _, format := range m.MediaName.Formats {
+				if kept[format] {
+					formats = append(formats, format)
+				}
+			}
+			m.MediaName.Formats = formats
+		}
+		m.Attributes = filterCodecAttributes(m.Attributes, kept)
+	}
+
+	data, err := parsed.Marshal()
+	if err != nil {
+		return offer, nil, err
+	}
+	offer.SDP = string(data)
+	return offer, rejected, nil
+}
+
+// filterCodecAttributes drops the rtpmap, fmtp and rtcp-fb attributes of payload types that aren't kept
+func filterCodecAttributes(attributes []sdp.Attribute, kept map[string]bool) []sdp.Attribute {
+	filtered := attributes[:0]
+	for _, a := range attributes {
+		switch a.Key {
+		case "rtpmap", "fmtp", "rtcp-fb":
+			payloadType := strings.SplitN(a.Value, " ", 2)[0]
+			if payloadType != "*" && !kept[payloadType] {
+				continue
+			}
+		}
+		filtered = append(filtered, a)
+	}
+	return filtered
+}
+
+// rejectMediaSections sets the port of the given sections to 0, declining them in an answer
+func rejectMediaSections(answer webrtc.SessionDescription, mids []string) (webrtc.SessionDescription, error) {
+	if len(mids) == 0 {
+		return answer, nil
+	}
+	parsed, err := answer.Unmarshal()
+	if err != nil {
+		return answer, err
+	}
+	for _, m := range parsed.MediaDescriptions {
+		mid, _ := m.Attribute(sdp.AttrKeyMID)
+		for _, rejected := range mids {
+			if mid == rejected {
+				m.MediaName.Port = sdp.RangedPort{Value: 0}
+				break
+			}
+		}
+	}
+	data, err := parsed.Marshal()
+	if err != nil {
+		return answer, err
+	}
+	answer.SDP = string(data)
+	return answer, nil
+}
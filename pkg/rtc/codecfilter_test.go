@@ -0,0 +1,123 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+)
+
+func TestHandleOfferCodecs(t *testing.T) {
+	const (
+		h264Baseline = "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f"
+		h264High     = "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640032"
+	)
+	enabledCodecs := []*livekit.Codec{
+		{Mime: webrtc.MimeTypeOpus},
+		{Mime: webrtc.MimeTypeVP8},
+		{Mime: webrtc.MimeTypeH264, FmtpLine: "packetization-mode=1;profile-level-id=42e01f"},
+	}
+	clientCodecs := []webrtc.RTPCodecParameters{
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, PayloadType: 96},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP9, ClockRate: 90000, SDPFmtpLine: "profile-id=0"}, PayloadType: 98},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000, SDPFmtpLine: h264Baseline}, PayloadType: 102},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000, SDPFmtpLine: h264High}, PayloadType: 104},
+	}
+
+	// offers one video track per codec list, with track ids a, b, ...
+	offer := func(t *testing.T, tracks ...[]webrtc.RTPCodecParameters) webrtc.SessionDescription {
+		me := &webrtc.MediaEngine{}
+		for _, codec := range clientCodecs {
+			require.NoError(t, me.RegisterCodec(codec, webrtc.RTPCodecTypeVideo))
+		}
+		pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = pc.Close() })
+
+		for i, codecs := range tracks {
+			track, err := webrtc.NewTrackLocalStaticSample(codecs[0].RTPCodecCapability, string(rune('a'+i)), "stream")
+			require.NoError(t, err)
+			tr, err := pc.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+			require.NoError(t, err)
+			require.NoError(t, tr.SetCodecPreferences(codecs))
+		}
+		sd, err := pc.CreateOffer(nil)
+		require.NoError(t, err)
+		return sd
+	}
+	sections := func(t *testing.T, sd webrtc.SessionDescription) []*sdp.MediaDescription {
+		parsed, err := sd.Unmarshal()
+		require.NoError(t, err)
+		var media []*sdp.MediaDescription
+		for _, m := range parsed.MediaDescriptions {
+			if m.MediaName.Media == "video" {
+				media = append(media, m)
+			}
+		}
+		return media
+	}
+	newParticipant := func(t *testing.T, cids ...string) *ParticipantImpl {
+		p := newParticipantForTestWithOpts("test", &participantOpts{enabledCodecs: enabledCodecs})
+		t.Cleanup(func() { _ = p.Close(false) })
+		p.SetMigrateState(types.MigrateStateComplete)
+		for _, cid := range cids {
+			p.AddTrack(&livekit.AddTrackRequest{Cid: cid, Name: cid, Type: livekit.TrackType_VIDEO})
+		}
+		return p
+	}
+
+	t.Run("disallowed codec is rejected", func(t *testing.T) {
+		p := newParticipant(t, "a")
+		answer, err := p.HandleOffer(offer(t, clientCodecs[1:2]))
+		require.NoError(t, err)
+
+		media := sections(t, answer)
+		require.Len(t, media, 1)
+		require.Equal(t, 0, media[0].MediaName.Port.Value)
+		require.Empty(t, p.pendingTracks)
+	})
+
+	t.Run("allowed codec with mismatched fmtp is rejected", func(t *testing.T) {
+		p := newParticipant(t, "a")
+		answer, err := p.HandleOffer(offer(t, clientCodecs[3:4]))
+		require.NoError(t, err)
+
+		media := sections(t, answer)
+		require.Len(t, media, 1)
+		require.Equal(t, 0, media[0].MediaName.Port.Value)
+		require.NotContains(t, answer.SDP, "640032")
+		require.Empty(t, p.pendingTracks)
+	})
+
+	t.Run("disallowed codecs are stripped from a section", func(t *testing.T) {
+		p := newParticipant(t, "a")
+		answer, err := p.HandleOffer(offer(t, []webrtc.RTPCodecParameters{clientCodecs[3], clientCodecs[1], clientCodecs[2]}))
+		require.NoError(t, err)
+
+		media := sections(t, answer)
+		require.Len(t, media, 1)
+		require.NotEqual(t, 0, media[0].MediaName.Port.Value)
+		require.Equal(t, []string{"102"}, media[0].MediaName.Formats)
+		require.NotContains(t, answer.SDP, "VP9")
+		require.NotContains(t, answer.SDP, "640032")
+		require.Contains(t, p.pendingTracks, "a")
+	})
+
+	t.Run("only sections without an allowed codec are rejected", func(t *testing.T) {
+		p := newParticipant(t, "a", "b")
+		answer, err := p.HandleOffer(offer(t, clientCodecs[0:1], clientCodecs[1:2]))
+		require.NoError(t, err)
+
+		media := sections(t, answer)
+		require.Len(t, media, 2)
+		require.NotEqual(t, 0, media[0].MediaName.Port.Value)
+		require.Equal(t, 0, media[1].MediaName.Port.Value)
+		require.Contains(t, p.pendingTracks, "a")
+		require.NotContains(t, p.pendingTracks, "b")
+		require.NotContains(t, answer.SDP, "VP9")
+	})
+}
@@ -3,6 +3,7 @@ package rtc
 import (
 	"errors"
 	"net"
+	"time"
 
 	"github.com/livekit/protocol/logger"
 	"github.com/pion/ice/v2"
@@ -17,7 +18,11 @@ import (
 const (
 	minUDPBufferSize     = 5_000_000
 	defaultUDPBufferSize = 16_777_216
-	frameMarking         = "urn:ietf:params:rtp-hdrext:framemarking"
+
+	// pion defaults, used for timeouts that aren't configured
+	defaultICEDisconnectedTimeout = 5 * time.Second
+	defaultICEFailedTimeout       = 25 * time.Second
+	defaultICEKeepaliveInterval   = 2 * time.Second
 )
 
 type WebRTCConfig struct {
@@ -30,10 +35,16 @@ type WebRTCConfig struct {
 	TCPMuxListener *net.TCPListener
 	Publisher      DirectionConfig
 	Subscriber     DirectionConfig
+	// zero uses defaultNegotiationTimeout
+	NegotiationTimeout time.Duration
+	// candidate types accepted from clients, empty allows all
+	ICECandidateTypes []webrtc.ICECandidateType
+	ConnectionQuality config.ConnectionQualityConfig
 }
 
 type ReceiverConfig struct {
 	PacketBufferSize int
+	PacketBuffer     config.PacketBufferConfig
 	maxBitrate       uint64
 }
 
@@ -50,6 +61,14 @@ type RTCPFeedbackConfig struct {
 type DirectionConfig struct {
 	RTPHeaderExtension RTPHeaderExtensionConfig
 	RTCPFeedback       RTCPFeedbackConfig
+	// tracks are only sent as codecs with compatible fmtp parameters, on m-lines negotiated for them
+	StrictCodecMatching bool
+	// negotiate RED (RFC 2198) for opus
+	EnableRED bool
+	// negotiate retransmission streams (RFC 4588) for video
+	EnableRTX bool
+	// RTCP interceptors registered on the peer connection
+	Interceptors config.DirectionInterceptorsConfig
 }
 
 // number of packets to buffer up
@@ -63,18 +82,79 @@ func NewWebRTCConfig(conf *config.Config, externalIP string) (*WebRTCConfig, err
 	s := webrtc.SettingEngine{
 		LoggerFactory: logging.NewLoggerFactory(logger.GetLogger()),
 	}
+	var err error
 
-	if externalIP != "" {
+	// sharing one certificate avoids generating a key for every peer connection
+	cert, err := newDTLSCertificate(rtcConf)
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		c.Certificates = []webrtc.Certificate{*cert}
+	}
+
+	candidateTypes, err := ParseICECandidateTypes(rtcConf.ICECandidateTypes)
+	if err != nil {
+		return nil, err
+	}
+	if config.IsRelayOnly(rtcConf.ICECandidateTypes) && !conf.HasTURNServer() {
+		return nil, config.ErrRelayWithoutTURN
+	}
+
+	if len(rtcConf.NAT1To1IPs) > 0 {
+		candidateType := webrtc.ICECandidateTypeHost
+		if rtcConf.NAT1To1CandidateType != "" {
+			candidateType, err = webrtc.NewICECandidateType(rtcConf.NAT1To1CandidateType)
+			if err != nil {
+				return nil, err
+			}
+		}
+		s.SetNAT1To1IPs(rtcConf.NAT1To1IPs, candidateType)
+	} else if externalIP != "" {
 		s.SetNAT1To1IPs([]string{externalIP}, webrtc.ICECandidateTypeHost)
 	}
 
+	if timeouts := rtcConf.ICETimeouts; timeouts != (config.ICETimeoutsConfig{}) {
+		disconnected, failed, keepalive := timeouts.Disconnected.Duration(), timeouts.Failed.Duration(), timeouts.KeepaliveInterval.Duration()
+		if disconnected == 0 {
+			disconnected = defaultICEDisconnectedTimeout
+		}
+		if failed == 0 {
+			failed = defaultICEFailedTimeout
+		}
+		if keepalive == 0 {
+			keepalive = defaultICEKeepaliveInterval
+		}
+		s.SetICETimeouts(disconnected, failed, keepalive)
+	}
+
+	filter, err := newInterfaceFilter(rtcConf.Interfaces, rtcConf.IPs)
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		s.SetInterfaceFilter(filter.Allow)
+	}
+
 	if rtcConf.PacketBufferSize == 0 {
 		rtcConf.PacketBufferSize = 500
 	}
+	packetBuffer := rtcConf.PacketBuffer
+	if packetBuffer.Audio == 0 {
+		packetBuffer.Audio = 25
+	}
+	if packetBuffer.VideoLowQuality == 0 {
+		packetBuffer.VideoLowQuality = rtcConf.PacketBufferSize
+	}
+	if packetBuffer.VideoMidQuality == 0 {
+		packetBuffer.VideoMidQuality = rtcConf.PacketBufferSize
+	}
+	if packetBuffer.VideoHighQuality == 0 {
+		packetBuffer.VideoHighQuality = rtcConf.PacketBufferSize
+	}
 
 	var udpMux *ice.UDPMuxDefault
 	var udpMuxConn *net.UDPConn
-	var err error
 	networkTypes := make([]webrtc.NetworkType, 0, 4)
 
 	if !rtcConf.ForceTCP {
@@ -144,7 +224,7 @@ func NewWebRTCConfig(conf *config.Config, externalIP string) (*WebRTCConfig, err
 				sdp.SDESMidURI,
 				sdp.SDESRTPStreamIDURI,
 				sdp.TransportCCURI,
-				frameMarking,
+				buffer.FrameMarkingURI,
 			},
 		},
 		RTCPFeedback: RTCPFeedbackConfig{
@@ -156,6 +236,8 @@ func NewWebRTCConfig(conf *config.Config, externalIP string) (*WebRTCConfig, err
 				{Type: webrtc.TypeRTCPFBNACK, Parameter: "pli"},
 			},
 		},
+		EnableRED:    conf.Audio.EnableRED,
+		Interceptors: rtcConf.Interceptors.Publisher,
 	}
 
 	// subscriber configuration
@@ -167,13 +249,17 @@ func NewWebRTCConfig(conf *config.Config, externalIP string) (*WebRTCConfig, err
 				{Type: webrtc.TypeRTCPFBNACK, Parameter: "pli"},
 			},
 		},
+		StrictCodecMatching: rtcConf.StrictCodecMatching,
+		EnableRED:           conf.Audio.EnableRED,
+		EnableRTX:           rtcConf.EnableRTX,
+		Interceptors:        rtcConf.Interceptors.Subscriber,
 	}
+	// REMB is always offered so that subscribers not negotiating transport-cc still provide estimates
+	subscriberConfig.RTPHeaderExtension.Video = append(subscriberConfig.RTPHeaderExtension.Video, sdp.ABSSendTimeURI)
+	subscriberConfig.RTCPFeedback.Video = append(subscriberConfig.RTCPFeedback.Video, webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBGoogREMB})
 	if rtcConf.CongestionControl.UseSendSideBWE {
 		subscriberConfig.RTPHeaderExtension.Video = append(subscriberConfig.RTPHeaderExtension.Video, sdp.TransportCCURI)
 		subscriberConfig.RTCPFeedback.Video = append(subscriberConfig.RTCPFeedback.Video, webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBTransportCC})
-	} else {
-		subscriberConfig.RTPHeaderExtension.Video = append(subscriberConfig.RTPHeaderExtension.Video, sdp.ABSSendTimeURI)
-		subscriberConfig.RTCPFeedback.Video = append(subscriberConfig.RTCPFeedback.Video, webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBGoogREMB})
 	}
 
 	if rtcConf.UseICELite {
@@ -185,13 +271,17 @@ func NewWebRTCConfig(conf *config.Config, externalIP string) (*WebRTCConfig, err
 		SettingEngine: s,
 		Receiver: ReceiverConfig{
 			PacketBufferSize: rtcConf.PacketBufferSize,
+			PacketBuffer:     packetBuffer,
 			maxBitrate:       rtcConf.MaxBitrate,
 		},
-		UDPMux:         udpMux,
-		UDPMuxConn:     udpMuxConn,
-		TCPMuxListener: tcpListener,
-		Publisher:      publisherConfig,
-		Subscriber:     subscriberConfig,
+		UDPMux:             udpMux,
+		UDPMuxConn:         udpMuxConn,
+		TCPMuxListener:     tcpListener,
+		Publisher:          publisherConfig,
+		Subscriber:         subscriberConfig,
+		NegotiationTimeout: rtcConf.NegotiationTimeout.Duration(),
+		ICECandidateTypes:  candidateTypes,
+		ConnectionQuality:  rtcConf.ConnectionQuality,
 	}, nil
 }
 
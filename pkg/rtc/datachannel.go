@@ -0,0 +1,266 @@
+package rtc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	defaultDataChannelMaxBufferedAmount = 1024 * 1024
+	defaultDataChannelWriteTimeout      = 5 * time.Second
+)
+
+// dataChannelWriter throttles writes to a data channel so that a slow receiver can't grow the send queue without
+// bound. Writes are queued without blocking, up to the max buffered amount, and sent in order. Once more than the max
+// buffered amount is queued on the channel, reliable messages wait for it to drain to half of it for up to the write
+// timeout, lossy ones aren't waited for. Lossy messages that don't fit in the queue or in the channel are dropped.
+// A reliable message that doesn't fit in the queue or times out fails the writer instead, which stops sending and
+// calls onFailed, as the receiver would otherwise carry on with messages missing
+type dataChannelWriter struct {
+	dc                *webrtc.DataChannel
+	kind              livekit.DataPacket_Kind
+	maxMessageSize    int
+	maxBufferedAmount uint64
+	writeTimeout      time.Duration
+	logger            logger.Logger
+	onFailed          func()
+
+	lock sync.Mutex
+	// closed and replaced whenever the channel drains below the low threshold
+	drained     chan struct{}
+	queue       [][]byte
+	queuedBytes uint64
+	closed      bool
+
+	queued    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	failOnce  sync.Once
+}
+
+func newDataChannelWriter(dc *webrtc.DataChannel, conf config.SCTPConfig, lossy bool, logger logger.Logger, onFailed func()) *dataChannelWriter {
+	w := &dataChannelWriter{
+		dc:                dc,
+		kind:              livekit.DataPacket_RELIABLE,
+		maxMessageSize:    int(conf.MaxMessageSize),
+		maxBufferedAmount: conf.MaxBufferedAmount,
+		logger:            logger,
+		onFailed:          onFailed,
+		drained:           make(chan struct{}),
+		queued:            make(chan struct{}, 1),
+		done:              make(chan struct{}),
+	}
+	if w.maxMessageSize == 0 {
+		w.maxMessageSize = config.MaxSCTPMessageSize
+	}
+	if w.maxBufferedAmount == 0 {
+		w.maxBufferedAmount = defaultDataChannelMaxBufferedAmount
+	}
+	if lossy {
+		w.kind = livekit.DataPacket_LOSSY
+	} else {
+		w.writeTimeout = conf.WriteTimeout.Duration()
+		if w.writeTimeout == 0 {
+			w.writeTimeout = defaultDataChannelWriteTimeout
+		}
+	}
+	dc.SetBufferedAmountLowThreshold(w.maxBufferedAmount / 2)
+	dc.OnBufferedAmountLow(w.onBufferedAmountLow)
+	go w.sendWorker()
+	return w
+}
+
+func (w *dataChannelWriter) onBufferedAmountLow() {
+	w.lock.Lock()
+	close(w.drained)
+	w.drained = make(chan struct{})
+	w.lock.Unlock()
+}
+
+// Write queues data to be sent, it never blocks. ErrDataChannelBufferFull is returned when the queue is full, which
+// fails a reliable writer
+func (w *dataChannelWriter) Write(data []byte) error {
+	if len(data) > w.maxMessageSize {
+		return ErrDataMessageTooLarge
+	}
+
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return ErrDataChannelUnavailable
+	}
+	if len(w.queue) != 0 && w.queuedBytes+uint64(len(data)) > w.maxBufferedAmount {
+		w.lock.Unlock()
+		prometheus.IncrementDataPacketDropped(w.kind, prometheus.DataDropBufferFull)
+		if w.kind == livekit.DataPacket_RELIABLE {
+			w.fail("reliable data queue is full, closing data channel", prometheus.DataDropBufferFull)
+		}
+		return ErrDataChannelBufferFull
+	}
+	w.queue = append(w.queue, data)
+	w.queuedBytes += uint64(len(data))
+	w.lock.Unlock()
+
+	select {
+	case w.queued <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close stops sending, dropping queued messages
+func (w *dataChannelWriter) Close() {
+	w.closeOnce.Do(func() {
+		w.lock.Lock()
+		w.closed = true
+		w.queue = nil
+		w.queuedBytes = 0
+		w.lock.Unlock()
+		close(w.done)
+	})
+}
+
+func (w *dataChannelWriter) isClosed() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.closed
+}
+
+// fail gives up on a reliable channel that lost a message, because the queue was full or the channel couldn't take
+// it within the write timeout. Only the first failure calls onFailed
+func (w *dataChannelWriter) fail(msg string, reason string) {
+	w.failOnce.Do(func() {
+		w.lock.Lock()
+		dropped := len(w.queue)
+		w.lock.Unlock()
+		w.logger.Warnw(msg, nil,
+			"label", w.dc.Label(),
+			"writeTimeout", w.writeTimeout,
+			"bufferedAmount", w.dc.BufferedAmount(),
+			"droppedQueued", dropped,
+		)
+		for i := 0; i < dropped; i++ {
+			prometheus.IncrementDataPacketDropped(w.kind, reason)
+		}
+		w.Close()
+		if w.onFailed != nil {
+			w.onFailed()
+		}
+	})
+}
+
+func (w *dataChannelWriter) sendWorker() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.queued:
+		}
+
+		for {
+			w.lock.Lock()
+			if len(w.queue) == 0 {
+				w.lock.Unlock()
+				break
+			}
+			data := w.queue[0]
+			w.queue[0] = nil
+			w.queue = w.queue[1:]
+			w.queuedBytes -= uint64(len(data))
+			w.lock.Unlock()
+
+			if !w.waitForRoom(len(data)) {
+				if w.isClosed() {
+					return
+				}
+				if w.kind == livekit.DataPacket_RELIABLE {
+					prometheus.IncrementDataPacketDropped(w.kind, prometheus.DataDropTimeout)
+					w.fail("could not send reliable data in time, closing data channel", prometheus.DataDropTimeout)
+					return
+				}
+				prometheus.IncrementDataPacketDropped(w.kind, prometheus.DataDropBufferFull)
+				continue
+			}
+			_ = w.dc.Send(data)
+		}
+	}
+}
+
+// waitForRoom returns whether size bytes can be sent now, or once the channel drained within the write timeout
+func (w *dataChannelWriter) waitForRoom(size int) bool {
+	var timeout <-chan time.Time
+	for {
+		w.lock.Lock()
+		drained := w.drained
+		w.lock.Unlock()
+
+		// checked after taking the channel, so that a drain in between isn't missed
+		buffered := w.dc.BufferedAmount()
+		if buffered == 0 || buffered+uint64(size) <= w.maxBufferedAmount {
+			return true
+		}
+		if w.writeTimeout == 0 {
+			return false
+		}
+		if timeout == nil {
+			timer := time.NewTimer(w.writeTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-drained:
+		case <-timeout:
+			return false
+		case <-w.done:
+			return false
+		}
+	}
+}
+
+// dataChannelInit returns the options of a built-in data channel. The lossy channel doesn't retransmit unless
+// configured with a limit
+func dataChannelInit(opts config.DataChannelOptions, lossy bool) *webrtc.DataChannelInit {
+	ordered := opts.Ordered
+	init := &webrtc.DataChannelInit{
+		Ordered:           &ordered,
+		MaxRetransmits:    opts.MaxRetransmits,
+		MaxPacketLifeTime: opts.MaxPacketLifeTime,
+	}
+	if lossy && init.MaxRetransmits == nil && init.MaxPacketLifeTime == nil {
+		retransmits := uint16(0)
+		init.MaxRetransmits = &retransmits
+	}
+	return init
+}
+
+// negotiatedDataChannelInit is dataChannelInit for a channel both sides create with a known id
+func negotiatedDataChannelInit(opts config.DataChannelOptions, lossy bool, id uint16) *webrtc.DataChannelInit {
+	init := dataChannelInit(opts, lossy)
+	negotiated := true
+	init.Negotiated = &negotiated
+	init.ID = &id
+	return init
+}
+
+func hasDataSection(sd *webrtc.SessionDescription) bool {
+	if sd == nil {
+		return false
+	}
+	parsed, err := sd.Unmarshal()
+	if err != nil {
+		return false
+	}
+	for _, m := range parsed.MediaDescriptions {
+		if m.MediaName.Media == "application" {
+			return true
+		}
+	}
+	return false
+}
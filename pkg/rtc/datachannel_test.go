@@ -0,0 +1,155 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func newTestDataChannel(t *testing.T) (*webrtc.DataChannel, *atomic.Int64, func()) {
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{},
+	}
+	transportA, err := NewPCTransport(params)
+	require.NoError(t, err)
+	transportB, err := NewPCTransport(params)
+	require.NoError(t, err)
+	closeTransports := func() {
+		transportA.Close()
+		transportB.Close()
+	}
+	handleICEExchange(t, transportA, transportB)
+	transportA.OnOffer(handleOfferFunc(t, transportA, transportB))
+
+	received := atomic.NewInt64(0)
+	transportB.pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+			received.Add(int64(len(msg.Data)))
+		})
+	})
+
+	dc, err := transportA.CreateDataChannel(reliableDataChannel, dataChannelInit(config.DataChannelOptions{Ordered: true}, false))
+	require.NoError(t, err)
+	opened := make(chan struct{})
+	dc.OnOpen(func() { close(opened) })
+	transportA.Negotiate()
+	select {
+	case <-opened:
+	case <-time.After(5 * time.Second):
+		closeTransports()
+		require.Fail(t, "data channel did not open")
+	}
+	return dc, received, closeTransports
+}
+
+func TestDataChannelWriter(t *testing.T) {
+	const (
+		total       = 10 * 1024 * 1024
+		chunkSize   = 16 * 1024
+		maxBuffered = 256 * 1024
+	)
+	sctpConf := config.SCTPConfig{
+		MaxBufferedAmount: maxBuffered,
+		WriteTimeout:      config.Duration(10 * time.Second),
+	}
+	chunk := make([]byte, chunkSize)
+
+	t.Run("lossy messages are dropped", func(t *testing.T) {
+		dc, received, closeTransports := newTestDataChannel(t)
+		defer closeTransports()
+
+		writer := newDataChannelWriter(dc, sctpConf, true, logger.Logger(logger.GetLogger()), nil)
+		require.ErrorIs(t, writer.Write(make([]byte, config.MaxSCTPMessageSize+1)), ErrDataMessageTooLarge)
+
+		// writes never wait for the receiver, messages beyond what the channel and the queue hold are dropped
+		var accepted, dropped int64
+		var maxSeen uint64
+		startedAt := time.Now()
+		for sent := 0; sent < total; sent += chunkSize {
+			switch err := writer.Write(chunk); err {
+			case nil:
+				accepted += chunkSize
+			case ErrDataChannelBufferFull:
+				dropped += chunkSize
+			default:
+				require.NoError(t, err)
+			}
+			if buffered := dc.BufferedAmount(); buffered > maxSeen {
+				maxSeen = buffered
+			}
+		}
+		require.Less(t, time.Since(startedAt), time.Second)
+		require.NotZero(t, dropped)
+		require.LessOrEqual(t, maxSeen, uint64(maxBuffered))
+
+		// once drained, messages get through again
+		require.Eventually(t, func() bool {
+			return dc.BufferedAmount() == 0
+		}, 10*time.Second, 10*time.Millisecond)
+		before := received.Load()
+		require.LessOrEqual(t, before, accepted)
+		require.NoError(t, writer.Write(chunk))
+		require.Eventually(t, func() bool {
+			return received.Load() == before+chunkSize
+		}, 10*time.Second, 10*time.Millisecond)
+
+		writer.Close()
+		require.ErrorIs(t, writer.Write(chunk), ErrDataChannelUnavailable)
+	})
+
+	t.Run("reliable messages fail the writer", func(t *testing.T) {
+		dc, received, closeTransports := newTestDataChannel(t)
+		defer closeTransports()
+
+		failed := atomic.NewInt32(0)
+		writer := newDataChannelWriter(dc, sctpConf, false, logger.Logger(logger.GetLogger()), func() { failed.Inc() })
+
+		// the messages queued until the queue is full are sent, the one that didn't fit fails the writer rather than
+		// being dropped
+		var accepted int64
+		startedAt := time.Now()
+		for {
+			err := writer.Write(chunk)
+			if err != nil {
+				require.ErrorIs(t, err, ErrDataChannelBufferFull)
+				break
+			}
+			accepted += chunkSize
+			require.Less(t, accepted, int64(total))
+		}
+		require.Less(t, time.Since(startedAt), time.Second)
+		require.Equal(t, int32(1), failed.Load())
+		require.ErrorIs(t, writer.Write(chunk), ErrDataChannelUnavailable)
+		require.Equal(t, int32(1), failed.Load())
+		require.LessOrEqual(t, received.Load(), accepted)
+	})
+}
+
+func TestDataChannelWriterTimeout(t *testing.T) {
+	dc, _, closeTransports := newTestDataChannel(t)
+	defer closeTransports()
+
+	failed := atomic.NewBool(false)
+	writer := newDataChannelWriter(dc, config.SCTPConfig{
+		MaxBufferedAmount: 1,
+		WriteTimeout:      config.Duration(time.Millisecond),
+	}, false, logger.Logger(logger.GetLogger()), func() { failed.Store(true) })
+
+	// reliable messages that can't be sent in time fail the writer rather than being dropped
+	chunk := make([]byte, 16*1024)
+	require.Eventually(t, func() bool {
+		_ = writer.Write(chunk)
+		return failed.Load()
+	}, 5*time.Second, time.Millisecond)
+	require.ErrorIs(t, writer.Write(chunk), ErrDataChannelUnavailable)
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"github.com/pion/webrtc/v3"
@@ -173,9 +174,17 @@ func (t *DataTrack) GetBitrateTemporalCumulative() sfu.Bitrates {
 	return sfu.Bitrates{}
 }
 
+func (t *DataTrack) MaxSpatialLayer() int32 {
+	return sfu.InvalidLayerSpatial
+}
+
 func (t *DataTrack) SendPLI(layer int32) {
 }
 
+func (t *DataTrack) GetCachedKeyFrame(layer int32) []*buffer.ExtPacket {
+	return nil
+}
+
 func (t *DataTrack) SetUpTrackPaused(paused bool) {
 
 }
@@ -0,0 +1,73 @@
+package rtc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// validity of the generated certificate. pion's own certificates expire after a month,
+// which would be too short for a long-running server. Peers only check the fingerprint
+const dtlsCertificateValidity = 365 * 24 * time.Hour
+
+// newDTLSCertificate returns the certificate shared by all peer connections of the process,
+// or nil when every connection should generate its own
+func newDTLSCertificate(conf config.RTCConfig) (*webrtc.Certificate, error) {
+	if conf.DTLSPerConnectionCert {
+		return nil, nil
+	}
+	if conf.DTLSCertFile != "" {
+		return loadDTLSCertificate(conf.DTLSCertFile, conf.DTLSKeyFile)
+	}
+	return generateDTLSCertificate()
+}
+
+func loadDTLSCertificate(certFile, keyFile string) (*webrtc.Certificate, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load DTLS certificate: %v", err)
+	}
+	if len(pair.Certificate) == 0 {
+		return nil, errors.New("could not load DTLS certificate: no certificate found")
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("could not load DTLS certificate: %v", err)
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, fmt.Errorf("DTLS certificate %s expired at %s", certFile, cert.NotAfter)
+	}
+	certificate := webrtc.CertificateFromX509(pair.PrivateKey, cert)
+	return &certificate, nil
+}
+
+func generateDTLSCertificate() (*webrtc.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return webrtc.NewCertificate(key, x509.Certificate{
+		Issuer:       pkix.Name{CommonName: "livekit"},
+		Subject:      pkix.Name{CommonName: "livekit"},
+		NotBefore:    now.Add(-24 * time.Hour),
+		NotAfter:     now.Add(dtlsCertificateValidity),
+		SerialNumber: serialNumber,
+		Version:      2,
+	})
+}
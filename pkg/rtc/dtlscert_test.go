@@ -0,0 +1,152 @@
+package rtc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestDTLSCertificate(t *testing.T) {
+	fingerprint := func(t *testing.T, transport *PCTransport) string {
+		certs := transport.pc.GetConfiguration().Certificates
+		require.Len(t, certs, 1)
+		fingerprints, err := certs[0].GetFingerprints()
+		require.NoError(t, err)
+		return fingerprints[0].Value
+	}
+	newTransports := func(t *testing.T, conf *config.Config) (*PCTransport, *PCTransport) {
+		// avoid listening on the TCP mux port
+		conf.RTC.TCPPort = 0
+		rtcConf, err := NewWebRTCConfig(conf, "")
+		require.NoError(t, err)
+		publisher, err := NewPCTransport(TransportParams{
+			ParticipantID:       "id",
+			ParticipantIdentity: "identity",
+			Target:              livekit.SignalTarget_PUBLISHER,
+			Config:              rtcConf,
+		})
+		require.NoError(t, err)
+		subscriber, err := NewPCTransport(TransportParams{
+			ParticipantID:       "id",
+			ParticipantIdentity: "identity",
+			Target:              livekit.SignalTarget_SUBSCRIBER,
+			Config:              rtcConf,
+		})
+		require.NoError(t, err)
+		return publisher, subscriber
+	}
+
+	t.Run("generated certificate is shared", func(t *testing.T) {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		publisher, subscriber := newTransports(t, conf)
+		defer publisher.Close()
+		defer subscriber.Close()
+
+		require.Equal(t, fingerprint(t, publisher), fingerprint(t, subscriber))
+		require.True(t, publisher.pc.GetConfiguration().Certificates[0].Expires().After(time.Now().AddDate(0, 6, 0)))
+	})
+
+	t.Run("certificate loaded from files", func(t *testing.T) {
+		certFile, keyFile := writeDTLSCertificate(t)
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		conf.RTC.DTLSCertFile = certFile
+		conf.RTC.DTLSKeyFile = keyFile
+		publisher, subscriber := newTransports(t, conf)
+		defer publisher.Close()
+		defer subscriber.Close()
+
+		loaded, err := loadDTLSCertificate(certFile, keyFile)
+		require.NoError(t, err)
+		expected, err := loaded.GetFingerprints()
+		require.NoError(t, err)
+		require.Equal(t, expected[0].Value, fingerprint(t, publisher))
+		require.Equal(t, expected[0].Value, fingerprint(t, subscriber))
+	})
+
+	t.Run("per connection certificates", func(t *testing.T) {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		conf.RTC.DTLSPerConnectionCert = true
+		publisher, subscriber := newTransports(t, conf)
+		defer publisher.Close()
+		defer subscriber.Close()
+
+		require.NotEqual(t, fingerprint(t, publisher), fingerprint(t, subscriber))
+	})
+
+	t.Run("missing files", func(t *testing.T) {
+		_, err := newDTLSCertificate(config.RTCConfig{
+			DTLSCertFile: filepath.Join(t.TempDir(), "missing.pem"),
+			DTLSKeyFile:  filepath.Join(t.TempDir(), "missing-key.pem"),
+		})
+		require.Error(t, err)
+	})
+}
+
+func BenchmarkNewPCTransport(b *testing.B) {
+	for _, perConnection := range []bool{false, true} {
+		name := "shared"
+		if perConnection {
+			name = "per_connection"
+		}
+		b.Run(name, func(b *testing.B) {
+			conf, err := config.NewConfig("", nil)
+			require.NoError(b, err)
+			conf.RTC.TCPPort = 0
+			conf.RTC.DTLSPerConnectionCert = perConnection
+			rtcConf, err := NewWebRTCConfig(conf, "")
+			require.NoError(b, err)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				transport, err := NewPCTransport(TransportParams{
+					ParticipantID:       "id",
+					ParticipantIdentity: "identity",
+					Target:              livekit.SignalTarget_PUBLISHER,
+					Config:              rtcConf,
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				transport.Close()
+			}
+		})
+	}
+}
+
+func writeDTLSCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
@@ -3,12 +3,20 @@ package rtc
 import "errors"
 
 var (
-	ErrRoomClosed              = errors.New("room has already closed")
-	ErrPermissionDenied        = errors.New("no permissions to access the room")
-	ErrMaxParticipantsExceeded = errors.New("room has exceeded its max participants")
-	ErrLimitExceeded           = errors.New("node has exceeded its configured limit")
-	ErrAlreadyJoined           = errors.New("a participant with the same identity is already in the room")
-	ErrUnexpectedOffer         = errors.New("expected answer SDP, received offer")
-	ErrDataChannelUnavailable  = errors.New("data channel is not available")
-	ErrCannotSubscribe         = errors.New("participant does not have permission to subscribe")
+	ErrRoomClosed               = errors.New("room has already closed")
+	ErrPermissionDenied         = errors.New("no permissions to access the room")
+	ErrMaxParticipantsExceeded  = errors.New("room has exceeded its max participants")
+	ErrLimitExceeded            = errors.New("node has exceeded its configured limit")
+	ErrAlreadyJoined            = errors.New("a participant with the same identity is already in the room")
+	ErrUnexpectedOffer          = errors.New("expected answer SDP, received offer")
+	ErrStaleAnswer              = errors.New("received answer SDP without an outstanding offer")
+	ErrTooManyPendingCandidates = errors.New("too many ICE candidates queued before remote description")
+	ErrNegotiationTimeout       = errors.New("client did not answer the offer in time")
+	ErrTransportClosed          = errors.New("transport has been closed")
+	ErrDataChannelUnavailable   = errors.New("data channel is not available")
+	ErrDataChannelBufferFull    = errors.New("data channel send buffer is full")
+	ErrDataMessageTooLarge      = errors.New("data message exceeds the maximum message size")
+	ErrCannotSubscribe          = errors.New("participant does not have permission to subscribe")
+
+	ErrUnsupportedLocalDescriptionMunge = errors.New("munged local description changes what pion negotiated")
 )
@@ -0,0 +1,78 @@
+package rtc
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/livekit-server/pkg/telemetry"
+)
+
+// ICECandidateInfo describes one side of the selected ICE candidate pair
+type ICECandidateInfo struct {
+	// host, srflx, prflx or relay
+	Type string `json:"type"`
+	// udp or tcp
+	Protocol string `json:"protocol"`
+	// address with port
+	Address string `json:"address"`
+}
+
+// ICEConnectionInfo is a snapshot of a PCTransport's connectivity. Local and Remote are nil until a
+// candidate pair has been selected
+type ICEConnectionInfo struct {
+	Local         *ICECandidateInfo `json:"local,omitempty"`
+	Remote        *ICECandidateInfo `json:"remote,omitempty"`
+	ICEState      string            `json:"iceState"`
+	DTLSState     string            `json:"dtlsState"`
+	BytesSent     uint64            `json:"bytesSent"`
+	BytesReceived uint64            `json:"bytesReceived"`
+}
+
+// GetICEConnectionInfo returns the selected candidate pair, connection states and transport byte counters
+func (t *PCTransport) GetICEConnectionInfo() *ICEConnectionInfo {
+	info := &ICEConnectionInfo{
+		ICEState:  t.pc.ICEConnectionState().String(),
+		DTLSState: webrtc.DTLSTransportStateNew.String(),
+	}
+
+	if sctp := t.pc.SCTP(); sctp != nil && sctp.Transport() != nil {
+		dtls := sctp.Transport()
+		info.DTLSState = dtls.State().String()
+		if ice := dtls.ICETransport(); ice != nil {
+			if pair, err := ice.GetSelectedCandidatePair(); err == nil && pair != nil {
+				info.Local = newICECandidateInfo(pair.Local)
+				info.Remote = newICECandidateInfo(pair.Remote)
+			}
+		}
+	}
+
+	if stats, ok := t.pc.GetStats()["iceTransport"].(webrtc.TransportStats); ok {
+		info.BytesSent = stats.BytesSent
+		info.BytesReceived = stats.BytesReceived
+	}
+	return info
+}
+
+func newICECandidateInfo(c *webrtc.ICECandidate) *ICECandidateInfo {
+	if c == nil {
+		return nil
+	}
+	return &ICECandidateInfo{
+		Type:     c.Typ.String(),
+		Protocol: c.Protocol.String(),
+		Address:  net.JoinHostPort(c.Address, strconv.Itoa(int(c.Port))),
+	}
+}
+
+func (c *ICECandidateInfo) toTelemetry() *telemetry.ICECandidate {
+	if c == nil {
+		return nil
+	}
+	return &telemetry.ICECandidate{
+		Type:     c.Type,
+		Protocol: c.Protocol,
+		Address:  c.Address,
+	}
+}
@@ -0,0 +1,64 @@
+package rtc
+
+import (
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/nack"
+	"github.com/pion/interceptor/pkg/report"
+	"github.com/pion/interceptor/pkg/twcc"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// registerInterceptors adds the configured RTCP interceptors to the registry, intervals left at zero keep the
+// pion defaults
+func registerInterceptors(ir *interceptor.Registry, conf config.DirectionInterceptorsConfig) error {
+	if conf.ReceiverReports.Enabled {
+		var opts []report.ReceiverOption
+		if conf.ReceiverReports.Interval > 0 {
+			opts = append(opts, report.ReceiverInterval(conf.ReceiverReports.Interval.Duration()))
+		}
+		f, err := report.NewReceiverInterceptor(opts...)
+		if err != nil {
+			return err
+		}
+		ir.Add(f)
+	}
+
+	if conf.SenderReports.Enabled {
+		var opts []report.SenderOption
+		if conf.SenderReports.Interval > 0 {
+			opts = append(opts, report.SenderInterval(conf.SenderReports.Interval.Duration()))
+		}
+		f, err := report.NewSenderInterceptor(opts...)
+		if err != nil {
+			return err
+		}
+		ir.Add(f)
+	}
+
+	if conf.TWCCFeedback.Enabled {
+		var opts []twcc.Option
+		if conf.TWCCFeedback.Interval > 0 {
+			opts = append(opts, twcc.SendInterval(conf.TWCCFeedback.Interval.Duration()))
+		}
+		f, err := twcc.NewSenderInterceptor(opts...)
+		if err != nil {
+			return err
+		}
+		ir.Add(f)
+	}
+
+	if conf.NACKGenerator.Enabled {
+		var opts []nack.GeneratorOption
+		if conf.NACKGenerator.Interval > 0 {
+			opts = append(opts, nack.GeneratorInterval(conf.NACKGenerator.Interval.Duration()))
+		}
+		f, err := nack.NewGeneratorInterceptor(opts...)
+		if err != nil {
+			return err
+		}
+		ir.Add(f)
+	}
+
+	return nil
+}
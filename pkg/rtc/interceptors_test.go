@@ -0,0 +1,100 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/testutils"
+)
+
+func TestSenderReportInterceptor(t *testing.T) {
+	// counts the sender reports received over a connected transport pair
+	countSenderReports := func(t *testing.T, interceptors config.DirectionInterceptorsConfig, window time.Duration) int {
+		sender, err := NewPCTransport(TransportParams{
+			ParticipantID:       "sub",
+			ParticipantIdentity: "sub",
+			Target:              livekit.SignalTarget_SUBSCRIBER,
+			Config:              &WebRTCConfig{Subscriber: DirectionConfig{Interceptors: interceptors}},
+			EnabledCodecs:       []*livekit.Codec{{Mime: webrtc.MimeTypeVP8}},
+		})
+		require.NoError(t, err)
+		defer sender.Close()
+		receiver, err := NewPCTransport(TransportParams{
+			ParticipantID:       "pub",
+			ParticipantIdentity: "pub",
+			Target:              livekit.SignalTarget_PUBLISHER,
+			Config:              &WebRTCConfig{},
+			EnabledCodecs:       []*livekit.Codec{{Mime: webrtc.MimeTypeVP8}},
+		})
+		require.NoError(t, err)
+		defer receiver.Close()
+
+		track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, "video", "stream")
+		require.NoError(t, err)
+		_, _, err = sender.GetTransceiverForSending(track)
+		require.NoError(t, err)
+
+		var reports atomic.Int32
+		receiver.pc.OnTrack(func(_ *webrtc.TrackRemote, r *webrtc.RTPReceiver) {
+			for {
+				pkts, _, err := r.ReadRTCP()
+				if err != nil {
+					return
+				}
+				for _, pkt := range pkts {
+					if _, ok := pkt.(*rtcp.SenderReport); ok {
+						reports.Inc()
+					}
+				}
+			}
+		})
+
+		handleICEExchange(t, sender, receiver)
+		sender.OnOffer(handleOfferFunc(t, sender, receiver))
+		require.NoError(t, sender.CreateAndSendOffer(nil))
+
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(20 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					_ = track.WriteSample(media.Sample{Data: []byte{0x10, 0x02, 0x00, 0x9d}, Duration: 20 * time.Millisecond})
+				}
+			}
+		}()
+
+		testutils.WithTimeout(t, func() string {
+			if sender.pc.ConnectionState() != webrtc.PeerConnectionStateConnected {
+				return "transports did not connect"
+			}
+			return ""
+		})
+		time.Sleep(window)
+		return int(reports.Load())
+	}
+
+	t.Run("not registered by default", func(t *testing.T) {
+		require.Zero(t, countSenderReports(t, config.DirectionInterceptorsConfig{}, 300*time.Millisecond))
+	})
+
+	t.Run("configured interval", func(t *testing.T) {
+		reports := countSenderReports(t, config.DirectionInterceptorsConfig{
+			SenderReports: config.IntervalInterceptorConfig{Enabled: true, Interval: config.Duration(50 * time.Millisecond)},
+		}, 500*time.Millisecond)
+		// the pion default would send at most one in the window
+		require.GreaterOrEqual(t, reports, 4)
+	})
+}
@@ -0,0 +1,143 @@
+package rtc
+
+import (
+	"net"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// interfaceFilter decides which interfaces are used when gathering ICE candidates.
+// pion doesn't support filtering individual IPs at this version, so IP rules are applied per interface:
+// an interface is used when at least one of its IPv4 addresses passes the IP rules. Config validation rejects IP rules
+// that would leave an interface in use with addresses they don't allow, and such interfaces are logged when used
+type interfaceFilter struct {
+	includeInterfaces []string
+	excludeInterfaces []string
+	includeIPs        []*net.IPNet
+	excludeIPs        []*net.IPNet
+
+	// returns addresses for an interface, replaceable for tests
+	interfaceAddrs func(name string) ([]net.IP, error)
+
+	logged sync.Map
+}
+
+// newInterfaceFilter returns nil when no filtering is configured
+func newInterfaceFilter(interfaces config.InterfacesConfig, ips config.IPsConfig) (*interfaceFilter, error) {
+	if len(interfaces.Include) == 0 && len(interfaces.Exclude) == 0 && len(ips.Include) == 0 && len(ips.Exclude) == 0 {
+		return nil, nil
+	}
+
+	f := &interfaceFilter{
+		includeInterfaces: interfaces.Include,
+		excludeInterfaces: interfaces.Exclude,
+		interfaceAddrs:    getInterfaceAddrs,
+	}
+	var err error
+	if f.includeIPs, err = parseCIDRs(ips.Include); err != nil {
+		return nil, err
+	}
+	if f.excludeIPs, err = parseCIDRs(ips.Exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *interfaceFilter) Allow(name string) bool {
+	allowed, reason := f.check(name)
+	if _, loaded := f.logged.LoadOrStore(name, allowed); !loaded {
+		if allowed && reason != "" {
+			logger.Warnw("using interface for ICE candidates, with addresses not allowed by rtc.ips", nil,
+				"interface", name, "addresses", reason)
+		} else if allowed {
+			logger.Debugw("using interface for ICE candidates", "interface", name)
+		} else {
+			logger.Debugw("skipping interface for ICE candidates", "interface", name, "reason", reason)
+		}
+	}
+	return allowed
+}
+
+func (f *interfaceFilter) check(name string) (bool, string) {
+	if len(f.includeInterfaces) > 0 && !matchAny(f.includeInterfaces, name) {
+		return false, "not in rtc.interfaces.include"
+	}
+	if matchAny(f.excludeInterfaces, name) {
+		return false, "in rtc.interfaces.exclude"
+	}
+	if len(f.includeIPs) == 0 && len(f.excludeIPs) == 0 {
+		return true, ""
+	}
+
+	ips, err := f.interfaceAddrs(name)
+	if err != nil {
+		return false, err.Error()
+	}
+	allowed := false
+	var disallowed []string
+	for _, ip := range ips {
+		if len(f.includeIPs) > 0 && !containsIP(f.includeIPs, ip) || containsIP(f.excludeIPs, ip) {
+			disallowed = append(disallowed, ip.String())
+			continue
+		}
+		allowed = true
+	}
+	if !allowed {
+		return false, "no address allowed by rtc.ips"
+	}
+	// the interface is used as a whole, so are these
+	return true, strings.Join(disallowed, ", ")
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func getInterfaceAddrs(name string) ([]net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips, nil
+}
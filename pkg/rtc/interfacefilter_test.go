@@ -0,0 +1,63 @@
+package rtc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestInterfaceFilter(t *testing.T) {
+	t.Run("no filter when not configured", func(t *testing.T) {
+		f, err := newInterfaceFilter(config.InterfacesConfig{}, config.IPsConfig{})
+		require.NoError(t, err)
+		require.Nil(t, f)
+	})
+
+	t.Run("interface globs", func(t *testing.T) {
+		f, err := newInterfaceFilter(config.InterfacesConfig{
+			Include: []string{"eth*", "en*"},
+			Exclude: []string{"eth1"},
+		}, config.IPsConfig{})
+		require.NoError(t, err)
+		require.True(t, f.Allow("eth0"))
+		require.True(t, f.Allow("en0"))
+		require.False(t, f.Allow("eth1"))
+		require.False(t, f.Allow("docker0"))
+	})
+
+	t.Run("ip ranges", func(t *testing.T) {
+		f, err := newInterfaceFilter(config.InterfacesConfig{}, config.IPsConfig{
+			Include: []string{"10.0.0.0/8"},
+			Exclude: []string{"10.1.0.0/16"},
+		})
+		require.NoError(t, err)
+		f.interfaceAddrs = func(name string) ([]net.IP, error) {
+			switch name {
+			case "eth0":
+				return []net.IP{net.ParseIP("10.0.0.5")}, nil
+			case "tun0":
+				return []net.IP{net.ParseIP("10.1.0.5")}, nil
+			case "eth1":
+				return []net.IP{net.ParseIP("10.1.0.6"), net.ParseIP("10.2.0.6")}, nil
+			default:
+				return []net.IP{net.ParseIP("172.17.0.1")}, nil
+			}
+		}
+		require.True(t, f.Allow("eth0"))
+		require.False(t, f.Allow("tun0"))
+		require.False(t, f.Allow("docker0"))
+
+		// used as a whole, along with the address that isn't allowed
+		allowed, disallowed := f.check("eth1")
+		require.True(t, allowed)
+		require.Equal(t, "10.1.0.6", disallowed)
+	})
+
+	t.Run("invalid CIDR", func(t *testing.T) {
+		_, err := newInterfaceFilter(config.InterfacesConfig{}, config.IPsConfig{Exclude: []string{"10.0.0.0"}})
+		require.Error(t, err)
+	})
+}
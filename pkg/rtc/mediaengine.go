@@ -1,14 +1,30 @@
 package rtc
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu"
 )
 
-func registerCodecs(me *webrtc.MediaEngine, codecs []*livekit.Codec, rtcpFeedback RTCPFeedbackConfig) error {
+// payload types of the retransmission streams of the built-in video codecs
+var rtxPayloadTypes = map[webrtc.PayloadType]webrtc.PayloadType{
+	96:  97,
+	98:  99,
+	100: 101,
+	35:  36,
+	125: 107,
+	108: 109,
+	123: 122,
+}
+
+func registerCodecs(me *webrtc.MediaEngine, codecs []*livekit.Codec, rtcpFeedback RTCPFeedbackConfig, enableRED bool, enableRTX bool) error {
 	opusCodec := webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2, SDPFmtpLine: "minptime=10;useinbandfec=1", RTCPFeedback: rtcpFeedback.Audio}
+	var registered []webrtc.RTPCodecCapability
 	if isCodecEnabled(codecs, opusCodec) {
 		if err := me.RegisterCodec(webrtc.RTPCodecParameters{
 			RTPCodecCapability: opusCodec,
@@ -16,6 +32,17 @@ func registerCodecs(me *webrtc.MediaEngine, codecs []*livekit.Codec, rtcpFeedbac
 		}, webrtc.RTPCodecTypeAudio); err != nil {
 			return err
 		}
+		registered = append(registered, opusCodec)
+
+		if enableRED {
+			// redundant opus encodings, the fmtp lists the payload type of each block
+			if err := me.RegisterCodec(webrtc.RTPCodecParameters{
+				RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: sfu.MimeTypeAudioRED, ClockRate: 48000, Channels: 2, SDPFmtpLine: "111/111"},
+				PayloadType:        63,
+			}, webrtc.RTPCodecTypeAudio); err != nil {
+				return err
+			}
+		}
 	}
 
 	for _, codec := range []webrtc.RTPCodecParameters{
@@ -31,6 +58,10 @@ func registerCodecs(me *webrtc.MediaEngine, codecs []*livekit.Codec, rtcpFeedbac
 			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP9, ClockRate: 90000, SDPFmtpLine: "profile-id=1", RTCPFeedback: rtcpFeedback.Video},
 			PayloadType:        100,
 		},
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeAV1, ClockRate: 90000, RTCPFeedback: rtcpFeedback.Video},
+			PayloadType:        35,
+		},
 		{
 			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000, SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f", RTCPFeedback: rtcpFeedback.Video},
 			PayloadType:        125,
@@ -48,11 +79,75 @@ func registerCodecs(me *webrtc.MediaEngine, codecs []*livekit.Codec, rtcpFeedbac
 			if err := me.RegisterCodec(codec, webrtc.RTPCodecTypeVideo); err != nil {
 				return err
 			}
+			if enableRTX {
+				if err := registerRTX(me, codec.PayloadType, rtxPayloadTypes[codec.PayloadType]); err != nil {
+					return err
+				}
+			}
+			registered = append(registered, codec.RTPCodecCapability)
+		}
+	}
+
+	// a codec configured with a profile none of the built-in ones offer is registered with its own fmtp line
+	customPayloadTypes := []webrtc.PayloadType{102, 104, 106, 110, 112, 114, 116, 118}
+	customRTXPayloadTypes := []webrtc.PayloadType{37, 38, 39, 40, 41, 42, 43, 44}
+	for _, codec := range codecs {
+		if codec.FmtpLine == "" || isCodecRegistered(registered, codec) {
+			continue
+		}
+		capability, codecType, ok := codecCapabilityForMime(codec.Mime, rtcpFeedback)
+		if !ok {
+			continue
+		}
+		if len(customPayloadTypes) == 0 {
+			return fmt.Errorf("no payload type left for codec %s with fmtp %s", codec.Mime, codec.FmtpLine)
+		}
+		capability.SDPFmtpLine = codec.FmtpLine
+		if err := me.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: capability,
+			PayloadType:        customPayloadTypes[0],
+		}, codecType); err != nil {
+			return err
+		}
+		if enableRTX && codecType == webrtc.RTPCodecTypeVideo {
+			if err := registerRTX(me, customPayloadTypes[0], customRTXPayloadTypes[0]); err != nil {
+				return err
+			}
 		}
+		customPayloadTypes = customPayloadTypes[1:]
+		customRTXPayloadTypes = customRTXPayloadTypes[1:]
+		registered = append(registered, capability)
 	}
 	return nil
 }
 
+// registers the retransmission stream (RFC 4588) of a video codec
+func registerRTX(me *webrtc.MediaEngine, payloadType webrtc.PayloadType, rtxPayloadType webrtc.PayloadType) error {
+	return me.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: sfu.MimeTypeRTX, ClockRate: 90000, SDPFmtpLine: fmt.Sprintf("apt=%d", payloadType)},
+		PayloadType:        rtxPayloadType,
+	}, webrtc.RTPCodecTypeVideo)
+}
+
+func codecCapabilityForMime(mime string, rtcpFeedback RTCPFeedbackConfig) (webrtc.RTPCodecCapability, webrtc.RTPCodecType, bool) {
+	switch strings.ToLower(mime) {
+	case strings.ToLower(webrtc.MimeTypeOpus):
+		return webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2, RTCPFeedback: rtcpFeedback.Audio}, webrtc.RTPCodecTypeAudio, true
+	case strings.ToLower(webrtc.MimeTypeVP8), strings.ToLower(webrtc.MimeTypeVP9), strings.ToLower(webrtc.MimeTypeH264), strings.ToLower(webrtc.MimeTypeAV1):
+		return webrtc.RTPCodecCapability{MimeType: mime, ClockRate: 90000, RTCPFeedback: rtcpFeedback.Video}, webrtc.RTPCodecTypeVideo, true
+	}
+	return webrtc.RTPCodecCapability{}, 0, false
+}
+
+func isCodecRegistered(registered []webrtc.RTPCodecCapability, codec *livekit.Codec) bool {
+	for _, capability := range registered {
+		if strings.EqualFold(codec.Mime, capability.MimeType) && fmtpMatches(codec.FmtpLine, capability.SDPFmtpLine) {
+			return true
+		}
+	}
+	return false
+}
+
 func registerHeaderExtensions(me *webrtc.MediaEngine, rtpHeaderExtension RTPHeaderExtensionConfig) error {
 	for _, extension := range rtpHeaderExtension.Video {
 		if err := me.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: extension}, webrtc.RTPCodecTypeVideo); err != nil {
@@ -71,7 +166,7 @@ func registerHeaderExtensions(me *webrtc.MediaEngine, rtpHeaderExtension RTPHead
 
 func createMediaEngine(codecs []*livekit.Codec, config DirectionConfig) (*webrtc.MediaEngine, error) {
 	me := &webrtc.MediaEngine{}
-	if err := registerCodecs(me, codecs, config.RTCPFeedback); err != nil {
+	if err := registerCodecs(me, codecs, config.RTCPFeedback, config.EnableRED, config.EnableRTX); err != nil {
 		return nil, err
 	}
 
@@ -87,9 +182,81 @@ func isCodecEnabled(codecs []*livekit.Codec, cap webrtc.RTPCodecCapability) bool
 		if !strings.EqualFold(codec.Mime, cap.MimeType) {
 			continue
 		}
-		if codec.FmtpLine == "" || strings.EqualFold(codec.FmtpLine, cap.SDPFmtpLine) {
+		if fmtpMatches(codec.FmtpLine, cap.SDPFmtpLine) {
 			return true
 		}
 	}
 	return false
 }
+
+// fmtpMatches reports whether an fmtp line satisfies a configured one, every configured parameter has to be present
+// with the same value
+func fmtpMatches(configured, fmtp string) bool {
+	params := parseFmtp(fmtp)
+	for key, value := range parseFmtp(configured) {
+		if v, ok := params[key]; !ok || !strings.EqualFold(v, value) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseFmtp(line string) map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(line, ";") {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		kv := strings.SplitN(param, "=", 2)
+		value := ""
+		if len(kv) == 2 {
+			value = strings.TrimSpace(kv[1])
+		}
+		params[strings.ToLower(strings.TrimSpace(kv[0]))] = value
+	}
+	return params
+}
+
+// negotiatedOpusFmtp returns the opus fmtp line to answer a publisher with, and to offer its subscribers. Stereo is
+// kept only when allowed, and DTX is requested when allowed and enabled on the track
+func negotiatedOpusFmtp(fmtp string, conf config.AudioConfig, enableDTX bool) string {
+	fmtp = removeFmtpParameters(fmtp, "usedtx")
+	if !conf.AllowStereo {
+		fmtp = removeFmtpParameters(fmtp, "stereo", "sprop-stereo")
+	}
+	if conf.AllowDTX && enableDTX {
+		fmtp = appendFmtpParameter(fmtp, "usedtx=1")
+	}
+	return fmtp
+}
+
+// removeFmtpParameters drops the given parameters from an fmtp line, keeping the order of the others
+func removeFmtpParameters(line string, keys ...string) string {
+	var kept []string
+	for _, param := range strings.Split(line, ";") {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(strings.SplitN(param, "=", 2)[0]))
+		remove := false
+		for _, k := range keys {
+			if key == k {
+				remove = true
+				break
+			}
+		}
+		if !remove {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, ";")
+}
+
+func appendFmtpParameter(line string, param string) string {
+	if line == "" {
+		return param
+	}
+	return line + ";" + param
+}
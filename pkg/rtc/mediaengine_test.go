@@ -6,6 +6,8 @@ import (
 	"github.com/livekit/protocol/livekit"
 	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
 )
 
 func TestIsCodecEnabled(t *testing.T) {
@@ -22,4 +24,64 @@ func TestIsCodecEnabled(t *testing.T) {
 		require.False(t, isCodecEnabled(enabledCodecs, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264}))
 		require.False(t, isCodecEnabled(enabledCodecs, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}))
 	})
+	t.Run("configured fmtp parameters must all match", func(t *testing.T) {
+		enabledCodecs := []*livekit.Codec{{Mime: "video/h264", FmtpLine: "packetization-mode=1;profile-level-id=42e01f"}}
+		require.True(t, isCodecEnabled(enabledCodecs, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f"}))
+		require.False(t, isCodecEnabled(enabledCodecs, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42e01f"}))
+		require.False(t, isCodecEnabled(enabledCodecs, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, SDPFmtpLine: "packetization-mode=1;profile-level-id=640032"}))
+	})
+}
+
+func TestRegisterCodecs(t *testing.T) {
+	me, err := createMediaEngine([]*livekit.Codec{
+		{Mime: webrtc.MimeTypeVP8},
+		{Mime: webrtc.MimeTypeH264, FmtpLine: "packetization-mode=1;profile-level-id=4d001f"},
+	}, DirectionConfig{})
+	require.NoError(t, err)
+
+	pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer pc.Close()
+	_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo)
+	require.NoError(t, err)
+	offer, err := pc.CreateOffer(nil)
+	require.NoError(t, err)
+
+	// only configured codecs, the H264 profile none of the built-in codecs offer is registered with its fmtp line
+	require.Contains(t, offer.SDP, "VP8/90000")
+	require.Contains(t, offer.SDP, "packetization-mode=1;profile-level-id=4d001f")
+	require.NotContains(t, offer.SDP, "42e01f")
+	require.NotContains(t, offer.SDP, "VP9")
+}
+
+func TestRegisterSVCCodecs(t *testing.T) {
+	me, err := createMediaEngine([]*livekit.Codec{
+		{Mime: webrtc.MimeTypeVP9},
+		{Mime: webrtc.MimeTypeAV1},
+	}, DirectionConfig{
+		RTCPFeedback: RTCPFeedbackConfig{
+			Video: []webrtc.RTCPFeedback{{Type: webrtc.TypeRTCPFBNACK}, {Type: webrtc.TypeRTCPFBNACK, Parameter: "pli"}},
+		},
+	})
+	require.NoError(t, err)
+
+	pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer pc.Close()
+	_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo)
+	require.NoError(t, err)
+	offer, err := pc.CreateOffer(nil)
+	require.NoError(t, err)
+
+	require.Contains(t, offer.SDP, "a=rtpmap:98 VP9/90000")
+	require.Contains(t, offer.SDP, "a=rtpmap:35 AV1/90000")
+	require.Contains(t, offer.SDP, "a=rtcp-fb:35 nack pli")
+	require.NotContains(t, offer.SDP, "VP8")
+}
+
+func TestNegotiatedOpusFmtp(t *testing.T) {
+	const offered = "minptime=10;stereo=1;sprop-stereo=1;useinbandfec=1"
+	require.Equal(t, offered+";usedtx=1", negotiatedOpusFmtp(offered, config.AudioConfig{AllowStereo: true, AllowDTX: true}, true))
+	require.Equal(t, offered, negotiatedOpusFmtp(offered+";usedtx=1", config.AudioConfig{AllowStereo: true, AllowDTX: true}, false))
+	require.Equal(t, "minptime=10;useinbandfec=1", negotiatedOpusFmtp("minptime=10;usedtx=1;useinbandfec=1;stereo=1", config.AudioConfig{}, true))
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/sfu/twcc"
 	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 
 	"go.uber.org/atomic"
 )
@@ -24,11 +25,16 @@ type MediaTrack struct {
 	params      MediaTrackParams
 	numUpTracks atomic.Uint32
 	buffer      *buffer.Buffer
+	// packets allocated to the buffers of this track's layers
+	packetBufferPackets atomic.Int32
 
 	layerSSRCs [livekit.VideoQuality_HIGH + 1]uint32
 
-	audioLevelMu sync.RWMutex
-	audioLevel   *AudioLevel
+	audioLevelMu  sync.RWMutex
+	audioLevel    *AudioLevel
+	audioActivity *AudioActivity
+
+	onSilenceChanged func(trackID livekit.TrackID, silent bool)
 
 	*MediaTrackReceiver
 
@@ -47,9 +53,15 @@ type MediaTrackParams struct {
 	ReceiverConfig    ReceiverConfig
 	SubscriberConfig  DirectionConfig
 	PLIThrottleConfig config.PLIThrottleConfig
+	KeyFrameCache     config.KeyFrameCacheConfig
+	StreamTracker     config.StreamTrackerConfig
+	MaxSpatialLayers  int32
 	AudioConfig       config.AudioConfig
 	Telemetry         telemetry.TelemetryService
 	Logger            logger.Logger
+
+	// sizes the buffers of the track's layers, buffers use the factory's pools when nil
+	PacketBufferAllocator *PacketBufferAllocator
 }
 
 func NewMediaTrack(params MediaTrackParams) *MediaTrack {
@@ -65,6 +77,7 @@ func NewMediaTrack(params MediaTrackParams) *MediaTrack {
 		BufferFactory:       params.BufferFactory,
 		ReceiverConfig:      params.ReceiverConfig,
 		SubscriberConfig:    params.SubscriberConfig,
+		AudioConfig:         params.AudioConfig,
 		Telemetry:           params.Telemetry,
 		Logger:              params.Logger,
 	})
@@ -122,14 +135,44 @@ func (t *MediaTrack) AddReceiver(receiver *webrtc.RTPReceiver, track *webrtc.Tra
 		return
 	}
 
+	var packetBufferSize int
+	if t.params.PacketBufferAllocator != nil {
+		packetBufferSize = t.params.PacketBufferAllocator.Allocate(t.Kind(), t.packetBufferQuality(track))
+		if packetBufferSize == 0 {
+			t.params.Logger.Warnw("packet buffer memory ceiling reached, not forwarding layer", nil,
+				"rid", track.RID(),
+				"allocatedBytes", t.params.PacketBufferAllocator.AllocatedBytes(),
+			)
+			_ = buff.Close()
+			return
+		}
+		t.packetBufferPackets.Add(int32(packetBufferSize))
+	}
+
 	if t.Kind() == livekit.TrackType_AUDIO {
 		t.audioLevelMu.Lock()
-		t.audioLevel = NewAudioLevel(t.params.AudioConfig.ActiveLevel, t.params.AudioConfig.MinPercentile, t.params.AudioConfig.UpdateInterval)
+		t.audioLevel = NewAudioLevel(t.params.AudioConfig.ActiveLevel, t.params.AudioConfig.MinPercentile, uint32(t.params.AudioConfig.UpdateInterval.Duration().Milliseconds()))
+		if silenceTimeout := t.params.AudioConfig.SilenceTimeout.Duration(); silenceTimeout > 0 {
+			t.audioActivity = NewAudioActivity(
+				t.params.AudioConfig.ActiveLevel,
+				t.params.AudioConfig.MinPercentile,
+				uint32(t.params.AudioConfig.UpdateInterval.Duration().Milliseconds()),
+				uint32(silenceTimeout.Milliseconds()),
+			)
+			t.audioActivity.OnSilenceChanged(func(silent bool) {
+				if t.onSilenceChanged != nil {
+					t.onSilenceChanged(t.ID(), silent)
+				}
+			})
+		}
 		buff.OnAudioLevel(func(level uint8, duration uint32) {
 			t.audioLevelMu.RLock()
 			defer t.audioLevelMu.RUnlock()
 
 			t.audioLevel.Observe(level, duration)
+			if t.audioActivity != nil {
+				t.audioActivity.Observe(level, duration)
+			}
 		})
 		t.audioLevelMu.Unlock()
 	} else if t.Kind() == livekit.TrackType_VIDEO {
@@ -159,25 +202,51 @@ func (t *MediaTrack) AddReceiver(receiver *webrtc.RTPReceiver, track *webrtc.Tra
 
 	t.lock.Lock()
 	if t.Receiver() == nil {
+		opts := []sfu.ReceiverOpts{
+			sfu.WithPliThrottle(pliThrottleForSource(t.params.PLIThrottleConfig, t.Source())),
+			sfu.WithLoadBalanceThreshold(20),
+			sfu.WithStreamTrackers(streamTrackerForSource(t.params.StreamTracker, t.Source())),
+			sfu.WithMaxSpatialLayers(t.params.MaxSpatialLayers),
+		}
+		if t.params.KeyFrameCache.Enabled {
+			opts = append(opts, sfu.WithKeyFrameCache(t.params.KeyFrameCache.MaxFrameSize))
+		}
 		wr := sfu.NewWebRTCReceiver(
 			receiver,
 			track,
 			t.PublisherID(),
 			t.params.Logger,
-			sfu.WithPliThrottle(t.params.PLIThrottleConfig),
-			sfu.WithLoadBalanceThreshold(20),
-			sfu.WithStreamTrackers(),
+			opts...,
 		)
 		wr.SetRTCPCh(t.params.RTCPChan)
+		updateLayerBitrateMetrics := publishedLayerBitrateMetricsUpdater()
 		wr.OnCloseHandler(func() {
+			updateLayerBitrateMetrics(nil)
+			if t.params.PacketBufferAllocator != nil {
+				t.params.PacketBufferAllocator.Release(int(t.packetBufferPackets.Swap(0)))
+			}
 			t.RemoveAllSubscribers()
 			t.MediaTrackReceiver.Close()
 			t.params.Telemetry.TrackUnpublished(context.Background(), t.PublisherID(), t.ToProto(), uint32(track.SSRC()))
 		})
-		wr.OnStatsUpdate(func(_ *sfu.WebRTCReceiver, stat *livekit.AnalyticsStat) {
+		wr.OnStatsUpdate(func(w *sfu.WebRTCReceiver, stat *livekit.AnalyticsStat) {
 			t.params.Telemetry.TrackStats(livekit.StreamType_UPSTREAM, t.PublisherID(), t.ID(), stat)
+			updateLayerBitrateMetrics(w.GetLayerBitrates())
+		})
+		wr.OnLayerFlap(func(layer int32, flap sfu.LayerFlap) {
+			prometheus.IncrementStreamTrackerLayerFlap(layer, flap.String())
+		})
+		wr.OnInvalidLayer(func(layer int32) {
+			prometheus.IncrementInvalidLayer()
+		})
+		wr.OnStreamIssue(func(layer int32, issue sfu.StreamIssue) {
+			// the first report requests a key frame, only report what that did not resolve
+			if issue.Persistent {
+				t.params.Telemetry.TrackStreamIssue(context.Background(), t.PublisherID(), t.ToProto(), layer, issue.Reason.String())
+			}
 		})
 		t.params.Telemetry.TrackPublished(context.Background(), t.PublisherID(), t.ToProto())
+		t.params.Telemetry.AddTrackQualitySource(t.PublisherID(), t.ID(), wr)
 
 		t.buffer = buff
 
@@ -201,10 +270,37 @@ func (t *MediaTrack) AddReceiver(receiver *webrtc.RTPReceiver, track *webrtc.Tra
 	}
 
 	buff.Bind(receiver.GetParameters(), track.Codec().RTPCodecCapability, buffer.Options{
-		MaxBitRate: t.params.ReceiverConfig.maxBitrate,
+		MaxBitRate:       t.params.ReceiverConfig.maxBitrate,
+		PacketBufferSize: packetBufferSize,
 	})
 }
 
+// packetBufferQuality returns the quality a layer is buffered for, simulcast layers by their rid, and a single
+// layer at the best quality published
+func (t *MediaTrack) packetBufferQuality(track *webrtc.TrackRemote) livekit.VideoQuality {
+	if track.RID() != "" {
+		return livekit.VideoQuality(sfu.RidToLayer(track.RID()))
+	}
+
+	layers := t.MediaTrackReceiver.TrackInfo().Layers
+	if len(layers) == 0 {
+		return livekit.VideoQuality_HIGH
+	}
+	quality := livekit.VideoQuality_LOW
+	for _, layer := range layers {
+		if layer.Quality > quality && layer.Quality <= livekit.VideoQuality_HIGH {
+			quality = layer.Quality
+		}
+	}
+	return quality
+}
+
+func (t *MediaTrack) DebugInfo() map[string]interface{} {
+	info := t.MediaTrackReceiver.DebugInfo()
+	info["PacketBufferBytes"] = buffer.PacketBufferBytes(int(t.packetBufferPackets.Load()))
+	return info
+}
+
 func (t *MediaTrack) TrySetSimulcastSSRC(layer uint8, ssrc uint32) {
 	if int(layer) < len(t.layerSSRCs) && t.layerSSRCs[layer] == 0 {
 		t.layerSSRCs[layer] = ssrc
@@ -221,6 +317,22 @@ func (t *MediaTrack) GetAudioLevel() (level uint8, active bool) {
 	return t.audioLevel.GetLevel()
 }
 
+// IsSilent returns true when the published audio has stayed below the active level for the silence timeout
+func (t *MediaTrack) IsSilent() bool {
+	t.audioLevelMu.RLock()
+	defer t.audioLevelMu.RUnlock()
+
+	if t.audioActivity == nil {
+		return false
+	}
+	return t.audioActivity.IsSilent()
+}
+
+// OnSilenceChanged is called when the published audio is declared silent, and when it is active again
+func (t *MediaTrack) OnSilenceChanged(f func(trackID livekit.TrackID, silent bool)) {
+	t.onSilenceChanged = f
+}
+
 func (t *MediaTrack) GetConnectionScore() float32 {
 	receiver := t.Receiver()
 	if receiver == nil {
@@ -238,3 +350,36 @@ func (t *MediaTrack) SetRTT(rtt uint32) {
 
 	receiver.(*sfu.WebRTCReceiver).SetRTT(rtt)
 }
+
+// pliThrottleForSource applies the throttle override configured for the source of a published track
+func pliThrottleForSource(conf config.PLIThrottleConfig, source livekit.TrackSource) config.PLIThrottleConfig {
+	switch source {
+	case livekit.TrackSource_CAMERA:
+		return conf.WithOverride(conf.Camera)
+	case livekit.TrackSource_SCREEN_SHARE:
+		return conf.WithOverride(conf.ScreenShare)
+	default:
+		return conf.WithOverride(config.PLIThrottleOverride{})
+	}
+}
+
+// streamTrackerForSource applies the stream tracker override configured for the source of a published track
+func streamTrackerForSource(conf config.StreamTrackerConfig, source livekit.TrackSource) config.StreamTrackerConfig {
+	if source == livekit.TrackSource_SCREEN_SHARE {
+		return conf.WithOverride(conf.ScreenShare)
+	}
+	return conf.WithOverride(config.StreamTrackerOverride{})
+}
+
+// publishedLayerBitrateMetricsUpdater returns a callback that tracks a published track's contribution to node wide gauges
+func publishedLayerBitrateMetricsUpdater() func(bitrates []uint64) {
+	var lock sync.Mutex
+	var prev []uint64
+	return func(bitrates []uint64) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		prometheus.UpdatePublishedLayerBitrates(prev, bitrates)
+		prev = bitrates
+	}
+}
@@ -2,9 +2,12 @@ package rtc
 
 import (
 	"testing"
+	"time"
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
 )
 
 func TestTrackInfo(t *testing.T) {
@@ -259,3 +262,31 @@ func TestSubscribedMaxQuality(t *testing.T) {
 		require.EqualValues(t, expectedSubscribedQualities, actualSubscribedQualities)
 	})
 }
+
+func TestPLIThrottleForSource(t *testing.T) {
+	conf := config.PLIThrottleConfig{
+		LowQuality:  config.Duration(500 * time.Millisecond),
+		MidQuality:  config.Duration(time.Second),
+		HighQuality: config.Duration(time.Second),
+		ScreenShare: config.PLIThrottleOverride{
+			MidQuality:  config.Duration(5 * time.Second),
+			HighQuality: config.Duration(10 * time.Second),
+		},
+	}
+
+	camera := pliThrottleForSource(conf, livekit.TrackSource_CAMERA)
+	require.Equal(t, config.PLIThrottleConfig{
+		LowQuality:  config.Duration(500 * time.Millisecond),
+		MidQuality:  config.Duration(time.Second),
+		HighQuality: config.Duration(time.Second),
+	}, camera)
+
+	screenShare := pliThrottleForSource(conf, livekit.TrackSource_SCREEN_SHARE)
+	require.Equal(t, config.PLIThrottleConfig{
+		LowQuality:  config.Duration(500 * time.Millisecond),
+		MidQuality:  config.Duration(5 * time.Second),
+		HighQuality: config.Duration(10 * time.Second),
+	}, screenShare)
+
+	require.Equal(t, camera, pliThrottleForSource(conf, livekit.TrackSource_UNKNOWN))
+}
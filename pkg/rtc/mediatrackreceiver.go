@@ -3,6 +3,7 @@ package rtc
 import (
 	"errors"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,7 +13,9 @@ import (
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/telemetry"
@@ -52,6 +55,7 @@ type MediaTrackReceiverParams struct {
 	BufferFactory       *buffer.Factory
 	ReceiverConfig      ReceiverConfig
 	SubscriberConfig    DirectionConfig
+	AudioConfig         config.AudioConfig
 	Telemetry           telemetry.TelemetryService
 	Logger              logger.Logger
 }
@@ -193,7 +197,7 @@ func (t *MediaTrackReceiver) AddSubscriber(sub types.LocalParticipant) error {
 		streamId = PackStreamID(t.PublisherID(), t.ID())
 	}
 
-	downTrack, err := t.MediaTrackSubscriptions.AddSubscriber(sub, receiver.Codec(), NewWrappedReceiver(receiver, t.ID(), streamId))
+	downTrack, err := t.MediaTrackSubscriptions.AddSubscriber(sub, t.subscriberCodec(receiver.Codec()), NewWrappedReceiver(receiver, t.ID(), streamId))
 	if err != nil {
 		return err
 	}
@@ -208,6 +212,17 @@ func (t *MediaTrackReceiver) AddSubscriber(sub types.LocalParticipant) error {
 	return nil
 }
 
+// subscriberCodec carries the opus parameters negotiated with the publisher, such as stereo and DTX, over to subscribers.
+// The publisher's track codec comes from its offer, so they're derived the same way as in the answer
+func (t *MediaTrackReceiver) subscriberCodec(codec webrtc.RTPCodecCapability) webrtc.RTPCodecCapability {
+	if !strings.EqualFold(codec.MimeType, webrtc.MimeTypeOpus) {
+		return codec
+	}
+	enableDTX := t.params.TrackInfo != nil && !t.params.TrackInfo.DisableDtx
+	codec.SDPFmtpLine = negotiatedOpusFmtp(codec.SDPFmtpLine, t.params.AudioConfig, enableDTX)
+	return codec
+}
+
 func (t *MediaTrackReceiver) UpdateTrackInfo(ti *livekit.TrackInfo) {
 	t.params.TrackInfo = ti
 	if ti != nil && t.Kind() == livekit.TrackType_VIDEO {
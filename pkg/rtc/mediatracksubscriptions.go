@@ -2,7 +2,6 @@ package rtc
 
 import (
 	"context"
-	"errors"
 	"sync"
 	"time"
 
@@ -135,6 +134,7 @@ func (t *MediaTrackSubscriptions) AddSubscriber(sub types.LocalParticipant, code
 	if err != nil {
 		return nil, err
 	}
+	downTrack.SetStrictCodecMatching(t.params.SubscriberConfig.StrictCodecMatching)
 
 	subTrack := NewSubscribedTrack(SubscribedTrackParams{
 		PublisherID:       t.params.MediaTrack.PublisherID(),
@@ -144,43 +144,14 @@ func (t *MediaTrackSubscriptions) AddSubscriber(sub types.LocalParticipant, code
 		DownTrack:         downTrack,
 	})
 
-	var transceiver *webrtc.RTPTransceiver
-	var sender *webrtc.RTPSender
-	if sub.ProtocolVersion().SupportsTransceiverReuse() {
-		//
-		// AddTrack will create a new transceiver or re-use an unused one
-		// if the attributes match. This prevents SDP from bloating
-		// because of dormant transceivers building up.
-		//
-		sender, err = sub.SubscriberPC().AddTrack(downTrack)
-		if err != nil {
-			return nil, err
-		}
-
-		// as there is no way to get transceiver from sender, search
-		for _, tr := range sub.SubscriberPC().GetTransceivers() {
-			if tr.Sender() == sender {
-				transceiver = tr
-				break
-			}
-		}
-		if transceiver == nil {
-			// cannot add, no transceiver
-			return nil, errors.New("cannot subscribe without a transceiver in place")
-		}
-	} else {
-		transceiver, err = sub.SubscriberPC().AddTransceiverFromTrack(downTrack, webrtc.RTPTransceiverInit{
-			Direction: webrtc.RTPTransceiverDirectionSendonly,
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		sender = transceiver.Sender()
-		if sender == nil {
-			// cannot add, no sender
-			return nil, errors.New("cannot subscribe without a sender in place")
-		}
+	//
+	// when the subscriber supports it, an unused transceiver is re-used before
+	// adding a new one. This prevents SDP from bloating because of dormant
+	// transceivers building up.
+	//
+	transceiver, sender, err := sub.GetSubscriberTransceiverForSending(downTrack)
+	if err != nil {
+		return nil, err
 	}
 
 	sendParameters := sender.GetParameters()
@@ -0,0 +1,75 @@
+package rtc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bep/debounce"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// newNegotiationDebouncer coalesces calls made within the configured window. With leading edge enabled, the first
+// call after an idle period runs right away and later calls in the window are coalesced into one trailing call
+func newNegotiationDebouncer(conf config.NegotiationConfig) func(func()) {
+	interval := conf.Debounce.Duration()
+	if interval <= 0 {
+		interval = defaultNegotiationDebounce
+	}
+	if !conf.LeadingEdge {
+		return debounce.New(interval)
+	}
+
+	d := &leadingEdgeDebouncer{interval: interval}
+	return d.call
+}
+
+type leadingEdgeDebouncer struct {
+	lock     sync.Mutex
+	interval time.Duration
+	timer    *time.Timer
+	timerID  uint64
+	lastCall time.Time
+	pending  func()
+}
+
+func (d *leadingEdgeDebouncer) call(f func()) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now()
+	idle := d.timer == nil && now.Sub(d.lastCall) >= d.interval
+	d.lastCall = now
+	if idle {
+		go f()
+		return
+	}
+
+	// restart the window, a timer that has already fired is ignored through timerID
+	d.pending = f
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timerID++
+	id := d.timerID
+	d.timer = time.AfterFunc(d.interval, func() {
+		d.fire(id)
+	})
+}
+
+func (d *leadingEdgeDebouncer) fire(id uint64) {
+	d.lock.Lock()
+	if id != d.timerID {
+		d.lock.Unlock()
+		return
+	}
+	f := d.pending
+	d.pending = nil
+	d.timer = nil
+	d.lastCall = time.Now()
+	d.lock.Unlock()
+
+	if f != nil {
+		f()
+	}
+}
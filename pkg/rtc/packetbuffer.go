@@ -0,0 +1,75 @@
+package rtc
+
+import (
+	"sync"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+// buffers shrunk below this many packets are not worth forwarding from, the default audio buffer size
+const minPacketBufferSize = 25
+
+// PacketBufferAllocator sizes the packet buffers of a participant's published tracks, keeping the memory they use
+// within the participant's ceiling
+type PacketBufferAllocator struct {
+	config config.PacketBufferConfig
+
+	lock      sync.Mutex
+	allocated int
+}
+
+func NewPacketBufferAllocator(conf config.PacketBufferConfig) *PacketBufferAllocator {
+	return &PacketBufferAllocator{
+		config: conf,
+	}
+}
+
+// Allocate returns the number of packets to buffer for a track of kind, and for video its layer quality. Buffers
+// are shrunk to what is left under the ceiling, 0 means nothing is left and the layer should not be forwarded
+func (a *PacketBufferAllocator) Allocate(kind livekit.TrackType, quality livekit.VideoQuality) int {
+	packets := a.config.Audio
+	if kind == livekit.TrackType_VIDEO {
+		switch quality {
+		case livekit.VideoQuality_LOW:
+			packets = a.config.VideoLowQuality
+		case livekit.VideoQuality_MEDIUM:
+			packets = a.config.VideoMidQuality
+		default:
+			packets = a.config.VideoHighQuality
+		}
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.config.MaxParticipantBytes > 0 {
+		remaining := (a.config.MaxParticipantBytes - a.allocated) / buffer.PacketBufferBytes(1)
+		if packets > remaining {
+			if remaining < minPacketBufferSize {
+				return 0
+			}
+			packets = remaining
+		}
+	}
+
+	a.allocated += buffer.PacketBufferBytes(packets)
+	return packets
+}
+
+// Release returns packets from Allocate once their buffer is closed
+func (a *PacketBufferAllocator) Release(packets int) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.allocated -= buffer.PacketBufferBytes(packets)
+}
+
+func (a *PacketBufferAllocator) AllocatedBytes() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.allocated
+}
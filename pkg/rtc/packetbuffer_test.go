@@ -0,0 +1,51 @@
+package rtc
+
+import (
+	"testing"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+func TestPacketBufferAllocator(t *testing.T) {
+	conf := config.PacketBufferConfig{
+		Audio:            25,
+		VideoLowQuality:  100,
+		VideoMidQuality:  200,
+		VideoHighQuality: 800,
+	}
+
+	t.Run("sized by kind and quality", func(t *testing.T) {
+		a := NewPacketBufferAllocator(conf)
+		require.Equal(t, 25, a.Allocate(livekit.TrackType_AUDIO, livekit.VideoQuality_HIGH))
+		require.Equal(t, 100, a.Allocate(livekit.TrackType_VIDEO, livekit.VideoQuality_LOW))
+		require.Equal(t, 200, a.Allocate(livekit.TrackType_VIDEO, livekit.VideoQuality_MEDIUM))
+		require.Equal(t, 800, a.Allocate(livekit.TrackType_VIDEO, livekit.VideoQuality_HIGH))
+		require.Equal(t, buffer.PacketBufferBytes(1125), a.AllocatedBytes())
+
+		a.Release(800)
+		require.Equal(t, buffer.PacketBufferBytes(325), a.AllocatedBytes())
+	})
+
+	t.Run("memory ceiling", func(t *testing.T) {
+		conf := conf
+		conf.MaxParticipantBytes = buffer.PacketBufferBytes(1000)
+		a := NewPacketBufferAllocator(conf)
+
+		require.Equal(t, 800, a.Allocate(livekit.TrackType_VIDEO, livekit.VideoQuality_HIGH))
+		require.Equal(t, 100, a.Allocate(livekit.TrackType_VIDEO, livekit.VideoQuality_LOW))
+
+		// shrunk to what is left
+		require.Equal(t, 100, a.Allocate(livekit.TrackType_VIDEO, livekit.VideoQuality_MEDIUM))
+		require.Equal(t, conf.MaxParticipantBytes, a.AllocatedBytes())
+
+		// nothing left
+		require.Equal(t, 0, a.Allocate(livekit.TrackType_AUDIO, livekit.VideoQuality_HIGH))
+
+		a.Release(100)
+		require.Equal(t, 25, a.Allocate(livekit.TrackType_AUDIO, livekit.VideoQuality_HIGH))
+	})
+}
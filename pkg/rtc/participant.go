@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"strings"
+	"sort"
 	"sync"
 	"time"
 
@@ -52,7 +52,14 @@ type ParticipantParams struct {
 	ProtocolVersion         types.ProtocolVersion
 	Telemetry               telemetry.TelemetryService
 	PLIThrottleConfig       config.PLIThrottleConfig
+	KeyFrameCacheConfig     config.KeyFrameCacheConfig
+	StreamTrackerConfig     config.StreamTrackerConfig
+	MaxSpatialLayers        int32
 	CongestionControlConfig config.CongestionControlConfig
+	PacerConfig             config.PacerConfig
+	NegotiationConfig       config.NegotiationConfig
+	DataChannelConfig       config.DataChannelConfig
+	SCTPConfig              config.SCTPConfig
 	EnabledCodecs           []*livekit.Codec
 	Hidden                  bool
 	Recorder                bool
@@ -61,6 +68,15 @@ type ParticipantParams struct {
 	Grants                  *auth.ClaimGrants
 	InitialVersion          uint32
 	ClientConf              *livekit.ClientConfiguration
+	// cap on the bitrate sent to the participant, 0 for none
+	MaxSubscribeBitrate uint64
+	// publisher is asked to pause layers that are not subscribed to
+	Dynacast bool
+	// when the join request reached the signal node and its token was validated, for join latency
+	JoinStartedAt    time.Time
+	TokenValidatedAt time.Time
+	// region and node the participant is connected to, reported with its join latency
+	ClientMeta *livekit.AnalyticsClientMeta
 }
 
 type ParticipantImpl struct {
@@ -74,10 +90,10 @@ type ParticipantImpl struct {
 	subscriberAsPrimary bool
 
 	// reliable and unreliable data channels
-	reliableDC    *webrtc.DataChannel
-	reliableDCSub *webrtc.DataChannel
-	lossyDC       *webrtc.DataChannel
-	lossyDCSub    *webrtc.DataChannel
+	reliableDC    *dataChannelWriter
+	reliableDCSub *dataChannelWriter
+	lossyDC       *dataChannelWriter
+	lossyDCSub    *dataChannelWriter
 
 	// when first connected
 	connectedAt time.Time
@@ -92,11 +108,13 @@ type ParticipantImpl struct {
 	pendingTracks     map[string]*pendingTrackInfo
 
 	*UpTrackManager
+	packetBufferAllocator *PacketBufferAllocator
 
 	// tracks the current participant is subscribed to, map of trackID => types.SubscribedTrack
 	subscribedTracks map[livekit.TrackID]types.SubscribedTrack
 	// track settings of tracks the current participant is subscribed to, map of trackID => types.SubscribedTrack
-	subscribedTracksSettings map[livekit.TrackID]*livekit.UpdateTrackSettings
+	subscribedTracksSettings  map[livekit.TrackID]*livekit.UpdateTrackSettings
+	subscribedTracksLayerCaps map[livekit.TrackID]*types.SubscriptionLayerCap
 	// keeps track of disallowed tracks
 	disallowedSubscriptions map[livekit.TrackID]livekit.ParticipantID // trackID -> publisherID
 	// keep track of other publishers identities that we are subscribed to
@@ -130,14 +148,16 @@ type ParticipantImpl struct {
 func NewParticipant(params ParticipantParams, perms *livekit.ParticipantPermission) (*ParticipantImpl, error) {
 	// TODO: check to ensure params are valid, id and identity can't be empty
 	p := &ParticipantImpl{
-		params:                   params,
-		rtcpCh:                   make(chan []rtcp.Packet, 50),
-		pendingTracks:            make(map[string]*pendingTrackInfo),
-		subscribedTracks:         make(map[livekit.TrackID]types.SubscribedTrack),
-		subscribedTracksSettings: make(map[livekit.TrackID]*livekit.UpdateTrackSettings),
-		disallowedSubscriptions:  make(map[livekit.TrackID]livekit.ParticipantID),
-		connectedAt:              time.Now(),
-		rttUpdatedAt:             time.Now(),
+		params:                    params,
+		rtcpCh:                    make(chan []rtcp.Packet, 50),
+		pendingTracks:             make(map[string]*pendingTrackInfo),
+		subscribedTracks:          make(map[livekit.TrackID]types.SubscribedTrack),
+		subscribedTracksSettings:  make(map[livekit.TrackID]*livekit.UpdateTrackSettings),
+		subscribedTracksLayerCaps: make(map[livekit.TrackID]*types.SubscriptionLayerCap),
+		disallowedSubscriptions:   make(map[livekit.TrackID]livekit.ParticipantID),
+		connectedAt:               time.Now(),
+		rttUpdatedAt:              time.Now(),
+		packetBufferAllocator:     NewPacketBufferAllocator(params.Config.Receiver.PacketBuffer),
 	}
 	p.version.Store(params.InitialVersion)
 	p.migrateState.Store(types.MigrateStateInit)
@@ -171,6 +191,8 @@ func NewParticipant(params ParticipantParams, perms *livekit.ParticipantPermissi
 		Target:                  livekit.SignalTarget_SUBSCRIBER,
 		Config:                  params.Config,
 		CongestionControlConfig: params.CongestionControlConfig,
+		PacerConfig:             params.PacerConfig,
+		NegotiationConfig:       params.NegotiationConfig,
 		Telemetry:               p.params.Telemetry,
 		EnabledCodecs:           p.params.EnabledCodecs,
 		Logger:                  params.Logger,
@@ -192,36 +214,41 @@ func NewParticipant(params ParticipantParams, perms *livekit.ParticipantPermissi
 		p.sendIceCandidate(c, livekit.SignalTarget_SUBSCRIBER)
 	})
 
-	primaryPC := p.publisher.pc
+	primary := p.publisher
 	// primary connection does not change, canSubscribe can change if permission was updated
 	// after the participant has joined
 	p.subscriberAsPrimary = p.ProtocolVersion().SubscriberAsPrimary() && p.CanSubscribe()
 	if p.SubscriberAsPrimary() {
-		primaryPC = p.subscriber.pc
-		ordered := true
+		primary = p.subscriber
 		// also create data channels for subs
-		p.reliableDCSub, err = primaryPC.CreateDataChannel(reliableDataChannel, &webrtc.DataChannelInit{
-			Ordered: &ordered,
-		})
+		reliableDC, err := p.subscriber.CreateDataChannel(reliableDataChannel,
+			dataChannelInit(params.DataChannelConfig.Reliable, false))
 		if err != nil {
 			return nil, err
 		}
-		retransmits := uint16(0)
-		p.lossyDCSub, err = primaryPC.CreateDataChannel(lossyDataChannel, &webrtc.DataChannelInit{
-			Ordered:        &ordered,
-			MaxRetransmits: &retransmits,
-		})
+		p.reliableDCSub = newDataChannelWriter(reliableDC, params.SCTPConfig, false, params.Logger, p.onReliableDataFailed)
+		lossyDC, err := p.subscriber.CreateDataChannel(lossyDataChannel,
+			dataChannelInit(params.DataChannelConfig.Lossy, true))
 		if err != nil {
 			return nil, err
 		}
+		p.lossyDCSub = newDataChannelWriter(lossyDC, params.SCTPConfig, true, params.Logger, nil)
 	}
-	primaryPC.OnConnectionStateChange(p.handlePrimaryStateChange)
+	primary.OnConnectionStateChange(p.handlePrimaryStateChange)
+	p.publisher.OnConnected(p.onTransportConnected)
+	p.subscriber.OnConnected(p.onTransportConnected)
+	p.publisher.OnConnectionEvent(p.onConnectionEvent)
+	p.subscriber.OnConnectionEvent(p.onConnectionEvent)
 	p.publisher.pc.OnTrack(p.onMediaTrack)
 	p.publisher.pc.OnDataChannel(p.onDataChannel)
 
 	p.subscriber.OnOffer(p.onOffer)
+	p.subscriber.OnNegotiationFailed(p.onNegotiationFailed)
+	p.publisher.OnDisallowedCandidatePair(p.onDisallowedCandidatePair)
+	p.subscriber.OnDisallowedCandidatePair(p.onDisallowedCandidatePair)
 
 	p.subscriber.OnStreamStateChange(p.onStreamStateChange)
+	p.SetMaxSubscribeBitrate(params.MaxSubscribeBitrate)
 
 	p.setupUpTrackManager()
 
@@ -284,6 +311,7 @@ func (p *ParticipantImpl) ClaimGrants() *auth.ClaimGrants {
 
 func (p *ParticipantImpl) SetPermission(permission *livekit.ParticipantPermission) {
 	p.lock.Lock()
+	hadCanPublish := p.CanPublish()
 	p.permission = permission
 
 	// update grants with this
@@ -293,12 +321,45 @@ func (p *ParticipantImpl) SetPermission(permission *livekit.ParticipantPermissio
 		video.SetCanPublish(permission.CanPublish)
 		video.SetCanPublishData(permission.CanPublishData)
 	}
+	revokedCanPublish := hadCanPublish && !p.CanPublish()
 	p.lock.Unlock()
+
+	// permissions set at creation come before the transports, nothing is published yet
+	if revokedCanPublish && p.publisher != nil {
+		p.unpublishTracks()
+	}
 	if p.onClaimsChanged != nil {
 		p.onClaimsChanged(p)
 	}
 }
 
+// unpublishTracks stops receiving the participant's tracks once it is no longer allowed to publish. Stopping the
+// receivers closes the tracks as when the client unpublishes them, subscribers are removed and others are updated
+func (p *ParticipantImpl) unpublishTracks() {
+	p.pendingTracksLock.Lock()
+	p.pendingTracks = make(map[string]*pendingTrackInfo)
+	p.pendingTracksLock.Unlock()
+
+	for _, tr := range p.publisher.pc.GetTransceivers() {
+		receiver := tr.Receiver()
+		if receiver == nil || len(receiver.Tracks()) == 0 {
+			continue
+		}
+		if err := receiver.Stop(); err != nil {
+			p.params.Logger.Warnw("could not stop receiver", err, "mid", tr.Mid())
+		}
+	}
+}
+
+// SetMaxSubscribeBitrate caps the bitrate sent to the participant, limited by the configured max bitrate.
+// 0 removes the cap
+func (p *ParticipantImpl) SetMaxSubscribeBitrate(bps uint64) {
+	if maxBitrate := p.params.Config.Receiver.maxBitrate; bps != 0 && maxBitrate != 0 && maxBitrate < bps {
+		bps = maxBitrate
+	}
+	p.subscriber.SetMaxSubscribeBitrate(bps)
+}
+
 func (p *ParticipantImpl) ToProto() *livekit.ParticipantInfo {
 	info := &livekit.ParticipantInfo{
 		Sid:      string(p.params.SID),
@@ -374,6 +435,12 @@ func (p *ParticipantImpl) HandleOffer(sdp webrtc.SessionDescription) (answer web
 		// "sdp", sdp.SDP,
 	)
 
+	sdp, rejected, err := filterOfferCodecs(sdp, p.params.EnabledCodecs)
+	if err != nil {
+		prometheus.ServiceOperationCounter.WithLabelValues("answer", "error", "codec_filter").Add(1)
+		return
+	}
+
 	if err = p.publisher.SetRemoteDescription(sdp); err != nil {
 		prometheus.ServiceOperationCounter.WithLabelValues("answer", "error", "remote_description").Add(1)
 		return
@@ -381,17 +448,16 @@ func (p *ParticipantImpl) HandleOffer(sdp webrtc.SessionDescription) (answer web
 
 	p.configureReceiverDTX()
 
-	answer, err = p.publisher.pc.CreateAnswer(nil)
+	answer, err = p.publisher.CreateAnswer()
 	if err != nil {
-		prometheus.ServiceOperationCounter.WithLabelValues("answer", "error", "create").Add(1)
-		err = errors.Wrap(err, "could not create answer")
 		return
 	}
 
-	if err = p.publisher.pc.SetLocalDescription(answer); err != nil {
-		prometheus.ServiceOperationCounter.WithLabelValues("answer", "error", "local_description").Add(1)
-		err = errors.Wrap(err, "could not set local description")
-		return
+	if len(rejected) != 0 {
+		if answer, err = p.rejectUnsupportedCodecs(answer, rejected); err != nil {
+			prometheus.ServiceOperationCounter.WithLabelValues("answer", "error", "codec_filter").Add(1)
+			return
+		}
 	}
 
 	p.params.Logger.Debugw("sending answer to client")
@@ -414,6 +480,24 @@ func (p *ParticipantImpl) HandleOffer(sdp webrtc.SessionDescription) (answer web
 	return
 }
 
+// rejectUnsupportedCodecs declines offered sections without an enabled codec. pion answers them with whatever codec
+// was negotiated for the kind, the client is sent the answer with those sections rejected instead
+func (p *ParticipantImpl) rejectUnsupportedCodecs(answer webrtc.SessionDescription, rejected []rejectedMediaSection) (webrtc.SessionDescription, error) {
+	mids := make([]string, 0, len(rejected))
+	p.pendingTracksLock.Lock()
+	for _, section := range rejected {
+		p.params.Logger.Warnw("rejecting track with unsupported codecs", nil,
+			"mid", section.Mid, "trackID", section.TrackID, "codecs", section.Codecs)
+		mids = append(mids, section.Mid)
+		if section.TrackID != "" {
+			delete(p.pendingTracks, section.TrackID)
+		}
+	}
+	p.pendingTracksLock.Unlock()
+
+	return rejectMediaSections(answer, mids)
+}
+
 // AddTrack is called when client intends to publish track.
 // records track details and lets client know it's ok to proceed
 func (p *ParticipantImpl) AddTrack(req *livekit.AddTrackRequest) {
@@ -440,6 +524,39 @@ func (p *ParticipantImpl) AddTrack(req *livekit.AddTrackRequest) {
 	})
 }
 
+// RejectTrack answers a publication refused by the server. The signal protocol can't fail an AddTrack, so the track is
+// acknowledged and immediately muted by the server: the client's publish resolves and it stops sending media, while
+// nothing is kept pending, so media that still arrives for the track is never published to the room.
+func (p *ParticipantImpl) RejectTrack(req *livekit.AddTrackRequest) {
+	ti := &livekit.TrackInfo{
+		Type:   req.Type,
+		Name:   req.Name,
+		Sid:    utils.NewGuid(utils.TrackPrefix),
+		Width:  req.Width,
+		Height: req.Height,
+		Muted:  true,
+		Source: req.Source,
+		Layers: req.Layers,
+	}
+
+	_ = p.writeMessage(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_TrackPublished{
+			TrackPublished: &livekit.TrackPublishedResponse{
+				Cid:   req.Cid,
+				Track: ti,
+			},
+		},
+	})
+	_ = p.writeMessage(&livekit.SignalResponse{
+		Message: &livekit.SignalResponse_Mute{
+			Mute: &livekit.MuteTrackRequest{
+				Sid:   ti.Sid,
+				Muted: true,
+			},
+		},
+	})
+}
+
 func (p *ParticipantImpl) SetMigrateInfo(mediaTracks []*livekit.TrackPublishedResponse, dataChannels []*livekit.DataChannelInfo) {
 	p.pendingTracksLock.Lock()
 	defer p.pendingTracksLock.Unlock()
@@ -502,6 +619,11 @@ func (p *ParticipantImpl) Close(sendLeave bool) error {
 	if p.dataTrack != nil {
 		p.dataTrack.Close()
 	}
+	for _, dc := range []*dataChannelWriter{p.reliableDC, p.reliableDCSub, p.lossyDC, p.lossyDCSub} {
+		if dc != nil {
+			dc.Close()
+		}
+	}
 	p.UpTrackManager.Close()
 
 	p.pendingTracksLock.Lock()
@@ -582,8 +704,24 @@ func (p *ParticipantImpl) MigrateState() types.MigrateState {
 	return p.migrateState.Load().(types.MigrateState)
 }
 
-// ICERestart restarts subscriber ICE connections
+// ICERestart restarts subscriber ICE connections, and prepares the publisher for the client's restart offer.
+// It's how a participant resumes its session after reconnecting, which is reported to telemetry
 func (p *ParticipantImpl) ICERestart() error {
+	primary := p.publisher
+	if p.SubscriberAsPrimary() {
+		primary = p.subscriber
+	}
+	state, since := primary.ICEConnectionState()
+	p.onConnectionEvent(&telemetry.ConnectionEvent{
+		Type:     telemetry.ConnectionEventResumed,
+		Target:   primary.joinTiming.Target,
+		State:    state.String(),
+		Duration: time.Since(since),
+	})
+
+	if p.publisher.pc.RemoteDescription() != nil {
+		p.publisher.PrepareICERestart()
+	}
 	if p.subscriber.pc.RemoteDescription() == nil {
 		// not connected, skip
 		return nil
@@ -692,7 +830,7 @@ func (p *ParticipantImpl) SendDataPacket(dp *livekit.DataPacket) error {
 		return err
 	}
 
-	var dc *webrtc.DataChannel
+	var dc *dataChannelWriter
 	if dp.Kind == livekit.DataPacket_RELIABLE {
 		if p.SubscriberAsPrimary() {
 			dc = p.reliableDCSub
@@ -710,7 +848,15 @@ func (p *ParticipantImpl) SendDataPacket(dp *livekit.DataPacket) error {
 	if dc == nil {
 		return ErrDataChannelUnavailable
 	}
-	return dc.Send(data)
+	return dc.Write(data)
+}
+
+// onReliableDataFailed disconnects the participant once reliable data couldn't be delivered, so that the client
+// reconnects rather than carrying on with messages missing
+func (p *ParticipantImpl) onReliableDataFailed() {
+	go func() {
+		_ = p.Close(false)
+	}()
 }
 
 func (p *ParticipantImpl) SendRoomUpdate(room *livekit.Room) error {
@@ -771,24 +917,21 @@ func (p *ParticipantImpl) GetAudioLevel() (level uint8, active bool) {
 
 func (p *ParticipantImpl) GetConnectionQuality() *livekit.ConnectionQualityInfo {
 	// avg loss across all tracks, weigh published the same as subscribed
-	totalScore, numTracks := p.getPublisherConnectionQuality()
-
-	p.lock.RLock()
-	for _, subTrack := range p.subscribedTracks {
-		if subTrack.IsMuted() || subTrack.MediaTrack().IsMuted() {
-			continue
-		}
-		totalScore += subTrack.DownTrack().GetConnectionScore()
-		numTracks++
-	}
-	p.lock.RUnlock()
+	pubScore, numPubTracks := p.getPublisherConnectionQuality()
+	subScore, numSubTracks := p.getSubscriberConnectionQuality()
 
+	totalScore := pubScore + subScore
+	numTracks := numPubTracks + numSubTracks
 	avgScore := float32(5.0)
 	if numTracks > 0 {
 		avgScore = totalScore / float32(numTracks)
 	}
 
-	rating := connectionquality.Score2Rating(avgScore)
+	cqConfig := p.params.Config.ConnectionQuality
+	rating := connectionquality.RatingThresholds{
+		Excellent: cqConfig.ExcellentScore,
+		Good:      cqConfig.GoodScore,
+	}.Rating(avgScore)
 
 	return &livekit.ConnectionQualityInfo{
 		ParticipantSid: string(p.ID()),
@@ -808,6 +951,17 @@ func (p *ParticipantImpl) GetSubscribedParticipants() []livekit.ParticipantID {
 	return participantIDs
 }
 
+func (p *ParticipantImpl) GetSubscribedTracks() []types.SubscribedTrack {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	tracks := make([]types.SubscribedTrack, 0, len(p.subscribedTracks))
+	for _, st := range p.subscribedTracks {
+		tracks = append(tracks, st)
+	}
+	return tracks
+}
+
 func (p *ParticipantImpl) CanPublish() bool {
 	return p.permission == nil || p.permission.CanPublish
 }
@@ -836,6 +990,10 @@ func (p *ParticipantImpl) SubscriberPC() *webrtc.PeerConnection {
 	return p.subscriber.pc
 }
 
+func (p *ParticipantImpl) GetSubscriberTransceiverForSending(track webrtc.TrackLocal) (*webrtc.RTPTransceiver, *webrtc.RTPSender, error) {
+	return p.subscriber.GetTransceiverForSending(track)
+}
+
 func (p *ParticipantImpl) UpdateSubscribedTrackSettings(trackID livekit.TrackID, settings *livekit.UpdateTrackSettings) error {
 	p.lock.Lock()
 	p.subscribedTracksSettings[trackID] = settings
@@ -852,6 +1010,21 @@ func (p *ParticipantImpl) UpdateSubscribedTrackSettings(trackID livekit.TrackID,
 	return nil
 }
 
+func (p *ParticipantImpl) UpdateSubscribedTrackLayerCap(trackID livekit.TrackID, layerCap *types.SubscriptionLayerCap) {
+	p.lock.Lock()
+	if layerCap == nil {
+		delete(p.subscribedTracksLayerCaps, trackID)
+	} else {
+		p.subscribedTracksLayerCaps[trackID] = layerCap
+	}
+	subTrack := p.subscribedTracks[trackID]
+	p.lock.Unlock()
+
+	if subTrack != nil {
+		subTrack.SetLayerCap(layerCap)
+	}
+}
+
 // AddSubscribedTrack adds a track to the participant's subscribed list
 func (p *ParticipantImpl) AddSubscribedTrack(subTrack types.SubscribedTrack) {
 	p.params.Logger.Debugw("added subscribedTrack",
@@ -861,12 +1034,16 @@ func (p *ParticipantImpl) AddSubscribedTrack(subTrack types.SubscribedTrack) {
 	p.lock.Lock()
 	p.subscribedTracks[subTrack.ID()] = subTrack
 	settings := p.subscribedTracksSettings[subTrack.ID()]
+	layerCap := p.subscribedTracksLayerCaps[subTrack.ID()]
 	p.lock.Unlock()
 
 	subTrack.OnBind(func() {
 		p.subscriber.AddTrack(subTrack)
 	})
 
+	if layerCap != nil {
+		subTrack.SetLayerCap(layerCap)
+	}
 	if settings != nil {
 		subTrack.UpdateSubscriberSettings(settings)
 	}
@@ -1037,6 +1214,25 @@ func (p *ParticipantImpl) writeMessage(msg *livekit.SignalResponse) error {
 }
 
 // when the server has an offer for participant
+// the client stopped responding to offers, disconnect it so that it can reconnect with a fresh session
+func (p *ParticipantImpl) onNegotiationFailed() {
+	if p.State() == livekit.ParticipantInfo_DISCONNECTED {
+		return
+	}
+	p.params.Logger.Warnw("subscriber negotiation failed, closing participant", nil)
+	_ = p.Close(true)
+}
+
+func (p *ParticipantImpl) onDisallowedCandidatePair() {
+	if p.State() == livekit.ParticipantInfo_DISCONNECTED {
+		return
+	}
+	p.params.Logger.Warnw("connected through a disallowed ICE candidate type, closing participant", nil)
+	go func() {
+		_ = p.Close(true)
+	}()
+}
+
 func (p *ParticipantImpl) onOffer(offer webrtc.SessionDescription) {
 	if p.State() == livekit.ParticipantInfo_DISCONNECTED {
 		// skip when disconnected
@@ -1097,12 +1293,12 @@ func (p *ParticipantImpl) onDataChannel(dc *webrtc.DataChannel) {
 	label := dc.Label()
 	switch label {
 	case reliableDataChannel:
-		p.reliableDC = dc
+		p.reliableDC = newDataChannelWriter(dc, p.params.SCTPConfig, false, p.params.Logger, p.onReliableDataFailed)
 		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
 			p.dataTrack.Write(label, msg.Data)
 		})
 	case lossyDataChannel:
-		p.lossyDC = dc
+		p.lossyDC = newDataChannelWriter(dc, p.params.SCTPConfig, true, p.params.Logger, nil)
 		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
 			p.dataTrack.Write(label, msg.Data)
 		})
@@ -1126,6 +1322,32 @@ func (p *ParticipantImpl) handlePrimaryStateChange(state webrtc.PeerConnectionSt
 	}
 }
 
+// onTransportConnected reports how long a transport took to connect, and the participant joining once its primary
+// transport is connected
+func (p *ParticipantImpl) onTransportConnected(timing telemetry.JoinTiming) {
+	timing.StartedAt = p.params.JoinStartedAt
+	timing.TokenValidatedAt = p.params.TokenValidatedAt
+	p.params.Telemetry.TransportConnected(context.Background(), p.ID(), &timing)
+
+	primaryTarget := livekit.SignalTarget_PUBLISHER
+	if p.SubscriberAsPrimary() {
+		primaryTarget = livekit.SignalTarget_SUBSCRIBER
+	}
+	if timing.Target != primaryTarget {
+		return
+	}
+	clientMeta := &livekit.AnalyticsClientMeta{}
+	if p.params.ClientMeta != nil {
+		clientMeta = proto.Clone(p.params.ClientMeta).(*livekit.AnalyticsClientMeta)
+	}
+	clientMeta.ClientConnectTime = uint32(timing.Duration().Milliseconds())
+	p.params.Telemetry.ParticipantActive(context.Background(), p.ID(), clientMeta, &timing)
+}
+
+func (p *ParticipantImpl) onConnectionEvent(event *telemetry.ConnectionEvent) {
+	p.params.Telemetry.ConnectionChanged(context.Background(), p.ID(), event)
+}
+
 // downTracksRTCPWorker sends SenderReports periodically when the participant is subscribed to
 // other publishedTracks in the room.
 func (p *ParticipantImpl) downTracksRTCPWorker() {
@@ -1177,7 +1399,7 @@ func (p *ParticipantImpl) downTracksRTCPWorker() {
 				batch = sd[:size]
 				sd = sd[size:]
 				pkts = append(pkts, &rtcp.SourceDescription{Chunks: batch})
-				if err := p.subscriber.pc.WriteRTCP(pkts); err != nil {
+				if err := p.subscriber.WriteRTCP(pkts); err != nil {
 					if err == io.EOF || err == io.ErrClosedPipe {
 						return
 					}
@@ -1244,19 +1466,18 @@ func (p *ParticipantImpl) configureReceiverDTX() {
 		receiverCodecs := receiver.GetParameters().Codecs
 		for _, receiverCodec := range receiverCodecs {
 			if receiverCodec.MimeType == webrtc.MimeTypeOpus {
-				fmtpUseDTX := "usedtx=1"
-				// remove occurrence in the middle
-				sdpFmtpLine := strings.ReplaceAll(receiverCodec.SDPFmtpLine, fmtpUseDTX+";", "")
-				// remove occurrence at the end
-				sdpFmtpLine = strings.ReplaceAll(sdpFmtpLine, fmtpUseDTX, "")
-				if enableDTX {
-					sdpFmtpLine += ";" + fmtpUseDTX
-				}
-				receiverCodec.SDPFmtpLine = sdpFmtpLine
+				receiverCodec.SDPFmtpLine = negotiatedOpusFmtp(receiverCodec.SDPFmtpLine, p.params.AudioConfig, enableDTX)
 			}
 			modifiedReceiverCodecs = append(modifiedReceiverCodecs, receiverCodec)
 		}
 
+		if p.params.Config.Publisher.EnableRED {
+			// publishers send the first codec of the answer, prefer RED when it was offered
+			sort.SliceStable(modifiedReceiverCodecs, func(i, j int) bool {
+				return sfu.IsREDCodec(modifiedReceiverCodecs[i].MimeType) && !sfu.IsREDCodec(modifiedReceiverCodecs[j].MimeType)
+			})
+		}
+
 		//
 		// As `SetCodecPreferences` on a transceiver replaces all codecs,
 		// cycle through sender codecs also and add them before calling
@@ -1324,6 +1545,18 @@ func (p *ParticipantImpl) onSubscribedMaxQualityChange(trackID livekit.TrackID,
 	})
 }
 
+func (p *ParticipantImpl) onTrackSilenceChanged(trackID livekit.TrackID, silent bool) {
+	track := p.GetPublishedTrack(trackID)
+	if track == nil {
+		return
+	}
+
+	p.params.Logger.Infow("published track silence changed", "trackID", trackID, "silent", silent)
+	if p.params.AudioConfig.SilenceWebhook {
+		p.params.Telemetry.TrackSilenceChanged(context.Background(), p.ID(), track.ToProto(), silent)
+	}
+}
+
 func (p *ParticipantImpl) addPendingTrack(req *livekit.AddTrackRequest) *livekit.TrackInfo {
 	if p.getPublishedTrackBySignalCid(req.Cid) != nil || p.getPublishedTrackBySdpCid(req.Cid) != nil {
 		return nil
@@ -1354,9 +1587,16 @@ func (p *ParticipantImpl) addPendingTrack(req *livekit.AddTrackRequest) *livekit
 }
 
 func (p *ParticipantImpl) setTrackMuted(trackID livekit.TrackID, muted bool) {
+	wasMuted := false
+	if track := p.UpTrackManager.GetPublishedTrack(trackID); track != nil {
+		wasMuted = track.IsMuted()
+	}
 	track := p.UpTrackManager.SetPublishedTrackMuted(trackID, muted)
 	if track != nil {
 		// handled in UpTrackManager for a published track, no need to update state of pending track
+		if wasMuted != track.IsMuted() {
+			p.params.Telemetry.TrackMuteChanged(context.Background(), p.ID(), track.ToProto(), track.IsMuted())
+		}
 		return
 	}
 
@@ -1388,6 +1628,21 @@ func (p *ParticipantImpl) getPublisherConnectionQuality() (totalScore float32, n
 	return
 }
 
+func (p *ParticipantImpl) getSubscriberConnectionQuality() (totalScore float32, numTracks int) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	for _, subTrack := range p.subscribedTracks {
+		if subTrack.IsMuted() || subTrack.MediaTrack().IsMuted() {
+			continue
+		}
+		totalScore += subTrack.DownTrack().GetConnectionScore()
+		numTracks++
+	}
+
+	return
+}
+
 func (p *ParticipantImpl) getDTX() bool {
 	p.pendingTracksLock.RLock()
 	defer p.pendingTracksLock.RUnlock()
@@ -1438,19 +1693,23 @@ func (p *ParticipantImpl) mediaTrackReceived(track *webrtc.TrackRemote, rtpRecei
 		ti.Mid = mid
 
 		mt = NewMediaTrack(MediaTrackParams{
-			TrackInfo:           ti,
-			SignalCid:           signalCid,
-			SdpCid:              track.ID(),
-			ParticipantID:       p.params.SID,
-			ParticipantIdentity: p.params.Identity,
-			RTCPChan:            p.rtcpCh,
-			BufferFactory:       p.params.Config.BufferFactory,
-			ReceiverConfig:      p.params.Config.Receiver,
-			AudioConfig:         p.params.AudioConfig,
-			Telemetry:           p.params.Telemetry,
-			Logger:              LoggerWithTrack(p.params.Logger, livekit.TrackID(ti.Sid)),
-			SubscriberConfig:    p.params.Config.Subscriber,
-			PLIThrottleConfig:   p.params.PLIThrottleConfig,
+			TrackInfo:             ti,
+			SignalCid:             signalCid,
+			SdpCid:                track.ID(),
+			ParticipantID:         p.params.SID,
+			ParticipantIdentity:   p.params.Identity,
+			RTCPChan:              p.rtcpCh,
+			BufferFactory:         p.params.Config.BufferFactory,
+			PacketBufferAllocator: p.packetBufferAllocator,
+			ReceiverConfig:        p.params.Config.Receiver,
+			AudioConfig:           p.params.AudioConfig,
+			Telemetry:             p.params.Telemetry,
+			Logger:                LoggerWithTrack(p.params.Logger, livekit.TrackID(ti.Sid)),
+			SubscriberConfig:      p.params.Config.Subscriber,
+			PLIThrottleConfig:     p.params.PLIThrottleConfig,
+			KeyFrameCache:         p.params.KeyFrameCacheConfig,
+			StreamTracker:         p.params.StreamTrackerConfig,
+			MaxSpatialLayers:      p.params.MaxSpatialLayers,
 		})
 
 		for ssrc, info := range p.params.SimTracks {
@@ -1459,7 +1718,10 @@ func (p *ParticipantImpl) mediaTrackReceived(track *webrtc.TrackRemote, rtpRecei
 			}
 		}
 
-		mt.OnSubscribedMaxQualityChange(p.onSubscribedMaxQualityChange)
+		if p.params.Dynacast {
+			mt.OnSubscribedMaxQualityChange(p.onSubscribedMaxQualityChange)
+		}
+		mt.OnSilenceChanged(p.onTrackSilenceChanged)
 
 		// add to published and clean up pending
 		p.UpTrackManager.AddPublishedTrack(mt)
@@ -1582,6 +1844,14 @@ func (p *ParticipantImpl) DebugInfo() map[string]interface{} {
 	info := map[string]interface{}{
 		"ID":    p.params.SID,
 		"State": p.State().String(),
+		"ICE": map[string]interface{}{
+			"Publisher":  p.publisher.GetICEConnectionInfo(),
+			"Subscriber": p.subscriber.GetICEConnectionInfo(),
+		},
+		"Transport": map[string]interface{}{
+			"Publisher":  p.publisher.DebugInfo(),
+			"Subscriber": p.subscriber.DebugInfo(),
+		},
 	}
 
 	pendingTrackInfo := make(map[string]interface{})
@@ -1597,6 +1867,18 @@ func (p *ParticipantImpl) DebugInfo() map[string]interface{} {
 	info["PendingTracks"] = pendingTrackInfo
 
 	info["UpTrackManager"] = p.UpTrackManager.DebugInfo()
+	info["PacketBufferBytes"] = p.packetBufferAllocator.AllocatedBytes()
+
+	pubScore, numPubTracks := p.getPublisherConnectionQuality()
+	subScore, numSubTracks := p.getSubscriberConnectionQuality()
+	connectionQualityInfo := make(map[string]interface{})
+	if numPubTracks > 0 {
+		connectionQualityInfo["PublisherScore"] = pubScore / float32(numPubTracks)
+	}
+	if numSubTracks > 0 {
+		connectionQualityInfo["SubscriberScore"] = subScore / float32(numSubTracks)
+	}
+	info["ConnectionQuality"] = connectionQualityInfo
 
 	subscribedTrackInfo := make(map[livekit.TrackID]interface{})
 	p.lock.RLock()
@@ -1618,29 +1900,18 @@ func (p *ParticipantImpl) GetDataTrack() types.DataTrack {
 func (p *ParticipantImpl) handlePendingDataChannels() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	ordered := true
-	negotiated := true
+	conf := p.params.DataChannelConfig
 	for _, ci := range p.pendingDataChannels {
 		var (
 			dc  *webrtc.DataChannel
 			err error
 		)
 		if ci.Label == lossyDataChannel && p.lossyDC == nil {
-			retransmits := uint16(0)
-			id := uint16(ci.GetId())
-			dc, err = p.publisher.pc.CreateDataChannel(lossyDataChannel, &webrtc.DataChannelInit{
-				Ordered:        &ordered,
-				MaxRetransmits: &retransmits,
-				Negotiated:     &negotiated,
-				ID:             &id,
-			})
+			dc, err = p.publisher.CreateDataChannel(lossyDataChannel,
+				negotiatedDataChannelInit(conf.Lossy, true, uint16(ci.GetId())))
 		} else if ci.Label == reliableDataChannel && p.reliableDC == nil {
-			id := uint16(ci.GetId())
-			dc, err = p.publisher.pc.CreateDataChannel(reliableDataChannel, &webrtc.DataChannelInit{
-				Ordered:    &ordered,
-				Negotiated: &negotiated,
-				ID:         &id,
-			})
+			dc, err = p.publisher.CreateDataChannel(reliableDataChannel,
+				negotiatedDataChannelInit(conf.Reliable, false, uint16(ci.GetId())))
 		}
 		if err != nil {
 			p.params.Logger.Errorw("create migrated data channel failed", err, "label", ci.Label)
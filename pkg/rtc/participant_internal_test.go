@@ -14,6 +14,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/routing/routingfakes"
 	"github.com/livekit/livekit-server/pkg/rtc/types"
 	"github.com/livekit/livekit-server/pkg/rtc/types/typesfakes"
+	"github.com/livekit/livekit-server/pkg/sfu"
 	"github.com/livekit/livekit-server/pkg/sfu/connectionquality"
 )
 
@@ -164,6 +165,56 @@ func TestTrackPublishing(t *testing.T) {
 		})
 		require.Equal(t, 0, sink.WriteMessageCallCount())
 	})
+
+	t.Run("rejected tracks are acknowledged muted and never kept pending", func(t *testing.T) {
+		p := newParticipantForTest("test")
+		sink := p.params.Sink.(*routingfakes.FakeMessageSink)
+		p.RejectTrack(&livekit.AddTrackRequest{
+			Cid:  "cid",
+			Name: "webcam",
+			Type: livekit.TrackType_VIDEO,
+		})
+		require.Empty(t, p.pendingTracks)
+		require.Equal(t, 2, sink.WriteMessageCallCount())
+
+		published := sink.WriteMessageArgsForCall(0).(*livekit.SignalResponse).Message.(*livekit.SignalResponse_TrackPublished).TrackPublished
+		require.Equal(t, "cid", published.Cid)
+		require.True(t, published.Track.Muted)
+		mute := sink.WriteMessageArgsForCall(1).(*livekit.SignalResponse).Message.(*livekit.SignalResponse_Mute).Mute
+		require.Equal(t, published.Track.Sid, mute.Sid)
+		require.True(t, mute.Muted)
+	})
+
+	t.Run("revoking publish drops pending tracks and rejects new ones", func(t *testing.T) {
+		p := newParticipantForTest("test")
+		sink := p.params.Sink.(*routingfakes.FakeMessageSink)
+		p.AddTrack(&livekit.AddTrackRequest{
+			Cid:  "cid",
+			Name: "webcam",
+			Type: livekit.TrackType_VIDEO,
+		})
+		require.Len(t, p.pendingTracks, 1)
+
+		claimsChanged := false
+		p.OnClaimsChanged(func(types.LocalParticipant) {
+			claimsChanged = true
+		})
+		p.SetPermission(&livekit.ParticipantPermission{
+			CanSubscribe:   true,
+			CanPublishData: true,
+		})
+		require.True(t, claimsChanged)
+		require.False(t, *p.ClaimGrants().Video.CanPublish)
+		require.Empty(t, p.pendingTracks)
+
+		p.AddTrack(&livekit.AddTrackRequest{
+			Cid:  "cid2",
+			Name: "mic",
+			Type: livekit.TrackType_AUDIO,
+		})
+		require.Empty(t, p.pendingTracks)
+		require.Equal(t, 1, sink.WriteMessageCallCount())
+	})
 }
 
 func TestOutOfOrderUpdates(t *testing.T) {
@@ -245,7 +296,7 @@ func TestConnectionQuality(t *testing.T) {
 		if numRegistered > 0 && numPublishing != numRegistered {
 			reducedQuality = true
 		}
-		return connectionquality.VideoConnectionScore(loss, reducedQuality)
+		return connectionquality.VideoConnectionScore(loss, 0, 0, reducedQuality)
 	}
 
 	testPublishedVideoTrack := func(loss float32, numPublishing, numRegistered uint32) *typesfakes.FakeLocalMediaTrack {
@@ -343,9 +394,103 @@ func TestSubscriberAsPrimary(t *testing.T) {
 	})
 }
 
+func TestHandleOfferOpusParameters(t *testing.T) {
+	offer := func(t *testing.T) webrtc.SessionDescription {
+		me := &webrtc.MediaEngine{}
+		require.NoError(t, me.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2, SDPFmtpLine: "minptime=10;useinbandfec=1;stereo=1;sprop-stereo=1"},
+			PayloadType:        111,
+		}, webrtc.RTPCodecTypeAudio))
+		pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = pc.Close() })
+		_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+		require.NoError(t, err)
+		sd, err := pc.CreateOffer(nil)
+		require.NoError(t, err)
+		return sd
+	}
+	answer := func(t *testing.T, audioConfig config.AudioConfig, disableDTX bool) string {
+		p := newParticipantForTestWithOpts("test", &participantOpts{
+			enabledCodecs: []*livekit.Codec{{Mime: webrtc.MimeTypeOpus}},
+			audioConfig:   &audioConfig,
+		})
+		t.Cleanup(func() { _ = p.Close(false) })
+		p.SetMigrateState(types.MigrateStateComplete)
+		p.AddTrack(&livekit.AddTrackRequest{Cid: "audio", Name: "audio", Type: livekit.TrackType_AUDIO, DisableDtx: disableDTX})
+
+		sd, err := p.HandleOffer(offer(t))
+		require.NoError(t, err)
+		return sd.SDP
+	}
+
+	t.Run("stereo and dtx are negotiated by default", func(t *testing.T) {
+		sdp := answer(t, config.AudioConfig{AllowStereo: true, AllowDTX: true}, false)
+		require.Contains(t, sdp, "stereo=1;sprop-stereo=1")
+		require.Contains(t, sdp, "usedtx=1")
+	})
+
+	t.Run("dtx disabled on the track", func(t *testing.T) {
+		sdp := answer(t, config.AudioConfig{AllowStereo: true, AllowDTX: true}, true)
+		require.Contains(t, sdp, "stereo=1")
+		require.NotContains(t, sdp, "usedtx")
+	})
+
+	t.Run("stereo and dtx not allowed", func(t *testing.T) {
+		sdp := answer(t, config.AudioConfig{}, false)
+		require.Contains(t, sdp, "useinbandfec=1")
+		require.NotContains(t, sdp, "stereo")
+		require.NotContains(t, sdp, "usedtx")
+	})
+}
+
+func TestHandleOfferRED(t *testing.T) {
+	offer := func(t *testing.T) webrtc.SessionDescription {
+		me := &webrtc.MediaEngine{}
+		require.NoError(t, me.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2, SDPFmtpLine: "minptime=10;useinbandfec=1"},
+			PayloadType:        111,
+		}, webrtc.RTPCodecTypeAudio))
+		require.NoError(t, me.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: sfu.MimeTypeAudioRED, ClockRate: 48000, Channels: 2, SDPFmtpLine: "111/111"},
+			PayloadType:        63,
+		}, webrtc.RTPCodecTypeAudio))
+		pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(me)).NewPeerConnection(webrtc.Configuration{})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = pc.Close() })
+		_, err = pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+		require.NoError(t, err)
+		sd, err := pc.CreateOffer(nil)
+		require.NoError(t, err)
+		return sd
+	}
+	answerFormats := func(t *testing.T, enableRED bool) []string {
+		p := newParticipantForTestWithOpts("test", &participantOpts{
+			enabledCodecs: []*livekit.Codec{{Mime: webrtc.MimeTypeOpus}},
+			audioConfig:   &config.AudioConfig{EnableRED: enableRED},
+		})
+		t.Cleanup(func() { _ = p.Close(false) })
+		p.SetMigrateState(types.MigrateStateComplete)
+		p.AddTrack(&livekit.AddTrackRequest{Cid: "audio", Name: "audio", Type: livekit.TrackType_AUDIO})
+
+		answer, err := p.HandleOffer(offer(t))
+		require.NoError(t, err)
+		parsed, err := answer.Unmarshal()
+		require.NoError(t, err)
+		require.Len(t, parsed.MediaDescriptions, 1)
+		return parsed.MediaDescriptions[0].MediaName.Formats
+	}
+
+	// publishers send the first codec of the answer
+	require.Equal(t, []string{"63", "111"}, answerFormats(t, true))
+	require.Equal(t, []string{"111"}, answerFormats(t, false))
+}
+
 type participantOpts struct {
 	permissions     *livekit.ParticipantPermission
 	protocolVersion types.ProtocolVersion
+	enabledCodecs   []*livekit.Codec
+	audioConfig     *config.AudioConfig
 }
 
 func newParticipantForTestWithOpts(identity livekit.ParticipantIdentity, opts *participantOpts) *ParticipantImpl {
@@ -359,6 +504,9 @@ func newParticipantForTestWithOpts(identity livekit.ParticipantIdentity, opts *p
 	// disable mux, it doesn't play too well with unit test
 	conf.RTC.UDPPort = 0
 	conf.RTC.TCPPort = 0
+	if opts.audioConfig != nil {
+		conf.Audio = *opts.audioConfig
+	}
 	rtcConf, err := NewWebRTCConfig(conf, "")
 	if err != nil {
 		panic(err)
@@ -369,6 +517,8 @@ func newParticipantForTestWithOpts(identity livekit.ParticipantIdentity, opts *p
 		Sink:              &routingfakes.FakeMessageSink{},
 		ProtocolVersion:   opts.protocolVersion,
 		PLIThrottleConfig: conf.RTC.PLIThrottle,
+		EnabledCodecs:     opts.enabledCodecs,
+		AudioConfig:       conf.Audio,
 		Grants: &auth.ClaimGrants{
 			Video: &auth.VideoGrant{},
 		},
@@ -1,6 +1,7 @@
 package rtc
 
 import (
+	"context"
 	"math"
 	"sort"
 	"sync"
@@ -23,6 +24,8 @@ const (
 	DefaultEmptyTimeout       = 5 * 60 // 5m
 	DefaultRoomDepartureGrace = 20
 	AudioLevelQuantization    = 8 // ideally power of 2 to minimize float decimal
+
+	defaultConnectionQualityUpdateInterval = 5 * time.Second
 )
 
 type Room struct {
@@ -47,6 +50,10 @@ type Room struct {
 	leftAt atomic.Int64
 	closed chan struct{}
 
+	// user data packets received by the room, from participants and SendData
+	dataPackets atomic.Uint64
+	dataBytes   atomic.Uint64
+
 	onParticipantChanged func(p types.LocalParticipant)
 	onMetadataUpdate     func(metadata string)
 	onClose              func()
@@ -425,6 +432,19 @@ func (r *Room) SetParticipantPermission(participant types.LocalParticipant, perm
 			}
 		}
 	}
+	// when taken away, current subscriptions end too
+	if hadCanSubscribe && !participant.CanSubscribe() {
+		for _, st := range participant.GetSubscribedTracks() {
+			if pub := r.GetParticipantBySid(st.PublisherID()); pub != nil {
+				pub.RemoveSubscriber(participant, st.ID(), false)
+			}
+		}
+	}
+
+	r.broadcastParticipantState(participant, false)
+	if r.onParticipantChanged != nil {
+		r.onParticipantChanged(participant)
+	}
 	return nil
 }
 
@@ -646,6 +666,15 @@ func (r *Room) onDataPacket(source types.LocalParticipant, dp *livekit.DataPacke
 	if source != nil && !source.CanPublishData() {
 		return
 	}
+	if up := dp.GetUser(); up != nil {
+		dataSource := prometheus.DataSourceParticipant
+		if source == nil {
+			dataSource = prometheus.DataSourceServer
+		}
+		r.dataPackets.Inc()
+		r.dataBytes.Add(uint64(len(up.Payload)))
+		prometheus.IncrementDataPacket(dataSource, dp.Kind, len(up.Payload))
+	}
 	dest := dp.GetUser().GetDestinationSids()
 
 	for _, op := range r.GetParticipants() {
@@ -761,92 +790,83 @@ func (r *Room) sendSpeakerChanges(speakers []*livekit.SpeakerInfo) {
 }
 
 func (r *Room) audioUpdateWorker() {
-	var smoothValues map[livekit.ParticipantID]float32
-	var smoothFactor float32
-	var activeThreshold float32
-	if ss := r.audioConfig.SmoothIntervals; ss > 1 {
-		smoothValues = make(map[livekit.ParticipantID]float32)
-		// exponential moving average (EMA), same center of mass with simple moving average (SMA)
-		smoothFactor = 2 / float32(ss+1)
-		activeThreshold = ConvertAudioLevel(r.audioConfig.ActiveLevel)
-	}
-
-	lastActiveMap := make(map[livekit.ParticipantID]*livekit.SpeakerInfo)
+	activeSpeakers := NewActiveSpeakers(r.audioConfig)
+	audioForwarding := make(map[livekit.ParticipantID]*AudioForwarding)
 	for {
 		if r.IsClosed() {
 			return
 		}
 
-		activeSpeakers := r.GetActiveSpeakers()
-		if smoothValues != nil {
-			for _, speaker := range activeSpeakers {
-				sid := livekit.ParticipantID(speaker.Sid)
-				level := smoothValues[sid]
-				delete(smoothValues, sid)
-				// exponential moving average (EMA)
-				level += (speaker.Level - level) * smoothFactor
-				speaker.Level = level
-			}
+		participants := r.GetParticipants()
+		levels := make([]SpeakerLevel, 0, len(participants))
+		for _, p := range participants {
+			level, active := p.GetAudioLevel()
+			levels = append(levels, SpeakerLevel{
+				ParticipantID: p.ID(),
+				Level:         ConvertAudioLevel(level),
+				Active:        active,
+			})
+		}
 
-			// ensure that previous active speakers are also included
-			for sid, level := range smoothValues {
-				delete(smoothValues, sid)
-				level += -level * smoothFactor
-				if level > activeThreshold {
-					activeSpeakers = append(activeSpeakers, &livekit.SpeakerInfo{
-						Sid:    string(sid),
-						Level:  level,
-						Active: true,
-					})
-				}
+		// updates are sent only when the set of speakers changes, levels are as of then
+		speakers, removed, changed := activeSpeakers.Update(levels, time.Now())
+		if changed {
+			const invAudioLevelQuantization = 1.0 / AudioLevelQuantization
+			for _, speaker := range speakers {
+				speaker.Level = float32(math.Ceil(float64(speaker.Level*AudioLevelQuantization)) * invAudioLevelQuantization)
 			}
 
-			// smoothValues map is drained, now repopulate it back
-			for _, speaker := range activeSpeakers {
-				smoothValues[livekit.ParticipantID(speaker.Sid)] = speaker.Level
+			// changedSpeakers need to include previous speakers that are no longer speaking
+			changedSpeakers := make([]*livekit.SpeakerInfo, 0, len(speakers)+len(removed))
+			changedSpeakers = append(changedSpeakers, speakers...)
+			for _, pID := range removed {
+				changedSpeakers = append(changedSpeakers, &livekit.SpeakerInfo{
+					Sid:    string(pID),
+					Level:  0,
+					Active: false,
+				})
 			}
 
-			sort.Slice(activeSpeakers, func(i, j int) bool {
-				return activeSpeakers[i].Level > activeSpeakers[j].Level
-			})
-		}
-
-		const invAudioLevelQuantization = 1.0 / AudioLevelQuantization
-		for _, speaker := range activeSpeakers {
-			speaker.Level = float32(math.Ceil(float64(speaker.Level*AudioLevelQuantization)) * invAudioLevelQuantization)
+			r.sendActiveSpeakers(speakers)
+			r.sendSpeakerChanges(changedSpeakers)
 		}
 
-		changedSpeakers := make([]*livekit.SpeakerInfo, 0, len(activeSpeakers))
-		nextActiveMap := make(map[livekit.ParticipantID]*livekit.SpeakerInfo, len(activeSpeakers))
-		for _, speaker := range activeSpeakers {
-			prev := lastActiveMap[livekit.ParticipantID(speaker.Sid)]
-			if prev == nil || prev.Level != speaker.Level {
-				changedSpeakers = append(changedSpeakers, speaker)
+		if maxTracks := r.audioConfig.MaxForwardedTracks; maxTracks > 0 {
+			present := make(map[livekit.ParticipantID]bool, len(participants))
+			for _, p := range participants {
+				present[p.ID()] = true
+				af := audioForwarding[p.ID()]
+				if af == nil {
+					af = NewAudioForwarding(maxTracks)
+					audioForwarding[p.ID()] = af
+				}
+				af.Update(p.GetSubscribedTracks(), activeSpeakers.Level, time.Now())
 			}
-			nextActiveMap[livekit.ParticipantID(speaker.Sid)] = speaker
-		}
-		// changedSpeakers need to include previous speakers that are no longer speaking
-		for sid, speaker := range lastActiveMap {
-			if nextActiveMap[sid] == nil {
-				speaker.Level = 0
-				speaker.Active = false
-				changedSpeakers = append(changedSpeakers, speaker)
+			for pID := range audioForwarding {
+				if !present[pID] {
+					delete(audioForwarding, pID)
+				}
 			}
 		}
 
-		// see if an update is needed
-		if len(changedSpeakers) > 0 {
-			r.sendActiveSpeakers(activeSpeakers)
-			r.sendSpeakerChanges(changedSpeakers)
-		}
-
-		lastActiveMap = nextActiveMap
-
-		time.Sleep(time.Duration(r.audioConfig.UpdateInterval) * time.Millisecond)
+		time.Sleep(r.audioConfig.UpdateInterval.Duration())
 	}
 }
 
 func (r *Room) connectionQualityWorker() {
+	interval := r.config.ConnectionQuality.UpdateInterval.Duration()
+	if interval == 0 {
+		interval = defaultConnectionQualityUpdateInterval
+	}
+
+	// last rating of each active participant, counted in the connection quality gauge
+	ratings := make(map[livekit.ParticipantID]livekit.ConnectionQuality)
+	defer func() {
+		for _, rating := range ratings {
+			prometheus.SubConnectionQuality(rating)
+		}
+	}()
+
 	// send updates to only users that are subscribed to each other
 	for {
 		if r.IsClosed() {
@@ -863,6 +883,7 @@ func (r *Room) connectionQualityWorker() {
 
 			connectionInfos[p.ID()] = p.GetConnectionQuality()
 		}
+		r.updateConnectionQualityRatings(ratings, connectionInfos)
 
 		for _, op := range participants {
 			if !op.ProtocolVersion().SupportsConnectionQuality() || op.State() != livekit.ParticipantInfo_ACTIVE {
@@ -887,7 +908,34 @@ func (r *Room) connectionQualityWorker() {
 			}
 		}
 
-		time.Sleep(time.Second * 5)
+		time.Sleep(interval)
+	}
+}
+
+// updateConnectionQualityRatings keeps the gauge in step with the participants' ratings, and records transitions
+func (r *Room) updateConnectionQualityRatings(ratings map[livekit.ParticipantID]livekit.ConnectionQuality,
+	connectionInfos map[livekit.ParticipantID]*livekit.ConnectionQualityInfo) {
+
+	for pID, info := range connectionInfos {
+		rating := info.GetQuality()
+		prev, ok := ratings[pID]
+		if ok && prev == rating {
+			continue
+		}
+
+		if ok {
+			prometheus.SubConnectionQuality(prev)
+			r.telemetry.ConnectionQualityChanged(context.Background(), pID, prev, info)
+		}
+		prometheus.AddConnectionQuality(rating)
+		ratings[pID] = rating
+	}
+
+	for pID, rating := range ratings {
+		if _, ok := connectionInfos[pID]; !ok {
+			prometheus.SubConnectionQuality(rating)
+			delete(ratings, pID)
+		}
 	}
 }
 
@@ -896,6 +944,10 @@ func (r *Room) DebugInfo() map[string]interface{} {
 		"Name":      r.Room.Name,
 		"Sid":       r.Room.Sid,
 		"CreatedAt": r.Room.CreationTime,
+		"Data": map[string]interface{}{
+			"Packets": r.dataPackets.Load(),
+			"Bytes":   r.dataBytes.Load(),
+		},
 	}
 
 	participants := r.GetParticipants()
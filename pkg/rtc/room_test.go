@@ -7,6 +7,7 @@ import (
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 
 	"github.com/livekit/livekit-server/pkg/config"
 	serverlogger "github.com/livekit/livekit-server/pkg/logger"
@@ -14,6 +15,7 @@ import (
 	"github.com/livekit/livekit-server/pkg/rtc/types"
 	"github.com/livekit/livekit-server/pkg/rtc/types/typesfakes"
 	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/telemetry/telemetryfakes"
 	"github.com/livekit/livekit-server/pkg/testutils"
 )
 
@@ -198,6 +200,43 @@ func TestParticipantUpdate(t *testing.T) {
 	}
 }
 
+func TestSetParticipantPermission(t *testing.T) {
+	t.Run("others are updated", func(t *testing.T) {
+		rm := newRoomWithParticipants(t, testRoomOpts{num: 3})
+		defer rm.Close()
+		participants := rm.GetParticipants()
+		p := participants[0].(*typesfakes.FakeLocalParticipant)
+
+		require.NoError(t, rm.SetParticipantPermission(p, &livekit.ParticipantPermission{CanSubscribe: true}))
+		require.Equal(t, 1, p.SetPermissionCallCount())
+		for _, op := range participants {
+			require.Equal(t, 1, op.(*typesfakes.FakeLocalParticipant).SendParticipantUpdateCallCount())
+		}
+	})
+
+	t.Run("revoking subscribe ends subscriptions", func(t *testing.T) {
+		rm := newRoomWithParticipants(t, testRoomOpts{num: 2})
+		defer rm.Close()
+		participants := rm.GetParticipants()
+		sub := participants[0].(*typesfakes.FakeLocalParticipant)
+		pub := participants[1].(*typesfakes.FakeLocalParticipant)
+
+		st := &typesfakes.FakeSubscribedTrack{}
+		st.IDReturns("track")
+		st.PublisherIDReturns(pub.ID())
+		sub.GetSubscribedTracksReturns([]types.SubscribedTrack{st})
+		sub.CanSubscribeReturnsOnCall(0, true)
+		sub.CanSubscribeReturns(false)
+
+		require.NoError(t, rm.SetParticipantPermission(sub, &livekit.ParticipantPermission{CanPublish: true}))
+		require.Equal(t, 1, pub.RemoveSubscriberCallCount())
+		removed, trackID, resume := pub.RemoveSubscriberArgsForCall(0)
+		require.Equal(t, sub, removed)
+		require.Equal(t, livekit.TrackID("track"), trackID)
+		require.False(t, resume)
+	})
+}
+
 func TestRoomClosure(t *testing.T) {
 	t.Run("room closes after participant leaves", func(t *testing.T) {
 		rm := newRoomWithParticipants(t, testRoomOpts{num: 1})
@@ -493,6 +532,29 @@ func TestDataChannel(t *testing.T) {
 			require.Zero(t, fp.SendDataPacketCallCount())
 		}
 	})
+
+	t.Run("server data reaches every participant and is counted", func(t *testing.T) {
+		rm := newRoomWithParticipants(t, testRoomOpts{num: 3})
+		defer rm.Close()
+
+		up := &livekit.UserPacket{
+			ParticipantSid: "PA_server",
+			Payload:        []byte("scoreboard"),
+		}
+		rm.SendDataPacket(up, livekit.DataPacket_LOSSY)
+
+		for _, op := range rm.GetParticipants() {
+			fp := op.(*typesfakes.FakeLocalParticipant)
+			require.Equal(t, 1, fp.SendDataPacketCallCount())
+			dp := fp.SendDataPacketArgsForCall(0)
+			require.Equal(t, livekit.DataPacket_LOSSY, dp.Kind)
+			require.Equal(t, "PA_server", dp.GetUser().ParticipantSid)
+		}
+
+		data := rm.DebugInfo()["Data"].(map[string]interface{})
+		require.Equal(t, uint64(1), data["Packets"])
+		require.Equal(t, uint64(len("scoreboard")), data["Bytes"])
+	})
 }
 
 func TestHiddenParticipants(t *testing.T) {
@@ -539,6 +601,58 @@ func TestHiddenParticipants(t *testing.T) {
 	})
 }
 
+func TestConnectionQuality(t *testing.T) {
+	telemetryService := &telemetryfakes.FakeTelemetryService{}
+	rm := rtc.NewRoom(
+		&livekit.Room{Name: "room"},
+		rtc.WebRTCConfig{
+			ConnectionQuality: config.ConnectionQualityConfig{
+				UpdateInterval: config.Duration(10 * time.Millisecond),
+			},
+		},
+		&config.AudioConfig{UpdateInterval: config.DurationMilliseconds(audioUpdateInterval * time.Millisecond)},
+		telemetryService,
+	)
+	defer rm.Close()
+
+	var quality atomic.Int32
+	quality.Store(int32(livekit.ConnectionQuality_EXCELLENT))
+	p := newMockParticipant("p", 6, false)
+	p.GetConnectionQualityStub = func() *livekit.ConnectionQualityInfo {
+		return &livekit.ConnectionQualityInfo{
+			ParticipantSid: string(p.ID()),
+			Quality:        livekit.ConnectionQuality(quality.Load()),
+		}
+	}
+	require.NoError(t, rm.Join(p, nil, iceServersForRoom, ""))
+	p.StateReturns(livekit.ParticipantInfo_ACTIVE)
+
+	t.Run("participant receives its own quality", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			return p.SendConnectionQualityUpdateCallCount() > 0
+		}, time.Second, defaultDelay)
+
+		update := p.SendConnectionQualityUpdateArgsForCall(0)
+		require.Len(t, update.Updates, 1)
+		require.Equal(t, livekit.ConnectionQuality_EXCELLENT, update.Updates[0].Quality)
+		require.Zero(t, telemetryService.ConnectionQualityChangedCallCount())
+	})
+
+	t.Run("transitions are recorded", func(t *testing.T) {
+		quality.Store(int32(livekit.ConnectionQuality_POOR))
+		require.Eventually(t, func() bool {
+			return telemetryService.ConnectionQualityChangedCallCount() > 0
+		}, time.Second, defaultDelay)
+
+		time.Sleep(5 * defaultDelay)
+		require.Equal(t, 1, telemetryService.ConnectionQualityChangedCallCount())
+		_, pID, prev, info := telemetryService.ConnectionQualityChangedArgsForCall(0)
+		require.Equal(t, p.ID(), pID)
+		require.Equal(t, livekit.ConnectionQuality_EXCELLENT, prev)
+		require.Equal(t, livekit.ConnectionQuality_POOR, info.Quality)
+	})
+}
+
 func TestRoomUpdate(t *testing.T) {
 	t.Run("participants should receive metadata update", func(t *testing.T) {
 		rm := newRoomWithParticipants(t, testRoomOpts{num: 2})
@@ -565,10 +679,10 @@ func newRoomWithParticipants(t *testing.T, opts testRoomOpts) *rtc.Room {
 		&livekit.Room{Name: "room"},
 		rtc.WebRTCConfig{},
 		&config.AudioConfig{
-			UpdateInterval:  audioUpdateInterval,
+			UpdateInterval:  config.DurationMilliseconds(audioUpdateInterval * time.Millisecond),
 			SmoothIntervals: opts.audioSmoothIntervals,
 		},
-		telemetry.NewTelemetryService(nil, nil),
+		telemetry.NewTelemetryService(&config.Config{}, nil, nil),
 	)
 	for i := 0; i < opts.num+opts.numHidden; i++ {
 		identity := livekit.ParticipantIdentity(fmt.Sprintf("p%d", i))
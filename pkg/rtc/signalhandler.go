@@ -31,7 +31,10 @@ func HandleParticipantSignal(room types.Room, participant types.LocalParticipant
 			pLogger.Warnw("could not decode trickle", err)
 			return nil
 		}
-		if err := participant.AddICECandidate(candidateInit, msg.Trickle.Target); err != nil {
+		if err := participant.AddICECandidate(candidateInit, msg.Trickle.Target); err == ErrTooManyPendingCandidates {
+			// the client keeps trickling before sending its description, later candidates are dropped
+			pLogger.Warnw("could not queue trickle", err, "target", msg.Trickle.Target)
+		} else if err != nil {
 			pLogger.Warnw("could not handle trickle", err)
 		}
 	case *livekit.SignalRequest_Mute:
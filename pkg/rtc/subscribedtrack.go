@@ -27,7 +27,10 @@ type SubscribedTrack struct {
 	params   SubscribedTrackParams
 	subMuted atomic.Bool
 	pubMuted atomic.Bool
-	settings atomic.Value // *livekit.UpdateTrackSettings
+	// forwarding paused by the server, i.e. audio outside the loudest tracks forwarded to the subscriber
+	forwardingPaused atomic.Bool
+	settings         atomic.Value // *livekit.UpdateTrackSettings
+	layerCap         atomic.Value // *types.SubscriptionLayerCap
 
 	onBind func()
 
@@ -85,36 +88,87 @@ func (t *SubscribedTrack) SetPublisherMuted(muted bool) {
 	t.updateDownTrackMute()
 }
 
+// SetForwardingPaused stops forwarding media without the subscriber seeing the track muted
+func (t *SubscribedTrack) SetForwardingPaused(paused bool) {
+	if t.forwardingPaused.Swap(paused) != paused {
+		t.updateDownTrackMute()
+	}
+}
+
+func (t *SubscribedTrack) IsForwardingPaused() bool {
+	return t.forwardingPaused.Load()
+}
+
 func (t *SubscribedTrack) UpdateSubscriberSettings(settings *livekit.UpdateTrackSettings) {
 	prevDisabled := t.subMuted.Swap(settings.Disabled)
 	t.settings.Store(settings)
-	// avoid frequent changes to mute & video layers, unless it became visible
-	if prevDisabled != settings.Disabled && !settings.Disabled {
+	// avoid frequent changes to mute & video layers, unless it became visible or needs a higher layer
+	if (prevDisabled != settings.Disabled && !settings.Disabled) || t.isRaisingMaxLayers() {
 		t.UpdateVideoLayer()
 	} else {
 		t.debouncer(t.UpdateVideoLayer)
 	}
 }
 
+// SetLayerCap limits the layers forwarded irrespective of the subscriber's settings, nil removes the limit.
+// Applied right away, so that lifting the cap requests the higher layer without delay
+func (t *SubscribedTrack) SetLayerCap(layerCap *types.SubscriptionLayerCap) {
+	t.layerCap.Store(layerCap)
+	t.UpdateVideoLayer()
+}
+
 func (t *SubscribedTrack) UpdateVideoLayer() {
 	t.updateDownTrackMute()
 	if t.DownTrack().Kind() != webrtc.RTPCodecTypeVideo {
 		return
 	}
 
-	settings, ok := t.settings.Load().(*livekit.UpdateTrackSettings)
-	if !ok {
-		return
+	spatial, temporal := t.getMaxLayers()
+	t.DownTrack().SetMaxSpatialLayer(spatial)
+	t.DownTrack().SetMaxTemporalLayer(temporal)
+}
+
+// getMaxLayers returns the layers requested by the subscriber, limited by the layer cap
+func (t *SubscribedTrack) getMaxLayers() (int32, int32) {
+	spatial := t.DownTrack().SpatialLayerLimit()
+	temporal := sfu.DefaultMaxLayerTemporal
+
+	if settings, ok := t.settings.Load().(*livekit.UpdateTrackSettings); ok {
+		quality := settings.Quality
+		if settings.Width > 0 {
+			quality = t.MediaTrack().GetQualityForDimension(settings.Width, settings.Height)
+		}
+		spatial = SpatialLayerForQuality(quality)
+	}
+
+	if layerCap, _ := t.layerCap.Load().(*types.SubscriptionLayerCap); layerCap != nil {
+		if layerCap.MaxSpatialLayer != sfu.InvalidLayerSpatial && layerCap.MaxSpatialLayer < spatial {
+			spatial = layerCap.MaxSpatialLayer
+		}
+		if layerCap.Width > 0 || layerCap.Height > 0 {
+			if capped := SpatialLayerForQuality(t.MediaTrack().GetQualityForDimension(layerCap.Width, layerCap.Height)); capped < spatial {
+				spatial = capped
+			}
+		}
+		if layerCap.MaxTemporalLayer != sfu.InvalidLayerTemporal && layerCap.MaxTemporalLayer < temporal {
+			temporal = layerCap.MaxTemporalLayer
+		}
 	}
 
-	quality := settings.Quality
-	if settings.Width > 0 {
-		quality = t.MediaTrack().GetQualityForDimension(settings.Width, settings.Height)
+	return spatial, temporal
+}
+
+func (t *SubscribedTrack) isRaisingMaxLayers() bool {
+	if t.DownTrack().Kind() != webrtc.RTPCodecTypeVideo {
+		return false
 	}
-	t.DownTrack().SetMaxSpatialLayer(SpatialLayerForQuality(quality))
+
+	spatial, temporal := t.getMaxLayers()
+	maxLayers := t.DownTrack().MaxLayers()
+	return spatial > maxLayers.Spatial() || temporal > maxLayers.Temporal()
 }
 
 func (t *SubscribedTrack) updateDownTrackMute() {
-	muted := t.subMuted.Load() || t.pubMuted.Load()
+	muted := t.subMuted.Load() || t.pubMuted.Load() || t.forwardingPaused.Load()
 	t.DownTrack().Mute(muted)
 }
@@ -0,0 +1,118 @@
+package rtc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/rtc/types/typesfakes"
+	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+type subscribedTrackTestReceiver struct{}
+
+func (r *subscribedTrackTestReceiver) TrackID() livekit.TrackID { return "TR_video" }
+func (r *subscribedTrackTestReceiver) StreamID() string         { return "stream" }
+func (r *subscribedTrackTestReceiver) Codec() webrtc.RTPCodecCapability {
+	return webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}
+}
+func (r *subscribedTrackTestReceiver) ReadRTP(buf []byte, layer uint8, sn uint16) (int, error) {
+	return 0, nil
+}
+func (r *subscribedTrackTestReceiver) GetSenderReportTime(layer int32) (uint32, uint64) { return 0, 0 }
+func (r *subscribedTrackTestReceiver) GetBitrateTemporalCumulative() sfu.Bitrates       { return nil }
+func (r *subscribedTrackTestReceiver) MaxSpatialLayer() int32                           { return sfu.DefaultMaxLayerSpatial }
+func (r *subscribedTrackTestReceiver) SendPLI(layer int32)                              {}
+func (r *subscribedTrackTestReceiver) GetCachedKeyFrame(layer int32) []*buffer.ExtPacket {
+	return nil
+}
+func (r *subscribedTrackTestReceiver) SetUpTrackPaused(paused bool)                 {}
+func (r *subscribedTrackTestReceiver) SetMaxExpectedSpatialLayer(layer int32)       {}
+func (r *subscribedTrackTestReceiver) AddDownTrack(track sfu.TrackSender) error     { return nil }
+func (r *subscribedTrackTestReceiver) DeleteDownTrack(peerID livekit.ParticipantID) {}
+func (r *subscribedTrackTestReceiver) DebugInfo() map[string]interface{}            { return nil }
+
+func newSubscribedTrackForTest(t *testing.T) (*SubscribedTrack, *typesfakes.FakeMediaTrack) {
+	receiver := &subscribedTrackTestReceiver{}
+	dt, err := sfu.NewDownTrack(receiver.Codec(), receiver, nil, "PA_sub", 1500, logger.Logger(logger.GetLogger()))
+	require.NoError(t, err)
+
+	mediaTrack := &typesfakes.FakeMediaTrack{}
+	mediaTrack.KindReturns(livekit.TrackType_VIDEO)
+	return NewSubscribedTrack(SubscribedTrackParams{
+		PublisherID:  "PA_pub",
+		SubscriberID: "PA_sub",
+		MediaTrack:   mediaTrack,
+		DownTrack:    dt,
+	}), mediaTrack
+}
+
+func requireMaxLayers(t *testing.T, st *SubscribedTrack, spatial int32, temporal int32) {
+	maxLayers := st.DownTrack().MaxLayers()
+	require.Equal(t, spatial, maxLayers.Spatial(), "spatial")
+	require.Equal(t, temporal, maxLayers.Temporal(), "temporal")
+}
+
+func TestSubscribedTrackLayerCap(t *testing.T) {
+	t.Run("caps the subscriber settings", func(t *testing.T) {
+		st, _ := newSubscribedTrackForTest(t)
+		st.UpdateSubscriberSettings(&livekit.UpdateTrackSettings{Quality: livekit.VideoQuality_HIGH})
+		requireMaxLayers(t, st, 2, sfu.DefaultMaxLayerTemporal)
+
+		st.SetLayerCap(&types.SubscriptionLayerCap{MaxSpatialLayer: 0, MaxTemporalLayer: 1})
+		requireMaxLayers(t, st, 0, 1)
+
+		// a lower subscriber setting still applies
+		st.SetLayerCap(&types.SubscriptionLayerCap{MaxSpatialLayer: 1, MaxTemporalLayer: sfu.InvalidLayerTemporal})
+		requireMaxLayers(t, st, 1, sfu.DefaultMaxLayerTemporal)
+
+		// lifting the cap is not delayed
+		st.SetLayerCap(nil)
+		requireMaxLayers(t, st, 2, sfu.DefaultMaxLayerTemporal)
+	})
+
+	t.Run("caps without subscriber settings", func(t *testing.T) {
+		st, _ := newSubscribedTrackForTest(t)
+		st.SetLayerCap(&types.SubscriptionLayerCap{MaxSpatialLayer: sfu.InvalidLayerSpatial, MaxTemporalLayer: 0})
+		requireMaxLayers(t, st, sfu.DefaultMaxLayerSpatial, 0)
+	})
+
+	t.Run("dimension hint", func(t *testing.T) {
+		st, mediaTrack := newSubscribedTrackForTest(t)
+		mediaTrack.GetQualityForDimensionReturns(livekit.VideoQuality_LOW)
+
+		st.SetLayerCap(&types.SubscriptionLayerCap{
+			MaxSpatialLayer:  sfu.InvalidLayerSpatial,
+			MaxTemporalLayer: sfu.InvalidLayerTemporal,
+			Width:            160,
+			Height:           90,
+		})
+		requireMaxLayers(t, st, 0, sfu.DefaultMaxLayerTemporal)
+
+		width, height := mediaTrack.GetQualityForDimensionArgsForCall(0)
+		require.Equal(t, uint32(160), width)
+		require.Equal(t, uint32(90), height)
+	})
+
+	t.Run("higher subscriber settings apply right away", func(t *testing.T) {
+		st, _ := newSubscribedTrackForTest(t)
+		st.UpdateSubscriberSettings(&livekit.UpdateTrackSettings{Quality: livekit.VideoQuality_HIGH})
+		requireMaxLayers(t, st, 2, sfu.DefaultMaxLayerTemporal)
+
+		// lowering is debounced
+		st.UpdateSubscriberSettings(&livekit.UpdateTrackSettings{Quality: livekit.VideoQuality_LOW})
+		requireMaxLayers(t, st, 2, sfu.DefaultMaxLayerTemporal)
+		require.Eventually(t, func() bool {
+			return st.DownTrack().MaxLayers().Spatial() == 0
+		}, time.Second, 10*time.Millisecond)
+
+		st.UpdateSubscriberSettings(&livekit.UpdateTrackSettings{Quality: livekit.VideoQuality_MEDIUM})
+		requireMaxLayers(t, st, 1, sfu.DefaultMaxLayerTemporal)
+	})
+}
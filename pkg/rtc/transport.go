@@ -1,19 +1,22 @@
 package rtc
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/bep/debounce"
 	"github.com/go-logr/logr"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"github.com/pion/interceptor"
 	"github.com/pion/interceptor/pkg/cc"
-	"github.com/pion/interceptor/pkg/gcc"
 	"github.com/pion/interceptor/pkg/twcc"
+	"github.com/pion/rtcp"
 	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
 
@@ -21,13 +24,16 @@ import (
 	serverlogger "github.com/livekit/livekit-server/pkg/logger"
 	"github.com/livekit/livekit-server/pkg/rtc/types"
 	"github.com/livekit/livekit-server/pkg/sfu"
+	"github.com/livekit/livekit-server/pkg/sfu/sendsidebwe"
 	"github.com/livekit/livekit-server/pkg/telemetry"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
 const (
-	negotiationFrequency       = 150 * time.Millisecond
+	defaultNegotiationDebounce = 150 * time.Millisecond
 	dtlsRetransmissionInterval = 100 * time.Millisecond
+	defaultNegotiationTimeout  = 10 * time.Second
+	maxPendingCandidates       = 50
 )
 
 const (
@@ -45,22 +51,76 @@ type SimulcastTrackInfo struct {
 
 // PCTransport is a wrapper around PeerConnection, with some helper methods
 type PCTransport struct {
-	pc *webrtc.PeerConnection
-	me *webrtc.MediaEngine
+	pc  *webrtc.PeerConnection
+	me  *webrtc.MediaEngine
+	api *webrtc.API
 
 	lock                  sync.Mutex
+	closeOnce             sync.Once
 	pendingCandidates     []webrtc.ICECandidateInit
 	debouncedNegotiate    func(func())
 	onOffer               func(offer webrtc.SessionDescription)
 	restartAfterGathering bool
 	negotiationState      int
+	// an ICE restart was requested while waiting for the client's answer
+	restartAfterNegotiation bool
+	// remote candidates are held until the client's ICE restart offer arrives
+	awaitingRestartOffer bool
+	// ICE username fragment of the current remote description, identifies the candidate generation
+	remoteUfrag string
 
 	// stream allocator for subscriber PC
 	streamAllocator *sfu.StreamAllocator
+	// paces what is sent on the subscriber PC, nil when not enabled
+	pacer *sfu.Pacer
 
 	logger logger.Logger
 
 	previousAnswer *webrtc.SessionDescription
+
+	// offer created while no OnOffer handler was registered, delivered once one is set
+	pendingOffer *webrtc.SessionDescription
+
+	// watchdog for the client's answer, negotiationTimerID invalidates timers that fire after being replaced
+	negotiationTimeout  time.Duration
+	negotiationTimer    *time.Timer
+	negotiationTimerID  uint64
+	onNegotiationFailed func()
+
+	// sender-less transceivers are reused before new m-lines are added, preferring the codec last sent on the mid.
+	// With strict codec matching, a mid is only reused for a compatible codec
+	reuseTransceivers   bool
+	strictCodecMatching bool
+	transceiverCodecs   map[string]webrtc.RTPCodecCapability
+
+	// pion doesn't signal retransmission streams of senders, they're added to the descriptions sent
+	signalRTX bool
+
+	// advertised as the bandwidth (b=AS) of video sections in the descriptions sent, 0 for none
+	maxSubscribeBitrate uint64
+
+	// candidate types accepted from the remote peer, empty allows all
+	allowedCandidateTypes     []webrtc.ICECandidateType
+	onDisallowedCandidatePair func()
+
+	// deployment specific SDP rewrites, called with the lock held
+	onLocalDescriptionMunge  func(sd *webrtc.SessionDescription) error
+	onRemoteDescriptionMunge func(sd *webrtc.SessionDescription) error
+
+	// callers of NegotiateWithResult waiting for the next offer to be sent, and for the answer to the sent offer
+	pendingNegotiationWaiters  []chan error
+	inflightNegotiationWaiters []chan error
+
+	// stages of the first connection, reported once the PeerConnection first connects
+	joinTiming              telemetry.JoinTiming
+	onConnected             func(timing telemetry.JoinTiming)
+	onConnectionStateChange func(state webrtc.PeerConnectionState)
+
+	// ICE connection state and when it was entered, and when a pending ICE restart began
+	iceState          webrtc.ICEConnectionState
+	iceStateAt        time.Time
+	iceRestartAt      time.Time
+	onConnectionEvent func(event *telemetry.ConnectionEvent)
 }
 
 type TransportParams struct {
@@ -70,13 +130,15 @@ type TransportParams struct {
 	Target                  livekit.SignalTarget
 	Config                  *WebRTCConfig
 	CongestionControlConfig config.CongestionControlConfig
+	PacerConfig             config.PacerConfig
 	Telemetry               telemetry.TelemetryService
 	EnabledCodecs           []*livekit.Codec
 	Logger                  logger.Logger
 	SimTracks               map[uint32]SimulcastTrackInfo
+	NegotiationConfig       config.NegotiationConfig
 }
 
-func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimator cc.BandwidthEstimator)) (*webrtc.PeerConnection, *webrtc.MediaEngine, error) {
+func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimator cc.BandwidthEstimator)) (*webrtc.PeerConnection, *webrtc.MediaEngine, *webrtc.API, error) {
 	var directionConfig DirectionConfig
 	if params.Target == livekit.SignalTarget_PUBLISHER {
 		directionConfig = params.Config.Publisher
@@ -85,7 +147,7 @@ func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimat
 	}
 	me, err := createMediaEngine(params.EnabledCodecs, directionConfig)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	se := params.Config.SettingEngine
@@ -137,10 +199,11 @@ func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimat
 
 		if isSendSideBWE {
 			gf, err := cc.NewInterceptor(func() (cc.BandwidthEstimator, error) {
-				return gcc.NewSendSideBWE(
-					gcc.SendSideBWEInitialBitrate(1*1000*1000),
-					gcc.SendSideBWEPacer(gcc.NewNoOpPacer()),
-				)
+				return sendsidebwe.NewSendSideBWE(sendsidebwe.SendSideBWEParams{
+					Logger:         params.Logger,
+					InitialBitrate: 1 * 1000 * 1000,
+					OnEstimate:     sendSideBWEMetricsUpdater(),
+				}), nil
 			})
 			if err == nil {
 				gf.OnNewPeerConnection(func(id string, estimator cc.BandwidthEstimator) {
@@ -157,6 +220,9 @@ func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimat
 			}
 		}
 	}
+	if err := registerInterceptors(ir, directionConfig.Interceptors); err != nil {
+		return nil, nil, nil, err
+	}
 	if len(params.SimTracks) > 0 {
 		f, err := NewUnhandleSimulcastInterceptorFactory(UnhandleSimulcastTracks(params.SimTracks))
 		if err != nil {
@@ -170,13 +236,44 @@ func newPeerConnection(params TransportParams, onBandwidthEstimator func(estimat
 		webrtc.WithSettingEngine(se),
 		webrtc.WithInterceptorRegistry(ir),
 	)
-	pc, err := api.NewPeerConnection(params.Config.Configuration)
-	return pc, me, err
+	configuration := params.Config.Configuration
+	if len(configuration.Certificates) > 0 && time.Now().After(configuration.Certificates[0].Expires()) {
+		// pion refuses expired certificates, fall back to one generated for this connection
+		params.Logger.Warnw("shared DTLS certificate expired, generating a new one for the connection", nil,
+			"expiredAt", configuration.Certificates[0].Expires())
+		configuration.Certificates = nil
+	}
+	pc, err := api.NewPeerConnection(configuration)
+	return pc, me, api, err
+}
+
+// sendSideBWEMetricsUpdater returns an estimate callback that tracks a connection's contribution to node wide gauges
+func sendSideBWEMetricsUpdater() func(estimate sendsidebwe.Estimate) {
+	var prev prometheus.BWEEstimate
+	return func(estimate sendsidebwe.Estimate) {
+		curr := prometheus.BWEEstimate{
+			Target:       estimate.Target,
+			DelayBased:   estimate.DelayBased,
+			LossBased:    estimate.LossBased,
+			Acknowledged: estimate.Acknowledged,
+		}
+		prometheus.UpdateSendSideBWE(prev, curr)
+		prev = curr
+	}
+}
+
+// pacerQueueDepthUpdater returns a queue depth callback that tracks a connection's contribution to the node wide gauge
+func pacerQueueDepthUpdater() func(depth int) {
+	prev := 0
+	return func(depth int) {
+		prometheus.UpdatePacerQueueDepth(prev, depth)
+		prev = depth
+	}
 }
 
 func NewPCTransport(params TransportParams) (*PCTransport, error) {
 	var bwe cc.BandwidthEstimator
-	pc, me, err := newPeerConnection(params, func(estimator cc.BandwidthEstimator) {
+	pc, me, api, err := newPeerConnection(params, func(estimator cc.BandwidthEstimator) {
 		bwe = estimator
 	})
 	if err != nil {
@@ -186,20 +283,91 @@ func NewPCTransport(params TransportParams) (*PCTransport, error) {
 	t := &PCTransport{
 		pc:                 pc,
 		me:                 me,
-		debouncedNegotiate: debounce.New(negotiationFrequency),
+		api:                api,
+		debouncedNegotiate: newNegotiationDebouncer(params.NegotiationConfig),
 		negotiationState:   negotiationStateNone,
 		logger:             params.Logger,
+		negotiationTimeout: params.Config.NegotiationTimeout,
+		reuseTransceivers:  params.ProtocolVersion.SupportsTransceiverReuse(),
+		transceiverCodecs:  make(map[string]webrtc.RTPCodecCapability),
+
+		allowedCandidateTypes: params.Config.ICECandidateTypes,
+		joinTiming:            telemetry.JoinTiming{Target: params.Target},
+		iceState:              webrtc.ICEConnectionStateNew,
+		iceStateAt:            time.Now(),
+	}
+	if t.negotiationTimeout == 0 {
+		t.negotiationTimeout = defaultNegotiationTimeout
+	}
+	if params.Target == livekit.SignalTarget_SUBSCRIBER {
+		t.strictCodecMatching = params.Config.Subscriber.StrictCodecMatching
+		t.signalRTX = params.Config.Subscriber.EnableRTX
 	}
 	if params.Target == livekit.SignalTarget_SUBSCRIBER {
 		t.streamAllocator = sfu.NewStreamAllocator(sfu.StreamAllocatorParams{
 			Config: params.CongestionControlConfig,
 			Logger: params.Logger,
 		})
-		t.streamAllocator.Start()
 		if bwe != nil {
 			t.streamAllocator.SetBandwidthEstimator(bwe)
 		}
+		if params.PacerConfig.Enabled {
+			t.pacer = sfu.NewPacer(sfu.PacerParams{
+				Config: params.PacerConfig,
+				Logger: params.Logger,
+			})
+			t.pacer.OnQueueDepth(pacerQueueDepthUpdater())
+			t.pacer.OnPacketSent(func(priority sfu.PacerPriority, delay time.Duration) {
+				prometheus.ObservePacerDelay(priority.String(), delay)
+			})
+			t.streamAllocator.SetPacer(t.pacer)
+			t.pacer.Start()
+		}
+		t.streamAllocator.Start()
 	}
+	t.pc.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
+		prev, prevAt := t.setICEState(state)
+		if state == webrtc.ICEConnectionStateNew || state == webrtc.ICEConnectionStateChecking {
+			return
+		}
+		info := t.GetICEConnectionInfo()
+		values := []interface{}{
+			"state", state.String(),
+			"dtlsState", info.DTLSState,
+			"bytesSent", info.BytesSent,
+			"bytesReceived", info.BytesReceived,
+		}
+		if info.Local != nil && info.Remote != nil {
+			values = append(values,
+				"localCandidate", info.Local.Type+" "+info.Local.Protocol+" "+info.Local.Address,
+				"remoteCandidate", info.Remote.Type+" "+info.Remote.Protocol+" "+info.Remote.Address,
+			)
+		}
+		params.Logger.Debugw("ICE connection state changed", values...)
+		t.reportICEConnectionState(prev, prevAt, state, info)
+		if state == webrtc.ICEConnectionStateConnected {
+			t.lock.Lock()
+			if t.joinTiming.ICEConnectedAt.IsZero() {
+				t.joinTiming.ICEConnectedAt = time.Now()
+				t.joinTiming.TURN = (info.Local != nil && info.Local.Type == webrtc.ICECandidateTypeRelay.String()) ||
+					(info.Remote != nil && info.Remote.Type == webrtc.ICECandidateTypeRelay.String())
+			}
+			t.lock.Unlock()
+		}
+
+		// candidates learned through connectivity checks bypass the trickle filter
+		if state == webrtc.ICEConnectionStateConnected && info.Remote != nil &&
+			!candidateTypeAllowed(t.allowedCandidateTypes, info.Remote.Type) {
+			params.Logger.Warnw("connected through disallowed candidate type", nil, "remoteCandidate", info.Remote.Type)
+			t.lock.Lock()
+			onDisallowedCandidatePair := t.onDisallowedCandidatePair
+			t.lock.Unlock()
+			if onDisallowedCandidatePair != nil {
+				onDisallowedCandidatePair()
+			}
+		}
+	})
+	t.pc.OnConnectionStateChange(t.handleConnectionStateChange)
 	t.pc.OnICEGatheringStateChange(func(state webrtc.ICEGathererState) {
 		if state == webrtc.ICEGathererStateComplete {
 			go func() {
@@ -215,14 +383,40 @@ func NewPCTransport(params TransportParams) (*PCTransport, error) {
 		}
 	})
 
+	prometheus.AddPeerConnection()
 	return t, nil
 }
 
 func (t *PCTransport) AddICECandidate(candidate webrtc.ICECandidateInit) error {
-	if t.pc.RemoteDescription() == nil {
-		t.lock.Lock()
+	if typ, ok := remoteCandidateType(candidate); ok && !candidateTypeAllowed(t.allowedCandidateTypes, typ) {
+		t.logger.Debugw("ignoring candidate of disallowed type", "candidate", candidate.Candidate)
+		return nil
+	}
+
+	t.lock.Lock()
+	if t.pc.RemoteDescription() == nil || t.awaitingRestartOffer {
+		defer t.lock.Unlock()
+		if t.awaitingRestartOffer && candidate.UsernameFragment != nil && *candidate.UsernameFragment == t.remoteUfrag {
+			// still trickling for the generation being restarted
+			return nil
+		}
+		for _, c := range t.pendingCandidates {
+			if c.Candidate == candidate.Candidate {
+				return nil
+			}
+		}
+		if len(t.pendingCandidates) >= maxPendingCandidates {
+			t.logger.Warnw("dropping ICE candidate, too many pending", nil, "candidate", candidate.Candidate)
+			return ErrTooManyPendingCandidates
+		}
 		t.pendingCandidates = append(t.pendingCandidates, candidate)
-		t.lock.Unlock()
+		return nil
+	}
+	stale := t.isStaleCandidate(candidate)
+	t.lock.Unlock()
+
+	if stale {
+		t.logger.Debugw("ignoring candidate from previous ICE generation", "candidate", candidate.Candidate)
 		return nil
 	}
 
@@ -236,9 +430,18 @@ func (t *PCTransport) PeerConnection() *webrtc.PeerConnection {
 }
 
 func (t *PCTransport) Close() {
+	t.closeOnce.Do(prometheus.SubPeerConnection)
 	if t.streamAllocator != nil {
 		t.streamAllocator.Stop()
 	}
+	if t.pacer != nil {
+		t.pacer.Stop()
+	}
+
+	t.lock.Lock()
+	t.clearNegotiationTimer()
+	t.resolveNegotiationWaiters(ErrTransportClosed)
+	t.lock.Unlock()
 
 	_ = t.pc.Close()
 }
@@ -247,15 +450,51 @@ func (t *PCTransport) SetRemoteDescription(sd webrtc.SessionDescription) error {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
+	if sd.Type == webrtc.SDPTypeAnswer && t.pc.SignalingState() != webrtc.SignalingStateHaveLocalOffer {
+		// i.e. a duplicate of an answer that has already been applied
+		return ErrStaleAnswer
+	}
+
+	if t.onRemoteDescriptionMunge != nil {
+		if err := t.onRemoteDescriptionMunge(&sd); err != nil {
+			// a remote offer is part of answering, a remote answer completes our offer
+			operation := "offer"
+			if sd.Type == webrtc.SDPTypeOffer {
+				operation = "answer"
+			}
+			prometheus.ServiceOperationCounter.WithLabelValues(operation, "error", "remote_munge").Add(1)
+			return fmt.Errorf("could not munge remote description: %w", err)
+		}
+	}
+
 	if err := t.pc.SetRemoteDescription(sd); err != nil {
 		return err
 	}
+	if sd.Type == webrtc.SDPTypeOffer {
+		t.markJoinStage(&t.joinTiming.OfferAt)
+	} else {
+		t.markJoinStage(&t.joinTiming.AnswerAt)
+	}
 
 	// negotiated, reset flag
 	lastState := t.negotiationState
 	t.negotiationState = negotiationStateNone
+	if sd.Type == webrtc.SDPTypeAnswer {
+		t.clearNegotiationTimer()
+		for _, ch := range t.inflightNegotiationWaiters {
+			ch <- nil
+		}
+		t.inflightNegotiationWaiters = nil
+	} else {
+		t.awaitingRestartOffer = false
+	}
+	t.remoteUfrag = getICEUfrag(sd)
 
 	for _, c := range t.pendingCandidates {
+		if t.isStaleCandidate(c) {
+			t.logger.Debugw("dropping candidate from previous ICE generation", "candidate", c.Candidate)
+			continue
+		}
 		if err := t.pc.AddICECandidate(c); err != nil {
 			return err
 		}
@@ -265,16 +504,482 @@ func (t *PCTransport) SetRemoteDescription(sd webrtc.SessionDescription) error {
 	// only initiate when we are the offerer
 	if lastState == negotiationRetry && sd.Type == webrtc.SDPTypeAnswer {
 		t.logger.Debugw("re-negotiate after answering")
-		if err := t.createAndSendOffer(nil); err != nil {
+		var options *webrtc.OfferOptions
+		if t.restartAfterNegotiation {
+			options = &webrtc.OfferOptions{ICERestart: true}
+		}
+		if err := t.createAndSendOffer(options); err != nil {
 			t.logger.Errorw("could not negotiate", err)
 		}
 	}
 	return nil
 }
 
-// OnOffer is called when the PeerConnection starts negotiation and prepares an offer
+// PrepareICERestart readies a transport that answers client offers for an ICE restart. Queued candidates from
+// the current generation are discarded, and new ones are held until the offer with the new credentials arrives
+func (t *PCTransport) PrepareICERestart() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.pendingCandidates = nil
+	t.awaitingRestartOffer = true
+	t.iceRestartAt = time.Now()
+}
+
+// OnOffer is called when the PeerConnection starts negotiation and prepares an offer.
+// An offer created before a handler is registered is delivered when the handler is set
 func (t *PCTransport) OnOffer(f func(sd webrtc.SessionDescription)) {
+	t.lock.Lock()
 	t.onOffer = f
+	pending := t.pendingOffer
+	if f != nil {
+		t.pendingOffer = nil
+	}
+	t.lock.Unlock()
+
+	if f != nil && pending != nil {
+		go f(*pending)
+	}
+}
+
+// OnLocalDescriptionMunge sets a hook to rewrite offers and answers before they are sent. pion only applies the
+// description it generated, so the rewritten one is only signalled: it may add or change what the remote peer is told,
+// i.e. bandwidth limits, but must keep what pion negotiates locally. Rewrites changing the media sections, their mids,
+// directions, payload types or header extensions, or the ICE and DTLS parameters fail with
+// ErrUnsupportedLocalDescriptionMunge. An error aborts the negotiation. The hook must not call back into the transport
+func (t *PCTransport) OnLocalDescriptionMunge(f func(sd *webrtc.SessionDescription) error) {
+	t.lock.Lock()
+	t.onLocalDescriptionMunge = f
+	t.lock.Unlock()
+}
+
+// OnRemoteDescriptionMunge sets a hook to rewrite the remote peer's descriptions before they are applied.
+// An error aborts the negotiation. The hook must not call back into the transport
+func (t *PCTransport) OnRemoteDescriptionMunge(f func(sd *webrtc.SessionDescription) error) {
+	t.lock.Lock()
+	t.onRemoteDescriptionMunge = f
+	t.lock.Unlock()
+}
+
+// CreateAnswer creates an answer to the remote offer, applies it as the local description and returns the
+// description to send
+func (t *PCTransport) CreateAnswer() (webrtc.SessionDescription, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	answer, err := t.pc.CreateAnswer(nil)
+	if err != nil {
+		prometheus.ServiceOperationCounter.WithLabelValues("answer", "error", "create").Add(1)
+		return answer, fmt.Errorf("could not create answer: %w", err)
+	}
+
+	munged, err := t.mungeLocalDescription(answer)
+	if err != nil {
+		prometheus.ServiceOperationCounter.WithLabelValues("answer", "error", "munge").Add(1)
+		return answer, fmt.Errorf("could not munge local description: %w", err)
+	}
+
+	if err = t.pc.SetLocalDescription(answer); err != nil {
+		prometheus.ServiceOperationCounter.WithLabelValues("answer", "error", "local_description").Add(1)
+		return answer, fmt.Errorf("could not set local description: %w", err)
+	}
+	t.markJoinStage(&t.joinTiming.AnswerAt)
+	return munged, nil
+}
+
+// returns the description to send to the client, assuming lock has been acquired.
+// pion only accepts unmodified local descriptions, so the hook rewrites a copy
+func (t *PCTransport) mungeLocalDescription(sd webrtc.SessionDescription) (webrtc.SessionDescription, error) {
+	if t.onLocalDescriptionMunge == nil && !t.signalRTX && t.maxSubscribeBitrate == 0 {
+		return sd, nil
+	}
+	munged := webrtc.SessionDescription{Type: sd.Type, SDP: sd.SDP}
+	if t.signalRTX {
+		if err := signalRTXStreams(&munged, t.rtxSSRCs()); err != nil {
+			return sd, err
+		}
+	}
+	if t.maxSubscribeBitrate != 0 {
+		if err := setVideoBandwidth(&munged, t.maxSubscribeBitrate); err != nil {
+			return sd, err
+		}
+	}
+	if t.onLocalDescriptionMunge != nil {
+		if err := t.onLocalDescriptionMunge(&munged); err != nil {
+			return sd, err
+		}
+		if err := checkLocalDescriptionMunge(sd, munged); err != nil {
+			return sd, err
+		}
+	}
+	return munged, nil
+}
+
+// attributes of a description that pion negotiates from the local description it applied
+var negotiatedAttributes = map[string]bool{
+	"mid":         true,
+	"sendrecv":    true,
+	"sendonly":    true,
+	"recvonly":    true,
+	"inactive":    true,
+	"rtpmap":      true,
+	"extmap":      true,
+	"ice-ufrag":   true,
+	"ice-pwd":     true,
+	"fingerprint": true,
+	"setup":       true,
+	"group":       true,
+}
+
+// checkLocalDescriptionMunge fails when a rewritten local description doesn't describe the session pion negotiates
+func checkLocalDescriptionMunge(original, munged webrtc.SessionDescription) error {
+	before, err := original.Unmarshal()
+	if err != nil {
+		return err
+	}
+	after, err := munged.Unmarshal()
+	if err != nil {
+		return err
+	}
+
+	if !equalNegotiatedAttributes(before.Attributes, after.Attributes) {
+		return fmt.Errorf("%w: session attributes changed", ErrUnsupportedLocalDescriptionMunge)
+	}
+	if len(before.MediaDescriptions) != len(after.MediaDescriptions) {
+		return fmt.Errorf("%w: media sections added or removed", ErrUnsupportedLocalDescriptionMunge)
+	}
+	for i, m := range before.MediaDescriptions {
+		mungedM := after.MediaDescriptions[i]
+		if m.MediaName.String() != mungedM.MediaName.String() ||
+			!equalNegotiatedAttributes(m.Attributes, mungedM.Attributes) {
+			return fmt.Errorf("%w: media section %d changed", ErrUnsupportedLocalDescriptionMunge, i)
+		}
+	}
+	return nil
+}
+
+func equalNegotiatedAttributes(before, after []sdp.Attribute) bool {
+	filter := func(attributes []sdp.Attribute) []string {
+		var negotiated []string
+		for _, a := range attributes {
+			if negotiatedAttributes[a.Key] {
+				negotiated = append(negotiated, a.String())
+			}
+		}
+		return negotiated
+	}
+	beforeNegotiated, afterNegotiated := filter(before), filter(after)
+	if len(beforeNegotiated) != len(afterNegotiated) {
+		return false
+	}
+	for i := range beforeNegotiated {
+		if beforeNegotiated[i] != afterNegotiated[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// returns the RTX SSRC of the video tracks being sent, keyed by their SSRC
+func (t *PCTransport) rtxSSRCs() map[uint32]uint32 {
+	rtxSSRCs := make(map[uint32]uint32)
+	for _, tr := range t.pc.GetTransceivers() {
+		sender := tr.Sender()
+		if sender == nil {
+			continue
+		}
+		dt, ok := sender.Track().(*sfu.DownTrack)
+		if !ok || dt.RTXSSRC() == 0 {
+			continue
+		}
+		for _, encoding := range sender.GetParameters().Encodings {
+			rtxSSRCs[uint32(encoding.SSRC)] = dt.RTXSSRC()
+		}
+	}
+	return rtxSSRCs
+}
+
+// signalRTXStreams declares the retransmission stream of each media source with an RTX SSRC, as a FID ssrc-group
+// (RFC 5576) with the attributes of the source
+func signalRTXStreams(sd *webrtc.SessionDescription, rtxSSRCs map[uint32]uint32) error {
+	if len(rtxSSRCs) == 0 {
+		return nil
+	}
+	parsed, err := sd.Unmarshal()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range parsed.MediaDescriptions {
+		if _, ok := m.Attribute(sdp.AttrKeySSRCGroup); ok {
+			// already grouped
+			continue
+		}
+
+		attrs := make([]sdp.Attribute, 0, len(m.Attributes))
+		var pending []sdp.Attribute
+		var pendingSSRC uint32
+		grouped := make(map[uint32]bool)
+		for _, attr := range m.Attributes {
+			ssrc, value, isSSRC := parseSSRCAttribute(attr)
+			if len(pending) != 0 && (!isSSRC || ssrc != pendingSSRC) {
+				attrs = append(attrs, pending...)
+				pending = nil
+			}
+			rtxSSRC, ok := rtxSSRCs[ssrc]
+			if !isSSRC || !ok {
+				attrs = append(attrs, attr)
+				continue
+			}
+
+			if !grouped[ssrc] {
+				grouped[ssrc] = true
+				attrs = append(attrs, sdp.NewAttribute(sdp.AttrKeySSRCGroup, fmt.Sprintf("%s %d %d", sdp.SemanticTokenFlowIdentification, ssrc, rtxSSRC)))
+			}
+			attrs = append(attrs, attr)
+			pending = append(pending, sdp.NewAttribute(sdp.AttrKeySSRC, fmt.Sprintf("%d %s", rtxSSRC, value)))
+			pendingSSRC = ssrc
+		}
+		m.Attributes = append(attrs, pending...)
+	}
+
+	bytes, err := parsed.Marshal()
+	if err != nil {
+		return err
+	}
+	sd.SDP = string(bytes)
+	return nil
+}
+
+// splits an "a=ssrc:<ssrc> <attribute>" line
+func parseSSRCAttribute(attr sdp.Attribute) (uint32, string, bool) {
+	if attr.Key != sdp.AttrKeySSRC {
+		return 0, "", false
+	}
+	parts := strings.SplitN(attr.Value, " ", 2)
+	if len(parts) != 2 {
+		return 0, "", false
+	}
+	ssrc, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, "", false
+	}
+	return uint32(ssrc), parts[1], true
+}
+
+// DebugInfo reports the signaling state and, for subscriber transports, the stream allocator's channel capacity and
+// probe state as well as the pacer queues
+func (t *PCTransport) DebugInfo() map[string]interface{} {
+	info := map[string]interface{}{
+		"SignalingState": t.pc.SignalingState().String(),
+	}
+
+	if t.streamAllocator != nil {
+		info["StreamAllocator"] = t.streamAllocator.DebugInfo()
+	}
+	if t.pacer != nil {
+		info["Pacer"] = t.pacer.DebugInfo()
+	}
+	return info
+}
+
+// WriteRTCP sends RTCP on the peer connection, ahead of media waiting in the pacer
+func (t *PCTransport) WriteRTCP(pkts []rtcp.Packet) error {
+	if t.pacer == nil {
+		return t.pc.WriteRTCP(pkts)
+	}
+
+	raw, err := rtcp.Marshal(pkts)
+	if err != nil {
+		return err
+	}
+
+	// callers reuse the slice once this returns
+	paced := append([]rtcp.Packet{}, pkts...)
+	return t.pacer.Enqueue(sfu.PacerPriorityRTCP, len(raw), func() {
+		if err := t.pc.WriteRTCP(paced); err != nil && err != io.EOF && err != io.ErrClosedPipe {
+			t.logger.Warnw("could not write paced rtcp", err)
+		}
+	})
+}
+
+// SetMaxSubscribeBitrate caps the bitrate sent on a subscriber transport, 0 removes the cap. Tracks are
+// re-allocated right away, the bandwidth advertised to the client is updated with the next offer
+func (t *PCTransport) SetMaxSubscribeBitrate(bps uint64) {
+	if t.streamAllocator == nil {
+		return
+	}
+
+	t.lock.Lock()
+	t.maxSubscribeBitrate = bps
+	t.lock.Unlock()
+
+	t.streamAllocator.SetMaxChannelCapacity(int64(bps))
+}
+
+// setVideoBandwidth sets the application specific maximum (b=AS, in kbps) of video sections
+func setVideoBandwidth(sd *webrtc.SessionDescription, bps uint64) error {
+	parsed, err := sd.Unmarshal()
+	if err != nil {
+		return err
+	}
+
+	kbps := (bps + 999) / 1000
+	for _, m := range parsed.MediaDescriptions {
+		if m.MediaName.Media != "video" {
+			continue
+		}
+
+		bandwidth := make([]sdp.Bandwidth, 0, len(m.Bandwidth)+1)
+		for _, b := range m.Bandwidth {
+			if b.Type != "AS" {
+				bandwidth = append(bandwidth, b)
+			}
+		}
+		m.Bandwidth = append(bandwidth, sdp.Bandwidth{Type: "AS", Bandwidth: kbps})
+	}
+
+	bytes, err := parsed.Marshal()
+	if err != nil {
+		return err
+	}
+	sd.SDP = string(bytes)
+	return nil
+}
+
+// CreateDataChannel creates a data channel on the peer connection. When a session offered by this side has no
+// SCTP association yet, it's renegotiated so that the channel can open
+func (t *PCTransport) CreateDataChannel(label string, opts *webrtc.DataChannelInit) (*webrtc.DataChannel, error) {
+	dc, err := t.pc.CreateDataChannel(label, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	t.lock.Lock()
+	local := t.pc.CurrentLocalDescription()
+	needsNegotiation := local != nil && local.Type == webrtc.SDPTypeOffer && !hasDataSection(local)
+	t.lock.Unlock()
+	if needsNegotiation {
+		t.logger.Debugw("negotiating SCTP association for data channel", "label", label)
+		t.Negotiate()
+	}
+	return dc, nil
+}
+
+// OnDisallowedCandidatePair is called when ICE connects through a remote candidate type that isn't allowed
+func (t *PCTransport) OnDisallowedCandidatePair(f func()) {
+	t.lock.Lock()
+	t.onDisallowedCandidatePair = f
+	t.lock.Unlock()
+}
+
+// OnConnectionStateChange is called with the state of the PeerConnection as it changes
+func (t *PCTransport) OnConnectionStateChange(f func(state webrtc.PeerConnectionState)) {
+	t.lock.Lock()
+	t.onConnectionStateChange = f
+	t.lock.Unlock()
+}
+
+// OnConnected is called the first time the PeerConnection connects, with the time each stage took
+func (t *PCTransport) OnConnected(f func(timing telemetry.JoinTiming)) {
+	t.lock.Lock()
+	t.onConnected = f
+	t.lock.Unlock()
+}
+
+func (t *PCTransport) handleConnectionStateChange(state webrtc.PeerConnectionState) {
+	t.lock.Lock()
+	onConnectionStateChange := t.onConnectionStateChange
+	var onConnected func(timing telemetry.JoinTiming)
+	if state == webrtc.PeerConnectionStateConnected && t.joinTiming.DTLSConnectedAt.IsZero() {
+		t.joinTiming.DTLSConnectedAt = time.Now()
+		onConnected = t.onConnected
+	}
+	timing := t.joinTiming
+	t.lock.Unlock()
+
+	if onConnectionStateChange != nil {
+		onConnectionStateChange(state)
+	}
+	if onConnected != nil {
+		onConnected(timing)
+	}
+}
+
+// OnConnectionEvent is called when the ICE connection connects, disconnects or fails, and when an ICE restart
+// completes
+func (t *PCTransport) OnConnectionEvent(f func(event *telemetry.ConnectionEvent)) {
+	t.lock.Lock()
+	t.onConnectionEvent = f
+	t.lock.Unlock()
+}
+
+// ICEConnectionState returns the ICE connection state, and when it was entered
+func (t *PCTransport) ICEConnectionState() (webrtc.ICEConnectionState, time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.iceState, t.iceStateAt
+}
+
+// setICEState records the new ICE connection state, returning the previous one and when it was entered
+func (t *PCTransport) setICEState(state webrtc.ICEConnectionState) (webrtc.ICEConnectionState, time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	prev, prevAt := t.iceState, t.iceStateAt
+	t.iceState, t.iceStateAt = state, time.Now()
+	return prev, prevAt
+}
+
+func (t *PCTransport) reportICEConnectionState(prev webrtc.ICEConnectionState, prevAt time.Time, state webrtc.ICEConnectionState,
+	info *ICEConnectionInfo) {
+
+	event := &telemetry.ConnectionEvent{
+		Target:    t.joinTiming.Target,
+		PrevState: prev.String(),
+		State:     state.String(),
+		Local:     info.Local.toTelemetry(),
+		Remote:    info.Remote.toTelemetry(),
+	}
+	if !prevAt.IsZero() {
+		event.Duration = time.Since(prevAt)
+	}
+
+	t.lock.Lock()
+	switch state {
+	case webrtc.ICEConnectionStateConnected:
+		event.Type = telemetry.ConnectionEventICEConnected
+		if !t.iceRestartAt.IsZero() {
+			event.Type = telemetry.ConnectionEventICERestarted
+			event.Duration = time.Since(t.iceRestartAt)
+			t.iceRestartAt = time.Time{}
+		}
+	case webrtc.ICEConnectionStateDisconnected:
+		event.Type = telemetry.ConnectionEventICEDisconnected
+	case webrtc.ICEConnectionStateFailed:
+		event.Type = telemetry.ConnectionEventICEFailed
+	}
+	onConnectionEvent := t.onConnectionEvent
+	t.lock.Unlock()
+
+	if event.Type != "" && onConnectionEvent != nil {
+		onConnectionEvent(event)
+	}
+}
+
+// records the first time of a stage of connecting, assuming lock has been acquired
+func (t *PCTransport) markJoinStage(at *time.Time) {
+	if at.IsZero() && t.joinTiming.DTLSConnectedAt.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// OnNegotiationFailed is called when the client does not answer an offer within the negotiation timeout
+func (t *PCTransport) OnNegotiationFailed(f func()) {
+	t.lock.Lock()
+	t.onNegotiationFailed = f
+	t.lock.Unlock()
 }
 
 func (t *PCTransport) Negotiate() {
@@ -285,6 +990,58 @@ func (t *PCTransport) Negotiate() {
 	})
 }
 
+// NegotiateWithResult negotiates like Negotiate, the returned channel receives nil once the client's answer
+// has been applied. Callers within the same debounce window share a single offer. It receives an error if the
+// offer could not be sent, the client doesn't answer in time, the transport closes, or ctx is done
+func (t *PCTransport) NegotiateWithResult(ctx context.Context) <-chan error {
+	ch := make(chan error, 1)
+	t.lock.Lock()
+	t.pendingNegotiationWaiters = append(t.pendingNegotiationWaiters, ch)
+	t.lock.Unlock()
+
+	t.Negotiate()
+
+	result := make(chan error, 1)
+	go func() {
+		select {
+		case err := <-ch:
+			result <- err
+		case <-ctx.Done():
+			t.removeNegotiationWaiter(ch)
+			result <- ctx.Err()
+		}
+	}()
+	return result
+}
+
+func (t *PCTransport) removeNegotiationWaiter(ch chan error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	remove := func(waiters []chan error) []chan error {
+		for i, w := range waiters {
+			if w == ch {
+				return append(waiters[:i], waiters[i+1:]...)
+			}
+		}
+		return waiters
+	}
+	t.pendingNegotiationWaiters = remove(t.pendingNegotiationWaiters)
+	t.inflightNegotiationWaiters = remove(t.inflightNegotiationWaiters)
+}
+
+// fails every caller waiting on a negotiation, assuming lock has been acquired
+func (t *PCTransport) resolveNegotiationWaiters(err error) {
+	for _, ch := range t.inflightNegotiationWaiters {
+		ch <- err
+	}
+	for _, ch := range t.pendingNegotiationWaiters {
+		ch <- err
+	}
+	t.inflightNegotiationWaiters = nil
+	t.pendingNegotiationWaiters = nil
+}
+
 func (t *PCTransport) CreateAndSendOffer(options *webrtc.OfferOptions) error {
 	t.lock.Lock()
 	defer t.lock.Unlock()
@@ -292,13 +1049,20 @@ func (t *PCTransport) CreateAndSendOffer(options *webrtc.OfferOptions) error {
 }
 
 // creates and sends offer assuming lock has been acquired
-func (t *PCTransport) createAndSendOffer(options *webrtc.OfferOptions) error {
-	if t.onOffer == nil {
-		return nil
-	}
+func (t *PCTransport) createAndSendOffer(options *webrtc.OfferOptions) (err error) {
 	if t.pc.ConnectionState() == webrtc.PeerConnectionStateClosed {
+		t.resolveNegotiationWaiters(ErrTransportClosed)
 		return nil
 	}
+	defer func() {
+		if err != nil {
+			// the offer was not sent, changes requested since the last offer failed to negotiate
+			for _, ch := range t.pendingNegotiationWaiters {
+				ch <- err
+			}
+			t.pendingNegotiationWaiters = nil
+		}
+	}()
 
 	iceRestart := options != nil && options.ICERestart
 
@@ -310,6 +1074,7 @@ func (t *PCTransport) createAndSendOffer(options *webrtc.OfferOptions) error {
 			return nil
 		}
 		t.logger.Debugw("restarting ICE")
+		t.iceRestartAt = time.Now()
 	}
 
 	// when there's an ongoing negotiation, let it finish and not disrupt its state
@@ -324,13 +1089,24 @@ func (t *PCTransport) createAndSendOffer(options *webrtc.OfferOptions) error {
 		} else {
 			t.logger.Debugw("skipping negotiation, trying again later")
 			t.negotiationState = negotiationRetry
+			if iceRestart {
+				t.restartAfterNegotiation = true
+			}
 			return nil
 		}
 	} else if t.negotiationState == negotiationRetry {
 		// already set to retry, we can safely skip this attempt
+		if iceRestart {
+			t.restartAfterNegotiation = true
+		}
 		return nil
 	}
 
+	if iceRestart {
+		// candidates queued for the previous ICE generation are no longer valid
+		t.pendingCandidates = nil
+	}
+
 	if t.previousAnswer != nil {
 		t.previousAnswer = nil
 		if options == nil {
@@ -346,21 +1122,234 @@ func (t *PCTransport) createAndSendOffer(options *webrtc.OfferOptions) error {
 		return err
 	}
 
+	munged, err := t.mungeLocalDescription(offer)
+	if err != nil {
+		prometheus.ServiceOperationCounter.WithLabelValues("offer", "error", "munge").Add(1)
+		t.logger.Errorw("could not munge local description", err)
+		return err
+	}
+
 	err = t.pc.SetLocalDescription(offer)
 	if err != nil {
 		prometheus.ServiceOperationCounter.WithLabelValues("offer", "error", "local_description").Add(1)
 		t.logger.Errorw("could not set local description", err)
 		return err
 	}
+	offer = munged
+
+	t.reclaimTransceivers()
 
 	// indicate waiting for client
 	t.negotiationState = negotiationStateClient
 	t.restartAfterGathering = false
+	if options != nil && options.ICERestart {
+		t.restartAfterNegotiation = false
+	}
+	t.startNegotiationTimer()
+	t.markJoinStage(&t.joinTiming.OfferAt)
+	t.inflightNegotiationWaiters = append(t.inflightNegotiationWaiters, t.pendingNegotiationWaiters...)
+	t.pendingNegotiationWaiters = nil
 
+	if t.onOffer == nil {
+		t.pendingOffer = &offer
+		return nil
+	}
+	t.pendingOffer = nil
 	go t.onOffer(offer)
 	return nil
 }
 
+// GetTransceiverForSending returns a transceiver sending the track. When the client supports reuse, an inactive
+// m-line of the same kind is taken over before a new one is added, preferring one that last carried the same codec
+func (t *PCTransport) GetTransceiverForSending(track webrtc.TrackLocal) (*webrtc.RTPTransceiver, *webrtc.RTPSender, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.reuseTransceivers {
+		transceiver, err := t.pc.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{
+			Direction: webrtc.RTPTransceiverDirectionSendonly,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		sender := transceiver.Sender()
+		if sender == nil {
+			return nil, nil, errors.New("cannot subscribe without a sender in place")
+		}
+		if err := preferTrackCodec(transceiver, track); err != nil {
+			return nil, nil, err
+		}
+		return transceiver, sender, nil
+	}
+
+	if t.pc.ConnectionState() == webrtc.PeerConnectionStateClosed {
+		return nil, nil, webrtc.ErrConnectionClosed
+	}
+	codec, hasCodec := trackCodec(track)
+	for _, tr := range t.pc.GetTransceivers() {
+		if !isReusableTransceiver(tr, track.Kind()) {
+			continue
+		}
+		if previous, ok := t.transceiverCodecs[tr.Mid()]; !hasCodec || !ok || !sfu.CodecsCompatible(codec, previous) {
+			continue
+		}
+		// pion's AddTrack would take the first unused transceiver of the kind, whatever codec it last carried. Senders
+		// are only attached under t.lock, and RemoveTrack only detaches them, so the transceiver can't be taken
+		// from under us
+		sender, err := t.api.NewRTPSender(track, t.pc.SCTP().Transport())
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := tr.SetSender(sender, track); err != nil {
+			// the transceiver keeps the stopped sender and won't be offered for reuse again
+			_ = sender.Stop()
+			return nil, nil, err
+		}
+		if err := preferTrackCodec(tr, track); err != nil {
+			return nil, nil, err
+		}
+		// the m-line goes from inactive to sending, which only takes effect with a new offer. The offer is created
+		// on another goroutine, once t.lock is released
+		t.Negotiate()
+		return tr, sender, nil
+	}
+
+	var transceiver *webrtc.RTPTransceiver
+	if t.strictCodecMatching {
+		// AddTrack would take over an m-line negotiated for another codec
+		var err error
+		transceiver, err = t.pc.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{
+			Direction: webrtc.RTPTransceiverDirectionSendonly,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		// AddTrack re-uses any unused transceiver of the same kind, or creates a new one
+		sender, err := t.pc.AddTrack(track)
+		if err != nil {
+			return nil, nil, err
+		}
+		// as there is no way to get transceiver from sender, search
+		for _, tr := range t.pc.GetTransceivers() {
+			if tr.Sender() == sender {
+				transceiver = tr
+				break
+			}
+		}
+		if transceiver == nil {
+			return nil, nil, errors.New("cannot subscribe without a transceiver in place")
+		}
+	}
+	if transceiver.Mid() != "" && hasCodec {
+		t.transceiverCodecs[transceiver.Mid()] = codec
+	}
+	if err := preferTrackCodec(transceiver, track); err != nil {
+		return nil, nil, err
+	}
+	return transceiver, transceiver.Sender(), nil
+}
+
+// isReusableTransceiver returns true for a negotiated transceiver of the kind whose track was removed. Its direction
+// is then inactive, or recvonly for the sendrecv transceivers AddTrack creates
+func isReusableTransceiver(tr *webrtc.RTPTransceiver, kind webrtc.RTPCodecType) bool {
+	if tr.Sender() != nil || tr.Kind() != kind || tr.Mid() == "" {
+		return false
+	}
+	direction := tr.Direction()
+	return direction == webrtc.RTPTransceiverDirectionInactive || direction == webrtc.RTPTransceiverDirectionRecvonly
+}
+
+// preferTrackCodec offers opus with the parameters of the track, the media engine's opus fmtp would otherwise drop
+// stereo and DTX negotiated with the publisher. Opus parameters don't change the bitstream, so m-lines are reused
+// regardless of them
+func preferTrackCodec(transceiver *webrtc.RTPTransceiver, track webrtc.TrackLocal) error {
+	codec, ok := trackCodec(track)
+	if !ok || !strings.EqualFold(codec.MimeType, webrtc.MimeTypeOpus) {
+		return nil
+	}
+	return transceiver.SetCodecPreferences([]webrtc.RTPCodecParameters{{RTPCodecCapability: codec}})
+}
+
+// CleanupTransceivers drops codec bookkeeping for mids that are no longer sending
+func (t *PCTransport) CleanupTransceivers() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.reclaimTransceivers()
+}
+
+// records the codec sent on each mid and forgets mids that are gone, assuming lock has been acquired.
+// codecs of inactive mids are kept so the m-line can be matched when reused
+func (t *PCTransport) reclaimTransceivers() {
+	present := make(map[string]bool)
+	for _, tr := range t.pc.GetTransceivers() {
+		mid := tr.Mid()
+		if mid == "" {
+			continue
+		}
+		present[mid] = true
+		if sender := tr.Sender(); sender != nil && sender.Track() != nil {
+			if codec, ok := trackCodec(sender.Track()); ok {
+				t.transceiverCodecs[mid] = codec
+			}
+		}
+	}
+	for mid := range t.transceiverCodecs {
+		if !present[mid] {
+			delete(t.transceiverCodecs, mid)
+		}
+	}
+}
+
+// implemented by DownTrack and pion's static local tracks
+type codecTrack interface {
+	Codec() webrtc.RTPCodecCapability
+}
+
+func trackCodec(track webrtc.TrackLocal) (webrtc.RTPCodecCapability, bool) {
+	if ct, ok := track.(codecTrack); ok && ct.Codec().MimeType != "" {
+		return ct.Codec(), true
+	}
+	return webrtc.RTPCodecCapability{}, false
+}
+
+// starts or restarts the answer watchdog, assuming lock has been acquired
+func (t *PCTransport) startNegotiationTimer() {
+	t.clearNegotiationTimer()
+	id := t.negotiationTimerID
+	t.negotiationTimer = time.AfterFunc(t.negotiationTimeout, func() {
+		t.handleNegotiationTimeout(id)
+	})
+}
+
+// assumes lock has been acquired
+func (t *PCTransport) clearNegotiationTimer() {
+	if t.negotiationTimer != nil {
+		t.negotiationTimer.Stop()
+		t.negotiationTimer = nil
+	}
+	t.negotiationTimerID++
+}
+
+func (t *PCTransport) handleNegotiationTimeout(id uint64) {
+	t.lock.Lock()
+	if id != t.negotiationTimerID || t.negotiationState == negotiationStateNone {
+		t.lock.Unlock()
+		return
+	}
+	t.negotiationTimer = nil
+	onNegotiationFailed := t.onNegotiationFailed
+	t.resolveNegotiationWaiters(ErrNegotiationTimeout)
+	t.lock.Unlock()
+
+	t.logger.Infow("negotiation timed out, client did not answer", "timeout", t.negotiationTimeout)
+	prometheus.ServiceOperationCounter.WithLabelValues("offer", "error", "timeout").Add(1)
+	if onNegotiationFailed != nil {
+		onNegotiationFailed()
+	}
+}
+
 func (t *PCTransport) preparePC(previousAnswer webrtc.SessionDescription) error {
 	// sticky data channel to first m-lines, if someday we don't send sdp without media streams to
 	// client's subscribe pc after joining, should change this step
@@ -482,6 +1471,7 @@ func (t *PCTransport) AddTrack(subTrack types.SubscribedTrack) {
 		return
 	}
 
+	subTrack.DownTrack().SetPacer(t.pacer)
 	t.streamAllocator.AddTrack(subTrack.DownTrack(), sfu.AddTrackParams{
 		Source:      subTrack.MediaTrack().Source(),
 		IsSimulcast: subTrack.MediaTrack().IsSimulcast(),
@@ -490,6 +1480,8 @@ func (t *PCTransport) AddTrack(subTrack types.SubscribedTrack) {
 }
 
 func (t *PCTransport) RemoveTrack(subTrack types.SubscribedTrack) {
+	t.CleanupTransceivers()
+
 	if t.streamAllocator == nil {
 		return
 	}
@@ -506,6 +1498,31 @@ func (t *PCTransport) SetPreviousAnswer(answer *webrtc.SessionDescription) {
 	}
 }
 
+// candidates that carry a username fragment are matched against the current remote description,
+// assuming lock has been acquired
+func (t *PCTransport) isStaleCandidate(candidate webrtc.ICECandidateInit) bool {
+	if candidate.UsernameFragment == nil || *candidate.UsernameFragment == "" || t.remoteUfrag == "" {
+		return false
+	}
+	return *candidate.UsernameFragment != t.remoteUfrag
+}
+
+func getICEUfrag(sd webrtc.SessionDescription) string {
+	parsed, err := sd.Unmarshal()
+	if err != nil {
+		return ""
+	}
+	if ufrag, ok := parsed.Attribute("ice-ufrag"); ok {
+		return ufrag
+	}
+	for _, m := range parsed.MediaDescriptions {
+		if ufrag, ok := m.Attribute("ice-ufrag"); ok {
+			return ufrag
+		}
+	}
+	return ""
+}
+
 func getMidValue(media *sdp.MediaDescription) string {
 	for _, attr := range media.Attributes {
 		if attr.Key == "mid" {
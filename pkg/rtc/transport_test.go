@@ -1,14 +1,24 @@
 package rtc
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/livekit/protocol/livekit"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/telemetry"
 	"github.com/livekit/livekit-server/pkg/testutils"
 )
 
@@ -124,6 +134,1367 @@ func TestNegotiationTiming(t *testing.T) {
 	require.False(t, offer2 == actualOffer)
 }
 
+func TestOnOfferRegistration(t *testing.T) {
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{},
+	}
+
+	t.Run("registering while negotiating", func(t *testing.T) {
+		transport, err := NewPCTransport(params)
+		require.NoError(t, err)
+		defer transport.Close()
+		_, err = transport.pc.CreateDataChannel("test", nil)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				transport.Negotiate()
+				time.Sleep(time.Millisecond)
+			}
+		}()
+		received := atomic.Bool{}
+		go func() {
+			defer wg.Done()
+			transport.OnOffer(func(sd webrtc.SessionDescription) {
+				received.Store(true)
+			})
+		}()
+		wg.Wait()
+
+		testutils.WithTimeout(t, func() string {
+			if !received.Load() {
+				return "offer was not delivered"
+			}
+			return ""
+		})
+	})
+
+	t.Run("offer created before registration is delivered", func(t *testing.T) {
+		transport, err := NewPCTransport(params)
+		require.NoError(t, err)
+		defer transport.Close()
+		_, err = transport.pc.CreateDataChannel("test", nil)
+		require.NoError(t, err)
+
+		require.NoError(t, transport.CreateAndSendOffer(nil))
+		require.Equal(t, negotiationStateClient, transport.negotiationState)
+
+		offers := make(chan webrtc.SessionDescription, 1)
+		transport.OnOffer(func(sd webrtc.SessionDescription) {
+			offers <- sd
+		})
+		select {
+		case sd := <-offers:
+			require.Equal(t, webrtc.SDPTypeOffer, sd.Type)
+		case <-time.After(time.Second):
+			t.Fatal("pending offer was not delivered")
+		}
+	})
+}
+
+func TestNegotiationTimeout(t *testing.T) {
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{NegotiationTimeout: 200 * time.Millisecond},
+	}
+	newTransports := func(t *testing.T) (*PCTransport, *PCTransport, *atomic.Int32) {
+		transportA, err := NewPCTransport(params)
+		require.NoError(t, err)
+		_, err = transportA.pc.CreateDataChannel("test", nil)
+		require.NoError(t, err)
+		transportB, err := NewPCTransport(params)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			transportA.Close()
+			transportB.Close()
+		})
+
+		failed := &atomic.Int32{}
+		transportA.OnNegotiationFailed(func() {
+			failed.Inc()
+		})
+		return transportA, transportB, failed
+	}
+	answer := func(t *testing.T, b *PCTransport, offer webrtc.SessionDescription) webrtc.SessionDescription {
+		require.NoError(t, b.SetRemoteDescription(offer))
+		sd, err := b.pc.CreateAnswer(nil)
+		require.NoError(t, err)
+		require.NoError(t, b.pc.SetLocalDescription(sd))
+		return sd
+	}
+
+	t.Run("answer in time", func(t *testing.T) {
+		transportA, transportB, failed := newTransports(t)
+		offers := make(chan webrtc.SessionDescription, 1)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+
+		require.NoError(t, transportA.CreateAndSendOffer(nil))
+		require.NoError(t, transportA.SetRemoteDescription(answer(t, transportB, <-offers)))
+
+		time.Sleep(400 * time.Millisecond)
+		require.Equal(t, int32(0), failed.Load())
+	})
+
+	t.Run("never answers", func(t *testing.T) {
+		transportA, _, failed := newTransports(t)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) {})
+
+		require.NoError(t, transportA.CreateAndSendOffer(nil))
+		testutils.WithTimeout(t, func() string {
+			if failed.Load() != 1 {
+				return "negotiation did not fail"
+			}
+			return ""
+		})
+	})
+
+	t.Run("answers late", func(t *testing.T) {
+		transportA, transportB, failed := newTransports(t)
+		offers := make(chan webrtc.SessionDescription, 1)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+
+		require.NoError(t, transportA.CreateAndSendOffer(nil))
+		offer := <-offers
+		time.Sleep(300 * time.Millisecond)
+		require.Equal(t, int32(1), failed.Load())
+
+		// a late answer is still applied
+		require.NoError(t, transportA.SetRemoteDescription(answer(t, transportB, offer)))
+		require.Equal(t, negotiationStateNone, transportA.negotiationState)
+	})
+
+	t.Run("retry resets the timer", func(t *testing.T) {
+		transportA, transportB, failed := newTransports(t)
+		offers := make(chan webrtc.SessionDescription, 2)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+
+		require.NoError(t, transportA.CreateAndSendOffer(nil))
+		// queued while waiting for the answer
+		require.NoError(t, transportA.CreateAndSendOffer(nil))
+		require.Equal(t, negotiationRetry, transportA.negotiationState)
+
+		time.Sleep(150 * time.Millisecond)
+		require.NoError(t, transportA.SetRemoteDescription(answer(t, transportB, <-offers)))
+		// the retry offer gets a full timeout of its own
+		<-offers
+		time.Sleep(100 * time.Millisecond)
+		require.Equal(t, int32(0), failed.Load())
+		testutils.WithTimeout(t, func() string {
+			if failed.Load() != 1 {
+				return "retry negotiation did not fail"
+			}
+			return ""
+		})
+	})
+
+	t.Run("stale answer", func(t *testing.T) {
+		transportA, transportB, failed := newTransports(t)
+		offers := make(chan webrtc.SessionDescription, 1)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+
+		require.NoError(t, transportA.CreateAndSendOffer(nil))
+		sd := answer(t, transportB, <-offers)
+		require.NoError(t, transportA.SetRemoteDescription(sd))
+
+		// a duplicate of an already applied answer is rejected
+		require.ErrorIs(t, transportA.SetRemoteDescription(sd), ErrStaleAnswer)
+		require.Equal(t, negotiationStateNone, transportA.negotiationState)
+
+		// the watchdog keeps working for the next offer
+		require.NoError(t, transportA.CreateAndSendOffer(nil))
+		<-offers
+		testutils.WithTimeout(t, func() string {
+			if failed.Load() != 1 {
+				return "negotiation did not fail"
+			}
+			return ""
+		})
+	})
+}
+
+func TestGetICEConnectionInfo(t *testing.T) {
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_PUBLISHER,
+		Config:              &WebRTCConfig{},
+	}
+	transportA, err := NewPCTransport(params)
+	require.NoError(t, err)
+	defer transportA.Close()
+	_, err = transportA.pc.CreateDataChannel("test", nil)
+	require.NoError(t, err)
+	transportB, err := NewPCTransport(params)
+	require.NoError(t, err)
+	defer transportB.Close()
+
+	// no pair selected yet
+	info := transportA.GetICEConnectionInfo()
+	require.Nil(t, info.Local)
+	require.Nil(t, info.Remote)
+	require.Equal(t, webrtc.ICEConnectionStateNew.String(), info.ICEState)
+
+	handleICEExchange(t, transportA, transportB)
+	transportA.OnOffer(handleOfferFunc(t, transportA, transportB))
+	require.NoError(t, transportA.CreateAndSendOffer(nil))
+
+	testutils.WithTimeout(t, func() string {
+		info = transportA.GetICEConnectionInfo()
+		if info.Local == nil || info.Remote == nil {
+			return "candidate pair was not selected"
+		}
+		if info.DTLSState != webrtc.DTLSTransportStateConnected.String() {
+			return "DTLS did not connect"
+		}
+		return ""
+	})
+	require.Equal(t, webrtc.ICEConnectionStateConnected.String(), info.ICEState)
+	require.Equal(t, "udp", info.Local.Protocol)
+	require.NotEmpty(t, info.Local.Type)
+	require.Contains(t, info.Remote.Address, ":")
+	require.NotZero(t, info.BytesSent)
+	require.NotZero(t, info.BytesReceived)
+}
+
+func TestJoinTiming(t *testing.T) {
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{},
+	}
+	transportA, err := NewPCTransport(params)
+	require.NoError(t, err)
+	defer transportA.Close()
+	_, err = transportA.pc.CreateDataChannel("test", nil)
+	require.NoError(t, err)
+	params.Target = livekit.SignalTarget_PUBLISHER
+	transportB, err := NewPCTransport(params)
+	require.NoError(t, err)
+	defer transportB.Close()
+
+	connected := make(chan telemetry.JoinTiming, 1)
+	transportA.OnConnected(func(timing telemetry.JoinTiming) {
+		connected <- timing
+	})
+	var states atomic.Int32
+	transportA.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		states.Inc()
+	})
+
+	handleICEExchange(t, transportA, transportB)
+	transportA.OnOffer(handleOfferFunc(t, transportA, transportB))
+	require.NoError(t, transportA.CreateAndSendOffer(nil))
+
+	var timing telemetry.JoinTiming
+	select {
+	case timing = <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transport did not connect")
+	}
+	require.Equal(t, livekit.SignalTarget_SUBSCRIBER, timing.Target)
+	require.False(t, timing.TURN)
+	require.False(t, timing.OfferAt.IsZero())
+	require.False(t, timing.AnswerAt.Before(timing.OfferAt))
+	require.False(t, timing.ICEConnectedAt.Before(timing.AnswerAt))
+	require.False(t, timing.DTLSConnectedAt.Before(timing.ICEConnectedAt))
+	require.NotZero(t, states.Load())
+
+	// the stages of the first connection are kept through renegotiation, and reported once
+	require.NoError(t, transportA.CreateAndSendOffer(nil))
+	select {
+	case <-connected:
+		t.Fatal("connection reported again")
+	case <-time.After(100 * time.Millisecond):
+	}
+	transportA.lock.Lock()
+	require.Equal(t, timing, transportA.joinTiming)
+	transportA.lock.Unlock()
+}
+
+func TestConnectionEvents(t *testing.T) {
+	conf := &WebRTCConfig{}
+	conf.SettingEngine.SetICETimeouts(500*time.Millisecond, 5*time.Second, 100*time.Millisecond)
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              conf,
+	}
+	transportA, err := NewPCTransport(params)
+	require.NoError(t, err)
+	defer transportA.Close()
+	_, err = transportA.pc.CreateDataChannel("test", nil)
+	require.NoError(t, err)
+	params.Target = livekit.SignalTarget_PUBLISHER
+	transportB, err := NewPCTransport(params)
+	require.NoError(t, err)
+
+	events := make(chan *telemetry.ConnectionEvent, 10)
+	transportA.OnConnectionEvent(func(event *telemetry.ConnectionEvent) {
+		events <- event
+	})
+	nextEvent := func() *telemetry.ConnectionEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no connection event")
+			return nil
+		}
+	}
+
+	handleICEExchange(t, transportA, transportB)
+	transportA.OnOffer(handleOfferFunc(t, transportA, transportB))
+	require.NoError(t, transportA.CreateAndSendOffer(nil))
+
+	// the selected candidate pair is reported on connecting
+	event := nextEvent()
+	require.Equal(t, telemetry.ConnectionEventICEConnected, event.Type)
+	require.Equal(t, livekit.SignalTarget_SUBSCRIBER, event.Target)
+	require.Equal(t, webrtc.ICEConnectionStateChecking.String(), event.PrevState)
+	require.Equal(t, webrtc.ICEConnectionStateConnected.String(), event.State)
+	require.NotNil(t, event.Local)
+	require.NotNil(t, event.Remote)
+	require.Equal(t, "udp", event.Remote.Protocol)
+
+	// an ICE restart connecting again
+	require.NoError(t, transportA.CreateAndSendOffer(&webrtc.OfferOptions{ICERestart: true}))
+	event = nextEvent()
+	require.Equal(t, telemetry.ConnectionEventICERestarted, event.Type)
+	require.NotZero(t, event.Duration)
+
+	// the transport losing its peer
+	transportB.Close()
+	event = nextEvent()
+	require.Equal(t, telemetry.ConnectionEventICEDisconnected, event.Type)
+	require.Equal(t, webrtc.ICEConnectionStateConnected.String(), event.PrevState)
+	require.NotZero(t, event.Duration)
+
+	state, since := transportA.ICEConnectionState()
+	require.Equal(t, webrtc.ICEConnectionStateDisconnected, state)
+	require.False(t, since.IsZero())
+}
+
+func TestNegotiationDebounce(t *testing.T) {
+	const window = 100 * time.Millisecond
+	countOffers := func(t *testing.T, leadingEdge bool, burst int) (int32, time.Duration) {
+		params := TransportParams{
+			ParticipantID:       "id",
+			ParticipantIdentity: "identity",
+			Target:              livekit.SignalTarget_SUBSCRIBER,
+			Config:              &WebRTCConfig{},
+			NegotiationConfig: config.NegotiationConfig{
+				Debounce:    config.Duration(window),
+				LeadingEdge: leadingEdge,
+			},
+		}
+		transportA, err := NewPCTransport(params)
+		require.NoError(t, err)
+		defer transportA.Close()
+		_, err = transportA.pc.CreateDataChannel("test", nil)
+		require.NoError(t, err)
+		transportB, err := NewPCTransport(params)
+		require.NoError(t, err)
+		defer transportB.Close()
+
+		start := time.Now()
+		firstOffer := atomic.Duration{}
+		offers := atomic.Int32{}
+		handleOffer := handleOfferFunc(t, transportA, transportB)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) {
+			if offers.Inc() == 1 {
+				firstOffer.Store(time.Since(start))
+			}
+			handleOffer(sd)
+		})
+
+		for i := 0; i < burst; i++ {
+			transportA.Negotiate()
+			time.Sleep(5 * time.Millisecond)
+		}
+		time.Sleep(4 * window)
+		return offers.Load(), firstOffer.Load()
+	}
+
+	for _, tc := range []struct {
+		burst            int
+		trailingExpected int32
+		leadingExpected  int32
+	}{
+		{burst: 1, trailingExpected: 1, leadingExpected: 1},
+		{burst: 3, trailingExpected: 1, leadingExpected: 2},
+		{burst: 10, trailingExpected: 1, leadingExpected: 2},
+	} {
+		t.Run(fmt.Sprintf("trailing edge, burst of %d", tc.burst), func(t *testing.T) {
+			offers, firstOffer := countOffers(t, false, tc.burst)
+			require.Equal(t, tc.trailingExpected, offers)
+			require.GreaterOrEqual(t, firstOffer, window)
+		})
+
+		t.Run(fmt.Sprintf("leading edge, burst of %d", tc.burst), func(t *testing.T) {
+			offers, firstOffer := countOffers(t, true, tc.burst)
+			require.Equal(t, tc.leadingExpected, offers)
+			require.Less(t, firstOffer, window)
+		})
+	}
+}
+
+func TestICERestart(t *testing.T) {
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{},
+	}
+	newTransports := func(t *testing.T) (*PCTransport, *PCTransport) {
+		transportA, err := NewPCTransport(params)
+		require.NoError(t, err)
+		_, err = transportA.pc.CreateDataChannel("test", nil)
+		require.NoError(t, err)
+		transportB, err := NewPCTransport(params)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			transportA.Close()
+			transportB.Close()
+		})
+		handleICEExchange(t, transportA, transportB)
+		return transportA, transportB
+	}
+	waitConnected := func(t *testing.T, a, b *PCTransport) {
+		testutils.WithTimeout(t, func() string {
+			if a.pc.ICEConnectionState() != webrtc.ICEConnectionStateConnected {
+				return "transportA did not connect"
+			}
+			if b.pc.ICEConnectionState() != webrtc.ICEConnectionStateConnected {
+				return "transportB did not connect"
+			}
+			return ""
+		})
+	}
+
+	t.Run("while gathering", func(t *testing.T) {
+		transportA, transportB := newTransports(t)
+		offers := make(chan webrtc.SessionDescription, 2)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+
+		require.NoError(t, transportA.CreateAndSendOffer(nil))
+		require.NoError(t, transportA.CreateAndSendOffer(&webrtc.OfferOptions{ICERestart: true}))
+		transportA.lock.Lock()
+		require.True(t, transportA.restartAfterGathering)
+		transportA.lock.Unlock()
+
+		// the restart is deferred until the outstanding offer is answered
+		offer := <-offers
+		handleOfferFunc(t, transportA, transportB)(offer)
+		restartOffer := <-offers
+		require.NotEqual(t, iceUfrag(t, offer), iceUfrag(t, restartOffer))
+
+		// pion cannot restart the answering side while it is still gathering either
+		testutils.WithTimeout(t, func() string {
+			if transportB.pc.ICEGatheringState() != webrtc.ICEGatheringStateComplete {
+				return "transportB did not finish gathering"
+			}
+			return ""
+		})
+		handleOfferFunc(t, transportA, transportB)(restartOffer)
+		waitConnected(t, transportA, transportB)
+	})
+
+	t.Run("while negotiation is outstanding", func(t *testing.T) {
+		transportA, transportB := newTransports(t)
+		offers := make(chan webrtc.SessionDescription, 2)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+
+		require.NoError(t, transportA.CreateAndSendOffer(nil))
+		offer := <-offers
+		handleOfferFunc(t, transportA, transportB)(offer)
+		waitConnected(t, transportA, transportB)
+
+		// this offer is never answered
+		require.NoError(t, transportA.CreateAndSendOffer(nil))
+		<-offers
+		require.Equal(t, negotiationStateClient, transportA.negotiationState)
+
+		require.NoError(t, transportA.CreateAndSendOffer(&webrtc.OfferOptions{ICERestart: true}))
+		restartOffer := <-offers
+		require.NotEqual(t, iceUfrag(t, offer), iceUfrag(t, restartOffer))
+		handleOfferFunc(t, transportA, transportB)(restartOffer)
+		waitConnected(t, transportA, transportB)
+	})
+
+	t.Run("answerer holds candidates until the restart offer", func(t *testing.T) {
+		// transportB offers here, transportA answers like a publisher transport
+		transportB, transportA := newTransports(t)
+		offers := make(chan webrtc.SessionDescription, 2)
+		transportB.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+
+		require.NoError(t, transportB.CreateAndSendOffer(nil))
+		handleOfferFunc(t, transportB, transportA)(<-offers)
+		waitConnected(t, transportA, transportB)
+
+		transportA.PrepareICERestart()
+		// a candidate from the old generation
+		require.NoError(t, transportA.AddICECandidate(webrtc.ICECandidateInit{
+			Candidate: "candidate:1 1 udp 2130706431 192.0.2.1 50000 typ host",
+		}))
+		transportA.lock.Lock()
+		require.Len(t, transportA.pendingCandidates, 1)
+		transportA.lock.Unlock()
+
+		require.NoError(t, transportB.CreateAndSendOffer(&webrtc.OfferOptions{ICERestart: true}))
+		transportA.PrepareICERestart()
+		handleOfferFunc(t, transportB, transportA)(<-offers)
+
+		transportA.lock.Lock()
+		require.False(t, transportA.awaitingRestartOffer)
+		require.Empty(t, transportA.pendingCandidates)
+		transportA.lock.Unlock()
+		waitConnected(t, transportA, transportB)
+	})
+}
+
+func iceUfrag(t *testing.T, sd webrtc.SessionDescription) string {
+	ufrag := getICEUfrag(sd)
+	require.NotEmpty(t, ufrag)
+	return ufrag
+}
+
+func TestPendingCandidates(t *testing.T) {
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_PUBLISHER,
+		Config:              &WebRTCConfig{},
+	}
+	candidate := func(port int, ufrag string) webrtc.ICECandidateInit {
+		c := webrtc.ICECandidateInit{
+			Candidate: fmt.Sprintf("candidate:1 1 udp 2130706431 192.0.2.1 %d typ host", port),
+		}
+		if ufrag != "" {
+			c.UsernameFragment = &ufrag
+		}
+		return c
+	}
+
+	t.Run("duplicates are ignored and the queue is capped", func(t *testing.T) {
+		transport, err := NewPCTransport(params)
+		require.NoError(t, err)
+		defer transport.Close()
+
+		require.NoError(t, transport.AddICECandidate(candidate(50000, "")))
+		require.NoError(t, transport.AddICECandidate(candidate(50000, "")))
+		require.Len(t, transport.pendingCandidates, 1)
+
+		for i := 1; i < maxPendingCandidates; i++ {
+			require.NoError(t, transport.AddICECandidate(candidate(50000+i, "")))
+		}
+		require.ErrorIs(t, transport.AddICECandidate(candidate(60000, "")), ErrTooManyPendingCandidates)
+		require.Len(t, transport.pendingCandidates, maxPendingCandidates)
+	})
+
+	t.Run("candidates from a previous generation are dropped", func(t *testing.T) {
+		offerer, err := NewPCTransport(params)
+		require.NoError(t, err)
+		defer offerer.Close()
+		_, err = offerer.pc.CreateDataChannel("test", nil)
+		require.NoError(t, err)
+		transport, err := NewPCTransport(params)
+		require.NoError(t, err)
+		defer transport.Close()
+
+		offers := make(chan webrtc.SessionDescription, 2)
+		offerer.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+		require.NoError(t, offerer.CreateAndSendOffer(nil))
+		offer := <-offers
+
+		// queued before the remote description, one from a stale generation
+		require.NoError(t, transport.AddICECandidate(candidate(50000, "stale")))
+		require.NoError(t, transport.AddICECandidate(candidate(50001, iceUfrag(t, offer))))
+		require.NoError(t, transport.SetRemoteDescription(offer))
+		require.Empty(t, transport.pendingCandidates)
+		require.Equal(t, iceUfrag(t, offer), transport.remoteUfrag)
+
+		// trickled after the remote description
+		require.NoError(t, transport.AddICECandidate(candidate(50002, "stale")))
+
+		// while waiting for a restart offer, candidates of the current generation are not queued
+		transport.PrepareICERestart()
+		require.NoError(t, transport.AddICECandidate(candidate(50003, iceUfrag(t, offer))))
+		require.Empty(t, transport.pendingCandidates)
+		require.NoError(t, transport.AddICECandidate(candidate(50004, "next")))
+		require.Len(t, transport.pendingCandidates, 1)
+	})
+}
+
+func TestNegotiateWithResult(t *testing.T) {
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{NegotiationTimeout: 500 * time.Millisecond},
+	}
+	newTransports := func(t *testing.T) (*PCTransport, *PCTransport) {
+		transportA, err := NewPCTransport(params)
+		require.NoError(t, err)
+		_, err = transportA.pc.CreateDataChannel("test", nil)
+		require.NoError(t, err)
+		transportB, err := NewPCTransport(params)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			transportA.Close()
+			transportB.Close()
+		})
+		return transportA, transportB
+	}
+	receive := func(t *testing.T, result <-chan error) error {
+		select {
+		case err := <-result:
+			return err
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "negotiation did not complete")
+			return nil
+		}
+	}
+
+	t.Run("resolves when the answer is applied", func(t *testing.T) {
+		transportA, transportB := newTransports(t)
+		offers := atomic.Int32{}
+		handleOffer := handleOfferFunc(t, transportA, transportB)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) {
+			offers.Inc()
+			handleOffer(sd)
+		})
+
+		results := make([]<-chan error, 0, 3)
+		for i := 0; i < 3; i++ {
+			results = append(results, transportA.NegotiateWithResult(context.Background()))
+		}
+		for _, result := range results {
+			require.NoError(t, receive(t, result))
+		}
+		// callers within the debounce window share an offer
+		require.Equal(t, int32(1), offers.Load())
+		require.Equal(t, webrtc.SignalingStateStable, transportA.pc.SignalingState())
+	})
+
+	t.Run("callers during an outstanding offer wait for the next answer", func(t *testing.T) {
+		transportA, transportB := newTransports(t)
+		offers := make(chan webrtc.SessionDescription, 2)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+		answer := func(offer webrtc.SessionDescription) {
+			require.NoError(t, transportB.SetRemoteDescription(offer))
+			sd, err := transportB.CreateAnswer()
+			require.NoError(t, err)
+			require.NoError(t, transportA.SetRemoteDescription(sd))
+		}
+
+		first := transportA.NegotiateWithResult(context.Background())
+		offer := <-offers
+		second := transportA.NegotiateWithResult(context.Background())
+		// wait for the debounced attempt to be deferred
+		time.Sleep(250 * time.Millisecond)
+		select {
+		case <-second:
+			require.Fail(t, "resolved before its offer was answered")
+		default:
+		}
+
+		answer(offer)
+		require.NoError(t, receive(t, first))
+		answer(<-offers)
+		require.NoError(t, receive(t, second))
+	})
+
+	t.Run("client does not answer", func(t *testing.T) {
+		transportA, _ := newTransports(t)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) {})
+		require.ErrorIs(t, receive(t, transportA.NegotiateWithResult(context.Background())), ErrNegotiationTimeout)
+	})
+
+	t.Run("context is cancelled", func(t *testing.T) {
+		transportA, _ := newTransports(t)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) {})
+		ctx, cancel := context.WithCancel(context.Background())
+		result := transportA.NegotiateWithResult(ctx)
+		cancel()
+		require.ErrorIs(t, receive(t, result), context.Canceled)
+
+		transportA.lock.Lock()
+		defer transportA.lock.Unlock()
+		require.Empty(t, transportA.pendingNegotiationWaiters)
+		require.Empty(t, transportA.inflightNegotiationWaiters)
+	})
+
+	t.Run("transport closes", func(t *testing.T) {
+		transportA, _ := newTransports(t)
+		transportA.OnOffer(func(sd webrtc.SessionDescription) {})
+		result := transportA.NegotiateWithResult(context.Background())
+		transportA.Close()
+		require.ErrorIs(t, receive(t, result), ErrTransportClosed)
+	})
+}
+
+func TestDescriptionMunge(t *testing.T) {
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{},
+	}
+	// sets a bandwidth limit on the first media section
+	setBandwidth := func(sd *webrtc.SessionDescription) error {
+		idx := strings.Index(sd.SDP, "c=IN ")
+		if idx < 0 {
+			return errors.New("no connection line")
+		}
+		idx += strings.Index(sd.SDP[idx:], "\r\n") + 2
+		sd.SDP = sd.SDP[:idx] + "b=AS:1000\r\n" + sd.SDP[idx:]
+		return nil
+	}
+
+	t.Run("munged offer is sent and answered", func(t *testing.T) {
+		offerer, err := NewPCTransport(params)
+		require.NoError(t, err)
+		defer offerer.Close()
+		_, err = offerer.pc.CreateDataChannel("test", nil)
+		require.NoError(t, err)
+		answerer, err := NewPCTransport(params)
+		require.NoError(t, err)
+		defer answerer.Close()
+
+		offerer.OnLocalDescriptionMunge(setBandwidth)
+		offers := make(chan webrtc.SessionDescription, 1)
+		offerer.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+		require.NoError(t, offerer.CreateAndSendOffer(nil))
+		offer := <-offers
+		require.Contains(t, offer.SDP, "b=AS:1000")
+
+		var remote string
+		answerer.OnRemoteDescriptionMunge(func(sd *webrtc.SessionDescription) error {
+			remote = sd.SDP
+			sd.SDP = strings.Replace(sd.SDP, "b=AS:1000", "b=AS:500", 1)
+			return nil
+		})
+		answerer.OnLocalDescriptionMunge(setBandwidth)
+		require.NoError(t, answerer.SetRemoteDescription(offer))
+		require.Equal(t, offer.SDP, remote)
+		require.Contains(t, answerer.pc.RemoteDescription().SDP, "b=AS:500")
+
+		answer, err := answerer.CreateAnswer()
+		require.NoError(t, err)
+		require.Contains(t, answer.SDP, "b=AS:1000")
+		require.NoError(t, offerer.SetRemoteDescription(answer))
+	})
+
+	t.Run("changes to what pion negotiated are refused", func(t *testing.T) {
+		for _, replace := range [][2]string{
+			{"a=setup:actpass", "a=setup:active"},
+			{"a=mid:0", "a=mid:data"},
+			{"a=ice-ufrag:", "a=ice-ufrag:x"},
+		} {
+			transport, err := NewPCTransport(params)
+			require.NoError(t, err)
+			_, err = transport.pc.CreateDataChannel("test", nil)
+			require.NoError(t, err)
+
+			transport.OnLocalDescriptionMunge(func(sd *webrtc.SessionDescription) error {
+				sd.SDP = strings.Replace(sd.SDP, replace[0], replace[1], 1)
+				return nil
+			})
+			require.ErrorIs(t, transport.CreateAndSendOffer(nil), ErrUnsupportedLocalDescriptionMunge, replace[0])
+			require.Equal(t, webrtc.SignalingStateStable, transport.pc.SignalingState())
+			transport.Close()
+		}
+	})
+
+	t.Run("errors abort the negotiation", func(t *testing.T) {
+		transport, err := NewPCTransport(params)
+		require.NoError(t, err)
+		defer transport.Close()
+		_, err = transport.pc.CreateDataChannel("test", nil)
+		require.NoError(t, err)
+
+		mungeErr := errors.New("cannot munge")
+		transport.OnLocalDescriptionMunge(func(sd *webrtc.SessionDescription) error { return mungeErr })
+		var offered atomic.Bool
+		transport.OnOffer(func(sd webrtc.SessionDescription) { offered.Store(true) })
+		require.ErrorIs(t, transport.CreateAndSendOffer(nil), mungeErr)
+		require.False(t, offered.Load())
+		require.Equal(t, webrtc.SignalingStateStable, transport.pc.SignalingState())
+		require.Equal(t, negotiationStateNone, transport.negotiationState)
+
+		transport.OnRemoteDescriptionMunge(func(sd *webrtc.SessionDescription) error { return mungeErr })
+		require.ErrorIs(t, transport.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: "v=0"}), mungeErr)
+	})
+}
+
+func TestCreateDataChannel(t *testing.T) {
+	params := TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{},
+		EnabledCodecs:       []*livekit.Codec{{Mime: webrtc.MimeTypeOpus}},
+	}
+	newTransports := func(t *testing.T) (*PCTransport, *PCTransport, chan *webrtc.DataChannel) {
+		transportA, err := NewPCTransport(params)
+		require.NoError(t, err)
+		transportB, err := NewPCTransport(params)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			transportA.Close()
+			transportB.Close()
+		})
+		handleICEExchange(t, transportA, transportB)
+		transportA.OnOffer(handleOfferFunc(t, transportA, transportB))
+
+		opened := make(chan *webrtc.DataChannel, 4)
+		transportB.pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+			opened <- dc
+		})
+		return transportA, transportB, opened
+	}
+	receive := func(t *testing.T, opened chan *webrtc.DataChannel) *webrtc.DataChannel {
+		select {
+		case dc := <-opened:
+			return dc
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "data channel did not open")
+			return nil
+		}
+	}
+
+	t.Run("remote side sees the configured reliability", func(t *testing.T) {
+		conf, err := config.NewConfig(`rtc:
+  data_channel:
+    lossy:
+      ordered: false
+      max_packet_life_time: 100`, nil)
+		require.NoError(t, err)
+		transportA, _, opened := newTransports(t)
+
+		_, err = transportA.CreateDataChannel(reliableDataChannel, dataChannelInit(conf.RTC.DataChannel.Reliable, false))
+		require.NoError(t, err)
+		_, err = transportA.CreateDataChannel(lossyDataChannel, dataChannelInit(conf.RTC.DataChannel.Lossy, true))
+		require.NoError(t, err)
+		_, err = transportA.CreateDataChannel("_lossy_default", dataChannelInit(config.DataChannelOptions{Ordered: true}, true))
+		require.NoError(t, err)
+		transportA.Negotiate()
+
+		channels := make(map[string]*webrtc.DataChannel)
+		for i := 0; i < 3; i++ {
+			dc := receive(t, opened)
+			channels[dc.Label()] = dc
+		}
+
+		reliable := channels[reliableDataChannel]
+		require.NotNil(t, reliable)
+		require.True(t, reliable.Ordered())
+		require.Nil(t, reliable.MaxRetransmits())
+		require.Nil(t, reliable.MaxPacketLifeTime())
+
+		lossy := channels[lossyDataChannel]
+		require.NotNil(t, lossy)
+		require.False(t, lossy.Ordered())
+		require.Nil(t, lossy.MaxRetransmits())
+		require.NotNil(t, lossy.MaxPacketLifeTime())
+		require.Equal(t, uint16(100), *lossy.MaxPacketLifeTime())
+
+		lossyDefault := channels["_lossy_default"]
+		require.NotNil(t, lossyDefault)
+		require.True(t, lossyDefault.Ordered())
+		require.NotNil(t, lossyDefault.MaxRetransmits())
+		require.Equal(t, uint16(0), *lossyDefault.MaxRetransmits())
+		require.Nil(t, lossyDefault.MaxPacketLifeTime())
+	})
+
+	t.Run("renegotiates a session without SCTP association", func(t *testing.T) {
+		transportA, _, opened := newTransports(t)
+		_, err := transportA.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio,
+			webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+		require.NoError(t, err)
+		require.NoError(t, <-transportA.NegotiateWithResult(context.Background()))
+		require.False(t, hasDataSection(transportA.pc.CurrentLocalDescription()))
+
+		_, err = transportA.CreateDataChannel(reliableDataChannel, dataChannelInit(config.DataChannelOptions{Ordered: true}, false))
+		require.NoError(t, err)
+		dc := receive(t, opened)
+		require.Equal(t, reliableDataChannel, dc.Label())
+		require.True(t, hasDataSection(transportA.pc.CurrentLocalDescription()))
+	})
+}
+
+func TestICECandidateTypes(t *testing.T) {
+	candidateTypes, err := ParseICECandidateTypes([]string{"relay"})
+	require.NoError(t, err)
+	transport, err := NewPCTransport(TransportParams{
+		ParticipantID:       "id",
+		ParticipantIdentity: "identity",
+		Target:              livekit.SignalTarget_PUBLISHER,
+		Config:              &WebRTCConfig{ICECandidateTypes: candidateTypes},
+	})
+	require.NoError(t, err)
+	defer transport.Close()
+
+	require.NoError(t, transport.AddICECandidate(webrtc.ICECandidateInit{
+		Candidate: "candidate:1 1 udp 2130706431 192.0.2.1 50000 typ host",
+	}))
+	require.NoError(t, transport.AddICECandidate(webrtc.ICECandidateInit{
+		Candidate: "candidate:2 1 udp 1694498815 198.51.100.1 50001 typ srflx raddr 192.0.2.1 rport 50000",
+	}))
+	require.Empty(t, transport.pendingCandidates)
+
+	require.NoError(t, transport.AddICECandidate(webrtc.ICECandidateInit{
+		Candidate: "candidate:3 1 udp 16777215 203.0.113.1 3478 typ relay raddr 198.51.100.1 rport 50001",
+	}))
+	require.Len(t, transport.pendingCandidates, 1)
+
+	_, err = ParseICECandidateTypes([]string{"local"})
+	require.Error(t, err)
+}
+
+func TestTransceiverReuse(t *testing.T) {
+	codecs := []*livekit.Codec{{Mime: webrtc.MimeTypeOpus}, {Mime: webrtc.MimeTypeVP8}, {Mime: webrtc.MimeTypeH264}}
+	subscriber, err := NewPCTransport(TransportParams{
+		ParticipantID:       "sub",
+		ParticipantIdentity: "sub",
+		ProtocolVersion:     5,
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{},
+		EnabledCodecs:       codecs,
+		// offers are exchanged by the test, not the renegotiation started by reuse
+		NegotiationConfig: config.NegotiationConfig{Debounce: config.Duration(time.Hour)},
+	})
+	require.NoError(t, err)
+	defer subscriber.Close()
+	client, err := NewPCTransport(TransportParams{
+		ParticipantID:       "client",
+		ParticipantIdentity: "client",
+		Target:              livekit.SignalTarget_PUBLISHER,
+		Config:              &WebRTCConfig{},
+		EnabledCodecs:       codecs,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	offers := make(chan webrtc.SessionDescription, 1)
+	subscriber.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+	negotiate := func() int {
+		require.NoError(t, subscriber.CreateAndSendOffer(nil))
+		offer := <-offers
+		require.NoError(t, client.SetRemoteDescription(offer))
+		answer, err := client.pc.CreateAnswer(nil)
+		require.NoError(t, err)
+		require.NoError(t, client.pc.SetLocalDescription(answer))
+		require.NoError(t, subscriber.SetRemoteDescription(answer))
+
+		parsed, err := offer.Unmarshal()
+		require.NoError(t, err)
+		return len(parsed.MediaDescriptions)
+	}
+
+	mimeTypes := []string{webrtc.MimeTypeVP8, webrtc.MimeTypeH264}
+	maxMLines := 0
+	for i := 0; i < 50; i++ {
+		mimeType := mimeTypes[i%len(mimeTypes)]
+		track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: mimeType}, fmt.Sprintf("track%d", i), "stream")
+		require.NoError(t, err)
+
+		transceiver, sender, err := subscriber.GetTransceiverForSending(track)
+		require.NoError(t, err)
+		if n := negotiate(); n > maxMLines {
+			maxMLines = n
+		}
+		require.Equal(t, mimeType, subscriber.transceiverCodecs[transceiver.Mid()].MimeType)
+
+		require.NoError(t, subscriber.pc.RemoveTrack(sender))
+		subscriber.CleanupTransceivers()
+		negotiate()
+	}
+
+	// a single m-line is taken over on every cycle
+	require.Equal(t, 1, maxMLines)
+	require.Len(t, subscriber.pc.GetTransceivers(), 1)
+	require.Len(t, subscriber.transceiverCodecs, 1)
+
+	t.Run("inactive m-line with the same codec is preferred", func(t *testing.T) {
+		vp8, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, "vp8", "stream")
+		require.NoError(t, err)
+		h264, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264}, "h264", "stream")
+		require.NoError(t, err)
+		_, vp8Sender, err := subscriber.GetTransceiverForSending(vp8)
+		require.NoError(t, err)
+		h264Transceiver, h264Sender, err := subscriber.GetTransceiverForSending(h264)
+		require.NoError(t, err)
+		negotiate()
+
+		require.NoError(t, subscriber.pc.RemoveTrack(vp8Sender))
+		require.NoError(t, subscriber.pc.RemoveTrack(h264Sender))
+		negotiate()
+
+		next, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264}, "next", "stream")
+		require.NoError(t, err)
+		transceiver, _, err := subscriber.GetTransceiverForSending(next)
+		require.NoError(t, err)
+		require.Equal(t, h264Transceiver.Mid(), transceiver.Mid())
+		require.Equal(t, 2, negotiate())
+	})
+
+	t.Run("older clients always get a new m-line", func(t *testing.T) {
+		legacy, err := NewPCTransport(TransportParams{
+			ParticipantID:       "legacy",
+			ParticipantIdentity: "legacy",
+			ProtocolVersion:     3,
+			Target:              livekit.SignalTarget_SUBSCRIBER,
+			Config:              &WebRTCConfig{},
+			EnabledCodecs:       codecs,
+		})
+		require.NoError(t, err)
+		defer legacy.Close()
+
+		for i := 0; i < 3; i++ {
+			track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, fmt.Sprintf("track%d", i), "stream")
+			require.NoError(t, err)
+			_, sender, err := legacy.GetTransceiverForSending(track)
+			require.NoError(t, err)
+			require.NoError(t, legacy.pc.RemoveTrack(sender))
+		}
+		require.Len(t, legacy.pc.GetTransceivers(), 3)
+	})
+}
+
+func TestTransceiverReuseRules(t *testing.T) {
+	codecs := []*livekit.Codec{{Mime: webrtc.MimeTypeVP8}}
+	setup := func(t *testing.T) (*PCTransport, chan webrtc.SessionDescription, func(offer webrtc.SessionDescription)) {
+		subscriber, err := NewPCTransport(TransportParams{
+			ParticipantID:       "sub",
+			ParticipantIdentity: "sub",
+			ProtocolVersion:     5,
+			Target:              livekit.SignalTarget_SUBSCRIBER,
+			Config:              &WebRTCConfig{},
+			EnabledCodecs:       codecs,
+			NegotiationConfig:   config.NegotiationConfig{Debounce: config.Duration(10 * time.Millisecond)},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { subscriber.Close() })
+		client, err := NewPCTransport(TransportParams{
+			ParticipantID:       "client",
+			ParticipantIdentity: "client",
+			Target:              livekit.SignalTarget_PUBLISHER,
+			Config:              &WebRTCConfig{},
+			EnabledCodecs:       codecs,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { client.Close() })
+
+		offers := make(chan webrtc.SessionDescription, 1)
+		subscriber.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+		answer := func(offer webrtc.SessionDescription) {
+			require.NoError(t, client.SetRemoteDescription(offer))
+			answer, err := client.pc.CreateAnswer(nil)
+			require.NoError(t, err)
+			require.NoError(t, client.pc.SetLocalDescription(answer))
+			require.NoError(t, subscriber.SetRemoteDescription(answer))
+		}
+		return subscriber, offers, answer
+	}
+	negotiate := func(t *testing.T, subscriber *PCTransport, offers chan webrtc.SessionDescription, answer func(webrtc.SessionDescription)) {
+		require.NoError(t, subscriber.CreateAndSendOffer(nil))
+		answer(<-offers)
+	}
+	newTrack := func(t *testing.T, id string) webrtc.TrackLocal {
+		track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, id, "stream")
+		require.NoError(t, err)
+		return track
+	}
+
+	t.Run("active sendrecv transceiver is never reused", func(t *testing.T) {
+		subscriber, offers, answer := setup(t)
+		active, err := subscriber.pc.AddTransceiverFromKind(webrtc.RTPCodecTypeVideo)
+		require.NoError(t, err)
+		negotiate(t, subscriber, offers, answer)
+		require.Equal(t, webrtc.RTPTransceiverDirectionSendrecv, active.Direction())
+		// recorded as if it carried the codec, so only its direction keeps it from being reused
+		subscriber.transceiverCodecs[active.Mid()] = webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}
+		require.False(t, isReusableTransceiver(active, webrtc.RTPCodecTypeVideo))
+
+		transceiver, _, err := subscriber.GetTransceiverForSending(newTrack(t, "track"))
+		require.NoError(t, err)
+		require.NotEqual(t, active, transceiver)
+		require.Equal(t, webrtc.RTPTransceiverDirectionSendrecv, active.Direction())
+		require.Len(t, subscriber.pc.GetTransceivers(), 2)
+	})
+
+	t.Run("transceiver that stopped sending is reused", func(t *testing.T) {
+		subscriber, offers, answer := setup(t)
+		previous, sender, err := subscriber.GetTransceiverForSending(newTrack(t, "first"))
+		require.NoError(t, err)
+		negotiate(t, subscriber, offers, answer)
+		require.NoError(t, subscriber.pc.RemoveTrack(sender))
+		negotiate(t, subscriber, offers, answer)
+		// AddTrack creates sendrecv transceivers, which stop sending by going to recvonly
+		require.Equal(t, webrtc.RTPTransceiverDirectionRecvonly, previous.Direction())
+		require.True(t, isReusableTransceiver(previous, webrtc.RTPCodecTypeVideo))
+
+		transceiver, _, err := subscriber.GetTransceiverForSending(newTrack(t, "second"))
+		require.NoError(t, err)
+		require.Equal(t, previous, transceiver)
+		require.Equal(t, webrtc.RTPTransceiverDirectionSendrecv, transceiver.Direction())
+		require.Len(t, subscriber.pc.GetTransceivers(), 1)
+	})
+
+	t.Run("reuse triggers a renegotiation", func(t *testing.T) {
+		subscriber, offers, answer := setup(t)
+		previous, sender, err := subscriber.GetTransceiverForSending(newTrack(t, "first"))
+		require.NoError(t, err)
+		negotiate(t, subscriber, offers, answer)
+		require.NoError(t, subscriber.pc.RemoveTrack(sender))
+		negotiate(t, subscriber, offers, answer)
+
+		_, _, err = subscriber.GetTransceiverForSending(newTrack(t, "second"))
+		require.NoError(t, err)
+
+		// offered without the test asking for it
+		var offer webrtc.SessionDescription
+		select {
+		case offer = <-offers:
+		case <-time.After(time.Second):
+			t.Fatal("no offer after reusing a transceiver")
+		}
+		parsed, err := offer.Unmarshal()
+		require.NoError(t, err)
+		require.Len(t, parsed.MediaDescriptions, 1)
+		mid, _ := parsed.MediaDescriptions[0].Attribute(sdp.AttrKeyMID)
+		require.Equal(t, previous.Mid(), mid)
+		// sending again
+		_, sendrecv := parsed.MediaDescriptions[0].Attribute(webrtc.RTPTransceiverDirectionSendrecv.String())
+		require.True(t, sendrecv)
+		answer(offer)
+	})
+}
+
+func TestStrictCodecMatching(t *testing.T) {
+	codecs := []*livekit.Codec{{Mime: webrtc.MimeTypeOpus}, {Mime: webrtc.MimeTypeVP8}, {Mime: webrtc.MimeTypeH264}}
+	subscriber, err := NewPCTransport(TransportParams{
+		ParticipantID:       "sub",
+		ParticipantIdentity: "sub",
+		ProtocolVersion:     5,
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{Subscriber: DirectionConfig{StrictCodecMatching: true}},
+		EnabledCodecs:       codecs,
+	})
+	require.NoError(t, err)
+	defer subscriber.Close()
+	client, err := NewPCTransport(TransportParams{
+		ParticipantID:       "client",
+		ParticipantIdentity: "client",
+		Target:              livekit.SignalTarget_PUBLISHER,
+		Config:              &WebRTCConfig{},
+		EnabledCodecs:       codecs,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	offers := make(chan webrtc.SessionDescription, 1)
+	subscriber.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+	negotiate := func() {
+		require.NoError(t, subscriber.CreateAndSendOffer(nil))
+		offer := <-offers
+		require.NoError(t, client.SetRemoteDescription(offer))
+		answer, err := client.pc.CreateAnswer(nil)
+		require.NoError(t, err)
+		require.NoError(t, client.pc.SetLocalDescription(answer))
+		require.NoError(t, subscriber.SetRemoteDescription(answer))
+	}
+
+	codecsToSend := []webrtc.RTPCodecCapability{
+		{MimeType: webrtc.MimeTypeVP8},
+		{MimeType: webrtc.MimeTypeH264, SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f"},
+		{MimeType: webrtc.MimeTypeH264, SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640032"},
+	}
+	mids := make(map[int]string)
+	for i := 0; i < 3*len(codecsToSend); i++ {
+		codec := codecsToSend[i%len(codecsToSend)]
+		track, err := webrtc.NewTrackLocalStaticSample(codec, fmt.Sprintf("track%d", i), "stream")
+		require.NoError(t, err)
+
+		transceiver, sender, err := subscriber.GetTransceiverForSending(track)
+		require.NoError(t, err)
+		negotiate()
+
+		// every codec keeps to its own m-line
+		if mid, ok := mids[i%len(codecsToSend)]; ok {
+			require.Equal(t, mid, transceiver.Mid())
+		} else {
+			for _, other := range mids {
+				require.NotEqual(t, other, transceiver.Mid())
+			}
+			mids[i%len(codecsToSend)] = transceiver.Mid()
+		}
+
+		require.NoError(t, subscriber.pc.RemoveTrack(sender))
+		subscriber.CleanupTransceivers()
+		negotiate()
+	}
+	require.Len(t, subscriber.pc.GetTransceivers(), len(codecsToSend))
+}
+
+func TestOpusParametersForSubscriber(t *testing.T) {
+	const fmtp = "minptime=10;useinbandfec=1;stereo=1;sprop-stereo=1;usedtx=1"
+	for _, reuse := range []bool{false, true} {
+		protocolVersion := types.ProtocolVersion(3)
+		if reuse {
+			protocolVersion = 5
+		}
+		subscriber, err := NewPCTransport(TransportParams{
+			ParticipantID:       "sub",
+			ParticipantIdentity: "sub",
+			ProtocolVersion:     protocolVersion,
+			Target:              livekit.SignalTarget_SUBSCRIBER,
+			Config:              &WebRTCConfig{},
+			EnabledCodecs:       []*livekit.Codec{{Mime: webrtc.MimeTypeOpus}},
+		})
+		require.NoError(t, err)
+		defer subscriber.Close()
+
+		mono, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2, SDPFmtpLine: "minptime=10;useinbandfec=1"}, "mono", "stream")
+		require.NoError(t, err)
+		stereo, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2, SDPFmtpLine: fmtp}, "stereo", "stream")
+		require.NoError(t, err)
+
+		_, _, err = subscriber.GetTransceiverForSending(mono)
+		require.NoError(t, err)
+		_, _, err = subscriber.GetTransceiverForSending(stereo)
+		require.NoError(t, err)
+		offer, err := subscriber.pc.CreateOffer(nil)
+		require.NoError(t, err)
+
+		// each m-line carries the parameters of its own track
+		parsed, err := offer.Unmarshal()
+		require.NoError(t, err)
+		var fmtps []string
+		for _, m := range parsed.MediaDescriptions {
+			for _, a := range m.Attributes {
+				if a.Key == "fmtp" {
+					fmtps = append(fmtps, a.Value)
+				}
+			}
+		}
+		require.Equal(t, []string{"111 minptime=10;useinbandfec=1", "111 " + fmtp}, fmtps)
+	}
+}
+
+func TestSignalRTXStreams(t *testing.T) {
+	subscriber, err := NewPCTransport(TransportParams{
+		ParticipantID:       "sub",
+		ParticipantIdentity: "sub",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{Subscriber: DirectionConfig{EnableRTX: true}},
+		EnabledCodecs:       []*livekit.Codec{{Mime: webrtc.MimeTypeVP8}},
+	})
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	track, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, "video", "stream")
+	require.NoError(t, err)
+	_, sender, err := subscriber.GetTransceiverForSending(track)
+	require.NoError(t, err)
+	ssrc := uint32(sender.GetParameters().Encodings[0].SSRC)
+
+	offer, err := subscriber.pc.CreateOffer(nil)
+	require.NoError(t, err)
+	require.Contains(t, offer.SDP, "a=rtpmap:97 rtx/90000")
+	require.Contains(t, offer.SDP, "a=fmtp:97 apt=96")
+
+	// only sources with a retransmission stream are grouped
+	unchanged := offer
+	require.NoError(t, signalRTXStreams(&unchanged, map[uint32]uint32{ssrc + 1: 5678}))
+	require.NotContains(t, unchanged.SDP, "ssrc-group")
+
+	require.NoError(t, signalRTXStreams(&offer, map[uint32]uint32{ssrc: 5678}))
+	require.Contains(t, offer.SDP, fmt.Sprintf("a=ssrc-group:FID %d 5678", ssrc))
+	parsed, err := offer.Unmarshal()
+	require.NoError(t, err)
+	var mediaAttrs, rtxAttrs []string
+	for _, a := range parsed.MediaDescriptions[0].Attributes {
+		if id, value, ok := parseSSRCAttribute(a); ok {
+			if id == ssrc {
+				mediaAttrs = append(mediaAttrs, value)
+			} else {
+				require.Equal(t, uint32(5678), id)
+				rtxAttrs = append(rtxAttrs, value)
+			}
+		}
+	}
+	require.NotEmpty(t, mediaAttrs)
+	require.Equal(t, mediaAttrs, rtxAttrs)
+
+	// answered with RTX
+	answerer, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	require.NoError(t, err)
+	defer answerer.Close()
+	require.NoError(t, answerer.SetRemoteDescription(offer))
+	answer, err := answerer.CreateAnswer(nil)
+	require.NoError(t, err)
+	require.NoError(t, answerer.SetLocalDescription(answer))
+	require.Contains(t, answer.SDP, "a=fmtp:97 apt=96")
+	require.Len(t, answerer.GetTransceivers(), 1)
+}
+
+func TestMaxSubscribeBitrate(t *testing.T) {
+	subscriber, err := NewPCTransport(TransportParams{
+		ParticipantID:       "sub",
+		ParticipantIdentity: "sub",
+		Target:              livekit.SignalTarget_SUBSCRIBER,
+		Config:              &WebRTCConfig{},
+		EnabledCodecs:       []*livekit.Codec{{Mime: webrtc.MimeTypeVP8}, {Mime: webrtc.MimeTypeOpus}},
+	})
+	require.NoError(t, err)
+	defer subscriber.Close()
+
+	video, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, "video", "stream")
+	require.NoError(t, err)
+	_, _, err = subscriber.GetTransceiverForSending(video)
+	require.NoError(t, err)
+	audio, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}, "audio", "stream")
+	require.NoError(t, err)
+	_, _, err = subscriber.GetTransceiverForSending(audio)
+	require.NoError(t, err)
+
+	// rounded up to kbps, on video sections only
+	subscriber.SetMaxSubscribeBitrate(1_499_500)
+	offers := make(chan webrtc.SessionDescription, 1)
+	subscriber.OnOffer(func(sd webrtc.SessionDescription) { offers <- sd })
+	require.NoError(t, subscriber.CreateAndSendOffer(nil))
+	offer := <-offers
+
+	parsed, err := offer.Unmarshal()
+	require.NoError(t, err)
+	require.Len(t, parsed.MediaDescriptions, 2)
+	for _, m := range parsed.MediaDescriptions {
+		if m.MediaName.Media == "video" {
+			require.Equal(t, []sdp.Bandwidth{{Type: "AS", Bandwidth: 1500}}, m.Bandwidth)
+		} else {
+			require.Empty(t, m.Bandwidth)
+		}
+	}
+
+	// replaces a bandwidth set before
+	require.NoError(t, setVideoBandwidth(&offer, 800_000))
+	require.Contains(t, offer.SDP, "b=AS:800")
+	require.NotContains(t, offer.SDP, "b=AS:1500")
+	require.Equal(t, 1, strings.Count(offer.SDP, "b=AS:"))
+}
+
 func handleOfferFunc(t *testing.T, current, other *PCTransport) func(sd webrtc.SessionDescription) {
 	return func(sd webrtc.SessionDescription) {
 		t.Logf("handling offer")
@@ -154,3 +1525,33 @@ func handleICEExchange(t *testing.T, a, b *PCTransport) {
 		require.NoError(t, a.AddICECandidate(candidate.ToJSON()))
 	})
 }
+
+func TestICETimeouts(t *testing.T) {
+	conf, err := config.NewConfig(`rtc:
+  ice_timeouts:
+    disconnected: 10s
+    keepalive_interval: 3s`, nil)
+	require.NoError(t, err)
+	// avoid listening on the TCP mux port
+	conf.RTC.TCPPort = 0
+	rtcConf, err := NewWebRTCConfig(conf, "")
+	require.NoError(t, err)
+
+	for _, target := range []livekit.SignalTarget{livekit.SignalTarget_PUBLISHER, livekit.SignalTarget_SUBSCRIBER} {
+		transport, err := NewPCTransport(TransportParams{
+			ParticipantID:       "id",
+			ParticipantIdentity: "identity",
+			Target:              target,
+			Config:              rtcConf,
+		})
+		require.NoError(t, err)
+
+		// settings are not exposed by pion, read them from the peer connection's setting engine
+		timeouts := reflect.ValueOf(transport.pc).Elem().FieldByName("api").Elem().
+			FieldByName("settingEngine").Elem().FieldByName("timeout")
+		require.Equal(t, 10*time.Second, time.Duration(timeouts.FieldByName("ICEDisconnectedTimeout").Elem().Int()))
+		require.Equal(t, 25*time.Second, time.Duration(timeouts.FieldByName("ICEFailedTimeout").Elem().Int()))
+		require.Equal(t, 3*time.Second, time.Duration(timeouts.FieldByName("ICEKeepaliveInterval").Elem().Int()))
+		transport.Close()
+	}
+}
@@ -27,6 +27,16 @@ type AddSubscriberParams struct {
 	TrackIDs  []livekit.TrackID
 }
 
+// SubscriptionLayerCap limits the video layers forwarded on a subscription, on top of the subscriber's own track settings
+type SubscriptionLayerCap struct {
+	// highest layers forwarded, InvalidLayerSpatial/InvalidLayerTemporal leave the layer uncapped
+	MaxSpatialLayer  int32 `json:"max_spatial_layer"`
+	MaxTemporalLayer int32 `json:"max_temporal_layer"`
+	// dimensions the track is rendered at, caps the spatial layer to the closest one when set
+	Width  uint32 `json:"width,omitempty"`
+	Height uint32 `json:"height,omitempty"`
+}
+
 type MigrateState int32
 
 const (
@@ -102,6 +112,7 @@ type LocalParticipant interface {
 	// permissions
 	ClaimGrants() *auth.ClaimGrants
 	SetPermission(permission *livekit.ParticipantPermission)
+	SetMaxSubscribeBitrate(bps uint64)
 	CanPublish() bool
 	CanSubscribe() bool
 	CanPublishData() bool
@@ -111,19 +122,24 @@ type LocalParticipant interface {
 	HandleOffer(sdp webrtc.SessionDescription) (answer webrtc.SessionDescription, err error)
 
 	AddTrack(req *livekit.AddTrackRequest)
+	RejectTrack(req *livekit.AddTrackRequest)
 	SetTrackMuted(trackID livekit.TrackID, muted bool, fromAdmin bool)
 
 	SubscriberMediaEngine() *webrtc.MediaEngine
 	SubscriberPC() *webrtc.PeerConnection
+	GetSubscriberTransceiverForSending(track webrtc.TrackLocal) (*webrtc.RTPTransceiver, *webrtc.RTPSender, error)
 	HandleAnswer(sdp webrtc.SessionDescription) error
 	Negotiate()
 	ICERestart() error
 	AddSubscribedTrack(st SubscribedTrack)
 	RemoveSubscribedTrack(st SubscribedTrack)
 	UpdateSubscribedTrackSettings(trackID livekit.TrackID, settings *livekit.UpdateTrackSettings) error
+	// caps the layers of a subscribed track, nil removes the cap. Kept for tracks subscribed later
+	UpdateSubscribedTrackLayerCap(trackID livekit.TrackID, layerCap *SubscriptionLayerCap)
 
 	// returns list of participant identities that the current participant is subscribed to
 	GetSubscribedParticipants() []livekit.ParticipantID
+	GetSubscribedTracks() []SubscribedTrack
 
 	GetAudioLevel() (level uint8, active bool)
 	GetConnectionQuality() *livekit.ConnectionQualityInfo
@@ -234,7 +250,11 @@ type SubscribedTrack interface {
 	MediaTrack() MediaTrack
 	IsMuted() bool
 	SetPublisherMuted(muted bool)
+	// pauses forwarding without the subscriber seeing the track muted
+	SetForwardingPaused(paused bool)
+	IsForwardingPaused() bool
 	UpdateSubscriberSettings(settings *livekit.UpdateTrackSettings)
+	SetLayerCap(layerCap *SubscriptionLayerCap)
 	// selects appropriate video layer according to subscriber preferences
 	UpdateVideoLayer()
 }
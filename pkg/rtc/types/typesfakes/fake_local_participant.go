@@ -204,6 +204,31 @@ type FakeLocalParticipant struct {
 	getSubscribedParticipantsReturnsOnCall map[int]struct {
 		result1 []livekit.ParticipantID
 	}
+	GetSubscribedTracksStub        func() []types.SubscribedTrack
+	getSubscribedTracksMutex       sync.RWMutex
+	getSubscribedTracksArgsForCall []struct {
+	}
+	getSubscribedTracksReturns struct {
+		result1 []types.SubscribedTrack
+	}
+	getSubscribedTracksReturnsOnCall map[int]struct {
+		result1 []types.SubscribedTrack
+	}
+	GetSubscriberTransceiverForSendingStub        func(webrtc.TrackLocal) (*webrtc.RTPTransceiver, *webrtc.RTPSender, error)
+	getSubscriberTransceiverForSendingMutex       sync.RWMutex
+	getSubscriberTransceiverForSendingArgsForCall []struct {
+		arg1 webrtc.TrackLocal
+	}
+	getSubscriberTransceiverForSendingReturns struct {
+		result1 *webrtc.RTPTransceiver
+		result2 *webrtc.RTPSender
+		result3 error
+	}
+	getSubscriberTransceiverForSendingReturnsOnCall map[int]struct {
+		result1 *webrtc.RTPTransceiver
+		result2 *webrtc.RTPSender
+		result3 error
+	}
 	HandleAnswerStub        func(webrtc.SessionDescription) error
 	handleAnswerMutex       sync.RWMutex
 	handleAnswerArgsForCall []struct {
@@ -347,6 +372,11 @@ type FakeLocalParticipant struct {
 	protocolVersionReturnsOnCall map[int]struct {
 		result1 types.ProtocolVersion
 	}
+	RejectTrackStub        func(*livekit.AddTrackRequest)
+	rejectTrackMutex       sync.RWMutex
+	rejectTrackArgsForCall []struct {
+		arg1 *livekit.AddTrackRequest
+	}
 	RemoveSubscribedTrackStub        func(types.SubscribedTrack)
 	removeSubscribedTrackMutex       sync.RWMutex
 	removeSubscribedTrackArgsForCall []struct {
@@ -439,6 +469,11 @@ type FakeLocalParticipant struct {
 	sendSpeakerUpdateReturnsOnCall map[int]struct {
 		result1 error
 	}
+	SetMaxSubscribeBitrateStub        func(uint64)
+	setMaxSubscribeBitrateMutex       sync.RWMutex
+	setMaxSubscribeBitrateArgsForCall []struct {
+		arg1 uint64
+	}
 	SetMetadataStub        func(string)
 	setMetadataMutex       sync.RWMutex
 	setMetadataArgsForCall []struct {
@@ -579,6 +614,12 @@ type FakeLocalParticipant struct {
 	updateSubscribedQualityReturnsOnCall map[int]struct {
 		result1 error
 	}
+	UpdateSubscribedTrackLayerCapStub        func(livekit.TrackID, *types.SubscriptionLayerCap)
+	updateSubscribedTrackLayerCapMutex       sync.RWMutex
+	updateSubscribedTrackLayerCapArgsForCall []struct {
+		arg1 livekit.TrackID
+		arg2 *types.SubscriptionLayerCap
+	}
 	UpdateSubscribedTrackSettingsStub        func(livekit.TrackID, *livekit.UpdateTrackSettings) error
 	updateSubscribedTrackSettingsMutex       sync.RWMutex
 	updateSubscribedTrackSettingsArgsForCall []struct {
@@ -1623,6 +1664,126 @@ func (fake *FakeLocalParticipant) GetSubscribedParticipantsReturnsOnCall(i int,
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) GetSubscribedTracks() []types.SubscribedTrack {
+	fake.getSubscribedTracksMutex.Lock()
+	ret, specificReturn := fake.getSubscribedTracksReturnsOnCall[len(fake.getSubscribedTracksArgsForCall)]
+	fake.getSubscribedTracksArgsForCall = append(fake.getSubscribedTracksArgsForCall, struct {
+	}{})
+	stub := fake.GetSubscribedTracksStub
+	fakeReturns := fake.getSubscribedTracksReturns
+	fake.recordInvocation("GetSubscribedTracks", []interface{}{})
+	fake.getSubscribedTracksMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeLocalParticipant) GetSubscribedTracksCallCount() int {
+	fake.getSubscribedTracksMutex.RLock()
+	defer fake.getSubscribedTracksMutex.RUnlock()
+	return len(fake.getSubscribedTracksArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) GetSubscribedTracksCalls(stub func() []types.SubscribedTrack) {
+	fake.getSubscribedTracksMutex.Lock()
+	defer fake.getSubscribedTracksMutex.Unlock()
+	fake.GetSubscribedTracksStub = stub
+}
+
+func (fake *FakeLocalParticipant) GetSubscribedTracksReturns(result1 []types.SubscribedTrack) {
+	fake.getSubscribedTracksMutex.Lock()
+	defer fake.getSubscribedTracksMutex.Unlock()
+	fake.GetSubscribedTracksStub = nil
+	fake.getSubscribedTracksReturns = struct {
+		result1 []types.SubscribedTrack
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) GetSubscribedTracksReturnsOnCall(i int, result1 []types.SubscribedTrack) {
+	fake.getSubscribedTracksMutex.Lock()
+	defer fake.getSubscribedTracksMutex.Unlock()
+	fake.GetSubscribedTracksStub = nil
+	if fake.getSubscribedTracksReturnsOnCall == nil {
+		fake.getSubscribedTracksReturnsOnCall = make(map[int]struct {
+			result1 []types.SubscribedTrack
+		})
+	}
+	fake.getSubscribedTracksReturnsOnCall[i] = struct {
+		result1 []types.SubscribedTrack
+	}{result1}
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberTransceiverForSending(arg1 webrtc.TrackLocal) (*webrtc.RTPTransceiver, *webrtc.RTPSender, error) {
+	fake.getSubscriberTransceiverForSendingMutex.Lock()
+	ret, specificReturn := fake.getSubscriberTransceiverForSendingReturnsOnCall[len(fake.getSubscriberTransceiverForSendingArgsForCall)]
+	fake.getSubscriberTransceiverForSendingArgsForCall = append(fake.getSubscriberTransceiverForSendingArgsForCall, struct {
+		arg1 webrtc.TrackLocal
+	}{arg1})
+	stub := fake.GetSubscriberTransceiverForSendingStub
+	fakeReturns := fake.getSubscriberTransceiverForSendingReturns
+	fake.recordInvocation("GetSubscriberTransceiverForSending", []interface{}{arg1})
+	fake.getSubscriberTransceiverForSendingMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberTransceiverForSendingCallCount() int {
+	fake.getSubscriberTransceiverForSendingMutex.RLock()
+	defer fake.getSubscriberTransceiverForSendingMutex.RUnlock()
+	return len(fake.getSubscriberTransceiverForSendingArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberTransceiverForSendingCalls(stub func(webrtc.TrackLocal) (*webrtc.RTPTransceiver, *webrtc.RTPSender, error)) {
+	fake.getSubscriberTransceiverForSendingMutex.Lock()
+	defer fake.getSubscriberTransceiverForSendingMutex.Unlock()
+	fake.GetSubscriberTransceiverForSendingStub = stub
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberTransceiverForSendingArgsForCall(i int) webrtc.TrackLocal {
+	fake.getSubscriberTransceiverForSendingMutex.RLock()
+	defer fake.getSubscriberTransceiverForSendingMutex.RUnlock()
+	argsForCall := fake.getSubscriberTransceiverForSendingArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberTransceiverForSendingReturns(result1 *webrtc.RTPTransceiver, result2 *webrtc.RTPSender, result3 error) {
+	fake.getSubscriberTransceiverForSendingMutex.Lock()
+	defer fake.getSubscriberTransceiverForSendingMutex.Unlock()
+	fake.GetSubscriberTransceiverForSendingStub = nil
+	fake.getSubscriberTransceiverForSendingReturns = struct {
+		result1 *webrtc.RTPTransceiver
+		result2 *webrtc.RTPSender
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeLocalParticipant) GetSubscriberTransceiverForSendingReturnsOnCall(i int, result1 *webrtc.RTPTransceiver, result2 *webrtc.RTPSender, result3 error) {
+	fake.getSubscriberTransceiverForSendingMutex.Lock()
+	defer fake.getSubscriberTransceiverForSendingMutex.Unlock()
+	fake.GetSubscriberTransceiverForSendingStub = nil
+	if fake.getSubscriberTransceiverForSendingReturnsOnCall == nil {
+		fake.getSubscriberTransceiverForSendingReturnsOnCall = make(map[int]struct {
+			result1 *webrtc.RTPTransceiver
+			result2 *webrtc.RTPSender
+			result3 error
+		})
+	}
+	fake.getSubscriberTransceiverForSendingReturnsOnCall[i] = struct {
+		result1 *webrtc.RTPTransceiver
+		result2 *webrtc.RTPSender
+		result3 error
+	}{result1, result2, result3}
+}
+
 func (fake *FakeLocalParticipant) HandleAnswer(arg1 webrtc.SessionDescription) error {
 	fake.handleAnswerMutex.Lock()
 	ret, specificReturn := fake.handleAnswerReturnsOnCall[len(fake.handleAnswerArgsForCall)]
@@ -2420,6 +2581,38 @@ func (fake *FakeLocalParticipant) ProtocolVersionReturnsOnCall(i int, result1 ty
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) RejectTrack(arg1 *livekit.AddTrackRequest) {
+	fake.rejectTrackMutex.Lock()
+	fake.rejectTrackArgsForCall = append(fake.rejectTrackArgsForCall, struct {
+		arg1 *livekit.AddTrackRequest
+	}{arg1})
+	stub := fake.RejectTrackStub
+	fake.recordInvocation("RejectTrack", []interface{}{arg1})
+	fake.rejectTrackMutex.Unlock()
+	if stub != nil {
+		fake.RejectTrackStub(arg1)
+	}
+}
+
+func (fake *FakeLocalParticipant) RejectTrackCallCount() int {
+	fake.rejectTrackMutex.RLock()
+	defer fake.rejectTrackMutex.RUnlock()
+	return len(fake.rejectTrackArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) RejectTrackCalls(stub func(*livekit.AddTrackRequest)) {
+	fake.rejectTrackMutex.Lock()
+	defer fake.rejectTrackMutex.Unlock()
+	fake.RejectTrackStub = stub
+}
+
+func (fake *FakeLocalParticipant) RejectTrackArgsForCall(i int) *livekit.AddTrackRequest {
+	fake.rejectTrackMutex.RLock()
+	defer fake.rejectTrackMutex.RUnlock()
+	argsForCall := fake.rejectTrackArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeLocalParticipant) RemoveSubscribedTrack(arg1 types.SubscribedTrack) {
 	fake.removeSubscribedTrackMutex.Lock()
 	fake.removeSubscribedTrackArgsForCall = append(fake.removeSubscribedTrackArgsForCall, struct {
@@ -2434,6 +2627,8 @@ func (fake *FakeLocalParticipant) RemoveSubscribedTrack(arg1 types.SubscribedTra
 }
 
 func (fake *FakeLocalParticipant) RemoveSubscribedTrackCallCount() int {
+	fake.rejectTrackMutex.RLock()
+	defer fake.rejectTrackMutex.RUnlock()
 	fake.removeSubscribedTrackMutex.RLock()
 	defer fake.removeSubscribedTrackMutex.RUnlock()
 	return len(fake.removeSubscribedTrackArgsForCall)
@@ -2936,6 +3131,38 @@ func (fake *FakeLocalParticipant) SendSpeakerUpdateReturnsOnCall(i int, result1
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) SetMaxSubscribeBitrate(arg1 uint64) {
+	fake.setMaxSubscribeBitrateMutex.Lock()
+	fake.setMaxSubscribeBitrateArgsForCall = append(fake.setMaxSubscribeBitrateArgsForCall, struct {
+		arg1 uint64
+	}{arg1})
+	stub := fake.SetMaxSubscribeBitrateStub
+	fake.recordInvocation("SetMaxSubscribeBitrate", []interface{}{arg1})
+	fake.setMaxSubscribeBitrateMutex.Unlock()
+	if stub != nil {
+		fake.SetMaxSubscribeBitrateStub(arg1)
+	}
+}
+
+func (fake *FakeLocalParticipant) SetMaxSubscribeBitrateCallCount() int {
+	fake.setMaxSubscribeBitrateMutex.RLock()
+	defer fake.setMaxSubscribeBitrateMutex.RUnlock()
+	return len(fake.setMaxSubscribeBitrateArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) SetMaxSubscribeBitrateCalls(stub func(uint64)) {
+	fake.setMaxSubscribeBitrateMutex.Lock()
+	defer fake.setMaxSubscribeBitrateMutex.Unlock()
+	fake.SetMaxSubscribeBitrateStub = stub
+}
+
+func (fake *FakeLocalParticipant) SetMaxSubscribeBitrateArgsForCall(i int) uint64 {
+	fake.setMaxSubscribeBitrateMutex.RLock()
+	defer fake.setMaxSubscribeBitrateMutex.RUnlock()
+	argsForCall := fake.setMaxSubscribeBitrateArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeLocalParticipant) SetMetadata(arg1 string) {
 	fake.setMetadataMutex.Lock()
 	fake.setMetadataArgsForCall = append(fake.setMetadataArgsForCall, struct {
@@ -3707,6 +3934,39 @@ func (fake *FakeLocalParticipant) UpdateSubscribedQualityReturnsOnCall(i int, re
 	}{result1}
 }
 
+func (fake *FakeLocalParticipant) UpdateSubscribedTrackLayerCap(arg1 livekit.TrackID, arg2 *types.SubscriptionLayerCap) {
+	fake.updateSubscribedTrackLayerCapMutex.Lock()
+	fake.updateSubscribedTrackLayerCapArgsForCall = append(fake.updateSubscribedTrackLayerCapArgsForCall, struct {
+		arg1 livekit.TrackID
+		arg2 *types.SubscriptionLayerCap
+	}{arg1, arg2})
+	stub := fake.UpdateSubscribedTrackLayerCapStub
+	fake.recordInvocation("UpdateSubscribedTrackLayerCap", []interface{}{arg1, arg2})
+	fake.updateSubscribedTrackLayerCapMutex.Unlock()
+	if stub != nil {
+		fake.UpdateSubscribedTrackLayerCapStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeLocalParticipant) UpdateSubscribedTrackLayerCapCallCount() int {
+	fake.updateSubscribedTrackLayerCapMutex.RLock()
+	defer fake.updateSubscribedTrackLayerCapMutex.RUnlock()
+	return len(fake.updateSubscribedTrackLayerCapArgsForCall)
+}
+
+func (fake *FakeLocalParticipant) UpdateSubscribedTrackLayerCapCalls(stub func(livekit.TrackID, *types.SubscriptionLayerCap)) {
+	fake.updateSubscribedTrackLayerCapMutex.Lock()
+	defer fake.updateSubscribedTrackLayerCapMutex.Unlock()
+	fake.UpdateSubscribedTrackLayerCapStub = stub
+}
+
+func (fake *FakeLocalParticipant) UpdateSubscribedTrackLayerCapArgsForCall(i int) (livekit.TrackID, *types.SubscriptionLayerCap) {
+	fake.updateSubscribedTrackLayerCapMutex.RLock()
+	defer fake.updateSubscribedTrackLayerCapMutex.RUnlock()
+	argsForCall := fake.updateSubscribedTrackLayerCapArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
 func (fake *FakeLocalParticipant) UpdateSubscribedTrackSettings(arg1 livekit.TrackID, arg2 *livekit.UpdateTrackSettings) error {
 	fake.updateSubscribedTrackSettingsMutex.Lock()
 	ret, specificReturn := fake.updateSubscribedTrackSettingsReturnsOnCall[len(fake.updateSubscribedTrackSettingsArgsForCall)]
@@ -3933,6 +4193,10 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.getResponseSinkMutex.RUnlock()
 	fake.getSubscribedParticipantsMutex.RLock()
 	defer fake.getSubscribedParticipantsMutex.RUnlock()
+	fake.getSubscribedTracksMutex.RLock()
+	defer fake.getSubscribedTracksMutex.RUnlock()
+	fake.getSubscriberTransceiverForSendingMutex.RLock()
+	defer fake.getSubscriberTransceiverForSendingMutex.RUnlock()
 	fake.handleAnswerMutex.RLock()
 	defer fake.handleAnswerMutex.RUnlock()
 	fake.handleOfferMutex.RLock()
@@ -3987,6 +4251,8 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.sendRoomUpdateMutex.RUnlock()
 	fake.sendSpeakerUpdateMutex.RLock()
 	defer fake.sendSpeakerUpdateMutex.RUnlock()
+	fake.setMaxSubscribeBitrateMutex.RLock()
+	defer fake.setMaxSubscribeBitrateMutex.RUnlock()
 	fake.setMetadataMutex.RLock()
 	defer fake.setMetadataMutex.RUnlock()
 	fake.setMigrateInfoMutex.RLock()
@@ -4023,6 +4289,8 @@ func (fake *FakeLocalParticipant) Invocations() map[string][][]interface{} {
 	defer fake.updateRTTMutex.RUnlock()
 	fake.updateSubscribedQualityMutex.RLock()
 	defer fake.updateSubscribedQualityMutex.RUnlock()
+	fake.updateSubscribedTrackLayerCapMutex.RLock()
+	defer fake.updateSubscribedTrackLayerCapMutex.RUnlock()
 	fake.updateSubscribedTrackSettingsMutex.RLock()
 	defer fake.updateSubscribedTrackSettingsMutex.RUnlock()
 	fake.updateSubscriptionPermissionMutex.RLock()
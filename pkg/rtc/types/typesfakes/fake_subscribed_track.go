@@ -30,6 +30,16 @@ type FakeSubscribedTrack struct {
 	iDReturnsOnCall map[int]struct {
 		result1 livekit.TrackID
 	}
+	IsForwardingPausedStub        func() bool
+	isForwardingPausedMutex       sync.RWMutex
+	isForwardingPausedArgsForCall []struct {
+	}
+	isForwardingPausedReturns struct {
+		result1 bool
+	}
+	isForwardingPausedReturnsOnCall map[int]struct {
+		result1 bool
+	}
 	IsMutedStub        func() bool
 	isMutedMutex       sync.RWMutex
 	isMutedArgsForCall []struct {
@@ -75,6 +85,16 @@ type FakeSubscribedTrack struct {
 	publisherIdentityReturnsOnCall map[int]struct {
 		result1 livekit.ParticipantIdentity
 	}
+	SetForwardingPausedStub        func(bool)
+	setForwardingPausedMutex       sync.RWMutex
+	setForwardingPausedArgsForCall []struct {
+		arg1 bool
+	}
+	SetLayerCapStub        func(*types.SubscriptionLayerCap)
+	setLayerCapMutex       sync.RWMutex
+	setLayerCapArgsForCall []struct {
+		arg1 *types.SubscriptionLayerCap
+	}
 	SetPublisherMutedStub        func(bool)
 	setPublisherMutedMutex       sync.RWMutex
 	setPublisherMutedArgsForCall []struct {
@@ -209,6 +229,59 @@ func (fake *FakeSubscribedTrack) IDReturnsOnCall(i int, result1 livekit.TrackID)
 	}{result1}
 }
 
+func (fake *FakeSubscribedTrack) IsForwardingPaused() bool {
+	fake.isForwardingPausedMutex.Lock()
+	ret, specificReturn := fake.isForwardingPausedReturnsOnCall[len(fake.isForwardingPausedArgsForCall)]
+	fake.isForwardingPausedArgsForCall = append(fake.isForwardingPausedArgsForCall, struct {
+	}{})
+	stub := fake.IsForwardingPausedStub
+	fakeReturns := fake.isForwardingPausedReturns
+	fake.recordInvocation("IsForwardingPaused", []interface{}{})
+	fake.isForwardingPausedMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeSubscribedTrack) IsForwardingPausedCallCount() int {
+	fake.isForwardingPausedMutex.RLock()
+	defer fake.isForwardingPausedMutex.RUnlock()
+	return len(fake.isForwardingPausedArgsForCall)
+}
+
+func (fake *FakeSubscribedTrack) IsForwardingPausedCalls(stub func() bool) {
+	fake.isForwardingPausedMutex.Lock()
+	defer fake.isForwardingPausedMutex.Unlock()
+	fake.IsForwardingPausedStub = stub
+}
+
+func (fake *FakeSubscribedTrack) IsForwardingPausedReturns(result1 bool) {
+	fake.isForwardingPausedMutex.Lock()
+	defer fake.isForwardingPausedMutex.Unlock()
+	fake.IsForwardingPausedStub = nil
+	fake.isForwardingPausedReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeSubscribedTrack) IsForwardingPausedReturnsOnCall(i int, result1 bool) {
+	fake.isForwardingPausedMutex.Lock()
+	defer fake.isForwardingPausedMutex.Unlock()
+	fake.IsForwardingPausedStub = nil
+	if fake.isForwardingPausedReturnsOnCall == nil {
+		fake.isForwardingPausedReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.isForwardingPausedReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
 func (fake *FakeSubscribedTrack) IsMuted() bool {
 	fake.isMutedMutex.Lock()
 	ret, specificReturn := fake.isMutedReturnsOnCall[len(fake.isMutedArgsForCall)]
@@ -453,6 +526,70 @@ func (fake *FakeSubscribedTrack) PublisherIdentityReturnsOnCall(i int, result1 l
 	}{result1}
 }
 
+func (fake *FakeSubscribedTrack) SetForwardingPaused(arg1 bool) {
+	fake.setForwardingPausedMutex.Lock()
+	fake.setForwardingPausedArgsForCall = append(fake.setForwardingPausedArgsForCall, struct {
+		arg1 bool
+	}{arg1})
+	stub := fake.SetForwardingPausedStub
+	fake.recordInvocation("SetForwardingPaused", []interface{}{arg1})
+	fake.setForwardingPausedMutex.Unlock()
+	if stub != nil {
+		fake.SetForwardingPausedStub(arg1)
+	}
+}
+
+func (fake *FakeSubscribedTrack) SetForwardingPausedCallCount() int {
+	fake.setForwardingPausedMutex.RLock()
+	defer fake.setForwardingPausedMutex.RUnlock()
+	return len(fake.setForwardingPausedArgsForCall)
+}
+
+func (fake *FakeSubscribedTrack) SetForwardingPausedCalls(stub func(bool)) {
+	fake.setForwardingPausedMutex.Lock()
+	defer fake.setForwardingPausedMutex.Unlock()
+	fake.SetForwardingPausedStub = stub
+}
+
+func (fake *FakeSubscribedTrack) SetForwardingPausedArgsForCall(i int) bool {
+	fake.setForwardingPausedMutex.RLock()
+	defer fake.setForwardingPausedMutex.RUnlock()
+	argsForCall := fake.setForwardingPausedArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeSubscribedTrack) SetLayerCap(arg1 *types.SubscriptionLayerCap) {
+	fake.setLayerCapMutex.Lock()
+	fake.setLayerCapArgsForCall = append(fake.setLayerCapArgsForCall, struct {
+		arg1 *types.SubscriptionLayerCap
+	}{arg1})
+	stub := fake.SetLayerCapStub
+	fake.recordInvocation("SetLayerCap", []interface{}{arg1})
+	fake.setLayerCapMutex.Unlock()
+	if stub != nil {
+		fake.SetLayerCapStub(arg1)
+	}
+}
+
+func (fake *FakeSubscribedTrack) SetLayerCapCallCount() int {
+	fake.setLayerCapMutex.RLock()
+	defer fake.setLayerCapMutex.RUnlock()
+	return len(fake.setLayerCapArgsForCall)
+}
+
+func (fake *FakeSubscribedTrack) SetLayerCapCalls(stub func(*types.SubscriptionLayerCap)) {
+	fake.setLayerCapMutex.Lock()
+	defer fake.setLayerCapMutex.Unlock()
+	fake.SetLayerCapStub = stub
+}
+
+func (fake *FakeSubscribedTrack) SetLayerCapArgsForCall(i int) *types.SubscriptionLayerCap {
+	fake.setLayerCapMutex.RLock()
+	defer fake.setLayerCapMutex.RUnlock()
+	argsForCall := fake.setLayerCapArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *FakeSubscribedTrack) SetPublisherMuted(arg1 bool) {
 	fake.setPublisherMutedMutex.Lock()
 	fake.setPublisherMutedArgsForCall = append(fake.setPublisherMutedArgsForCall, struct {
@@ -601,6 +738,8 @@ func (fake *FakeSubscribedTrack) Invocations() map[string][][]interface{} {
 	defer fake.downTrackMutex.RUnlock()
 	fake.iDMutex.RLock()
 	defer fake.iDMutex.RUnlock()
+	fake.isForwardingPausedMutex.RLock()
+	defer fake.isForwardingPausedMutex.RUnlock()
 	fake.isMutedMutex.RLock()
 	defer fake.isMutedMutex.RUnlock()
 	fake.mediaTrackMutex.RLock()
@@ -611,6 +750,10 @@ func (fake *FakeSubscribedTrack) Invocations() map[string][][]interface{} {
 	defer fake.publisherIDMutex.RUnlock()
 	fake.publisherIdentityMutex.RLock()
 	defer fake.publisherIdentityMutex.RUnlock()
+	fake.setForwardingPausedMutex.RLock()
+	defer fake.setForwardingPausedMutex.RUnlock()
+	fake.setLayerCapMutex.RLock()
+	defer fake.setLayerCapMutex.RUnlock()
 	fake.setPublisherMutedMutex.RLock()
 	defer fake.setPublisherMutedMutex.RUnlock()
 	fake.subscriberIDMutex.RLock()
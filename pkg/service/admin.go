@@ -0,0 +1,69 @@
+package service
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// AdminServer serves the node's admin endpoints. Unlike the rest of the API, they don't take access tokens: requests
+// authenticate with the admin credentials of the config, using HTTP basic auth
+type AdminServer struct {
+	conf  *config.Config
+	mux   *http.ServeMux
+	paths []string
+}
+
+func NewAdminServer(conf *config.Config) *AdminServer {
+	s := &AdminServer{
+		conf: conf,
+		mux:  http.NewServeMux(),
+	}
+	s.HandleFunc("/debug/config", s.debugConfig)
+	return s
+}
+
+// HandleFunc serves an admin endpoint, only called for authenticated requests
+func (s *AdminServer) HandleFunc(path string, handler http.HandlerFunc) {
+	s.mux.HandleFunc(path, handler)
+	s.paths = append(s.paths, path)
+}
+
+// Paths returns the paths served by the admin server, to route them past the access token middlewares
+func (s *AdminServer) Paths() []string {
+	return s.paths
+}
+
+func (s *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="livekit admin"`)
+		handleError(w, http.StatusUnauthorized, "admin credentials required")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *AdminServer) authenticated(r *http.Request) bool {
+	if !s.conf.Admin.Enabled() {
+		return false
+	}
+	apiKey, apiSecret, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	// both are compared, whatever the key, so the time taken doesn't tell which one was wrong
+	keyMatches := subtle.ConstantTimeCompare([]byte(apiKey), []byte(s.conf.Admin.APIKey))
+	secretMatches := subtle.ConstantTimeCompare([]byte(apiSecret), []byte(s.conf.Admin.APISecret))
+	return keyMatches&secretMatches == 1
+}
+
+func (s *AdminServer) debugConfig(w http.ResponseWriter, _ *http.Request) {
+	out, err := s.conf.DumpYAML()
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write([]byte(out))
+}
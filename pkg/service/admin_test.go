@@ -0,0 +1,88 @@
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+const adminTestConfig = `keys:
+  key1: apisecret1
+redis:
+  address: redis.host:6379
+  password: redispassword
+admin:
+  api_key: admin
+  api_secret: adminsecret
+`
+
+func TestAdminServerAuth(t *testing.T) {
+	conf, err := config.NewConfig(adminTestConfig, nil)
+	require.NoError(t, err)
+	s := service.NewAdminServer(conf)
+
+	request := func(apiKey, apiSecret string) int {
+		r := httptest.NewRequest(http.MethodGet, "/debug/config", nil)
+		if apiKey != "" || apiSecret != "" {
+			r.SetBasicAuth(apiKey, apiSecret)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, request("", ""))
+	require.Equal(t, http.StatusUnauthorized, request("admin", "wrong"))
+	require.Equal(t, http.StatusUnauthorized, request("key1", "apisecret1"))
+	require.Equal(t, http.StatusOK, request("admin", "adminsecret"))
+
+	t.Run("refuses every request while the credentials are unset", func(t *testing.T) {
+		conf, err := config.NewConfig("keys:\n  key1: apisecret1\n", nil)
+		require.NoError(t, err)
+		s = service.NewAdminServer(conf)
+		require.Equal(t, http.StatusUnauthorized, request("", ""))
+	})
+}
+
+func TestAdminServerDrain(t *testing.T) {
+	conf, err := config.NewConfig(adminTestConfig, nil)
+	require.NoError(t, err)
+	s := service.NewAdminServer(conf)
+	drained := 0
+	s.HandleFunc("/drain", func(w http.ResponseWriter, r *http.Request) { drained++ })
+	require.Contains(t, s.Paths(), "/drain")
+
+	r := httptest.NewRequest(http.MethodPost, "/drain", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Zero(t, drained)
+
+	r = httptest.NewRequest(http.MethodPost, "/drain", nil)
+	r.SetBasicAuth("admin", "adminsecret")
+	s.ServeHTTP(httptest.NewRecorder(), r)
+	require.Equal(t, 1, drained)
+}
+
+func TestAdminServerConfigIsRedacted(t *testing.T) {
+	conf, err := config.NewConfig(adminTestConfig, nil)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/debug/config", nil)
+	r.SetBasicAuth("admin", "adminsecret")
+	w := httptest.NewRecorder()
+	service.NewAdminServer(conf).ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	out := w.Body.String()
+	for _, secret := range []string{"apisecret1", "redispassword", "adminsecret"} {
+		require.NotContains(t, out, secret)
+	}
+	require.Contains(t, out, "redis.host:6379")
+	require.Contains(t, out, "key1: '****'")
+}
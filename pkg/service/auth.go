@@ -5,8 +5,10 @@ import (
 	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/twitchtv/twirp"
+	"gopkg.in/square/go-jose.v2/jwt"
 
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
@@ -19,11 +21,27 @@ const (
 )
 
 type grantsKey struct{}
+type apiKeyKey struct{}
+type maxSubscribeBitrateKey struct{}
+type requestStartedAtKey struct{}
+
+// claims of the video grant that auth.VideoGrant doesn't have
+type videoGrantExtension struct {
+	Video *struct {
+		MaxSubscribeBitrate uint64 `json:"maxSubscribeBitrate,omitempty"`
+	} `json:"video,omitempty"`
+}
 
 var (
 	ErrPermissionDenied = errors.New("permissions denied")
 )
 
+// removedKeyProvider is implemented by key providers whose removed keys keep validating the tokens issued before
+// their removal for a while
+type removedKeyProvider interface {
+	RemovedAt(key string) (time.Time, bool)
+}
+
 // authentication middleware
 type APIKeyAuthMiddleware struct {
 	provider auth.KeyProvider
@@ -36,6 +54,7 @@ func NewAPIKeyAuthMiddleware(provider auth.KeyProvider) *APIKeyAuthMiddleware {
 }
 
 func (m *APIKeyAuthMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	startedAt := time.Now()
 	if r.URL != nil && r.URL.Path == "/rtc/validate" {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 	}
@@ -73,10 +92,21 @@ func (m *APIKeyAuthMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request,
 			handleError(w, http.StatusUnauthorized, "invalid token: "+authToken+", error: "+err.Error())
 			return
 		}
+		if p, ok := m.provider.(removedKeyProvider); ok {
+			if removedAt, removed := p.RemovedAt(v.APIKey()); removed && !issuedBefore(authToken, removedAt) {
+				handleError(w, http.StatusUnauthorized, "API key was removed")
+				return
+			}
+		}
 
 		// set grants in context
 		ctx := r.Context()
-		r = r.WithContext(context.WithValue(ctx, grantsKey{}, grants))
+		ctx = context.WithValue(ctx, grantsKey{}, grants)
+		if maxSubscribeBitrate := parseMaxSubscribeBitrate(authToken); maxSubscribeBitrate != 0 {
+			ctx = WithMaxSubscribeBitrate(ctx, maxSubscribeBitrate)
+		}
+		ctx = context.WithValue(ctx, requestStartedAtKey{}, startedAt)
+		r = r.WithContext(WithAPIKey(ctx, v.APIKey()))
 	}
 
 	next.ServeHTTP(w, r)
@@ -94,6 +124,63 @@ func WithGrants(ctx context.Context, grants *auth.ClaimGrants) context.Context {
 	return context.WithValue(ctx, grantsKey{}, grants)
 }
 
+// GetAPIKey returns the API key the request's token was signed with
+func GetAPIKey(ctx context.Context) string {
+	apiKey, _ := ctx.Value(apiKeyKey{}).(string)
+	return apiKey
+}
+
+func WithAPIKey(ctx context.Context, apiKey string) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, apiKey)
+}
+
+// GetMaxSubscribeBitrate returns the cap on the bitrate sent to the participant from the request's token, 0 for none
+func GetMaxSubscribeBitrate(ctx context.Context) uint64 {
+	maxSubscribeBitrate, _ := ctx.Value(maxSubscribeBitrateKey{}).(uint64)
+	return maxSubscribeBitrate
+}
+
+func WithMaxSubscribeBitrate(ctx context.Context, maxSubscribeBitrate uint64) context.Context {
+	return context.WithValue(ctx, maxSubscribeBitrateKey{}, maxSubscribeBitrate)
+}
+
+// GetRequestStartedAt returns when the request reached authentication, zero when it had no token
+func GetRequestStartedAt(ctx context.Context) time.Time {
+	startedAt, _ := ctx.Value(requestStartedAtKey{}).(time.Time)
+	return startedAt
+}
+
+// reads the video.maxSubscribeBitrate claim, the token has to be verified already
+func parseMaxSubscribeBitrate(token string) uint64 {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return 0
+	}
+	ext := videoGrantExtension{}
+	if err := tok.UnsafeClaimsWithoutVerification(&ext); err != nil || ext.Video == nil {
+		return 0
+	}
+	return ext.Video.MaxSubscribeBitrate
+}
+
+// issuedBefore is true for tokens issued before t, going by their iat claim, or nbf for tokens without one. The
+// claims are in seconds, tokens issued within the second of t are not considered before it
+func issuedBefore(token string, t time.Time) bool {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return false
+	}
+	claims := jwt.Claims{}
+	if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return false
+	}
+	issuedAt := claims.IssuedAt
+	if issuedAt == nil {
+		issuedAt = claims.NotBefore
+	}
+	return issuedAt != nil && issuedAt.Time().Before(t.Truncate(time.Second))
+}
+
 func SetAuthorizationToken(r *http.Request, token string) {
 	r.Header.Set(authorizationHeader, bearerPrefix+token)
 }
@@ -128,7 +215,7 @@ func EnsureAdminPermission(ctx context.Context, room livekit.RoomName) error {
 
 func EnsureCreatePermission(ctx context.Context) error {
 	claims := GetGrants(ctx)
-	if claims == nil {
+	if claims == nil || claims.Video == nil {
 		return ErrPermissionDenied
 	}
 
@@ -4,10 +4,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/auth/authfakes"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
 
 	"github.com/livekit/livekit-server/pkg/service"
 )
@@ -57,3 +60,61 @@ func TestAuthMiddleware(t *testing.T) {
 	require.Nil(t, grants)
 	require.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+func TestAuthMiddlewareMaxSubscribeBitrate(t *testing.T) {
+	api := "APIabcdefg"
+	secret := "somesecretencodedinbase62"
+	provider := &authfakes.FakeKeyProvider{}
+	provider.GetSecretReturns(secret)
+
+	m := service.NewAPIKeyAuthMiddleware(provider)
+	var maxSubscribeBitrate uint64
+	var grants *auth.ClaimGrants
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maxSubscribeBitrate = service.GetMaxSubscribeBitrate(r.Context())
+		grants = service.GetGrants(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// the video grant has no field for it, sign the claims directly
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte(secret)}, (&jose.SignerOptions{}).WithType("JWT"))
+	require.NoError(t, err)
+	token, err := jwt.Signed(sig).
+		Claims(jwt.Claims{
+			Issuer:    api,
+			Subject:   "participant",
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Expiry:    jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		}).
+		Claims(map[string]interface{}{
+			"video": map[string]interface{}{
+				"room":                "abcdefg",
+				"roomJoin":            true,
+				"maxSubscribeBitrate": 1500000,
+			},
+		}).
+		CompactSerialize()
+	require.NoError(t, err)
+
+	r := &http.Request{Header: http.Header{}}
+	w := httptest.NewRecorder()
+	service.SetAuthorizationToken(r, token)
+	m.ServeHTTP(w, r, handler)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, uint64(1500000), maxSubscribeBitrate)
+	require.Equal(t, "abcdefg", grants.Video.Room)
+
+	// not set by regular tokens
+	at := auth.NewAccessToken(api, secret).
+		AddGrant(&auth.VideoGrant{Room: "abcdefg", RoomJoin: true})
+	token, err = at.ToJWT()
+	require.NoError(t, err)
+
+	maxSubscribeBitrate = 1
+	r = &http.Request{Header: http.Header{}}
+	w = httptest.NewRecorder()
+	service.SetAuthorizationToken(r, token)
+	m.ServeHTTP(w, r, handler)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Zero(t, maxSubscribeBitrate)
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing/selector"
+)
+
+// ClientLocationHeader overrides where the client is, so that rooms it creates are placed in the nearest region.
+// Either "lat,lon" or the name of a region in node_selector.regions. Only used with
+// node_selector.client_location.trust_header
+const ClientLocationHeader = "X-LiveKit-Client-Location"
+
+type clientLocationKey struct{}
+
+// ClientLocator finds where clients are, from ClientLocationHeader then GeoIP
+type ClientLocator struct {
+	trustHeader    bool
+	trustedProxies []*net.IPNet
+	regions        map[string]*selector.Location
+	geoIP          *mmdbReader
+}
+
+// NewClientLocator fails on invalid GeoIP databases. Clients aren't located by IP when the database is missing,
+// so that nodes start while it's being downloaded
+func NewClientLocator(conf *config.Config) (*ClientLocator, error) {
+	l := &ClientLocator{
+		trustHeader: conf.NodeSelector.ClientLocation.TrustHeader,
+		regions:     make(map[string]*selector.Location),
+	}
+	for _, proxy := range conf.NodeSelector.ClientLocation.TrustedProxies {
+		ipNet, err := config.ParseIPNet(proxy)
+		if err != nil {
+			return nil, err
+		}
+		l.trustedProxies = append(l.trustedProxies, ipNet)
+	}
+	for _, region := range conf.NodeSelector.Regions {
+		l.regions[region.Name] = &selector.Location{Lat: region.Lat, Lon: region.Lon}
+	}
+
+	if file := conf.NodeSelector.GeoIPDB; file != "" {
+		db, err := openMMDB(file)
+		if os.IsNotExist(err) {
+			logger.Warnw("GeoIP database not found, clients won't be located by IP", err, "file", file)
+		} else if err != nil {
+			return nil, fmt.Errorf("could not open GeoIP database %s: %w", file, err)
+		} else {
+			l.geoIP = db
+		}
+	}
+	return l, nil
+}
+
+// ServeHTTP adds the location of the client to the request context, when it can be found
+func (l *ClientLocator) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if loc := l.Locate(r); loc != nil {
+		r = r.WithContext(WithClientLocation(r.Context(), loc))
+	}
+	next(w, r)
+}
+
+// Locate returns where the client sending r is, or nil when it's unknown
+func (l *ClientLocator) Locate(r *http.Request) *selector.Location {
+	if l.trustHeader {
+		if header := r.Header.Get(ClientLocationHeader); header != "" {
+			if loc := l.parseLocation(header); loc != nil {
+				return loc
+			}
+			logger.Debugw("ignoring invalid client location", "location", header)
+		}
+	}
+
+	if l.geoIP == nil {
+		return nil
+	}
+	ip := l.ClientIP(r)
+	// private addresses can't be located, the client is likely near this node
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+		return nil
+	}
+	record, err := l.geoIP.lookup(ip)
+	if err != nil {
+		logger.Warnw("could not look up client location", err, "ip", ip)
+		return nil
+	}
+	return locationOfRecord(record)
+}
+
+// ClientIP returns the address of the client sending r, or nil when it's invalid. X-Forwarded-For is followed from
+// the right only through trusted proxies, so that clients can't choose their address
+func (l *ClientLocator) ClientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !l.isTrustedProxy(ip) {
+		return ip
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if ip = net.ParseIP(strings.TrimSpace(hops[i])); ip == nil {
+			return nil
+		}
+		if !l.isTrustedProxy(ip) {
+			return ip
+		}
+	}
+	// every hop is a trusted proxy, or the header is missing
+	return ip
+}
+
+func (l *ClientLocator) isTrustedProxy(ip net.IP) bool {
+	for _, proxy := range l.trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *ClientLocator) parseLocation(value string) *selector.Location {
+	value = strings.TrimSpace(value)
+	if loc := l.regions[value]; loc != nil {
+		return loc
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return nil
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return nil
+	}
+	return &selector.Location{Lat: lat, Lon: lon}
+}
+
+// locationOfRecord returns the location of a GeoIP2 or GeoLite2 City record, nil when it has none
+func locationOfRecord(record interface{}) *selector.Location {
+	fields, _ := record.(map[string]interface{})
+	location, _ := fields["location"].(map[string]interface{})
+	lat, hasLat := location["latitude"].(float64)
+	lon, hasLon := location["longitude"].(float64)
+	if !hasLat || !hasLon {
+		return nil
+	}
+	return &selector.Location{Lat: lat, Lon: lon}
+}
+
+func WithClientLocation(ctx context.Context, loc *selector.Location) context.Context {
+	return context.WithValue(ctx, clientLocationKey{}, loc)
+}
+
+func GetClientLocation(ctx context.Context) *selector.Location {
+	loc, _ := ctx.Value(clientLocationKey{}).(*selector.Location)
+	return loc
+}
@@ -0,0 +1,194 @@
+package service_test
+
+import (
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing/selector"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+// holds the networks below, along with 2.16.0.0/13 without a location and 10.0.0.0/8, which is private
+const geoIPTest = "testdata/GeoIP-City-Test.mmdb"
+
+var (
+	sydney    = &selector.Location{Lat: -33.494, Lon: 143.2104} // 1.0.0.0/24
+	london    = &selector.Location{Lat: 51.5142, Lon: -0.0931}  // 81.2.69.0/24
+	linkoping = &selector.Location{Lat: 58.4167, Lon: 15.6167}  // 89.160.20.0/24
+	kansas    = &selector.Location{Lat: 37.751, Lon: -97.822}   // 2001:db8::/32
+	paris     = &selector.Location{Lat: 48.8566, Lon: 2.3522}   // header only
+	usEast    = &selector.Location{Lat: 38.99, Lon: -77.45}     // region in the config
+)
+
+func newTestClientLocator(t *testing.T, clientLocation config.ClientLocationConfig, geoIPDB string) *service.ClientLocator {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	conf.NodeSelector.Regions = []config.RegionConfig{{Name: "us-east", Lat: usEast.Lat, Lon: usEast.Lon}}
+	conf.NodeSelector.GeoIPDB = geoIPDB
+	conf.NodeSelector.ClientLocation = clientLocation
+	l, err := service.NewClientLocator(conf)
+	require.NoError(t, err)
+	return l
+}
+
+func TestClientLocator(t *testing.T) {
+	t.Run("header overrides GeoIP", func(t *testing.T) {
+		l := newTestClientLocator(t, config.ClientLocationConfig{TrustHeader: true}, geoIPTest)
+		r := httptest.NewRequest("GET", "/rtc", nil)
+		r.RemoteAddr = "81.2.69.160:50000"
+
+		r.Header.Set(service.ClientLocationHeader, "48.8566, 2.3522")
+		require.Equal(t, paris, l.Locate(r))
+
+		r.Header.Set(service.ClientLocationHeader, "us-east")
+		require.Equal(t, usEast, l.Locate(r))
+
+		// invalid locations fall back to GeoIP
+		r.Header.Set(service.ClientLocationHeader, "91,0")
+		require.Equal(t, london, l.Locate(r))
+	})
+
+	t.Run("header is ignored unless trusted", func(t *testing.T) {
+		l := newTestClientLocator(t, config.ClientLocationConfig{}, geoIPTest)
+		r := httptest.NewRequest("GET", "/rtc", nil)
+		r.RemoteAddr = "81.2.69.160:50000"
+		r.Header.Set(service.ClientLocationHeader, "48.8566,2.3522")
+		require.Equal(t, london, l.Locate(r))
+	})
+
+	t.Run("GeoIP", func(t *testing.T) {
+		l := newTestClientLocator(t, config.ClientLocationConfig{}, geoIPTest)
+		for addr, expected := range map[string]*selector.Location{
+			"1.0.0.1:1":       sydney,
+			"1.0.0.255:1":     sydney,
+			"89.160.20.112:1": linkoping,
+			"[2001:db8::1]:1": kansas,
+			"1.0.1.0:1":       nil,
+			"[2001:db9::1]:1": nil,
+			// network without a location
+			"2.16.0.1:1": nil,
+			// private addresses aren't looked up, even when they're in the database
+			"10.0.0.1:1":  nil,
+			"127.0.0.1:1": nil,
+			"[fe80::1]:1": nil,
+			"invalid:1":   nil,
+		} {
+			r := httptest.NewRequest("GET", "/rtc", nil)
+			r.RemoteAddr = addr
+			require.Equal(t, expected, l.Locate(r), addr)
+		}
+	})
+
+	t.Run("missing database", func(t *testing.T) {
+		l := newTestClientLocator(t, config.ClientLocationConfig{TrustHeader: true}, filepath.Join(t.TempDir(), "missing.mmdb"))
+		r := httptest.NewRequest("GET", "/rtc", nil)
+		r.RemoteAddr = "81.2.69.160:50000"
+		require.Nil(t, l.Locate(r))
+
+		// the header is still used
+		r.Header.Set(service.ClientLocationHeader, "us-east")
+		require.Equal(t, usEast, l.Locate(r))
+	})
+
+	t.Run("invalid database", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "invalid.mmdb")
+		require.NoError(t, os.WriteFile(file, []byte("not a database"), 0600))
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		conf.NodeSelector.GeoIPDB = file
+		_, err = service.NewClientLocator(conf)
+		require.Error(t, err)
+	})
+}
+
+func TestClientLocatorTrustedProxies(t *testing.T) {
+	l := newTestClientLocator(t, config.ClientLocationConfig{
+		TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"},
+	}, geoIPTest)
+
+	for _, tc := range []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		expectedIP   string
+	}{
+		{
+			name:       "direct connection",
+			remoteAddr: "81.2.69.160:1",
+			expectedIP: "81.2.69.160",
+		},
+		{
+			name:         "untrusted peer cannot forward",
+			remoteAddr:   "89.160.20.112:1",
+			forwardedFor: []string{"81.2.69.160"},
+			expectedIP:   "89.160.20.112",
+		},
+		{
+			name:         "trusted proxy",
+			remoteAddr:   "10.1.2.3:1",
+			forwardedFor: []string{"81.2.69.160"},
+			expectedIP:   "81.2.69.160",
+		},
+		{
+			name:         "trusted proxy by address",
+			remoteAddr:   "192.0.2.1:1",
+			forwardedFor: []string{"81.2.69.160"},
+			expectedIP:   "81.2.69.160",
+		},
+		{
+			name:       "address of a single proxy is exact",
+			remoteAddr: "192.0.2.2:1",
+			// 192.0.2.2 isn't trusted
+			forwardedFor: []string{"81.2.69.160"},
+			expectedIP:   "192.0.2.2",
+		},
+		{
+			name:       "chain of trusted proxies",
+			remoteAddr: "10.1.2.3:1",
+			// the client spoofs the first hop, the proxies append what they saw
+			forwardedFor: []string{"81.2.69.160, 1.0.0.1, 10.0.0.2", "192.0.2.1"},
+			expectedIP:   "1.0.0.1",
+		},
+		{
+			name:         "invalid hop",
+			remoteAddr:   "10.1.2.3:1",
+			forwardedFor: []string{"81.2.69.160, unknown"},
+		},
+		{
+			name:         "only proxies",
+			remoteAddr:   "10.1.2.3:1",
+			forwardedFor: []string{"10.0.0.2"},
+			expectedIP:   "10.0.0.2",
+		},
+		{
+			name:       "trusted proxy without header",
+			remoteAddr: "10.1.2.3:1",
+			expectedIP: "10.1.2.3",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/rtc/validate", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for _, header := range tc.forwardedFor {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+			var expected net.IP
+			if tc.expectedIP != "" {
+				expected = net.ParseIP(tc.expectedIP)
+			}
+			require.True(t, expected.Equal(l.ClientIP(r)), "got %s", l.ClientIP(r))
+		})
+	}
+
+	// the located client is the one behind the proxies
+	r := httptest.NewRequest("GET", "/rtc", nil)
+	r.RemoteAddr = "10.1.2.3:1"
+	r.Header.Set("X-Forwarded-For", "81.2.69.160, 1.0.0.1")
+	require.Equal(t, sydney, l.Locate(r))
+}
@@ -11,4 +11,16 @@ var (
 	ErrTrackNotFound        = errors.New("track is not found")
 	ErrWebHookMissingAPIKey = errors.New("api_key is required to use webhooks")
 	ErrOperationFailed      = errors.New("operation cannot be completed")
+	ErrCodecNotEnabled      = errors.New("codec is not enabled on the server")
+	ErrMaxDurationExceeded  = errors.New("max duration is greater than the server's room.max_duration")
+	ErrInvalidCandidateType = errors.New("invalid ICE candidate type")
+	ErrMetadataTooLarge     = errors.New("metadata size exceeds room.max_metadata_size")
+	ErrRemoteUnmuteDisabled = errors.New("remote unmute is not enabled, see room.enable_remote_unmute")
+	ErrParticipantBlocked   = errors.New("participant was removed from the room and cannot rejoin yet")
+	ErrDataTooLarge         = errors.New("data size exceeds room.max_data_size")
+	ErrInvalidPageToken     = errors.New("invalid page token")
+	ErrRoomLocked           = errors.New("room is locked, new participants cannot join")
+	ErrApprovalTimeout      = errors.New("timed out waiting for a moderator to approve the participant")
+	ErrNotApproved          = errors.New("participant was not approved to join the room")
+	ErrNodeDraining         = errors.New("node is draining, request a new node to connect to")
 )
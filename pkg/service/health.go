@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/pion/turn/v2"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing"
+)
+
+const (
+	HealthStatusOK      = "ok"
+	HealthStatusFailing = "failing"
+
+	healthCheckTimeout = 2 * time.Second
+)
+
+type HealthCheck struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status string                  `json:"status"`
+	Checks map[string]*HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthChecker serves /healthz, which only tells that the process is up, and /readyz, which checks
+// everything the node needs to take on rooms
+type HealthChecker struct {
+	conf        *config.Config
+	router      routing.Router
+	currentNode routing.LocalNode
+	redisClient redis.UniversalClient
+	turnServer  *turn.Server
+	keyProvider auth.KeyProvider
+}
+
+func NewHealthChecker(
+	conf *config.Config,
+	router routing.Router,
+	currentNode routing.LocalNode,
+	redisClient redis.UniversalClient,
+	turnServer *turn.Server,
+	keyProvider auth.KeyProvider,
+) *HealthChecker {
+	return &HealthChecker{
+		conf:        conf,
+		router:      router,
+		currentNode: currentNode,
+		redisClient: redisClient,
+		turnServer:  turnServer,
+		keyProvider: keyProvider,
+	}
+}
+
+func (h *HealthChecker) Liveness(w http.ResponseWriter, _ *http.Request) {
+	writeHealthReport(w, &HealthReport{Status: HealthStatusOK})
+}
+
+func (h *HealthChecker) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	writeHealthReport(w, h.Check(ctx))
+}
+
+// Check runs the readiness checks, the report is failing when any of them fails
+func (h *HealthChecker) Check(ctx context.Context) *HealthReport {
+	report := &HealthReport{
+		Status: HealthStatusOK,
+		Checks: make(map[string]*HealthCheck),
+	}
+	run := func(name string, check func() error) {
+		start := time.Now()
+		err := check()
+		result := &HealthCheck{
+			Status:  HealthStatusOK,
+			Latency: time.Since(start).String(),
+		}
+		if err != nil {
+			result.Status = HealthStatusFailing
+			result.Error = err.Error()
+			report.Status = HealthStatusFailing
+		}
+		report.Checks[name] = result
+	}
+
+	if h.conf.HasRedis() {
+		run("redis", func() error {
+			if h.redisClient == nil {
+				return errors.New("redis client is not configured")
+			}
+			return h.redisClient.Ping(ctx).Err()
+		})
+	}
+	run("router", h.checkRouter)
+	if h.conf.TURN.Enabled {
+		run("turn", func() error {
+			if h.turnServer == nil {
+				return errors.New("TURN server is not listening")
+			}
+			return nil
+		})
+	}
+	run("keys", func() error {
+		if h.keyProvider == nil || h.keyProvider.NumKeys() == 0 {
+			return errors.New("no API keys are loaded")
+		}
+		return nil
+	})
+	run("drain", func() error {
+		if h.currentNode.State == livekit.NodeState_SHUTTING_DOWN {
+			return errors.New("node is draining")
+		}
+		return nil
+	})
+	return report
+}
+
+func (h *HealthChecker) checkRouter() error {
+	nodes, err := h.router.ListNodes()
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if node.Id == h.currentNode.Id {
+			return nil
+		}
+	}
+	return errors.New("node is not registered with the router")
+}
+
+func writeHealthReport(w http.ResponseWriter, report *HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != HealthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
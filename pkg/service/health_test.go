@@ -0,0 +1,145 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/pion/turn/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing/routingfakes"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+type healthFixture struct {
+	conf        *config.Config
+	router      *routingfakes.FakeRouter
+	node        *livekit.Node
+	redisClient redis.UniversalClient
+	turnServer  *turn.Server
+	keyProvider auth.KeyProvider
+}
+
+func newHealthFixture(t *testing.T) *healthFixture {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+
+	node := &livekit.Node{Id: "node1", State: livekit.NodeState_SERVING}
+	router := &routingfakes.FakeRouter{}
+	router.ListNodesReturns([]*livekit.Node{node}, nil)
+
+	return &healthFixture{
+		conf:        conf,
+		router:      router,
+		node:        node,
+		keyProvider: auth.NewFileBasedKeyProviderFromMap(map[string]string{"key": "secret"}),
+	}
+}
+
+func (f *healthFixture) readiness(t *testing.T) (int, *service.HealthReport) {
+	h := service.NewHealthChecker(f.conf, f.router, f.node, f.redisClient, f.turnServer, f.keyProvider)
+	w := httptest.NewRecorder()
+	h.Readiness(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	report := &service.HealthReport{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), report))
+	return w.Code, report
+}
+
+func TestHealthChecker(t *testing.T) {
+	t.Run("liveness is always ok", func(t *testing.T) {
+		f := newHealthFixture(t)
+		h := service.NewHealthChecker(f.conf, f.router, f.node, nil, nil, nil)
+		w := httptest.NewRecorder()
+		h.Liveness(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		code, report := newHealthFixture(t).readiness(t)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, service.HealthStatusOK, report.Status)
+		require.Len(t, report.Checks, 3)
+		for name, check := range report.Checks {
+			require.Equal(t, service.HealthStatusOK, check.Status, name)
+			require.NotEmpty(t, check.Latency, name)
+		}
+	})
+
+	testCases := []struct {
+		name  string
+		check string
+		setup func(f *healthFixture)
+	}{
+		{
+			name:  "redis unreachable",
+			check: "redis",
+			setup: func(f *healthFixture) {
+				f.conf.Redis.Address = "127.0.0.1:1"
+				f.redisClient = redis.NewUniversalClient(&redis.UniversalOptions{Addrs: []string{f.conf.Redis.Address}})
+			},
+		},
+		{
+			name:  "router unavailable",
+			check: "router",
+			setup: func(f *healthFixture) {
+				f.router.ListNodesReturns(nil, errors.New("connection refused"))
+			},
+		},
+		{
+			name:  "node not registered",
+			check: "router",
+			setup: func(f *healthFixture) {
+				f.router.ListNodesReturns([]*livekit.Node{{Id: "node2"}}, nil)
+			},
+		},
+		{
+			name:  "TURN not listening",
+			check: "turn",
+			setup: func(f *healthFixture) {
+				f.conf.TURN.Enabled = true
+			},
+		},
+		{
+			name:  "no keys",
+			check: "keys",
+			setup: func(f *healthFixture) {
+				f.keyProvider = auth.NewFileBasedKeyProviderFromMap(map[string]string{})
+			},
+		},
+		{
+			name:  "draining",
+			check: "drain",
+			setup: func(f *healthFixture) {
+				f.node.State = livekit.NodeState_SHUTTING_DOWN
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newHealthFixture(t)
+			tc.setup(f)
+
+			code, report := f.readiness(t)
+			require.Equal(t, http.StatusServiceUnavailable, code)
+			require.Equal(t, service.HealthStatusFailing, report.Status)
+			require.Contains(t, report.Checks, tc.check)
+			for name, check := range report.Checks {
+				if name == tc.check {
+					require.Equal(t, service.HealthStatusFailing, check.Status)
+					require.NotEmpty(t, check.Error)
+				} else {
+					require.Equal(t, service.HealthStatusOK, check.Status, name)
+				}
+			}
+		})
+	}
+}
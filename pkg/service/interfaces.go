@@ -2,9 +2,12 @@ package service
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
 )
 
 //go:generate go run github.com/maxbrunsfeld/counterfeiter/v6 -generate
@@ -23,19 +26,107 @@ type ObjectStore interface {
 	StoreRoom(ctx context.Context, room *livekit.Room) error
 	DeleteRoom(ctx context.Context, name livekit.RoomName) error
 
+	// server-side room settings that livekit.Room cannot carry
+	StoreRoomInternal(ctx context.Context, name livekit.RoomName, internal *RoomInternal) error
+
 	StoreParticipant(ctx context.Context, roomName livekit.RoomName, participant *livekit.ParticipantInfo) error
 	DeleteParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error
+
+	// participants waiting for approval to join rooms that require it
+	StorePendingParticipant(ctx context.Context, roomName livekit.RoomName, pending *PendingParticipant) error
+	DeletePendingParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error
+
+	// refuse the identity when joining the room until duration has passed
+	BlockParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity, duration time.Duration) error
+	UnblockParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error
+}
+
+// RoomInternal holds room settings that aren't part of the livekit.Room message
+type RoomInternal struct {
+	// max duration of the room in seconds, 0 to use the server default
+	MaxDuration uint32 `json:"max_duration,omitempty"`
+	// unix time in seconds a node first started hosting the room, the start of its max duration
+	StartedAt int64 `json:"started_at,omitempty"`
+	// API key the room was created with, used for limits_per_key
+	APIKey string `json:"api_key,omitempty"`
+	// candidate types accepted from participants, overriding rtc.ice_candidate_types
+	ICECandidateTypes []string `json:"ice_candidate_types,omitempty"`
+	// audio tracks forwarded to each subscriber, overriding audio.max_forwarded_tracks when set. 0 for no limit
+	MaxForwardedAudioTracks *int `json:"max_forwarded_audio_tracks,omitempty"`
+	// caps on the bitrate sent to participants set through UpdateParticipant, by participant sid
+	MaxSubscribeBitrates map[livekit.ParticipantID]uint64 `json:"max_subscribe_bitrates,omitempty"`
+	// caps on the layers forwarded to participants set through UpdateSubscriptions, by participant sid and track sid.
+	// A nil cap removes one applied previously
+	SubscriptionLayerCaps map[livekit.ParticipantID]map[livekit.TrackID]*types.SubscriptionLayerCap `json:"subscription_layer_caps,omitempty"`
+	// new participants are refused while the room is locked, unless their token has room admin for it
+	Locked bool `json:"locked,omitempty"`
+	// participants wait for a moderator to approve them before joining
+	RequireApproval bool `json:"require_approval,omitempty"`
+	// seconds a removed participant is refused when rejoining, overriding room.block_rejoin_duration when set.
+	// 0 lets them rejoin right away
+	BlockRejoinDuration *uint32 `json:"block_rejoin_duration,omitempty"`
+}
+
+// PendingParticipant is a participant waiting for a moderator to let it into a room that requires approval
+type PendingParticipant struct {
+	Identity livekit.ParticipantIdentity `json:"identity"`
+	Name     livekit.ParticipantName     `json:"name,omitempty"`
+	Metadata string                      `json:"metadata,omitempty"`
+	// unix time in seconds the participant started waiting
+	WaitingSince int64 `json:"waiting_since"`
+	// nil until a moderator approves or denies the participant
+	Approved *bool `json:"approved,omitempty"`
+}
+
+func (p *PendingParticipant) ToProto() *livekit.ParticipantInfo {
+	return &livekit.ParticipantInfo{
+		Identity: string(p.Identity),
+		Name:     string(p.Name),
+		Metadata: p.Metadata,
+		State:    livekit.ParticipantInfo_JOINING,
+		JoinedAt: p.WaitingSince,
+	}
 }
 
 //counterfeiter:generate . ServiceStore
 type ServiceStore interface {
 	LoadRoom(ctx context.Context, name livekit.RoomName) (*livekit.Room, error)
+	LoadRoomInternal(ctx context.Context, name livekit.RoomName) (*RoomInternal, error)
+	// CountRooms returns the number of active rooms created with apiKey
+	CountRooms(ctx context.Context, apiKey string) (int, error)
 	// ListRooms returns currently active rooms. if names is not nil, it'll filter and return
 	// only rooms that match
 	ListRooms(ctx context.Context, names []livekit.RoomName) ([]*livekit.Room, error)
+	// ListRoomsPage returns a page of active rooms matching opts, along with the token of the next page.
+	// The token is empty on the last page
+	ListRoomsPage(ctx context.Context, opts ListRoomsOptions) ([]*livekit.Room, string, error)
 
 	LoadParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (*livekit.ParticipantInfo, error)
 	ListParticipants(ctx context.Context, roomName livekit.RoomName) ([]*livekit.ParticipantInfo, error)
+	IsParticipantBlocked(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (bool, error)
+
+	LoadPendingParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (*PendingParticipant, error)
+	ListPendingParticipants(ctx context.Context, roomName livekit.RoomName) ([]*PendingParticipant, error)
+}
+
+// ListRoomsOptions filters and pages ListRoomsPage
+type ListRoomsOptions struct {
+	// only rooms with names starting with the prefix
+	NamePrefix string
+	// only rooms created after this unix time in seconds, 0 for any
+	CreatedAfter int64
+	// only rooms with at least this many participants
+	MinParticipants uint32
+	// token returned with the previous page, empty for the first page
+	PageToken string
+	// max rooms in a page, 0 for no limit
+	Limit int
+}
+
+func (o ListRoomsOptions) Matches(room *livekit.Room) bool {
+	return strings.HasPrefix(room.Name, o.NamePrefix) &&
+		(o.CreatedAfter == 0 || room.CreationTime > o.CreatedAfter) &&
+		room.NumParticipants >= o.MinParticipants
 }
 
 //counterfeiter:generate . EgressStore
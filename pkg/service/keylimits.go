@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing/selector"
+	"github.com/livekit/livekit-server/pkg/rtc"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	keyLimitNumTracks              = "num_tracks"
+	keyLimitBytesPerSec            = "bytes_per_sec"
+	keyLimitMaxRooms               = "max_rooms"
+	keyLimitMaxParticipantsPerRoom = "max_participants_per_room"
+)
+
+// KeyLimitError is returned when a request is rejected by limits_per_key
+type KeyLimitError struct {
+	APIKey string
+	Limit  string
+}
+
+func (e *KeyLimitError) Error() string {
+	return fmt.Sprintf("API key %s has reached its %s limit", e.APIKey, e.Limit)
+}
+
+func newKeyLimitError(apiKey, limit string) *KeyLimitError {
+	prometheus.KeyLimitRejectedCounter.WithLabelValues(apiKey, limit).Add(1)
+	return &KeyLimitError{APIKey: apiKey, Limit: limit}
+}
+
+// checkJoinLimits enforces limits_per_key when a participant joins with a token signed by apiKey.
+// Rooms are counted against the key that created them
+func checkJoinLimits(ctx context.Context, store ServiceStore, apiKey string, limits config.KeyLimitConfig, roomName livekit.RoomName, node *livekit.Node) error {
+	if node != nil && selector.LimitsReached(config.LimitConfig{BytesPerSec: limits.BytesPerSec}, node.Stats) {
+		return newKeyLimitError(apiKey, keyLimitBytesPerSec)
+	}
+
+	if limits.MaxParticipantsPerRoom > 0 {
+		participants, err := store.ListParticipants(ctx, roomName)
+		if err != nil {
+			return err
+		}
+		if int32(len(participants)) >= limits.MaxParticipantsPerRoom {
+			return newKeyLimitError(apiKey, keyLimitMaxParticipantsPerRoom)
+		}
+	}
+
+	if limits.MaxRooms > 0 {
+		if _, err := store.LoadRoom(ctx, roomName); err != ErrRoomNotFound {
+			// joining an existing room doesn't count towards max_rooms
+			return err
+		}
+		numRooms, err := store.CountRooms(ctx, apiKey)
+		if err != nil {
+			return err
+		}
+		if int32(numRooms) >= limits.MaxRooms {
+			return newKeyLimitError(apiKey, keyLimitMaxRooms)
+		}
+	}
+	return nil
+}
+
+// checkTrackLimit enforces num_tracks from limits_per_key when a track is published in room. Tracks are counted
+// across the rooms hosted on this node that were created with the same key
+func (r *RoomManager) checkTrackLimit(room *rtc.Room) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	var apiKey string
+	if internal := r.roomInternal[room.Name()]; internal != nil {
+		apiKey = internal.APIKey
+	}
+	limits, ok := r.config.LimitsPerKey[apiKey]
+	if !ok || limits.NumTracks <= 0 {
+		return nil
+	}
+
+	var numTracks int32
+	for name, rm := range r.rooms {
+		if internal := r.roomInternal[name]; internal == nil || internal.APIKey != apiKey {
+			continue
+		}
+		for _, p := range rm.GetParticipants() {
+			numTracks += int32(len(p.GetPublishedTracks()))
+		}
+	}
+	if numTracks >= limits.NumTracks {
+		return newKeyLimitError(apiKey, keyLimitNumTracks)
+	}
+	return nil
+}
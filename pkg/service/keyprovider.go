@@ -0,0 +1,212 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const keyFilePollInterval = 5 * time.Second
+
+// ReloadingKeyProvider is an auth.KeyProvider backed by a key file. The file is re-read when it changes
+// or when the process receives SIGHUP, and the keys are swapped atomically. Keys removed from the file keep
+// validating tokens for the removal grace period, so that tokens they signed keep working until they expire. Only
+// tokens issued before the removal are accepted, see RemovedAt.
+// Inline keys are merged on top of the file, taking precedence when the same key is defined in both.
+type ReloadingKeyProvider struct {
+	path         string
+	inlineKeys   map[string]string
+	removalGrace time.Duration
+
+	lock        sync.RWMutex
+	keys        map[string]string
+	removed     map[string]removedKey
+	numFileKeys int
+	modTime     time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type removedKey struct {
+	secret    string
+	removedAt time.Time
+	expires   time.Time
+}
+
+// NewReloadingKeyProvider loads keys from path, merged with inlineKeys, and starts watching the file for changes.
+// Keys removed from the file are accepted for removalGrace
+func NewReloadingKeyProvider(path string, inlineKeys map[string]string, removalGrace time.Duration) (*ReloadingKeyProvider, error) {
+	p := &ReloadingKeyProvider{
+		path:         path,
+		inlineKeys:   inlineKeys,
+		removalGrace: removalGrace,
+		removed:      make(map[string]removedKey),
+		done:         make(chan struct{}),
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+
+	// registered before returning, so that SIGHUP doesn't terminate the process until the watcher starts
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	go p.watch(sigChan)
+	return p, nil
+}
+
+func (p *ReloadingKeyProvider) GetSecret(key string) string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if secret, ok := p.keys[key]; ok {
+		return secret
+	}
+	if removed, ok := p.removed[key]; ok && time.Now().Before(removed.expires) {
+		return removed.secret
+	}
+	return ""
+}
+
+// RemovedAt returns when the key was removed from the key file, for keys still in their removal grace period. Their
+// secret is only valid for tokens issued before then
+func (p *ReloadingKeyProvider) RemovedAt(key string) (time.Time, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if _, ok := p.keys[key]; ok {
+		return time.Time{}, false
+	}
+	if removed, ok := p.removed[key]; ok && time.Now().Before(removed.expires) {
+		return removed.removedAt, true
+	}
+	return time.Time{}, false
+}
+
+// NumKeys returns the number of keys loaded, not counting removed keys still in their grace period
+func (p *ReloadingKeyProvider) NumKeys() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return len(p.keys)
+}
+
+// NumFileKeys returns the number of keys defined in the key file
+func (p *ReloadingKeyProvider) NumFileKeys() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.numFileKeys
+}
+
+// Reload re-reads the key file. On failure, the previously loaded keys are kept
+func (p *ReloadingKeyProvider) Reload() error {
+	st, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	if st.Mode().Perm() != 0600 {
+		return fmt.Errorf("key file must have permission set to 600")
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	fileKeys, warnings, err := config.ParseKeys(data)
+	if err != nil {
+		return fmt.Errorf("could not parse key file: %v", err)
+	}
+	for _, warning := range warnings {
+		logger.Warnw(warning, nil, "keyFile", p.path)
+	}
+
+	keys := make(map[string]string, len(p.inlineKeys)+len(fileKeys))
+	for key, secret := range fileKeys {
+		keys[key] = secret
+	}
+	for key, secret := range p.inlineKeys {
+		keys[key] = secret
+	}
+	warnShortSecrets(keys)
+
+	now := time.Now()
+	p.lock.Lock()
+	for key, secret := range p.keys {
+		if _, ok := keys[key]; !ok && p.removalGrace > 0 {
+			p.removed[key] = removedKey{secret: secret, removedAt: now, expires: now.Add(p.removalGrace)}
+		}
+	}
+	for key, removed := range p.removed {
+		if _, ok := keys[key]; ok || !now.Before(removed.expires) {
+			delete(p.removed, key)
+		}
+	}
+	p.keys = keys
+	p.numFileKeys = len(fileKeys)
+	p.modTime = st.ModTime()
+	p.lock.Unlock()
+	return nil
+}
+
+func (p *ReloadingKeyProvider) Stop() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+func (p *ReloadingKeyProvider) watch(sigChan chan os.Signal) {
+	defer signal.Stop(sigChan)
+
+	ticker := time.NewTicker(keyFilePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-sigChan:
+			p.reloadAndLog("signal")
+		case <-ticker.C:
+			if p.hasChanged() {
+				p.reloadAndLog("file changed")
+			}
+		}
+	}
+}
+
+func (p *ReloadingKeyProvider) hasChanged() bool {
+	st, err := os.Stat(p.path)
+	if err != nil {
+		return false
+	}
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return !st.ModTime().Equal(p.modTime)
+}
+
+func (p *ReloadingKeyProvider) reloadAndLog(reason string) {
+	if err := p.Reload(); err != nil {
+		logger.Errorw("could not reload API keys", err, "keyFile", p.path, "reason", reason)
+		return
+	}
+	logger.Infow("reloaded API keys", "keyFile", p.path, "reason", reason,
+		"numKeys", p.NumKeys(),
+		"fileKeys", p.NumFileKeys(),
+		"inlineKeys", len(p.inlineKeys),
+	)
+}
+
+// minSecretLength is the shortest secret considered safe for signing tokens
+const minSecretLength = 32
+
+func warnShortSecrets(keys map[string]string) {
+	for key, secret := range keys {
+		if len(secret) < minSecretLength {
+			logger.Warnw("API secret is shorter than recommended", nil, "key", key, "length", len(secret), "minLength", minSecretLength)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestReloadingKeyProvider(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(keyFile, []byte("key1: secret1\n"), 0600))
+
+	p, err := service.NewReloadingKeyProvider(keyFile, map[string]string{"inline": "inlinesecret"}, 0)
+	require.NoError(t, err)
+	defer p.Stop()
+
+	require.Equal(t, 2, p.NumKeys())
+	require.Equal(t, "secret1", p.GetSecret("key1"))
+	require.Equal(t, "inlinesecret", p.GetSecret("inline"))
+
+	t.Run("inline keys take precedence", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("key1: secret1\ninline: filesecret\n"), 0600))
+		require.NoError(t, p.Reload())
+
+		require.Equal(t, "inlinesecret", p.GetSecret("inline"))
+		require.Equal(t, "secret1", p.GetSecret("key1"))
+		require.Equal(t, 2, p.NumKeys())
+		require.Equal(t, 2, p.NumFileKeys())
+	})
+
+	t.Run("rotated keys are swapped in without grace", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("key2: secret2\n"), 0600))
+		require.NoError(t, p.Reload())
+
+		require.Equal(t, "", p.GetSecret("key1"))
+		require.Equal(t, "secret2", p.GetSecret("key2"))
+		require.Equal(t, "inlinesecret", p.GetSecret("inline"))
+	})
+
+	t.Run("invalid file keeps previous keys", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("key3: [not a secret\n"), 0600))
+		require.Error(t, p.Reload())
+
+		require.Equal(t, "secret2", p.GetSecret("key2"))
+		require.Equal(t, 2, p.NumKeys())
+	})
+
+	t.Run("non-string secrets", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("key5: 123456\n"), 0600))
+		require.NoError(t, p.Reload())
+		require.Equal(t, "123456", p.GetSecret("key5"))
+
+		require.NoError(t, os.WriteFile(keyFile, []byte("key6: true\n"), 0600))
+		err := p.Reload()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "secret for key key6 must be a string, got YAML bool")
+		require.Equal(t, "123456", p.GetSecret("key5"))
+	})
+
+	t.Run("rejects insecure permissions", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("key4: secret4\n"), 0600))
+		require.NoError(t, os.Chmod(keyFile, 0644))
+		require.Error(t, p.Reload())
+		require.Equal(t, "", p.GetSecret("key4"))
+	})
+}
+
+func TestReloadingKeyProviderRemovalGrace(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(keyFile, []byte("key1: secret1\n"), 0600))
+
+	p, err := service.NewReloadingKeyProvider(keyFile, nil, 200*time.Millisecond)
+	require.NoError(t, err)
+	defer p.Stop()
+
+	m := service.NewAPIKeyAuthMiddleware(p)
+	authenticate := func(token string) int {
+		r := &http.Request{Header: http.Header{}}
+		w := httptest.NewRecorder()
+		service.SetAuthorizationToken(r, token)
+		m.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {})
+		return w.Code
+	}
+	issuedBefore := signToken(t, "key1", "secret1", jwt.Claims{
+		IssuedAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+	})
+	require.Equal(t, http.StatusOK, authenticate(issuedBefore))
+	_, removed := p.RemovedAt("key1")
+	require.False(t, removed)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("key2: secret2\n"), 0600))
+	require.NoError(t, p.Reload())
+	removedAt, removed := p.RemovedAt("key1")
+	require.True(t, removed)
+	require.WithinDuration(t, time.Now(), removedAt, time.Second)
+	require.Equal(t, "secret2", p.GetSecret("key2"))
+	require.Equal(t, 1, p.NumKeys())
+
+	// tokens issued before the key was removed are accepted until they would have expired
+	require.Equal(t, http.StatusOK, authenticate(issuedBefore))
+	notBefore := signToken(t, "key1", "secret1", jwt.Claims{
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+	})
+	require.Equal(t, http.StatusOK, authenticate(notBefore))
+
+	// the removed key can't sign new ones
+	for name, claims := range map[string]jwt.Claims{
+		"issued after":             {IssuedAt: jwt.NewNumericDate(removedAt.Add(time.Second))},
+		"valid after":              {NotBefore: jwt.NewNumericDate(removedAt.Add(time.Second))},
+		"no time of issue":         {},
+		"issued within the second": {IssuedAt: jwt.NewNumericDate(removedAt)},
+	} {
+		require.Equal(t, http.StatusUnauthorized, authenticate(signToken(t, "key1", "secret1", claims)), name)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, "", p.GetSecret("key1"))
+	require.Equal(t, http.StatusUnauthorized, authenticate(issuedBefore))
+}
+
+// signToken signs a token for the key with the given times of issue, valid for a minute
+func signToken(t *testing.T, apiKey, secret string, claims jwt.Claims) string {
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte(secret)}, (&jose.SignerOptions{}).WithType("JWT"))
+	require.NoError(t, err)
+	claims.Issuer = apiKey
+	claims.Subject = "participant"
+	claims.Expiry = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	token, err := jwt.Signed(sig).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestReloadingKeyProviderWatch(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(keyFile, []byte("key1: secret1\n"), 0600))
+
+	p, err := service.NewReloadingKeyProvider(keyFile, nil, 0)
+	require.NoError(t, err)
+	defer p.Stop()
+
+	t.Run("on SIGHUP", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("key2: secret2\n"), 0600))
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+		require.Eventually(t, func() bool {
+			return p.GetSecret("key2") == "secret2"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("when the file changes", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(keyFile, []byte("key3: secret3\n"), 0600))
+		// the file is polled every 5s
+		require.Eventually(t, func() bool {
+			return p.GetSecret("key3") == "secret3"
+		}, 7*time.Second, 50*time.Millisecond)
+		require.Equal(t, "", p.GetSecret("key2"))
+	})
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,18 +15,42 @@ import (
 type LocalStore struct {
 	// map of roomName => room
 	rooms map[livekit.RoomName]*livekit.Room
+	// map of roomName => internal room settings
+	roomInternal map[livekit.RoomName]*RoomInternal
+	// map of API key => rooms created with it
+	keyRooms map[string]map[livekit.RoomName]struct{}
 	// map of roomName => { identity: participant }
 	participants map[livekit.RoomName]map[livekit.ParticipantIdentity]*livekit.ParticipantInfo
+	// map of roomName => { identity: time the block expires }
+	blockedParticipants map[livekit.RoomName]map[livekit.ParticipantIdentity]time.Time
+	// map of roomName => { identity: participant waiting for approval }
+	pendingParticipants map[livekit.RoomName]map[livekit.ParticipantIdentity]*PendingParticipant
 
 	lock       sync.RWMutex
 	globalLock sync.Mutex
+
+	// when persisted, rooms are saved to this file. dirty is set when they've changed since
+	snapshotFile     string
+	snapshotInterval time.Duration
+	dirty            bool
+	// restored rooms are deleted unless a participant rejoins within their empty timeout
+	restoreTimers map[livekit.RoomName]*time.Timer
+
+	closeOnce sync.Once
+	done      chan struct{}
 }
 
 func NewLocalStore() *LocalStore {
 	return &LocalStore{
-		rooms:        make(map[livekit.RoomName]*livekit.Room),
-		participants: make(map[livekit.RoomName]map[livekit.ParticipantIdentity]*livekit.ParticipantInfo),
-		lock:         sync.RWMutex{},
+		rooms:               make(map[livekit.RoomName]*livekit.Room),
+		roomInternal:        make(map[livekit.RoomName]*RoomInternal),
+		keyRooms:            make(map[string]map[livekit.RoomName]struct{}),
+		participants:        make(map[livekit.RoomName]map[livekit.ParticipantIdentity]*livekit.ParticipantInfo),
+		blockedParticipants: make(map[livekit.RoomName]map[livekit.ParticipantIdentity]time.Time),
+		pendingParticipants: make(map[livekit.RoomName]map[livekit.ParticipantIdentity]*PendingParticipant),
+		lock:                sync.RWMutex{},
+		restoreTimers:       make(map[livekit.RoomName]*time.Timer),
+		done:                make(chan struct{}),
 	}
 }
 
@@ -34,6 +60,7 @@ func (s *LocalStore) StoreRoom(_ context.Context, room *livekit.Room) error {
 	}
 	s.lock.Lock()
 	s.rooms[livekit.RoomName(room.Name)] = room
+	s.dirty = true
 	s.lock.Unlock()
 	return nil
 }
@@ -61,6 +88,38 @@ func (s *LocalStore) ListRooms(_ context.Context, names []livekit.RoomName) ([]*
 	return rooms, nil
 }
 
+// ListRoomsPage pages through rooms ordered by name, the page token being the last name of the previous page
+func (s *LocalStore) ListRoomsPage(_ context.Context, opts ListRoomsOptions) ([]*livekit.Room, string, error) {
+	var after string
+	if opts.PageToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(opts.PageToken)
+		if err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+		after = string(decoded)
+	}
+
+	s.lock.RLock()
+	rooms := make([]*livekit.Room, 0, len(s.rooms))
+	for name, r := range s.rooms {
+		if (after == "" || string(name) > after) && opts.Matches(r) {
+			rooms = append(rooms, r)
+		}
+	}
+	s.lock.RUnlock()
+
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].Name < rooms[j].Name
+	})
+
+	var nextPageToken string
+	if opts.Limit > 0 && len(rooms) > opts.Limit {
+		rooms = rooms[:opts.Limit]
+		nextPageToken = base64.RawURLEncoding.EncodeToString([]byte(rooms[len(rooms)-1].Name))
+	}
+	return rooms, nextPageToken, nil
+}
+
 func (s *LocalStore) DeleteRoom(ctx context.Context, name livekit.RoomName) error {
 	room, err := s.LoadRoom(ctx, name)
 	if err == ErrRoomNotFound {
@@ -73,10 +132,62 @@ func (s *LocalStore) DeleteRoom(ctx context.Context, name livekit.RoomName) erro
 	defer s.lock.Unlock()
 
 	delete(s.participants, livekit.RoomName(room.Name))
+	delete(s.pendingParticipants, livekit.RoomName(room.Name))
 	delete(s.rooms, livekit.RoomName(room.Name))
+	s.setRoomKey(livekit.RoomName(room.Name), "")
+	delete(s.roomInternal, livekit.RoomName(room.Name))
+	s.stopRestoreTimer(livekit.RoomName(room.Name))
+	s.dirty = true
 	return nil
 }
 
+func (s *LocalStore) StoreRoomInternal(_ context.Context, name livekit.RoomName, internal *RoomInternal) error {
+	s.lock.Lock()
+	s.setRoomKey(name, internal.APIKey)
+	s.roomInternal[name] = internal
+	s.dirty = true
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *LocalStore) LoadRoomInternal(_ context.Context, name livekit.RoomName) (*RoomInternal, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if internal := s.roomInternal[name]; internal != nil {
+		return internal, nil
+	}
+	return &RoomInternal{}, nil
+}
+
+func (s *LocalStore) CountRooms(_ context.Context, apiKey string) (int, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.keyRooms[apiKey]), nil
+}
+
+// setRoomKey moves the room to the rooms counted for apiKey, none when empty. Requires s.lock
+func (s *LocalStore) setRoomKey(name livekit.RoomName, apiKey string) {
+	if internal := s.roomInternal[name]; internal != nil && internal.APIKey != "" {
+		if rooms := s.keyRooms[internal.APIKey]; rooms != nil {
+			delete(rooms, name)
+			if len(rooms) == 0 {
+				delete(s.keyRooms, internal.APIKey)
+			}
+		}
+	}
+	if apiKey == "" {
+		return
+	}
+	rooms := s.keyRooms[apiKey]
+	if rooms == nil {
+		rooms = make(map[livekit.RoomName]struct{})
+		s.keyRooms[apiKey] = rooms
+	}
+	rooms[name] = struct{}{}
+}
+
 func (s *LocalStore) LockRoom(_ context.Context, _ livekit.RoomName, _ time.Duration) (string, error) {
 	// local rooms lock & unlock globally
 	s.globalLock.Lock()
@@ -97,6 +208,7 @@ func (s *LocalStore) StoreParticipant(_ context.Context, roomName livekit.RoomNa
 		s.participants[roomName] = roomParticipants
 	}
 	roomParticipants[livekit.ParticipantIdentity(participant.Identity)] = participant
+	s.stopRestoreTimer(roomName)
 	return nil
 }
 
@@ -144,6 +256,98 @@ func (s *LocalStore) DeleteParticipant(_ context.Context, roomName livekit.RoomN
 	return nil
 }
 
+func (s *LocalStore) BlockParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity, duration time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	blocked := s.blockedParticipants[roomName]
+	if blocked == nil {
+		blocked = make(map[livekit.ParticipantIdentity]time.Time)
+		s.blockedParticipants[roomName] = blocked
+	}
+	now := time.Now()
+	// drop expired entries so the list doesn't grow unbounded
+	for id, expiry := range blocked {
+		if !now.Before(expiry) {
+			delete(blocked, id)
+		}
+	}
+	blocked[identity] = now.Add(duration)
+	s.dirty = true
+	return nil
+}
+
+func (s *LocalStore) UnblockParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if blocked := s.blockedParticipants[roomName]; blocked != nil {
+		delete(blocked, identity)
+		if len(blocked) == 0 {
+			delete(s.blockedParticipants, roomName)
+		}
+		s.dirty = true
+	}
+	return nil
+}
+
+func (s *LocalStore) IsParticipantBlocked(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	expiry, ok := s.blockedParticipants[roomName][identity]
+	return ok && time.Now().Before(expiry), nil
+}
+
+func (s *LocalStore) StorePendingParticipant(_ context.Context, roomName livekit.RoomName, pending *PendingParticipant) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	roomPending := s.pendingParticipants[roomName]
+	if roomPending == nil {
+		roomPending = make(map[livekit.ParticipantIdentity]*PendingParticipant)
+		s.pendingParticipants[roomName] = roomPending
+	}
+	stored := *pending
+	roomPending[pending.Identity] = &stored
+	return nil
+}
+
+func (s *LocalStore) LoadPendingParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (*PendingParticipant, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	pending := s.pendingParticipants[roomName][identity]
+	if pending == nil {
+		return nil, ErrParticipantNotFound
+	}
+	loaded := *pending
+	return &loaded, nil
+}
+
+func (s *LocalStore) ListPendingParticipants(_ context.Context, roomName livekit.RoomName) ([]*PendingParticipant, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	roomPending := s.pendingParticipants[roomName]
+	items := make([]*PendingParticipant, 0, len(roomPending))
+	for _, pending := range roomPending {
+		loaded := *pending
+		items = append(items, &loaded)
+	}
+	return items, nil
+}
+
+func (s *LocalStore) DeletePendingParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if roomPending := s.pendingParticipants[roomName]; roomPending != nil {
+		delete(roomPending, identity)
+	}
+	return nil
+}
+
 func (s *LocalStore) StoreEgress(_ context.Context, _ *livekit.EgressInfo) error {
 	// redis is required for egress
 	return nil
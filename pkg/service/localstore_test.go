@@ -0,0 +1,185 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestLocalStoreListRoomsPage(t *testing.T) {
+	ctx := context.Background()
+	store := service.NewLocalStore()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.StoreRoom(ctx, &livekit.Room{
+			Name:            fmt.Sprintf("game-%d", i),
+			CreationTime:    int64(100 + i),
+			NumParticipants: uint32(i),
+		}))
+	}
+	require.NoError(t, store.StoreRoom(ctx, &livekit.Room{Name: "lobby", CreationTime: 200, NumParticipants: 10}))
+
+	names := func(rooms []*livekit.Room) []string {
+		var n []string
+		for _, r := range rooms {
+			n = append(n, r.Name)
+		}
+		return n
+	}
+
+	t.Run("pages in name order", func(t *testing.T) {
+		opts := service.ListRoomsOptions{Limit: 4}
+		rooms, token, err := store.ListRoomsPage(ctx, opts)
+		require.NoError(t, err)
+		require.Equal(t, []string{"game-0", "game-1", "game-2", "game-3"}, names(rooms))
+		require.NotEmpty(t, token)
+
+		opts.PageToken = token
+		rooms, token, err = store.ListRoomsPage(ctx, opts)
+		require.NoError(t, err)
+		require.Equal(t, []string{"game-4", "lobby"}, names(rooms))
+		require.Empty(t, token)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		rooms, token, err := store.ListRoomsPage(ctx, service.ListRoomsOptions{
+			NamePrefix:      "game-",
+			CreatedAfter:    101,
+			MinParticipants: 3,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"game-3", "game-4"}, names(rooms))
+		require.Empty(t, token)
+	})
+
+	t.Run("invalid page token", func(t *testing.T) {
+		_, _, err := store.ListRoomsPage(ctx, service.ListRoomsOptions{PageToken: "not base64!"})
+		require.ErrorIs(t, err, service.ErrInvalidPageToken)
+	})
+}
+
+func TestLocalStorePersistence(t *testing.T) {
+	ctx := context.Background()
+	conf := config.LocalStorageConfig{DataDir: t.TempDir(), SnapshotInterval: config.Duration(time.Hour)}
+
+	store, err := service.NewPersistentLocalStore(conf)
+	require.NoError(t, err)
+	require.NoError(t, store.StoreRoom(ctx, &livekit.Room{
+		Sid:             "RM_saved",
+		Name:            "saved",
+		Metadata:        "metadata",
+		CreationTime:    100,
+		EmptyTimeout:    60,
+		NumParticipants: 2,
+	}))
+	require.NoError(t, store.StoreRoomInternal(ctx, "saved", &service.RoomInternal{MaxDuration: 600, Locked: true}))
+	require.NoError(t, store.StoreParticipant(ctx, "saved", &livekit.ParticipantInfo{Identity: "alice"}))
+	require.NoError(t, store.BlockParticipant(ctx, "saved", "mallory", time.Hour))
+	require.NoError(t, store.StoreRoom(ctx, &livekit.Room{Name: "short", EmptyTimeout: 1}))
+	require.NoError(t, store.Snapshot())
+
+	// rooms closed while shutting down are kept
+	store.Stop()
+	require.NoError(t, store.DeleteRoom(ctx, "saved"))
+	time.Sleep(1100 * time.Millisecond)
+
+	store, err = service.NewPersistentLocalStore(conf)
+	require.NoError(t, err)
+	defer store.Stop()
+
+	t.Run("rooms and settings are restored", func(t *testing.T) {
+		room, err := store.LoadRoom(ctx, "saved")
+		require.NoError(t, err)
+		require.Equal(t, "RM_saved", room.Sid)
+		require.Equal(t, "metadata", room.Metadata)
+		require.Equal(t, int64(100), room.CreationTime)
+		require.Zero(t, room.NumParticipants)
+
+		internal, err := store.LoadRoomInternal(ctx, "saved")
+		require.NoError(t, err)
+		require.Equal(t, uint32(600), internal.MaxDuration)
+		require.True(t, internal.Locked)
+
+		blocked, err := store.IsParticipantBlocked(ctx, "saved", "mallory")
+		require.NoError(t, err)
+		require.True(t, blocked)
+
+		require.NoError(t, store.UnblockParticipant(ctx, "saved", "mallory"))
+		blocked, err = store.IsParticipantBlocked(ctx, "saved", "mallory")
+		require.NoError(t, err)
+		require.False(t, blocked)
+	})
+
+	t.Run("participants are not restored", func(t *testing.T) {
+		participants, err := store.ListParticipants(ctx, "saved")
+		require.NoError(t, err)
+		require.Empty(t, participants)
+	})
+
+	t.Run("rooms empty for longer than their empty timeout are discarded", func(t *testing.T) {
+		_, err := store.LoadRoom(ctx, "short")
+		require.Equal(t, service.ErrRoomNotFound, err)
+	})
+}
+
+func TestLocalStoreRestoredRoomsExpire(t *testing.T) {
+	ctx := context.Background()
+	conf := config.LocalStorageConfig{DataDir: t.TempDir(), SnapshotInterval: config.Duration(time.Hour)}
+
+	store, err := service.NewPersistentLocalStore(conf)
+	require.NoError(t, err)
+	require.NoError(t, store.StoreRoom(ctx, &livekit.Room{Name: "rejoined", EmptyTimeout: 1}))
+	require.NoError(t, store.StoreRoom(ctx, &livekit.Room{Name: "abandoned", EmptyTimeout: 1}))
+	store.Stop()
+
+	store, err = service.NewPersistentLocalStore(conf)
+	require.NoError(t, err)
+	defer store.Stop()
+	require.NoError(t, store.StoreParticipant(ctx, "rejoined", &livekit.ParticipantInfo{Identity: "alice"}))
+
+	require.Eventually(t, func() bool {
+		_, err := store.LoadRoom(ctx, "abandoned")
+		return err == service.ErrRoomNotFound
+	}, 2*time.Second, 50*time.Millisecond)
+	_, err = store.LoadRoom(ctx, "rejoined")
+	require.NoError(t, err)
+
+	// the expired room is removed from the file as well
+	require.NoError(t, store.Snapshot())
+	data, err := os.ReadFile(filepath.Join(conf.DataDir, "rooms.json"))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "abandoned")
+}
+
+func TestLocalStoreCountRooms(t *testing.T) {
+	ctx := context.Background()
+	store := service.NewLocalStore()
+	for _, name := range []livekit.RoomName{"a", "b", "c"} {
+		require.NoError(t, store.StoreRoom(ctx, &livekit.Room{Name: string(name)}))
+	}
+	require.NoError(t, store.StoreRoomInternal(ctx, "a", &service.RoomInternal{APIKey: "key1"}))
+	require.NoError(t, store.StoreRoomInternal(ctx, "b", &service.RoomInternal{APIKey: "key1"}))
+	require.NoError(t, store.StoreRoomInternal(ctx, "c", &service.RoomInternal{APIKey: "key2"}))
+	// updating settings doesn't count the room twice
+	require.NoError(t, store.StoreRoomInternal(ctx, "a", &service.RoomInternal{APIKey: "key1", Locked: true}))
+
+	count, err := store.CountRooms(ctx, "key1")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	require.NoError(t, store.DeleteRoom(ctx, "a"))
+	count, err = store.CountRooms(ctx, "key1")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	count, err = store.CountRooms(ctx, "key2")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/rtc"
+)
+
+const localStoreSnapshotFile = "rooms.json"
+
+// localStoreSnapshot is the content of the snapshot file. When the rooms were last seen is the file's
+// modification time, refreshed while the server runs, so that it's accurate even when rooms haven't changed
+type localStoreSnapshot struct {
+	Rooms []*localStoreRoom `json:"rooms"`
+	// map of roomName => { identity: unix time the block expires }
+	BlockedParticipants map[livekit.RoomName]map[livekit.ParticipantIdentity]int64 `json:"blocked_participants,omitempty"`
+}
+
+type localStoreRoom struct {
+	// livekit.Room as protojson
+	Room     json.RawMessage `json:"room"`
+	Internal *RoomInternal   `json:"internal,omitempty"`
+}
+
+// NewPersistentLocalStore is a LocalStore saving rooms, their settings and blocked participants to the data
+// directory. Rooms saved previously are restored, unless they've been empty for longer than their empty timeout,
+// and deleted if no participant rejoins them within it
+func NewPersistentLocalStore(conf config.LocalStorageConfig) (*LocalStore, error) {
+	if err := os.MkdirAll(conf.DataDir, 0700); err != nil {
+		return nil, err
+	}
+
+	s := NewLocalStore()
+	s.snapshotFile = filepath.Join(conf.DataDir, localStoreSnapshotFile)
+	s.snapshotInterval = conf.SnapshotInterval.Duration()
+	if err := s.restore(); err != nil {
+		return nil, err
+	}
+
+	go s.snapshotWorker()
+	return s, nil
+}
+
+// Stop saves rooms a last time and stops saving them, so that rooms closed while the server shuts down are
+// restored on the next start
+func (s *LocalStore) Stop() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if err := s.Snapshot(); err != nil {
+			logger.Errorw("could not save rooms", err, "file", s.snapshotFile)
+		}
+
+		s.lock.Lock()
+		s.snapshotFile = ""
+		for name := range s.restoreTimers {
+			s.stopRestoreTimer(name)
+		}
+		s.lock.Unlock()
+	})
+}
+
+// Snapshot saves rooms when they've changed since the last snapshot, replacing the file atomically
+func (s *LocalStore) Snapshot() error {
+	s.lock.Lock()
+	file := s.snapshotFile
+	if file == "" {
+		s.lock.Unlock()
+		return nil
+	}
+	if !s.dirty {
+		s.lock.Unlock()
+		// rooms were seen now
+		now := time.Now()
+		if err := os.Chtimes(file, now, now); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	snapshot := &localStoreSnapshot{
+		BlockedParticipants: make(map[livekit.RoomName]map[livekit.ParticipantIdentity]int64),
+	}
+	for name, room := range s.rooms {
+		data, err := protojson.Marshal(room)
+		if err != nil {
+			s.lock.Unlock()
+			return err
+		}
+		snapshot.Rooms = append(snapshot.Rooms, &localStoreRoom{
+			Room:     data,
+			Internal: s.roomInternal[name],
+		})
+	}
+	now := time.Now()
+	for name, blocked := range s.blockedParticipants {
+		for identity, expiry := range blocked {
+			if !now.Before(expiry) {
+				continue
+			}
+			if snapshot.BlockedParticipants[name] == nil {
+				snapshot.BlockedParticipants[name] = make(map[livekit.ParticipantIdentity]int64)
+			}
+			snapshot.BlockedParticipants[name][identity] = expiry.Unix()
+		}
+	}
+	s.dirty = false
+	s.lock.Unlock()
+
+	if err := writeFileAtomic(file, snapshot); err != nil {
+		s.lock.Lock()
+		s.dirty = true
+		s.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// writeFileAtomic writes v as json to a temporary file in the same directory and renames it over the file, so that
+// a crash leaves either the previous or the new content
+func writeFileAtomic(file string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// no-op once renamed
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (s *LocalStore) snapshotWorker() {
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.Snapshot(); err != nil {
+				logger.Errorw("could not save rooms", err, "file", s.snapshotFile)
+			}
+		}
+	}
+}
+
+func (s *LocalStore) restore() error {
+	stat, err := os.Stat(s.snapshotFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(s.snapshotFile)
+	if err != nil {
+		return err
+	}
+	snapshot := &localStoreSnapshot{}
+	if err = json.Unmarshal(data, snapshot); err != nil {
+		return err
+	}
+
+	// participants left when the server stopped
+	emptyFor := time.Since(stat.ModTime())
+	var restored, discarded int
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, r := range snapshot.Rooms {
+		room := &livekit.Room{}
+		if err = protojson.Unmarshal(r.Room, room); err != nil {
+			return err
+		}
+		timeout := time.Duration(room.EmptyTimeout) * time.Second
+		if timeout == 0 {
+			timeout = rtc.DefaultEmptyTimeout * time.Second
+		}
+		if emptyFor >= timeout {
+			discarded++
+			continue
+		}
+
+		name := livekit.RoomName(room.Name)
+		room.NumParticipants = 0
+		s.rooms[name] = room
+		if r.Internal != nil {
+			s.setRoomKey(name, r.Internal.APIKey)
+			s.roomInternal[name] = r.Internal
+		}
+		s.restoreTimers[name] = time.AfterFunc(timeout-emptyFor, func() {
+			s.expireRestoredRoom(name)
+		})
+		restored++
+	}
+
+	now := time.Now()
+	for name, blocked := range snapshot.BlockedParticipants {
+		for identity, expiry := range blocked {
+			if now.Before(time.Unix(expiry, 0)) {
+				if s.blockedParticipants[name] == nil {
+					s.blockedParticipants[name] = make(map[livekit.ParticipantIdentity]time.Time)
+				}
+				s.blockedParticipants[name][identity] = time.Unix(expiry, 0)
+			}
+		}
+	}
+	// discarded rooms are removed from the file on the next snapshot
+	s.dirty = discarded > 0
+
+	logger.Infow("restored rooms", "file", s.snapshotFile, "restored", restored, "discarded", discarded)
+	return nil
+}
+
+// expireRestoredRoom deletes a restored room no participant rejoined
+func (s *LocalStore) expireRestoredRoom(name livekit.RoomName) {
+	s.lock.Lock()
+	_, ok := s.restoreTimers[name]
+	delete(s.restoreTimers, name)
+	s.lock.Unlock()
+	if !ok {
+		return
+	}
+
+	logger.Infow("deleting restored room, no participant rejoined", "room", name)
+	_ = s.DeleteRoom(context.Background(), name)
+}
+
+// stopRestoreTimer keeps a restored room. Called with the lock held
+func (s *LocalStore) stopRestoreTimer(name livekit.RoomName) {
+	if timer := s.restoreTimers[name]; timer != nil {
+		timer.Stop()
+		delete(s.restoreTimers, name)
+	}
+}
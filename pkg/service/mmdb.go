@@ -0,0 +1,272 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+	"net"
+	"os"
+)
+
+var errInvalidMMDB = errors.New("invalid MaxMind database")
+
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// data types of the MaxMind DB format
+const (
+	mmdbPointer   = 1
+	mmdbString    = 2
+	mmdbDouble    = 3
+	mmdbBytes     = 4
+	mmdbUint16    = 5
+	mmdbUint32    = 6
+	mmdbMap       = 7
+	mmdbInt32     = 8
+	mmdbUint64    = 9
+	mmdbUint128   = 10
+	mmdbArray     = 11
+	mmdbContainer = 12
+	mmdbEndMarker = 13
+	mmdbBool      = 14
+	mmdbFloat     = 15
+
+	// nesting allowed in records, deeper ones are invalid
+	mmdbMaxDepth = 32
+)
+
+// mmdbReader looks up addresses in a database in the MaxMind DB format, such as GeoLite2-City. The file is read
+// into memory, records are decoded as maps, slices, strings and numbers
+// https://maxmind.github.io/MaxMind-DB/
+type mmdbReader struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	// node IPv4 addresses start at in IPv6 databases, ::/96
+	ipv4Start uint
+	ipv4Only  bool
+}
+
+func openMMDB(file string) (*mmdbReader, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := bytes.LastIndex(buf, mmdbMetadataMarker)
+	if idx < 0 {
+		return nil, errInvalidMMDB
+	}
+	v, _, err := decodeMMDB(buf[idx+len(mmdbMetadataMarker):], 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	metadata, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errInvalidMMDB
+	}
+	version, _ := metadata["binary_format_major_version"].(uint64)
+	nodeCount, _ := metadata["node_count"].(uint64)
+	recordSize, _ := metadata["record_size"].(uint64)
+	ipVersion, _ := metadata["ip_version"].(uint64)
+	if version != 2 || (recordSize != 24 && recordSize != 28 && recordSize != 32) || (ipVersion != 4 && ipVersion != 6) {
+		return nil, errInvalidMMDB
+	}
+
+	treeSize := nodeCount * recordSize / 4
+	// the tree is followed by 16 zero bytes, then data
+	if treeSize+16 > uint64(idx) {
+		return nil, errInvalidMMDB
+	}
+	r := &mmdbReader{
+		tree:       buf[:treeSize],
+		data:       buf[treeSize+16 : idx],
+		nodeCount:  uint(nodeCount),
+		recordSize: uint(recordSize),
+		ipv4Only:   ipVersion == 4,
+	}
+	if !r.ipv4Only {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.readRecord(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// lookup returns the record of the network containing ip, or nil when it's not in the database
+func (r *mmdbReader) lookup(ip net.IP) (interface{}, error) {
+	node := uint(0)
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		node = r.ipv4Start
+	} else if r.ipv4Only {
+		return nil, nil
+	}
+
+	bits := len(ip) * 8
+	for i := 0; i < bits && node < r.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-uint(i%8))) & 1
+		node = r.readRecord(node, bit)
+	}
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, errInvalidMMDB
+	}
+
+	offset := node - r.nodeCount - 16
+	v, _, err := decodeMMDB(r.data, offset, 0)
+	return v, err
+}
+
+// readRecord returns the left (bit 0) or right (bit 1) record of a node
+func (r *mmdbReader) readRecord(node uint, bit uint) uint {
+	b := r.tree[node*r.recordSize/4:]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// decodeMMDB decodes the value at offset in data, returning the offset after it
+func decodeMMDB(data []byte, offset uint, depth int) (interface{}, uint, error) {
+	if depth > mmdbMaxDepth || offset >= uint(len(data)) {
+		return nil, 0, errInvalidMMDB
+	}
+	ctrl := data[offset]
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == mmdbPointer {
+		n := uint(ctrl>>3)&3 + 1
+		if offset+n > uint(len(data)) {
+			return nil, 0, errInvalidMMDB
+		}
+		b := data[offset : offset+n]
+		var pointer uint
+		switch n {
+		case 1:
+			pointer = uint(ctrl&7)<<8 | uint(b[0])
+		case 2:
+			pointer = (uint(ctrl&7)<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+		case 3:
+			pointer = (uint(ctrl&7)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+		default:
+			pointer = uint(binary.BigEndian.Uint32(b))
+		}
+		v, _, err := decodeMMDB(data, pointer, depth+1)
+		return v, offset + n, err
+	}
+	if typ == 0 {
+		if offset >= uint(len(data)) {
+			return nil, 0, errInvalidMMDB
+		}
+		typ = 7 + uint(data[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(data)) {
+			return nil, 0, errInvalidMMDB
+		}
+		var extra uint
+		for _, b := range data[offset : offset+n] {
+			extra = extra<<8 | uint(b)
+		}
+		offset += n
+		size = []uint{29, 285, 65821}[n-1] + extra
+	}
+
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]interface{})
+		for i := uint(0); i < size; i++ {
+			k, next, err := decodeMMDB(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errInvalidMMDB
+			}
+			if m[key], offset, err = decodeMMDB(data, next, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, 0)
+		for i := uint(0); i < size; i++ {
+			v, next, err := decodeMMDB(data, offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(data)) {
+		return nil, 0, errInvalidMMDB
+	}
+	b := data[offset : offset+size]
+	offset += size
+	switch typ {
+	case mmdbString:
+		return string(b), offset, nil
+	case mmdbBytes:
+		return append([]byte(nil), b...), offset, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errInvalidMMDB
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errInvalidMMDB
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		if size > 8 {
+			return nil, 0, errInvalidMMDB
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, offset, nil
+	case mmdbInt32:
+		if size > 4 {
+			return nil, 0, errInvalidMMDB
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), offset, nil
+	case mmdbUint128:
+		if size > 16 {
+			return nil, 0, errInvalidMMDB
+		}
+		return new(big.Int).SetBytes(b), offset, nil
+	default:
+		// containers and end markers only appear in data sections of other formats
+		return nil, 0, errInvalidMMDB
+	}
+}
@@ -0,0 +1,583 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/utils"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// tables are created when missing, columns besides data are the ones queries filter on
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS livekit_rooms (
+		name TEXT PRIMARY KEY,
+		creation_time BIGINT NOT NULL,
+		num_participants INTEGER NOT NULL,
+		data BYTEA NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS livekit_room_internal (
+		room_name TEXT PRIMARY KEY,
+		data JSONB NOT NULL
+	)`,
+	`ALTER TABLE livekit_room_internal ADD COLUMN IF NOT EXISTS api_key TEXT NOT NULL DEFAULT ''`,
+	`CREATE INDEX IF NOT EXISTS livekit_room_internal_api_key ON livekit_room_internal (api_key)`,
+	`CREATE TABLE IF NOT EXISTS livekit_participants (
+		room_name TEXT NOT NULL,
+		identity TEXT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		data BYTEA NOT NULL,
+		PRIMARY KEY (room_name, identity)
+	)`,
+	`CREATE INDEX IF NOT EXISTS livekit_participants_updated_at ON livekit_participants (updated_at)`,
+	`CREATE TABLE IF NOT EXISTS livekit_pending_participants (
+		room_name TEXT NOT NULL,
+		identity TEXT NOT NULL,
+		data JSONB NOT NULL,
+		PRIMARY KEY (room_name, identity)
+	)`,
+	`CREATE TABLE IF NOT EXISTS livekit_blocked_participants (
+		room_name TEXT NOT NULL,
+		identity TEXT NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (room_name, identity)
+	)`,
+	`CREATE INDEX IF NOT EXISTS livekit_blocked_participants_expires_at ON livekit_blocked_participants (expires_at)`,
+	`CREATE TABLE IF NOT EXISTS livekit_room_locks (
+		room_name TEXT PRIMARY KEY,
+		token TEXT NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS livekit_egress (
+		egress_id TEXT PRIMARY KEY,
+		room_id TEXT NOT NULL,
+		data BYTEA NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS livekit_egress_room_id ON livekit_egress (room_id)`,
+}
+
+// escapes the characters LIKE treats as a pattern, with the default backslash escape
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// PostgresStore keeps rooms, participants and egress in Postgres, so that they survive redis being flushed and can
+// be queried outside of LiveKit. Nodes sharing a PostgresStore still route through redis.
+// Postgres has no expiring keys: participants that haven't been updated within the TTL, expired blocks and
+// expired locks are deleted periodically instead
+type PostgresStore struct {
+	db   *sql.DB
+	conf config.PostgresConfig
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewPostgresStore connects to the database, creates the tables that are missing and starts the cleanup
+func NewPostgresStore(conf config.PostgresConfig) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", conf.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if conf.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(conf.MaxOpenConns)
+		db.SetMaxIdleConns(conf.MaxOpenConns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "could not connect to postgres")
+	}
+	for _, stmt := range postgresSchema {
+		if _, err = db.ExecContext(ctx, stmt); err != nil {
+			_ = db.Close()
+			return nil, errors.Wrap(err, "could not create postgres tables")
+		}
+	}
+
+	s := &PostgresStore{
+		db:   db,
+		conf: conf,
+		done: make(chan struct{}),
+	}
+	go s.cleanupWorker()
+	return s, nil
+}
+
+// Stop ends the cleanup and closes the connections
+func (s *PostgresStore) Stop() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		_ = s.db.Close()
+	})
+}
+
+func (s *PostgresStore) StoreRoom(ctx context.Context, room *livekit.Room) error {
+	if room.CreationTime == 0 {
+		room.CreationTime = time.Now().Unix()
+	}
+
+	data, err := proto.Marshal(room)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO livekit_rooms (name, creation_time, num_participants, data)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name) DO UPDATE SET
+			creation_time = EXCLUDED.creation_time, num_participants = EXCLUDED.num_participants, data = EXCLUDED.data`,
+		room.Name, room.CreationTime, room.NumParticipants, data)
+	if err != nil {
+		return errors.Wrap(err, "could not create room")
+	}
+	return nil
+}
+
+func (s *PostgresStore) LoadRoom(ctx context.Context, name livekit.RoomName) (*livekit.Room, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM livekit_rooms WHERE name = $1`, string(name)).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	room := livekit.Room{}
+	if err = proto.Unmarshal(data, &room); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+func (s *PostgresStore) ListRooms(ctx context.Context, names []livekit.RoomName) ([]*livekit.Room, error) {
+	var rows *sql.Rows
+	var err error
+	if names == nil {
+		rows, err = s.db.QueryContext(ctx, `SELECT data FROM livekit_rooms`)
+	} else {
+		rows, err = s.db.QueryContext(ctx, `SELECT data FROM livekit_rooms WHERE name = ANY($1)`,
+			pq.Array(livekit.RoomNamesAsStrings(names)))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get rooms")
+	}
+	return scanRooms(rows)
+}
+
+// ListRoomsPage pages through rooms ordered by name, the page token being the last name of the previous page
+func (s *PostgresStore) ListRoomsPage(ctx context.Context, opts ListRoomsOptions) ([]*livekit.Room, string, error) {
+	var after string
+	if opts.PageToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(opts.PageToken)
+		if err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+		after = string(decoded)
+	}
+
+	query := `SELECT data FROM livekit_rooms
+		WHERE name > $1 AND name LIKE $2 AND creation_time > $3 AND num_participants >= $4
+		ORDER BY name`
+	args := []interface{}{after, likeEscaper.Replace(opts.NamePrefix) + "%", opts.CreatedAfter, opts.MinParticipants}
+	if opts.Limit > 0 {
+		// one more to tell whether there's a next page
+		query += ` LIMIT $5`
+		args = append(args, opts.Limit+1)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "could not get rooms")
+	}
+	rooms, err := scanRooms(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var nextPageToken string
+	if opts.Limit > 0 && len(rooms) > opts.Limit {
+		rooms = rooms[:opts.Limit]
+		nextPageToken = base64.RawURLEncoding.EncodeToString([]byte(rooms[len(rooms)-1].Name))
+	}
+	return rooms, nextPageToken, nil
+}
+
+func scanRooms(rows *sql.Rows) ([]*livekit.Room, error) {
+	defer rows.Close()
+
+	var rooms []*livekit.Room
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		room := livekit.Room{}
+		if err := proto.Unmarshal(data, &room); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, &room)
+	}
+	return rooms, rows.Err()
+}
+
+func (s *PostgresStore) DeleteRoom(ctx context.Context, name livekit.RoomName) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, stmt := range []string{
+		`DELETE FROM livekit_rooms WHERE name = $1`,
+		`DELETE FROM livekit_room_internal WHERE room_name = $1`,
+		`DELETE FROM livekit_participants WHERE room_name = $1`,
+		`DELETE FROM livekit_pending_participants WHERE room_name = $1`,
+	} {
+		if _, err = tx.ExecContext(ctx, stmt, string(name)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresStore) StoreRoomInternal(ctx context.Context, name livekit.RoomName, internal *RoomInternal) error {
+	data, err := json.Marshal(internal)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO livekit_room_internal (room_name, api_key, data) VALUES ($1, $2, $3)
+		ON CONFLICT (room_name) DO UPDATE SET api_key = EXCLUDED.api_key, data = EXCLUDED.data`,
+		string(name), internal.APIKey, data)
+	return err
+}
+
+func (s *PostgresStore) CountRooms(ctx context.Context, apiKey string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM livekit_room_internal WHERE api_key = $1`, apiKey).Scan(&count)
+	return count, err
+}
+
+func (s *PostgresStore) LoadRoomInternal(ctx context.Context, name livekit.RoomName) (*RoomInternal, error) {
+	internal := &RoomInternal{}
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM livekit_room_internal WHERE room_name = $1`, string(name)).Scan(&data)
+	if err == sql.ErrNoRows {
+		return internal, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, internal); err != nil {
+		return nil, err
+	}
+	return internal, nil
+}
+
+func (s *PostgresStore) LockRoom(ctx context.Context, name livekit.RoomName, duration time.Duration) (string, error) {
+	token := utils.NewGuid("LOCK")
+
+	startTime := time.Now()
+	for {
+		// takes the lock when there's none, or the one held has expired
+		var locked string
+		err := s.db.QueryRowContext(ctx, `INSERT INTO livekit_room_locks (room_name, token, expires_at)
+			VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+			ON CONFLICT (room_name) DO UPDATE SET token = EXCLUDED.token, expires_at = EXCLUDED.expires_at
+			WHERE livekit_room_locks.expires_at < now()
+			RETURNING token`, string(name), token, duration.Milliseconds()).Scan(&locked)
+		if err == nil {
+			return token, nil
+		} else if err != sql.ErrNoRows {
+			return "", err
+		}
+
+		// stop waiting past lock duration
+		if time.Since(startTime) > duration {
+			break
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return "", ErrRoomLockFailed
+}
+
+func (s *PostgresStore) UnlockRoom(ctx context.Context, name livekit.RoomName, uid string) error {
+	var token string
+	err := s.db.QueryRowContext(ctx, `SELECT token FROM livekit_room_locks WHERE room_name = $1 AND expires_at >= now()`,
+		string(name)).Scan(&token)
+	if err == sql.ErrNoRows {
+		// already unlocked
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if token != uid {
+		return ErrRoomUnlockFailed
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM livekit_room_locks WHERE room_name = $1 AND token = $2`, string(name), uid)
+	return err
+}
+
+func (s *PostgresStore) StoreParticipant(ctx context.Context, roomName livekit.RoomName, participant *livekit.ParticipantInfo) error {
+	data, err := proto.Marshal(participant)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO livekit_participants (room_name, identity, updated_at, data)
+		VALUES ($1, $2, now(), $3)
+		ON CONFLICT (room_name, identity) DO UPDATE SET updated_at = EXCLUDED.updated_at, data = EXCLUDED.data`,
+		string(roomName), participant.Identity, data)
+	return err
+}
+
+func (s *PostgresStore) LoadParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (*livekit.ParticipantInfo, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM livekit_participants WHERE room_name = $1 AND identity = $2`,
+		string(roomName), string(identity)).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrParticipantNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	pi := livekit.ParticipantInfo{}
+	if err = proto.Unmarshal(data, &pi); err != nil {
+		return nil, err
+	}
+	return &pi, nil
+}
+
+func (s *PostgresStore) ListParticipants(ctx context.Context, roomName livekit.RoomName) ([]*livekit.ParticipantInfo, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM livekit_participants WHERE room_name = $1`, string(roomName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []*livekit.ParticipantInfo
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		pi := livekit.ParticipantInfo{}
+		if err = proto.Unmarshal(data, &pi); err != nil {
+			return nil, err
+		}
+		participants = append(participants, &pi)
+	}
+	return participants, rows.Err()
+}
+
+func (s *PostgresStore) DeleteParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM livekit_participants WHERE room_name = $1 AND identity = $2`,
+		string(roomName), string(identity))
+	return err
+}
+
+func (s *PostgresStore) BlockParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity, duration time.Duration) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO livekit_blocked_participants (room_name, identity, expires_at)
+		VALUES ($1, $2, now() + $3 * interval '1 millisecond')
+		ON CONFLICT (room_name, identity) DO UPDATE SET expires_at = EXCLUDED.expires_at`,
+		string(roomName), string(identity), duration.Milliseconds())
+	return err
+}
+
+func (s *PostgresStore) UnblockParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM livekit_blocked_participants WHERE room_name = $1 AND identity = $2`,
+		string(roomName), string(identity))
+	return err
+}
+
+func (s *PostgresStore) IsParticipantBlocked(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (bool, error) {
+	var blocked bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM livekit_blocked_participants
+		WHERE room_name = $1 AND identity = $2 AND expires_at > now())`, string(roomName), string(identity)).Scan(&blocked)
+	return blocked, err
+}
+
+func (s *PostgresStore) StorePendingParticipant(ctx context.Context, roomName livekit.RoomName, pending *PendingParticipant) error {
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO livekit_pending_participants (room_name, identity, data)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (room_name, identity) DO UPDATE SET data = EXCLUDED.data`,
+		string(roomName), string(pending.Identity), data)
+	return err
+}
+
+func (s *PostgresStore) LoadPendingParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (*PendingParticipant, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM livekit_pending_participants WHERE room_name = $1 AND identity = $2`,
+		string(roomName), string(identity)).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrParticipantNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	pending := &PendingParticipant{}
+	if err = json.Unmarshal(data, pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+func (s *PostgresStore) ListPendingParticipants(ctx context.Context, roomName livekit.RoomName) ([]*PendingParticipant, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM livekit_pending_participants WHERE room_name = $1`, string(roomName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []*PendingParticipant
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		p := &PendingParticipant{}
+		if err = json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+func (s *PostgresStore) DeletePendingParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM livekit_pending_participants WHERE room_name = $1 AND identity = $2`,
+		string(roomName), string(identity))
+	return err
+}
+
+func (s *PostgresStore) StoreEgress(ctx context.Context, info *livekit.EgressInfo) error {
+	data, err := proto.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO livekit_egress (egress_id, room_id, data) VALUES ($1, $2, $3)
+		ON CONFLICT (egress_id) DO UPDATE SET room_id = EXCLUDED.room_id, data = EXCLUDED.data`,
+		info.EgressId, info.RoomId, data)
+	if err != nil {
+		return errors.Wrap(err, "could not store egress info")
+	}
+	return nil
+}
+
+func (s *PostgresStore) LoadEgress(ctx context.Context, egressID string) (*livekit.EgressInfo, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM livekit_egress WHERE egress_id = $1`, egressID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrEgressNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	info := &livekit.EgressInfo{}
+	if err = proto.Unmarshal(data, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (s *PostgresStore) ListEgress(ctx context.Context, roomID livekit.RoomID) ([]*livekit.EgressInfo, error) {
+	var rows *sql.Rows
+	var err error
+	if roomID == "" {
+		rows, err = s.db.QueryContext(ctx, `SELECT data FROM livekit_egress`)
+	} else {
+		rows, err = s.db.QueryContext(ctx, `SELECT data FROM livekit_egress WHERE room_id = $1`, string(roomID))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var infos []*livekit.EgressInfo
+	for rows.Next() {
+		var data []byte
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		info := &livekit.EgressInfo{}
+		if err = proto.Unmarshal(data, info); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, rows.Err()
+}
+
+func (s *PostgresStore) UpdateEgress(ctx context.Context, info *livekit.EgressInfo) error {
+	data, err := proto.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	if _, err = s.db.ExecContext(ctx, `UPDATE livekit_egress SET data = $2 WHERE egress_id = $1`, info.EgressId, data); err != nil {
+		return errors.Wrap(err, "could not store egress info")
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteEgress(ctx context.Context, info *livekit.EgressInfo) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM livekit_egress WHERE egress_id = $1`, info.EgressId)
+	return err
+}
+
+func (s *PostgresStore) cleanupWorker() {
+	ticker := time.NewTicker(s.conf.CleanupInterval.Duration())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.Cleanup(context.Background()); err != nil {
+				logger.Errorw("could not clean up postgres store", err)
+			}
+		}
+	}
+}
+
+// Cleanup deletes participants not updated within the TTL or of rooms that no longer exist, expired blocks and
+// expired locks
+func (s *PostgresStore) Cleanup(ctx context.Context) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM livekit_participants p
+		WHERE p.updated_at < $1 OR NOT EXISTS (SELECT 1 FROM livekit_rooms r WHERE r.name = p.room_name)`,
+		time.Now().Add(-s.conf.ParticipantTTL.Duration()))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		logger.Infow("deleted stale participants", "count", n)
+	}
+
+	if _, err = s.db.ExecContext(ctx, `DELETE FROM livekit_blocked_participants WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `DELETE FROM livekit_room_locks WHERE expires_at < now()`)
+	return err
+}
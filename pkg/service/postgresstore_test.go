@@ -0,0 +1,127 @@
+package service_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+// postgresStore connects to the database in LIVEKIT_TEST_POSTGRES_DSN, skipping the test when it's not set
+func postgresStore(t *testing.T, participantTTL time.Duration) *service.PostgresStore {
+	dsn := os.Getenv("LIVEKIT_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("LIVEKIT_TEST_POSTGRES_DSN is not set")
+	}
+	s, err := service.NewPostgresStore(config.PostgresConfig{
+		DSN:             dsn,
+		ParticipantTTL:  config.Duration(participantTTL),
+		CleanupInterval: config.Duration(time.Hour),
+	})
+	require.NoError(t, err)
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func TestPostgresStore(t *testing.T) {
+	ctx := context.Background()
+	s := postgresStore(t, time.Hour)
+
+	roomName := livekit.RoomName("postgres_room1")
+	require.NoError(t, s.DeleteRoom(ctx, roomName))
+	require.NoError(t, s.StoreRoom(ctx, &livekit.Room{Sid: "RM_postgres", Name: string(roomName)}))
+
+	room, err := s.LoadRoom(ctx, roomName)
+	require.NoError(t, err)
+	require.Equal(t, "RM_postgres", room.Sid)
+	require.NotZero(t, room.CreationTime)
+
+	rooms, err := s.ListRooms(ctx, []livekit.RoomName{roomName, "postgres_missing"})
+	require.NoError(t, err)
+	require.Len(t, rooms, 1)
+
+	require.NoError(t, s.StoreRoomInternal(ctx, roomName, &service.RoomInternal{MaxDuration: 60}))
+	internal, err := s.LoadRoomInternal(ctx, roomName)
+	require.NoError(t, err)
+	require.Equal(t, uint32(60), internal.MaxDuration)
+
+	require.NoError(t, s.StoreRoomInternal(ctx, roomName, &service.RoomInternal{APIKey: "postgres_key"}))
+	count, err := s.CountRooms(ctx, "postgres_key")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	p := &livekit.ParticipantInfo{Sid: "PA_test", Identity: "test", State: livekit.ParticipantInfo_ACTIVE}
+	require.NoError(t, s.StoreParticipant(ctx, roomName, p))
+	pGet, err := s.LoadParticipant(ctx, roomName, "test")
+	require.NoError(t, err)
+	require.Equal(t, p.Sid, pGet.Sid)
+	participants, err := s.ListParticipants(ctx, roomName)
+	require.NoError(t, err)
+	require.Len(t, participants, 1)
+
+	require.NoError(t, s.BlockParticipant(ctx, roomName, "test", time.Minute))
+	blocked, err := s.IsParticipantBlocked(ctx, roomName, "test")
+	require.NoError(t, err)
+	require.True(t, blocked)
+	require.NoError(t, s.UnblockParticipant(ctx, roomName, "test"))
+	blocked, err = s.IsParticipantBlocked(ctx, roomName, "test")
+	require.NoError(t, err)
+	require.False(t, blocked)
+
+	// deleting the room removes its participants
+	require.NoError(t, s.DeleteRoom(ctx, roomName))
+	_, err = s.LoadRoom(ctx, roomName)
+	require.Equal(t, service.ErrRoomNotFound, err)
+	_, err = s.LoadParticipant(ctx, roomName, "test")
+	require.Equal(t, service.ErrParticipantNotFound, err)
+	count, err = s.CountRooms(ctx, "postgres_key")
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+func TestPostgresRoomLock(t *testing.T) {
+	ctx := context.Background()
+	s := postgresStore(t, time.Hour)
+	roomName := livekit.RoomName("postgres_lock")
+
+	token, err := s.LockRoom(ctx, roomName, 200*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, service.ErrRoomUnlockFailed, s.UnlockRoom(ctx, roomName, "other"))
+
+	// a second lock waits for the first to expire
+	start := time.Now()
+	token2, err := s.LockRoom(ctx, roomName, time.Second)
+	require.NoError(t, err)
+	require.NotEqual(t, token, token2)
+	require.Greater(t, time.Since(start), 100*time.Millisecond)
+
+	require.NoError(t, s.UnlockRoom(ctx, roomName, token2))
+	// unlocked rooms unlock again without error
+	require.NoError(t, s.UnlockRoom(ctx, roomName, token2))
+}
+
+func TestPostgresStoreCleanup(t *testing.T) {
+	ctx := context.Background()
+	s := postgresStore(t, time.Millisecond)
+
+	roomName := livekit.RoomName("postgres_cleanup")
+	require.NoError(t, s.StoreRoom(ctx, &livekit.Room{Name: string(roomName)}))
+	defer s.DeleteRoom(ctx, roomName)
+	require.NoError(t, s.StoreParticipant(ctx, roomName, &livekit.ParticipantInfo{Identity: "stale"}))
+	// participants of rooms that were deleted without cleaning up
+	require.NoError(t, s.StoreParticipant(ctx, "postgres_gone", &livekit.ParticipantInfo{Identity: "orphan"}))
+
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, s.Cleanup(ctx))
+
+	_, err := s.LoadParticipant(ctx, roomName, "stale")
+	require.Equal(t, service.ErrParticipantNotFound, err)
+	_, err = s.LoadParticipant(ctx, "postgres_gone", "orphan")
+	require.Equal(t, service.ErrParticipantNotFound, err)
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+var ErrPrometheusInsecure = errors.New("prometheus credentials require TLS or a loopback bind_address, set prometheus.allow_insecure to override")
+
+// NewPrometheusHandler returns the metrics handler, protected with basic auth when credentials are configured.
+// Credentials are refused on a plaintext listener reachable from other hosts unless AllowInsecure is set
+func NewPrometheusHandler(conf config.PrometheusConfig) (http.Handler, error) {
+	handler := promhttp.Handler()
+	if conf.Username == "" && conf.Password == "" {
+		return handler, nil
+	}
+
+	if conf.TLS.CertFile == "" && !conf.AllowInsecure && !isLoopback(conf.BindAddress) {
+		return nil, ErrPrometheusInsecure
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(conf.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(conf.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			handleError(w, http.StatusUnauthorized, "invalid credentials")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}), nil
+}
+
+func isLoopback(address string) bool {
+	if address == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(address)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,56 @@
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	t.Run("refuses credentials on plaintext public listener", func(t *testing.T) {
+		_, err := service.NewPrometheusHandler(config.PrometheusConfig{
+			Port:     6789,
+			Username: "metrics",
+			Password: "password",
+		})
+		require.ErrorIs(t, err, service.ErrPrometheusInsecure)
+
+		_, err = service.NewPrometheusHandler(config.PrometheusConfig{
+			Port:          6789,
+			Username:      "metrics",
+			Password:      "password",
+			AllowInsecure: true,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("requires basic auth", func(t *testing.T) {
+		handler, err := service.NewPrometheusHandler(config.PrometheusConfig{
+			Port:        6789,
+			BindAddress: "127.0.0.1",
+			Username:    "metrics",
+			Password:    "password",
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+
+		req.SetBasicAuth("metrics", "wrong")
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+
+		req.SetBasicAuth("metrics", "password")
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/rand"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/livekit/protocol/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+const messageBusLockExpiration = time.Second * 5
+
+// UniversalMessageBus is a utils.MessageBus that works with both single node and cluster redis clients.
+// utils.NewRedisMessageBus only accepts a *redis.Client
+type UniversalMessageBus struct {
+	rc redis.UniversalClient
+}
+
+func NewUniversalMessageBus(rc redis.UniversalClient) *UniversalMessageBus {
+	return &UniversalMessageBus{rc: rc}
+}
+
+func (r *UniversalMessageBus) Lock(ctx context.Context, key string, expiration time.Duration) (bool, error) {
+	return r.rc.SetNX(ctx, key, rand.Int(), expiration).Result()
+}
+
+func (r *UniversalMessageBus) Subscribe(ctx context.Context, channel string) (utils.PubSub, error) {
+	sub := r.rc.Subscribe(ctx, channel)
+	ps := newUniversalPubSub(sub)
+	go ps.forward(sub.Channel(), nil)
+	return ps, nil
+}
+
+func (r *UniversalMessageBus) SubscribeQueue(ctx context.Context, channel string) (utils.PubSub, error) {
+	sub := r.rc.Subscribe(ctx, channel)
+	ps := newUniversalPubSub(sub)
+	go ps.forward(sub.Channel(), func(msg *redis.Message) bool {
+		// ensure only a single instance gets to process the message
+		sha := sha256.Sum256([]byte(msg.Payload))
+		hash := base64.StdEncoding.EncodeToString(sha[:])
+		acquired, _ := r.Lock(ctx, hash, messageBusLockExpiration)
+		if acquired {
+			utils.PromMessageBusCounter.WithLabelValues("in", "success").Add(1)
+		}
+		return acquired
+	})
+	return ps, nil
+}
+
+func (r *UniversalMessageBus) Publish(ctx context.Context, channel string, msg proto.Message) error {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		utils.PromMessageBusCounter.WithLabelValues("out", "failure").Add(1)
+		return err
+	}
+
+	err = r.rc.Publish(ctx, channel, b).Err()
+	if err == nil {
+		utils.PromMessageBusCounter.WithLabelValues("out", "success").Add(1)
+	} else {
+		utils.PromMessageBusCounter.WithLabelValues("out", "failure").Add(1)
+	}
+
+	return err
+}
+
+type universalPubSub struct {
+	ps   *redis.PubSub
+	c    chan interface{}
+	done chan struct{}
+}
+
+func newUniversalPubSub(ps *redis.PubSub) *universalPubSub {
+	return &universalPubSub{
+		ps: ps,
+		// same chan size as redis pubsub
+		c:    make(chan interface{}, 100),
+		done: make(chan struct{}),
+	}
+}
+
+func (r *universalPubSub) forward(in <-chan *redis.Message, filter func(msg *redis.Message) bool) {
+	for {
+		select {
+		case <-r.done:
+			return
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+			if filter != nil && !filter(msg) {
+				continue
+			}
+			select {
+			case r.c <- msg:
+			case <-r.done:
+				return
+			}
+		}
+	}
+}
+
+func (r *universalPubSub) Channel() <-chan interface{} {
+	return r.c
+}
+
+func (r *universalPubSub) Payload(msg interface{}) []byte {
+	return []byte(msg.(*redis.Message).Payload)
+}
+
+func (r *universalPubSub) Close() error {
+	close(r.done)
+	return r.ps.Close()
+}
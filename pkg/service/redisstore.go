@@ -2,6 +2,11 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -9,12 +14,20 @@ import (
 	"github.com/livekit/protocol/utils"
 	"github.com/pkg/errors"
 	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/config"
 )
 
 const (
 	// RoomsKey is hash of room_name => Room proto
 	RoomsKey = "rooms"
 
+	// RoomInternalKey is hash of room_name => RoomInternal json
+	RoomInternalKey = "room_internal"
+
+	// KeyRoomsPrefix is a set of the names of rooms created with an API key
+	KeyRoomsPrefix = "key_rooms:"
+
 	// EgressKey is a hash of egressID => egress info
 	EgressKey        = "egress"
 	RoomEgressPrefix = "room_egress:"
@@ -22,20 +35,71 @@ const (
 	// RoomParticipantsPrefix is hash of participant_name => ParticipantInfo
 	RoomParticipantsPrefix = "room_participants:"
 
+	// RoomParticipantHeartbeatsPrefix is hash of identity => participantHeartbeat json, the node hosting the
+	// participant and when it last refreshed it
+	RoomParticipantHeartbeatsPrefix = "room_participant_heartbeats:"
+
+	// RoomEmptiedKey is hash of room_name => unix time the room was found empty on a node that's gone
+	RoomEmptiedKey = "room_emptied"
+
 	// RoomLockPrefix is a simple key containing a provided lock uid
 	RoomLockPrefix = "room_lock:"
+
+	// BlockedParticipantPrefix is a simple key per room_name:identity, expiring when the participant may rejoin
+	BlockedParticipantPrefix = "blocked_participant:"
+
+	// RoomPendingParticipantsPrefix is hash of identity => PendingParticipant json
+	RoomPendingParticipantsPrefix = "room_pending_participants:"
+
+	// rooms requested per HSCAN when listing without a limit
+	listRoomsScanCount = 1000
 )
 
+// escapes the characters HSCAN's MATCH treats as a pattern
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// RedisStore works with both single node redis and Redis Cluster. Multi-key operations are issued as
+// non-transactional pipelines, which the cluster client splits by slot. They are not atomic, a failed pipeline can
+// leave some of its writes applied, so they only hold writes that are safe to repeat.
+// When conf.ParticipantTTL is set, participants stored by a node are refreshed by it until they're deleted, and
+// participants its refreshes stopped for, e.g. because it crashed, are deleted
 type RedisStore struct {
-	rc  *redis.Client
-	ctx context.Context
+	rc     redis.UniversalClient
+	ctx    context.Context
+	nodeID livekit.NodeID
+	conf   config.RedisStorageConfig
+
+	// participants stored by this node. map of roomName => { identity: ParticipantInfo proto }
+	lock   sync.Mutex
+	hosted map[livekit.RoomName]map[livekit.ParticipantIdentity][]byte
+
+	closeOnce sync.Once
+	done      chan struct{}
 }
 
-func NewRedisStore(rc *redis.Client) *RedisStore {
-	return &RedisStore{
-		ctx: context.Background(),
-		rc:  rc,
+func NewRedisStore(rc redis.UniversalClient, nodeID livekit.NodeID, conf config.RedisStorageConfig) *RedisStore {
+	s := &RedisStore{
+		ctx:    context.Background(),
+		rc:     rc,
+		nodeID: nodeID,
+		conf:   conf,
+		hosted: make(map[livekit.RoomName]map[livekit.ParticipantIdentity][]byte),
+		done:   make(chan struct{}),
 	}
+	if conf.ParticipantTTL > 0 {
+		go s.heartbeatWorker()
+		if conf.CleanupInterval > 0 {
+			go s.cleanupWorker()
+		}
+	}
+	return s
+}
+
+// Stop ends participant refreshes and cleanup
+func (s *RedisStore) Stop() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
 }
 
 func (s *RedisStore) StoreRoom(_ context.Context, room *livekit.Room) error {
@@ -110,20 +174,125 @@ func (s *RedisStore) ListRooms(_ context.Context, names []livekit.RoomName) ([]*
 	return rooms, nil
 }
 
+// ListRoomsPage iterates the rooms hash with HSCAN rather than loading it whole. The page token is the HSCAN cursor,
+// and like HSCAN's COUNT, Limit is a hint: a page may hold a few more rooms. Rooms are ordered by name within a page
+func (s *RedisStore) ListRoomsPage(_ context.Context, opts ListRoomsOptions) ([]*livekit.Room, string, error) {
+	var cursor uint64
+	if opts.PageToken != "" {
+		var err error
+		if cursor, err = strconv.ParseUint(opts.PageToken, 10, 64); err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+	}
+
+	match := ""
+	if opts.NamePrefix != "" {
+		match = redisGlobEscaper.Replace(opts.NamePrefix) + "*"
+	}
+	count := int64(opts.Limit)
+	if count <= 0 {
+		count = listRoomsScanCount
+	}
+
+	var rooms []*livekit.Room
+	for {
+		items, next, err := s.rc.HScan(s.ctx, RoomsKey, cursor, match, count).Result()
+		if err != nil {
+			return nil, "", errors.Wrap(err, "could not scan rooms")
+		}
+		// items alternate between field and value
+		for i := 1; i < len(items); i += 2 {
+			room := livekit.Room{}
+			if err = proto.Unmarshal([]byte(items[i]), &room); err != nil {
+				return nil, "", err
+			}
+			if opts.Matches(&room) {
+				rooms = append(rooms, &room)
+			}
+		}
+
+		cursor = next
+		if cursor == 0 || (opts.Limit > 0 && len(rooms) >= opts.Limit) {
+			break
+		}
+	}
+
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].Name < rooms[j].Name
+	})
+
+	var nextPageToken string
+	if cursor != 0 {
+		nextPageToken = strconv.FormatUint(cursor, 10)
+	}
+	return rooms, nextPageToken, nil
+}
+
 func (s *RedisStore) DeleteRoom(ctx context.Context, name livekit.RoomName) error {
 	_, err := s.LoadRoom(ctx, name)
 	if err == ErrRoomNotFound {
 		return nil
 	}
+	internal, err := s.LoadRoomInternal(ctx, name)
+	if err != nil {
+		return err
+	}
 
 	pp := s.rc.Pipeline()
 	pp.HDel(s.ctx, RoomsKey, string(name))
+	pp.HDel(s.ctx, RoomInternalKey, string(name))
+	if internal.APIKey != "" {
+		pp.SRem(s.ctx, KeyRoomsPrefix+internal.APIKey, string(name))
+	}
 	pp.Del(s.ctx, RoomParticipantsPrefix+string(name))
+	pp.Del(s.ctx, RoomPendingParticipantsPrefix+string(name))
+	pp.Del(s.ctx, RoomParticipantHeartbeatsPrefix+string(name))
+	pp.HDel(s.ctx, RoomEmptiedKey, string(name))
+
+	s.lock.Lock()
+	delete(s.hosted, name)
+	s.lock.Unlock()
+
+	_, err = pp.Exec(s.ctx)
+	return err
+}
 
+func (s *RedisStore) StoreRoomInternal(_ context.Context, name livekit.RoomName, internal *RoomInternal) error {
+	data, err := json.Marshal(internal)
+	if err != nil {
+		return err
+	}
+	if internal.APIKey == "" {
+		return s.rc.HSet(s.ctx, RoomInternalKey, string(name), data).Err()
+	}
+
+	pp := s.rc.Pipeline()
+	pp.HSet(s.ctx, RoomInternalKey, string(name), data)
+	pp.SAdd(s.ctx, KeyRoomsPrefix+internal.APIKey, string(name))
 	_, err = pp.Exec(s.ctx)
 	return err
 }
 
+func (s *RedisStore) CountRooms(_ context.Context, apiKey string) (int, error) {
+	count, err := s.rc.SCard(s.ctx, KeyRoomsPrefix+apiKey).Result()
+	return int(count), err
+}
+
+func (s *RedisStore) LoadRoomInternal(_ context.Context, name livekit.RoomName) (*RoomInternal, error) {
+	internal := &RoomInternal{}
+	data, err := s.rc.HGet(s.ctx, RoomInternalKey, string(name)).Result()
+	if err == redis.Nil {
+		return internal, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal([]byte(data), internal); err != nil {
+		return nil, err
+	}
+	return internal, nil
+}
+
 func (s *RedisStore) LockRoom(_ context.Context, name livekit.RoomName, duration time.Duration) (string, error) {
 	token := utils.NewGuid("LOCK")
 	key := RoomLockPrefix + string(name)
@@ -174,7 +343,27 @@ func (s *RedisStore) StoreParticipant(_ context.Context, roomName livekit.RoomNa
 		return err
 	}
 
-	return s.rc.HSet(s.ctx, key, participant.Identity, data).Err()
+	if s.conf.ParticipantTTL <= 0 {
+		return s.rc.HSet(s.ctx, key, participant.Identity, data).Err()
+	}
+
+	identity := livekit.ParticipantIdentity(participant.Identity)
+	s.lock.Lock()
+	if s.hosted[roomName] == nil {
+		s.hosted[roomName] = make(map[livekit.ParticipantIdentity][]byte)
+	}
+	s.hosted[roomName][identity] = data
+	s.lock.Unlock()
+
+	heartbeat, err := s.newHeartbeat()
+	if err != nil {
+		return err
+	}
+	pp := s.rc.Pipeline()
+	pp.HSet(s.ctx, key, participant.Identity, data)
+	pp.HSet(s.ctx, RoomParticipantHeartbeatsPrefix+string(roomName), participant.Identity, heartbeat)
+	_, err = pp.Exec(s.ctx)
+	return err
 }
 
 func (s *RedisStore) LoadParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (*livekit.ParticipantInfo, error) {
@@ -186,6 +375,18 @@ func (s *RedisStore) LoadParticipant(_ context.Context, roomName livekit.RoomNam
 		return nil, err
 	}
 
+	if s.conf.ParticipantTTL > 0 {
+		heartbeats, err := s.loadHeartbeats(roomName, identity)
+		if err != nil {
+			return nil, err
+		}
+		if stale, err := s.removeStaleParticipants(roomName, heartbeats); err != nil {
+			return nil, err
+		} else if len(stale) > 0 {
+			return nil, ErrParticipantNotFound
+		}
+	}
+
 	pi := livekit.ParticipantInfo{}
 	if err := proto.Unmarshal([]byte(data), &pi); err != nil {
 		return nil, err
@@ -195,13 +396,27 @@ func (s *RedisStore) LoadParticipant(_ context.Context, roomName livekit.RoomNam
 
 func (s *RedisStore) ListParticipants(_ context.Context, roomName livekit.RoomName) ([]*livekit.ParticipantInfo, error) {
 	key := RoomParticipantsPrefix + string(roomName)
-	items, err := s.rc.HVals(s.ctx, key).Result()
+	items, err := s.rc.HGetAll(s.ctx, key).Result()
 	if err == redis.Nil {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
 	}
 
+	if s.conf.ParticipantTTL > 0 && len(items) > 0 {
+		heartbeats, err := s.loadHeartbeats(roomName)
+		if err != nil {
+			return nil, err
+		}
+		stale, err := s.removeStaleParticipants(roomName, heartbeats)
+		if err != nil {
+			return nil, err
+		}
+		for _, identity := range stale {
+			delete(items, string(identity))
+		}
+	}
+
 	participants := make([]*livekit.ParticipantInfo, 0, len(items))
 	for _, item := range items {
 		pi := livekit.ParticipantInfo{}
@@ -216,6 +431,98 @@ func (s *RedisStore) ListParticipants(_ context.Context, roomName livekit.RoomNa
 func (s *RedisStore) DeleteParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
 	key := RoomParticipantsPrefix + string(roomName)
 
+	if s.conf.ParticipantTTL <= 0 {
+		return s.rc.HDel(s.ctx, key, string(identity)).Err()
+	}
+
+	s.lock.Lock()
+	if hosted := s.hosted[roomName]; hosted != nil {
+		delete(hosted, identity)
+		if len(hosted) == 0 {
+			delete(s.hosted, roomName)
+		}
+	}
+	s.lock.Unlock()
+
+	pp := s.rc.Pipeline()
+	pp.HDel(s.ctx, key, string(identity))
+	pp.HDel(s.ctx, RoomParticipantHeartbeatsPrefix+string(roomName), string(identity))
+	_, err := pp.Exec(s.ctx)
+	return err
+}
+
+func (s *RedisStore) BlockParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity, duration time.Duration) error {
+	key := BlockedParticipantPrefix + string(roomName) + ":" + string(identity)
+
+	return s.rc.Set(s.ctx, key, time.Now().Add(duration).Unix(), duration).Err()
+}
+
+func (s *RedisStore) UnblockParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
+	key := BlockedParticipantPrefix + string(roomName) + ":" + string(identity)
+
+	return s.rc.Del(s.ctx, key).Err()
+}
+
+func (s *RedisStore) IsParticipantBlocked(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (bool, error) {
+	key := BlockedParticipantPrefix + string(roomName) + ":" + string(identity)
+
+	n, err := s.rc.Exists(s.ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *RedisStore) StorePendingParticipant(_ context.Context, roomName livekit.RoomName, pending *PendingParticipant) error {
+	key := RoomPendingParticipantsPrefix + string(roomName)
+
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+
+	return s.rc.HSet(s.ctx, key, string(pending.Identity), data).Err()
+}
+
+func (s *RedisStore) LoadPendingParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (*PendingParticipant, error) {
+	key := RoomPendingParticipantsPrefix + string(roomName)
+	data, err := s.rc.HGet(s.ctx, key, string(identity)).Result()
+	if err == redis.Nil {
+		return nil, ErrParticipantNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	pending := &PendingParticipant{}
+	if err = json.Unmarshal([]byte(data), pending); err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+func (s *RedisStore) ListPendingParticipants(_ context.Context, roomName livekit.RoomName) ([]*PendingParticipant, error) {
+	key := RoomPendingParticipantsPrefix + string(roomName)
+	items, err := s.rc.HVals(s.ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	pending := make([]*PendingParticipant, 0, len(items))
+	for _, item := range items {
+		p := &PendingParticipant{}
+		if err = json.Unmarshal([]byte(item), p); err != nil {
+			return nil, err
+		}
+		pending = append(pending, p)
+	}
+	return pending, nil
+}
+
+func (s *RedisStore) DeletePendingParticipant(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
+	key := RoomPendingParticipantsPrefix + string(roomName)
+
 	return s.rc.HDel(s.ctx, key, string(identity)).Err()
 }
 
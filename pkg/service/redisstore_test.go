@@ -2,20 +2,25 @@ package service_test
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/livekit/protocol/livekit"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/service"
 )
 
 func TestParticipantPersistence(t *testing.T) {
 	ctx := context.Background()
-	rs := service.NewRedisStore(redisClient())
+	rs := service.NewRedisStore(redisClient(), "", config.RedisStorageConfig{})
 
 	roomName := livekit.RoomName("room1")
 	_ = rs.DeleteRoom(ctx, roomName)
@@ -62,7 +67,7 @@ func TestParticipantPersistence(t *testing.T) {
 
 func TestRoomLock(t *testing.T) {
 	ctx := context.Background()
-	rs := service.NewRedisStore(redisClient())
+	rs := service.NewRedisStore(redisClient(), "", config.RedisStorageConfig{})
 	lockInterval := 5 * time.Millisecond
 	roomName := livekit.RoomName("myroom")
 
@@ -109,3 +114,169 @@ func TestRoomLock(t *testing.T) {
 		_ = rs.UnlockRoom(ctx, roomName, token2)
 	})
 }
+
+func TestRedisStoreCluster(t *testing.T) {
+	ctx := context.Background()
+	rc := redisClusterClient(t)
+	rs := service.NewRedisStore(rc, "", config.RedisStorageConfig{})
+
+	roomName := livekit.RoomName("cluster_room")
+	_ = rs.DeleteRoom(ctx, roomName)
+
+	require.NoError(t, rs.StoreRoom(ctx, &livekit.Room{Sid: "RM_cluster", Name: string(roomName)}))
+	require.NoError(t, rs.StoreParticipant(ctx, roomName, &livekit.ParticipantInfo{Sid: "PA_cluster", Identity: "cluster"}))
+	participants, err := rs.ListParticipants(ctx, roomName)
+	require.NoError(t, err)
+	require.Len(t, participants, 1)
+
+	info := &livekit.EgressInfo{EgressId: "EG_cluster", RoomId: "RM_cluster"}
+	require.NoError(t, rs.StoreEgress(ctx, info))
+	infos, err := rs.ListEgress(ctx, "RM_cluster")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	require.NoError(t, rs.DeleteEgress(ctx, info))
+
+	// deleting the room removes keys spread across multiple slots
+	require.NoError(t, rs.DeleteRoom(ctx, roomName))
+	_, err = rs.LoadRoom(ctx, roomName)
+	require.Equal(t, service.ErrRoomNotFound, err)
+	participants, err = rs.ListParticipants(ctx, roomName)
+	require.NoError(t, err)
+	require.Len(t, participants, 0)
+}
+
+func TestUniversalMessageBusCluster(t *testing.T) {
+	ctx := context.Background()
+	rc := redisClusterClient(t)
+	bus := service.NewUniversalMessageBus(rc)
+
+	sub, err := bus.Subscribe(ctx, "cluster_channel")
+	require.NoError(t, err)
+	defer sub.Close()
+	// allow subscription to be established
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, bus.Publish(ctx, "cluster_channel", &livekit.Room{Name: "cluster_room"}))
+	select {
+	case msg := <-sub.Channel():
+		room := &livekit.Room{}
+		require.NoError(t, proto.Unmarshal(sub.Payload(msg), room))
+		require.Equal(t, "cluster_room", room.Name)
+	case <-time.After(time.Second):
+		t.Fatal("did not receive message")
+	}
+}
+
+// registerNode adds a node that has just reported its stats
+func registerNode(t *testing.T, rc redis.UniversalClient, nodeID livekit.NodeID) {
+	data, err := proto.Marshal(&livekit.Node{Id: string(nodeID), Stats: &livekit.NodeStats{UpdatedAt: time.Now().Unix()}})
+	require.NoError(t, err)
+	require.NoError(t, rc.HSet(context.Background(), routing.NodesKey, string(nodeID), data).Err())
+	t.Cleanup(func() {
+		rc.HDel(context.Background(), routing.NodesKey, string(nodeID))
+	})
+}
+
+func participantIdentities(t *testing.T, rs *service.RedisStore, roomName livekit.RoomName) []string {
+	participants, err := rs.ListParticipants(context.Background(), roomName)
+	require.NoError(t, err)
+	var identities []string
+	for _, p := range participants {
+		identities = append(identities, p.Identity)
+	}
+	sort.Strings(identities)
+	return identities
+}
+
+func TestRedisStoreCrashedNode(t *testing.T) {
+	ctx := context.Background()
+	rc := redisClient()
+	conf := config.RedisStorageConfig{ParticipantTTL: config.Duration(time.Second)}
+
+	live := service.NewRedisStore(rc, "ND_live", conf)
+	defer live.Stop()
+	// the live node reports its stats periodically
+	reportStats := func() {
+		registerNode(t, rc, "ND_live")
+	}
+
+	// starts a node hosting a participant in the room, then crashes it
+	crash := func(nodeID livekit.NodeID, roomName livekit.RoomName, identity livekit.ParticipantIdentity, unregister bool) {
+		crashed := service.NewRedisStore(rc, nodeID, conf)
+		registerNode(t, rc, nodeID)
+		require.NoError(t, crashed.StoreParticipant(ctx, roomName, &livekit.ParticipantInfo{Identity: string(identity)}))
+		crashed.Stop()
+		if unregister {
+			require.NoError(t, rc.HDel(ctx, routing.NodesKey, string(nodeID)).Err())
+		}
+	}
+
+	t.Run("participants of unregistered nodes are removed on read", func(t *testing.T) {
+		reportStats()
+		roomName := livekit.RoomName("crashed_room1")
+		_ = live.DeleteRoom(ctx, roomName)
+		defer live.DeleteRoom(ctx, roomName)
+		require.NoError(t, live.StoreRoom(ctx, &livekit.Room{Name: string(roomName)}))
+		require.NoError(t, live.StoreParticipant(ctx, roomName, &livekit.ParticipantInfo{Identity: "alive"}))
+		crash("ND_crashed1", roomName, "ghost", true)
+
+		_, err := live.LoadParticipant(ctx, roomName, "ghost")
+		require.Equal(t, service.ErrParticipantNotFound, err)
+		require.Equal(t, []string{"alive"}, participantIdentities(t, live, roomName))
+	})
+
+	t.Run("participants that aren't refreshed expire", func(t *testing.T) {
+		reportStats()
+		roomName := livekit.RoomName("crashed_room2")
+		_ = live.DeleteRoom(ctx, roomName)
+		defer live.DeleteRoom(ctx, roomName)
+		require.NoError(t, live.StoreRoom(ctx, &livekit.Room{Name: string(roomName)}))
+		require.NoError(t, live.StoreParticipant(ctx, roomName, &livekit.ParticipantInfo{Identity: "alive"}))
+		// still registered, but no longer refreshing its participants
+		crash("ND_crashed2", roomName, "ghost", false)
+		require.Equal(t, []string{"alive", "ghost"}, participantIdentities(t, live, roomName))
+
+		// the live node keeps refreshing its participant
+		time.Sleep(1500 * time.Millisecond)
+		require.Equal(t, []string{"alive"}, participantIdentities(t, live, roomName))
+	})
+
+	t.Run("rooms emptied by cleanup close after their empty timeout", func(t *testing.T) {
+		reportStats()
+		roomName := livekit.RoomName("crashed_room3")
+		_ = live.DeleteRoom(ctx, roomName)
+		defer live.DeleteRoom(ctx, roomName)
+		require.NoError(t, live.StoreRoom(ctx, &livekit.Room{Name: string(roomName), EmptyTimeout: 1, NumParticipants: 1}))
+		require.NoError(t, rc.HSet(ctx, routing.NodeRoomKey, string(roomName), "ND_crashed3").Err())
+		crash("ND_crashed3", roomName, "ghost", true)
+
+		require.NoError(t, live.Cleanup(ctx))
+		require.Empty(t, participantIdentities(t, live, roomName))
+		room, err := live.LoadRoom(ctx, roomName)
+		require.NoError(t, err)
+		require.Zero(t, room.NumParticipants)
+
+		time.Sleep(1100 * time.Millisecond)
+		require.NoError(t, live.Cleanup(ctx))
+		_, err = live.LoadRoom(ctx, roomName)
+		require.Equal(t, service.ErrRoomNotFound, err)
+		require.Zero(t, rc.HExists(ctx, routing.NodeRoomKey, string(roomName)).Val())
+	})
+
+	t.Run("rooms on live nodes are left to them", func(t *testing.T) {
+		reportStats()
+		roomName := livekit.RoomName("crashed_room4")
+		_ = live.DeleteRoom(ctx, roomName)
+		defer live.DeleteRoom(ctx, roomName)
+		require.NoError(t, live.StoreRoom(ctx, &livekit.Room{Name: string(roomName), EmptyTimeout: 1}))
+		require.NoError(t, rc.HSet(ctx, routing.NodeRoomKey, string(roomName), "ND_live").Err())
+		defer rc.HDel(ctx, routing.NodeRoomKey, string(roomName))
+		crash("ND_crashed4", roomName, "ghost", true)
+
+		require.NoError(t, live.Cleanup(ctx))
+		time.Sleep(1100 * time.Millisecond)
+		require.NoError(t, live.Cleanup(ctx))
+		_, err := live.LoadRoom(ctx, roomName)
+		require.NoError(t, err)
+	})
+}
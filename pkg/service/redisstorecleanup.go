@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/routing/selector"
+	"github.com/livekit/livekit-server/pkg/rtc"
+)
+
+// participantHeartbeat is the node hosting a participant and when it last refreshed it
+type participantHeartbeat struct {
+	// empty for participants stored before heartbeats, which expire unless a node refreshes them
+	NodeID livekit.NodeID `json:"node_id,omitempty"`
+	// unix time in milliseconds
+	LastSeen int64 `json:"last_seen"`
+}
+
+func (s *RedisStore) newHeartbeat() ([]byte, error) {
+	return json.Marshal(&participantHeartbeat{NodeID: s.nodeID, LastSeen: time.Now().UnixMilli()})
+}
+
+// loadHeartbeats returns the heartbeats of the identities, or of all participants in the room when none are given
+func (s *RedisStore) loadHeartbeats(roomName livekit.RoomName, identities ...livekit.ParticipantIdentity) (map[livekit.ParticipantIdentity]*participantHeartbeat, error) {
+	key := RoomParticipantHeartbeatsPrefix + string(roomName)
+	items := make(map[string]string)
+	if len(identities) == 0 {
+		var err error
+		if items, err = s.rc.HGetAll(s.ctx, key).Result(); err != nil && err != redis.Nil {
+			return nil, err
+		}
+	} else {
+		values, err := s.rc.HMGet(s.ctx, key, identitiesAsStrings(identities)...).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if item, ok := v.(string); ok {
+				items[string(identities[i])] = item
+			}
+		}
+	}
+
+	heartbeats := make(map[livekit.ParticipantIdentity]*participantHeartbeat, len(items))
+	for identity, item := range items {
+		hb := &participantHeartbeat{}
+		if err := json.Unmarshal([]byte(item), hb); err != nil {
+			return nil, err
+		}
+		heartbeats[livekit.ParticipantIdentity(identity)] = hb
+	}
+	return heartbeats, nil
+}
+
+// liveNodes returns whether each registered node updated its stats within the participant TTL, and at least as
+// recently as nodes are available for new rooms
+func (s *RedisStore) liveNodes() (map[livekit.NodeID]bool, error) {
+	items, err := s.rc.HGetAll(s.ctx, routing.NodesKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	window := s.conf.ParticipantTTL.Duration()
+	if window < selector.AvailableSeconds*time.Second {
+		window = selector.AvailableSeconds * time.Second
+	}
+	now := time.Now().Unix()
+	nodes := make(map[livekit.NodeID]bool, len(items))
+	for id, item := range items {
+		n := livekit.Node{}
+		if err = proto.Unmarshal([]byte(item), &n); err != nil {
+			return nil, err
+		}
+		// nodes that haven't reported stats yet are starting
+		updatedAt := n.GetStats().GetUpdatedAt()
+		nodes[livekit.NodeID(id)] = updatedAt == 0 || time.Duration(now-updatedAt)*time.Second < window
+	}
+	return nodes, nil
+}
+
+func (s *RedisStore) isStale(hb *participantHeartbeat, nodes map[livekit.NodeID]bool, now time.Time) bool {
+	if now.Sub(time.UnixMilli(hb.LastSeen)) > s.conf.ParticipantTTL.Duration() {
+		return true
+	}
+	return hb.NodeID != "" && !nodes[hb.NodeID]
+}
+
+// removeStaleParticipants deletes the participants whose heartbeat has expired or whose node is gone, returning them
+func (s *RedisStore) removeStaleParticipants(roomName livekit.RoomName, heartbeats map[livekit.ParticipantIdentity]*participantHeartbeat) ([]livekit.ParticipantIdentity, error) {
+	if len(heartbeats) == 0 {
+		return nil, nil
+	}
+	nodes, err := s.liveNodes()
+	if err != nil {
+		return nil, err
+	}
+	return s.removeStaleParticipantsOfNodes(roomName, heartbeats, nodes)
+}
+
+func (s *RedisStore) removeStaleParticipantsOfNodes(roomName livekit.RoomName, heartbeats map[livekit.ParticipantIdentity]*participantHeartbeat, nodes map[livekit.NodeID]bool) ([]livekit.ParticipantIdentity, error) {
+	now := time.Now()
+	var stale []livekit.ParticipantIdentity
+	for identity, hb := range heartbeats {
+		if s.isStale(hb, nodes, now) {
+			stale = append(stale, identity)
+		}
+	}
+	if len(stale) == 0 {
+		return nil, nil
+	}
+
+	fields := identitiesAsStrings(stale)
+	pp := s.rc.Pipeline()
+	pp.HDel(s.ctx, RoomParticipantsPrefix+string(roomName), fields...)
+	pp.HDel(s.ctx, RoomParticipantHeartbeatsPrefix+string(roomName), fields...)
+	if _, err := pp.Exec(s.ctx); err != nil {
+		return nil, err
+	}
+	logger.Infow("removed participants of a node that's gone", "room", roomName, "participants", fields)
+	return stale, nil
+}
+
+func (s *RedisStore) heartbeatWorker() {
+	ticker := time.NewTicker(s.conf.ParticipantTTL.Duration() / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.refreshParticipants(); err != nil {
+				logger.Errorw("could not refresh participants", err)
+			}
+		}
+	}
+}
+
+// refreshParticipants renews the heartbeats of participants hosted by this node. Their records are written again
+// too, in case they were removed while this node couldn't reach redis
+func (s *RedisStore) refreshParticipants() error {
+	heartbeat, err := s.newHeartbeat()
+	if err != nil {
+		return err
+	}
+
+	// held while writing, so that participants deleted meanwhile aren't written back
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.hosted) == 0 {
+		return nil
+	}
+
+	pp := s.rc.Pipeline()
+	for roomName, participants := range s.hosted {
+		values := make([]interface{}, 0, 2*len(participants))
+		heartbeats := make([]interface{}, 0, 2*len(participants))
+		for identity, data := range participants {
+			values = append(values, string(identity), data)
+			heartbeats = append(heartbeats, string(identity), heartbeat)
+		}
+		pp.HSet(s.ctx, RoomParticipantsPrefix+string(roomName), values...)
+		pp.HSet(s.ctx, RoomParticipantHeartbeatsPrefix+string(roomName), heartbeats...)
+	}
+	_, err = pp.Exec(s.ctx)
+	return err
+}
+
+func (s *RedisStore) cleanupWorker() {
+	ticker := time.NewTicker(s.conf.CleanupInterval.Duration())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.Cleanup(context.Background()); err != nil {
+				logger.Errorw("could not clean up redis store", err)
+			}
+		}
+	}
+}
+
+// Cleanup removes participants of nodes that are gone from every room. Rooms whose node is gone are closed once
+// they've been empty for their empty timeout
+func (s *RedisStore) Cleanup(ctx context.Context) error {
+	rooms, err := s.rc.HGetAll(s.ctx, RoomsKey).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	emptied, err := s.rc.HGetAll(s.ctx, RoomEmptiedKey).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	roomNodes, err := s.rc.HGetAll(s.ctx, routing.NodeRoomKey).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	nodes, err := s.liveNodes()
+	if err != nil {
+		return err
+	}
+
+	for name, data := range rooms {
+		room := &livekit.Room{}
+		if err = proto.Unmarshal([]byte(data), room); err != nil {
+			return err
+		}
+		nodeID := livekit.NodeID(roomNodes[name])
+		if err = s.cleanupRoom(ctx, room, nodes, nodeID, emptied[name]); err != nil {
+			logger.Errorw("could not clean up room", err, "room", name)
+		}
+	}
+
+	// rooms deleted since they were found empty
+	for name := range emptied {
+		if _, ok := rooms[name]; !ok {
+			if err = s.rc.HDel(s.ctx, RoomEmptiedKey, name).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *RedisStore) cleanupRoom(ctx context.Context, room *livekit.Room, nodes map[livekit.NodeID]bool, nodeID livekit.NodeID, emptiedAt string) error {
+	roomName := livekit.RoomName(room.Name)
+	items, err := s.rc.HGetAll(s.ctx, RoomParticipantsPrefix+room.Name).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	heartbeats, err := s.loadHeartbeats(roomName)
+	if err != nil {
+		return err
+	}
+
+	// participants stored before heartbeats expire unless their node refreshes them
+	now := time.Now()
+	for identity := range items {
+		if heartbeats[livekit.ParticipantIdentity(identity)] == nil {
+			data, err := json.Marshal(&participantHeartbeat{LastSeen: now.UnixMilli()})
+			if err != nil {
+				return err
+			}
+			if err = s.rc.HSetNX(s.ctx, RoomParticipantHeartbeatsPrefix+room.Name, identity, data).Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	stale, err := s.removeStaleParticipantsOfNodes(roomName, heartbeats, nodes)
+	if err != nil {
+		return err
+	}
+	for _, identity := range stale {
+		delete(items, string(identity))
+	}
+
+	if nodeID != "" && nodes[nodeID] {
+		// the node hosting the room closes it when it's empty
+		if emptiedAt != "" {
+			return s.rc.HDel(s.ctx, RoomEmptiedKey, room.Name).Err()
+		}
+		return nil
+	}
+
+	var numParticipants uint32
+	for _, item := range items {
+		pi := livekit.ParticipantInfo{}
+		if err = proto.Unmarshal([]byte(item), &pi); err != nil {
+			return err
+		}
+		if !pi.Hidden {
+			numParticipants++
+		}
+	}
+	if room.NumParticipants != numParticipants {
+		room.NumParticipants = numParticipants
+		if err = s.StoreRoom(ctx, room); err != nil {
+			return err
+		}
+	}
+
+	if numParticipants > 0 {
+		if emptiedAt != "" {
+			return s.rc.HDel(s.ctx, RoomEmptiedKey, room.Name).Err()
+		}
+		return nil
+	}
+	if emptiedAt == "" {
+		return s.rc.HSet(s.ctx, RoomEmptiedKey, room.Name, now.Unix()).Err()
+	}
+
+	at, err := strconv.ParseInt(emptiedAt, 10, 64)
+	if err != nil {
+		return err
+	}
+	timeout := room.EmptyTimeout
+	if timeout == 0 {
+		timeout = rtc.DefaultEmptyTimeout
+	}
+	if now.Unix()-at < int64(timeout) {
+		return nil
+	}
+
+	// the room may be moved to another node meanwhile
+	token, err := s.LockRoom(ctx, roomName, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = s.UnlockRoom(ctx, roomName, token)
+	}()
+	current, err := s.rc.HGet(s.ctx, routing.NodeRoomKey, room.Name).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if current != "" && nodes[livekit.NodeID(current)] {
+		return nil
+	}
+
+	logger.Infow("closing room left empty by a node that's gone", "room", roomName, "nodeID", nodeID)
+	if err = s.DeleteRoom(ctx, roomName); err != nil {
+		return err
+	}
+	return s.rc.HDel(s.ctx, routing.NodeRoomKey, room.Name).Err()
+}
+
+func identitiesAsStrings(identities []livekit.ParticipantIdentity) []string {
+	asString := make([]string, 0, len(identities))
+	for _, identity := range identities {
+		asString = append(asString, string(identity))
+	}
+	return asString
+}
@@ -2,15 +2,19 @@ package service
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"github.com/livekit/protocol/utils"
+	"github.com/pkg/errors"
 
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/routing/selector"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
 type StandardRoomAllocator struct {
@@ -18,9 +22,10 @@ type StandardRoomAllocator struct {
 	router    routing.Router
 	selector  selector.NodeSelector
 	roomStore ObjectStore
+	telemetry telemetry.TelemetryService
 }
 
-func NewRoomAllocator(conf *config.Config, router routing.Router, rs ObjectStore) (RoomAllocator, error) {
+func NewRoomAllocator(conf *config.Config, router routing.Router, rs ObjectStore, telemetry telemetry.TelemetryService) (RoomAllocator, error) {
 	ns, err := selector.CreateNodeSelector(conf)
 	if err != nil {
 		return nil, err
@@ -31,6 +36,7 @@ func NewRoomAllocator(conf *config.Config, router routing.Router, rs ObjectStore
 		router:    router,
 		selector:  ns,
 		roomStore: rs,
+		telemetry: telemetry,
 	}, nil
 }
 
@@ -47,7 +53,8 @@ func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.Cre
 
 	// find existing room and update it
 	rm, err := r.roomStore.LoadRoom(ctx, livekit.RoomName(req.Name))
-	if err == ErrRoomNotFound {
+	isNew := err == ErrRoomNotFound
+	if isNew {
 		rm = &livekit.Room{
 			Sid:          utils.NewGuid(utils.RoomPrefix),
 			Name:         req.Name,
@@ -68,15 +75,39 @@ func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.Cre
 	if req.Metadata != "" {
 		rm.Metadata = req.Metadata
 	}
+	settings := GetRoomSettings(ctx)
+	if codecs := settings.EnabledCodecs; len(codecs) > 0 {
+		rm.EnabledCodecs, err = filterEnabledCodecs(codecs, r.config.Room.EnabledCodecs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	maxDuration := settings.MaxDuration
+	if global := r.config.Room.MaxDuration.Duration(); global > 0 && maxDuration > global {
+		return nil, ErrMaxDurationExceeded
+	}
+	candidateTypes := settings.ICECandidateTypes
+	if err := config.ValidateICECandidateTypes(candidateTypes); err != nil {
+		return nil, errors.Wrap(ErrInvalidCandidateType, err.Error())
+	}
+	if config.IsRelayOnly(candidateTypes) && !r.config.HasTURNServer() {
+		return nil, config.ErrRelayWithoutTURN
+	}
 	if err := r.roomStore.StoreRoom(ctx, rm); err != nil {
 		return nil, err
 	}
+	if err := r.updateRoomInternal(ctx, livekit.RoomName(rm.Name), settings, isNew); err != nil {
+		return nil, err
+	}
 
 	// check if room already assigned
 	existing, err := r.router.GetNodeForRoom(ctx, livekit.RoomName(rm.Name))
-	if err != routing.ErrNotFound && err != nil {
+	if err != routing.ErrNotFound && err != routing.ErrRoomNodeGone && err != nil {
 		return nil, err
 	}
+	// the room was assigned to a node that is dead. Rooms of nodes that are alive but unavailable move to another
+	// node, keeping their participants
+	reassigned := !isNew && err == routing.ErrRoomNodeGone
 
 	// if already assigned and still available, keep it on that node
 	if err == nil && selector.IsAvailable(existing) {
@@ -96,7 +127,18 @@ func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.Cre
 			return nil, err
 		}
 
-		node, err := r.selector.SelectNode(nodes)
+		var node *livekit.Node
+		switch s := r.selector.(type) {
+		case selector.LocationAwareSelector:
+			node, err = s.SelectNodeNear(nodes, GetClientLocation(ctx))
+		case selector.StatsAwareSelector:
+			var reports map[string]*prometheus.NodeStatsReport
+			if reports, err = r.router.GetNodeStatsReports(); err == nil {
+				node, err = s.SelectNodeWithReports(nodes, reports)
+			}
+		default:
+			node, err = r.selector.SelectNode(nodes)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -104,17 +146,90 @@ func (r *StandardRoomAllocator) CreateRoom(ctx context.Context, req *livekit.Cre
 		nodeID = livekit.NodeID(node.Id)
 	}
 
+	if reassigned {
+		if err := r.migrateRoom(ctx, rm); err != nil {
+			return nil, err
+		}
+	}
+
 	logger.Debugw("selected node for room", "room", rm.Name, "roomID", rm.Sid, "nodeID", nodeID)
 	err = r.router.SetNodeForRoom(ctx, livekit.RoomName(rm.Name), nodeID)
 	if err != nil {
 		return nil, err
 	}
 
+	if reassigned {
+		logger.Infow("reassigned room", "room", rm.Name, "roomID", rm.Sid, "nodeID", nodeID)
+		r.telemetry.RoomReassigned(ctx, rm)
+	}
 	return rm, nil
 }
 
+// migrateRoom prepares the record of a room whose node is gone for its new node. Participants of the old node
+// were disconnected with it, they're removed so that they can rejoin, and so that late joiners aren't counted
+// against them
+func (r *StandardRoomAllocator) migrateRoom(ctx context.Context, rm *livekit.Room) error {
+	participants, err := r.roomStore.ListParticipants(ctx, livekit.RoomName(rm.Name))
+	if err != nil {
+		return err
+	}
+	for _, p := range participants {
+		if err := r.roomStore.DeleteParticipant(ctx, livekit.RoomName(rm.Name), livekit.ParticipantIdentity(p.Identity)); err != nil {
+			return err
+		}
+	}
+	rm.NumParticipants = 0
+	return r.roomStore.StoreRoom(ctx, rm)
+}
+
+// updateRoomInternal stores settings that livekit.Room cannot carry. The creator's API key is only
+// recorded for new rooms
+func (r *StandardRoomAllocator) updateRoomInternal(ctx context.Context, roomName livekit.RoomName, settings *RoomSettings, isNew bool) error {
+	apiKey := GetAPIKey(ctx)
+	if settings.MaxDuration <= 0 && len(settings.ICECandidateTypes) == 0 && settings.MaxForwardedAudioTracks == nil &&
+		settings.Locked == nil && settings.RequireApproval == nil && settings.BlockRejoinDuration == nil &&
+		(!isNew || apiKey == "") {
+		return nil
+	}
+
+	internal := &RoomInternal{}
+	if isNew {
+		internal.APIKey = apiKey
+	} else {
+		existing, err := r.roomStore.LoadRoomInternal(ctx, roomName)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			*internal = *existing
+		}
+	}
+	if settings.MaxDuration > 0 {
+		internal.MaxDuration = uint32(settings.MaxDuration.Seconds())
+	}
+	if len(settings.ICECandidateTypes) > 0 {
+		internal.ICECandidateTypes = settings.ICECandidateTypes
+	}
+	if settings.MaxForwardedAudioTracks != nil {
+		maxForwardedAudioTracks := *settings.MaxForwardedAudioTracks
+		internal.MaxForwardedAudioTracks = &maxForwardedAudioTracks
+	}
+	if settings.Locked != nil {
+		internal.Locked = *settings.Locked
+	}
+	if settings.RequireApproval != nil {
+		internal.RequireApproval = *settings.RequireApproval
+	}
+	if settings.BlockRejoinDuration != nil {
+		// rounded up, so that a short block isn't dropped
+		blockRejoinDuration := uint32((*settings.BlockRejoinDuration + time.Second - 1) / time.Second)
+		internal.BlockRejoinDuration = &blockRejoinDuration
+	}
+	return r.roomStore.StoreRoomInternal(ctx, roomName, internal)
+}
+
 func applyDefaultRoomConfig(room *livekit.Room, conf *config.RoomConfig) {
-	room.EmptyTimeout = conf.EmptyTimeout
+	room.EmptyTimeout = uint32(conf.EmptyTimeout.Duration().Seconds())
 	room.MaxParticipants = conf.MaxParticipants
 	for _, codec := range conf.EnabledCodecs {
 		room.EnabledCodecs = append(room.EnabledCodecs, &livekit.Codec{
@@ -123,3 +238,34 @@ func applyDefaultRoomConfig(room *livekit.Room, conf *config.RoomConfig) {
 		})
 	}
 }
+
+// filterEnabledCodecs intersects a room's codec allow-list with the codecs enabled on the server.
+// requesting a codec that isn't enabled globally is an error
+func filterEnabledCodecs(requested []*livekit.Codec, enabled []config.CodecSpec) ([]*livekit.Codec, error) {
+	var codecs []*livekit.Codec
+	for _, req := range requested {
+		found := false
+		for _, codec := range enabled {
+			if !strings.EqualFold(req.Mime, codec.Mime) {
+				continue
+			}
+			fmtpLine := codec.FmtpLine
+			if req.FmtpLine != "" {
+				if codec.FmtpLine != "" && !strings.EqualFold(req.FmtpLine, codec.FmtpLine) {
+					continue
+				}
+				fmtpLine = req.FmtpLine
+			}
+			codecs = append(codecs, &livekit.Codec{
+				Mime:     codec.Mime,
+				FmtpLine: fmtpLine,
+			})
+			found = true
+			break
+		}
+		if !found {
+			return nil, errors.Wrapf(ErrCodecNotEnabled, "codec %s", req.Mime)
+		}
+	}
+	return codecs, nil
+}
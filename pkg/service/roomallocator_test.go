@@ -3,6 +3,7 @@ package service_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/stretchr/testify/require"
@@ -10,8 +11,10 @@ import (
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing"
 	"github.com/livekit/livekit-server/pkg/routing/routingfakes"
+	"github.com/livekit/livekit-server/pkg/routing/selector"
 	"github.com/livekit/livekit-server/pkg/service"
 	"github.com/livekit/livekit-server/pkg/service/servicefakes"
+	"github.com/livekit/livekit-server/pkg/telemetry/telemetryfakes"
 )
 
 func TestCreateRoom(t *testing.T) {
@@ -26,7 +29,7 @@ func TestCreateRoom(t *testing.T) {
 
 		room, err := ra.CreateRoom(context.Background(), &livekit.CreateRoomRequest{Name: "myroom"})
 		require.NoError(t, err)
-		require.Equal(t, conf.Room.EmptyTimeout, room.EmptyTimeout)
+		require.Equal(t, uint32(conf.Room.EmptyTimeout.Duration().Seconds()), room.EmptyTimeout)
 		require.NotEmpty(t, room.EnabledCodecs)
 	})
 
@@ -63,6 +66,97 @@ func TestCreateRoom(t *testing.T) {
 	})
 }
 
+func TestCreateRoomNearClient(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	conf.Region = "us-west"
+	conf.NodeSelector.Kind = "regionaware"
+	conf.NodeSelector.SysloadLimit = 0.9
+	conf.NodeSelector.Regions = []config.RegionConfig{
+		{Name: "us-west", Lat: 37.64, Lon: -120.88},
+		{Name: "eu-west", Lat: 53.35, Lon: -6.26},
+	}
+
+	nodes := make([]*livekit.Node, 0, 2)
+	for _, region := range []string{"us-west", "eu-west"} {
+		node, err := routing.NewLocalNode(conf)
+		require.NoError(t, err)
+		node.Region = region
+		node.Stats.UpdatedAt = time.Now().Unix()
+		nodes = append(nodes, node)
+	}
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadRoomReturns(nil, service.ErrRoomNotFound)
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(nil, routing.ErrNotFound)
+	router.ListNodesReturns(nodes, nil)
+	ra, err := service.NewRoomAllocator(conf, router, store, &telemetryfakes.FakeTelemetryService{})
+	require.NoError(t, err)
+
+	// a client in Paris gets the node in eu-west rather than the one in this node's region
+	ctx := service.WithClientLocation(context.Background(), &selector.Location{Lat: 48.86, Lon: 2.35})
+	_, err = ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "near-client"})
+	require.NoError(t, err)
+	require.Equal(t, 1, router.SetNodeForRoomCallCount())
+	_, _, nodeID := router.SetNodeForRoomArgsForCall(0)
+	require.Equal(t, livekit.NodeID(nodes[1].Id), nodeID)
+}
+
+func TestCreateRoomReassignsFromGoneNode(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+	node.Stats.UpdatedAt = time.Now().Unix()
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadRoomReturns(&livekit.Room{Sid: "RM_failover", Name: "failover", NumParticipants: 2}, nil)
+	store.ListParticipantsReturns([]*livekit.ParticipantInfo{{Identity: "p1"}, {Identity: "p2"}}, nil)
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(nil, routing.ErrRoomNodeGone)
+	router.ListNodesReturns([]*livekit.Node{node}, nil)
+	telemetry := &telemetryfakes.FakeTelemetryService{}
+	ra, err := service.NewRoomAllocator(conf, router, store, telemetry)
+	require.NoError(t, err)
+
+	rm, err := ra.CreateRoom(context.Background(), &livekit.CreateRoomRequest{Name: "failover"})
+	require.NoError(t, err)
+	require.Equal(t, "RM_failover", rm.Sid)
+	require.Zero(t, rm.NumParticipants)
+
+	// participants of the old node are removed, so that late joiners succeed
+	require.Equal(t, 2, store.DeleteParticipantCallCount())
+	_, _, identity := store.DeleteParticipantArgsForCall(1)
+	require.Equal(t, livekit.ParticipantIdentity("p2"), identity)
+
+	require.Equal(t, 1, router.SetNodeForRoomCallCount())
+	_, _, nodeID := router.SetNodeForRoomArgsForCall(0)
+	require.Equal(t, livekit.NodeID(node.Id), nodeID)
+	require.Equal(t, 1, telemetry.RoomReassignedCallCount())
+	_, reassigned := telemetry.RoomReassignedArgsForCall(0)
+	require.Equal(t, "RM_failover", reassigned.Sid)
+
+	// rooms of nodes that are alive but unavailable move without losing their participants
+	stale, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+	stale.Id = "ND_stale"
+	stale.Stats.UpdatedAt = time.Now().Add(-time.Minute).Unix()
+	router.GetNodeForRoomReturns(stale, nil)
+	_, err = ra.CreateRoom(context.Background(), &livekit.CreateRoomRequest{Name: "failover"})
+	require.NoError(t, err)
+	require.Equal(t, 2, router.SetNodeForRoomCallCount())
+	require.Equal(t, 2, store.DeleteParticipantCallCount())
+	require.Equal(t, 1, telemetry.RoomReassignedCallCount())
+
+	// new rooms aren't reassigned
+	store.LoadRoomReturns(nil, service.ErrRoomNotFound)
+	router.GetNodeForRoomReturns(nil, routing.ErrNotFound)
+	_, err = ra.CreateRoom(context.Background(), &livekit.CreateRoomRequest{Name: "new"})
+	require.NoError(t, err)
+	require.Equal(t, 1, telemetry.RoomReassignedCallCount())
+}
+
 func newTestRoomAllocator(t *testing.T, conf *config.Config, node *livekit.Node) (service.RoomAllocator, *config.Config) {
 	store := &servicefakes.FakeObjectStore{}
 	store.LoadRoomReturns(nil, service.ErrRoomNotFound)
@@ -70,7 +164,216 @@ func newTestRoomAllocator(t *testing.T, conf *config.Config, node *livekit.Node)
 
 	router.GetNodeForRoomReturns(node, nil)
 
-	ra, err := service.NewRoomAllocator(conf, router, store)
+	ra, err := service.NewRoomAllocator(conf, router, store, &telemetryfakes.FakeTelemetryService{})
 	require.NoError(t, err)
 	return ra, conf
 }
+
+func TestCreateRoomWithCodecs(t *testing.T) {
+	newAllocator := func(t *testing.T) service.RoomAllocator {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		node, err := routing.NewLocalNode(conf)
+		require.NoError(t, err)
+		ra, _ := newTestRoomAllocator(t, conf, node)
+		return ra
+	}
+
+	t.Run("room codecs are intersected with enabled codecs", func(t *testing.T) {
+		ra := newAllocator(t)
+		ctx := service.WithRoomSettings(context.Background(), &service.RoomSettings{EnabledCodecs: []*livekit.Codec{
+			{Mime: "video/h264"},
+			{Mime: "audio/opus"},
+		}})
+		room, err := ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "h264room"})
+		require.NoError(t, err)
+		require.Len(t, room.EnabledCodecs, 2)
+		require.Equal(t, "video/H264", room.EnabledCodecs[0].Mime)
+		require.Equal(t, "audio/opus", room.EnabledCodecs[1].Mime)
+	})
+
+	t.Run("codecs not enabled on the server are rejected", func(t *testing.T) {
+		ra := newAllocator(t)
+		ctx := service.WithRoomSettings(context.Background(), &service.RoomSettings{EnabledCodecs: []*livekit.Codec{
+			{Mime: "video/vp9"},
+		}})
+		_, err := ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "vp9room"})
+		require.ErrorIs(t, err, service.ErrCodecNotEnabled)
+	})
+}
+
+func TestCreateRoomWithMaxDuration(t *testing.T) {
+	conf, err := config.NewConfig("room:\n  max_duration: 2h", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadRoomReturns(nil, service.ErrRoomNotFound)
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(node, nil)
+	ra, err := service.NewRoomAllocator(conf, router, store, &telemetryfakes.FakeTelemetryService{})
+	require.NoError(t, err)
+
+	t.Run("override smaller than the global max is stored", func(t *testing.T) {
+		ctx := service.WithRoomSettings(context.Background(), &service.RoomSettings{MaxDuration: time.Hour})
+		_, err := ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "webinar"})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, store.StoreRoomInternalCallCount())
+		_, name, internal := store.StoreRoomInternalArgsForCall(0)
+		require.Equal(t, livekit.RoomName("webinar"), name)
+		require.Equal(t, uint32(3600), internal.MaxDuration)
+	})
+
+	t.Run("override greater than the global max is rejected", func(t *testing.T) {
+		ctx := service.WithRoomSettings(context.Background(), &service.RoomSettings{MaxDuration: 3 * time.Hour})
+		_, err := ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "webinar"})
+		require.ErrorIs(t, err, service.ErrMaxDurationExceeded)
+		require.Equal(t, 1, store.StoreRoomInternalCallCount())
+	})
+}
+
+func TestCreateRoomRecordsAPIKey(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadRoomReturns(nil, service.ErrRoomNotFound)
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(node, nil)
+	ra, err := service.NewRoomAllocator(conf, router, store, &telemetryfakes.FakeTelemetryService{})
+	require.NoError(t, err)
+
+	ctx := service.WithAPIKey(context.Background(), "creator")
+	_, err = ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "myroom"})
+	require.NoError(t, err)
+
+	require.Equal(t, 1, store.StoreRoomInternalCallCount())
+	_, _, internal := store.StoreRoomInternalArgsForCall(0)
+	require.Equal(t, "creator", internal.APIKey)
+}
+
+func TestCreateRoomWithICECandidateTypes(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadRoomReturns(nil, service.ErrRoomNotFound)
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(node, nil)
+	ra, err := service.NewRoomAllocator(conf, router, store, &telemetryfakes.FakeTelemetryService{})
+	require.NoError(t, err)
+
+	t.Run("relay only without a TURN server is rejected", func(t *testing.T) {
+		ctx := service.WithRoomSettings(context.Background(), &service.RoomSettings{ICECandidateTypes: []string{"relay"}})
+		_, err := ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "private"})
+		require.ErrorIs(t, err, config.ErrRelayWithoutTURN)
+		require.Equal(t, 0, store.StoreRoomInternalCallCount())
+	})
+
+	t.Run("unknown types are rejected", func(t *testing.T) {
+		ctx := service.WithRoomSettings(context.Background(), &service.RoomSettings{ICECandidateTypes: []string{"local"}})
+		_, err := ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "private"})
+		require.ErrorIs(t, err, service.ErrInvalidCandidateType)
+	})
+
+	t.Run("override is stored", func(t *testing.T) {
+		conf.TURN.Enabled = true
+		defer func() { conf.TURN.Enabled = false }()
+
+		ctx := service.WithRoomSettings(context.Background(), &service.RoomSettings{ICECandidateTypes: []string{"relay"}})
+		_, err := ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "private"})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, store.StoreRoomInternalCallCount())
+		_, _, internal := store.StoreRoomInternalArgsForCall(0)
+		require.Equal(t, []string{"relay"}, internal.ICECandidateTypes)
+	})
+}
+
+func TestCreateRoomWithMaxForwardedAudioTracks(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadRoomReturns(nil, service.ErrRoomNotFound)
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(node, nil)
+	ra, err := service.NewRoomAllocator(conf, router, store, &telemetryfakes.FakeTelemetryService{})
+	require.NoError(t, err)
+
+	// no limit overrides a limit set on the server
+	maxTracks := 0
+	ctx := service.WithRoomSettings(context.Background(), &service.RoomSettings{MaxForwardedAudioTracks: &maxTracks})
+	_, err = ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "townhall"})
+	require.NoError(t, err)
+
+	require.Equal(t, 1, store.StoreRoomInternalCallCount())
+	_, _, internal := store.StoreRoomInternalArgsForCall(0)
+	require.NotNil(t, internal.MaxForwardedAudioTracks)
+	require.Zero(t, *internal.MaxForwardedAudioTracks)
+}
+
+func TestCreateRoomWithBlockRejoinDuration(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadRoomReturns(nil, service.ErrRoomNotFound)
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(node, nil)
+	ra, err := service.NewRoomAllocator(conf, router, store, &telemetryfakes.FakeTelemetryService{})
+	require.NoError(t, err)
+
+	blockRejoinDuration := 1500 * time.Millisecond
+	ctx := service.WithRoomSettings(context.Background(), &service.RoomSettings{BlockRejoinDuration: &blockRejoinDuration})
+	_, err = ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "classroom"})
+	require.NoError(t, err)
+
+	require.Equal(t, 1, store.StoreRoomInternalCallCount())
+	_, _, internal := store.StoreRoomInternalArgsForCall(0)
+	require.NotNil(t, internal.BlockRejoinDuration)
+	require.Equal(t, uint32(2), *internal.BlockRejoinDuration)
+}
+
+func TestCreateRoomLocked(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadRoomReturns(&livekit.Room{Name: "meeting"}, nil)
+	store.LoadRoomInternalReturns(&service.RoomInternal{MaxDuration: 3600}, nil)
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(node, nil)
+	ra, err := service.NewRoomAllocator(conf, router, store, &telemetryfakes.FakeTelemetryService{})
+	require.NoError(t, err)
+
+	locked := true
+	ctx := service.WithRoomSettings(context.Background(), &service.RoomSettings{Locked: &locked})
+	_, err = ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "meeting"})
+	require.NoError(t, err)
+
+	// locking keeps the settings of the existing room
+	require.Equal(t, 1, store.StoreRoomInternalCallCount())
+	_, _, internal := store.StoreRoomInternalArgsForCall(0)
+	require.True(t, internal.Locked)
+	require.Equal(t, uint32(3600), internal.MaxDuration)
+
+	store.LoadRoomInternalReturns(internal, nil)
+	locked = false
+	_, err = ra.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "meeting"})
+	require.NoError(t, err)
+	_, _, internal = store.StoreRoomInternalArgsForCall(1)
+	require.False(t, internal.Locked)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"github.com/livekit/protocol/utils"
+	"github.com/pion/webrtc/v3"
 
 	"github.com/livekit/livekit-server/pkg/clientconfiguration"
 	"github.com/livekit/livekit-server/pkg/config"
@@ -39,6 +40,8 @@ type RoomManager struct {
 	clientConfManager clientconfiguration.ClientConfigurationManager
 
 	rooms map[livekit.RoomName]*rtc.Room
+	// settings stored alongside each room, such as the API key it was created with
+	roomInternal map[livekit.RoomName]*RoomInternal
 }
 
 func NewLocalRoomManager(
@@ -64,7 +67,8 @@ func NewLocalRoomManager(
 		telemetry:         telemetry,
 		clientConfManager: clientConfManager,
 
-		rooms: make(map[livekit.RoomName]*rtc.Room),
+		rooms:        make(map[livekit.RoomName]*rtc.Room),
+		roomInternal: make(map[livekit.RoomName]*RoomInternal),
 	}
 
 	// hook up to router
@@ -84,6 +88,7 @@ func (r *RoomManager) DeleteRoom(ctx context.Context, roomName livekit.RoomName)
 	logger.Infow("deleting room state", "room", roomName)
 	r.lock.Lock()
 	delete(r.rooms, roomName)
+	delete(r.roomInternal, roomName)
 	r.lock.Unlock()
 
 	var err, err2 error
@@ -153,6 +158,13 @@ func (r *RoomManager) HasParticipants() bool {
 	return false
 }
 
+func (r *RoomManager) RoomCount() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return len(r.rooms)
+}
+
 func (r *RoomManager) Stop() {
 	// disconnect all clients
 	r.lock.RLock()
@@ -237,7 +249,11 @@ func (r *RoomManager) StartSession(ctx context.Context, roomName livekit.RoomNam
 	pv := types.ProtocolVersion(pi.Client.Protocol)
 	rtcConf := *r.rtcConfig
 	rtcConf.SetBufferFactory(room.GetBufferFactory())
+	if candidateTypes := r.iceCandidateTypesForRoom(roomName); candidateTypes != nil {
+		rtcConf.ICECandidateTypes = candidateTypes
+	}
 	sid := livekit.ParticipantID(utils.NewGuid(utils.ParticipantPrefix))
+	clientMeta := &livekit.AnalyticsClientMeta{Region: r.currentNode.Region, Node: r.currentNode.Id}
 	pLogger := rtc.LoggerWithParticipant(room.Logger, pi.Identity, sid)
 	participant, err = rtc.NewParticipant(rtc.ParticipantParams{
 		Identity:                pi.Identity,
@@ -249,12 +265,24 @@ func (r *RoomManager) StartSession(ctx context.Context, roomName livekit.RoomNam
 		ProtocolVersion:         pv,
 		Telemetry:               r.telemetry,
 		PLIThrottleConfig:       r.config.RTC.PLIThrottle,
+		KeyFrameCacheConfig:     r.config.RTC.KeyFrameCache,
+		StreamTrackerConfig:     r.config.RTC.StreamTracker,
+		MaxSpatialLayers:        r.config.RTC.MaxSpatialLayers,
 		CongestionControlConfig: r.config.RTC.CongestionControl,
+		PacerConfig:             r.config.RTC.Pacer,
+		NegotiationConfig:       r.config.RTC.Negotiation,
+		DataChannelConfig:       r.config.RTC.DataChannel,
+		SCTPConfig:              r.config.RTC.SCTP,
 		EnabledCodecs:           room.Room.EnabledCodecs,
 		Grants:                  pi.Grants,
 		Hidden:                  pi.Hidden,
 		Logger:                  pLogger,
 		ClientConf:              clientConf,
+		MaxSubscribeBitrate:     pi.MaxSubscribeBitrate,
+		Dynacast:                r.config.Room.Dynacast,
+		JoinStartedAt:           pi.JoinStartedAt,
+		TokenValidatedAt:        pi.TokenValidatedAt,
+		ClientMeta:              clientMeta,
 	}, pi.Permission)
 	if err != nil {
 		logger.Errorw("could not create participant", err)
@@ -265,7 +293,7 @@ func (r *RoomManager) StartSession(ctx context.Context, roomName livekit.RoomNam
 	opts := rtc.ParticipantOptions{
 		AutoSubscribe: pi.AutoSubscribe,
 	}
-	if err = room.Join(participant, &opts, r.iceServersForRoom(room.Room), r.currentNode.Region); err != nil {
+	if err = room.Join(participant, &opts, r.iceServersForParticipant(room.Room, participant.Identity()), r.currentNode.Region); err != nil {
 		pLogger.Errorw("could not join room", err)
 		_ = participant.Close(true)
 		return
@@ -286,7 +314,6 @@ func (r *RoomManager) StartSession(ctx context.Context, roomName livekit.RoomNam
 	// update room store with new numParticipants
 	updateParticipantCount()
 
-	clientMeta := &livekit.AnalyticsClientMeta{Region: r.currentNode.Region, Node: r.currentNode.Id}
 	r.telemetry.ParticipantJoined(ctx, room.Room, participant.ToProto(), pi.Client, clientMeta)
 	participant.OnClose(func(p types.LocalParticipant, disallowedSubscriptions map[livekit.TrackID]livekit.ParticipantID) {
 		if err := r.roomStore.DeleteParticipant(ctx, roomName, p.Identity()); err != nil {
@@ -325,13 +352,42 @@ func (r *RoomManager) getOrCreateRoom(ctx context.Context, roomName livekit.Room
 		return nil, err
 	}
 
+	internal, err := r.roomStore.LoadRoomInternal(ctx, roomName)
+	if err != nil {
+		logger.Errorw("could not load room settings", err, "room", roomName)
+		internal = &RoomInternal{}
+	}
+
+	audioConfig := r.config.Audio
+	if internal.MaxForwardedAudioTracks != nil {
+		audioConfig.MaxForwardedTracks = *internal.MaxForwardedAudioTracks
+	}
+
 	// construct ice servers
-	room = rtc.NewRoom(ri, *r.rtcConfig, &r.config.Audio, r.telemetry)
+	room = rtc.NewRoom(ri, *r.rtcConfig, &audioConfig, r.telemetry)
 	room.Hold()
 
 	r.telemetry.RoomStarted(ctx, room.Room)
 
+	// the deadline is measured from when a node first started hosting the room, so it carries over when the room
+	// moves to another node. A room that closes is deleted along with its settings, and starts over
+	var maxDurationTimer *time.Timer
+	if maxDuration := r.maxDurationForRoom(internal); maxDuration > 0 {
+		startedAt, err := r.roomStartedAt(ctx, roomName)
+		if err != nil {
+			logger.Errorw("could not record room start", err, "room", roomName)
+			startedAt = time.Now()
+		}
+		deadline := startedAt.Add(maxDuration)
+		maxDurationTimer = time.AfterFunc(time.Until(deadline), func() {
+			r.closeExpiredRoom(room)
+		})
+	}
+
 	room.OnClose(func() {
+		if maxDurationTimer != nil {
+			maxDurationTimer.Stop()
+		}
 		r.telemetry.RoomEnded(ctx, room.Room)
 		if err := r.DeleteRoom(ctx, roomName); err != nil {
 			logger.Errorw("could not delete room", err)
@@ -356,11 +412,70 @@ func (r *RoomManager) getOrCreateRoom(ctx context.Context, roomName livekit.Room
 
 	r.lock.Lock()
 	r.rooms[roomName] = room
+	r.roomInternal[roomName] = internal
 	r.lock.Unlock()
 
 	return room, nil
 }
 
+// iceCandidateTypesForRoom returns the room's override of rtc.ice_candidate_types, nil to use the server's
+func (r *RoomManager) iceCandidateTypesForRoom(roomName livekit.RoomName) []webrtc.ICECandidateType {
+	r.lock.RLock()
+	internal := r.roomInternal[roomName]
+	r.lock.RUnlock()
+	if internal == nil || len(internal.ICECandidateTypes) == 0 {
+		return nil
+	}
+	candidateTypes, err := rtc.ParseICECandidateTypes(internal.ICECandidateTypes)
+	if err != nil {
+		logger.Warnw("invalid ICE candidate types for room", err, "room", roomName)
+		return nil
+	}
+	return candidateTypes
+}
+
+func (r *RoomManager) maxDurationForRoom(internal *RoomInternal) time.Duration {
+	if internal.MaxDuration > 0 {
+		return time.Duration(internal.MaxDuration) * time.Second
+	}
+	return r.config.Room.MaxDuration.Duration()
+}
+
+// roomStartedAt returns when a node first started hosting the room, recording now when none has
+func (r *RoomManager) roomStartedAt(ctx context.Context, roomName livekit.RoomName) (time.Time, error) {
+	token, err := r.roomStore.LockRoom(ctx, roomName, 5*time.Second)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() {
+		_ = r.roomStore.UnlockRoom(ctx, roomName, token)
+	}()
+
+	internal, err := r.roomStore.LoadRoomInternal(ctx, roomName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if internal.StartedAt != 0 {
+		return time.Unix(internal.StartedAt, 0), nil
+	}
+
+	now := time.Now()
+	internal.StartedAt = now.Unix()
+	if err = r.roomStore.StoreRoomInternal(ctx, roomName, internal); err != nil {
+		return time.Time{}, err
+	}
+	return now, nil
+}
+
+// closeExpiredRoom disconnects all participants and closes a room that has reached its max duration
+func (r *RoomManager) closeExpiredRoom(room *rtc.Room) {
+	room.Logger.Infow("closing room, max duration reached")
+	for _, p := range room.GetParticipants() {
+		_ = p.Close(true)
+	}
+	room.Close()
+}
+
 // manages an RTC session for a participant, runs on the RTC node
 func (r *RoomManager) rtcSessionWorker(room *rtc.Room, participant types.LocalParticipant, requestSource routing.MessageSource) {
 	defer func() {
@@ -404,6 +519,13 @@ func (r *RoomManager) rtcSessionWorker(room *rtc.Room, participant types.LocalPa
 			}
 
 			req := obj.(*livekit.SignalRequest)
+			if req.GetAddTrack() != nil {
+				if err := r.checkTrackLimit(room); err != nil {
+					pLogger.Warnw("rejecting track publication", err, "track", req.GetAddTrack().Cid)
+					participant.RejectTrack(req.GetAddTrack())
+					continue
+				}
+			}
 			if err := rtc.HandleParticipantSignal(room, participant, req, pLogger); err != nil {
 				// more specific errors are already logged
 				// treat errors returned as fatal
@@ -414,7 +536,7 @@ func (r *RoomManager) rtcSessionWorker(room *rtc.Room, participant types.LocalPa
 }
 
 // handles RTC messages resulted from Room API calls
-func (r *RoomManager) handleRTCMessage(_ context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity, msg *livekit.RTCNodeMessage) {
+func (r *RoomManager) handleRTCMessage(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity, msg *livekit.RTCNodeMessage) {
 	r.lock.RLock()
 	room := r.rooms[roomName]
 	r.lock.RUnlock()
@@ -452,7 +574,8 @@ func (r *RoomManager) handleRTCMessage(_ context.Context, roomName livekit.RoomN
 			pLogger.Errorw("cannot unmute track, remote unmute is disabled", nil)
 			return
 		}
-		participant.SetTrackMuted(livekit.TrackID(rm.MuteTrack.TrackSid), rm.MuteTrack.Muted, true)
+		trackID := livekit.TrackID(rm.MuteTrack.TrackSid)
+		participant.SetTrackMuted(trackID, rm.MuteTrack.Muted, true)
 	case *livekit.RTCNodeMessage_UpdateParticipant:
 		if participant == nil {
 			return
@@ -467,6 +590,17 @@ func (r *RoomManager) handleRTCMessage(_ context.Context, roomName livekit.RoomN
 				pLogger.Errorw("could not update permissions", err)
 			}
 		}
+		if rm.UpdateParticipant.Metadata != "" || rm.UpdateParticipant.Permission != nil {
+			r.telemetry.ParticipantUpdated(ctx, room.Room, participant.ToProto(), rm.UpdateParticipant.Permission)
+		}
+		// set by the room service ahead of the update, the message has no field for it
+		internal, err := r.roomStore.LoadRoomInternal(ctx, roomName)
+		if err != nil {
+			pLogger.Errorw("could not load room settings", err)
+		} else if maxSubscribeBitrate, ok := internal.MaxSubscribeBitrates[participant.ID()]; ok {
+			pLogger.Debugw("setting max subscribe bitrate", "bitrate", maxSubscribeBitrate)
+			participant.SetMaxSubscribeBitrate(maxSubscribeBitrate)
+		}
 	case *livekit.RTCNodeMessage_DeleteRoom:
 		for _, p := range room.GetParticipants() {
 			_ = p.Close(true)
@@ -487,9 +621,22 @@ func (r *RoomManager) handleRTCMessage(_ context.Context, roomName livekit.RoomN
 				"tracks", rm.UpdateSubscriptions.TrackSids,
 				"subscribe", rm.UpdateSubscriptions.Subscribe)
 		}
+		// set by the room service ahead of the update, the message has no field for it
+		internal, err := r.roomStore.LoadRoomInternal(ctx, roomName)
+		if err != nil {
+			pLogger.Errorw("could not load room settings", err)
+		} else if trackCaps, ok := internal.SubscriptionLayerCaps[participant.ID()]; ok {
+			for _, trackID := range subscriptionTrackIDs(rm.UpdateSubscriptions) {
+				if layerCap, ok := trackCaps[trackID]; ok {
+					pLogger.Debugw("setting subscription layer cap", "trackID", trackID, "cap", layerCap)
+					participant.UpdateSubscribedTrackLayerCap(trackID, layerCap)
+				}
+			}
+		}
 	case *livekit.RTCNodeMessage_SendData:
 		pLogger.Debugw("SendData", "size", len(rm.SendData.Data))
 		up := &livekit.UserPacket{
+			ParticipantSid:  r.config.Room.ServerParticipantSid,
 			Payload:         rm.SendData.Data,
 			DestinationSids: rm.SendData.DestinationSids,
 		}
@@ -500,7 +647,7 @@ func (r *RoomManager) handleRTCMessage(_ context.Context, roomName livekit.RoomN
 	}
 }
 
-func (r *RoomManager) iceServersForRoom(ri *livekit.Room) []*livekit.ICEServer {
+func (r *RoomManager) iceServersForParticipant(ri *livekit.Room, identity livekit.ParticipantIdentity) []*livekit.ICEServer {
 	var iceServers []*livekit.ICEServer
 	rtcConf := r.config.RTC
 
@@ -516,10 +663,15 @@ func (r *RoomManager) iceServersForRoom(ri *livekit.Room) []*livekit.ICEServer {
 			urls = append(urls, fmt.Sprintf("turns:%s:443?transport=tcp", r.config.TURN.Domain))
 		}
 		if len(urls) > 0 {
+			username, credential := ri.Name, ri.TurnPassword
+			if r.config.TURN.AuthSecret != "" {
+				expiry := time.Now().Add(time.Duration(r.config.TURN.CredentialTTL))
+				username, credential = GenerateTurnCredentials(r.config.TURN.AuthSecret, identity, expiry)
+			}
 			iceServers = append(iceServers, &livekit.ICEServer{
 				Urls:       urls,
-				Username:   ri.Name,
-				Credential: ri.TurnPassword,
+				Username:   username,
+				Credential: credential,
 			})
 		}
 	}
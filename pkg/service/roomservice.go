@@ -2,14 +2,19 @@ package service
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
 	"github.com/pkg/errors"
 	"github.com/thoas/go-funk"
 	"github.com/twitchtv/twirp"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/telemetry"
 )
 
 const (
@@ -19,16 +24,26 @@ const (
 
 // A rooms service that supports a single node
 type RoomService struct {
+	conf          *config.Config
 	router        routing.MessageRouter
 	roomAllocator RoomAllocator
-	roomStore     ServiceStore
+	roomStore     ObjectStore
+	telemetry     telemetry.TelemetryService
 }
 
-func NewRoomService(ra RoomAllocator, rs ServiceStore, router routing.MessageRouter) (svc *RoomService, err error) {
+func NewRoomService(
+	conf *config.Config,
+	ra RoomAllocator,
+	rs ObjectStore,
+	router routing.MessageRouter,
+	telemetry telemetry.TelemetryService,
+) (svc *RoomService, err error) {
 	svc = &RoomService{
+		conf:          conf,
 		router:        router,
 		roomAllocator: ra,
 		roomStore:     rs,
+		telemetry:     telemetry,
 	}
 	return
 }
@@ -37,9 +52,21 @@ func (s *RoomService) CreateRoom(ctx context.Context, req *livekit.CreateRoomReq
 	if err = EnsureCreatePermission(ctx); err != nil {
 		return nil, twirpAuthError(err)
 	}
+	// the lock keeps participants out of the room, only its admins may change it
+	if GetRoomSettings(ctx).Locked != nil {
+		if err = EnsureAdminPermission(ctx, livekit.RoomName(req.Name)); err != nil {
+			return nil, twirpAuthError(err)
+		}
+	}
+	if err = s.checkMetadataSize(req.Metadata); err != nil {
+		return nil, err
+	}
 
 	rm, err = s.roomAllocator.CreateRoom(ctx, req)
-	if err != nil {
+	if errors.Is(err, ErrCodecNotEnabled) || errors.Is(err, ErrMaxDurationExceeded) ||
+		errors.Is(err, ErrInvalidCandidateType) || errors.Is(err, config.ErrRelayWithoutTURN) {
+		err = twirp.NewError(twirp.InvalidArgument, err.Error())
+	} else if err != nil {
 		err = errors.Wrap(err, "could not create room")
 	}
 
@@ -52,14 +79,34 @@ func (s *RoomService) ListRooms(ctx context.Context, req *livekit.ListRoomsReque
 		return nil, twirpAuthError(err)
 	}
 
-	var names []livekit.RoomName
-	if len(req.Names) > 0 {
-		names = livekit.StringsAsRoomNames(req.Names)
-	}
-	rooms, err := s.roomStore.ListRooms(ctx, names)
-	if err != nil {
-		// TODO: translate error codes to twirp
-		return
+	var rooms []*livekit.Room
+	// listing rooms by name is already bounded, paging only applies to listing all rooms
+	if opts := GetRoomSettings(ctx).ListRoomsOptions; opts != nil && len(req.Names) == 0 {
+		var nextPageToken string
+		rooms, nextPageToken, err = s.roomStore.ListRoomsPage(ctx, *opts)
+		if errors.Is(err, ErrInvalidPageToken) {
+			return nil, twirp.NewError(twirp.InvalidArgument, err.Error())
+		} else if err != nil {
+			return
+		}
+		if nextPageToken != "" {
+			if err = twirp.SetHTTPResponseHeader(ctx, NextPageTokenHeader, nextPageToken); err != nil {
+				return
+			}
+		}
+	} else {
+		var names []livekit.RoomName
+		if len(req.Names) > 0 {
+			names = livekit.StringsAsRoomNames(req.Names)
+		}
+		rooms, err = s.roomStore.ListRooms(ctx, names)
+		if err != nil {
+			// TODO: translate error codes to twirp
+			return
+		}
+		sort.Slice(rooms, func(i, j int) bool {
+			return rooms[i].Name < rooms[j].Name
+		})
 	}
 
 	res = &livekit.ListRoomsResponse{
@@ -104,11 +151,36 @@ func (s *RoomService) ListParticipants(ctx context.Context, req *livekit.ListPar
 		return nil, twirpAuthError(err)
 	}
 
-	participants, err := s.roomStore.ListParticipants(ctx, livekit.RoomName(req.Room))
-	if err != nil {
+	var participants []*livekit.ParticipantInfo
+	settings := GetRoomSettings(ctx)
+	if settings.PendingParticipants {
+		var pending []*PendingParticipant
+		if pending, err = s.roomStore.ListPendingParticipants(ctx, livekit.RoomName(req.Room)); err != nil {
+			return
+		}
+		for _, p := range pending {
+			participants = append(participants, p.ToProto())
+		}
+	} else if participants, err = s.roomStore.ListParticipants(ctx, livekit.RoomName(req.Room)); err != nil {
 		return
 	}
 
+	if settings.PublishersOnly {
+		publishers := participants[:0]
+		for _, p := range participants {
+			if len(p.Tracks) > 0 {
+				publishers = append(publishers, p)
+			}
+		}
+		participants = publishers
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		if participants[i].JoinedAt != participants[j].JoinedAt {
+			return participants[i].JoinedAt < participants[j].JoinedAt
+		}
+		return participants[i].Identity < participants[j].Identity
+	})
+
 	res = &livekit.ListParticipantsResponse{
 		Participants: participants,
 	}
@@ -130,12 +202,37 @@ func (s *RoomService) GetParticipant(ctx context.Context, req *livekit.RoomParti
 }
 
 func (s *RoomService) RemoveParticipant(ctx context.Context, req *livekit.RoomParticipantIdentity) (res *livekit.RemoveParticipantResponse, err error) {
-	err = s.writeParticipantMessage(ctx, livekit.RoomName(req.Room), livekit.ParticipantIdentity(req.Identity), &livekit.RTCNodeMessage{
+	roomName, identity := livekit.RoomName(req.Room), livekit.ParticipantIdentity(req.Identity)
+	if err = EnsureAdminPermission(ctx, roomName); err != nil {
+		return nil, twirpAuthError(err)
+	}
+	if _, err = s.roomStore.LoadParticipant(ctx, roomName, identity); err != nil {
+		return
+	}
+
+	// blocked before the participant is told to leave, so that it can't rejoin in between
+	blockDuration, err := s.blockRejoinDuration(ctx, roomName)
+	if err != nil {
+		return
+	}
+	if blockDuration > 0 {
+		if err = s.roomStore.BlockParticipant(ctx, roomName, identity, blockDuration); err != nil {
+			return
+		}
+	}
+
+	err = s.router.WriteParticipantRTC(ctx, roomName, identity, &livekit.RTCNodeMessage{
 		Message: &livekit.RTCNodeMessage_RemoveParticipant{
 			RemoveParticipant: req,
 		},
 	})
 	if err != nil {
+		if blockDuration > 0 {
+			if unblockErr := s.roomStore.UnblockParticipant(ctx, roomName, identity); unblockErr != nil {
+				logger.Errorw("could not unblock participant that wasn't removed", unblockErr,
+					"room", roomName, "participant", identity)
+			}
+		}
 		return
 	}
 
@@ -161,6 +258,9 @@ func (s *RoomService) MutePublishedTrack(ctx context.Context, req *livekit.MuteR
 	if err = EnsureAdminPermission(ctx, livekit.RoomName(req.Room)); err != nil {
 		return nil, twirpAuthError(err)
 	}
+	if !req.Muted && !s.conf.Room.EnableRemoteUnmute {
+		return nil, twirp.NewError(twirp.FailedPrecondition, ErrRemoteUnmuteDisabled.Error())
+	}
 
 	participant, err := s.roomStore.LoadParticipant(ctx, livekit.RoomName(req.Room), livekit.ParticipantIdentity(req.Identity))
 	if err != nil {
@@ -214,6 +314,18 @@ func (s *RoomService) MutePublishedTrack(ctx context.Context, req *livekit.MuteR
 }
 
 func (s *RoomService) UpdateParticipant(ctx context.Context, req *livekit.UpdateParticipantRequest) (*livekit.ParticipantInfo, error) {
+	settings := GetRoomSettings(ctx)
+	if approved := settings.ApproveParticipant; approved != nil {
+		return s.approveParticipant(ctx, livekit.RoomName(req.Room), livekit.ParticipantIdentity(req.Identity), *approved)
+	}
+
+	if maxSubscribeBitrate := settings.MaxSubscribeBitrate; maxSubscribeBitrate != nil {
+		// stored for the RTC node to pick up when handling the update
+		if err := s.storeMaxSubscribeBitrate(ctx, livekit.RoomName(req.Room), livekit.ParticipantIdentity(req.Identity), *maxSubscribeBitrate); err != nil {
+			return nil, err
+		}
+	}
+
 	err := s.writeParticipantMessage(ctx, livekit.RoomName(req.Room), livekit.ParticipantIdentity(req.Identity), &livekit.RTCNodeMessage{
 		Message: &livekit.RTCNodeMessage_UpdateParticipant{
 			UpdateParticipant: req,
@@ -229,7 +341,7 @@ func (s *RoomService) UpdateParticipant(ctx context.Context, req *livekit.Update
 		if err != nil {
 			return err
 		}
-		if participant.Metadata != req.Metadata {
+		if req.Metadata != "" && participant.Metadata != req.Metadata {
 			return ErrOperationFailed
 		}
 		return nil
@@ -241,7 +353,66 @@ func (s *RoomService) UpdateParticipant(ctx context.Context, req *livekit.Update
 	return participant, nil
 }
 
+// approveParticipant records a moderator's decision on a participant waiting to join, which RTCService is polling for
+func (s *RoomService) approveParticipant(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity, approved bool) (*livekit.ParticipantInfo, error) {
+	if err := EnsureAdminPermission(ctx, roomName); err != nil {
+		return nil, twirpAuthError(err)
+	}
+
+	pending, err := s.roomStore.LoadPendingParticipant(ctx, roomName, identity)
+	if err != nil {
+		return nil, err
+	}
+	pending.Approved = &approved
+	if err = s.roomStore.StorePendingParticipant(ctx, roomName, pending); err != nil {
+		return nil, err
+	}
+	return pending.ToProto(), nil
+}
+
+// storeMaxSubscribeBitrate sets the subscribe bitrate cap of the participant's current session in the room's
+// internal settings, since UpdateParticipantRequest cannot carry it. Caps of sessions that have left are dropped
+func (s *RoomService) storeMaxSubscribeBitrate(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity, maxSubscribeBitrate uint64) error {
+	if err := EnsureAdminPermission(ctx, roomName); err != nil {
+		return twirpAuthError(err)
+	}
+
+	participant, err := s.roomStore.LoadParticipant(ctx, roomName, identity)
+	if err != nil {
+		return err
+	}
+	participants, err := s.roomStore.ListParticipants(ctx, roomName)
+	if err != nil {
+		return err
+	}
+	internal, err := s.roomStore.LoadRoomInternal(ctx, roomName)
+	if err != nil {
+		return err
+	}
+
+	bitrates := map[livekit.ParticipantID]uint64{
+		livekit.ParticipantID(participant.Sid): maxSubscribeBitrate,
+	}
+	for _, p := range participants {
+		if bitrate, ok := internal.MaxSubscribeBitrates[livekit.ParticipantID(p.Sid)]; ok && p.Sid != participant.Sid {
+			bitrates[livekit.ParticipantID(p.Sid)] = bitrate
+		}
+	}
+
+	// the loaded settings may be shared with the store, update a copy
+	updated := *internal
+	updated.MaxSubscribeBitrates = bitrates
+	return s.roomStore.StoreRoomInternal(ctx, roomName, &updated)
+}
+
 func (s *RoomService) UpdateSubscriptions(ctx context.Context, req *livekit.UpdateSubscriptionsRequest) (*livekit.UpdateSubscriptionsResponse, error) {
+	if settings := GetRoomSettings(ctx); settings.SubscriptionLayerCapSet {
+		// stored for the RTC node to pick up when handling the update
+		if err := s.storeSubscriptionLayerCaps(ctx, req, settings.SubscriptionLayerCap); err != nil {
+			return nil, err
+		}
+	}
+
 	err := s.writeParticipantMessage(ctx, livekit.RoomName(req.Room), livekit.ParticipantIdentity(req.Identity), &livekit.RTCNodeMessage{
 		Message: &livekit.RTCNodeMessage_UpdateSubscriptions{
 			UpdateSubscriptions: req,
@@ -254,11 +425,67 @@ func (s *RoomService) UpdateSubscriptions(ctx context.Context, req *livekit.Upda
 	return &livekit.UpdateSubscriptionsResponse{}, nil
 }
 
+// storeSubscriptionLayerCaps sets the layer cap of the requested tracks for the participant's current session
+// in the room's internal settings. Caps of sessions that have left are dropped
+func (s *RoomService) storeSubscriptionLayerCaps(ctx context.Context, req *livekit.UpdateSubscriptionsRequest, layerCap *types.SubscriptionLayerCap) error {
+	roomName := livekit.RoomName(req.Room)
+	if err := EnsureAdminPermission(ctx, roomName); err != nil {
+		return twirpAuthError(err)
+	}
+
+	participant, err := s.roomStore.LoadParticipant(ctx, roomName, livekit.ParticipantIdentity(req.Identity))
+	if err != nil {
+		return err
+	}
+	participants, err := s.roomStore.ListParticipants(ctx, roomName)
+	if err != nil {
+		return err
+	}
+	internal, err := s.roomStore.LoadRoomInternal(ctx, roomName)
+	if err != nil {
+		return err
+	}
+
+	caps := make(map[livekit.ParticipantID]map[livekit.TrackID]*types.SubscriptionLayerCap)
+	for _, p := range participants {
+		if trackCaps, ok := internal.SubscriptionLayerCaps[livekit.ParticipantID(p.Sid)]; ok {
+			caps[livekit.ParticipantID(p.Sid)] = trackCaps
+		}
+	}
+
+	// the loaded caps may be shared with the store, update a copy
+	trackCaps := make(map[livekit.TrackID]*types.SubscriptionLayerCap)
+	for trackID, c := range caps[livekit.ParticipantID(participant.Sid)] {
+		trackCaps[trackID] = c
+	}
+	for _, trackID := range subscriptionTrackIDs(req) {
+		trackCaps[trackID] = layerCap
+	}
+	caps[livekit.ParticipantID(participant.Sid)] = trackCaps
+
+	updated := *internal
+	updated.SubscriptionLayerCaps = caps
+	return s.roomStore.StoreRoomInternal(ctx, roomName, &updated)
+}
+
+// subscriptionTrackIDs returns all tracks of the request, whether listed directly or by participant
+func subscriptionTrackIDs(req *livekit.UpdateSubscriptionsRequest) []livekit.TrackID {
+	trackIDs := livekit.StringsAsTrackIDs(req.TrackSids)
+	for _, pt := range req.ParticipantTracks {
+		trackIDs = append(trackIDs, livekit.StringsAsTrackIDs(pt.TrackSids)...)
+	}
+	return trackIDs
+}
+
 func (s *RoomService) SendData(ctx context.Context, req *livekit.SendDataRequest) (*livekit.SendDataResponse, error) {
 	roomName := livekit.RoomName(req.Room)
 	if err := EnsureAdminPermission(ctx, roomName); err != nil {
 		return nil, twirpAuthError(err)
 	}
+	if maxSize := s.conf.Room.MaxDataSize; maxSize > 0 && len(req.Data) > maxSize {
+		return nil, twirp.NewError(twirp.InvalidArgument,
+			errors.Wrapf(ErrDataTooLarge, "%d bytes, max %d", len(req.Data), maxSize).Error())
+	}
 
 	err := s.router.WriteRoomRTC(ctx, roomName, &livekit.RTCNodeMessage{
 		Message: &livekit.RTCNodeMessage_SendData{
@@ -273,18 +500,24 @@ func (s *RoomService) SendData(ctx context.Context, req *livekit.SendDataRequest
 }
 
 func (s *RoomService) UpdateRoomMetadata(ctx context.Context, req *livekit.UpdateRoomMetadataRequest) (*livekit.Room, error) {
-	if err := EnsureAdminPermission(ctx, livekit.RoomName(req.Room)); err != nil {
+	roomName := livekit.RoomName(req.Room)
+	if err := EnsureAdminPermission(ctx, roomName); err != nil {
 		return nil, twirpAuthError(err)
 	}
+	if err := s.checkMetadataSize(req.Metadata); err != nil {
+		return nil, err
+	}
 
-	room, err := s.roomStore.LoadRoom(ctx, livekit.RoomName(req.Room))
+	_, err := s.roomStore.LoadRoom(ctx, roomName)
 	if err != nil {
 		return nil, err
 	}
 
 	// no one has joined the room, would not have been created on an RTC node.
-	// in this case, we'd want to run create again
-	_, err = s.roomAllocator.CreateRoom(ctx, &livekit.CreateRoomRequest{
+	// in this case, we'd want to run create again. Of the room settings only the lock can be changed here, room
+	// admin was checked above
+	lockCtx := WithRoomSettings(ctx, &RoomSettings{Locked: GetRoomSettings(ctx).Locked})
+	_, err = s.roomAllocator.CreateRoom(lockCtx, &livekit.CreateRoomRequest{
 		Name:     req.Room,
 		Metadata: req.Metadata,
 	})
@@ -292,7 +525,14 @@ func (s *RoomService) UpdateRoomMetadata(ctx context.Context, req *livekit.Updat
 		return nil, err
 	}
 
-	err = s.router.WriteRoomRTC(ctx, livekit.RoomName(req.Room), &livekit.RTCNodeMessage{
+	// stored here rather than waiting on the RTC node, so that ListRooms has it right away, including when it is
+	// cleared, which CreateRoom leaves alone
+	room, err := s.storeRoomMetadata(ctx, roomName, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.router.WriteRoomRTC(ctx, roomName, &livekit.RTCNodeMessage{
 		Message: &livekit.RTCNodeMessage_UpdateRoomMetadata{
 			UpdateRoomMetadata: req,
 		},
@@ -301,23 +541,52 @@ func (s *RoomService) UpdateRoomMetadata(ctx context.Context, req *livekit.Updat
 		return nil, err
 	}
 
-	err = confirmExecution(func() error {
-		room, err = s.roomStore.LoadRoom(ctx, livekit.RoomName(req.Room))
-		if err != nil {
-			return err
-		}
-		if room.Metadata != req.Metadata {
-			return ErrOperationFailed
-		}
-		return nil
-	})
+	s.telemetry.RoomMetadataChanged(ctx, room)
+
+	return room, nil
+}
+
+func (s *RoomService) checkMetadataSize(metadata string) error {
+	if maxSize := s.conf.Room.MaxMetadataSize; maxSize > 0 && len(metadata) > maxSize {
+		return twirp.NewError(twirp.InvalidArgument,
+			errors.Wrapf(ErrMetadataTooLarge, "%d bytes, max %d", len(metadata), maxSize).Error())
+	}
+	return nil
+}
+
+func (s *RoomService) storeRoomMetadata(ctx context.Context, roomName livekit.RoomName, metadata string) (*livekit.Room, error) {
+	token, err := s.roomStore.LockRoom(ctx, roomName, 5*time.Second)
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		_ = s.roomStore.UnlockRoom(ctx, roomName, token)
+	}()
 
+	room, err := s.roomStore.LoadRoom(ctx, roomName)
+	if err != nil {
+		return nil, err
+	}
+	room.Metadata = metadata
+	if err = s.roomStore.StoreRoom(ctx, room); err != nil {
+		return nil, err
+	}
 	return room, nil
 }
 
+// blockRejoinDuration returns how long participants removed from the room are refused, the room's own setting or
+// room.block_rejoin_duration
+func (s *RoomService) blockRejoinDuration(ctx context.Context, roomName livekit.RoomName) (time.Duration, error) {
+	internal, err := s.roomStore.LoadRoomInternal(ctx, roomName)
+	if err != nil {
+		return 0, err
+	}
+	if internal != nil && internal.BlockRejoinDuration != nil {
+		return time.Duration(*internal.BlockRejoinDuration) * time.Second, nil
+	}
+	return s.conf.Room.BlockRejoinDuration.Duration(), nil
+}
+
 func (s *RoomService) writeParticipantMessage(ctx context.Context, room livekit.RoomName, identity livekit.ParticipantIdentity, msg *livekit.RTCNodeMessage) error {
 	if err := EnsureAdminPermission(ctx, room); err != nil {
 		return twirpAuthError(err)
@@ -2,15 +2,24 @@ package service_test
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
 	"github.com/stretchr/testify/require"
+	"github.com/twitchtv/twirp"
 
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing/routingfakes"
+	"github.com/livekit/livekit-server/pkg/rtc/types"
 	"github.com/livekit/livekit-server/pkg/service"
 	"github.com/livekit/livekit-server/pkg/service/servicefakes"
+	"github.com/livekit/livekit-server/pkg/telemetry/telemetryfakes"
 )
 
 func TestDeleteRoom(t *testing.T) {
@@ -43,10 +52,19 @@ func TestDeleteRoom(t *testing.T) {
 }
 
 func newTestRoomService() *TestRoomService {
+	conf, err := config.NewConfig("", nil)
+	if err != nil {
+		panic(err)
+	}
+	return newTestRoomServiceWithConfig(conf)
+}
+
+func newTestRoomServiceWithConfig(conf *config.Config) *TestRoomService {
 	router := &routingfakes.FakeRouter{}
 	allocator := &servicefakes.FakeRoomAllocator{}
-	store := &servicefakes.FakeServiceStore{}
-	svc, err := service.NewRoomService(allocator, store, router)
+	store := &servicefakes.FakeObjectStore{}
+	telemetry := &telemetryfakes.FakeTelemetryService{}
+	svc, err := service.NewRoomService(conf, allocator, store, router, telemetry)
 	if err != nil {
 		panic(err)
 	}
@@ -55,6 +73,7 @@ func newTestRoomService() *TestRoomService {
 		router:      router,
 		allocator:   allocator,
 		store:       store,
+		telemetry:   telemetry,
 	}
 }
 
@@ -62,5 +81,649 @@ type TestRoomService struct {
 	service.RoomService
 	router    *routingfakes.FakeRouter
 	allocator *servicefakes.FakeRoomAllocator
-	store     *servicefakes.FakeServiceStore
+	store     *servicefakes.FakeObjectStore
+	telemetry *telemetryfakes.FakeTelemetryService
+}
+
+func TestMutePublishedTrack(t *testing.T) {
+	grant := &auth.ClaimGrants{
+		Video: &auth.VideoGrant{
+			RoomAdmin: true,
+			Room:      "testroom",
+		},
+	}
+	ctx := service.WithGrants(context.Background(), grant)
+
+	t.Run("routed to the participant", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadParticipantReturns(&livekit.ParticipantInfo{
+			Sid:      "PA_current",
+			Identity: "user",
+			Tracks:   []*livekit.TrackInfo{{Sid: "TR_mic", Muted: true}},
+		}, nil)
+
+		res, err := svc.MutePublishedTrack(ctx, &livekit.MuteRoomTrackRequest{
+			Room:     "testroom",
+			Identity: "user",
+			TrackSid: "TR_mic",
+			Muted:    true,
+		})
+		require.NoError(t, err)
+		require.True(t, res.Track.Muted)
+
+		require.Equal(t, 1, svc.router.WriteParticipantRTCCallCount())
+		_, _, identity, msg := svc.router.WriteParticipantRTCArgsForCall(0)
+		require.Equal(t, livekit.ParticipantIdentity("user"), identity)
+		require.Equal(t, "TR_mic", msg.GetMuteTrack().TrackSid)
+	})
+
+	t.Run("unmute requires remote unmute", func(t *testing.T) {
+		svc := newTestRoomService()
+		_, err := svc.MutePublishedTrack(ctx, &livekit.MuteRoomTrackRequest{
+			Room:     "testroom",
+			Identity: "user",
+			TrackSid: "TR_mic",
+			Muted:    false,
+		})
+		var twErr twirp.Error
+		require.ErrorAs(t, err, &twErr)
+		require.Equal(t, twirp.FailedPrecondition, twErr.Code())
+		require.Zero(t, svc.router.WriteParticipantRTCCallCount())
+	})
+}
+
+func TestRemoveParticipant(t *testing.T) {
+	grant := &auth.ClaimGrants{
+		Video: &auth.VideoGrant{
+			RoomAdmin: true,
+			Room:      "testroom",
+		},
+	}
+	ctx := service.WithGrants(context.Background(), grant)
+
+	remove := func(t *testing.T, svc *TestRoomService) {
+		// participant is gone from the store once the RTC node has removed it
+		svc.store.LoadParticipantReturns(nil, service.ErrParticipantNotFound)
+		svc.store.LoadParticipantReturnsOnCall(0, &livekit.ParticipantInfo{Identity: "user"}, nil)
+
+		_, err := svc.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{
+			Room:     "testroom",
+			Identity: "user",
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, svc.router.WriteParticipantRTCCallCount())
+		_, _, identity, msg := svc.router.WriteParticipantRTCArgsForCall(0)
+		require.Equal(t, livekit.ParticipantIdentity("user"), identity)
+		require.Equal(t, "user", msg.GetRemoveParticipant().Identity)
+	}
+
+	t.Run("rejoining allowed by default", func(t *testing.T) {
+		svc := newTestRoomService()
+		remove(t, svc)
+		require.Zero(t, svc.store.BlockParticipantCallCount())
+	})
+
+	t.Run("blocks rejoining", func(t *testing.T) {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		conf.Room.BlockRejoinDuration = config.Duration(5 * time.Minute)
+		svc := newTestRoomServiceWithConfig(conf)
+		remove(t, svc)
+
+		require.Equal(t, 1, svc.store.BlockParticipantCallCount())
+		_, roomName, identity, duration := svc.store.BlockParticipantArgsForCall(0)
+		require.Equal(t, livekit.RoomName("testroom"), roomName)
+		require.Equal(t, livekit.ParticipantIdentity("user"), identity)
+		require.Equal(t, 5*time.Minute, duration)
+	})
+
+	t.Run("blocked before being removed", func(t *testing.T) {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		conf.Room.BlockRejoinDuration = config.Duration(5 * time.Minute)
+		svc := newTestRoomServiceWithConfig(conf)
+		svc.router.WriteParticipantRTCCalls(func(context.Context, livekit.RoomName, livekit.ParticipantIdentity, *livekit.RTCNodeMessage) error {
+			require.Equal(t, 1, svc.store.BlockParticipantCallCount())
+			return nil
+		})
+		remove(t, svc)
+		require.Zero(t, svc.store.UnblockParticipantCallCount())
+	})
+
+	t.Run("unblocked when the removal fails", func(t *testing.T) {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		conf.Room.BlockRejoinDuration = config.Duration(5 * time.Minute)
+		svc := newTestRoomServiceWithConfig(conf)
+		svc.store.LoadParticipantReturns(&livekit.ParticipantInfo{Identity: "user"}, nil)
+		svc.router.WriteParticipantRTCReturns(errors.New("node unreachable"))
+
+		_, err = svc.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{
+			Room:     "testroom",
+			Identity: "user",
+		})
+		require.Error(t, err)
+		require.Equal(t, 1, svc.store.BlockParticipantCallCount())
+		require.Equal(t, 1, svc.store.UnblockParticipantCallCount())
+		_, roomName, identity := svc.store.UnblockParticipantArgsForCall(0)
+		require.Equal(t, livekit.RoomName("testroom"), roomName)
+		require.Equal(t, livekit.ParticipantIdentity("user"), identity)
+	})
+
+	t.Run("room setting overrides the server's", func(t *testing.T) {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		conf.Room.BlockRejoinDuration = config.Duration(5 * time.Minute)
+		svc := newTestRoomServiceWithConfig(conf)
+		blockRejoinDuration := uint32(30)
+		svc.store.LoadRoomInternalReturns(&service.RoomInternal{BlockRejoinDuration: &blockRejoinDuration}, nil)
+		remove(t, svc)
+		require.Equal(t, 1, svc.store.BlockParticipantCallCount())
+		_, _, _, duration := svc.store.BlockParticipantArgsForCall(0)
+		require.Equal(t, 30*time.Second, duration)
+
+		// rejoining right away
+		svc = newTestRoomServiceWithConfig(conf)
+		blockRejoinDuration = 0
+		svc.store.LoadRoomInternalReturns(&service.RoomInternal{BlockRejoinDuration: &blockRejoinDuration}, nil)
+		remove(t, svc)
+		require.Zero(t, svc.store.BlockParticipantCallCount())
+	})
+
+	t.Run("participant not found", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadParticipantReturns(nil, service.ErrParticipantNotFound)
+		_, err := svc.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{
+			Room:     "testroom",
+			Identity: "user",
+		})
+		require.ErrorIs(t, err, service.ErrParticipantNotFound)
+		require.Zero(t, svc.router.WriteParticipantRTCCallCount())
+		require.Zero(t, svc.store.BlockParticipantCallCount())
+	})
+}
+
+func TestSendData(t *testing.T) {
+	grant := &auth.ClaimGrants{
+		Video: &auth.VideoGrant{
+			RoomAdmin: true,
+			Room:      "testroom",
+		},
+	}
+	ctx := service.WithGrants(context.Background(), grant)
+
+	t.Run("routed to the room", func(t *testing.T) {
+		svc := newTestRoomService()
+		_, err := svc.SendData(ctx, &livekit.SendDataRequest{
+			Room:            "testroom",
+			Data:            []byte("scoreboard"),
+			Kind:            livekit.DataPacket_LOSSY,
+			DestinationSids: []string{"PA_user"},
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, svc.router.WriteRoomRTCCallCount())
+		_, roomName, msg := svc.router.WriteRoomRTCArgsForCall(0)
+		require.Equal(t, livekit.RoomName("testroom"), roomName)
+		require.Equal(t, []byte("scoreboard"), msg.GetSendData().Data)
+		require.Equal(t, livekit.DataPacket_LOSSY, msg.GetSendData().Kind)
+		require.Equal(t, []string{"PA_user"}, msg.GetSendData().DestinationSids)
+	})
+
+	t.Run("payload too large", func(t *testing.T) {
+		svc := newTestRoomService()
+		_, err := svc.SendData(ctx, &livekit.SendDataRequest{
+			Room: "testroom",
+			Data: make([]byte, 15*1024+1),
+		})
+		var twErr twirp.Error
+		require.ErrorAs(t, err, &twErr)
+		require.Equal(t, twirp.InvalidArgument, twErr.Code())
+		require.Zero(t, svc.router.WriteRoomRTCCallCount())
+	})
+}
+
+func TestListRooms(t *testing.T) {
+	grant := &auth.ClaimGrants{
+		Video: &auth.VideoGrant{
+			RoomList: true,
+		},
+	}
+
+	// serves through twirp so response headers are set
+	listRooms := func(svc *TestRoomService, headers map[string]string) *httptest.ResponseRecorder {
+		server := livekit.NewRoomServiceServer(&svc.RoomService)
+		r := httptest.NewRequest(http.MethodPost, server.PathPrefix()+"ListRooms", strings.NewReader("{}"))
+		r.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		service.RoomSettingsMiddleware(w, r, func(w http.ResponseWriter, r *http.Request) {
+			server.ServeHTTP(w, r.WithContext(service.WithGrants(r.Context(), grant)))
+		})
+		return w
+	}
+
+	t.Run("lists all rooms by name", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.ListRoomsReturns([]*livekit.Room{{Name: "b"}, {Name: "a"}}, nil)
+
+		w := listRooms(svc, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Less(t, strings.Index(w.Body.String(), `"a"`), strings.Index(w.Body.String(), `"b"`))
+		require.Zero(t, svc.store.ListRoomsPageCallCount())
+		require.Empty(t, w.Header().Get(service.NextPageTokenHeader))
+	})
+
+	t.Run("pages with filters", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.ListRoomsPageReturns([]*livekit.Room{{Name: "game-1"}}, "next", nil)
+
+		w := listRooms(svc, map[string]string{
+			service.PageLimitHeader:       "1",
+			service.PageTokenHeader:       "current",
+			service.RoomNamePrefixHeader:  "game-",
+			service.CreatedAfterHeader:    "1600000000",
+			service.MinParticipantsHeader: "2",
+		})
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "next", w.Header().Get(service.NextPageTokenHeader))
+		require.Contains(t, w.Body.String(), "game-1")
+
+		require.Equal(t, 1, svc.store.ListRoomsPageCallCount())
+		_, opts := svc.store.ListRoomsPageArgsForCall(0)
+		require.Equal(t, service.ListRoomsOptions{
+			NamePrefix:      "game-",
+			CreatedAfter:    1600000000,
+			MinParticipants: 2,
+			PageToken:       "current",
+			Limit:           1,
+		}, opts)
+	})
+
+	t.Run("invalid page token", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.ListRoomsPageReturns(nil, "", service.ErrInvalidPageToken)
+
+		w := listRooms(svc, map[string]string{service.PageTokenHeader: "bogus"})
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		svc := newTestRoomService()
+		w := listRooms(svc, map[string]string{service.MinParticipantsHeader: "many"})
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Zero(t, svc.store.ListRoomsPageCallCount())
+	})
+}
+
+func TestListParticipants(t *testing.T) {
+	grant := &auth.ClaimGrants{
+		Video: &auth.VideoGrant{
+			RoomAdmin: true,
+			Room:      "testroom",
+		},
+	}
+	ctx := service.WithGrants(context.Background(), grant)
+
+	participants := func() []*livekit.ParticipantInfo {
+		return []*livekit.ParticipantInfo{
+			{Identity: "publisher", JoinedAt: 20, Tracks: []*livekit.TrackInfo{{Sid: "TR_mic"}}},
+			{Identity: "viewer", JoinedAt: 10},
+			{Identity: "first", JoinedAt: 10, Tracks: []*livekit.TrackInfo{{Sid: "TR_cam"}}},
+		}
+	}
+	identities := func(res *livekit.ListParticipantsResponse) []string {
+		var ids []string
+		for _, p := range res.Participants {
+			ids = append(ids, p.Identity)
+		}
+		return ids
+	}
+
+	svc := newTestRoomService()
+	svc.store.ListParticipantsReturns(participants(), nil)
+	res, err := svc.ListParticipants(ctx, &livekit.ListParticipantsRequest{Room: "testroom"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "viewer", "publisher"}, identities(res))
+
+	svc.store.ListParticipantsReturns(participants(), nil)
+	res, err = svc.ListParticipants(service.WithRoomSettings(ctx, &service.RoomSettings{PublishersOnly: true}), &livekit.ListParticipantsRequest{Room: "testroom"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "publisher"}, identities(res))
+}
+
+func TestUpdateRoomMetadata(t *testing.T) {
+	grant := &auth.ClaimGrants{
+		Video: &auth.VideoGrant{
+			RoomAdmin: true,
+			Room:      "testroom",
+		},
+	}
+	ctx := service.WithGrants(context.Background(), grant)
+
+	t.Run("stored and sent to the room", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadRoomReturns(&livekit.Room{Name: "testroom", Metadata: "agenda: intro"}, nil)
+
+		room, err := svc.UpdateRoomMetadata(ctx, &livekit.UpdateRoomMetadataRequest{
+			Room:     "testroom",
+			Metadata: "agenda: q&a",
+		})
+		require.NoError(t, err)
+		require.Equal(t, "agenda: q&a", room.Metadata)
+
+		require.Equal(t, 1, svc.store.StoreRoomCallCount())
+		_, stored := svc.store.StoreRoomArgsForCall(0)
+		require.Equal(t, "agenda: q&a", stored.Metadata)
+
+		require.Equal(t, 1, svc.router.WriteRoomRTCCallCount())
+		_, roomName, msg := svc.router.WriteRoomRTCArgsForCall(0)
+		require.Equal(t, livekit.RoomName("testroom"), roomName)
+		require.Equal(t, "agenda: q&a", msg.GetUpdateRoomMetadata().Metadata)
+
+		require.Equal(t, 1, svc.telemetry.RoomMetadataChangedCallCount())
+		_, notified := svc.telemetry.RoomMetadataChangedArgsForCall(0)
+		require.Equal(t, "agenda: q&a", notified.Metadata)
+	})
+
+	t.Run("cleared", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadRoomReturns(&livekit.Room{Name: "testroom", Metadata: "agenda: intro"}, nil)
+
+		room, err := svc.UpdateRoomMetadata(ctx, &livekit.UpdateRoomMetadataRequest{Room: "testroom"})
+		require.NoError(t, err)
+		require.Empty(t, room.Metadata)
+		_, stored := svc.store.StoreRoomArgsForCall(0)
+		require.Empty(t, stored.Metadata)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadRoomReturns(&livekit.Room{Name: "testroom"}, nil)
+
+		_, err := svc.UpdateRoomMetadata(ctx, &livekit.UpdateRoomMetadataRequest{
+			Room:     "testroom",
+			Metadata: strings.Repeat("a", 64*1024+1),
+		})
+		var twErr twirp.Error
+		require.ErrorAs(t, err, &twErr)
+		require.Equal(t, twirp.InvalidArgument, twErr.Code())
+		require.Zero(t, svc.store.StoreRoomCallCount())
+		require.Zero(t, svc.router.WriteRoomRTCCallCount())
+		require.Zero(t, svc.telemetry.RoomMetadataChangedCallCount())
+	})
+}
+
+func TestUpdateRoomMetadataLock(t *testing.T) {
+	locked := true
+	settings := &service.RoomSettings{
+		Locked:        &locked,
+		EnabledCodecs: []*livekit.Codec{{Mime: "video/h264"}},
+		MaxDuration:   time.Hour,
+	}
+
+	t.Run("room admin", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadRoomReturns(&livekit.Room{Name: "testroom"}, nil)
+		ctx := service.WithRoomSettings(service.WithGrants(context.Background(), &auth.ClaimGrants{
+			Video: &auth.VideoGrant{RoomAdmin: true, Room: "testroom"},
+		}), settings)
+
+		_, err := svc.UpdateRoomMetadata(ctx, &livekit.UpdateRoomMetadataRequest{Room: "testroom"})
+		require.NoError(t, err)
+
+		// only the lock is applied, other settings can only be set on CreateRoom
+		require.Equal(t, 1, svc.allocator.CreateRoomCallCount())
+		createCtx, _ := svc.allocator.CreateRoomArgsForCall(0)
+		require.Equal(t, &service.RoomSettings{Locked: &locked}, service.GetRoomSettings(createCtx))
+	})
+
+	for name, grant := range map[string]*auth.VideoGrant{
+		"create permission":        {RoomCreate: true},
+		"join permission":          {RoomJoin: true, Room: "testroom"},
+		"admin of another room":    {RoomAdmin: true, Room: "otherroom"},
+		"create and join the room": {RoomCreate: true, RoomJoin: true, Room: "testroom"},
+	} {
+		grant := grant
+		t.Run(name, func(t *testing.T) {
+			svc := newTestRoomService()
+			svc.store.LoadRoomReturns(&livekit.Room{Name: "testroom"}, nil)
+			ctx := service.WithRoomSettings(service.WithGrants(context.Background(), &auth.ClaimGrants{Video: grant}), settings)
+
+			_, err := svc.UpdateRoomMetadata(ctx, &livekit.UpdateRoomMetadataRequest{Room: "testroom"})
+			var twErr twirp.Error
+			require.ErrorAs(t, err, &twErr)
+			require.Equal(t, twirp.Unauthenticated, twErr.Code())
+			require.Zero(t, svc.allocator.CreateRoomCallCount())
+			require.Zero(t, svc.store.StoreRoomCallCount())
+			require.Zero(t, svc.router.WriteRoomRTCCallCount())
+		})
+	}
+}
+
+func TestUpdateParticipantMaxSubscribeBitrate(t *testing.T) {
+	grant := &auth.ClaimGrants{
+		Video: &auth.VideoGrant{
+			RoomAdmin: true,
+			Room:      "testroom",
+		},
+	}
+	participant := &livekit.ParticipantInfo{Sid: "PA_current", Identity: "user"}
+	maxSubscribeBitrate := uint64(500000)
+
+	t.Run("stored for the current session", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadParticipantReturns(participant, nil)
+		svc.store.ListParticipantsReturns([]*livekit.ParticipantInfo{
+			participant,
+			{Sid: "PA_other", Identity: "other"},
+		}, nil)
+		svc.store.LoadRoomInternalReturns(&service.RoomInternal{
+			MaxDuration: 60,
+			MaxSubscribeBitrates: map[livekit.ParticipantID]uint64{
+				"PA_current": 1000000,
+				"PA_other":   2000000,
+				"PA_left":    3000000,
+			},
+		}, nil)
+
+		ctx := service.WithRoomSettings(service.WithGrants(context.Background(), grant), &service.RoomSettings{
+			MaxSubscribeBitrate: &maxSubscribeBitrate,
+		})
+		_, err := svc.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+			Room:     "testroom",
+			Identity: "user",
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, svc.store.StoreRoomInternalCallCount())
+		_, roomName, internal := svc.store.StoreRoomInternalArgsForCall(0)
+		require.Equal(t, livekit.RoomName("testroom"), roomName)
+		require.Equal(t, uint32(60), internal.MaxDuration)
+		require.Equal(t, map[livekit.ParticipantID]uint64{
+			"PA_current": 500000,
+			"PA_other":   2000000,
+		}, internal.MaxSubscribeBitrates)
+
+		// stored before the RTC node is asked to apply it
+		require.Equal(t, 1, svc.router.WriteParticipantRTCCallCount())
+	})
+
+	t.Run("not stored without the header", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadParticipantReturns(participant, nil)
+
+		_, err := svc.UpdateParticipant(service.WithGrants(context.Background(), grant), &livekit.UpdateParticipantRequest{
+			Room:     "testroom",
+			Identity: "user",
+		})
+		require.NoError(t, err)
+		require.Zero(t, svc.store.StoreRoomInternalCallCount())
+	})
+
+	t.Run("missing permissions", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadParticipantReturns(participant, nil)
+
+		ctx := service.WithRoomSettings(service.WithGrants(context.Background(), &auth.ClaimGrants{
+			Video: &auth.VideoGrant{Room: "testroom"},
+		}), &service.RoomSettings{MaxSubscribeBitrate: &maxSubscribeBitrate})
+		_, err := svc.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{
+			Room:     "testroom",
+			Identity: "user",
+		})
+		require.Error(t, err)
+		require.Zero(t, svc.store.StoreRoomInternalCallCount())
+		require.Zero(t, svc.router.WriteParticipantRTCCallCount())
+	})
+}
+
+func TestCreateRoomLockRequiresAdmin(t *testing.T) {
+	locked, unlocked := true, false
+
+	t.Run("create permission only", func(t *testing.T) {
+		svc := newTestRoomService()
+		ctx := service.WithGrants(context.Background(), &auth.ClaimGrants{
+			Video: &auth.VideoGrant{RoomCreate: true},
+		})
+		_, err := svc.CreateRoom(service.WithRoomSettings(ctx, &service.RoomSettings{Locked: &unlocked}), &livekit.CreateRoomRequest{Name: "testroom"})
+		var twErr twirp.Error
+		require.ErrorAs(t, err, &twErr)
+		require.Equal(t, twirp.Unauthenticated, twErr.Code())
+		require.Zero(t, svc.allocator.CreateRoomCallCount())
+
+		_, err = svc.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "testroom"})
+		require.NoError(t, err)
+	})
+
+	t.Run("admin of the room", func(t *testing.T) {
+		svc := newTestRoomService()
+		ctx := service.WithGrants(context.Background(), &auth.ClaimGrants{
+			Video: &auth.VideoGrant{RoomCreate: true, RoomAdmin: true, Room: "testroom"},
+		})
+		_, err := svc.CreateRoom(service.WithRoomSettings(ctx, &service.RoomSettings{Locked: &locked}), &livekit.CreateRoomRequest{Name: "testroom"})
+		require.NoError(t, err)
+
+		_, err = svc.CreateRoom(service.WithRoomSettings(ctx, &service.RoomSettings{Locked: &locked}), &livekit.CreateRoomRequest{Name: "otherroom"})
+		require.Error(t, err)
+	})
+}
+
+func TestApproveParticipant(t *testing.T) {
+	grant := &auth.ClaimGrants{
+		Video: &auth.VideoGrant{
+			RoomAdmin: true,
+			Room:      "testroom",
+		},
+	}
+	ctx := service.WithGrants(context.Background(), grant)
+	approved, denied := true, false
+
+	t.Run("records the decision for the waiting participant", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadPendingParticipantReturns(&service.PendingParticipant{Identity: "guest", WaitingSince: 10}, nil)
+
+		res, err := svc.UpdateParticipant(service.WithRoomSettings(ctx, &service.RoomSettings{ApproveParticipant: &approved}), &livekit.UpdateParticipantRequest{
+			Room:     "testroom",
+			Identity: "guest",
+		})
+		require.NoError(t, err)
+		require.Equal(t, livekit.ParticipantInfo_JOINING, res.State)
+
+		require.Equal(t, 1, svc.store.StorePendingParticipantCallCount())
+		_, roomName, pending := svc.store.StorePendingParticipantArgsForCall(0)
+		require.Equal(t, livekit.RoomName("testroom"), roomName)
+		require.NotNil(t, pending.Approved)
+		require.True(t, *pending.Approved)
+		// not routed to an RTC node, the participant hasn't joined yet
+		require.Zero(t, svc.router.WriteParticipantRTCCallCount())
+	})
+
+	t.Run("participant not waiting", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadPendingParticipantReturns(nil, service.ErrParticipantNotFound)
+
+		_, err := svc.UpdateParticipant(service.WithRoomSettings(ctx, &service.RoomSettings{ApproveParticipant: &denied}), &livekit.UpdateParticipantRequest{
+			Room:     "testroom",
+			Identity: "guest",
+		})
+		require.ErrorIs(t, err, service.ErrParticipantNotFound)
+		require.Zero(t, svc.store.StorePendingParticipantCallCount())
+	})
+
+	t.Run("lists waiting participants", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.ListPendingParticipantsReturns([]*service.PendingParticipant{{Identity: "guest"}}, nil)
+
+		res, err := svc.ListParticipants(service.WithRoomSettings(ctx, &service.RoomSettings{PendingParticipants: true}), &livekit.ListParticipantsRequest{
+			Room: "testroom",
+		})
+		require.NoError(t, err)
+		require.Len(t, res.Participants, 1)
+		require.Equal(t, "guest", res.Participants[0].Identity)
+		require.Zero(t, svc.store.ListParticipantsCallCount())
+	})
+}
+
+func TestUpdateSubscriptionsLayerCap(t *testing.T) {
+	grant := &auth.ClaimGrants{
+		Video: &auth.VideoGrant{
+			RoomAdmin: true,
+			Room:      "testroom",
+		},
+	}
+	participant := &livekit.ParticipantInfo{Sid: "PA_current", Identity: "user"}
+	thumbnail := &types.SubscriptionLayerCap{MaxSpatialLayer: 0, MaxTemporalLayer: 1}
+	previous := &types.SubscriptionLayerCap{MaxSpatialLayer: 1, MaxTemporalLayer: -1}
+
+	t.Run("stored for the requested tracks", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadParticipantReturns(participant, nil)
+		svc.store.ListParticipantsReturns([]*livekit.ParticipantInfo{participant}, nil)
+		svc.store.LoadRoomInternalReturns(&service.RoomInternal{
+			SubscriptionLayerCaps: map[livekit.ParticipantID]map[livekit.TrackID]*types.SubscriptionLayerCap{
+				"PA_current": {"TR_kept": previous, "TR_a": previous},
+				"PA_left":    {"TR_a": previous},
+			},
+		}, nil)
+
+		ctx := service.WithRoomSettings(service.WithGrants(context.Background(), grant), &service.RoomSettings{
+			SubscriptionLayerCap:    thumbnail,
+			SubscriptionLayerCapSet: true,
+		})
+		_, err := svc.UpdateSubscriptions(ctx, &livekit.UpdateSubscriptionsRequest{
+			Room:      "testroom",
+			Identity:  "user",
+			TrackSids: []string{"TR_a"},
+			ParticipantTracks: []*livekit.ParticipantTracks{
+				{ParticipantSid: "PA_pub", TrackSids: []string{"TR_b"}},
+			},
+			Subscribe: true,
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, 1, svc.store.StoreRoomInternalCallCount())
+		_, _, internal := svc.store.StoreRoomInternalArgsForCall(0)
+		require.Equal(t, map[livekit.ParticipantID]map[livekit.TrackID]*types.SubscriptionLayerCap{
+			"PA_current": {"TR_kept": previous, "TR_a": thumbnail, "TR_b": thumbnail},
+		}, internal.SubscriptionLayerCaps)
+		require.Equal(t, 1, svc.router.WriteParticipantRTCCallCount())
+	})
+
+	t.Run("not stored without the header", func(t *testing.T) {
+		svc := newTestRoomService()
+		svc.store.LoadParticipantReturns(participant, nil)
+
+		_, err := svc.UpdateSubscriptions(service.WithGrants(context.Background(), grant), &livekit.UpdateSubscriptionsRequest{
+			Room:      "testroom",
+			Identity:  "user",
+			TrackSids: []string{"TR_a"},
+			Subscribe: true,
+		})
+		require.NoError(t, err)
+		require.Zero(t, svc.store.StoreRoomInternalCallCount())
+	})
 }
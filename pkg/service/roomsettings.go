@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/pkg/errors"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/sfu"
+)
+
+const (
+	// EnabledCodecsHeader carries a comma separated codec allow-list for CreateRoom, i.e. "video/h264,audio/opus"
+	EnabledCodecsHeader = "X-LiveKit-Enabled-Codecs"
+	// MaxDurationHeader carries a per-room max duration for CreateRoom, either as a duration string or in seconds
+	MaxDurationHeader = "X-LiveKit-Max-Duration"
+	// ICECandidateTypesHeader carries the candidate types accepted from participants of the room for CreateRoom,
+	// i.e. "relay" to force all media through TURN
+	ICECandidateTypesHeader = "X-LiveKit-ICE-Candidate-Types"
+	// MaxForwardedAudioTracksHeader carries the number of audio tracks forwarded to each subscriber of the room for
+	// CreateRoom, overriding audio.max_forwarded_tracks. 0 for no limit
+	MaxForwardedAudioTracksHeader = "X-LiveKit-Max-Forwarded-Audio-Tracks"
+	// BlockRejoinDurationHeader carries how long participants removed from the room are refused when rejoining for
+	// CreateRoom, overriding room.block_rejoin_duration. Either a duration string or in seconds, 0 lets them rejoin
+	// right away
+	BlockRejoinDurationHeader = "X-LiveKit-Block-Rejoin-Duration"
+	// RoomLockedHeader locks or unlocks the room for CreateRoom and UpdateRoomMetadata, "true" or "false". Both
+	// require room admin for the room when it is set. Participants already in a locked room can reconnect, new ones
+	// are refused
+	RoomLockedHeader = "X-LiveKit-Room-Locked"
+	// RequireApprovalHeader makes participants of the room wait for a moderator to approve them for CreateRoom,
+	// "true" or "false". Room admins join right away
+	RequireApprovalHeader = "X-LiveKit-Require-Approval"
+	// ApproveParticipantHeader approves ("true") or denies ("false") a waiting participant for UpdateParticipant
+	ApproveParticipantHeader = "X-LiveKit-Approve-Participant"
+	// PendingParticipantsHeader lists the participants waiting for approval instead for ListParticipants, when "true"
+	PendingParticipantsHeader = "X-LiveKit-Pending-Participants"
+	// MaxSubscribeBitrateHeader carries a cap on the bitrate sent to the participant for UpdateParticipant, in bps.
+	// 0 removes a cap set previously
+	MaxSubscribeBitrateHeader = "X-LiveKit-Max-Subscribe-Bitrate"
+	// SubscriptionLayersHeader carries a cap on the video layers forwarded for the tracks of UpdateSubscriptions,
+	// i.e. "spatial=0,temporal=1" or "width=160,height=90". "none" removes a cap set previously
+	SubscriptionLayersHeader = "X-LiveKit-Subscription-Layers"
+	// ListRooms pages through rooms when PageLimitHeader or PageTokenHeader is set, with the token of the next page
+	// returned in NextPageTokenHeader. Rooms can be filtered by RoomNamePrefixHeader, CreatedAfterHeader (unix time in
+	// seconds) and MinParticipantsHeader
+	PageLimitHeader       = "X-LiveKit-Page-Limit"
+	PageTokenHeader       = "X-LiveKit-Page-Token"
+	NextPageTokenHeader   = "X-LiveKit-Next-Page-Token"
+	RoomNamePrefixHeader  = "X-LiveKit-Room-Name-Prefix"
+	CreatedAfterHeader    = "X-LiveKit-Created-After"
+	MinParticipantsHeader = "X-LiveKit-Min-Participants"
+	// PublishersOnlyHeader limits ListParticipants to participants publishing tracks when "true"
+	PublishersOnlyHeader = "X-LiveKit-Publishers-Only"
+)
+
+type roomSettingsKey struct{}
+
+// RoomSettings are the RoomService request fields that the requests of livekit/protocol cannot carry, read from
+// the X-LiveKit-* headers. Fields are nil when their header isn't set
+type RoomSettings struct {
+	// CreateRoom
+	EnabledCodecs           []*livekit.Codec
+	MaxDuration             time.Duration
+	ICECandidateTypes       []string
+	MaxForwardedAudioTracks *int
+	RequireApproval         *bool
+	BlockRejoinDuration     *time.Duration
+	// CreateRoom and UpdateRoomMetadata
+	Locked *bool
+
+	// UpdateParticipant
+	ApproveParticipant  *bool
+	MaxSubscribeBitrate *uint64
+
+	// UpdateSubscriptions, a nil SubscriptionLayerCap removes caps set previously when SubscriptionLayerCapSet
+	SubscriptionLayerCap    *types.SubscriptionLayerCap
+	SubscriptionLayerCapSet bool
+
+	// ListRooms and ListParticipants
+	ListRoomsOptions    *ListRoomsOptions
+	PendingParticipants bool
+	PublishersOnly      bool
+}
+
+// RoomSettingsMiddleware reads RoomSettings from the X-LiveKit-* headers, rejecting requests with invalid values
+func RoomSettingsMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	settings, err := ParseRoomSettings(r.Header)
+	if err != nil {
+		handleError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	next(w, r.WithContext(WithRoomSettings(r.Context(), settings)))
+}
+
+func ParseRoomSettings(header http.Header) (*RoomSettings, error) {
+	settings := &RoomSettings{}
+	var listRoomsOptions ListRoomsOptions
+	for _, name := range []string{
+		EnabledCodecsHeader, MaxDurationHeader, ICECandidateTypesHeader, MaxForwardedAudioTracksHeader,
+		RequireApprovalHeader, BlockRejoinDurationHeader, RoomLockedHeader, ApproveParticipantHeader,
+		MaxSubscribeBitrateHeader, SubscriptionLayersHeader, PageLimitHeader, PageTokenHeader, RoomNamePrefixHeader,
+		CreatedAfterHeader, MinParticipantsHeader, PendingParticipantsHeader, PublishersOnlyHeader,
+	} {
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" {
+			continue
+		}
+
+		var err error
+		switch name {
+		case EnabledCodecsHeader:
+			for _, mime := range splitList(value) {
+				settings.EnabledCodecs = append(settings.EnabledCodecs, &livekit.Codec{Mime: mime})
+			}
+		case MaxDurationHeader:
+			settings.MaxDuration, err = parseMaxDuration(value)
+		case ICECandidateTypesHeader:
+			for _, t := range splitList(value) {
+				settings.ICECandidateTypes = append(settings.ICECandidateTypes, strings.ToLower(t))
+			}
+		case MaxForwardedAudioTracksHeader:
+			var maxTracks uint64
+			if maxTracks, err = strconv.ParseUint(value, 10, 31); err == nil {
+				n := int(maxTracks)
+				settings.MaxForwardedAudioTracks = &n
+			}
+		case RequireApprovalHeader:
+			settings.RequireApproval, err = parseBoolSetting(value)
+		case BlockRejoinDurationHeader:
+			var duration time.Duration
+			if duration, err = parseBlockRejoinDuration(value); err == nil {
+				settings.BlockRejoinDuration = &duration
+			}
+		case RoomLockedHeader:
+			settings.Locked, err = parseBoolSetting(value)
+		case ApproveParticipantHeader:
+			settings.ApproveParticipant, err = parseBoolSetting(value)
+		case MaxSubscribeBitrateHeader:
+			var maxSubscribeBitrate uint64
+			if maxSubscribeBitrate, err = strconv.ParseUint(value, 10, 64); err == nil {
+				settings.MaxSubscribeBitrate = &maxSubscribeBitrate
+			}
+		case SubscriptionLayersHeader:
+			settings.SubscriptionLayerCap, err = parseSubscriptionLayerCap(value)
+			settings.SubscriptionLayerCapSet = err == nil
+		case PageLimitHeader:
+			var limit uint64
+			limit, err = strconv.ParseUint(value, 10, 31)
+			listRoomsOptions.Limit = int(limit)
+			settings.ListRoomsOptions = &listRoomsOptions
+		case PageTokenHeader:
+			listRoomsOptions.PageToken = value
+			settings.ListRoomsOptions = &listRoomsOptions
+		case RoomNamePrefixHeader:
+			listRoomsOptions.NamePrefix = value
+			settings.ListRoomsOptions = &listRoomsOptions
+		case CreatedAfterHeader:
+			listRoomsOptions.CreatedAfter, err = strconv.ParseInt(value, 10, 64)
+			settings.ListRoomsOptions = &listRoomsOptions
+		case MinParticipantsHeader:
+			var minParticipants uint64
+			minParticipants, err = strconv.ParseUint(value, 10, 32)
+			listRoomsOptions.MinParticipants = uint32(minParticipants)
+			settings.ListRoomsOptions = &listRoomsOptions
+		case PendingParticipantsHeader:
+			settings.PendingParticipants, err = strconv.ParseBool(value)
+		case PublishersOnlyHeader:
+			settings.PublishersOnly, err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			return nil, errors.Errorf("invalid %s: %s", name, value)
+		}
+	}
+	return settings, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func parseBoolSetting(value string) (*bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func parseMaxDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil && d <= 0 {
+		err = errors.New("max duration must be positive")
+	}
+	return d, err
+}
+
+func parseBlockRejoinDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil && (d < 0 || d > math.MaxUint32*time.Second) {
+		err = errors.New("block rejoin duration out of range")
+	}
+	return d, err
+}
+
+func parseSubscriptionLayerCap(value string) (*types.SubscriptionLayerCap, error) {
+	if strings.EqualFold(strings.TrimSpace(value), "none") {
+		return nil, nil
+	}
+
+	layerCap := &types.SubscriptionLayerCap{
+		MaxSpatialLayer:  sfu.InvalidLayerSpatial,
+		MaxTemporalLayer: sfu.InvalidLayerTemporal,
+	}
+	for _, field := range strings.Split(value, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("expected key=value, got %q", field)
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(kv[1]), 10, 16)
+		if err != nil {
+			return nil, err
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "spatial":
+			layerCap.MaxSpatialLayer = int32(n)
+		case "temporal":
+			layerCap.MaxTemporalLayer = int32(n)
+		case "width":
+			layerCap.Width = uint32(n)
+		case "height":
+			layerCap.Height = uint32(n)
+		default:
+			return nil, errors.Errorf("unknown key %q", kv[0])
+		}
+	}
+	return layerCap, nil
+}
+
+// WithRoomSettings sets the settings of the request, nil hides settings of an outer context
+func WithRoomSettings(ctx context.Context, settings *RoomSettings) context.Context {
+	return context.WithValue(ctx, roomSettingsKey{}, settings)
+}
+
+// GetRoomSettings returns the settings of the request, with no fields set if there are none
+func GetRoomSettings(ctx context.Context) *RoomSettings {
+	if settings, _ := ctx.Value(roomSettingsKey{}).(*RoomSettings); settings != nil {
+		return settings
+	}
+	return &RoomSettings{}
+}
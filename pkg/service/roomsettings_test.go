@@ -0,0 +1,119 @@
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestRoomSettingsMiddleware(t *testing.T) {
+	serve := func(headers map[string]string) (*httptest.ResponseRecorder, *service.RoomSettings) {
+		var settings *service.RoomSettings
+		r := httptest.NewRequest(http.MethodPost, "/twirp/livekit.RoomService/CreateRoom", nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		service.RoomSettingsMiddleware(w, r, func(w http.ResponseWriter, r *http.Request) {
+			settings = service.GetRoomSettings(r.Context())
+		})
+		return w, settings
+	}
+
+	t.Run("no headers", func(t *testing.T) {
+		_, settings := serve(nil)
+		require.Equal(t, &service.RoomSettings{}, settings)
+	})
+
+	t.Run("room creation", func(t *testing.T) {
+		_, settings := serve(map[string]string{
+			service.EnabledCodecsHeader:           "video/h264, audio/opus",
+			service.MaxDurationHeader:             "3600",
+			service.ICECandidateTypesHeader:       "Relay",
+			service.MaxForwardedAudioTracksHeader: "0",
+			service.RequireApprovalHeader:         "true",
+			service.BlockRejoinDurationHeader:     "10m",
+			service.RoomLockedHeader:              "false",
+		})
+		require.Equal(t, []*livekit.Codec{{Mime: "video/h264"}, {Mime: "audio/opus"}}, settings.EnabledCodecs)
+		require.Equal(t, time.Hour, settings.MaxDuration)
+		require.Equal(t, []string{"relay"}, settings.ICECandidateTypes)
+		require.NotNil(t, settings.MaxForwardedAudioTracks)
+		require.Zero(t, *settings.MaxForwardedAudioTracks)
+		require.NotNil(t, settings.RequireApproval)
+		require.True(t, *settings.RequireApproval)
+		require.NotNil(t, settings.BlockRejoinDuration)
+		require.Equal(t, 10*time.Minute, *settings.BlockRejoinDuration)
+		require.NotNil(t, settings.Locked)
+		require.False(t, *settings.Locked)
+	})
+
+	t.Run("participant updates", func(t *testing.T) {
+		_, settings := serve(map[string]string{
+			service.ApproveParticipantHeader:  "true",
+			service.MaxSubscribeBitrateHeader: "0",
+		})
+		require.NotNil(t, settings.ApproveParticipant)
+		require.True(t, *settings.ApproveParticipant)
+		// removes the cap
+		require.NotNil(t, settings.MaxSubscribeBitrate)
+		require.Zero(t, *settings.MaxSubscribeBitrate)
+		require.Nil(t, settings.RequireApproval)
+		require.False(t, settings.SubscriptionLayerCapSet)
+	})
+
+	t.Run("subscription layers", func(t *testing.T) {
+		_, settings := serve(map[string]string{service.SubscriptionLayersHeader: "spatial=0, temporal=1"})
+		require.True(t, settings.SubscriptionLayerCapSet)
+		require.Equal(t, &types.SubscriptionLayerCap{MaxSpatialLayer: 0, MaxTemporalLayer: 1}, settings.SubscriptionLayerCap)
+
+		_, settings = serve(map[string]string{service.SubscriptionLayersHeader: "width=160,height=90"})
+		require.Equal(t, &types.SubscriptionLayerCap{MaxSpatialLayer: -1, MaxTemporalLayer: -1, Width: 160, Height: 90}, settings.SubscriptionLayerCap)
+
+		// removes the cap
+		_, settings = serve(map[string]string{service.SubscriptionLayersHeader: "none"})
+		require.True(t, settings.SubscriptionLayerCapSet)
+		require.Nil(t, settings.SubscriptionLayerCap)
+	})
+
+	t.Run("listing", func(t *testing.T) {
+		_, settings := serve(map[string]string{
+			service.PageLimitHeader:           "10",
+			service.RoomNamePrefixHeader:      "game-",
+			service.PendingParticipantsHeader: "true",
+			service.PublishersOnlyHeader:      "true",
+		})
+		require.Equal(t, &service.ListRoomsOptions{Limit: 10, NamePrefix: "game-"}, settings.ListRoomsOptions)
+		require.True(t, settings.PendingParticipants)
+		require.True(t, settings.PublishersOnly)
+	})
+
+	t.Run("invalid values are rejected", func(t *testing.T) {
+		for header, value := range map[string]string{
+			service.MaxDurationHeader:             "-1h",
+			service.MaxForwardedAudioTracksHeader: "all",
+			service.BlockRejoinDurationHeader:     "-5m",
+			service.RoomLockedHeader:              "closed",
+			service.RequireApprovalHeader:         "sometimes",
+			service.MaxSubscribeBitrateHeader:     "2mbps",
+			service.SubscriptionLayersHeader:      "quality=low",
+			service.MinParticipantsHeader:         "many",
+		} {
+			r := httptest.NewRequest(http.MethodPost, "/twirp/livekit.RoomService/CreateRoom", nil)
+			r.Header.Set(header, value)
+			w := httptest.NewRecorder()
+			service.RoomSettingsMiddleware(w, r, func(w http.ResponseWriter, r *http.Request) {
+				t.Fatalf("%s: %s should be rejected", header, value)
+			})
+			require.Equal(t, http.StatusBadRequest, w.Code, header)
+			require.Contains(t, w.Body.String(), header)
+		}
+	})
+}
@@ -2,11 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sebest/xff"
 
@@ -20,13 +22,18 @@ import (
 	"github.com/livekit/livekit-server/pkg/routing/selector"
 	"github.com/livekit/livekit-server/pkg/rtc"
 	"github.com/livekit/livekit-server/pkg/rtc/types"
+	"github.com/livekit/livekit-server/pkg/telemetry"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
+// how often participants waiting for approval check whether a moderator has decided
+const approvalCheckInterval = 500 * time.Millisecond
+
 type RTCService struct {
 	router        routing.MessageRouter
 	roomAllocator RoomAllocator
-	store         ServiceStore
+	store         ObjectStore
+	telemetry     telemetry.TelemetryService
 	upgrader      websocket.Upgrader
 	currentNode   routing.LocalNode
 	config        *config.Config
@@ -38,14 +45,16 @@ type RTCService struct {
 func NewRTCService(
 	conf *config.Config,
 	ra RoomAllocator,
-	store ServiceStore,
+	store ObjectStore,
 	router routing.MessageRouter,
 	currentNode routing.LocalNode,
+	telemetry telemetry.TelemetryService,
 ) *RTCService {
 	s := &RTCService{
 		router:        router,
 		roomAllocator: ra,
 		store:         store,
+		telemetry:     telemetry,
 		upgrader:      websocket.Upgrader{},
 		currentNode:   currentNode,
 		config:        conf,
@@ -104,24 +113,59 @@ func (s *RTCService) validate(r *http.Request) (livekit.RoomName, routing.Partic
 		claims.Identity += "#" + publishParam
 	}
 
+	blocked, err := s.store.IsParticipantBlocked(r.Context(), roomName, livekit.ParticipantIdentity(claims.Identity))
+	if err != nil {
+		return "", routing.ParticipantInit{}, http.StatusInternalServerError, err
+	}
+	if blocked {
+		return "", routing.ParticipantInit{}, http.StatusForbidden, ErrParticipantBlocked
+	}
+	if err = s.checkDraining(r.Context(), roomName, livekit.ParticipantIdentity(claims.Identity)); err != nil {
+		if errors.Is(err, ErrNodeDraining) {
+			return "", routing.ParticipantInit{}, http.StatusServiceUnavailable, err
+		}
+		return "", routing.ParticipantInit{}, http.StatusInternalServerError, err
+	}
+	if err = s.checkRoomLocked(r.Context(), roomName, livekit.ParticipantIdentity(claims.Identity)); err != nil {
+		if errors.Is(err, ErrRoomLocked) {
+			return "", routing.ParticipantInit{}, http.StatusLocked, err
+		}
+		return "", routing.ParticipantInit{}, http.StatusInternalServerError, err
+	}
+
+	var foundNode *livekit.Node
 	if router, ok := s.router.(routing.Router); ok {
-		if foundNode, err := router.GetNodeForRoom(r.Context(), roomName); err == nil {
+		if foundNode, err = router.GetNodeForRoom(r.Context(), roomName); err == nil {
 			if selector.LimitsReached(s.limits, foundNode.Stats) {
 				return "", routing.ParticipantInit{}, http.StatusServiceUnavailable, rtc.ErrLimitExceeded
 			}
+		} else {
+			foundNode = nil
+		}
+	}
+
+	apiKey := GetAPIKey(r.Context())
+	if limits, ok := s.config.LimitsPerKey[apiKey]; ok {
+		if err = checkJoinLimits(r.Context(), s.store, apiKey, limits, roomName, foundNode); err != nil {
+			var limitErr *KeyLimitError
+			if errors.As(err, &limitErr) {
+				return "", routing.ParticipantInit{}, http.StatusTooManyRequests, err
+			}
+			return "", routing.ParticipantInit{}, http.StatusInternalServerError, err
 		}
 	}
 
 	pi := routing.ParticipantInit{
-		Reconnect:     boolValue(reconnectParam),
-		Identity:      livekit.ParticipantIdentity(claims.Identity),
-		Name:          livekit.ParticipantName(claims.Name),
-		AutoSubscribe: true,
-		Metadata:      claims.Metadata,
-		Hidden:        claims.Video.Hidden,
-		Recorder:      claims.Video.Recorder,
-		Client:        s.ParseClientInfo(r),
-		Grants:        claims,
+		Reconnect:           boolValue(reconnectParam),
+		Identity:            livekit.ParticipantIdentity(claims.Identity),
+		Name:                livekit.ParticipantName(claims.Name),
+		AutoSubscribe:       true,
+		Metadata:            claims.Metadata,
+		Hidden:              claims.Video.Hidden,
+		Recorder:            claims.Video.Recorder,
+		Client:              s.ParseClientInfo(r),
+		Grants:              claims,
+		MaxSubscribeBitrate: GetMaxSubscribeBitrate(r.Context()),
 	}
 
 	if autoSubParam != "" {
@@ -132,6 +176,115 @@ func (s *RTCService) validate(r *http.Request) (livekit.RoomName, routing.Partic
 	return roomName, pi, http.StatusOK, nil
 }
 
+// checkDraining refuses new participants while the node drains, telling clients to connect through another node.
+// Participants already in a room can reconnect
+func (s *RTCService) checkDraining(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
+	if s.currentNode.State != livekit.NodeState_SHUTTING_DOWN {
+		return nil
+	}
+
+	_, err := s.store.LoadParticipant(ctx, roomName, identity)
+	if err == ErrParticipantNotFound {
+		return ErrNodeDraining
+	}
+	return err
+}
+
+// checkRoomLocked refuses new participants of a locked room. Participants already in the room can reconnect, and
+// tokens with room admin for the room can always join
+func (s *RTCService) checkRoomLocked(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) error {
+	internal, err := s.store.LoadRoomInternal(ctx, roomName)
+	if err != nil {
+		return err
+	}
+	if internal == nil || !internal.Locked || EnsureAdminPermission(ctx, roomName) == nil {
+		return nil
+	}
+
+	_, err = s.store.LoadParticipant(ctx, roomName, identity)
+	if err == ErrParticipantNotFound {
+		return ErrRoomLocked
+	}
+	return err
+}
+
+// requiresApproval is true for new participants of rooms requiring approval. Participants already in the room and
+// room admins join right away
+func (s *RTCService) requiresApproval(ctx context.Context, roomName livekit.RoomName, identity livekit.ParticipantIdentity) (bool, error) {
+	internal, err := s.store.LoadRoomInternal(ctx, roomName)
+	if err != nil {
+		return false, err
+	}
+	if internal == nil || !internal.RequireApproval || EnsureAdminPermission(ctx, roomName) == nil {
+		return false, nil
+	}
+
+	_, err = s.store.LoadParticipant(ctx, roomName, identity)
+	if err == ErrParticipantNotFound {
+		return true, nil
+	}
+	return false, err
+}
+
+// waitForApproval lists the participant as pending until a moderator approves or denies it through UpdateParticipant,
+// or room.approval_timeout passes. Pending participants are not in the room, and don't count against its limits
+func (s *RTCService) waitForApproval(ctx context.Context, conn *websocket.Conn, room *livekit.Room, pi routing.ParticipantInit) error {
+	roomName := livekit.RoomName(room.Name)
+	pending := &PendingParticipant{
+		Identity:     pi.Identity,
+		Name:         pi.Name,
+		Metadata:     pi.Metadata,
+		WaitingSince: time.Now().Unix(),
+	}
+	if err := s.store.StorePendingParticipant(ctx, roomName, pending); err != nil {
+		return err
+	}
+	defer func() {
+		_ = s.store.DeletePendingParticipant(context.Background(), roomName, pi.Identity)
+	}()
+	s.telemetry.ParticipantPending(ctx, room, pending.ToProto())
+
+	var timeout <-chan time.Time
+	if d := s.config.Room.ApprovalTimeout.Duration(); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	ticker := time.NewTicker(approvalCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timeout:
+			return ErrApprovalTimeout
+		case <-ticker.C:
+		}
+
+		// stop waiting once the participant has given up
+		if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(approvalCheckInterval)); err != nil {
+			return err
+		}
+
+		pending, err := s.store.LoadPendingParticipant(ctx, roomName, pi.Identity)
+		if err == ErrParticipantNotFound {
+			// the room has been deleted
+			return ErrNotApproved
+		} else if err != nil {
+			return err
+		}
+		if pending.Approved != nil {
+			if *pending.Approved {
+				return nil
+			}
+			return ErrNotApproved
+		}
+	}
+}
+
+func closeWithReason(conn *websocket.Conn, code int, reason string) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	_ = conn.Close()
+}
+
 func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// reject non websocket requests
 	if !websocket.IsWebSocketUpgrade(r) {
@@ -140,11 +293,18 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	joinStartedAt := GetRequestStartedAt(r.Context())
+	if joinStartedAt.IsZero() {
+		joinStartedAt = time.Now()
+	}
 	roomName, pi, code, err := s.validate(r)
 	if err != nil {
 		handleError(w, code, err.Error())
 		return
 	}
+	pi.JoinStartedAt = joinStartedAt
+	pi.TokenValidatedAt = time.Now()
+	prometheus.ObserveTokenValidation(pi.TokenValidatedAt.Sub(pi.JoinStartedAt))
 
 	// when autocreate is disabled, we'll check to ensure it's already created
 	if !s.config.Room.AutoCreate {
@@ -158,19 +318,50 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// create room if it doesn't exist, also assigns an RTC node for the room
-	rm, err := s.roomAllocator.CreateRoom(r.Context(), &livekit.CreateRoomRequest{Name: string(roomName)})
+	// create room if it doesn't exist, also assigns an RTC node for the room. Only RoomService honors room settings,
+	// once it checked the caller may change them, participants joining must not change an existing room's settings
+	rm, err := s.roomAllocator.CreateRoom(WithRoomSettings(r.Context(), nil), &livekit.CreateRoomRequest{Name: string(roomName)})
 	if err != nil {
 		prometheus.ServiceOperationCounter.WithLabelValues("signal_ws", "error", "create_room").Add(1)
 		handleError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	// participants of rooms requiring approval wait on an upgraded connection, their session is only started once
+	// a moderator lets them in
+	var conn *websocket.Conn
+	requireApproval, err := s.requiresApproval(r.Context(), roomName, pi.Identity)
+	if err != nil {
+		handleError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if requireApproval {
+		if conn, err = s.upgrader.Upgrade(w, r, nil); err != nil {
+			prometheus.ServiceOperationCounter.WithLabelValues("signal_ws", "error", "upgrade").Add(1)
+			logger.Warnw("could not upgrade to WS", err, "room", roomName, "participant", pi.Identity)
+			handleError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err = s.waitForApproval(r.Context(), conn, rm, pi); err != nil {
+			prometheus.ServiceOperationCounter.WithLabelValues("signal_ws", "error", "not_approved").Add(1)
+			logger.Infow("participant not let into room", "room", roomName, "participant", pi.Identity, "reason", err)
+			closeWithReason(conn, websocket.ClosePolicyViolation, err.Error())
+			return
+		}
+		// time spent waiting for a moderator is not join latency
+		pi.JoinStartedAt = time.Now()
+		pi.TokenValidatedAt = pi.JoinStartedAt
+	}
+
 	// this needs to be started first *before* using router functions on this node
 	connId, reqSink, resSource, err := s.router.StartParticipantSignal(r.Context(), roomName, pi)
 	if err != nil {
 		prometheus.ServiceOperationCounter.WithLabelValues("signal_ws", "error", "start_signal").Add(1)
-		handleError(w, http.StatusInternalServerError, "could not start session: "+err.Error())
+		if conn != nil {
+			closeWithReason(conn, websocket.CloseInternalServerErr, "could not start session: "+err.Error())
+		} else {
+			handleError(w, http.StatusInternalServerError, "could not start session: "+err.Error())
+		}
 		return
 	}
 
@@ -187,12 +378,13 @@ func (s *RTCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// upgrade only once the basics are good to go
-	conn, err := s.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		prometheus.ServiceOperationCounter.WithLabelValues("signal_ws", "error", "upgrade").Add(1)
-		pLogger.Warnw("could not upgrade to WS", err)
-		handleError(w, http.StatusInternalServerError, err.Error())
-		return
+	if conn == nil {
+		if conn, err = s.upgrader.Upgrade(w, r, nil); err != nil {
+			prometheus.ServiceOperationCounter.WithLabelValues("signal_ws", "error", "upgrade").Add(1)
+			pLogger.Warnw("could not upgrade to WS", err)
+			handleError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 	}
 	sigConn := NewWSSignalConnection(conn)
 	if types.ProtocolVersion(pi.Client.Protocol).SupportsProtobuf() {
@@ -0,0 +1,373 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+	"github.com/twitchtv/twirp"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/routing/routingfakes"
+	"github.com/livekit/livekit-server/pkg/service"
+	"github.com/livekit/livekit-server/pkg/service/servicefakes"
+	"github.com/livekit/livekit-server/pkg/telemetry/telemetryfakes"
+)
+
+func TestValidateKeyLimits(t *testing.T) {
+	validate := func(t *testing.T, limits config.KeyLimitConfig, store *servicefakes.FakeObjectStore, stats *livekit.NodeStats) *httptest.ResponseRecorder {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		conf.LimitsPerKey = map[string]config.KeyLimitConfig{"limited": limits}
+
+		node, err := routing.NewLocalNode(conf)
+		require.NoError(t, err)
+		node.Stats = stats
+		router := &routingfakes.FakeRouter{}
+		router.GetNodeForRoomReturns(node, nil)
+
+		s := service.NewRTCService(conf, &servicefakes.FakeRoomAllocator{}, store, router, node, &telemetryfakes.FakeTelemetryService{})
+
+		ctx := service.WithGrants(context.Background(), &auth.ClaimGrants{
+			Identity: "user",
+			Video:    &auth.VideoGrant{RoomJoin: true, Room: "myroom"},
+		})
+		ctx = service.WithAPIKey(ctx, "limited")
+		r := httptest.NewRequest(http.MethodGet, "/rtc/validate", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		s.Validate(w, r)
+		return w
+	}
+
+	t.Run("max participants per room", func(t *testing.T) {
+		store := &servicefakes.FakeObjectStore{}
+		store.ListParticipantsReturns([]*livekit.ParticipantInfo{{Identity: "a"}, {Identity: "b"}}, nil)
+
+		w := validate(t, config.KeyLimitConfig{MaxParticipantsPerRoom: 2}, store, nil)
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.Contains(t, w.Body.String(), "max_participants_per_room")
+
+		w = validate(t, config.KeyLimitConfig{MaxParticipantsPerRoom: 3}, store, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("max rooms only counts rooms created by the key", func(t *testing.T) {
+		store := &servicefakes.FakeObjectStore{}
+		store.LoadRoomReturns(nil, service.ErrRoomNotFound)
+		store.CountRoomsCalls(func(_ context.Context, apiKey string) (int, error) {
+			if apiKey == "limited" {
+				return 1, nil
+			}
+			return 5, nil
+		})
+
+		w := validate(t, config.KeyLimitConfig{MaxRooms: 1}, store, nil)
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+
+		w = validate(t, config.KeyLimitConfig{MaxRooms: 2}, store, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		// joining an existing room is always allowed
+		store.LoadRoomReturns(&livekit.Room{Name: "myroom"}, nil)
+		w = validate(t, config.KeyLimitConfig{MaxRooms: 1}, store, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("bytes per sec", func(t *testing.T) {
+		stats := &livekit.NodeStats{BytesInPerSec: 600, BytesOutPerSec: 600}
+		w := validate(t, config.KeyLimitConfig{BytesPerSec: 1000}, &servicefakes.FakeObjectStore{}, stats)
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+}
+
+func TestValidateBlockedParticipant(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(node, nil)
+
+	store := &servicefakes.FakeObjectStore{}
+	s := service.NewRTCService(conf, &servicefakes.FakeRoomAllocator{}, store, router, node, &telemetryfakes.FakeTelemetryService{})
+
+	validate := func() *httptest.ResponseRecorder {
+		ctx := service.WithGrants(context.Background(), &auth.ClaimGrants{
+			Identity: "user",
+			Video:    &auth.VideoGrant{RoomJoin: true, Room: "myroom"},
+		})
+		r := httptest.NewRequest(http.MethodGet, "/rtc/validate", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		s.Validate(w, r)
+		return w
+	}
+
+	w := validate()
+	require.Equal(t, http.StatusOK, w.Code)
+
+	store.IsParticipantBlockedReturns(true, nil)
+	w = validate()
+	require.Equal(t, http.StatusForbidden, w.Code)
+	_, roomName, identity := store.IsParticipantBlockedArgsForCall(1)
+	require.Equal(t, livekit.RoomName("myroom"), roomName)
+	require.Equal(t, livekit.ParticipantIdentity("user"), identity)
+}
+
+func TestValidateLockedRoom(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(node, nil)
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadRoomInternalReturns(&service.RoomInternal{Locked: true}, nil)
+	store.LoadParticipantReturns(nil, service.ErrParticipantNotFound)
+	s := service.NewRTCService(conf, &servicefakes.FakeRoomAllocator{}, store, router, node, &telemetryfakes.FakeTelemetryService{})
+
+	validate := func(grant *auth.VideoGrant) *httptest.ResponseRecorder {
+		ctx := service.WithGrants(context.Background(), &auth.ClaimGrants{
+			Identity: "user",
+			Video:    grant,
+		})
+		r := httptest.NewRequest(http.MethodGet, "/rtc/validate", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		s.Validate(w, r)
+		return w
+	}
+
+	w := validate(&auth.VideoGrant{RoomJoin: true, Room: "myroom"})
+	require.Equal(t, http.StatusLocked, w.Code)
+
+	// admins of the room bypass the lock
+	w = validate(&auth.VideoGrant{RoomJoin: true, RoomAdmin: true, Room: "myroom"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// participants already in the room can reconnect
+	store.LoadParticipantReturns(&livekit.ParticipantInfo{Identity: "user"}, nil)
+	w = validate(&auth.VideoGrant{RoomJoin: true, Room: "myroom"})
+	require.Equal(t, http.StatusOK, w.Code)
+	_, roomName, identity := store.LoadParticipantArgsForCall(store.LoadParticipantCallCount() - 1)
+	require.Equal(t, livekit.RoomName("myroom"), roomName)
+	require.Equal(t, livekit.ParticipantIdentity("user"), identity)
+
+	store.LoadRoomInternalReturns(&service.RoomInternal{}, nil)
+	store.LoadParticipantReturns(nil, service.ErrParticipantNotFound)
+	w = validate(&auth.VideoGrant{RoomJoin: true, Room: "myroom"})
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestValidateDrainingNode(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+	router := &routingfakes.FakeRouter{}
+	router.GetNodeForRoomReturns(node, nil)
+
+	store := &servicefakes.FakeObjectStore{}
+	store.LoadParticipantReturns(nil, service.ErrParticipantNotFound)
+	s := service.NewRTCService(conf, &servicefakes.FakeRoomAllocator{}, store, router, node, &telemetryfakes.FakeTelemetryService{})
+
+	validate := func() *httptest.ResponseRecorder {
+		ctx := service.WithGrants(context.Background(), &auth.ClaimGrants{
+			Identity: "user",
+			Video:    &auth.VideoGrant{RoomJoin: true, Room: "myroom"},
+		})
+		r := httptest.NewRequest(http.MethodGet, "/rtc/validate", nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		s.Validate(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, validate().Code)
+
+	node.State = livekit.NodeState_SHUTTING_DOWN
+	w := validate()
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// participants already in the room can reconnect
+	store.LoadParticipantReturns(&livekit.ParticipantInfo{Identity: "user"}, nil)
+	require.Equal(t, http.StatusOK, validate().Code)
+}
+
+func TestWaitingRoom(t *testing.T) {
+	// connects a participant to a room requiring approval, returning the reason the connection was closed with
+	connect := func(t *testing.T, conf *config.Config, store *servicefakes.FakeObjectStore, router *routingfakes.FakeRouter) string {
+		node, err := routing.NewLocalNode(conf)
+		require.NoError(t, err)
+		router.GetNodeForRoomReturns(node, nil)
+		allocator := &servicefakes.FakeRoomAllocator{}
+		allocator.CreateRoomReturns(&livekit.Room{Name: "meeting"}, nil)
+		telemetry := &telemetryfakes.FakeTelemetryService{}
+		s := service.NewRTCService(conf, allocator, store, router, node, telemetry)
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := service.WithGrants(r.Context(), &auth.ClaimGrants{
+				Identity: "guest",
+				Video:    &auth.VideoGrant{RoomJoin: true, Room: "meeting"},
+			})
+			s.ServeHTTP(w, r.WithContext(ctx))
+		}))
+		defer server.Close()
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/rtc", nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, _, err = conn.ReadMessage()
+		var closeErr *websocket.CloseError
+		require.ErrorAs(t, err, &closeErr)
+
+		require.Equal(t, 1, telemetry.ParticipantPendingCallCount())
+		require.Equal(t, 1, store.StorePendingParticipantCallCount())
+		_, roomName, pending := store.StorePendingParticipantArgsForCall(0)
+		require.Equal(t, livekit.RoomName("meeting"), roomName)
+		require.Equal(t, livekit.ParticipantIdentity("guest"), pending.Identity)
+		// no longer pending once let in or turned away
+		require.Equal(t, 1, store.DeletePendingParticipantCallCount())
+		return closeErr.Text
+	}
+
+	newStore := func(approved *bool) *servicefakes.FakeObjectStore {
+		store := &servicefakes.FakeObjectStore{}
+		store.LoadRoomInternalReturns(&service.RoomInternal{RequireApproval: true}, nil)
+		store.LoadParticipantReturns(nil, service.ErrParticipantNotFound)
+		store.LoadPendingParticipantReturns(&service.PendingParticipant{Identity: "guest", Approved: approved}, nil)
+		return store
+	}
+
+	t.Run("approved participants start their session", func(t *testing.T) {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		approved := true
+		router := &routingfakes.FakeRouter{}
+		router.StartParticipantSignalReturns("", nil, nil, errors.New("no nodes"))
+
+		reason := connect(t, conf, newStore(&approved), router)
+		require.Equal(t, 1, router.StartParticipantSignalCallCount())
+		require.Contains(t, reason, "could not start session")
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		approved := false
+		router := &routingfakes.FakeRouter{}
+
+		reason := connect(t, conf, newStore(&approved), router)
+		require.Zero(t, router.StartParticipantSignalCallCount())
+		require.Equal(t, service.ErrNotApproved.Error(), reason)
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		conf.Room.ApprovalTimeout = config.Duration(100 * time.Millisecond)
+		router := &routingfakes.FakeRouter{}
+
+		reason := connect(t, conf, newStore(nil), router)
+		require.Zero(t, router.StartParticipantSignalCallCount())
+		require.Equal(t, service.ErrApprovalTimeout.Error(), reason)
+	})
+}
+
+func TestJoinOnlyTokenCannotChangeRoomSettings(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	node, err := routing.NewLocalNode(conf)
+	require.NoError(t, err)
+	joinOnly := &auth.ClaimGrants{
+		Identity: "guest",
+		Video:    &auth.VideoGrant{RoomJoin: true, Room: "meeting"},
+	}
+
+	// each setting, with the RoomService call honoring it
+	createRoom := func(svc *TestRoomService, ctx context.Context) error {
+		_, err := svc.CreateRoom(ctx, &livekit.CreateRoomRequest{Name: "meeting"})
+		return err
+	}
+	updateParticipant := func(svc *TestRoomService, ctx context.Context) error {
+		_, err := svc.UpdateParticipant(ctx, &livekit.UpdateParticipantRequest{Room: "meeting", Identity: "guest"})
+		return err
+	}
+	for _, setting := range []struct {
+		header  string
+		value   string
+		service func(svc *TestRoomService, ctx context.Context) error
+	}{
+		{service.EnabledCodecsHeader, "video/vp8", createRoom},
+		{service.MaxDurationHeader, "1h", createRoom},
+		{service.ICECandidateTypesHeader, "relay", createRoom},
+		{service.MaxForwardedAudioTracksHeader, "1", createRoom},
+		{service.RequireApprovalHeader, "false", createRoom},
+		{service.RoomLockedHeader, "true", createRoom},
+		{service.RoomLockedHeader, "false", func(svc *TestRoomService, ctx context.Context) error {
+			_, err := svc.UpdateRoomMetadata(ctx, &livekit.UpdateRoomMetadataRequest{Room: "meeting"})
+			return err
+		}},
+		{service.ApproveParticipantHeader, "true", updateParticipant},
+		{service.MaxSubscribeBitrateHeader, "0", updateParticipant},
+		{service.SubscriptionLayersHeader, "none", func(svc *TestRoomService, ctx context.Context) error {
+			_, err := svc.UpdateSubscriptions(ctx, &livekit.UpdateSubscriptionsRequest{Room: "meeting", Identity: "guest"})
+			return err
+		}},
+	} {
+		setting := setting
+		t.Run(setting.header+": "+setting.value, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/rtc", nil)
+			r.Header.Set("Connection", "upgrade")
+			r.Header.Set("Upgrade", "websocket")
+			r.Header.Set(setting.header, setting.value)
+			r = r.WithContext(service.WithGrants(r.Context(), joinOnly))
+			var ctx context.Context
+			service.RoomSettingsMiddleware(httptest.NewRecorder(), r, func(w http.ResponseWriter, r *http.Request) {
+				ctx = r.Context()
+			})
+			require.NotNil(t, ctx)
+
+			// joining creates the room without any of the settings
+			store := &servicefakes.FakeObjectStore{}
+			store.LoadParticipantReturns(nil, service.ErrParticipantNotFound)
+			router := &routingfakes.FakeRouter{}
+			router.GetNodeForRoomReturns(node, nil)
+			router.StartParticipantSignalReturns("", nil, nil, errors.New("no nodes"))
+			allocator := &servicefakes.FakeRoomAllocator{}
+			allocator.CreateRoomReturns(&livekit.Room{Name: "meeting"}, nil)
+			s := service.NewRTCService(conf, allocator, store, router, node, &telemetryfakes.FakeTelemetryService{})
+			s.ServeHTTP(httptest.NewRecorder(), r.WithContext(ctx))
+
+			require.Equal(t, 1, allocator.CreateRoomCallCount())
+			createCtx, _ := allocator.CreateRoomArgsForCall(0)
+			require.Equal(t, &service.RoomSettings{}, service.GetRoomSettings(createCtx))
+			// the rest of the request's context is kept
+			require.Equal(t, "guest", service.GetGrants(createCtx).Identity)
+
+			// and RoomService refuses the token
+			svc := newTestRoomService()
+			svc.store.LoadRoomReturns(&livekit.Room{Name: "meeting"}, nil)
+			svc.store.LoadParticipantReturns(&livekit.ParticipantInfo{Identity: "guest"}, nil)
+			var twErr twirp.Error
+			require.ErrorAs(t, setting.service(svc, ctx), &twErr)
+			require.Equal(t, twirp.Unauthenticated, twErr.Code())
+			require.Zero(t, svc.allocator.CreateRoomCallCount())
+			require.Zero(t, svc.store.StoreRoomCallCount())
+			require.Zero(t, svc.store.StoreRoomInternalCallCount())
+			require.Zero(t, svc.router.WriteRoomRTCCallCount())
+			require.Zero(t, svc.router.WriteParticipantRTCCallCount())
+		})
+	}
+}
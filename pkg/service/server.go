@@ -8,22 +8,28 @@ import (
 	"net"
 	"net/http"
 	"runtime/pprof"
+	"strconv"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
+	"github.com/livekit/protocol/webhook"
 	"github.com/pion/turn/v2"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
 	"github.com/urfave/negroni"
 	"go.uber.org/atomic"
 
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 	"github.com/livekit/livekit-server/version"
 )
 
+const drainCheckInterval = time.Second
+
 type LivekitServer struct {
 	config        *config.Config
 	egressService *EgressService
@@ -34,8 +40,12 @@ type LivekitServer struct {
 	router        routing.Router
 	roomManager   *RoomManager
 	turnServer    *turn.Server
+	notifier      webhook.Notifier
+	analytics     telemetry.AnalyticsService
+	health        *HealthChecker
 	currentNode   routing.LocalNode
 	running       atomic.Bool
+	draining      atomic.Bool
 	doneChan      chan struct{}
 	closedChan    chan struct{}
 }
@@ -50,6 +60,9 @@ func NewLivekitServer(conf *config.Config,
 	roomManager *RoomManager,
 	turnServer *turn.Server,
 	currentNode routing.LocalNode,
+	notifier webhook.Notifier,
+	analytics telemetry.AnalyticsService,
+	redisClient redis.UniversalClient,
 ) (s *LivekitServer, err error) {
 	s = &LivekitServer{
 		config:        conf,
@@ -60,6 +73,9 @@ func NewLivekitServer(conf *config.Config,
 		roomManager:   roomManager,
 		// turn server starts automatically
 		turnServer:  turnServer,
+		notifier:    notifier,
+		analytics:   analytics,
+		health:      NewHealthChecker(conf, router, currentNode, redisClient, turnServer, keyProvider),
 		currentNode: currentNode,
 		closedChan:  make(chan struct{}),
 	}
@@ -78,6 +94,14 @@ func NewLivekitServer(conf *config.Config,
 	if keyProvider != nil {
 		middlewares = append(middlewares, NewAPIKeyAuthMiddleware(keyProvider))
 	}
+	middlewares = append(middlewares, negroni.HandlerFunc(RoomSettingsMiddleware))
+	if conf.NodeSelector.Kind == "regionaware" {
+		locator, err := NewClientLocator(conf)
+		if err != nil {
+			return nil, err
+		}
+		middlewares = append(middlewares, locator)
+	}
 
 	roomServer := livekit.NewRoomServiceServer(roomService)
 	egressServer := livekit.NewEgressServer(egressService)
@@ -90,20 +114,38 @@ func NewLivekitServer(conf *config.Config,
 	mux.Handle("/rtc", rtcService)
 	mux.HandleFunc("/rtc/validate", rtcService.Validate)
 	mux.HandleFunc("/", s.healthCheck)
+	mux.HandleFunc("/healthz", s.health.Liveness)
+	mux.HandleFunc("/readyz", s.health.Readiness)
 	if conf.Development {
 		mux.HandleFunc("/debug/goroutine", s.debugGoroutines)
 		mux.HandleFunc("/debug/rooms", s.debugInfo)
 	}
 
+	// admin endpoints authenticate with the admin credentials, so they are kept out of the access token middlewares
+	handler := http.NewServeMux()
+	handler.Handle("/", configureMiddlewares(mux, middlewares...))
+	adminServer := NewAdminServer(conf)
+	adminServer.HandleFunc("/drain", s.drainHandler)
+	for _, path := range adminServer.Paths() {
+		handler.Handle(path, configureMiddlewares(adminServer, negroni.NewRecovery()))
+	}
+
 	s.httpServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", conf.Port),
-		Handler: configureMiddlewares(mux, middlewares...),
+		Addr:    net.JoinHostPort(conf.BindAddress, strconv.Itoa(int(conf.Port))),
+		Handler: handler,
 	}
 
-	if conf.PrometheusPort > 0 {
+	prometheus.SetMaxLabeledRooms(conf.Prometheus.MaxRoomLabels)
+	prometheus.SetJoinLatencyBuckets(conf.Prometheus.JoinLatencyBuckets)
+	if conf.Prometheus.Port > 0 {
+		var promHandler http.Handler
+		promHandler, err = NewPrometheusHandler(conf.Prometheus)
+		if err != nil {
+			return
+		}
 		s.promServer = &http.Server{
-			Addr:    fmt.Sprintf(":%d", conf.PrometheusPort),
-			Handler: promhttp.Handler(),
+			Addr:    net.JoinHostPort(conf.Prometheus.BindAddress, strconv.Itoa(int(conf.Prometheus.Port))),
+			Handler: promHandler,
 		}
 	}
 
@@ -164,7 +206,11 @@ func (s *LivekitServer) Start() error {
 			return err
 		}
 		go func() {
-			_ = s.promServer.Serve(promLn)
+			if tlsConf := s.config.Prometheus.TLS; tlsConf.CertFile != "" {
+				_ = s.promServer.ServeTLS(promLn, tlsConf.CertFile, tlsConf.KeyFile)
+			} else {
+				_ = s.promServer.Serve(promLn)
+			}
 		}()
 	}
 
@@ -185,8 +231,8 @@ func (s *LivekitServer) Start() error {
 				"rtc.portICERange", []uint32{s.config.RTC.ICEPortRangeStart, s.config.RTC.ICEPortRangeEnd},
 			)
 		}
-		if s.config.PrometheusPort != 0 {
-			values = append(values, "portPrometheus", s.config.PrometheusPort)
+		if s.config.Prometheus.Port != 0 {
+			values = append(values, "portPrometheus", s.config.Prometheus.Port)
 		}
 		if s.config.Region != "" {
 			values = append(values, "region", s.config.Region)
@@ -216,10 +262,23 @@ func (s *LivekitServer) Start() error {
 		_ = s.turnServer.Close()
 	}
 
+	// rooms closed below stay saved for the next start
+	if ls, ok := s.roomManager.roomStore.(*LocalStore); ok {
+		ls.Stop()
+	}
 	s.roomManager.Stop()
 	s.egressService.Stop()
 	s.recService.Stop()
 
+	// give queued webhooks a chance to be delivered
+	if n, ok := s.notifier.(*telemetry.WebhookNotifier); ok {
+		n.Stop()
+	}
+	// and queued analytics to be exported
+	if e, ok := s.analytics.(*telemetry.OTLPExporter); ok {
+		e.Stop()
+	}
+
 	close(s.closedChan)
 	return nil
 }
@@ -247,6 +306,71 @@ func (s *LivekitServer) Stop(force bool) {
 	<-s.closedChan
 }
 
+// Drain stops the node from taking new rooms and participants. It shuts down once its last room closes, or when
+// drain_timeout elapses, closing the rooms left
+func (s *LivekitServer) Drain() {
+	if s.draining.Swap(true) {
+		return
+	}
+
+	logger.Infow("draining node", "rooms", s.roomManager.RoomCount(), "timeout", s.config.DrainTimeout.Duration())
+	s.router.Drain()
+	prometheus.SetDraining(s.roomManager.RoomCount())
+	go s.waitForDrain()
+}
+
+func (s *LivekitServer) IsDraining() bool {
+	return s.draining.Load()
+}
+
+func (s *LivekitServer) waitForDrain() {
+	var deadline <-chan time.Time
+	if timeout := s.config.DrainTimeout.Duration(); timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+
+drain:
+	for {
+		rooms := s.roomManager.RoomCount()
+		prometheus.SetDraining(rooms)
+		if rooms == 0 {
+			logger.Infow("node drained, shutting down")
+			break
+		}
+
+		select {
+		case <-s.doneChan:
+			// stopped some other way
+			return
+		case <-deadline:
+			logger.Infow("drain timed out, closing remaining rooms", "rooms", rooms)
+			break drain
+		case <-ticker.C:
+		}
+	}
+
+	// rooms still open are closed on the way out, sending room_finished and participant_left webhooks
+	s.Stop(true)
+}
+
+func (s *LivekitServer) drainHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		handleError(w, http.StatusMethodNotAllowed, "drain must be requested with POST")
+		return
+	}
+
+	s.Drain()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"draining": true,
+		"rooms":    s.roomManager.RoomCount(),
+	})
+}
+
 func (s *LivekitServer) RoomManager() *RoomManager {
 	return s.roomManager
 }
@@ -290,12 +414,19 @@ func (s *LivekitServer) healthCheck(w http.ResponseWriter, _ *http.Request) {
 // worker to perform periodic tasks per node
 func (s *LivekitServer) backgroundWorker() {
 	roomTicker := time.NewTicker(30 * time.Second)
+	turnTicker := time.NewTicker(turnSummaryInterval)
+	defer turnTicker.Stop()
+	var turnStats prometheus.TURNStats
 	for {
 		select {
 		case <-s.doneChan:
 			return
 		case <-roomTicker.C:
 			s.roomManager.CloseIdleRooms()
+		case <-turnTicker.C:
+			if s.turnServer != nil {
+				turnStats = logTurnSummary(turnStats)
+			}
 		}
 	}
 }
@@ -11,6 +11,34 @@ import (
 )
 
 type FakeObjectStore struct {
+	BlockParticipantStub        func(context.Context, livekit.RoomName, livekit.ParticipantIdentity, time.Duration) error
+	blockParticipantMutex       sync.RWMutex
+	blockParticipantArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+		arg4 time.Duration
+	}
+	blockParticipantReturns struct {
+		result1 error
+	}
+	blockParticipantReturnsOnCall map[int]struct {
+		result1 error
+	}
+	CountRoomsStub        func(context.Context, string) (int, error)
+	countRoomsMutex       sync.RWMutex
+	countRoomsArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	countRoomsReturns struct {
+		result1 int
+		result2 error
+	}
+	countRoomsReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
 	DeleteEgressStub        func(context.Context, *livekit.EgressInfo) error
 	deleteEgressMutex       sync.RWMutex
 	deleteEgressArgsForCall []struct {
@@ -36,6 +64,19 @@ type FakeObjectStore struct {
 	deleteParticipantReturnsOnCall map[int]struct {
 		result1 error
 	}
+	DeletePendingParticipantStub        func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) error
+	deletePendingParticipantMutex       sync.RWMutex
+	deletePendingParticipantArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}
+	deletePendingParticipantReturns struct {
+		result1 error
+	}
+	deletePendingParticipantReturnsOnCall map[int]struct {
+		result1 error
+	}
 	DeleteRoomStub        func(context.Context, livekit.RoomName) error
 	deleteRoomMutex       sync.RWMutex
 	deleteRoomArgsForCall []struct {
@@ -48,6 +89,21 @@ type FakeObjectStore struct {
 	deleteRoomReturnsOnCall map[int]struct {
 		result1 error
 	}
+	IsParticipantBlockedStub        func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) (bool, error)
+	isParticipantBlockedMutex       sync.RWMutex
+	isParticipantBlockedArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}
+	isParticipantBlockedReturns struct {
+		result1 bool
+		result2 error
+	}
+	isParticipantBlockedReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
 	ListEgressStub        func(context.Context, livekit.RoomID) ([]*livekit.EgressInfo, error)
 	listEgressMutex       sync.RWMutex
 	listEgressArgsForCall []struct {
@@ -76,6 +132,20 @@ type FakeObjectStore struct {
 		result1 []*livekit.ParticipantInfo
 		result2 error
 	}
+	ListPendingParticipantsStub        func(context.Context, livekit.RoomName) ([]*service.PendingParticipant, error)
+	listPendingParticipantsMutex       sync.RWMutex
+	listPendingParticipantsArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+	}
+	listPendingParticipantsReturns struct {
+		result1 []*service.PendingParticipant
+		result2 error
+	}
+	listPendingParticipantsReturnsOnCall map[int]struct {
+		result1 []*service.PendingParticipant
+		result2 error
+	}
 	ListRoomsStub        func(context.Context, []livekit.RoomName) ([]*livekit.Room, error)
 	listRoomsMutex       sync.RWMutex
 	listRoomsArgsForCall []struct {
@@ -90,6 +160,22 @@ type FakeObjectStore struct {
 		result1 []*livekit.Room
 		result2 error
 	}
+	ListRoomsPageStub        func(context.Context, service.ListRoomsOptions) ([]*livekit.Room, string, error)
+	listRoomsPageMutex       sync.RWMutex
+	listRoomsPageArgsForCall []struct {
+		arg1 context.Context
+		arg2 service.ListRoomsOptions
+	}
+	listRoomsPageReturns struct {
+		result1 []*livekit.Room
+		result2 string
+		result3 error
+	}
+	listRoomsPageReturnsOnCall map[int]struct {
+		result1 []*livekit.Room
+		result2 string
+		result3 error
+	}
 	LoadEgressStub        func(context.Context, string) (*livekit.EgressInfo, error)
 	loadEgressMutex       sync.RWMutex
 	loadEgressArgsForCall []struct {
@@ -119,6 +205,21 @@ type FakeObjectStore struct {
 		result1 *livekit.ParticipantInfo
 		result2 error
 	}
+	LoadPendingParticipantStub        func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) (*service.PendingParticipant, error)
+	loadPendingParticipantMutex       sync.RWMutex
+	loadPendingParticipantArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}
+	loadPendingParticipantReturns struct {
+		result1 *service.PendingParticipant
+		result2 error
+	}
+	loadPendingParticipantReturnsOnCall map[int]struct {
+		result1 *service.PendingParticipant
+		result2 error
+	}
 	LoadRoomStub        func(context.Context, livekit.RoomName) (*livekit.Room, error)
 	loadRoomMutex       sync.RWMutex
 	loadRoomArgsForCall []struct {
@@ -133,6 +234,20 @@ type FakeObjectStore struct {
 		result1 *livekit.Room
 		result2 error
 	}
+	LoadRoomInternalStub        func(context.Context, livekit.RoomName) (*service.RoomInternal, error)
+	loadRoomInternalMutex       sync.RWMutex
+	loadRoomInternalArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+	}
+	loadRoomInternalReturns struct {
+		result1 *service.RoomInternal
+		result2 error
+	}
+	loadRoomInternalReturnsOnCall map[int]struct {
+		result1 *service.RoomInternal
+		result2 error
+	}
 	LockRoomStub        func(context.Context, livekit.RoomName, time.Duration) (string, error)
 	lockRoomMutex       sync.RWMutex
 	lockRoomArgsForCall []struct {
@@ -173,6 +288,19 @@ type FakeObjectStore struct {
 	storeParticipantReturnsOnCall map[int]struct {
 		result1 error
 	}
+	StorePendingParticipantStub        func(context.Context, livekit.RoomName, *service.PendingParticipant) error
+	storePendingParticipantMutex       sync.RWMutex
+	storePendingParticipantArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 *service.PendingParticipant
+	}
+	storePendingParticipantReturns struct {
+		result1 error
+	}
+	storePendingParticipantReturnsOnCall map[int]struct {
+		result1 error
+	}
 	StoreRoomStub        func(context.Context, *livekit.Room) error
 	storeRoomMutex       sync.RWMutex
 	storeRoomArgsForCall []struct {
@@ -185,6 +313,32 @@ type FakeObjectStore struct {
 	storeRoomReturnsOnCall map[int]struct {
 		result1 error
 	}
+	StoreRoomInternalStub        func(context.Context, livekit.RoomName, *service.RoomInternal) error
+	storeRoomInternalMutex       sync.RWMutex
+	storeRoomInternalArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 *service.RoomInternal
+	}
+	storeRoomInternalReturns struct {
+		result1 error
+	}
+	storeRoomInternalReturnsOnCall map[int]struct {
+		result1 error
+	}
+	UnblockParticipantStub        func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) error
+	unblockParticipantMutex       sync.RWMutex
+	unblockParticipantArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}
+	unblockParticipantReturns struct {
+		result1 error
+	}
+	unblockParticipantReturnsOnCall map[int]struct {
+		result1 error
+	}
 	UnlockRoomStub        func(context.Context, livekit.RoomName, string) error
 	unlockRoomMutex       sync.RWMutex
 	unlockRoomArgsForCall []struct {
@@ -214,6 +368,135 @@ type FakeObjectStore struct {
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeObjectStore) BlockParticipant(arg1 context.Context, arg2 livekit.RoomName, arg3 livekit.ParticipantIdentity, arg4 time.Duration) error {
+	fake.blockParticipantMutex.Lock()
+	ret, specificReturn := fake.blockParticipantReturnsOnCall[len(fake.blockParticipantArgsForCall)]
+	fake.blockParticipantArgsForCall = append(fake.blockParticipantArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+		arg4 time.Duration
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.BlockParticipantStub
+	fakeReturns := fake.blockParticipantReturns
+	fake.recordInvocation("BlockParticipant", []interface{}{arg1, arg2, arg3, arg4})
+	fake.blockParticipantMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeObjectStore) BlockParticipantCallCount() int {
+	fake.blockParticipantMutex.RLock()
+	defer fake.blockParticipantMutex.RUnlock()
+	return len(fake.blockParticipantArgsForCall)
+}
+
+func (fake *FakeObjectStore) BlockParticipantCalls(stub func(context.Context, livekit.RoomName, livekit.ParticipantIdentity, time.Duration) error) {
+	fake.blockParticipantMutex.Lock()
+	defer fake.blockParticipantMutex.Unlock()
+	fake.BlockParticipantStub = stub
+}
+
+func (fake *FakeObjectStore) BlockParticipantArgsForCall(i int) (context.Context, livekit.RoomName, livekit.ParticipantIdentity, time.Duration) {
+	fake.blockParticipantMutex.RLock()
+	defer fake.blockParticipantMutex.RUnlock()
+	argsForCall := fake.blockParticipantArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeObjectStore) BlockParticipantReturns(result1 error) {
+	fake.blockParticipantMutex.Lock()
+	defer fake.blockParticipantMutex.Unlock()
+	fake.BlockParticipantStub = nil
+	fake.blockParticipantReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) BlockParticipantReturnsOnCall(i int, result1 error) {
+	fake.blockParticipantMutex.Lock()
+	defer fake.blockParticipantMutex.Unlock()
+	fake.BlockParticipantStub = nil
+	if fake.blockParticipantReturnsOnCall == nil {
+		fake.blockParticipantReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.blockParticipantReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) CountRooms(arg1 context.Context, arg2 string) (int, error) {
+	fake.countRoomsMutex.Lock()
+	ret, specificReturn := fake.countRoomsReturnsOnCall[len(fake.countRoomsArgsForCall)]
+	fake.countRoomsArgsForCall = append(fake.countRoomsArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.CountRoomsStub
+	fakeReturns := fake.countRoomsReturns
+	fake.recordInvocation("CountRooms", []interface{}{arg1, arg2})
+	fake.countRoomsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeObjectStore) CountRoomsCallCount() int {
+	fake.countRoomsMutex.RLock()
+	defer fake.countRoomsMutex.RUnlock()
+	return len(fake.countRoomsArgsForCall)
+}
+
+func (fake *FakeObjectStore) CountRoomsCalls(stub func(context.Context, string) (int, error)) {
+	fake.countRoomsMutex.Lock()
+	defer fake.countRoomsMutex.Unlock()
+	fake.CountRoomsStub = stub
+}
+
+func (fake *FakeObjectStore) CountRoomsArgsForCall(i int) (context.Context, string) {
+	fake.countRoomsMutex.RLock()
+	defer fake.countRoomsMutex.RUnlock()
+	argsForCall := fake.countRoomsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeObjectStore) CountRoomsReturns(result1 int, result2 error) {
+	fake.countRoomsMutex.Lock()
+	defer fake.countRoomsMutex.Unlock()
+	fake.CountRoomsStub = nil
+	fake.countRoomsReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeObjectStore) CountRoomsReturnsOnCall(i int, result1 int, result2 error) {
+	fake.countRoomsMutex.Lock()
+	defer fake.countRoomsMutex.Unlock()
+	fake.CountRoomsStub = nil
+	if fake.countRoomsReturnsOnCall == nil {
+		fake.countRoomsReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 error
+		})
+	}
+	fake.countRoomsReturnsOnCall[i] = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeObjectStore) DeleteEgress(arg1 context.Context, arg2 *livekit.EgressInfo) error {
 	fake.deleteEgressMutex.Lock()
 	ret, specificReturn := fake.deleteEgressReturnsOnCall[len(fake.deleteEgressArgsForCall)]
@@ -339,6 +622,69 @@ func (fake *FakeObjectStore) DeleteParticipantReturnsOnCall(i int, result1 error
 	}{result1}
 }
 
+func (fake *FakeObjectStore) DeletePendingParticipant(arg1 context.Context, arg2 livekit.RoomName, arg3 livekit.ParticipantIdentity) error {
+	fake.deletePendingParticipantMutex.Lock()
+	ret, specificReturn := fake.deletePendingParticipantReturnsOnCall[len(fake.deletePendingParticipantArgsForCall)]
+	fake.deletePendingParticipantArgsForCall = append(fake.deletePendingParticipantArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}{arg1, arg2, arg3})
+	stub := fake.DeletePendingParticipantStub
+	fakeReturns := fake.deletePendingParticipantReturns
+	fake.recordInvocation("DeletePendingParticipant", []interface{}{arg1, arg2, arg3})
+	fake.deletePendingParticipantMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeObjectStore) DeletePendingParticipantCallCount() int {
+	fake.deletePendingParticipantMutex.RLock()
+	defer fake.deletePendingParticipantMutex.RUnlock()
+	return len(fake.deletePendingParticipantArgsForCall)
+}
+
+func (fake *FakeObjectStore) DeletePendingParticipantCalls(stub func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) error) {
+	fake.deletePendingParticipantMutex.Lock()
+	defer fake.deletePendingParticipantMutex.Unlock()
+	fake.DeletePendingParticipantStub = stub
+}
+
+func (fake *FakeObjectStore) DeletePendingParticipantArgsForCall(i int) (context.Context, livekit.RoomName, livekit.ParticipantIdentity) {
+	fake.deletePendingParticipantMutex.RLock()
+	defer fake.deletePendingParticipantMutex.RUnlock()
+	argsForCall := fake.deletePendingParticipantArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeObjectStore) DeletePendingParticipantReturns(result1 error) {
+	fake.deletePendingParticipantMutex.Lock()
+	defer fake.deletePendingParticipantMutex.Unlock()
+	fake.DeletePendingParticipantStub = nil
+	fake.deletePendingParticipantReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) DeletePendingParticipantReturnsOnCall(i int, result1 error) {
+	fake.deletePendingParticipantMutex.Lock()
+	defer fake.deletePendingParticipantMutex.Unlock()
+	fake.DeletePendingParticipantStub = nil
+	if fake.deletePendingParticipantReturnsOnCall == nil {
+		fake.deletePendingParticipantReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deletePendingParticipantReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeObjectStore) DeleteRoom(arg1 context.Context, arg2 livekit.RoomName) error {
 	fake.deleteRoomMutex.Lock()
 	ret, specificReturn := fake.deleteRoomReturnsOnCall[len(fake.deleteRoomArgsForCall)]
@@ -401,6 +747,72 @@ func (fake *FakeObjectStore) DeleteRoomReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeObjectStore) IsParticipantBlocked(arg1 context.Context, arg2 livekit.RoomName, arg3 livekit.ParticipantIdentity) (bool, error) {
+	fake.isParticipantBlockedMutex.Lock()
+	ret, specificReturn := fake.isParticipantBlockedReturnsOnCall[len(fake.isParticipantBlockedArgsForCall)]
+	fake.isParticipantBlockedArgsForCall = append(fake.isParticipantBlockedArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}{arg1, arg2, arg3})
+	stub := fake.IsParticipantBlockedStub
+	fakeReturns := fake.isParticipantBlockedReturns
+	fake.recordInvocation("IsParticipantBlocked", []interface{}{arg1, arg2, arg3})
+	fake.isParticipantBlockedMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeObjectStore) IsParticipantBlockedCallCount() int {
+	fake.isParticipantBlockedMutex.RLock()
+	defer fake.isParticipantBlockedMutex.RUnlock()
+	return len(fake.isParticipantBlockedArgsForCall)
+}
+
+func (fake *FakeObjectStore) IsParticipantBlockedCalls(stub func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) (bool, error)) {
+	fake.isParticipantBlockedMutex.Lock()
+	defer fake.isParticipantBlockedMutex.Unlock()
+	fake.IsParticipantBlockedStub = stub
+}
+
+func (fake *FakeObjectStore) IsParticipantBlockedArgsForCall(i int) (context.Context, livekit.RoomName, livekit.ParticipantIdentity) {
+	fake.isParticipantBlockedMutex.RLock()
+	defer fake.isParticipantBlockedMutex.RUnlock()
+	argsForCall := fake.isParticipantBlockedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeObjectStore) IsParticipantBlockedReturns(result1 bool, result2 error) {
+	fake.isParticipantBlockedMutex.Lock()
+	defer fake.isParticipantBlockedMutex.Unlock()
+	fake.IsParticipantBlockedStub = nil
+	fake.isParticipantBlockedReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeObjectStore) IsParticipantBlockedReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.isParticipantBlockedMutex.Lock()
+	defer fake.isParticipantBlockedMutex.Unlock()
+	fake.IsParticipantBlockedStub = nil
+	if fake.isParticipantBlockedReturnsOnCall == nil {
+		fake.isParticipantBlockedReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.isParticipantBlockedReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeObjectStore) ListEgress(arg1 context.Context, arg2 livekit.RoomID) ([]*livekit.EgressInfo, error) {
 	fake.listEgressMutex.Lock()
 	ret, specificReturn := fake.listEgressReturnsOnCall[len(fake.listEgressArgsForCall)]
@@ -531,6 +943,71 @@ func (fake *FakeObjectStore) ListParticipantsReturnsOnCall(i int, result1 []*liv
 	}{result1, result2}
 }
 
+func (fake *FakeObjectStore) ListPendingParticipants(arg1 context.Context, arg2 livekit.RoomName) ([]*service.PendingParticipant, error) {
+	fake.listPendingParticipantsMutex.Lock()
+	ret, specificReturn := fake.listPendingParticipantsReturnsOnCall[len(fake.listPendingParticipantsArgsForCall)]
+	fake.listPendingParticipantsArgsForCall = append(fake.listPendingParticipantsArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+	}{arg1, arg2})
+	stub := fake.ListPendingParticipantsStub
+	fakeReturns := fake.listPendingParticipantsReturns
+	fake.recordInvocation("ListPendingParticipants", []interface{}{arg1, arg2})
+	fake.listPendingParticipantsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeObjectStore) ListPendingParticipantsCallCount() int {
+	fake.listPendingParticipantsMutex.RLock()
+	defer fake.listPendingParticipantsMutex.RUnlock()
+	return len(fake.listPendingParticipantsArgsForCall)
+}
+
+func (fake *FakeObjectStore) ListPendingParticipantsCalls(stub func(context.Context, livekit.RoomName) ([]*service.PendingParticipant, error)) {
+	fake.listPendingParticipantsMutex.Lock()
+	defer fake.listPendingParticipantsMutex.Unlock()
+	fake.ListPendingParticipantsStub = stub
+}
+
+func (fake *FakeObjectStore) ListPendingParticipantsArgsForCall(i int) (context.Context, livekit.RoomName) {
+	fake.listPendingParticipantsMutex.RLock()
+	defer fake.listPendingParticipantsMutex.RUnlock()
+	argsForCall := fake.listPendingParticipantsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeObjectStore) ListPendingParticipantsReturns(result1 []*service.PendingParticipant, result2 error) {
+	fake.listPendingParticipantsMutex.Lock()
+	defer fake.listPendingParticipantsMutex.Unlock()
+	fake.ListPendingParticipantsStub = nil
+	fake.listPendingParticipantsReturns = struct {
+		result1 []*service.PendingParticipant
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeObjectStore) ListPendingParticipantsReturnsOnCall(i int, result1 []*service.PendingParticipant, result2 error) {
+	fake.listPendingParticipantsMutex.Lock()
+	defer fake.listPendingParticipantsMutex.Unlock()
+	fake.ListPendingParticipantsStub = nil
+	if fake.listPendingParticipantsReturnsOnCall == nil {
+		fake.listPendingParticipantsReturnsOnCall = make(map[int]struct {
+			result1 []*service.PendingParticipant
+			result2 error
+		})
+	}
+	fake.listPendingParticipantsReturnsOnCall[i] = struct {
+		result1 []*service.PendingParticipant
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeObjectStore) ListRooms(arg1 context.Context, arg2 []livekit.RoomName) ([]*livekit.Room, error) {
 	var arg2Copy []livekit.RoomName
 	if arg2 != nil {
@@ -601,6 +1078,74 @@ func (fake *FakeObjectStore) ListRoomsReturnsOnCall(i int, result1 []*livekit.Ro
 	}{result1, result2}
 }
 
+func (fake *FakeObjectStore) ListRoomsPage(arg1 context.Context, arg2 service.ListRoomsOptions) ([]*livekit.Room, string, error) {
+	fake.listRoomsPageMutex.Lock()
+	ret, specificReturn := fake.listRoomsPageReturnsOnCall[len(fake.listRoomsPageArgsForCall)]
+	fake.listRoomsPageArgsForCall = append(fake.listRoomsPageArgsForCall, struct {
+		arg1 context.Context
+		arg2 service.ListRoomsOptions
+	}{arg1, arg2})
+	stub := fake.ListRoomsPageStub
+	fakeReturns := fake.listRoomsPageReturns
+	fake.recordInvocation("ListRoomsPage", []interface{}{arg1, arg2})
+	fake.listRoomsPageMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeObjectStore) ListRoomsPageCallCount() int {
+	fake.listRoomsPageMutex.RLock()
+	defer fake.listRoomsPageMutex.RUnlock()
+	return len(fake.listRoomsPageArgsForCall)
+}
+
+func (fake *FakeObjectStore) ListRoomsPageCalls(stub func(context.Context, service.ListRoomsOptions) ([]*livekit.Room, string, error)) {
+	fake.listRoomsPageMutex.Lock()
+	defer fake.listRoomsPageMutex.Unlock()
+	fake.ListRoomsPageStub = stub
+}
+
+func (fake *FakeObjectStore) ListRoomsPageArgsForCall(i int) (context.Context, service.ListRoomsOptions) {
+	fake.listRoomsPageMutex.RLock()
+	defer fake.listRoomsPageMutex.RUnlock()
+	argsForCall := fake.listRoomsPageArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeObjectStore) ListRoomsPageReturns(result1 []*livekit.Room, result2 string, result3 error) {
+	fake.listRoomsPageMutex.Lock()
+	defer fake.listRoomsPageMutex.Unlock()
+	fake.ListRoomsPageStub = nil
+	fake.listRoomsPageReturns = struct {
+		result1 []*livekit.Room
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeObjectStore) ListRoomsPageReturnsOnCall(i int, result1 []*livekit.Room, result2 string, result3 error) {
+	fake.listRoomsPageMutex.Lock()
+	defer fake.listRoomsPageMutex.Unlock()
+	fake.ListRoomsPageStub = nil
+	if fake.listRoomsPageReturnsOnCall == nil {
+		fake.listRoomsPageReturnsOnCall = make(map[int]struct {
+			result1 []*livekit.Room
+			result2 string
+			result3 error
+		})
+	}
+	fake.listRoomsPageReturnsOnCall[i] = struct {
+		result1 []*livekit.Room
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
 func (fake *FakeObjectStore) LoadEgress(arg1 context.Context, arg2 string) (*livekit.EgressInfo, error) {
 	fake.loadEgressMutex.Lock()
 	ret, specificReturn := fake.loadEgressReturnsOnCall[len(fake.loadEgressArgsForCall)]
@@ -732,6 +1277,72 @@ func (fake *FakeObjectStore) LoadParticipantReturnsOnCall(i int, result1 *liveki
 	}{result1, result2}
 }
 
+func (fake *FakeObjectStore) LoadPendingParticipant(arg1 context.Context, arg2 livekit.RoomName, arg3 livekit.ParticipantIdentity) (*service.PendingParticipant, error) {
+	fake.loadPendingParticipantMutex.Lock()
+	ret, specificReturn := fake.loadPendingParticipantReturnsOnCall[len(fake.loadPendingParticipantArgsForCall)]
+	fake.loadPendingParticipantArgsForCall = append(fake.loadPendingParticipantArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}{arg1, arg2, arg3})
+	stub := fake.LoadPendingParticipantStub
+	fakeReturns := fake.loadPendingParticipantReturns
+	fake.recordInvocation("LoadPendingParticipant", []interface{}{arg1, arg2, arg3})
+	fake.loadPendingParticipantMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeObjectStore) LoadPendingParticipantCallCount() int {
+	fake.loadPendingParticipantMutex.RLock()
+	defer fake.loadPendingParticipantMutex.RUnlock()
+	return len(fake.loadPendingParticipantArgsForCall)
+}
+
+func (fake *FakeObjectStore) LoadPendingParticipantCalls(stub func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) (*service.PendingParticipant, error)) {
+	fake.loadPendingParticipantMutex.Lock()
+	defer fake.loadPendingParticipantMutex.Unlock()
+	fake.LoadPendingParticipantStub = stub
+}
+
+func (fake *FakeObjectStore) LoadPendingParticipantArgsForCall(i int) (context.Context, livekit.RoomName, livekit.ParticipantIdentity) {
+	fake.loadPendingParticipantMutex.RLock()
+	defer fake.loadPendingParticipantMutex.RUnlock()
+	argsForCall := fake.loadPendingParticipantArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeObjectStore) LoadPendingParticipantReturns(result1 *service.PendingParticipant, result2 error) {
+	fake.loadPendingParticipantMutex.Lock()
+	defer fake.loadPendingParticipantMutex.Unlock()
+	fake.LoadPendingParticipantStub = nil
+	fake.loadPendingParticipantReturns = struct {
+		result1 *service.PendingParticipant
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeObjectStore) LoadPendingParticipantReturnsOnCall(i int, result1 *service.PendingParticipant, result2 error) {
+	fake.loadPendingParticipantMutex.Lock()
+	defer fake.loadPendingParticipantMutex.Unlock()
+	fake.LoadPendingParticipantStub = nil
+	if fake.loadPendingParticipantReturnsOnCall == nil {
+		fake.loadPendingParticipantReturnsOnCall = make(map[int]struct {
+			result1 *service.PendingParticipant
+			result2 error
+		})
+	}
+	fake.loadPendingParticipantReturnsOnCall[i] = struct {
+		result1 *service.PendingParticipant
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeObjectStore) LoadRoom(arg1 context.Context, arg2 livekit.RoomName) (*livekit.Room, error) {
 	fake.loadRoomMutex.Lock()
 	ret, specificReturn := fake.loadRoomReturnsOnCall[len(fake.loadRoomArgsForCall)]
@@ -797,6 +1408,71 @@ func (fake *FakeObjectStore) LoadRoomReturnsOnCall(i int, result1 *livekit.Room,
 	}{result1, result2}
 }
 
+func (fake *FakeObjectStore) LoadRoomInternal(arg1 context.Context, arg2 livekit.RoomName) (*service.RoomInternal, error) {
+	fake.loadRoomInternalMutex.Lock()
+	ret, specificReturn := fake.loadRoomInternalReturnsOnCall[len(fake.loadRoomInternalArgsForCall)]
+	fake.loadRoomInternalArgsForCall = append(fake.loadRoomInternalArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+	}{arg1, arg2})
+	stub := fake.LoadRoomInternalStub
+	fakeReturns := fake.loadRoomInternalReturns
+	fake.recordInvocation("LoadRoomInternal", []interface{}{arg1, arg2})
+	fake.loadRoomInternalMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeObjectStore) LoadRoomInternalCallCount() int {
+	fake.loadRoomInternalMutex.RLock()
+	defer fake.loadRoomInternalMutex.RUnlock()
+	return len(fake.loadRoomInternalArgsForCall)
+}
+
+func (fake *FakeObjectStore) LoadRoomInternalCalls(stub func(context.Context, livekit.RoomName) (*service.RoomInternal, error)) {
+	fake.loadRoomInternalMutex.Lock()
+	defer fake.loadRoomInternalMutex.Unlock()
+	fake.LoadRoomInternalStub = stub
+}
+
+func (fake *FakeObjectStore) LoadRoomInternalArgsForCall(i int) (context.Context, livekit.RoomName) {
+	fake.loadRoomInternalMutex.RLock()
+	defer fake.loadRoomInternalMutex.RUnlock()
+	argsForCall := fake.loadRoomInternalArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeObjectStore) LoadRoomInternalReturns(result1 *service.RoomInternal, result2 error) {
+	fake.loadRoomInternalMutex.Lock()
+	defer fake.loadRoomInternalMutex.Unlock()
+	fake.LoadRoomInternalStub = nil
+	fake.loadRoomInternalReturns = struct {
+		result1 *service.RoomInternal
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeObjectStore) LoadRoomInternalReturnsOnCall(i int, result1 *service.RoomInternal, result2 error) {
+	fake.loadRoomInternalMutex.Lock()
+	defer fake.loadRoomInternalMutex.Unlock()
+	fake.LoadRoomInternalStub = nil
+	if fake.loadRoomInternalReturnsOnCall == nil {
+		fake.loadRoomInternalReturnsOnCall = make(map[int]struct {
+			result1 *service.RoomInternal
+			result2 error
+		})
+	}
+	fake.loadRoomInternalReturnsOnCall[i] = struct {
+		result1 *service.RoomInternal
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeObjectStore) LockRoom(arg1 context.Context, arg2 livekit.RoomName, arg3 time.Duration) (string, error) {
 	fake.lockRoomMutex.Lock()
 	ret, specificReturn := fake.lockRoomReturnsOnCall[len(fake.lockRoomArgsForCall)]
@@ -988,6 +1664,69 @@ func (fake *FakeObjectStore) StoreParticipantReturnsOnCall(i int, result1 error)
 	}{result1}
 }
 
+func (fake *FakeObjectStore) StorePendingParticipant(arg1 context.Context, arg2 livekit.RoomName, arg3 *service.PendingParticipant) error {
+	fake.storePendingParticipantMutex.Lock()
+	ret, specificReturn := fake.storePendingParticipantReturnsOnCall[len(fake.storePendingParticipantArgsForCall)]
+	fake.storePendingParticipantArgsForCall = append(fake.storePendingParticipantArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 *service.PendingParticipant
+	}{arg1, arg2, arg3})
+	stub := fake.StorePendingParticipantStub
+	fakeReturns := fake.storePendingParticipantReturns
+	fake.recordInvocation("StorePendingParticipant", []interface{}{arg1, arg2, arg3})
+	fake.storePendingParticipantMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeObjectStore) StorePendingParticipantCallCount() int {
+	fake.storePendingParticipantMutex.RLock()
+	defer fake.storePendingParticipantMutex.RUnlock()
+	return len(fake.storePendingParticipantArgsForCall)
+}
+
+func (fake *FakeObjectStore) StorePendingParticipantCalls(stub func(context.Context, livekit.RoomName, *service.PendingParticipant) error) {
+	fake.storePendingParticipantMutex.Lock()
+	defer fake.storePendingParticipantMutex.Unlock()
+	fake.StorePendingParticipantStub = stub
+}
+
+func (fake *FakeObjectStore) StorePendingParticipantArgsForCall(i int) (context.Context, livekit.RoomName, *service.PendingParticipant) {
+	fake.storePendingParticipantMutex.RLock()
+	defer fake.storePendingParticipantMutex.RUnlock()
+	argsForCall := fake.storePendingParticipantArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeObjectStore) StorePendingParticipantReturns(result1 error) {
+	fake.storePendingParticipantMutex.Lock()
+	defer fake.storePendingParticipantMutex.Unlock()
+	fake.StorePendingParticipantStub = nil
+	fake.storePendingParticipantReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) StorePendingParticipantReturnsOnCall(i int, result1 error) {
+	fake.storePendingParticipantMutex.Lock()
+	defer fake.storePendingParticipantMutex.Unlock()
+	fake.StorePendingParticipantStub = nil
+	if fake.storePendingParticipantReturnsOnCall == nil {
+		fake.storePendingParticipantReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.storePendingParticipantReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeObjectStore) StoreRoom(arg1 context.Context, arg2 *livekit.Room) error {
 	fake.storeRoomMutex.Lock()
 	ret, specificReturn := fake.storeRoomReturnsOnCall[len(fake.storeRoomArgsForCall)]
@@ -1050,6 +1789,132 @@ func (fake *FakeObjectStore) StoreRoomReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeObjectStore) StoreRoomInternal(arg1 context.Context, arg2 livekit.RoomName, arg3 *service.RoomInternal) error {
+	fake.storeRoomInternalMutex.Lock()
+	ret, specificReturn := fake.storeRoomInternalReturnsOnCall[len(fake.storeRoomInternalArgsForCall)]
+	fake.storeRoomInternalArgsForCall = append(fake.storeRoomInternalArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 *service.RoomInternal
+	}{arg1, arg2, arg3})
+	stub := fake.StoreRoomInternalStub
+	fakeReturns := fake.storeRoomInternalReturns
+	fake.recordInvocation("StoreRoomInternal", []interface{}{arg1, arg2, arg3})
+	fake.storeRoomInternalMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeObjectStore) StoreRoomInternalCallCount() int {
+	fake.storeRoomInternalMutex.RLock()
+	defer fake.storeRoomInternalMutex.RUnlock()
+	return len(fake.storeRoomInternalArgsForCall)
+}
+
+func (fake *FakeObjectStore) StoreRoomInternalCalls(stub func(context.Context, livekit.RoomName, *service.RoomInternal) error) {
+	fake.storeRoomInternalMutex.Lock()
+	defer fake.storeRoomInternalMutex.Unlock()
+	fake.StoreRoomInternalStub = stub
+}
+
+func (fake *FakeObjectStore) StoreRoomInternalArgsForCall(i int) (context.Context, livekit.RoomName, *service.RoomInternal) {
+	fake.storeRoomInternalMutex.RLock()
+	defer fake.storeRoomInternalMutex.RUnlock()
+	argsForCall := fake.storeRoomInternalArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeObjectStore) StoreRoomInternalReturns(result1 error) {
+	fake.storeRoomInternalMutex.Lock()
+	defer fake.storeRoomInternalMutex.Unlock()
+	fake.StoreRoomInternalStub = nil
+	fake.storeRoomInternalReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) StoreRoomInternalReturnsOnCall(i int, result1 error) {
+	fake.storeRoomInternalMutex.Lock()
+	defer fake.storeRoomInternalMutex.Unlock()
+	fake.StoreRoomInternalStub = nil
+	if fake.storeRoomInternalReturnsOnCall == nil {
+		fake.storeRoomInternalReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.storeRoomInternalReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) UnblockParticipant(arg1 context.Context, arg2 livekit.RoomName, arg3 livekit.ParticipantIdentity) error {
+	fake.unblockParticipantMutex.Lock()
+	ret, specificReturn := fake.unblockParticipantReturnsOnCall[len(fake.unblockParticipantArgsForCall)]
+	fake.unblockParticipantArgsForCall = append(fake.unblockParticipantArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}{arg1, arg2, arg3})
+	stub := fake.UnblockParticipantStub
+	fakeReturns := fake.unblockParticipantReturns
+	fake.recordInvocation("UnblockParticipant", []interface{}{arg1, arg2, arg3})
+	fake.unblockParticipantMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeObjectStore) UnblockParticipantCallCount() int {
+	fake.unblockParticipantMutex.RLock()
+	defer fake.unblockParticipantMutex.RUnlock()
+	return len(fake.unblockParticipantArgsForCall)
+}
+
+func (fake *FakeObjectStore) UnblockParticipantCalls(stub func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) error) {
+	fake.unblockParticipantMutex.Lock()
+	defer fake.unblockParticipantMutex.Unlock()
+	fake.UnblockParticipantStub = stub
+}
+
+func (fake *FakeObjectStore) UnblockParticipantArgsForCall(i int) (context.Context, livekit.RoomName, livekit.ParticipantIdentity) {
+	fake.unblockParticipantMutex.RLock()
+	defer fake.unblockParticipantMutex.RUnlock()
+	argsForCall := fake.unblockParticipantArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeObjectStore) UnblockParticipantReturns(result1 error) {
+	fake.unblockParticipantMutex.Lock()
+	defer fake.unblockParticipantMutex.Unlock()
+	fake.UnblockParticipantStub = nil
+	fake.unblockParticipantReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeObjectStore) UnblockParticipantReturnsOnCall(i int, result1 error) {
+	fake.unblockParticipantMutex.Lock()
+	defer fake.unblockParticipantMutex.Unlock()
+	fake.UnblockParticipantStub = nil
+	if fake.unblockParticipantReturnsOnCall == nil {
+		fake.unblockParticipantReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.unblockParticipantReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeObjectStore) UnlockRoom(arg1 context.Context, arg2 livekit.RoomName, arg3 string) error {
 	fake.unlockRoomMutex.Lock()
 	ret, specificReturn := fake.unlockRoomReturnsOnCall[len(fake.unlockRoomArgsForCall)]
@@ -1072,6 +1937,8 @@ func (fake *FakeObjectStore) UnlockRoom(arg1 context.Context, arg2 livekit.RoomN
 }
 
 func (fake *FakeObjectStore) UnlockRoomCallCount() int {
+	fake.unblockParticipantMutex.RLock()
+	defer fake.unblockParticipantMutex.RUnlock()
 	fake.unlockRoomMutex.RLock()
 	defer fake.unlockRoomMutex.RUnlock()
 	return len(fake.unlockRoomArgsForCall)
@@ -1084,6 +1951,8 @@ func (fake *FakeObjectStore) UnlockRoomCalls(stub func(context.Context, livekit.
 }
 
 func (fake *FakeObjectStore) UnlockRoomArgsForCall(i int) (context.Context, livekit.RoomName, string) {
+	fake.unblockParticipantMutex.RLock()
+	defer fake.unblockParticipantMutex.RUnlock()
 	fake.unlockRoomMutex.RLock()
 	defer fake.unlockRoomMutex.RUnlock()
 	argsForCall := fake.unlockRoomArgsForCall[i]
@@ -1178,32 +2047,54 @@ func (fake *FakeObjectStore) UpdateEgressReturnsOnCall(i int, result1 error) {
 func (fake *FakeObjectStore) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.blockParticipantMutex.RLock()
+	defer fake.blockParticipantMutex.RUnlock()
+	fake.countRoomsMutex.RLock()
+	defer fake.countRoomsMutex.RUnlock()
 	fake.deleteEgressMutex.RLock()
 	defer fake.deleteEgressMutex.RUnlock()
 	fake.deleteParticipantMutex.RLock()
 	defer fake.deleteParticipantMutex.RUnlock()
+	fake.deletePendingParticipantMutex.RLock()
+	defer fake.deletePendingParticipantMutex.RUnlock()
 	fake.deleteRoomMutex.RLock()
 	defer fake.deleteRoomMutex.RUnlock()
+	fake.isParticipantBlockedMutex.RLock()
+	defer fake.isParticipantBlockedMutex.RUnlock()
 	fake.listEgressMutex.RLock()
 	defer fake.listEgressMutex.RUnlock()
 	fake.listParticipantsMutex.RLock()
 	defer fake.listParticipantsMutex.RUnlock()
+	fake.listPendingParticipantsMutex.RLock()
+	defer fake.listPendingParticipantsMutex.RUnlock()
 	fake.listRoomsMutex.RLock()
 	defer fake.listRoomsMutex.RUnlock()
+	fake.listRoomsPageMutex.RLock()
+	defer fake.listRoomsPageMutex.RUnlock()
 	fake.loadEgressMutex.RLock()
 	defer fake.loadEgressMutex.RUnlock()
 	fake.loadParticipantMutex.RLock()
 	defer fake.loadParticipantMutex.RUnlock()
+	fake.loadPendingParticipantMutex.RLock()
+	defer fake.loadPendingParticipantMutex.RUnlock()
 	fake.loadRoomMutex.RLock()
 	defer fake.loadRoomMutex.RUnlock()
+	fake.loadRoomInternalMutex.RLock()
+	defer fake.loadRoomInternalMutex.RUnlock()
 	fake.lockRoomMutex.RLock()
 	defer fake.lockRoomMutex.RUnlock()
 	fake.storeEgressMutex.RLock()
 	defer fake.storeEgressMutex.RUnlock()
 	fake.storeParticipantMutex.RLock()
 	defer fake.storeParticipantMutex.RUnlock()
+	fake.storePendingParticipantMutex.RLock()
+	defer fake.storePendingParticipantMutex.RUnlock()
 	fake.storeRoomMutex.RLock()
 	defer fake.storeRoomMutex.RUnlock()
+	fake.storeRoomInternalMutex.RLock()
+	defer fake.storeRoomInternalMutex.RUnlock()
+	fake.unblockParticipantMutex.RLock()
+	defer fake.unblockParticipantMutex.RUnlock()
 	fake.unlockRoomMutex.RLock()
 	defer fake.unlockRoomMutex.RUnlock()
 	fake.updateEgressMutex.RLock()
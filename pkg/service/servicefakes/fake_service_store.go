@@ -10,6 +10,35 @@ import (
 )
 
 type FakeServiceStore struct {
+	CountRoomsStub        func(context.Context, string) (int, error)
+	countRoomsMutex       sync.RWMutex
+	countRoomsArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	countRoomsReturns struct {
+		result1 int
+		result2 error
+	}
+	countRoomsReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
+	IsParticipantBlockedStub        func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) (bool, error)
+	isParticipantBlockedMutex       sync.RWMutex
+	isParticipantBlockedArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}
+	isParticipantBlockedReturns struct {
+		result1 bool
+		result2 error
+	}
+	isParticipantBlockedReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
 	ListParticipantsStub        func(context.Context, livekit.RoomName) ([]*livekit.ParticipantInfo, error)
 	listParticipantsMutex       sync.RWMutex
 	listParticipantsArgsForCall []struct {
@@ -24,6 +53,20 @@ type FakeServiceStore struct {
 		result1 []*livekit.ParticipantInfo
 		result2 error
 	}
+	ListPendingParticipantsStub        func(context.Context, livekit.RoomName) ([]*service.PendingParticipant, error)
+	listPendingParticipantsMutex       sync.RWMutex
+	listPendingParticipantsArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+	}
+	listPendingParticipantsReturns struct {
+		result1 []*service.PendingParticipant
+		result2 error
+	}
+	listPendingParticipantsReturnsOnCall map[int]struct {
+		result1 []*service.PendingParticipant
+		result2 error
+	}
 	ListRoomsStub        func(context.Context, []livekit.RoomName) ([]*livekit.Room, error)
 	listRoomsMutex       sync.RWMutex
 	listRoomsArgsForCall []struct {
@@ -38,6 +81,22 @@ type FakeServiceStore struct {
 		result1 []*livekit.Room
 		result2 error
 	}
+	ListRoomsPageStub        func(context.Context, service.ListRoomsOptions) ([]*livekit.Room, string, error)
+	listRoomsPageMutex       sync.RWMutex
+	listRoomsPageArgsForCall []struct {
+		arg1 context.Context
+		arg2 service.ListRoomsOptions
+	}
+	listRoomsPageReturns struct {
+		result1 []*livekit.Room
+		result2 string
+		result3 error
+	}
+	listRoomsPageReturnsOnCall map[int]struct {
+		result1 []*livekit.Room
+		result2 string
+		result3 error
+	}
 	LoadParticipantStub        func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) (*livekit.ParticipantInfo, error)
 	loadParticipantMutex       sync.RWMutex
 	loadParticipantArgsForCall []struct {
@@ -53,6 +112,21 @@ type FakeServiceStore struct {
 		result1 *livekit.ParticipantInfo
 		result2 error
 	}
+	LoadPendingParticipantStub        func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) (*service.PendingParticipant, error)
+	loadPendingParticipantMutex       sync.RWMutex
+	loadPendingParticipantArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}
+	loadPendingParticipantReturns struct {
+		result1 *service.PendingParticipant
+		result2 error
+	}
+	loadPendingParticipantReturnsOnCall map[int]struct {
+		result1 *service.PendingParticipant
+		result2 error
+	}
 	LoadRoomStub        func(context.Context, livekit.RoomName) (*livekit.Room, error)
 	loadRoomMutex       sync.RWMutex
 	loadRoomArgsForCall []struct {
@@ -67,10 +141,155 @@ type FakeServiceStore struct {
 		result1 *livekit.Room
 		result2 error
 	}
+	LoadRoomInternalStub        func(context.Context, livekit.RoomName) (*service.RoomInternal, error)
+	loadRoomInternalMutex       sync.RWMutex
+	loadRoomInternalArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+	}
+	loadRoomInternalReturns struct {
+		result1 *service.RoomInternal
+		result2 error
+	}
+	loadRoomInternalReturnsOnCall map[int]struct {
+		result1 *service.RoomInternal
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeServiceStore) CountRooms(arg1 context.Context, arg2 string) (int, error) {
+	fake.countRoomsMutex.Lock()
+	ret, specificReturn := fake.countRoomsReturnsOnCall[len(fake.countRoomsArgsForCall)]
+	fake.countRoomsArgsForCall = append(fake.countRoomsArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.CountRoomsStub
+	fakeReturns := fake.countRoomsReturns
+	fake.recordInvocation("CountRooms", []interface{}{arg1, arg2})
+	fake.countRoomsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceStore) CountRoomsCallCount() int {
+	fake.countRoomsMutex.RLock()
+	defer fake.countRoomsMutex.RUnlock()
+	return len(fake.countRoomsArgsForCall)
+}
+
+func (fake *FakeServiceStore) CountRoomsCalls(stub func(context.Context, string) (int, error)) {
+	fake.countRoomsMutex.Lock()
+	defer fake.countRoomsMutex.Unlock()
+	fake.CountRoomsStub = stub
+}
+
+func (fake *FakeServiceStore) CountRoomsArgsForCall(i int) (context.Context, string) {
+	fake.countRoomsMutex.RLock()
+	defer fake.countRoomsMutex.RUnlock()
+	argsForCall := fake.countRoomsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeServiceStore) CountRoomsReturns(result1 int, result2 error) {
+	fake.countRoomsMutex.Lock()
+	defer fake.countRoomsMutex.Unlock()
+	fake.CountRoomsStub = nil
+	fake.countRoomsReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceStore) CountRoomsReturnsOnCall(i int, result1 int, result2 error) {
+	fake.countRoomsMutex.Lock()
+	defer fake.countRoomsMutex.Unlock()
+	fake.CountRoomsStub = nil
+	if fake.countRoomsReturnsOnCall == nil {
+		fake.countRoomsReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 error
+		})
+	}
+	fake.countRoomsReturnsOnCall[i] = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceStore) IsParticipantBlocked(arg1 context.Context, arg2 livekit.RoomName, arg3 livekit.ParticipantIdentity) (bool, error) {
+	fake.isParticipantBlockedMutex.Lock()
+	ret, specificReturn := fake.isParticipantBlockedReturnsOnCall[len(fake.isParticipantBlockedArgsForCall)]
+	fake.isParticipantBlockedArgsForCall = append(fake.isParticipantBlockedArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}{arg1, arg2, arg3})
+	stub := fake.IsParticipantBlockedStub
+	fakeReturns := fake.isParticipantBlockedReturns
+	fake.recordInvocation("IsParticipantBlocked", []interface{}{arg1, arg2, arg3})
+	fake.isParticipantBlockedMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceStore) IsParticipantBlockedCallCount() int {
+	fake.isParticipantBlockedMutex.RLock()
+	defer fake.isParticipantBlockedMutex.RUnlock()
+	return len(fake.isParticipantBlockedArgsForCall)
+}
+
+func (fake *FakeServiceStore) IsParticipantBlockedCalls(stub func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) (bool, error)) {
+	fake.isParticipantBlockedMutex.Lock()
+	defer fake.isParticipantBlockedMutex.Unlock()
+	fake.IsParticipantBlockedStub = stub
+}
+
+func (fake *FakeServiceStore) IsParticipantBlockedArgsForCall(i int) (context.Context, livekit.RoomName, livekit.ParticipantIdentity) {
+	fake.isParticipantBlockedMutex.RLock()
+	defer fake.isParticipantBlockedMutex.RUnlock()
+	argsForCall := fake.isParticipantBlockedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeServiceStore) IsParticipantBlockedReturns(result1 bool, result2 error) {
+	fake.isParticipantBlockedMutex.Lock()
+	defer fake.isParticipantBlockedMutex.Unlock()
+	fake.IsParticipantBlockedStub = nil
+	fake.isParticipantBlockedReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceStore) IsParticipantBlockedReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.isParticipantBlockedMutex.Lock()
+	defer fake.isParticipantBlockedMutex.Unlock()
+	fake.IsParticipantBlockedStub = nil
+	if fake.isParticipantBlockedReturnsOnCall == nil {
+		fake.isParticipantBlockedReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.isParticipantBlockedReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeServiceStore) ListParticipants(arg1 context.Context, arg2 livekit.RoomName) ([]*livekit.ParticipantInfo, error) {
 	fake.listParticipantsMutex.Lock()
 	ret, specificReturn := fake.listParticipantsReturnsOnCall[len(fake.listParticipantsArgsForCall)]
@@ -136,6 +355,71 @@ func (fake *FakeServiceStore) ListParticipantsReturnsOnCall(i int, result1 []*li
 	}{result1, result2}
 }
 
+func (fake *FakeServiceStore) ListPendingParticipants(arg1 context.Context, arg2 livekit.RoomName) ([]*service.PendingParticipant, error) {
+	fake.listPendingParticipantsMutex.Lock()
+	ret, specificReturn := fake.listPendingParticipantsReturnsOnCall[len(fake.listPendingParticipantsArgsForCall)]
+	fake.listPendingParticipantsArgsForCall = append(fake.listPendingParticipantsArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+	}{arg1, arg2})
+	stub := fake.ListPendingParticipantsStub
+	fakeReturns := fake.listPendingParticipantsReturns
+	fake.recordInvocation("ListPendingParticipants", []interface{}{arg1, arg2})
+	fake.listPendingParticipantsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceStore) ListPendingParticipantsCallCount() int {
+	fake.listPendingParticipantsMutex.RLock()
+	defer fake.listPendingParticipantsMutex.RUnlock()
+	return len(fake.listPendingParticipantsArgsForCall)
+}
+
+func (fake *FakeServiceStore) ListPendingParticipantsCalls(stub func(context.Context, livekit.RoomName) ([]*service.PendingParticipant, error)) {
+	fake.listPendingParticipantsMutex.Lock()
+	defer fake.listPendingParticipantsMutex.Unlock()
+	fake.ListPendingParticipantsStub = stub
+}
+
+func (fake *FakeServiceStore) ListPendingParticipantsArgsForCall(i int) (context.Context, livekit.RoomName) {
+	fake.listPendingParticipantsMutex.RLock()
+	defer fake.listPendingParticipantsMutex.RUnlock()
+	argsForCall := fake.listPendingParticipantsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeServiceStore) ListPendingParticipantsReturns(result1 []*service.PendingParticipant, result2 error) {
+	fake.listPendingParticipantsMutex.Lock()
+	defer fake.listPendingParticipantsMutex.Unlock()
+	fake.ListPendingParticipantsStub = nil
+	fake.listPendingParticipantsReturns = struct {
+		result1 []*service.PendingParticipant
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceStore) ListPendingParticipantsReturnsOnCall(i int, result1 []*service.PendingParticipant, result2 error) {
+	fake.listPendingParticipantsMutex.Lock()
+	defer fake.listPendingParticipantsMutex.Unlock()
+	fake.ListPendingParticipantsStub = nil
+	if fake.listPendingParticipantsReturnsOnCall == nil {
+		fake.listPendingParticipantsReturnsOnCall = make(map[int]struct {
+			result1 []*service.PendingParticipant
+			result2 error
+		})
+	}
+	fake.listPendingParticipantsReturnsOnCall[i] = struct {
+		result1 []*service.PendingParticipant
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeServiceStore) ListRooms(arg1 context.Context, arg2 []livekit.RoomName) ([]*livekit.Room, error) {
 	var arg2Copy []livekit.RoomName
 	if arg2 != nil {
@@ -206,6 +490,74 @@ func (fake *FakeServiceStore) ListRoomsReturnsOnCall(i int, result1 []*livekit.R
 	}{result1, result2}
 }
 
+func (fake *FakeServiceStore) ListRoomsPage(arg1 context.Context, arg2 service.ListRoomsOptions) ([]*livekit.Room, string, error) {
+	fake.listRoomsPageMutex.Lock()
+	ret, specificReturn := fake.listRoomsPageReturnsOnCall[len(fake.listRoomsPageArgsForCall)]
+	fake.listRoomsPageArgsForCall = append(fake.listRoomsPageArgsForCall, struct {
+		arg1 context.Context
+		arg2 service.ListRoomsOptions
+	}{arg1, arg2})
+	stub := fake.ListRoomsPageStub
+	fakeReturns := fake.listRoomsPageReturns
+	fake.recordInvocation("ListRoomsPage", []interface{}{arg1, arg2})
+	fake.listRoomsPageMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeServiceStore) ListRoomsPageCallCount() int {
+	fake.listRoomsPageMutex.RLock()
+	defer fake.listRoomsPageMutex.RUnlock()
+	return len(fake.listRoomsPageArgsForCall)
+}
+
+func (fake *FakeServiceStore) ListRoomsPageCalls(stub func(context.Context, service.ListRoomsOptions) ([]*livekit.Room, string, error)) {
+	fake.listRoomsPageMutex.Lock()
+	defer fake.listRoomsPageMutex.Unlock()
+	fake.ListRoomsPageStub = stub
+}
+
+func (fake *FakeServiceStore) ListRoomsPageArgsForCall(i int) (context.Context, service.ListRoomsOptions) {
+	fake.listRoomsPageMutex.RLock()
+	defer fake.listRoomsPageMutex.RUnlock()
+	argsForCall := fake.listRoomsPageArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeServiceStore) ListRoomsPageReturns(result1 []*livekit.Room, result2 string, result3 error) {
+	fake.listRoomsPageMutex.Lock()
+	defer fake.listRoomsPageMutex.Unlock()
+	fake.ListRoomsPageStub = nil
+	fake.listRoomsPageReturns = struct {
+		result1 []*livekit.Room
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeServiceStore) ListRoomsPageReturnsOnCall(i int, result1 []*livekit.Room, result2 string, result3 error) {
+	fake.listRoomsPageMutex.Lock()
+	defer fake.listRoomsPageMutex.Unlock()
+	fake.ListRoomsPageStub = nil
+	if fake.listRoomsPageReturnsOnCall == nil {
+		fake.listRoomsPageReturnsOnCall = make(map[int]struct {
+			result1 []*livekit.Room
+			result2 string
+			result3 error
+		})
+	}
+	fake.listRoomsPageReturnsOnCall[i] = struct {
+		result1 []*livekit.Room
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
 func (fake *FakeServiceStore) LoadParticipant(arg1 context.Context, arg2 livekit.RoomName, arg3 livekit.ParticipantIdentity) (*livekit.ParticipantInfo, error) {
 	fake.loadParticipantMutex.Lock()
 	ret, specificReturn := fake.loadParticipantReturnsOnCall[len(fake.loadParticipantArgsForCall)]
@@ -272,6 +624,72 @@ func (fake *FakeServiceStore) LoadParticipantReturnsOnCall(i int, result1 *livek
 	}{result1, result2}
 }
 
+func (fake *FakeServiceStore) LoadPendingParticipant(arg1 context.Context, arg2 livekit.RoomName, arg3 livekit.ParticipantIdentity) (*service.PendingParticipant, error) {
+	fake.loadPendingParticipantMutex.Lock()
+	ret, specificReturn := fake.loadPendingParticipantReturnsOnCall[len(fake.loadPendingParticipantArgsForCall)]
+	fake.loadPendingParticipantArgsForCall = append(fake.loadPendingParticipantArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+		arg3 livekit.ParticipantIdentity
+	}{arg1, arg2, arg3})
+	stub := fake.LoadPendingParticipantStub
+	fakeReturns := fake.loadPendingParticipantReturns
+	fake.recordInvocation("LoadPendingParticipant", []interface{}{arg1, arg2, arg3})
+	fake.loadPendingParticipantMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceStore) LoadPendingParticipantCallCount() int {
+	fake.loadPendingParticipantMutex.RLock()
+	defer fake.loadPendingParticipantMutex.RUnlock()
+	return len(fake.loadPendingParticipantArgsForCall)
+}
+
+func (fake *FakeServiceStore) LoadPendingParticipantCalls(stub func(context.Context, livekit.RoomName, livekit.ParticipantIdentity) (*service.PendingParticipant, error)) {
+	fake.loadPendingParticipantMutex.Lock()
+	defer fake.loadPendingParticipantMutex.Unlock()
+	fake.LoadPendingParticipantStub = stub
+}
+
+func (fake *FakeServiceStore) LoadPendingParticipantArgsForCall(i int) (context.Context, livekit.RoomName, livekit.ParticipantIdentity) {
+	fake.loadPendingParticipantMutex.RLock()
+	defer fake.loadPendingParticipantMutex.RUnlock()
+	argsForCall := fake.loadPendingParticipantArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeServiceStore) LoadPendingParticipantReturns(result1 *service.PendingParticipant, result2 error) {
+	fake.loadPendingParticipantMutex.Lock()
+	defer fake.loadPendingParticipantMutex.Unlock()
+	fake.LoadPendingParticipantStub = nil
+	fake.loadPendingParticipantReturns = struct {
+		result1 *service.PendingParticipant
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceStore) LoadPendingParticipantReturnsOnCall(i int, result1 *service.PendingParticipant, result2 error) {
+	fake.loadPendingParticipantMutex.Lock()
+	defer fake.loadPendingParticipantMutex.Unlock()
+	fake.LoadPendingParticipantStub = nil
+	if fake.loadPendingParticipantReturnsOnCall == nil {
+		fake.loadPendingParticipantReturnsOnCall = make(map[int]struct {
+			result1 *service.PendingParticipant
+			result2 error
+		})
+	}
+	fake.loadPendingParticipantReturnsOnCall[i] = struct {
+		result1 *service.PendingParticipant
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeServiceStore) LoadRoom(arg1 context.Context, arg2 livekit.RoomName) (*livekit.Room, error) {
 	fake.loadRoomMutex.Lock()
 	ret, specificReturn := fake.loadRoomReturnsOnCall[len(fake.loadRoomArgsForCall)]
@@ -337,17 +755,94 @@ func (fake *FakeServiceStore) LoadRoomReturnsOnCall(i int, result1 *livekit.Room
 	}{result1, result2}
 }
 
+func (fake *FakeServiceStore) LoadRoomInternal(arg1 context.Context, arg2 livekit.RoomName) (*service.RoomInternal, error) {
+	fake.loadRoomInternalMutex.Lock()
+	ret, specificReturn := fake.loadRoomInternalReturnsOnCall[len(fake.loadRoomInternalArgsForCall)]
+	fake.loadRoomInternalArgsForCall = append(fake.loadRoomInternalArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.RoomName
+	}{arg1, arg2})
+	stub := fake.LoadRoomInternalStub
+	fakeReturns := fake.loadRoomInternalReturns
+	fake.recordInvocation("LoadRoomInternal", []interface{}{arg1, arg2})
+	fake.loadRoomInternalMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceStore) LoadRoomInternalCallCount() int {
+	fake.loadRoomInternalMutex.RLock()
+	defer fake.loadRoomInternalMutex.RUnlock()
+	return len(fake.loadRoomInternalArgsForCall)
+}
+
+func (fake *FakeServiceStore) LoadRoomInternalCalls(stub func(context.Context, livekit.RoomName) (*service.RoomInternal, error)) {
+	fake.loadRoomInternalMutex.Lock()
+	defer fake.loadRoomInternalMutex.Unlock()
+	fake.LoadRoomInternalStub = stub
+}
+
+func (fake *FakeServiceStore) LoadRoomInternalArgsForCall(i int) (context.Context, livekit.RoomName) {
+	fake.loadRoomInternalMutex.RLock()
+	defer fake.loadRoomInternalMutex.RUnlock()
+	argsForCall := fake.loadRoomInternalArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeServiceStore) LoadRoomInternalReturns(result1 *service.RoomInternal, result2 error) {
+	fake.loadRoomInternalMutex.Lock()
+	defer fake.loadRoomInternalMutex.Unlock()
+	fake.LoadRoomInternalStub = nil
+	fake.loadRoomInternalReturns = struct {
+		result1 *service.RoomInternal
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceStore) LoadRoomInternalReturnsOnCall(i int, result1 *service.RoomInternal, result2 error) {
+	fake.loadRoomInternalMutex.Lock()
+	defer fake.loadRoomInternalMutex.Unlock()
+	fake.LoadRoomInternalStub = nil
+	if fake.loadRoomInternalReturnsOnCall == nil {
+		fake.loadRoomInternalReturnsOnCall = make(map[int]struct {
+			result1 *service.RoomInternal
+			result2 error
+		})
+	}
+	fake.loadRoomInternalReturnsOnCall[i] = struct {
+		result1 *service.RoomInternal
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeServiceStore) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.countRoomsMutex.RLock()
+	defer fake.countRoomsMutex.RUnlock()
+	fake.isParticipantBlockedMutex.RLock()
+	defer fake.isParticipantBlockedMutex.RUnlock()
 	fake.listParticipantsMutex.RLock()
 	defer fake.listParticipantsMutex.RUnlock()
+	fake.listPendingParticipantsMutex.RLock()
+	defer fake.listPendingParticipantsMutex.RUnlock()
 	fake.listRoomsMutex.RLock()
 	defer fake.listRoomsMutex.RUnlock()
+	fake.listRoomsPageMutex.RLock()
+	defer fake.listRoomsPageMutex.RUnlock()
 	fake.loadParticipantMutex.RLock()
 	defer fake.loadParticipantMutex.RUnlock()
+	fake.loadPendingParticipantMutex.RLock()
+	defer fake.loadPendingParticipantMutex.RUnlock()
 	fake.loadRoomMutex.RLock()
 	defer fake.loadRoomMutex.RUnlock()
+	fake.loadRoomInternalMutex.RLock()
+	defer fake.loadRoomInternalMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
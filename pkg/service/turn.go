@@ -1,29 +1,30 @@
 package service
 
 import (
-	"context"
 	"crypto/tls"
+	"fmt"
 	"net"
 	"strconv"
+	"time"
 
-	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
 	"github.com/pion/turn/v2"
 	"github.com/pkg/errors"
+	"go.uber.org/atomic"
 
 	"github.com/livekit/livekit-server/pkg/config"
 	logging "github.com/livekit/livekit-server/pkg/logger"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
 const (
 	LivekitRealm = "livekit"
 
-	allocateRetries = 50
-	turnMinPort     = 1024
-	turnMaxPort     = 30000
+	allocateRetries     = 50
+	turnSummaryInterval = time.Minute
 )
 
-func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler) (*turn.Server, error) {
+func NewTurnServer(conf *config.Config, authHandler *TurnAuthHandler) (*turn.Server, error) {
 	turnConf := conf.TURN
 	if !turnConf.Enabled {
 		return nil, nil
@@ -35,17 +36,30 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler) (*turn.Ser
 
 	serverConfig := turn.ServerConfig{
 		Realm:         LivekitRealm,
-		AuthHandler:   authHandler,
+		AuthHandler:   authHandler.HandleAuth,
 		LoggerFactory: logging.NewLoggerFactory(logger.GetLogger()),
 	}
-	relayAddrGen := &turn.RelayAddressGeneratorPortRange{
-		RelayAddress: net.ParseIP(conf.RTC.NodeIP),
-		Address:      "0.0.0.0",
-		MinPort:      turnMinPort,
-		MaxPort:      turnMaxPort,
-		MaxRetries:   allocateRetries,
+	relayAddress := turnConf.RelayAddress
+	if relayAddress == "" {
+		relayAddress = conf.RTC.NodeIP
+	}
+	// by the protocol clients reach the server with
+	relayAddrGen := func(protocol string) turn.RelayAddressGenerator {
+		return &turnRelayAddressGenerator{
+			RelayAddressGenerator: &turn.RelayAddressGeneratorPortRange{
+				RelayAddress: net.ParseIP(relayAddress),
+				Address:      "0.0.0.0",
+				MinPort:      uint16(turnConf.RelayPortRangeStart),
+				MaxPort:      uint16(turnConf.RelayPortRangeEnd),
+				MaxRetries:   allocateRetries,
+			},
+			protocol: protocol,
+		}
+	}
+	logValues := []interface{}{
+		"turn.relayAddress", relayAddress,
+		"turn.relayPortRange", fmt.Sprintf("%d-%d", turnConf.RelayPortRangeStart, turnConf.RelayPortRangeEnd),
 	}
-	var logValues []interface{}
 
 	if turnConf.TLSPort > 0 {
 		if turnConf.Domain == "" {
@@ -57,23 +71,38 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler) (*turn.Ser
 		}
 
 		if !turnConf.ExternalTLS {
-			cert, err := tls.LoadX509KeyPair(turnConf.CertFile, turnConf.KeyFile)
-			if err != nil {
-				return nil, errors.Wrap(err, "TURN tls cert required")
+			var tlsConfig *tls.Config
+			var onClose func()
+			if turnConf.ACME.Enabled() {
+				tlsConfig = newTurnACMETLSConfig(turnConf)
+				logValues = append(logValues, "turn.acme", true)
+			} else {
+				cert, err := NewReloadingCertificate(turnConf.CertFile, turnConf.KeyFile)
+				if err != nil {
+					return nil, errors.Wrap(err, "TURN tls cert required")
+				}
+				tlsConfig = &tls.Config{
+					MinVersion:     tls.VersionTLS12,
+					GetCertificate: cert.GetCertificate,
+				}
+				onClose = cert.Stop
+				logValues = append(logValues, "turn.certNotAfter", cert.NotAfter())
 			}
 
-			tlsListener, err := tls.Listen("tcp4", "0.0.0.0:"+strconv.Itoa(turnConf.TLSPort),
-				&tls.Config{
-					MinVersion:   tls.VersionTLS12,
-					Certificates: []tls.Certificate{cert},
-				})
+			tlsListener, err := tls.Listen("tcp4", "0.0.0.0:"+strconv.Itoa(turnConf.TLSPort), tlsConfig)
 			if err != nil {
+				if onClose != nil {
+					onClose()
+				}
 				return nil, errors.Wrap(err, "could not listen on TURN TCP port")
 			}
+			if onClose != nil {
+				tlsListener = &closingListener{Listener: tlsListener, onClose: onClose}
+			}
 
 			listenerConfig := turn.ListenerConfig{
-				Listener:              tlsListener,
-				RelayAddressGenerator: relayAddrGen,
+				Listener:              authHandler.wrapListener(tlsListener),
+				RelayAddressGenerator: relayAddrGen("tls"),
 			}
 			serverConfig.ListenerConfigs = append(serverConfig.ListenerConfigs, listenerConfig)
 		} else {
@@ -83,8 +112,8 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler) (*turn.Ser
 			}
 
 			listenerConfig := turn.ListenerConfig{
-				Listener:              tcpListener,
-				RelayAddressGenerator: relayAddrGen,
+				Listener:              authHandler.wrapListener(tcpListener),
+				RelayAddressGenerator: relayAddrGen("tls"),
 			}
 			serverConfig.ListenerConfigs = append(serverConfig.ListenerConfigs, listenerConfig)
 		}
@@ -98,25 +127,77 @@ func NewTurnServer(conf *config.Config, authHandler turn.AuthHandler) (*turn.Ser
 		}
 
 		packetConfig := turn.PacketConnConfig{
-			PacketConn:            udpListener,
-			RelayAddressGenerator: relayAddrGen,
+			PacketConn:            authHandler.wrapPacketConn(udpListener),
+			RelayAddressGenerator: relayAddrGen("udp"),
 		}
 		serverConfig.PacketConnConfigs = append(serverConfig.PacketConnConfigs, packetConfig)
 		logValues = append(logValues, "turn.portUDP", turnConf.UDPPort)
 	}
+	if turnConf.AuthSecret != "" {
+		logValues = append(logValues, "turn.credentialTTL", time.Duration(turnConf.CredentialTTL))
+	}
 
 	logger.Infow("Starting TURN server", logValues...)
 	return turn.NewServer(serverConfig)
 }
 
-func newTurnAuthHandler(roomStore ObjectStore) turn.AuthHandler {
-	return func(username, realm string, srcAddr net.Addr) (key []byte, ok bool) {
-		// room id should be the username, create a hashed room id
-		rm, err := roomStore.LoadRoom(context.Background(), livekit.RoomName(username))
-		if err != nil {
-			return nil, false
-		}
+// turnRelayAddressGenerator counts the relays allocated and the bytes they relay
+type turnRelayAddressGenerator struct {
+	turn.RelayAddressGenerator
+	protocol string
+}
+
+func (g *turnRelayAddressGenerator) AllocatePacketConn(network string, requestedPort int) (net.PacketConn, net.Addr, error) {
+	conn, addr, err := g.RelayAddressGenerator.AllocatePacketConn(network, requestedPort)
+	if err != nil {
+		return nil, nil, err
+	}
+	prometheus.AddTURNAllocation(g.protocol)
+	return &turnRelayConn{PacketConn: conn, protocol: g.protocol}, addr, nil
+}
+
+// turnRelayConn is the socket of a relay, closed when its allocation is deleted
+type turnRelayConn struct {
+	net.PacketConn
+	protocol string
+	closed   atomic.Bool
+}
+
+// ReadFrom reads from peers
+func (c *turnRelayConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if n > 0 {
+		prometheus.IncrementTURNRelayedBytes(c.protocol, prometheus.Incoming, n)
+	}
+	return n, addr, err
+}
 
-		return turn.GenerateAuthKey(username, LivekitRealm, rm.TurnPassword), true
+// WriteTo writes to peers
+func (c *turnRelayConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(b, addr)
+	if n > 0 {
+		prometheus.IncrementTURNRelayedBytes(c.protocol, prometheus.Outgoing, n)
 	}
+	return n, err
+}
+
+func (c *turnRelayConn) Close() error {
+	if c.closed.CAS(false, true) {
+		prometheus.SubTURNAllocation(c.protocol)
+	}
+	return c.PacketConn.Close()
+}
+
+// logTurnSummary logs the activity of the TURN server since prev, and returns the current stats
+func logTurnSummary(prev prometheus.TURNStats) prometheus.TURNStats {
+	stats := prometheus.GetTURNStats()
+	logger.Infow("TURN summary",
+		"allocations", stats.Allocations,
+		"allocationsCreated", stats.AllocationsCreated-prev.AllocationsCreated,
+		"allocationsExpired", stats.AllocationsExpired-prev.AllocationsExpired,
+		"bytesIn", stats.BytesIn-prev.BytesIn,
+		"bytesOut", stats.BytesOut-prev.BytesOut,
+		"authFailures", stats.AuthFailures-prev.AuthFailures,
+	)
+	return stats
 }
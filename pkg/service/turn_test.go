@@ -0,0 +1,273 @@
+package service_test
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pion/turn/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/service"
+	"github.com/livekit/livekit-server/pkg/service/servicefakes"
+)
+
+func freePort(t *testing.T, network string) int {
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).Port
+	}
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func newRESTTurnServer(t *testing.T, secret, previousSecret string) (udpAddr string, tcpAddr string) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	conf.RTC.NodeIP = "127.0.0.1"
+	conf.TURN.Enabled = true
+	conf.TURN.Domain = "turn.example.com"
+	conf.TURN.ExternalTLS = true
+	conf.TURN.UDPPort = freePort(t, "udp")
+	conf.TURN.TLSPort = freePort(t, "tcp")
+	conf.TURN.AuthSecret = secret
+	conf.TURN.PreviousAuthSecret = previousSecret
+
+	server, err := service.NewTurnServer(conf, service.NewTurnAuthHandler(conf, &servicefakes.FakeObjectStore{}))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(conf.TURN.UDPPort)), net.JoinHostPort("127.0.0.1", strconv.Itoa(conf.TURN.TLSPort))
+}
+
+func allocate(t *testing.T, network, addr, username, password string) error {
+	var conn net.PacketConn
+	if network == "udp" {
+		udpConn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		conn = udpConn
+	} else {
+		tcpConn, err := net.Dial("tcp4", addr)
+		require.NoError(t, err)
+		conn = turn.NewSTUNConn(tcpConn)
+	}
+	defer conn.Close()
+
+	client, err := turn.NewClient(&turn.ClientConfig{
+		STUNServerAddr: addr,
+		TURNServerAddr: addr,
+		Username:       username,
+		Password:       password,
+		Conn:           conn,
+		RTO:            100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.Listen())
+
+	relayConn, err := client.Allocate()
+	if err != nil {
+		return err
+	}
+	return relayConn.Close()
+}
+
+func authFailures(t *testing.T, reason string) float64 {
+	return turnMetric(t, "livekit_turn_auth_failures_total", map[string]string{"reason": reason})
+}
+
+func TestTurnRESTCredentials(t *testing.T) {
+	udpAddr, tcpAddr := newRESTTurnServer(t, "secret", "")
+	valid := time.Now().Add(time.Hour)
+
+	t.Run("valid credentials allocate", func(t *testing.T) {
+		username, credential := service.GenerateTurnCredentials("secret", "alice", valid)
+		require.Regexp(t, `^\d+:alice$`, username)
+		require.NoError(t, allocate(t, "udp", udpAddr, username, credential))
+		require.NoError(t, allocate(t, "tcp", tcpAddr, username, credential))
+	})
+
+	t.Run("expired credentials are rejected", func(t *testing.T) {
+		failures := authFailures(t, "expired")
+		username, credential := service.GenerateTurnCredentials("secret", "alice", time.Now().Add(-time.Second))
+		require.Error(t, allocate(t, "udp", udpAddr, username, credential))
+		require.Equal(t, failures+1, authFailures(t, "expired"))
+	})
+
+	t.Run("allocations outlive their credentials", func(t *testing.T) {
+		username, credential := service.GenerateTurnCredentials("secret", "alice", time.Now().Add(time.Second))
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer conn.Close()
+		client, err := turn.NewClient(&turn.ClientConfig{
+			STUNServerAddr: udpAddr,
+			TURNServerAddr: udpAddr,
+			Username:       username,
+			Password:       credential,
+			Conn:           conn,
+			RTO:            100 * time.Millisecond,
+		})
+		require.NoError(t, err)
+		defer client.Close()
+		require.NoError(t, client.Listen())
+		relayConn, err := client.Allocate()
+		require.NoError(t, err)
+		defer relayConn.Close()
+
+		time.Sleep(time.Until(time.Now().Add(time.Second).Truncate(time.Second)) + time.Second)
+		// creating a permission is authenticated with the expired credentials
+		peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer peer.Close()
+		_, err = relayConn.WriteTo([]byte("hello"), peer.LocalAddr())
+		require.NoError(t, err)
+
+		// other clients can't allocate with them
+		require.Error(t, allocate(t, "udp", udpAddr, username, credential))
+	})
+
+	t.Run("tampered credentials are rejected", func(t *testing.T) {
+		failures := authFailures(t, "invalid_credential")
+		username, credential := service.GenerateTurnCredentials("secret", "alice", valid)
+		// a password signed for another expiry, or an altered username
+		_, otherCredential := service.GenerateTurnCredentials("secret", "alice", valid.Add(time.Hour))
+		require.Error(t, allocate(t, "udp", udpAddr, username, otherCredential))
+		require.Error(t, allocate(t, "udp", udpAddr, username[:len(username)-1]+"b", credential))
+
+		username, credential = service.GenerateTurnCredentials("other-secret", "alice", valid)
+		require.Error(t, allocate(t, "udp", udpAddr, username, credential))
+		require.Equal(t, failures+3, authFailures(t, "invalid_credential"))
+	})
+
+	t.Run("malformed usernames are rejected", func(t *testing.T) {
+		failures := authFailures(t, "malformed_username")
+		require.Error(t, allocate(t, "udp", udpAddr, "alice", "password"))
+		require.Equal(t, failures+1, authFailures(t, "malformed_username"))
+	})
+}
+
+func TestTurnRESTCredentialsRotation(t *testing.T) {
+	udpAddr, tcpAddr := newRESTTurnServer(t, "new-secret", "old-secret")
+	valid := time.Now().Add(time.Hour)
+
+	for _, secret := range []string{"new-secret", "old-secret"} {
+		username, credential := service.GenerateTurnCredentials(secret, "alice", valid)
+		require.NoError(t, allocate(t, "udp", udpAddr, username, credential), secret)
+		require.NoError(t, allocate(t, "tcp", tcpAddr, username, credential), secret)
+	}
+
+	username, credential := service.GenerateTurnCredentials("other-secret", "alice", valid)
+	require.Error(t, allocate(t, "udp", udpAddr, username, credential))
+	require.Error(t, allocate(t, "tcp", tcpAddr, username, credential))
+}
+
+// turnMetric returns the value of the TURN metric with the label values, zero before it's been set
+func turnMetric(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if value, ok := labels[label.GetName()]; ok && value != label.GetValue() {
+					continue metrics
+				}
+			}
+			if m.GetGauge() != nil {
+				return m.GetGauge().GetValue()
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestTurnRelay(t *testing.T) {
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	conf.RTC.NodeIP = "127.0.0.1"
+	conf.TURN.Enabled = true
+	conf.TURN.UDPPort = freePort(t, "udp")
+	conf.TURN.AuthSecret = "secret"
+	conf.TURN.RelayPortRangeStart = 45000
+	conf.TURN.RelayPortRangeEnd = 45100
+	// the address clients are given, while the relay listens on all interfaces
+	conf.TURN.RelayAddress = "203.0.113.10"
+
+	server, err := service.NewTurnServer(conf, service.NewTurnAuthHandler(conf, &servicefakes.FakeObjectStore{}))
+	require.NoError(t, err)
+	defer server.Close()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(conf.TURN.UDPPort))
+	username, credential := service.GenerateTurnCredentials("secret", "alice", time.Now().Add(time.Hour))
+	client, err := turn.NewClient(&turn.ClientConfig{
+		STUNServerAddr: addr,
+		TURNServerAddr: addr,
+		Username:       username,
+		Password:       credential,
+		Conn:           conn,
+	})
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.Listen())
+
+	udp := map[string]string{"protocol": "udp"}
+	incomingUDP := map[string]string{"protocol": "udp", "direction": "incoming"}
+	outgoingUDP := map[string]string{"protocol": "udp", "direction": "outgoing"}
+	allocations := turnMetric(t, "livekit_turn_allocations", udp)
+	created := turnMetric(t, "livekit_turn_allocations_created_total", udp)
+	expired := turnMetric(t, "livekit_turn_allocations_expired_total", udp)
+	incoming := turnMetric(t, "livekit_turn_relayed_bytes_total", incomingUDP)
+	outgoing := turnMetric(t, "livekit_turn_relayed_bytes_total", outgoingUDP)
+
+	relayConn, err := client.Allocate()
+	require.NoError(t, err)
+	relayAddr := relayConn.LocalAddr().(*net.UDPAddr)
+	require.Equal(t, "203.0.113.10", relayAddr.IP.String())
+	require.GreaterOrEqual(t, relayAddr.Port, 45000)
+	require.LessOrEqual(t, relayAddr.Port, 45100)
+	require.Equal(t, allocations+1, turnMetric(t, "livekit_turn_allocations", udp))
+	require.Equal(t, created+1, turnMetric(t, "livekit_turn_allocations_created_total", udp))
+
+	// relay to a peer and back
+	peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer peer.Close()
+	_, err = relayConn.WriteTo([]byte("hello"), peer.LocalAddr())
+	require.NoError(t, err)
+	buf := make([]byte, 100)
+	require.NoError(t, peer.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := peer.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf[:n]))
+
+	_, err = peer.WriteTo([]byte("hi"), &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: relayAddr.Port})
+	require.NoError(t, err)
+	require.NoError(t, relayConn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err = relayConn.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "hi", string(buf[:n]))
+
+	require.Equal(t, outgoing+5, turnMetric(t, "livekit_turn_relayed_bytes_total", outgoingUDP))
+	require.Equal(t, incoming+2, turnMetric(t, "livekit_turn_relayed_bytes_total", incomingUDP))
+
+	require.NoError(t, relayConn.Close())
+	require.Eventually(t, func() bool {
+		return turnMetric(t, "livekit_turn_allocations", udp) == allocations
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, expired+1, turnMetric(t, "livekit_turn_allocations_expired_total", udp))
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/pion/stun"
+	"github.com/pion/turn/v2"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	// messages recorded to tell which secret signed a client's credentials are kept at most this long
+	turnMessageLifetime = time.Minute
+	// clients are forgotten when they haven't authenticated for longer than the maximum allocation lifetime
+	turnClientLifetime = time.Hour
+)
+
+// TurnAuthHandler authenticates TURN clients with their room's password, or when turn.auth_secret is set, with
+// time-limited credentials issued to each participant
+type TurnAuthHandler struct {
+	roomStore ObjectStore
+	// current secret first
+	secrets []string
+
+	// the latest signed message of each client. The auth callback is only given the username, the message tells
+	// which secret signed its credentials, or that they're invalid
+	lock     sync.Mutex
+	messages map[string]turnMessage
+	sweptAt  time.Time
+
+	// clients that authenticated before their credentials expired, by address. Credentials are only issued at join,
+	// the client keeps using them for the requests refreshing its allocation, which are accepted after they expire.
+	// New allocations, i.e. on ICE restarts, require credentials that haven't expired
+	clientsLock    sync.Mutex
+	clients        map[string]turnClient
+	clientsSweptAt time.Time
+}
+
+type turnClient struct {
+	username string
+	at       time.Time
+}
+
+type turnMessage struct {
+	msg *stun.Message
+	at  time.Time
+}
+
+func NewTurnAuthHandler(conf *config.Config, roomStore ObjectStore) *TurnAuthHandler {
+	h := &TurnAuthHandler{
+		roomStore: roomStore,
+		messages:  make(map[string]turnMessage),
+		clients:   make(map[string]turnClient),
+	}
+	if conf.TURN.AuthSecret != "" {
+		h.secrets = append(h.secrets, conf.TURN.AuthSecret)
+		if conf.TURN.PreviousAuthSecret != "" {
+			h.secrets = append(h.secrets, conf.TURN.PreviousAuthSecret)
+		}
+	}
+	return h
+}
+
+// GenerateTurnCredentials returns credentials for identity that are valid until expiry, signed with secret
+func GenerateTurnCredentials(secret string, identity livekit.ParticipantIdentity, expiry time.Time) (username string, credential string) {
+	username = strconv.FormatInt(expiry.Unix(), 10) + ":" + string(identity)
+	return username, turnCredential(secret, username)
+}
+
+func turnCredential(secret string, username string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func turnCredentialExpiry(username string) (time.Time, bool) {
+	idx := strings.IndexByte(username, ':')
+	if idx <= 0 {
+		return time.Time{}, false
+	}
+	expiry, err := strconv.ParseInt(username[:idx], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(expiry, 0), true
+}
+
+func (h *TurnAuthHandler) HandleAuth(username, realm string, srcAddr net.Addr) (key []byte, ok bool) {
+	var keys [][]byte
+	if len(h.secrets) == 0 {
+		// room id should be the username, create a hashed room id
+		rm, err := h.roomStore.LoadRoom(context.Background(), livekit.RoomName(username))
+		if err != nil {
+			prometheus.IncrementTURNAuthFailure("unknown_room")
+			return nil, false
+		}
+		keys = append(keys, turn.GenerateAuthKey(username, LivekitRealm, rm.TurnPassword))
+	} else {
+		expiry, ok := turnCredentialExpiry(username)
+		if !ok {
+			prometheus.IncrementTURNAuthFailure("malformed_username")
+			return nil, false
+		}
+		if !time.Now().Before(expiry) && !h.authenticatedBefore(srcAddr, username) {
+			prometheus.IncrementTURNAuthFailure("expired")
+			return nil, false
+		}
+		h.recordClient(srcAddr, username)
+		for _, secret := range h.secrets {
+			keys = append(keys, turn.GenerateAuthKey(username, LivekitRealm, turnCredential(secret, username)))
+		}
+	}
+
+	msg := h.lastMessage(srcAddr, username)
+	if msg == nil {
+		// not seen yet, the server checks the integrity against the current secret
+		return keys[0], true
+	}
+	for _, key := range keys {
+		if stun.MessageIntegrity(key).Check(msg) == nil {
+			return key, true
+		}
+	}
+	prometheus.IncrementTURNAuthFailure("invalid_credential")
+	return nil, false
+}
+
+func (h *TurnAuthHandler) recordMessage(addr net.Addr, b []byte) {
+	if !stun.IsMessage(b) {
+		return
+	}
+	msg := &stun.Message{Raw: append([]byte{}, b...)}
+	if msg.Decode() != nil || !msg.Contains(stun.AttrMessageIntegrity) {
+		return
+	}
+
+	now := time.Now()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.messages[addr.String()] = turnMessage{msg: msg, at: now}
+	if now.Sub(h.sweptAt) > turnMessageLifetime {
+		for k, m := range h.messages {
+			if now.Sub(m.at) > turnMessageLifetime {
+				delete(h.messages, k)
+			}
+		}
+		h.sweptAt = now
+	}
+}
+
+// authenticatedBefore returns whether the client at addr authenticated as username before its credentials expired
+func (h *TurnAuthHandler) authenticatedBefore(addr net.Addr, username string) bool {
+	h.clientsLock.Lock()
+	defer h.clientsLock.Unlock()
+	c, ok := h.clients[addr.String()]
+	return ok && c.username == username
+}
+
+func (h *TurnAuthHandler) recordClient(addr net.Addr, username string) {
+	now := time.Now()
+	h.clientsLock.Lock()
+	defer h.clientsLock.Unlock()
+	h.clients[addr.String()] = turnClient{username: username, at: now}
+	if now.Sub(h.clientsSweptAt) > turnMessageLifetime {
+		for k, c := range h.clients {
+			if now.Sub(c.at) > turnClientLifetime {
+				delete(h.clients, k)
+			}
+		}
+		h.clientsSweptAt = now
+	}
+}
+
+// lastMessage returns the latest signed message from addr, if it's from username
+func (h *TurnAuthHandler) lastMessage(addr net.Addr, username string) *stun.Message {
+	h.lock.Lock()
+	msg := h.messages[addr.String()].msg
+	h.lock.Unlock()
+	if msg == nil {
+		return nil
+	}
+
+	var u stun.Username
+	if u.GetFrom(msg) != nil || u.String() != username {
+		return nil
+	}
+	return msg
+}
+
+func (h *TurnAuthHandler) wrapPacketConn(conn net.PacketConn) net.PacketConn {
+	return &turnRecordingPacketConn{PacketConn: conn, handler: h}
+}
+
+func (h *TurnAuthHandler) wrapListener(listener net.Listener) net.Listener {
+	return &turnRecordingListener{Listener: listener, handler: h}
+}
+
+type turnRecordingPacketConn struct {
+	net.PacketConn
+	handler *TurnAuthHandler
+}
+
+func (c *turnRecordingPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err == nil {
+		c.handler.recordMessage(addr, b[:n])
+	}
+	return n, addr, err
+}
+
+type turnRecordingListener struct {
+	net.Listener
+	handler *TurnAuthHandler
+}
+
+func (l *turnRecordingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &turnRecordingConn{Conn: conn, handler: l.handler}, nil
+}
+
+// turnRecordingConn splits the stream into STUN messages and channel data, as the TURN server does
+type turnRecordingConn struct {
+	net.Conn
+	handler *TurnAuthHandler
+	buf     []byte
+	// stream isn't framed as expected, stop recording
+	invalid bool
+}
+
+func (c *turnRecordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 && !c.invalid {
+		c.buf = append(c.buf, b[:n]...)
+		c.frame()
+	}
+	return n, err
+}
+
+func (c *turnRecordingConn) frame() {
+	for len(c.buf) >= 4 {
+		length := int(binary.BigEndian.Uint16(c.buf[2:4]))
+		var size int
+		switch c.buf[0] >> 6 {
+		case 0:
+			// STUN header is 20 bytes
+			size = 20 + length
+		case 1:
+			// channel data is padded to 4 bytes over streams
+			size = 4 + (length+3)&^3
+		default:
+			c.invalid = true
+			c.buf = nil
+			return
+		}
+		if len(c.buf) < size {
+			return
+		}
+		if c.buf[0]>>6 == 0 {
+			c.handler.recordMessage(c.RemoteAddr(), c.buf[:size])
+		}
+		c.buf = c.buf[size:]
+	}
+	if len(c.buf) == 0 {
+		c.buf = nil
+	}
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const certFilePollInterval = 5 * time.Second
+
+// ReloadingCertificate is a TLS certificate backed by a cert and key file. The files are re-read when either changes
+// or when the process receives SIGHUP, and only new handshakes use the new certificate, existing connections are kept.
+// When the files can't be loaded, the previous certificate keeps being served.
+type ReloadingCertificate struct {
+	certFile string
+	keyFile  string
+
+	lock        sync.RWMutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewReloadingCertificate loads the cert and key pair and starts watching the files for changes
+func NewReloadingCertificate(certFile, keyFile string) (*ReloadingCertificate, error) {
+	c := &ReloadingCertificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+		done:     make(chan struct{}),
+	}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	go c.watch()
+	return c, nil
+}
+
+// GetCertificate is the tls.Config callback returning the current certificate
+func (c *ReloadingCertificate) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.cert, nil
+}
+
+// NotAfter returns when the current certificate expires
+func (c *ReloadingCertificate) NotAfter() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.cert.Leaf.NotAfter
+}
+
+// Reload re-reads the cert and key files. On failure, the previously loaded certificate is kept
+func (c *ReloadingCertificate) Reload() error {
+	certStat, err := os.Stat(c.certFile)
+	if err != nil {
+		return err
+	}
+	keyStat, err := os.Stat(c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	c.cert = &cert
+	c.certModTime = certStat.ModTime()
+	c.keyModTime = keyStat.ModTime()
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *ReloadingCertificate) Stop() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *ReloadingCertificate) watch() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	ticker := time.NewTicker(certFilePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-sigChan:
+			c.reloadAndLog("signal")
+		case <-ticker.C:
+			if c.hasChanged() {
+				c.reloadAndLog("file changed")
+			}
+		}
+	}
+}
+
+func (c *ReloadingCertificate) hasChanged() bool {
+	certStat, err := os.Stat(c.certFile)
+	if err != nil {
+		return false
+	}
+	keyStat, err := os.Stat(c.keyFile)
+	if err != nil {
+		return false
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return !certStat.ModTime().Equal(c.certModTime) || !keyStat.ModTime().Equal(c.keyModTime)
+}
+
+func (c *ReloadingCertificate) reloadAndLog(reason string) {
+	if err := c.Reload(); err != nil {
+		// files are often replaced one at a time, the next change or signal retries
+		logger.Errorw("could not reload TURN certificate, still serving the previous one", err,
+			"certFile", c.certFile, "keyFile", c.keyFile, "reason", reason, "notAfter", c.NotAfter())
+		return
+	}
+	logger.Infow("reloaded TURN certificate", "certFile", c.certFile, "reason", reason, "notAfter", c.NotAfter())
+}
+
+// newTurnACMETLSConfig obtains and renews the certificate for the TURN domain with the TLS-ALPN-01 challenge,
+// answered on the TURN TLS listener
+func newTurnACMETLSConfig(turnConf config.TURNConfig) *tls.Config {
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(turnConf.ACME.CacheDir),
+		HostPolicy: autocert.HostWhitelist(turnConf.Domain),
+		Email:      turnConf.ACME.Email,
+	}
+	if turnConf.ACME.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: turnConf.ACME.DirectoryURL}
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{acme.ALPNProto},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName == "" {
+				// TURN clients don't always send SNI, there's only one domain anyway
+				h := *hello
+				h.ServerName = turnConf.Domain
+				hello = &h
+			}
+			cert, err := m.GetCertificate(hello)
+			if err != nil {
+				// no certificate yet, the CA couldn't verify the domain or the client asked for another name.
+				// Renewals happen in the background, the current certificate is served until they succeed
+				logger.Errorw("could not get TURN certificate from ACME", err, "domain", turnConf.Domain)
+			}
+			return cert, err
+		},
+	}
+}
+
+// closingListener stops the certificate reloader with the listener
+type closingListener struct {
+	net.Listener
+	onClose func()
+}
+
+func (l *closingListener) Close() error {
+	l.onClose()
+	return l.Listener.Close()
+}
@@ -0,0 +1,116 @@
+package service_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/service"
+	"github.com/livekit/livekit-server/pkg/service/servicefakes"
+)
+
+// writeCertificate writes a self-signed certificate for turn.example.com with the serial number
+func writeCertificate(t *testing.T, certFile, keyFile string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "turn.example.com"},
+		DNSNames:     []string{"turn.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
+
+func certificateSerial(t *testing.T, c *service.ReloadingCertificate) int64 {
+	cert, err := c.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	return cert.Leaf.SerialNumber.Int64()
+}
+
+func TestReloadingCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeCertificate(t, certFile, keyFile, 1)
+
+	c, err := service.NewReloadingCertificate(certFile, keyFile)
+	require.NoError(t, err)
+	defer c.Stop()
+	require.Equal(t, int64(1), certificateSerial(t, c))
+
+	t.Run("renewed certificate is swapped in", func(t *testing.T) {
+		writeCertificate(t, certFile, keyFile, 2)
+		require.NoError(t, c.Reload())
+		require.Equal(t, int64(2), certificateSerial(t, c))
+	})
+
+	t.Run("invalid files keep the previous certificate", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0600))
+		require.Error(t, c.Reload())
+		require.Equal(t, int64(2), certificateSerial(t, c))
+
+		// the key of another certificate
+		otherKeyFile := filepath.Join(dir, "other-key.pem")
+		writeCertificate(t, certFile, otherKeyFile, 3)
+		require.Error(t, c.Reload())
+		require.Equal(t, int64(2), certificateSerial(t, c))
+
+		require.NoError(t, os.Remove(keyFile))
+		require.Error(t, c.Reload())
+		require.Equal(t, int64(2), certificateSerial(t, c))
+	})
+
+	t.Run("missing files fail to load", func(t *testing.T) {
+		_, err := service.NewReloadingCertificate(filepath.Join(dir, "missing.pem"), keyFile)
+		require.Error(t, err)
+	})
+}
+
+func TestTurnServerCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeCertificate(t, certFile, keyFile, 42)
+
+	conf, err := config.NewConfig("", nil)
+	require.NoError(t, err)
+	conf.RTC.NodeIP = "127.0.0.1"
+	conf.TURN.Enabled = true
+	conf.TURN.Domain = "turn.example.com"
+	conf.TURN.TLSPort = freePort(t, "tcp")
+	conf.TURN.CertFile = certFile
+	conf.TURN.KeyFile = keyFile
+
+	server, err := service.NewTurnServer(conf, service.NewTurnAuthHandler(conf, &servicefakes.FakeObjectStore{}))
+	require.NoError(t, err)
+	defer server.Close()
+
+	conn, err := tls.Dial("tcp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(conf.TURN.TLSPort)), &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, int64(42), conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64())
+}
@@ -12,6 +12,10 @@ import (
 func handleError(w http.ResponseWriter, status int, msg string) {
 	// GetLogger already with extra depth 1
 	logger.GetLogger().V(1).Info("error handling request", "error", msg, "status", status)
+	if status == http.StatusServiceUnavailable {
+		// i.e. a draining node, clients can retry right away and be given another node
+		w.Header().Set("Retry-After", "1")
+	}
 	w.WriteHeader(status)
 	_, _ = w.Write([]byte(msg))
 }
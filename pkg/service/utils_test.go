@@ -3,6 +3,7 @@ package service_test
 import (
 	"testing"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/require"
 
@@ -15,6 +16,14 @@ func redisClient() *redis.Client {
 	})
 }
 
+// redisClusterClient connects to an in-memory server that answers as a single node cluster holding all slots
+func redisClusterClient(t *testing.T) *redis.ClusterClient {
+	m := miniredis.RunT(t)
+	return redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{m.Addr()},
+	})
+}
+
 func TestIsValidDomain(t *testing.T) {
 	list := map[string]bool{
 		"turn.myhost.com":  true,
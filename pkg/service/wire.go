@@ -6,7 +6,7 @@ package service
 import (
 	"context"
 	"fmt"
-	"os"
+	"io/ioutil"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/wire"
@@ -17,8 +17,8 @@ import (
 	"github.com/livekit/protocol/utils"
 	"github.com/livekit/protocol/webhook"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v3"
 	"crypto/tls"
+	"crypto/x509"
 
 	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/routing"
@@ -38,7 +38,7 @@ func InitializeServer(conf *config.Config, currentNode routing.LocalNode) (*Live
 		routing.CreateRouter,
 		wire.Bind(new(routing.MessageRouter), new(routing.Router)),
 		wire.Bind(new(livekit.RoomService), new(*RoomService)),
-		telemetry.NewAnalyticsService,
+		createAnalyticsService,
 		telemetry.NewTelemetryService,
 		NewEgressService,
 		NewRecordingService,
@@ -46,7 +46,7 @@ func InitializeServer(conf *config.Config, currentNode routing.LocalNode) (*Live
 		NewRoomService,
 		NewRTCService,
 		NewLocalRoomManager,
-		newTurnAuthHandler,
+		NewTurnAuthHandler,
 		NewTurnServer,
 		NewLivekitServer,
 	)
@@ -63,30 +63,40 @@ func InitializeRouter(conf *config.Config, currentNode routing.LocalNode) (routi
 }
 
 func createKeyProvider(conf *config.Config) (auth.KeyProvider, error) {
-	// prefer keyfile if set
+	// keys from key_file are merged with inline keys, inline keys take precedence
 	if conf.KeyFile != "" {
-		if st, err := os.Stat(conf.KeyFile); err != nil {
-			return nil, err
-		} else if st.Mode().Perm() != 0600 {
-			return nil, fmt.Errorf("key file must have permission set to 600")
-		}
-		f, err := os.Open(conf.KeyFile)
+		provider, err := NewReloadingKeyProvider(conf.KeyFile, conf.Keys, conf.KeyRemovalGrace.Duration())
 		if err != nil {
 			return nil, err
 		}
-		defer func() {
-			_ = f.Close()
-		}()
-		decoder := yaml.NewDecoder(f)
-		if err = decoder.Decode(conf.Keys); err != nil {
-			return nil, err
+		if provider.NumKeys() == 0 {
+			provider.Stop()
+			return nil, errors.New("one of key-file or keys must be provided in order to support a secure installation")
 		}
+		logger.Infow("loaded API keys",
+			"keyFile", conf.KeyFile,
+			"fileKeys", provider.NumFileKeys(),
+			"inlineKeys", len(conf.Keys),
+			"numKeys", provider.NumKeys(),
+		)
+		return provider, nil
 	}
 
 	if len(conf.Keys) == 0 {
-		return nil, errors.New("one of key-file or keys must be provided in order to support a secure installation")
+		if !conf.Development {
+			return nil, errors.New("one of key-file or keys must be provided in order to support a secure installation")
+		}
+		// the generated key is only held in memory
+		apiKey, apiSecret, deterministic := config.DevelopmentKey()
+		logger.Infow("no API keys configured, using generated development key",
+			"apiKey", apiKey,
+			"apiSecret", apiSecret,
+			"fromHostname", deterministic,
+		)
+		return auth.NewFileBasedKeyProviderFromMap(map[string]string{apiKey: apiSecret}), nil
 	}
 
+	warnShortSecrets(conf.Keys)
 	return auth.NewFileBasedKeyProviderFromMap(conf.Keys), nil
 }
 
@@ -100,33 +110,47 @@ func createWebhookNotifier(conf *config.Config, provider auth.KeyProvider) (webh
 		return nil, ErrWebHookMissingAPIKey
 	}
 
-	return webhook.NewNotifier(wc.APIKey, secret, wc.URLs), nil
+	return telemetry.NewWebhookNotifier(wc.APIKey, secret, wc.URLs, wc.Retry), nil
+}
+
+func createAnalyticsService(conf *config.Config, currentNode routing.LocalNode) telemetry.AnalyticsService {
+	if conf.Telemetry.OTLP.Endpoint == "" {
+		return telemetry.NewAnalyticsService(conf, currentNode)
+	}
+
+	logger.Infow("exporting analytics over OTLP", "endpoint", conf.Telemetry.OTLP.Endpoint)
+	return telemetry.NewOTLPExporter(conf.Telemetry.OTLP, currentNode)
 }
 
-func createRedisClient(conf *config.Config) (*redis.Client, error) {
+func createRedisClient(conf *config.Config) (redis.UniversalClient, error) {
 	if !conf.HasRedis() {
 		return nil, nil
 	}
 
-	logger.Infow("using multi-node routing via redis", "addr", conf.Redis.Address)
-    rcOptions :=  &redis.Options{
-                             Addr:     conf.Redis.Address,
-                             Username: conf.Redis.Username,
-                             Password: conf.Redis.Password,
-                             DB:       conf.Redis.DB,
-                         }
-	if conf.Redis.UseTLS {
-		rcOptions = &redis.Options{
-    		Addr:     conf.Redis.Address,
-    		Username: conf.Redis.Username,
-    		Password: conf.Redis.Password,
-    		DB:       conf.Redis.DB,
-            TLSConfig: &tls.Config{
-                    MinVersion: tls.VersionTLS12,
-                },
-    	}
-	}
-	rc := redis.NewClient(rcOptions)
+	tlsConfig, err := createRedisTLSConfig(conf.Redis)
+	if err != nil {
+		return nil, err
+	}
+
+	var rc redis.UniversalClient
+	if len(conf.Redis.ClusterAddresses) > 0 {
+		logger.Infow("using multi-node routing via redis cluster", "addrs", conf.Redis.ClusterAddresses)
+		rc = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     conf.Redis.ClusterAddresses,
+			Username:  conf.Redis.Username,
+			Password:  conf.Redis.Password,
+			TLSConfig: tlsConfig,
+		})
+	} else {
+		logger.Infow("using multi-node routing via redis", "addr", conf.Redis.Address)
+		rc = redis.NewClient(&redis.Options{
+			Addr:      conf.Redis.Address,
+			Username:  conf.Redis.Username,
+			Password:  conf.Redis.Password,
+			DB:        conf.Redis.DB,
+			TLSConfig: tlsConfig,
+		})
+	}
 
 	if err := rc.Ping(context.Background()).Err(); err != nil {
 		err = errors.Wrap(err, "unable to connect to redis")
@@ -136,18 +160,69 @@ func createRedisClient(conf *config.Config) (*redis.Client, error) {
 	return rc, nil
 }
 
-func createMessageBus(rc *redis.Client) utils.MessageBus {
+func createRedisTLSConfig(rc config.RedisConfig) (*tls.Config, error) {
+	if !rc.UseTLS {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: rc.TLSInsecureSkipVerify,
+	}
+
+	if rc.TLSCAFile != "" {
+		caPEM, err := ioutil.ReadFile(rc.TLSCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read redis tls_ca_file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("could not parse redis tls_ca_file %s, no PEM certificates found", rc.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if rc.TLSCertFile != "" || rc.TLSKeyFile != "" {
+		if rc.TLSCertFile == "" || rc.TLSKeyFile == "" {
+			return nil, errors.New("redis tls_cert_file and tls_key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(rc.TLSCertFile, rc.TLSKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load redis client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func createMessageBus(rc redis.UniversalClient) utils.MessageBus {
 	if rc == nil {
 		return nil
 	}
-	return utils.NewRedisMessageBus(rc)
+	if client, ok := rc.(*redis.Client); ok {
+		return utils.NewRedisMessageBus(client)
+	}
+	return NewUniversalMessageBus(rc)
 }
 
-func createStore(rc *redis.Client) ObjectStore {
-	if rc != nil {
-		return NewRedisStore(rc)
+func createStore(conf *config.Config, currentNode routing.LocalNode, rc redis.UniversalClient) (ObjectStore, error) {
+	switch conf.StorageType() {
+	case config.StorageTypePostgres:
+		logger.Infow("using postgres storage")
+		return NewPostgresStore(conf.Storage.Postgres)
+	case config.StorageTypeLocal:
+		if conf.Storage.Local.DataDir != "" {
+			logger.Infow("saving rooms to disk", "dataDir", conf.Storage.Local.DataDir)
+			return NewPersistentLocalStore(conf.Storage.Local)
+		}
+		return NewLocalStore(), nil
+	default:
+		if rc == nil {
+			return nil, errors.New("redis storage requires redis to be configured")
+		}
+		return NewRedisStore(rc, livekit.NodeID(currentNode.Id), conf.Storage.Redis), nil
 	}
-	return NewLocalStore()
 }
 
 func createClientConfiguration() clientconfiguration.ClientConfigurationManager {
@@ -0,0 +1,187 @@
+package service_test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/service"
+)
+
+func TestRedisTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := createTestCA(t, dir)
+	serverCert := createTestCert(t, dir, "server", ca, caKey)
+	createTestCert(t, dir, "client", ca, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	addr := startTLSRedisStub(t, &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+
+	newConf := func() *config.Config {
+		conf, err := config.NewConfig("", nil)
+		require.NoError(t, err)
+		conf.Redis.Address = addr
+		conf.Redis.UseTLS = true
+		conf.Redis.TLSCAFile = filepath.Join(dir, "ca.pem")
+		conf.Redis.TLSCertFile = filepath.Join(dir, "client.pem")
+		conf.Redis.TLSKeyFile = filepath.Join(dir, "client-key.pem")
+		return conf
+	}
+	node := &livekit.Node{Id: "node"}
+
+	t.Run("handshake with CA and client cert", func(t *testing.T) {
+		_, err := service.InitializeRouter(newConf(), node)
+		require.NoError(t, err)
+	})
+
+	t.Run("server rejects missing client cert", func(t *testing.T) {
+		conf := newConf()
+		conf.Redis.TLSCertFile = ""
+		conf.Redis.TLSKeyFile = ""
+		_, err := service.InitializeRouter(conf, node)
+		require.Error(t, err)
+	})
+
+	t.Run("unknown CA is rejected", func(t *testing.T) {
+		conf := newConf()
+		conf.Redis.TLSCAFile = ""
+		_, err := service.InitializeRouter(conf, node)
+		require.Error(t, err)
+	})
+
+	t.Run("unreadable CA file", func(t *testing.T) {
+		conf := newConf()
+		conf.Redis.TLSCAFile = filepath.Join(dir, "missing.pem")
+		_, err := service.InitializeRouter(conf, node)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "tls_ca_file")
+	})
+
+	t.Run("invalid CA file", func(t *testing.T) {
+		conf := newConf()
+		conf.Redis.TLSCAFile = filepath.Join(dir, "client-key.pem")
+		_, err := service.InitializeRouter(conf, node)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no PEM certificates")
+	})
+}
+
+func createTestCA(t *testing.T, dir string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", der)
+
+	ca, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return ca, key
+}
+
+func createTestCert(t *testing.T, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	return cert
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
+
+// startTLSRedisStub starts a minimal TLS server that answers every RESP command with +PONG
+func startTLSRedisStub(t *testing.T, tlsConfig *tls.Config) string {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveRedisStub(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveRedisStub(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		// commands are arrays of bulk strings: *<n>\r\n followed by n x ($<len>\r\n<data>\r\n)
+		line, err := r.ReadString('\n')
+		if err != nil || !strings.HasPrefix(line, "*") {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return
+		}
+		for i := 0; i < n*2; i++ {
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+		}
+		if _, err := conn.Write([]byte("+PONG\r\n")); err != nil {
+			return
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/livekit/protocol/logger"
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
 	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
 	"go.uber.org/atomic"
@@ -20,20 +21,30 @@ import (
 
 const (
 	ReportDelta = 1e9
+
+	FrameMarkingURI = "urn:ietf:params:rtp-hdrext:framemarking"
 )
 
 type pendingPacket struct {
 	arrivalTime int64
-	packet      []byte
+	packet      *[]byte
 }
 
 type ExtPacket struct {
-	Head      bool
-	Arrival   int64
-	Packet    *rtp.Packet
-	Payload   interface{}
-	KeyFrame  bool
-	RawPacket []byte
+	Head         bool
+	Arrival      int64
+	Packet       *rtp.Packet
+	Payload      interface{}
+	KeyFrame     bool
+	SpatialLayer int32
+	// -1 when the stream does not carry temporal layer information
+	TemporalLayer int32
+	RawPacket     []byte
+
+	// packets read from a buffer come from a pool, see Retain and Release
+	pooled bool
+	refs   atomic.Int32
+	packet rtp.Packet
 }
 
 // Buffer contains all packets
@@ -43,6 +54,8 @@ type Buffer struct {
 	nacker     *NackQueue
 	videoPool  *sync.Pool
 	audioPool  *sync.Pool
+	sizedPool  func(packets int) *sync.Pool
+	bucketPool *sync.Pool
 	codecType  webrtc.RTPCodecType
 	extPackets deque.Deque
 	pPackets   []pendingPacket
@@ -73,8 +86,17 @@ type Buffer struct {
 	lastSRRecv     int64 // Represents wall clock of the most recent sender report arrival
 	lastTransit    uint32
 
+	// H.264 temporal layers are signalled by the frame marking extension
+	frameMarkingExt uint8
+
 	pliThrottle int64
 	lastPli     int64
+	// a request throttled in the current window is sent at the end of it, unless a key frame arrives after the request
+	pliPending     bool
+	lastPliRequest int64
+	lastKeyFrame   int64
+	// RTP timestamp of the last key frame counted, a key frame can span packets
+	lastKeyFrameTS uint32
 
 	started    bool
 	stats      StreamStats
@@ -106,6 +128,8 @@ type receiverReportSnapshot struct {
 // BufferOptions provides configuration options for the buffer
 type Options struct {
 	MaxBitRate uint64
+	// packets to buffer, 0 uses the pool for the kind. Only applies to buffers from a Factory
+	PacketBufferSize int
 }
 
 // NewBuffer constructs a new Buffer
@@ -145,13 +169,19 @@ func (b *Buffer) Bind(params webrtc.RTPParameters, codec webrtc.RTPCodecCapabili
 	switch {
 	case strings.HasPrefix(b.mime, "audio/"):
 		b.codecType = webrtc.RTPCodecTypeAudio
-		b.bucket = NewBucket(b.audioPool.Get().(*[]byte))
+		b.bucketPool = b.audioPool
 	case strings.HasPrefix(b.mime, "video/"):
 		b.codecType = webrtc.RTPCodecTypeVideo
-		b.bucket = NewBucket(b.videoPool.Get().(*[]byte))
+		b.bucketPool = b.videoPool
 	default:
 		b.codecType = webrtc.RTPCodecType(0)
 	}
+	if b.bucketPool != nil {
+		if o.PacketBufferSize > 0 && b.sizedPool != nil {
+			b.bucketPool = b.sizedPool(o.PacketBufferSize)
+		}
+		b.bucket = NewBucket(b.bucketPool.Get().(*[]byte))
+	}
 
 	for _, ext := range params.HeaderExtensions {
 		if ext.URI == sdp.TransportCCURI {
@@ -161,6 +191,13 @@ func (b *Buffer) Bind(params webrtc.RTPParameters, codec webrtc.RTPCodecCapabili
 	}
 
 	if b.codecType == webrtc.RTPCodecTypeVideo {
+		for _, ext := range params.HeaderExtensions {
+			if ext.URI == FrameMarkingURI {
+				b.frameMarkingExt = uint8(ext.ID)
+				break
+			}
+		}
+
 		for _, fb := range codec.RTCPFeedback {
 			switch fb.Type {
 			case webrtc.TypeRTCPFBGoogREMB:
@@ -186,12 +223,13 @@ func (b *Buffer) Bind(params webrtc.RTPParameters, codec webrtc.RTPCodecCapabili
 	}
 
 	for _, pp := range b.pPackets {
-		b.calc(pp.packet, pp.arrivalTime)
+		b.calc(*pp.packet, pp.arrivalTime)
+		PutPacketBuffer(pp.packet)
 	}
 	b.pPackets = nil
 	b.bound = true
 
-	b.logger.Debugw("NewBuffer", "MaxBitRate", o.MaxBitRate)
+	b.logger.Debugw("NewBuffer", "MaxBitRate", o.MaxBitRate, "PacketBufferSize", o.PacketBufferSize)
 }
 
 // Write adds an RTP Packet, out of order, new packet may be arrived later
@@ -205,8 +243,8 @@ func (b *Buffer) Write(pkt []byte) (n int, err error) {
 	}
 
 	if !b.bound {
-		packet := make([]byte, len(pkt))
-		copy(packet, pkt)
+		packet := GetPacketBuffer(len(pkt))
+		copy(*packet, pkt)
 		b.pPackets = append(b.pPackets, pendingPacket{
 			packet:      packet,
 			arrivalTime: time.Now().UnixNano(),
@@ -226,13 +264,14 @@ func (b *Buffer) Read(buff []byte) (n int, err error) {
 		}
 		b.Lock()
 		if b.pPackets != nil && len(b.pPackets) > b.lastPacketRead {
-			if len(buff) < len(b.pPackets[b.lastPacketRead].packet) {
+			packet := *b.pPackets[b.lastPacketRead].packet
+			if len(buff) < len(packet) {
 				err = ErrBufferTooSmall
 				b.Unlock()
 				return
 			}
-			n = len(b.pPackets[b.lastPacketRead].packet)
-			copy(buff, b.pPackets[b.lastPacketRead].packet)
+			n = len(packet)
+			copy(buff, packet)
 			b.lastPacketRead++
 			b.Unlock()
 			return
@@ -242,6 +281,7 @@ func (b *Buffer) Read(buff []byte) (n int, err error) {
 	}
 }
 
+// ReadExtended returns the next packet to forward. The caller owns a reference to it and has to release it once done
 func (b *Buffer) ReadExtended() (*ExtPacket, error) {
 	for {
 		if b.closed.Load() {
@@ -263,11 +303,8 @@ func (b *Buffer) Close() error {
 	defer b.Unlock()
 
 	b.closeOnce.Do(func() {
-		if b.bucket != nil && b.codecType == webrtc.RTPCodecTypeVideo {
-			b.videoPool.Put(b.bucket.src)
-		}
-		if b.bucket != nil && b.codecType == webrtc.RTPCodecTypeAudio {
-			b.audioPool.Put(b.bucket.src)
+		if b.bucket != nil {
+			b.bucketPool.Put(b.bucket.src)
 		}
 		b.closed.Store(true)
 		b.callbacksQueue.Enqueue(b.onClose)
@@ -287,12 +324,20 @@ func (b *Buffer) SetPLIThrottle(duration int64) {
 	b.pliThrottle = duration
 }
 
+// SendPLI requests a key frame from the publisher. Requests are coalesced, at most one PLI
+// is sent per throttle window. Requests made within a window result in one more PLI at the end of it
+// if no key frame has arrived since.
 func (b *Buffer) SendPLI() {
 	now := time.Now().UnixNano()
 
 	b.Lock()
 	throttled := now-b.lastPli < b.pliThrottle
 	if throttled {
+		b.lastPliRequest = now
+		if !b.pliPending {
+			b.pliPending = true
+			time.AfterFunc(time.Duration(b.lastPli+b.pliThrottle-now), b.sendPendingPLI)
+		}
 		b.Unlock()
 		return
 	}
@@ -300,6 +345,29 @@ func (b *Buffer) SendPLI() {
 	b.stats.TotalPLIs++
 	b.Unlock()
 
+	b.writePLI()
+}
+
+func (b *Buffer) sendPendingPLI() {
+	b.Lock()
+	if !b.pliPending || b.closed.Load() {
+		b.Unlock()
+		return
+	}
+	b.pliPending = false
+	if b.lastKeyFrame >= b.lastPliRequest {
+		// request satisfied by a key frame in the meantime
+		b.Unlock()
+		return
+	}
+	b.lastPli = time.Now().UnixNano()
+	b.stats.TotalPLIs++
+	b.Unlock()
+
+	b.writePLI()
+}
+
+func (b *Buffer) writePLI() {
 	b.logger.Debugw("send pli", "ssrc", b.mediaSSRC)
 	pli := []rtcp.Packet{
 		&rtcp.PictureLossIndication{SenderSSRC: rand.Uint32(), MediaSSRC: b.mediaSSRC},
@@ -334,20 +402,21 @@ func (b *Buffer) calc(pkt []byte, arrivalTime int64) {
 		}
 	}
 
-	var p rtp.Packet
+	ep := newExtPacket()
 	if isRTX {
-		err = p.Unmarshal(pkt)
+		err = ep.Packet.Unmarshal(pkt)
 	} else {
-		err = p.Unmarshal(pb)
+		err = ep.Packet.Unmarshal(pb)
 	}
 	if err != nil {
 		b.logger.Warnw("error unmarshaling RTP packet", err)
+		ep.Release()
 		return
 	}
 
-	b.updateStreamState(&p, len(pkt), arrivalTime, isRTX)
+	b.updateStreamState(ep.Packet, len(pkt), arrivalTime, isRTX)
 
-	b.processHeaderExtensions(&p, arrivalTime)
+	b.processHeaderExtensions(ep.Packet, arrivalTime)
 
 	if isRTX {
 		//
@@ -357,15 +426,25 @@ func (b *Buffer) calc(pkt []byte, arrivalTime int64) {
 		//
 		// But, do not forward those packets
 		//
+		ep.Release()
 		return
 	}
 
-	ep, temporalLayer := b.getExtPacket(pb, &p, arrivalTime)
-	if ep == nil {
+	temporalLayer, ok := b.populateExtPacket(ep, pb, arrivalTime)
+	if !ok {
+		ep.Release()
 		return
 	}
 	b.extPackets.PushBack(ep)
 
+	if ep.KeyFrame {
+		b.lastKeyFrame = arrivalTime
+		if b.stats.TotalKeyFrames == 0 || ep.Packet.Timestamp != b.lastKeyFrameTS {
+			b.stats.TotalKeyFrames++
+			b.lastKeyFrameTS = ep.Packet.Timestamp
+		}
+	}
+
 	if temporalLayer >= 0 {
 		b.bitrateHelper[temporalLayer] += int64(len(pkt))
 	}
@@ -484,17 +563,17 @@ func (b *Buffer) processHeaderExtensions(p *rtp.Packet, arrivalTime int64) {
 	}
 }
 
-func (b *Buffer) getExtPacket(rawPacket []byte, rtpPacket *rtp.Packet, arrivalTime int64) (*ExtPacket, int32) {
-	ep := &ExtPacket{
-		Head:      rtpPacket.SequenceNumber == b.highestSN,
-		Packet:    rtpPacket,
-		Arrival:   arrivalTime,
-		RawPacket: rawPacket,
-	}
+// populateExtPacket fills in the packet unmarshalled into ep, returns false if it cannot be forwarded
+func (b *Buffer) populateExtPacket(ep *ExtPacket, rawPacket []byte, arrivalTime int64) (int32, bool) {
+	rtpPacket := ep.Packet
+	ep.Head = rtpPacket.SequenceNumber == b.highestSN
+	ep.Arrival = arrivalTime
+	ep.TemporalLayer = -1
+	ep.RawPacket = rawPacket
 
 	if len(rtpPacket.Payload) == 0 {
 		// padding only packet, nothing else to do
-		return ep, -1
+		return -1, true
 	}
 
 	temporalLayer := int32(0)
@@ -503,16 +582,44 @@ func (b *Buffer) getExtPacket(rawPacket []byte, rtpPacket *rtp.Packet, arrivalTi
 		vp8Packet := VP8{}
 		if err := vp8Packet.Unmarshal(rtpPacket.Payload); err != nil {
 			b.logger.Warnw("could not unmarshal VP8 packet", err)
-			return nil, -1
+			return -1, false
 		}
 		ep.Payload = vp8Packet
 		ep.KeyFrame = vp8Packet.IsKeyFrame
 		temporalLayer = int32(vp8Packet.TID)
+		if vp8Packet.TIDPresent == 1 {
+			ep.TemporalLayer = temporalLayer
+		}
+	case "video/vp9":
+		vp9Packet := codecs.VP9Packet{}
+		if _, err := vp9Packet.Unmarshal(rtpPacket.Payload); err != nil {
+			b.logger.Warnw("could not unmarshal VP9 packet", err)
+			return -1, false
+		}
+		ep.Payload = vp9Packet
+		ep.KeyFrame = IsVP9Keyframe(&vp9Packet)
+		ep.SpatialLayer = int32(vp9Packet.SID)
+		temporalLayer = int32(vp9Packet.TID)
+		if temporalLayer >= int32(len(b.bitrateHelper)) {
+			temporalLayer = int32(len(b.bitrateHelper) - 1)
+		}
+		ep.TemporalLayer = temporalLayer
 	case "video/h264":
 		ep.KeyFrame = IsH264Keyframe(rtpPacket.Payload)
+		if b.frameMarkingExt != 0 {
+			if tid, ok := FrameMarkingTemporalID(rtpPacket.GetExtension(b.frameMarkingExt)); ok {
+				temporalLayer = int32(tid)
+				if temporalLayer >= int32(len(b.bitrateHelper)) {
+					temporalLayer = int32(len(b.bitrateHelper) - 1)
+				}
+				ep.TemporalLayer = temporalLayer
+			}
+		}
+	case "video/av1":
+		ep.KeyFrame = IsAV1Keyframe(rtpPacket.Payload)
 	}
 
-	return ep, temporalLayer
+	return temporalLayer, true
 }
 
 func (b *Buffer) doNACKs() {
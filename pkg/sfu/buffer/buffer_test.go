@@ -8,8 +8,10 @@ import (
 
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
+	"github.com/pion/transport/packetio"
 	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 )
 
 var vp8Codec = webrtc.RTPCodecParameters{
@@ -213,6 +215,24 @@ func TestNewBuffer(t *testing.T) {
 	}
 }
 
+func TestPacketBufferSize(t *testing.T) {
+	factory := NewBufferFactory(500)
+
+	bind := func(ssrc uint32, codec webrtc.RTPCodecParameters, packetBufferSize int) *Buffer {
+		buff := factory.GetOrNew(packetio.RTPBufferPacket, ssrc).(*Buffer)
+		buff.OnFeedback(func(_ []rtcp.Packet) {})
+		buff.Bind(webrtc.RTPParameters{
+			Codecs: []webrtc.RTPCodecParameters{codec},
+		}, codec.RTPCodecCapability, Options{PacketBufferSize: packetBufferSize})
+		return buff
+	}
+
+	require.Equal(t, 500, bind(1, vp8Codec, 0).bucket.maxSteps)
+	require.Equal(t, 25, bind(2, opusCodec, 0).bucket.maxSteps)
+	require.Equal(t, 800, bind(3, vp8Codec, 800).bucket.maxSteps)
+	require.Equal(t, 10, bind(4, opusCodec, 10).bucket.maxSteps)
+}
+
 func TestFractionLostReport(t *testing.T) {
 	pool := &sync.Pool{
 		New: func() interface{} {
@@ -256,3 +276,110 @@ func TestFractionLostReport(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestPLICoalescing(t *testing.T) {
+	pool := &sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, 1500)
+			return &b
+		},
+	}
+
+	newBuffer := func(numPLIs *atomic.Int32) *Buffer {
+		buff := NewBuffer(123, pool, pool)
+		buff.OnClose(func() {})
+		buff.OnFeedback(func(fb []rtcp.Packet) {
+			for _, pkt := range fb {
+				if _, ok := pkt.(*rtcp.PictureLossIndication); ok {
+					numPLIs.Inc()
+				}
+			}
+		})
+		buff.Bind(webrtc.RTPParameters{
+			HeaderExtensions: nil,
+			Codecs:           []webrtc.RTPCodecParameters{vp8Codec},
+		}, vp8Codec.RTPCodecCapability, Options{})
+		buff.SetPLIThrottle(int64(100 * time.Millisecond))
+		return buff
+	}
+
+	t.Run("requests within a window are coalesced into a trailing PLI", func(t *testing.T) {
+		var numPLIs atomic.Int32
+		buff := newBuffer(&numPLIs)
+		defer buff.Close()
+
+		for i := 0; i < 3; i++ {
+			buff.SendPLI()
+		}
+		time.Sleep(50 * time.Millisecond)
+		require.EqualValues(t, 1, numPLIs.Load())
+
+		time.Sleep(100 * time.Millisecond)
+		require.EqualValues(t, 2, numPLIs.Load())
+
+		// nothing pending anymore
+		time.Sleep(150 * time.Millisecond)
+		require.EqualValues(t, 2, numPLIs.Load())
+	})
+
+	t.Run("key frame satisfies pending requests", func(t *testing.T) {
+		var numPLIs atomic.Int32
+		buff := newBuffer(&numPLIs)
+		defer buff.Close()
+
+		buff.SendPLI()
+		buff.SendPLI()
+
+		pkt := rtp.Packet{
+			Header:  rtp.Header{Version: 2, SequenceNumber: 1, Timestamp: 1, SSRC: 123, Marker: true},
+			Payload: []byte{0x10, 0x00, 0x00, 0x00},
+		}
+		b, err := pkt.Marshal()
+		require.NoError(t, err)
+		_, err = buff.Write(b)
+		require.NoError(t, err)
+
+		time.Sleep(150 * time.Millisecond)
+		require.EqualValues(t, 1, numPLIs.Load())
+	})
+}
+
+func TestKeyFrameCount(t *testing.T) {
+	pool := &sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, 1500)
+			return &b
+		},
+	}
+	buff := NewBuffer(123, pool, pool)
+	buff.OnClose(func() {})
+	buff.OnFeedback(func(_ []rtcp.Packet) {})
+	buff.Bind(webrtc.RTPParameters{
+		Codecs: []webrtc.RTPCodecParameters{vp8Codec},
+	}, vp8Codec.RTPCodecCapability, Options{})
+	defer buff.Close()
+
+	keyFrame := []byte{0x10, 0x00, 0x00, 0x00}
+	deltaFrame := []byte{0x10, 0x01, 0x00, 0x00}
+	for i, p := range []struct {
+		ts      uint32
+		payload []byte
+	}{
+		// a key frame over two packets counts once
+		{ts: 1000, payload: keyFrame},
+		{ts: 1000, payload: keyFrame},
+		{ts: 4000, payload: deltaFrame},
+		{ts: 7000, payload: keyFrame},
+	} {
+		pkt := rtp.Packet{
+			Header:  rtp.Header{Version: 2, SequenceNumber: uint16(i + 1), Timestamp: p.ts, SSRC: 123},
+			Payload: p.payload,
+		}
+		b, err := pkt.Marshal()
+		require.NoError(t, err)
+		_, err = buff.Write(b)
+		require.NoError(t, err)
+	}
+
+	require.EqualValues(t, 2, buff.GetStats().StreamStats.TotalKeyFrames)
+}
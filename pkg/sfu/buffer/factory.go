@@ -11,6 +11,7 @@ type Factory struct {
 	sync.RWMutex
 	videoPool   *sync.Pool
 	audioPool   *sync.Pool
+	sizedPools  map[int]*sync.Pool
 	rtpBuffers  map[uint32]*Buffer
 	rtcpReaders map[uint32]*RTCPReader
 }
@@ -29,11 +30,35 @@ func NewBufferFactory(trackingPackets int) *Factory {
 				return &b
 			},
 		},
+		sizedPools:  make(map[int]*sync.Pool),
 		rtpBuffers:  make(map[uint32]*Buffer),
 		rtcpReaders: make(map[uint32]*RTCPReader),
 	}
 }
 
+// PacketBufferBytes returns the memory a buffer of packets uses
+func PacketBufferBytes(packets int) int {
+	return packets * maxPktSize
+}
+
+// sizedPool returns the pool of buckets holding packets, for buffers bound with a packet buffer size
+func (f *Factory) sizedPool(packets int) *sync.Pool {
+	f.Lock()
+	defer f.Unlock()
+
+	pool, ok := f.sizedPools[packets]
+	if !ok {
+		pool = &sync.Pool{
+			New: func() interface{} {
+				b := make([]byte, PacketBufferBytes(packets))
+				return &b
+			},
+		}
+		f.sizedPools[packets] = pool
+	}
+	return pool
+}
+
 func (f *Factory) GetOrNew(packetType packetio.BufferPacketType, ssrc uint32) io.ReadWriteCloser {
 	f.Lock()
 	defer f.Unlock()
@@ -55,6 +80,7 @@ func (f *Factory) GetOrNew(packetType packetio.BufferPacketType, ssrc uint32) io
 			return reader
 		}
 		buffer := NewBuffer(ssrc, f.videoPool, f.audioPool)
+		buffer.sizedPool = f.sizedPool
 		f.rtpBuffers[ssrc] = buffer
 		buffer.OnClose(func() {
 			f.Lock()
@@ -5,6 +5,7 @@ import (
 	"errors"
 
 	"github.com/livekit/protocol/logger"
+	"github.com/pion/rtp/codecs"
 )
 
 var (
@@ -71,6 +72,8 @@ func (v *VP8) Unmarshal(payload []byte) error {
 	idx := 0
 	v.FirstByte = payload[idx]
 	S := payload[idx]&0x10 > 0
+	// start of a frame is the start of partition 0, S is set at the start of every partition
+	S = S && payload[idx]&0x07 == 0
 	// Check for extended bit control
 	if payload[idx]&0x80 > 0 {
 		idx++
@@ -221,8 +224,8 @@ func IsH264Keyframe(payload []byte) bool {
 		// reserved
 		return false
 	} else if nalu <= 23 {
-		// simple NALU
-		return nalu == 5
+		// simple NALU, an IDR slice or the SPS preceding it
+		return nalu == 5 || nalu == 7
 	} else if nalu == 24 || nalu == 25 || nalu == 26 || nalu == 27 {
 		// STAP-A, STAP-B, MTAP16 or MTAP24
 		i := 1
@@ -250,7 +253,7 @@ func IsH264Keyframe(payload []byte) bool {
 				return false
 			}
 			n := payload[i+offset] & 0x1F
-			if n == 7 {
+			if n == 7 || n == 5 {
 				return true
 			} else if n >= 24 {
 				// is this legal?
@@ -271,7 +274,91 @@ func IsH264Keyframe(payload []byte) bool {
 			// not a starting fragment
 			return false
 		}
-		return payload[1]&0x1F == 7
+		n := payload[1] & 0x1F
+		return n == 7 || n == 5
 	}
 	return false
 }
+
+// H264NALUnitTypes returns the types of NAL units started in a h264 payload.
+// Only the starting fragment of a fragmented unit is counted
+func H264NALUnitTypes(payload []byte) []uint8 {
+	if len(payload) < 1 {
+		return nil
+	}
+	nalu := payload[0] & 0x1F
+	switch {
+	case nalu == 0:
+		return nil
+	case nalu <= 23:
+		return []uint8{nalu}
+	case nalu == 24 || nalu == 25 || nalu == 26 || nalu == 27:
+		i := 1
+		if nalu == 25 || nalu == 26 || nalu == 27 {
+			i += 2
+		}
+		offset := 0
+		if nalu == 26 {
+			offset = 3
+		} else if nalu == 27 {
+			offset = 4
+		}
+		var types []uint8
+		for i+2 <= len(payload) {
+			length := int(payload[i])<<8 | int(payload[i+1])
+			i += 2
+			if i+length > len(payload) || offset >= length {
+				break
+			}
+			types = append(types, payload[i+offset]&0x1F)
+			i += length
+		}
+		return types
+	case nalu == 28 || nalu == 29:
+		if len(payload) < 2 || payload[1]&0x80 == 0 {
+			return nil
+		}
+		return []uint8{payload[1] & 0x1F}
+	}
+	return nil
+}
+
+// FrameMarkingTemporalID returns the temporal layer of a frame marking header extension.
+// The short form used by non-scalable streams has no layer information, all frames belong to layer 0
+func FrameMarkingTemporalID(ext []byte) (uint8, bool) {
+	switch {
+	case len(ext) == 0:
+		return 0, false
+	case len(ext) == 1:
+		return 0, true
+	default:
+		return ext[0] & 0x07, true
+	}
+}
+
+// IsVP9Keyframe detects if a parsed VP9 payload starts a keyframe, i.e. the first packet of an intra picture on the
+// base spatial layer. Upper spatial layers of a keyframe don't use inter-picture prediction either, but they can't be
+// decoded without the base layer
+func IsVP9Keyframe(vp9 *codecs.VP9Packet) bool {
+	return !vp9.P && vp9.B && vp9.SID == 0
+}
+
+// IsAV1Keyframe detects if an AV1 payload starts a new coded video sequence, signalled by the N bit of the
+// aggregation header
+/*
+	AV1 Aggregation Header
+	 0 1 2 3 4 5 6 7
+	+-+-+-+-+-+-+-+-+
+	|Z|Y| W |N|-|-|-|
+	+-+-+-+-+-+-+-+-+
+*/
+func IsAV1Keyframe(payload []byte) bool {
+	if len(payload) < 1 {
+		return false
+	}
+	// a packet continuing an OBU fragment can't start a sequence
+	if payload[0]&0x80 != 0 {
+		return false
+	}
+	return payload[0]&0x08 != 0
+}
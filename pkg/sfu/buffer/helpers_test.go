@@ -3,6 +3,7 @@ package buffer
 import (
 	"testing"
 
+	"github.com/pion/rtp/codecs"
 	"github.com/stretchr/testify/require"
 )
 
@@ -62,10 +63,16 @@ func TestVP8Helper_Unmarshal(t *testing.T) {
 		},
 		{
 			name:          "Check if packet is a keyframe by looking at P bit set to 0",
-			args:          args{payload: []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1}},
+			args:          args{payload: []byte{0xf8, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1}},
 			checkKeyFrame: true,
 			keyFrame:      true,
 		},
+		{
+			name:          "Start of a partition other than the first is not a keyframe",
+			args:          args{payload: []byte{0xf9, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1}},
+			checkKeyFrame: true,
+			keyFrame:      false,
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -92,3 +99,128 @@ func TestVP8Helper_Unmarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestIsVP9Keyframe(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  []byte
+		keyFrame bool
+	}{
+		{
+			// I|L|B|E, picture id, TID 0/SID 0, TL0PICIDX
+			name:     "start of intra picture on the base layer",
+			payload:  []byte{0xac, 0x01, 0x00, 0x00, 0xaa},
+			keyFrame: true,
+		},
+		{
+			name:    "inter predicted picture",
+			payload: []byte{0xec, 0x01, 0x00, 0x00, 0xaa},
+		},
+		{
+			name:    "continuation of a picture",
+			payload: []byte{0xa4, 0x01, 0x00, 0x00, 0xaa},
+		},
+		{
+			// SID 1
+			name:    "upper spatial layer of a keyframe",
+			payload: []byte{0xac, 0x01, 0x02, 0x00, 0xaa},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			vp9 := codecs.VP9Packet{}
+			_, err := vp9.Unmarshal(tt.payload)
+			require.NoError(t, err)
+			require.Equal(t, tt.keyFrame, IsVP9Keyframe(&vp9))
+		})
+	}
+}
+
+func TestIsAV1Keyframe(t *testing.T) {
+	require.False(t, IsAV1Keyframe(nil))
+	// N bit
+	require.True(t, IsAV1Keyframe([]byte{0x18, 0x00}))
+	require.False(t, IsAV1Keyframe([]byte{0x10, 0x00}))
+	// Z bit, continuing a fragmented OBU
+	require.False(t, IsAV1Keyframe([]byte{0x98, 0x00}))
+}
+
+func TestIsH264Keyframe(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  []byte
+		keyFrame bool
+	}{
+		{
+			name:     "IDR slice",
+			payload:  []byte{0x65, 0x88},
+			keyFrame: true,
+		},
+		{
+			name:     "SPS",
+			payload:  []byte{0x67, 0x42},
+			keyFrame: true,
+		},
+		{
+			name:    "non-IDR slice",
+			payload: []byte{0x41, 0x9a},
+		},
+		{
+			name:     "STAP-A with SPS and PPS",
+			payload:  []byte{0x78, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xce},
+			keyFrame: true,
+		},
+		{
+			name:     "STAP-A with SEI and IDR slice",
+			payload:  []byte{0x78, 0x00, 0x02, 0x06, 0x05, 0x00, 0x02, 0x65, 0x88},
+			keyFrame: true,
+		},
+		{
+			name:    "STAP-A with SEI and non-IDR slice",
+			payload: []byte{0x78, 0x00, 0x02, 0x06, 0x05, 0x00, 0x02, 0x41, 0x9a},
+		},
+		{
+			name:     "FU-A start of IDR slice",
+			payload:  []byte{0x7c, 0x85, 0x88},
+			keyFrame: true,
+		},
+		{
+			name:    "FU-A continuation of IDR slice",
+			payload: []byte{0x7c, 0x05, 0x88},
+		},
+		{
+			name:    "FU-A start of non-IDR slice",
+			payload: []byte{0x7c, 0x81, 0x9a},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.keyFrame, IsH264Keyframe(tt.payload))
+		})
+	}
+}
+
+func TestH264NALUnitTypes(t *testing.T) {
+	require.Nil(t, H264NALUnitTypes(nil))
+	require.Equal(t, []uint8{5}, H264NALUnitTypes([]byte{0x65, 0x88}))
+	require.Equal(t, []uint8{7, 8}, H264NALUnitTypes([]byte{0x78, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xce}))
+	require.Equal(t, []uint8{5}, H264NALUnitTypes([]byte{0x7c, 0x85, 0x88}))
+	require.Nil(t, H264NALUnitTypes([]byte{0x7c, 0x05, 0x88}))
+}
+
+func TestFrameMarkingTemporalID(t *testing.T) {
+	_, ok := FrameMarkingTemporalID(nil)
+	require.False(t, ok)
+
+	// short form, non-scalable
+	tid, ok := FrameMarkingTemporalID([]byte{0xa0})
+	require.True(t, ok)
+	require.Equal(t, uint8(0), tid)
+
+	// S|E|B, TID 2, LID, TL0PICIDX
+	tid, ok = FrameMarkingTemporalID([]byte{0xca, 0x00, 0x05})
+	require.True(t, ok)
+	require.Equal(t, uint8(2), tid)
+}
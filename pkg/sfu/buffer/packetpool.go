@@ -0,0 +1,120 @@
+package buffer
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+// Packet buffers are pooled in sized buckets so that small packets, i.e. audio and padding, do not hold on to MTU sized
+// buffers. The large bucket leaves room for what is added to a full sized packet on the way out, i.e. header
+// extensions, the RTX original sequence number and a grown VP8 payload descriptor
+const (
+	PacketBufferSizeSmall = 256
+	PacketBufferSizeMTU   = 1500
+	PacketBufferSizeLarge = 2048
+)
+
+var (
+	packetBufferSizes = [...]int{PacketBufferSizeSmall, PacketBufferSizeMTU, PacketBufferSizeLarge}
+	packetBufferPools [len(packetBufferSizes)]sync.Pool
+
+	extPacketPool = sync.Pool{
+		New: func() interface{} {
+			return &ExtPacket{pooled: true}
+		},
+	}
+)
+
+func init() {
+	for i, size := range packetBufferSizes {
+		size := size
+		packetBufferPools[i].New = func() interface{} {
+			b := make([]byte, size)
+			return &b
+		}
+	}
+}
+
+// GetPacketBuffer returns a buffer of size bytes from the smallest bucket that fits, buffers larger than the large
+// bucket are not pooled. The buffer is owned by the caller until it is put back
+func GetPacketBuffer(size int) *[]byte {
+	for i, bucketSize := range packetBufferSizes {
+		if size <= bucketSize {
+			b := packetBufferPools[i].Get().(*[]byte)
+			*b = (*b)[:size]
+			return b
+		}
+	}
+
+	b := make([]byte, size)
+	return &b
+}
+
+// PutPacketBuffer returns a buffer from GetPacketBuffer to its bucket, it must not be used after
+func PutPacketBuffer(b *[]byte) {
+	size := cap(*b)
+	for i, bucketSize := range packetBufferSizes {
+		if size == bucketSize {
+			*b = (*b)[:size]
+			packetBufferPools[i].Put(b)
+			return
+		}
+	}
+}
+
+// newExtPacket returns a packet from the pool holding one reference, owned by the caller until released
+func newExtPacket() *ExtPacket {
+	ep := extPacketPool.Get().(*ExtPacket)
+	ep.refs.Store(1)
+	ep.Packet = &ep.packet
+	return ep
+}
+
+// Retain keeps a pooled packet from going back to the pool until a matching Release. Holders using a packet beyond
+// the call it was handed to in have to retain it, packets not from the pool are not affected
+func (e *ExtPacket) Retain() {
+	if e.pooled {
+		e.refs.Inc()
+	}
+}
+
+// Release gives up a reference to a pooled packet, the last one returns it to the pool. Neither the packet nor
+// its raw packet and payload may be used after
+func (e *ExtPacket) Release() {
+	if !e.pooled {
+		return
+	}
+
+	refs := e.refs.Dec()
+	if refs < 0 {
+		panic("buffer: ExtPacket released more often than retained")
+	}
+	if refs > 0 {
+		return
+	}
+
+	// header slices are kept for the next packet to unmarshal into
+	csrc := e.packet.CSRC[:0]
+	extensions := e.packet.Extensions[:0]
+	*e = ExtPacket{pooled: true}
+	e.packet.Header = rtp.Header{
+		CSRC:       csrc,
+		Extensions: extensions,
+	}
+	extPacketPool.Put(e)
+}
+
+// Clone returns a copy that is not pooled and does not share memory with the packet
+func (e *ExtPacket) Clone() *ExtPacket {
+	return &ExtPacket{
+		Head:          e.Head,
+		Arrival:       e.Arrival,
+		Packet:        e.Packet.Clone(),
+		Payload:       e.Payload,
+		KeyFrame:      e.KeyFrame,
+		SpatialLayer:  e.SpatialLayer,
+		TemporalLayer: e.TemporalLayer,
+		RawPacket:     append([]byte(nil), e.RawPacket...),
+	}
+}
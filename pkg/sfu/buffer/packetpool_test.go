@@ -0,0 +1,211 @@
+package buffer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketBuffer(t *testing.T) {
+	for _, size := range []int{1, PacketBufferSizeSmall, PacketBufferSizeSmall + 1, PacketBufferSizeMTU, PacketBufferSizeLarge} {
+		b := GetPacketBuffer(size)
+		require.Len(t, *b, size)
+		PutPacketBuffer(b)
+	}
+
+	require.Equal(t, PacketBufferSizeMTU, cap(*GetPacketBuffer(PacketBufferSizeSmall + 1)))
+
+	// beyond the large bucket, not pooled
+	b := GetPacketBuffer(PacketBufferSizeLarge + 1)
+	require.Len(t, *b, PacketBufferSizeLarge+1)
+	PutPacketBuffer(b)
+}
+
+func TestExtPacketRefs(t *testing.T) {
+	t.Run("released with the last reference", func(t *testing.T) {
+		ep := newExtPacket()
+		ep.Retain()
+
+		ep.Release()
+		require.Equal(t, int32(1), ep.refs.Load())
+
+		ep.Release()
+		require.Equal(t, int32(0), ep.refs.Load())
+		require.Panics(t, ep.Release)
+	})
+
+	t.Run("packets not from the pool are not affected", func(t *testing.T) {
+		ep := &ExtPacket{Packet: &rtp.Packet{}}
+		ep.Retain()
+		ep.Release()
+		ep.Release()
+		require.NotNil(t, ep.Packet)
+	})
+
+	t.Run("clones are not pooled", func(t *testing.T) {
+		ep := newExtPacket()
+		ep.Packet.SequenceNumber = 10
+		ep.RawPacket = []byte{1, 2, 3}
+
+		clone := ep.Clone()
+		ep.Release()
+
+		require.False(t, clone.pooled)
+		require.Equal(t, uint16(10), clone.Packet.SequenceNumber)
+		require.Equal(t, []byte{1, 2, 3}, clone.RawPacket)
+	})
+}
+
+// soakPacket returns a VP8 packet carrying its sequence number at the end of the payload
+func soakPacket(t testing.TB, sn uint16) []byte {
+	payload := []byte{0xff, 0xff, 0xff, 0xfd, 0xb4, 0x9f, 0x94, 0x1, 0, 0}
+	binary.BigEndian.PutUint16(payload[len(payload)-2:], sn)
+	pkt := rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    96,
+			SequenceNumber: sn,
+			Timestamp:      uint32(sn) * 3000,
+			SSRC:           123,
+		},
+		Payload: payload,
+	}
+	raw, err := pkt.Marshal()
+	require.NoError(t, err)
+	return raw
+}
+
+func newSoakBuffer() *Buffer {
+	pool := &sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, 500*maxPktSize)
+			return &b
+		},
+	}
+	buff := NewBuffer(123, pool, pool)
+	buff.OnFeedback(func(_ []rtcp.Packet) {})
+	buff.OnClose(func() {})
+	buff.Bind(webrtc.RTPParameters{
+		Codecs: []webrtc.RTPCodecParameters{vp8Codec},
+	}, vp8Codec.RTPCodecCapability, Options{})
+	return buff
+}
+
+// Hands packets read from a buffer to concurrent holders, as the receiver does with down tracks. Packets going back
+// to the pool while held show up as mismatches, or as races when run with -race
+func TestExtPacketPoolSoak(t *testing.T) {
+	const (
+		numPackets = 5000
+		numHolders = 4
+		// bounds the packets in flight well within the bucket, so that payloads are not overwritten by the
+		// bucket wrapping around
+		maxInFlight = 32
+	)
+
+	buff := newSoakBuffer()
+	defer buff.Close()
+
+	inFlight := make(chan struct{}, maxInFlight)
+	holders := make([]chan *ExtPacket, numHolders)
+	var wg sync.WaitGroup
+	var mismatches sync.Map
+	for i := range holders {
+		holders[i] = make(chan *ExtPacket, maxInFlight)
+		wg.Add(1)
+		go func(pkts chan *ExtPacket) {
+			defer wg.Done()
+			for ep := range pkts {
+				sn := ep.Packet.SequenceNumber
+				payload := ep.Packet.Payload
+				if ep.Packet.Timestamp != uint32(sn)*3000 || binary.BigEndian.Uint16(payload[len(payload)-2:]) != sn {
+					mismatches.Store(sn, true)
+				}
+				ep.Release()
+				<-inFlight
+			}
+		}(holders[i])
+	}
+
+	for i := 0; i < numPackets; i++ {
+		_, err := buff.Write(soakPacket(t, uint16(i)))
+		require.NoError(t, err)
+
+		ep, err := buff.ReadExtended()
+		require.NoError(t, err)
+		require.Equal(t, uint16(i), ep.Packet.SequenceNumber)
+
+		for _, pkts := range holders {
+			inFlight <- struct{}{}
+			ep.Retain()
+			pkts <- ep
+		}
+		ep.Release()
+	}
+	for _, pkts := range holders {
+		close(pkts)
+	}
+	wg.Wait()
+
+	mismatches.Range(func(sn, _ interface{}) bool {
+		t.Errorf("packet %d changed while held", sn)
+		return true
+	})
+}
+
+func BenchmarkPacketBuffer(b *testing.B) {
+	for _, size := range []int{160, 1200} {
+		b.Run(fmt.Sprintf("%d/Control", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf := make([]byte, size)
+				buf[0] = 1
+			}
+		})
+		b.Run(fmt.Sprintf("%d/Pool", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf := GetPacketBuffer(size)
+				(*buf)[0] = 1
+				PutPacketBuffer(buf)
+			}
+		})
+	}
+}
+
+func BenchmarkBufferReadExtended(b *testing.B) {
+	pkts := make([][]byte, 1<<16)
+	for sn := range pkts {
+		pkts[sn] = soakPacket(b, uint16(sn))
+	}
+
+	// packets that are never released leave the pool empty, as when every packet was allocated
+	b.Run("Control", func(b *testing.B) {
+		buff := newSoakBuffer()
+		defer buff.Close()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = buff.Write(pkts[i&0xffff])
+			_, _ = buff.ReadExtended()
+		}
+	})
+	b.Run("Pool", func(b *testing.B) {
+		buff := newSoakBuffer()
+		defer buff.Close()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = buff.Write(pkts[i&0xffff])
+			ep, _ := buff.ReadExtended()
+			ep.Release()
+		}
+	})
+}
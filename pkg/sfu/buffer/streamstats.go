@@ -15,6 +15,7 @@ type StreamStats struct {
 	TotalPaddingBytes      uint64
 	TotalPacketsLost       uint32
 	TotalFrames            uint32
+	TotalKeyFrames         uint32
 	RTT                    uint32
 	Jitter                 float64
 	TotalNACKs             uint32
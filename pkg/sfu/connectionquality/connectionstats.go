@@ -143,15 +143,19 @@ func (cs *ConnectionStats) updateScore() float32 {
 		pctLoss = (float32(lostPacketsInInterval) / float32(expectedPacketsInInterval)) * 100.0
 	}
 
+	// covert jitter (in media samples units) to milliseconds
+	jitterMs := float32(0)
+	if cs.params.ClockRate != 0 {
+		jitterMs = float32(maxJitter) * 1000.0 / float32(cs.params.ClockRate)
+	}
 	if cs.params.CodecType == webrtc.RTPCodecTypeAudio {
-		// covert jitter (in media samples units) to milliseconds
-		cs.score = AudioConnectionScore(pctLoss, maxRTT, float32(maxJitter)*1000.0/float32(cs.params.ClockRate))
+		cs.score = AudioConnectionScore(pctLoss, maxRTT, jitterMs)
 	} else {
 		isReducedQuality := false
 		if cs.params.GetIsReducedQuality != nil {
 			isReducedQuality = cs.params.GetIsReducedQuality()
 		}
-		cs.score = VideoConnectionScore(pctLoss, isReducedQuality)
+		cs.score = VideoConnectionScore(pctLoss, maxRTT, jitterMs, isReducedQuality)
 	}
 
 	return cs.score
@@ -9,19 +9,46 @@ import (
 
 const (
 	defaultRtt = uint32(70)
+
+	defaultExcellentScore = float32(3.9)
+	defaultGoodScore      = float32(2.5)
+
+	// round trip delay of video including jitter, in milliseconds, above which it is noticeably late, and above
+	// which it is hardly usable for a conversation
+	videoDelayGood = float32(300)
+	videoDelayPoor = float32(600)
 )
 
-func Score2Rating(score float32) livekit.ConnectionQuality {
-	if score > 3.9 {
+// RatingThresholds are the scores above which connections are rated excellent and good, zero values use the defaults
+type RatingThresholds struct {
+	Excellent float32
+	Good      float32
+}
+
+func (t RatingThresholds) Rating(score float32) livekit.ConnectionQuality {
+	excellent := t.Excellent
+	if excellent == 0 {
+		excellent = defaultExcellentScore
+	}
+	good := t.Good
+	if good == 0 {
+		good = defaultGoodScore
+	}
+
+	if score > excellent {
 		return livekit.ConnectionQuality_EXCELLENT
 	}
 
-	if score > 2.5 {
+	if score > good {
 		return livekit.ConnectionQuality_GOOD
 	}
 	return livekit.ConnectionQuality_POOR
 }
 
+func Score2Rating(score float32) livekit.ConnectionQuality {
+	return RatingThresholds{}.Rating(score)
+}
+
 func mosAudioEmodel(pctLoss float32, rtt uint32, jitter float32) float32 {
 	rx := 93.2 - pctLoss
 	ry := 0.18*rx*rx - 27.9*rx + 1126.62
@@ -71,6 +98,23 @@ func AudioConnectionScore(pctLoss float32, rtt uint32, jitter float32) float32 {
 	return mosAudioEmodel(pctLoss, rtt, jitter)
 }
 
-func VideoConnectionScore(pctLoss float32, reducedQuality bool) float32 {
-	return loss2Score(pctLoss, reducedQuality)
+// delay2Score caps a video score by the round trip delay, an unknown RTT does not affect it
+func delay2Score(score float32, rtt uint32, jitter float32) float32 {
+	if rtt == 0 {
+		return score
+	}
+
+	d := float32(rtt) + jitter
+	if d > videoDelayPoor && score > 2.0 {
+		return 2.0
+	}
+	if d > videoDelayGood && score > 3.5 {
+		return 3.5
+	}
+	return score
+}
+
+// VideoConnectionScore scores video by loss and layers received, capped by delay. Jitter is in milliseconds
+func VideoConnectionScore(pctLoss float32, rtt uint32, jitter float32, reducedQuality bool) float32 {
+	return delay2Score(loss2Score(pctLoss, reducedQuality), rtt, jitter)
 }
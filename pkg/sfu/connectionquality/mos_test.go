@@ -0,0 +1,39 @@
+package connectionquality
+
+import (
+	"testing"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRatingThresholds(t *testing.T) {
+	require.Equal(t, livekit.ConnectionQuality_EXCELLENT, Score2Rating(4.0))
+	require.Equal(t, livekit.ConnectionQuality_GOOD, Score2Rating(3.9))
+	require.Equal(t, livekit.ConnectionQuality_POOR, Score2Rating(2.5))
+
+	thresholds := RatingThresholds{Excellent: 4.5, Good: 3.5}
+	require.Equal(t, livekit.ConnectionQuality_EXCELLENT, thresholds.Rating(5.0))
+	require.Equal(t, livekit.ConnectionQuality_GOOD, thresholds.Rating(4.0))
+	require.Equal(t, livekit.ConnectionQuality_POOR, thresholds.Rating(3.5))
+}
+
+func TestVideoConnectionScore(t *testing.T) {
+	t.Run("loss", func(t *testing.T) {
+		require.Equal(t, float32(5.0), VideoConnectionScore(0, 50, 10, false))
+		require.Equal(t, float32(4.5), VideoConnectionScore(1, 50, 10, false))
+		require.Equal(t, float32(3.5), VideoConnectionScore(0, 50, 10, true))
+		require.Equal(t, float32(2.0), VideoConnectionScore(5, 50, 10, false))
+	})
+
+	t.Run("delay caps the score", func(t *testing.T) {
+		require.Equal(t, float32(3.5), VideoConnectionScore(0, 300, 10, false))
+		require.Equal(t, float32(2.0), VideoConnectionScore(0, 550, 60, false))
+		// already lower
+		require.Equal(t, float32(2.0), VideoConnectionScore(5, 350, 0, false))
+	})
+
+	t.Run("unknown rtt", func(t *testing.T) {
+		require.Equal(t, float32(5.0), VideoConnectionScore(0, 0, 700, false))
+	})
+}
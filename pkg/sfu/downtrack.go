@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"strings"
 	"sync"
 	"time"
@@ -27,6 +28,8 @@ import (
 type TrackSender interface {
 	UpTrackLayersChange(availableLayers []int32)
 	WriteRTP(p *buffer.ExtPacket, layer int32) error
+	// ForwardsREDPrimary reports whether the sender takes the primary encoding of a RED track
+	ForwardsREDPrimary() bool
 	Close()
 	// ID is the globally unique identifier for this Track.
 	ID() string
@@ -88,10 +91,17 @@ type DownTrack struct {
 	forwarder *Forwarder
 
 	codec                   webrtc.RTPCodecCapability
+	strictCodecMatching     bool
+	forwardREDPrimary       atomic.Bool
+	redBlockPayloadType     uint8
+	rtxSSRC                 uint32
+	rtx                     *rtxStream
 	rtpHeaderExtensions     []webrtc.RTPHeaderExtensionParameter
 	receiver                TrackReceiver
 	transceiver             *webrtc.RTPTransceiver
 	writeStream             webrtc.TrackLocalWriter
+	pacerLock               sync.RWMutex
+	pacer                   *Pacer
 	onCloseHandler          func()
 	onBind                  func()
 	receiverReportListeners []ReceiverReportListener
@@ -111,6 +121,9 @@ type DownTrack struct {
 
 	isNACKThrottled atomic.Bool
 
+	// a subscriber is started at most once from a cached key frame
+	primedFromCache atomic.Bool
+
 	callbacksQueue *utils.OpsQueue
 
 	// RTCP callbacks
@@ -173,10 +186,16 @@ func NewDownTrack(
 		receiver:       r,
 		codec:          c,
 		kind:           kind,
-		forwarder:      NewForwarder(c, kind, logger),
+		forwarder:      NewForwarder(c, kind, r.MaxSpatialLayer(), logger),
 		callbacksQueue: utils.NewOpsQueue(logger),
 		closed:         make(chan struct{}),
 	}
+	if kind == webrtc.RTPCodecTypeVideo {
+		// allocated upfront as it's signalled in the offer, before the track is bound
+		for d.rtxSSRC == 0 {
+			d.rtxSSRC = rand.Uint32()
+		}
+	}
 
 	d.connectionStats = connectionquality.NewConnectionStats(connectionquality.ConnectionStatsParams{
 		CodecType:     kind,
@@ -206,15 +225,37 @@ func (d *DownTrack) Bind(t webrtc.TrackLocalContext) (webrtc.RTPCodecParameters,
 		return webrtc.RTPCodecParameters{}, ErrTrackAlreadyBind
 	}
 	parameters := webrtc.RTPCodecParameters{RTPCodecCapability: d.codec}
-	codec, err := codecParametersFuzzySearch(parameters, t.CodecParameters())
+	codec, err := codecParametersFuzzySearch(parameters, t.CodecParameters(), d.strictCodecMatching)
+	forwardREDPrimary := false
+	if err != nil && IsREDCodec(d.codec.MimeType) {
+		// the subscriber didn't negotiate RED, forward the primary opus encoding instead
+		parameters.RTPCodecCapability = webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: d.codec.ClockRate, Channels: d.codec.Channels}
+		codec, err = codecParametersFuzzySearch(parameters, t.CodecParameters(), d.strictCodecMatching)
+		forwardREDPrimary = err == nil
+	}
 	if err != nil {
 		return webrtc.RTPCodecParameters{}, webrtc.ErrUnsupportedCodec
 	}
+	if IsREDCodec(codec.MimeType) {
+		// blocks carry the publisher's opus payload type, which is remapped to the one negotiated with the subscriber
+		for _, c := range t.CodecParameters() {
+			if strings.EqualFold(c.MimeType, webrtc.MimeTypeOpus) {
+				d.redBlockPayloadType = uint8(c.PayloadType)
+				break
+			}
+		}
+	}
+	d.forwardREDPrimary.Store(forwardREDPrimary)
 
 	d.callbacksQueue.Start()
 
 	d.ssrc = uint32(t.SSRC())
 	d.payloadType = uint8(codec.PayloadType)
+	if d.rtxSSRC != 0 && d.rtxSSRC != d.ssrc {
+		if rtxPayloadType, ok := RTXPayloadType(t.CodecParameters(), codec.PayloadType); ok {
+			d.rtx = newRTXStream(d.rtxSSRC, uint8(rtxPayloadType), uint16(rand.Uint32()))
+		}
+	}
 	d.writeStream = t.WriteStream()
 	d.mime = strings.ToLower(codec.MimeType)
 	if rr := d.bufferFactory.GetOrNew(packetio.RTCPBufferPacket, uint32(t.SSRC())).(*buffer.RTCPReader); rr != nil {
@@ -222,6 +263,14 @@ func (d *DownTrack) Bind(t webrtc.TrackLocalContext) (webrtc.RTPCodecParameters,
 			d.handleRTCP(pkt)
 		})
 	}
+	if d.rtx != nil {
+		// transport-cc feedback names the SSRC of the last packet received, which could be a retransmission
+		if rr := d.bufferFactory.GetOrNew(packetio.RTCPBufferPacket, d.rtxSSRC).(*buffer.RTCPReader); rr != nil {
+			rr.OnPacket(func(pkt []byte) {
+				d.handleRTXRTCP(pkt)
+			})
+		}
+	}
 	if strings.HasPrefix(d.codec.MimeType, "video/") {
 		d.sequencer = newSequencer(d.maxTrack, d.logger)
 	}
@@ -277,6 +326,12 @@ func (d *DownTrack) SSRC() uint32 {
 	return d.ssrc
 }
 
+// RTXSSRC returns the SSRC of the retransmission stream of a video track, 0 for audio.
+// It's only used when the subscriber negotiates RTX for the bound codec
+func (d *DownTrack) RTXSSRC() uint32 {
+	return d.rtxSSRC
+}
+
 func (d *DownTrack) Stop() error {
 	if d.transceiver != nil {
 		return d.transceiver.Stop()
@@ -288,6 +343,17 @@ func (d *DownTrack) SetTransceiver(transceiver *webrtc.RTPTransceiver) {
 	d.transceiver = transceiver
 }
 
+// ForwardsREDPrimary reports whether a RED track is unwrapped into its primary encoding for this subscriber
+func (d *DownTrack) ForwardsREDPrimary() bool {
+	return d.forwardREDPrimary.Load()
+}
+
+// SetStrictCodecMatching makes Bind fail unless the receiver negotiated compatible fmtp parameters,
+// instead of falling back to a codec with the same mime type
+func (d *DownTrack) SetStrictCodecMatching(strict bool) {
+	d.strictCodecMatching = strict
+}
+
 func (d *DownTrack) requestFirstKeyframe() {
 	ticker := time.NewTicker(firstKeyFramePLIInterval)
 	for !d.forwarder.ReceivedFirstKeyFrame() {
@@ -308,7 +374,7 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 	var pool *[]byte
 	defer func() {
 		if pool != nil {
-			PacketFactory.Put(pool)
+			buffer.PutPacketBuffer(pool)
 			pool = nil
 		}
 	}()
@@ -323,6 +389,10 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 	if tp.shouldSendPLI {
 		d.lastPli.Store(time.Now())
 		d.receiver.SendPLI(layer)
+
+		if !d.forwarder.ReceivedFirstKeyFrame() && d.primedFromCache.CAS(false, true) {
+			d.primeFromCache(layer)
+		}
 	}
 	if tp.shouldDrop {
 		if tp.isDroppingRelevant {
@@ -337,7 +407,7 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 
 		outbuf := &payload
 		if incomingVP8.HeaderSize != tp.vp8.header.HeaderSize {
-			pool = PacketFactory.Get().(*[]byte)
+			pool = buffer.GetPacketBuffer(buffer.PacketBufferSizeLarge)
 			outbuf = pool
 		}
 		payload, err = d.translateVP8PacketTo(extPkt.Packet, &incomingVP8, tp.vp8.header, outbuf)
@@ -346,6 +416,14 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 			return err
 		}
 	}
+	if d.redBlockPayloadType != 0 {
+		pool = buffer.GetPacketBuffer(buffer.PacketBufferSizeLarge)
+		payload, err = RewriteREDPayloadType(payload, d.redBlockPayloadType, *pool)
+		if err != nil {
+			d.pktsDropped.Inc()
+			return err
+		}
+	}
 
 	if d.sequencer != nil {
 		meta := d.sequencer.push(extPkt.Packet.SequenceNumber, tp.rtp.sequenceNumber, tp.rtp.timestamp, int8(layer))
@@ -360,24 +438,29 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 		return err
 	}
 
-	_, err = d.writeStream.WriteRTP(hdr, payload)
-	if err == nil {
-		pktSize := hdr.MarshalSize() + len(payload)
+	priority := PacerPriorityVideo
+	if d.kind == webrtc.RTPCodecTypeAudio {
+		priority = PacerPriorityAudio
+	}
+	isSwitchingToMaxLayer := tp.isSwitchingToMaxLayer
+	isKeyFrame := extPkt.KeyFrame
+	err = d.writeRTP(hdr, payload, priority, func(pktSize int) {
 		for _, f := range d.onPacketSentUnsafe {
 			f(d, pktSize)
 		}
 
-		if tp.isSwitchingToMaxLayer && d.onMaxLayerChanged != nil && d.kind == webrtc.RTPCodecTypeVideo {
+		if isSwitchingToMaxLayer && d.onMaxLayerChanged != nil && d.kind == webrtc.RTPCodecTypeVideo {
 			d.callbacksQueue.Enqueue(func() {
 				d.onMaxLayerChanged(d, layer)
 			})
 		}
 
 		d.updatePrimaryStats(pktSize, hdr.Marker)
-		if extPkt.KeyFrame {
+		if isKeyFrame {
 			d.isNACKThrottled.Store(false)
 		}
-	} else {
+	})
+	if err != nil {
 		d.logger.Errorw("writing rtp packet err", err)
 		d.pktsDropped.Inc()
 	}
@@ -385,8 +468,85 @@ func (d *DownTrack) WriteRTP(extPkt *buffer.ExtPacket, layer int32) error {
 	return err
 }
 
+// SetPacer sends packets through the pacer of the peer connection, nil writes them right away
+func (d *DownTrack) SetPacer(pacer *Pacer) {
+	d.pacerLock.Lock()
+	defer d.pacerLock.Unlock()
+
+	d.pacer = pacer
+}
+
+func (d *DownTrack) getPacer() *Pacer {
+	d.pacerLock.RLock()
+	defer d.pacerLock.RUnlock()
+
+	return d.pacer
+}
+
+// writeRTP writes a packet, through the pacer if there is one. As buffers are reused once this returns, paced packets
+// are copied and get their header extensions written again when they go out. onSent is called with the size once written
+func (d *DownTrack) writeRTP(hdr *rtp.Header, payload []byte, priority PacerPriority, onSent func(pktSize int)) error {
+	pktSize := hdr.MarshalSize() + len(payload)
+
+	pacer := d.getPacer()
+	if pacer == nil {
+		if _, err := d.writeStream.WriteRTP(hdr, payload); err != nil {
+			return err
+		}
+
+		if onSent != nil {
+			onSent(pktSize)
+		}
+		return nil
+	}
+
+	pacedHdr := *hdr
+	pacedPayload := buffer.GetPacketBuffer(len(payload))
+	copy(*pacedPayload, payload)
+	return pacer.Enqueue(priority, pktSize, func() {
+		defer buffer.PutPacketBuffer(pacedPayload)
+
+		if err := d.writeRTPHeaderExtensions(&pacedHdr); err != nil {
+			d.logger.Errorw("writing rtp header extensions err", err)
+			return
+		}
+
+		if _, err := d.writeStream.WriteRTP(&pacedHdr, *pacedPayload); err != nil {
+			d.logger.Debugw("writing paced rtp packet err", "error", err, "priority", priority)
+			d.pktsDropped.Inc()
+			return
+		}
+
+		if onSent != nil {
+			onSent(pktSize)
+		}
+	})
+}
+
+// primeFromCache starts the stream with the cached key frame of the layer, if any,
+// so that the subscriber can render while the requested key frame is on its way
+func (d *DownTrack) primeFromCache(layer int32) {
+	pkts := d.receiver.GetCachedKeyFrame(layer)
+	if len(pkts) == 0 {
+		return
+	}
+
+	for _, pkt := range pkts {
+		if err := d.WriteRTP(pkt, layer); err != nil {
+			d.logger.Warnw("could not write cached key frame", err)
+			return
+		}
+	}
+
+	if d.forwarder.ReceivedFirstKeyFrame() {
+		d.logger.Debugw("primed from cached key frame", "layer", layer, "packets", len(pkts))
+		d.forwarder.SetPrimed()
+	}
+}
+
 // WritePaddingRTP tries to write as many padding only RTP packets as necessary
-// to satisfy given size to the DownTrack
+// to satisfy given size to the DownTrack. When the subscriber negotiated RTX, padding
+// goes on the RTX stream and doesn't have to wait for a frame boundary
 func (d *DownTrack) WritePaddingRTP(bytesToSend int) int {
 	d.statsLock.RLock()
 	if d.stats.TotalPrimaryPackets == 0 {
@@ -422,6 +582,10 @@ func (d *DownTrack) WritePaddingRTP(bytesToSend int) int {
 		return 0
 	}
 
+	if d.rtx != nil {
+		return d.writeRTXPadding(num)
+	}
+
 	snts, err := d.forwarder.GetSnTsForPadding(num)
 	if err != nil {
 		return 0
@@ -457,17 +621,16 @@ func (d *DownTrack) WritePaddingRTP(bytesToSend int) int {
 		// last byte of padding has padding size including that byte
 		payload[RTPPaddingMaxPayloadSize-1] = byte(RTPPaddingMaxPayloadSize)
 
-		_, err = d.writeStream.WriteRTP(&hdr, payload)
+		err = d.writeRTP(&hdr, payload, PacerPriorityPadding, func(size int) {
+			d.updatePaddingStats(size)
+			for _, f := range d.onPaddingSentUnsafe {
+				f(d, size)
+			}
+		})
 		if err != nil {
 			return bytesSent
 		}
 
-		size := hdr.MarshalSize() + len(payload)
-		d.updatePaddingStats(size)
-		for _, f := range d.onPaddingSentUnsafe {
-			f(d, size)
-		}
-
 		//
 		// Register with sequencer with invalid layer so that NACKs for these can be filtered out.
 		// Retransmission is probably a sign of network congestion/badness.
@@ -477,7 +640,42 @@ func (d *DownTrack) WritePaddingRTP(bytesToSend int) int {
 			d.sequencer.push(0, hdr.SequenceNumber, hdr.Timestamp, int8(InvalidLayerSpatial))
 		}
 
-		bytesSent += size
+		bytesSent += hdr.MarshalSize() + len(payload)
+	}
+
+	return bytesSent
+}
+
+func (d *DownTrack) writeRTXPadding(num int) int {
+	hdr := rtp.Header{
+		Version:   2,
+		Timestamp: d.forwarder.GetRTPMungerParams().lastTS,
+		CSRC:      []uint32{},
+	}
+
+	bytesSent := 0
+	for i := 0; i < num; i++ {
+		rtxHdr := d.rtx.header(&hdr)
+		rtxHdr.Padding = true
+		if err := d.writeRTPHeaderExtensions(&rtxHdr); err != nil {
+			return bytesSent
+		}
+
+		payload := make([]byte, RTPPaddingMaxPayloadSize)
+		// last byte of padding has padding size including that byte
+		payload[RTPPaddingMaxPayloadSize-1] = byte(RTPPaddingMaxPayloadSize)
+
+		err := d.writeRTP(&rtxHdr, payload, PacerPriorityPadding, func(size int) {
+			d.updatePaddingStats(size)
+			for _, f := range d.onPaddingSentUnsafe {
+				f(d, size)
+			}
+		})
+		if err != nil {
+			return bytesSent
+		}
+
+		bytesSent += rtxHdr.MarshalSize() + len(payload)
 	}
 
 	return bytesSent
@@ -595,6 +793,11 @@ func (d *DownTrack) SetMaxTemporalLayer(temporalLayer int32) {
 	}
 }
 
+// SpatialLayerLimit returns the highest spatial layer the track can be forwarded at
+func (d *DownTrack) SpatialLayerLimit() int32 {
+	return d.receiver.MaxSpatialLayer()
+}
+
 func (d *DownTrack) MaxLayers() VideoLayers {
 	return d.forwarder.MaxLayers()
 }
@@ -856,12 +1059,12 @@ func (d *DownTrack) writeBlankFrameRTP() error {
 			return err
 		}
 
-		var pktSize int
+		var payload []byte
 		switch d.mime {
 		case "video/vp8":
-			pktSize, err = d.writeVP8BlankFrame(&hdr, frameEndNeeded)
+			payload, err = d.getVP8BlankFrame(frameEndNeeded)
 		case "video/h264":
-			pktSize, err = d.writeH264BlankFrame(&hdr, frameEndNeeded)
+			payload = d.getH264BlankFrame()
 		default:
 			return nil
 		}
@@ -869,11 +1072,16 @@ func (d *DownTrack) writeBlankFrameRTP() error {
 			return err
 		}
 
-		for _, f := range d.onPacketSentUnsafe {
-			f(d, pktSize)
-		}
+		err = d.writeRTP(&hdr, payload, PacerPriorityVideo, func(pktSize int) {
+			for _, f := range d.onPacketSentUnsafe {
+				f(d, pktSize)
+			}
 
-		d.updatePrimaryStats(pktSize, hdr.Marker)
+			d.updatePrimaryStats(pktSize, true)
+		})
+		if err != nil {
+			return err
+		}
 
 		// only the first frame will need frameEndNeeded to close out the
 		// previous picture, rest are small key frames
@@ -883,7 +1091,7 @@ func (d *DownTrack) writeBlankFrameRTP() error {
 	return nil
 }
 
-func (d *DownTrack) writeVP8BlankFrame(hdr *rtp.Header, frameEndNeeded bool) (int, error) {
+func (d *DownTrack) getVP8BlankFrame(frameEndNeeded bool) ([]byte, error) {
 	blankVP8 := d.forwarder.GetPaddingVP8(frameEndNeeded)
 
 	// 1x1 key frame
@@ -894,16 +1102,14 @@ func (d *DownTrack) writeVP8BlankFrame(hdr *rtp.Header, frameEndNeeded bool) (in
 	vp8Header := payload[:blankVP8.HeaderSize]
 	err := blankVP8.MarshalTo(vp8Header)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	copy(payload[blankVP8.HeaderSize:], VP8KeyFrame8x8)
-
-	_, err = d.writeStream.WriteRTP(hdr, payload)
-	return hdr.MarshalSize() + len(payload), err
+	return payload, nil
 }
 
-func (d *DownTrack) writeH264BlankFrame(hdr *rtp.Header, frameEndNeeded bool) (int, error) {
+func (d *DownTrack) getH264BlankFrame() []byte {
 	// TODO - Jie Zeng
 	// now use STAP-A to compose sps, pps, idr together, most decoder support packetization-mode 1.
 	// if client only support packetization-mode 0, use single nalu unit packet
@@ -917,8 +1123,24 @@ func (d *DownTrack) writeH264BlankFrame(hdr *rtp.Header, frameEndNeeded bool) (i
 		copy(buf[offset:offset+len(payload)], payload)
 		offset += len(payload)
 	}
-	_, err := d.writeStream.WriteRTP(hdr, buf[:offset])
-	return hdr.MarshalSize() + offset, err
+	return buf[:offset]
+}
+
+// handleRTXRTCP handles RTCP addressed to the retransmission stream, only transport-cc feedback is of interest
+func (d *DownTrack) handleRTXRTCP(bytes []byte) {
+	pkts, err := rtcp.Unmarshal(bytes)
+	if err != nil {
+		d.logger.Errorw("unmarshal rtcp receiver packets err", err)
+		return
+	}
+
+	for _, pkt := range pkts {
+		if p, ok := pkt.(*rtcp.TransportLayerCC); ok && p.MediaSSRC == d.rtxSSRC && d.onTransportCCFeedback != nil {
+			d.callbacksQueue.Enqueue(func() {
+				d.onTransportCCFeedback(d, p)
+			})
+		}
+	}
 }
 
 func (d *DownTrack) handleRTCP(bytes []byte) {
@@ -1051,13 +1273,19 @@ func (d *DownTrack) retransmitPackets(nacks []uint16) {
 	var pool *[]byte
 	defer func() {
 		if pool != nil {
-			PacketFactory.Put(pool)
+			buffer.PutPacketBuffer(pool)
 			pool = nil
 		}
 	}()
 
-	src := PacketFactory.Get().(*[]byte)
-	defer PacketFactory.Put(src)
+	src := buffer.GetPacketBuffer(buffer.PacketBufferSizeLarge)
+	defer buffer.PutPacketBuffer(src)
+
+	var rtxBuf *[]byte
+	if d.rtx != nil {
+		rtxBuf = buffer.GetPacketBuffer(buffer.PacketBufferSizeLarge)
+		defer buffer.PutPacketBuffer(rtxBuf)
+	}
 
 	numRepeatedNACKs := uint32(0)
 	for _, meta := range d.sequencer.getPacketsMeta(filtered) {
@@ -1070,7 +1298,7 @@ func (d *DownTrack) retransmitPackets(nacks []uint16) {
 			continue
 		}
 
-		if disallowedLayers[meta.layer] {
+		if int(meta.layer) < len(disallowedLayers) && disallowedLayers[meta.layer] {
 			continue
 		}
 
@@ -1079,7 +1307,7 @@ func (d *DownTrack) retransmitPackets(nacks []uint16) {
 		}
 
 		if pool != nil {
-			PacketFactory.Put(pool)
+			buffer.PutPacketBuffer(pool)
 			pool = nil
 		}
 
@@ -1111,7 +1339,7 @@ func (d *DownTrack) retransmitPackets(nacks []uint16) {
 			outbuf := &payload
 			translatedVP8 := meta.unpackVP8()
 			if incomingVP8.HeaderSize != translatedVP8.HeaderSize {
-				pool = PacketFactory.Get().(*[]byte)
+				pool = buffer.GetPacketBuffer(buffer.PacketBufferSizeLarge)
 				outbuf = pool
 			}
 			payload, err = d.translateVP8PacketTo(&pkt, &incomingVP8, translatedVP8, outbuf)
@@ -1121,21 +1349,32 @@ func (d *DownTrack) retransmitPackets(nacks []uint16) {
 			}
 		}
 
-		err = d.writeRTPHeaderExtensions(&pkt.Header)
+		hdr := pkt.Header
+		if d.rtx != nil {
+			// sent on the RTX stream, carrying the sequence number of the media packet
+			hdr = d.rtx.header(&pkt.Header)
+			payload, err = BuildRTXPayload(pkt.Header.SequenceNumber, payload, *rtxBuf)
+			if err != nil {
+				d.logger.Errorw("building rtx packet err", err)
+				continue
+			}
+		}
+
+		err = d.writeRTPHeaderExtensions(&hdr)
 		if err != nil {
 			d.logger.Errorw("writing rtp header extensions err", err)
 			continue
 		}
 
-		if _, err = d.writeStream.WriteRTP(&pkt.Header, payload); err != nil {
-			d.logger.Errorw("writing rtx packet err", err)
-		} else {
-			pktSize := pkt.Header.MarshalSize() + len(payload)
+		err = d.writeRTP(&hdr, payload, PacerPriorityRetransmission, func(pktSize int) {
 			for _, f := range d.onPacketSentUnsafe {
 				f(d, pktSize)
 			}
 
 			d.updateRtxStats(pktSize)
+		})
+		if err != nil {
+			d.logger.Errorw("writing rtx packet err", err)
 		}
 	}
 
@@ -1148,6 +1387,11 @@ func (d *DownTrack) getSRStats() (uint64, uint32) {
 	d.statsLock.RLock()
 	defer d.statsLock.RUnlock()
 
+	if d.rtx != nil {
+		// retransmissions and padding are sent on the RTX stream
+		return d.stats.TotalPrimaryBytes, d.stats.TotalPrimaryPackets
+	}
+
 	packets := d.stats.TotalPrimaryPackets + d.stats.TotalRetransmitPackets + d.stats.TotalPaddingPackets
 	octets := d.stats.TotalPrimaryBytes + d.stats.TotalRetransmitBytes + d.stats.TotalPaddingBytes
 
@@ -1272,6 +1516,13 @@ func (d *DownTrack) getTrackStats() map[uint32]*buffer.StreamStatsWithLayers {
 	return stats
 }
 
+func (d *DownTrack) GetBytesSent() uint64 {
+	d.statsLock.RLock()
+	defer d.statsLock.RUnlock()
+
+	return d.stats.TotalPrimaryBytes + d.stats.TotalRetransmitBytes
+}
+
 func (d *DownTrack) GetNackStats() (totalPackets uint32, totalRepeatedNACKs uint32) {
 	d.statsLock.RLock()
 	defer d.statsLock.RUnlock()
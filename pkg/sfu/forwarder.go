@@ -5,6 +5,7 @@ import (
 	"math"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/livekit/protocol/logger"
 	"github.com/pion/webrtc/v3"
@@ -136,6 +137,14 @@ func (v VideoLayers) String() string {
 	return fmt.Sprintf("VideoLayers{s: %d, t: %d}", v.spatial, v.temporal)
 }
 
+func (v VideoLayers) Spatial() int32 {
+	return v.spatial
+}
+
+func (v VideoLayers) Temporal() int32 {
+	return v.temporal
+}
+
 func (v VideoLayers) GreaterThan(v2 VideoLayers) bool {
 	return v.spatial > v2.spatial || (v.spatial == v2.spatial && v.temporal > v2.temporal)
 }
@@ -190,14 +199,22 @@ type Forwarder struct {
 	vp8Munger *VP8Munger
 
 	receivedFirstKeyFrame atomic.Bool
+
+	// started with a cached key frame, forwarding resumes at the next live key frame
+	primed bool
+
+	// highest spatial layer the up track can publish, max layers are capped to it
+	spatialLayerLimit int32
 }
 
-func NewForwarder(codec webrtc.RTPCodecCapability, kind webrtc.RTPCodecType, logger logger.Logger) *Forwarder {
+func NewForwarder(codec webrtc.RTPCodecCapability, kind webrtc.RTPCodecType, maxSpatialLayer int32, logger logger.Logger) *Forwarder {
 	f := &Forwarder{
 		codec:  codec,
 		kind:   kind,
 		logger: logger,
 
+		spatialLayerLimit: maxSpatialLayer,
+
 		// start off with nothing, let streamallocator set things
 		currentLayers: InvalidLayers,
 		targetLayers:  InvalidLayers,
@@ -212,7 +229,10 @@ func NewForwarder(codec webrtc.RTPCodecCapability, kind webrtc.RTPCodecType, log
 	}
 
 	if f.kind == webrtc.RTPCodecTypeVideo {
-		f.maxLayers = DefaultMaxLayers
+		f.maxLayers = VideoLayers{
+			spatial:  maxSpatialLayer,
+			temporal: DefaultMaxLayerTemporal,
+		}
 	} else {
 		f.maxLayers = InvalidLayers
 	}
@@ -243,6 +263,9 @@ func (f *Forwarder) SetMaxSpatialLayer(spatialLayer int32) (bool, VideoLayers, V
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
+	if spatialLayer > f.spatialLayerLimit {
+		spatialLayer = f.spatialLayerLimit
+	}
 	if f.kind == webrtc.RTPCodecTypeAudio || spatialLayer == f.maxLayers.spatial {
 		return false, f.maxLayers, f.currentLayers
 	}
@@ -315,11 +338,11 @@ func (f *Forwarder) UpTrackLayersChange(availableLayers []int32) {
 func (f *Forwarder) getOptimalBandwidthNeeded(brs Bitrates) int64 {
 	for i := f.maxLayers.spatial; i >= 0; i-- {
 		for j := f.maxLayers.temporal; j >= 0; j-- {
-			if brs[i][j] == 0 {
+			if brs.get(i, j) == 0 {
 				continue
 			}
 
-			return brs[i][j]
+			return brs.get(i, j)
 		}
 	}
 
@@ -335,7 +358,7 @@ func (f *Forwarder) getDistanceToDesired(brs Bitrates, targetLayers VideoLayers)
 	for s := f.maxLayers.spatial; s >= 0; s-- {
 		found := false
 		for t := f.maxLayers.temporal; t >= 0; t-- {
-			if brs[s][t] == 0 {
+			if brs.get(s, t) == 0 {
 				continue
 			}
 			if s == targetLayers.spatial && t == targetLayers.temporal {
@@ -369,7 +392,7 @@ func (f *Forwarder) BandwidthRequested(brs Bitrates) int64 {
 		return 0
 	}
 
-	return brs[f.targetLayers.spatial][f.targetLayers.temporal]
+	return brs.get(f.targetLayers.spatial, f.targetLayers.temporal)
 }
 
 func (f *Forwarder) DistanceToDesired() int32 {
@@ -441,17 +464,17 @@ func (f *Forwarder) Allocate(availableChannelCapacity int64, allowPause bool, br
 		// allocate best layer that fits
 		for s := f.maxLayers.spatial; s >= 0; s-- {
 			for t := f.maxLayers.temporal; t >= 0; t-- {
-				if brs[s][t] == 0 {
+				if brs.get(s, t) == 0 {
 					continue
 				}
 
-				if brs[s][t] <= availableChannelCapacity {
+				if brs.get(s, t) <= availableChannelCapacity {
 					targetLayers = VideoLayers{
 						spatial:  s,
 						temporal: t,
 					}
 
-					bandwidthRequested = brs[s][t]
+					bandwidthRequested = brs.get(s, t)
 					if bandwidthRequested == optimalBandwidthNeeded {
 						state = VideoAllocationStateOptimal
 					} else {
@@ -476,7 +499,7 @@ func (f *Forwarder) Allocate(availableChannelCapacity int64, allowPause bool, br
 				// find the lowest layer to prevent pausing
 				for s := int32(0); s <= f.maxLayers.spatial; s++ {
 					for t := int32(0); t <= f.maxLayers.temporal; t++ {
-						if brs[s][t] == 0 {
+						if brs.get(s, t) == 0 {
 							continue
 						}
 
@@ -485,7 +508,7 @@ func (f *Forwarder) Allocate(availableChannelCapacity int64, allowPause bool, br
 							temporal: t,
 						}
 
-						bandwidthRequested = brs[s][t]
+						bandwidthRequested = brs.get(s, t)
 
 						if f.targetLayers == InvalidLayers {
 							change = VideoStreamingChangeResuming
@@ -546,14 +569,14 @@ func (f *Forwarder) ProvisionalAllocate(availableChannelCapacity int64, layers V
 		return 0
 	}
 
-	requiredBitrate := f.provisional.bitrates[layers.spatial][layers.temporal]
+	requiredBitrate := f.provisional.bitrates.get(layers.spatial, layers.temporal)
 	if requiredBitrate == 0 {
 		return 0
 	}
 
 	alreadyAllocatedBitrate := int64(0)
 	if f.provisional.layers != InvalidLayers {
-		alreadyAllocatedBitrate = f.provisional.bitrates[f.provisional.layers.spatial][f.provisional.layers.temporal]
+		alreadyAllocatedBitrate = f.provisional.bitrates.get(f.provisional.layers.spatial, f.provisional.layers.temporal)
 	}
 
 	if requiredBitrate <= (availableChannelCapacity + alreadyAllocatedBitrate) {
@@ -610,9 +633,9 @@ func (f *Forwarder) ProvisionalAllocateGetCooperativeTransition() VideoTransitio
 		maximalBandwidthRequired := int64(0)
 		for s := f.maxLayers.spatial; s >= 0; s-- {
 			for t := f.maxLayers.temporal; t >= 0; t-- {
-				if f.provisional.bitrates[s][t] != 0 {
+				if f.provisional.bitrates.get(s, t) != 0 {
 					maximalLayers = VideoLayers{spatial: s, temporal: t}
-					maximalBandwidthRequired = f.provisional.bitrates[s][t]
+					maximalBandwidthRequired = f.provisional.bitrates.get(s, t)
 					break
 				}
 			}
@@ -623,7 +646,7 @@ func (f *Forwarder) ProvisionalAllocateGetCooperativeTransition() VideoTransitio
 		}
 
 		if maximalLayers != InvalidLayers {
-			if !f.targetLayers.GreaterThan(maximalLayers) && (f.provisional.bitrates[f.targetLayers.spatial][f.targetLayers.temporal] != 0) {
+			if !f.targetLayers.GreaterThan(maximalLayers) && (f.provisional.bitrates.get(f.targetLayers.spatial, f.targetLayers.temporal) != 0) {
 				// currently streaming and wanting an upgrade, just preserve current target in the cooperative scheme of things
 				f.provisional.layers = f.targetLayers
 				return VideoTransition{
@@ -651,9 +674,9 @@ func (f *Forwarder) ProvisionalAllocateGetCooperativeTransition() VideoTransitio
 	bandwidthRequired := int64(0)
 	for s := int32(0); s <= f.maxLayers.spatial; s++ {
 		for t := int32(0); t <= f.maxLayers.temporal; t++ {
-			if f.provisional.bitrates[s][t] != 0 {
+			if f.provisional.bitrates.get(s, t) != 0 {
 				minimalLayers = VideoLayers{spatial: s, temporal: t}
-				bandwidthRequired = f.provisional.bitrates[s][t]
+				bandwidthRequired = f.provisional.bitrates.get(s, t)
 				break
 			}
 		}
@@ -664,7 +687,7 @@ func (f *Forwarder) ProvisionalAllocateGetCooperativeTransition() VideoTransitio
 	}
 
 	targetLayers := f.targetLayers
-	if targetLayers == InvalidLayers || targetLayers.GreaterThan(minimalLayers) || (f.provisional.bitrates[targetLayers.spatial][targetLayers.temporal] == 0) {
+	if targetLayers == InvalidLayers || targetLayers.GreaterThan(minimalLayers) || (f.provisional.bitrates.get(targetLayers.spatial, targetLayers.temporal) == 0) {
 		targetLayers = minimalLayers
 	}
 
@@ -707,7 +730,7 @@ func (f *Forwarder) ProvisionalAllocateGetBestWeightedTransition() VideoTransiti
 	maxReachableLayerTemporal := int32(-1)
 	for t := f.maxLayers.temporal; t >= 0; t-- {
 		for s := f.maxLayers.spatial; s >= 0; s-- {
-			if f.provisional.bitrates[s][t] != 0 {
+			if f.provisional.bitrates.get(s, t) != 0 {
 				maxReachableLayerTemporal = t
 				break
 			}
@@ -738,7 +761,7 @@ func (f *Forwarder) ProvisionalAllocateGetBestWeightedTransition() VideoTransiti
 				break
 			}
 
-			bandwidthDelta := int64(math.Max(float64(0), float64(f.lastAllocation.bandwidthRequested-f.provisional.bitrates[s][t])))
+			bandwidthDelta := int64(math.Max(float64(0), float64(f.lastAllocation.bandwidthRequested-f.provisional.bitrates.get(s, t))))
 
 			transitionCost := int32(0)
 			if f.targetLayers.spatial != s {
@@ -802,7 +825,7 @@ func (f *Forwarder) ProvisionalAllocateCommit() VideoAllocation {
 			change = VideoStreamingChangePausing
 		}
 	default:
-		bandwidthRequested = f.provisional.bitrates[f.provisional.layers.spatial][f.provisional.layers.temporal]
+		bandwidthRequested = f.provisional.bitrates.get(f.provisional.layers.spatial, f.provisional.layers.temporal)
 		if bandwidthRequested == optimalBandwidthNeeded {
 			state = VideoAllocationStateOptimal
 		} else {
@@ -868,7 +891,7 @@ func (f *Forwarder) FinalizeAllocate(brs Bitrates) VideoAllocation {
 	// finalize using optimal layer
 	for s := f.maxLayers.spatial; s >= 0; s-- {
 		for t := f.maxLayers.temporal; t >= 0; t-- {
-			bandwidthRequested := brs[s][t]
+			bandwidthRequested := brs.get(s, t)
 			if bandwidthRequested == 0 {
 				continue
 			}
@@ -932,13 +955,13 @@ func (f *Forwarder) AllocateNextHigher(availableChannelCapacity int64, brs Bitra
 
 	alreadyAllocated := int64(0)
 	if f.targetLayers != InvalidLayers {
-		alreadyAllocated = brs[f.targetLayers.spatial][f.targetLayers.temporal]
+		alreadyAllocated = brs.get(f.targetLayers.spatial, f.targetLayers.temporal)
 	}
 
 	// try moving temporal layer up in currently streaming spatial layer
 	if f.targetLayers != InvalidLayers {
 		for t := f.targetLayers.temporal + 1; t <= f.maxLayers.temporal; t++ {
-			bandwidthRequested := brs[f.targetLayers.spatial][t]
+			bandwidthRequested := brs.get(f.targetLayers.spatial, t)
 			if bandwidthRequested == 0 {
 				continue
 			}
@@ -973,7 +996,7 @@ func (f *Forwarder) AllocateNextHigher(availableChannelCapacity int64, brs Bitra
 	// try moving spatial layer up if temporal layer move up is not available
 	for s := f.targetLayers.spatial + 1; s <= f.maxLayers.spatial; s++ {
 		for t := int32(0); t <= f.maxLayers.temporal; t++ {
-			bandwidthRequested := brs[s][t]
+			bandwidthRequested := brs.get(s, t)
 			if bandwidthRequested == 0 {
 				continue
 			}
@@ -1040,13 +1063,13 @@ func (f *Forwarder) GetNextHigherTransition(brs Bitrates) (VideoTransition, bool
 
 	alreadyAllocated := int64(0)
 	if f.targetLayers != InvalidLayers {
-		alreadyAllocated = brs[f.targetLayers.spatial][f.targetLayers.temporal]
+		alreadyAllocated = brs.get(f.targetLayers.spatial, f.targetLayers.temporal)
 	}
 
 	// try moving temporal layer up in currently streaming spatial layer
 	if f.targetLayers != InvalidLayers {
 		for t := f.targetLayers.temporal + 1; t <= f.maxLayers.temporal; t++ {
-			bandwidthRequested := brs[f.targetLayers.spatial][t]
+			bandwidthRequested := brs.get(f.targetLayers.spatial, t)
 			if bandwidthRequested == 0 {
 				continue
 			}
@@ -1064,7 +1087,7 @@ func (f *Forwarder) GetNextHigherTransition(brs Bitrates) (VideoTransition, bool
 	// try moving spatial layer up if temporal layer move up is not available
 	for s := f.targetLayers.spatial + 1; s <= f.maxLayers.spatial; s++ {
 		for t := int32(0); t <= f.maxLayers.temporal; t++ {
-			bandwidthRequested := brs[s][t]
+			bandwidthRequested := brs.get(s, t)
 			if bandwidthRequested == 0 {
 				continue
 			}
@@ -1144,7 +1167,8 @@ func (f *Forwarder) resyncLocked() {
 	f.lastSSRC = 0
 }
 
-func (f *Forwarder) FilterRTX(nacks []uint16) (filtered []uint16, disallowedLayers [DefaultMaxLayerSpatial + 1]bool) {
+// FilterRTX returns the NACKs to retransmit, disallowedLayers are indexed by spatial layer and nil when none are
+func (f *Forwarder) FilterRTX(nacks []uint16) (filtered []uint16, disallowedLayers []bool) {
 	if !FlagFilterRTX {
 		filtered = nacks
 		return
@@ -1163,9 +1187,12 @@ func (f *Forwarder) FilterRTX(nacks []uint16) (filtered []uint16, disallowedLaye
 	//
 	// Without the curb, when congestion hits, RTX rate could be so high that it further congests the channel.
 	//
-	for layer := int32(0); layer < DefaultMaxLayerSpatial+1; layer++ {
+	for layer := int32(0); layer <= f.spatialLayerLimit; layer++ {
 		if f.lastAllocation.state == VideoAllocationStateDeficient &&
 			(f.targetLayers.spatial < f.currentLayers.spatial || layer > f.currentLayers.spatial) {
+			if disallowedLayers == nil {
+				disallowedLayers = make([]bool, f.spatialLayerLimit+1)
+			}
 			disallowedLayers[layer] = true
 		}
 	}
@@ -1197,6 +1224,21 @@ func (f *Forwarder) ReceivedFirstKeyFrame() bool {
 	return f.receivedFirstKeyFrame.Load()
 }
 
+// SetPrimed indicates that a cached key frame has been forwarded to start the stream.
+// Packets following the cached key frame in the live stream are missing for the subscriber,
+// so everything up to the next key frame is dropped and the next key frame is stitched in like a layer switch.
+func (f *Forwarder) SetPrimed() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !f.started {
+		return
+	}
+
+	f.primed = true
+	f.lTSCalc = time.Now().UnixNano()
+}
+
 // should be called with lock held
 func (f *Forwarder) getTranslationParamsAudio(extPkt *buffer.ExtPacket) (*TranslationParams, error) {
 	if f.lastSSRC != extPkt.Packet.SSRC {
@@ -1292,6 +1334,18 @@ func (f *Forwarder) getTranslationParamsVideo(extPkt *buffer.ExtPacket, layer in
 		return tp, nil
 	}
 
+	if f.primed {
+		if !extPkt.KeyFrame {
+			tp.shouldDrop = true
+			tp.shouldSendPLI = true
+			return tp, nil
+		}
+
+		f.primed = false
+		// force offsets to be re-calculated from the last forwarded cached packet
+		f.lastSSRC = 0
+	}
+
 	if f.lastSSRC != extPkt.Packet.SSRC {
 		if !f.started {
 			f.started = true
@@ -3,6 +3,7 @@ package sfu
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/livekit/protocol/logger"
 	"github.com/pion/webrtc/v3"
@@ -18,7 +19,7 @@ func disable(f *Forwarder) {
 }
 
 func newForwarder(codec webrtc.RTPCodecCapability, kind webrtc.RTPCodecType) *Forwarder {
-	return NewForwarder(codec, kind, logger.Logger(logger.GetLogger()))
+	return NewForwarder(codec, kind, DefaultMaxLayerSpatial, logger.Logger(logger.GetLogger()))
 
 }
 
@@ -112,6 +113,32 @@ func TestForwarderLayersVideo(t *testing.T) {
 	require.Equal(t, VideoLayers{spatial: 0, temporal: 1}, currentLayers)
 }
 
+func TestForwarderLayersVideoMoreSpatial(t *testing.T) {
+	f := NewForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo, 4, logger.Logger(logger.GetLogger()))
+
+	require.Equal(t, VideoLayers{spatial: 4, temporal: DefaultMaxLayerTemporal}, f.MaxLayers())
+
+	changed, maxLayers, _ := f.SetMaxSpatialLayer(3)
+	require.True(t, changed)
+	require.Equal(t, VideoLayers{spatial: 3, temporal: DefaultMaxLayerTemporal}, maxLayers)
+
+	// cannot go beyond the layers the publisher can send
+	changed, maxLayers, _ = f.SetMaxSpatialLayer(6)
+	require.True(t, changed)
+	require.Equal(t, VideoLayers{spatial: 4, temporal: DefaultMaxLayerTemporal}, maxLayers)
+
+	bitrates := Bitrates{
+		{1, 2, 3, 4},
+		{5, 6, 7, 8},
+		{9, 10, 11, 12},
+		{13, 14, 15, 16},
+		{17, 18, 19, 20},
+	}
+	require.Equal(t, int64(20), bitrates.get(4, 3))
+	require.Equal(t, int64(0), bitrates.get(5, 0))
+	require.Equal(t, int64(0), bitrates.get(-1, 0))
+}
+
 func TestForwarderGetForwardingStatus(t *testing.T) {
 	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
 
@@ -163,7 +190,7 @@ func TestForwarderUpTrackLayersChange(t *testing.T) {
 func TestForwarderAllocate(t *testing.T) {
 	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
 
-	emptyBitrates := Bitrates{}
+	var emptyBitrates Bitrates
 	bitrates := Bitrates{
 		{2, 3, 0, 0},
 		{4, 0, 0, 5},
@@ -593,7 +620,7 @@ func TestForwarderFinalizeAllocate(t *testing.T) {
 		bandwidthRequested: 0,
 		bandwidthDelta:     0,
 		availableLayers:    nil,
-		bitrates:           Bitrates{},
+		bitrates:           nil,
 		targetLayers:       InvalidLayers,
 		distanceToDesired:  0,
 	}
@@ -689,7 +716,7 @@ func TestForwarderFinalizeAllocate(t *testing.T) {
 func TestForwarderAllocateNextHigher(t *testing.T) {
 	f := newForwarder(testutils.TestOpusCodec, webrtc.RTPCodecTypeAudio)
 
-	emptyBitrates := Bitrates{}
+	var emptyBitrates Bitrates
 	bitrates := Bitrates{
 		{2, 3, 0, 0},
 		{4, 0, 0, 5},
@@ -1481,6 +1508,89 @@ func TestForwarderGetTranslationParamsVideo(t *testing.T) {
 	require.Equal(t, f.lastSSRC, params.SSRC)
 }
 
+func TestForwarderGetTranslationParamsVideoPrimed(t *testing.T) {
+	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
+	f.targetLayers = VideoLayers{
+		spatial:  0,
+		temporal: 1,
+	}
+
+	vp8 := func(pictureID uint16, keyFrame bool) *buffer.VP8 {
+		return &buffer.VP8{
+			FirstByte:        25,
+			PictureIDPresent: 1,
+			PictureID:        pictureID,
+			MBit:             true,
+			TIDPresent:       1,
+			TID:              0,
+			HeaderSize:       5,
+			IsKeyFrame:       keyFrame,
+		}
+	}
+
+	// cached key frame starts the stream
+	params := &testutils.TestExtPacketParams{
+		IsHead:         true,
+		SetMarker:      true,
+		SequenceNumber: 100,
+		Timestamp:      1000,
+		SSRC:           0x12345678,
+		PayloadSize:    20,
+	}
+	extPkt, _ := testutils.GetTestExtPacketVP8(params, vp8(300, true))
+	actualTP, err := f.GetTranslationParams(extPkt, 0)
+	require.NoError(t, err)
+	require.False(t, actualTP.shouldDrop)
+	require.True(t, f.ReceivedFirstKeyFrame())
+
+	f.SetPrimed()
+
+	// live stream continues from a point the subscriber has no references for, drop till a key frame
+	params = &testutils.TestExtPacketParams{
+		IsHead:         true,
+		SequenceNumber: 500,
+		Timestamp:      50000,
+		SSRC:           0x12345678,
+		PayloadSize:    20,
+	}
+	extPkt, _ = testutils.GetTestExtPacketVP8(params, vp8(700, false))
+	actualTP, err = f.GetTranslationParams(extPkt, 0)
+	require.NoError(t, err)
+	require.Equal(t, TranslationParams{shouldDrop: true, shouldSendPLI: true}, *actualTP)
+
+	// live key frame is stitched to the cached one
+	params = &testutils.TestExtPacketParams{
+		IsHead:         true,
+		SequenceNumber: 510,
+		Timestamp:      60000,
+		SSRC:           0x12345678,
+		PayloadSize:    20,
+		ArrivalTime:    time.Now().UnixNano(),
+	}
+	extPkt, _ = testutils.GetTestExtPacketVP8(params, vp8(710, true))
+	actualTP, err = f.GetTranslationParams(extPkt, 0)
+	require.NoError(t, err)
+	require.False(t, actualTP.shouldDrop)
+	require.Equal(t, uint16(101), actualTP.rtp.sequenceNumber)
+	require.Greater(t, actualTP.rtp.timestamp, uint32(1000))
+	require.Less(t, actualTP.rtp.timestamp, uint32(1000+90000))
+	require.Equal(t, uint16(301), actualTP.vp8.header.PictureID)
+
+	// and forwarding continues
+	params = &testutils.TestExtPacketParams{
+		IsHead:         true,
+		SequenceNumber: 511,
+		Timestamp:      60000,
+		SSRC:           0x12345678,
+		PayloadSize:    20,
+	}
+	extPkt, _ = testutils.GetTestExtPacketVP8(params, vp8(710, false))
+	actualTP, err = f.GetTranslationParams(extPkt, 0)
+	require.NoError(t, err)
+	require.False(t, actualTP.shouldDrop)
+	require.Equal(t, uint16(102), actualTP.rtp.sequenceNumber)
+}
+
 func TestForwardGetSnTsForPadding(t *testing.T) {
 	f := newForwarder(testutils.TestVP8Codec, webrtc.RTPCodecTypeVideo)
 
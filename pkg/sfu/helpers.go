@@ -22,8 +22,9 @@ const (
 )
 
 // Do a fuzzy find for a codec in the list of codecs
-// Used for lookup up a codec in an existing list to find a match
-func codecParametersFuzzySearch(needle webrtc.RTPCodecParameters, haystack []webrtc.RTPCodecParameters) (webrtc.RTPCodecParameters, error) {
+// Used for lookup up a codec in an existing list to find a match.
+// A codec with compatible fmtp parameters is preferred, unless strict it falls back to one with the same mime type
+func codecParametersFuzzySearch(needle webrtc.RTPCodecParameters, haystack []webrtc.RTPCodecParameters, strict bool) (webrtc.RTPCodecParameters, error) {
 	// First attempt to match on MimeType + SDPFmtpLine
 	for _, c := range haystack {
 		if strings.EqualFold(c.RTPCodecCapability.MimeType, needle.RTPCodecCapability.MimeType) &&
@@ -32,6 +33,16 @@ func codecParametersFuzzySearch(needle webrtc.RTPCodecParameters, haystack []web
 		}
 	}
 
+	// Then on the fmtp parameters that change the bitstream
+	for _, c := range haystack {
+		if CodecsCompatible(needle.RTPCodecCapability, c.RTPCodecCapability) {
+			return c, nil
+		}
+	}
+	if strict {
+		return webrtc.RTPCodecParameters{}, webrtc.ErrCodecNotFound
+	}
+
 	// Fallback to just MimeType
 	for _, c := range haystack {
 		if strings.EqualFold(c.RTPCodecCapability.MimeType, needle.RTPCodecCapability.MimeType) {
@@ -42,6 +53,55 @@ func codecParametersFuzzySearch(needle webrtc.RTPCodecParameters, haystack []web
 	return webrtc.RTPCodecParameters{}, webrtc.ErrCodecNotFound
 }
 
+// CodecsCompatible reports whether media sent as codec a can be decoded by a receiver that negotiated codec b.
+// Only fmtp parameters that change the bitstream are compared, profile and packetization-mode for H264 and
+// profile-id for VP9 and profile for AV1
+func CodecsCompatible(a, b webrtc.RTPCodecCapability) bool {
+	if !strings.EqualFold(a.MimeType, b.MimeType) {
+		return false
+	}
+
+	aFmtp, bFmtp := parseFmtpLine(a.SDPFmtpLine), parseFmtpLine(b.SDPFmtpLine)
+	switch strings.ToLower(a.MimeType) {
+	case "video/h264":
+		return h264Profile(aFmtp) == h264Profile(bFmtp) &&
+			fmtpValue(aFmtp, "packetization-mode", "0") == fmtpValue(bFmtp, "packetization-mode", "0")
+	case "video/vp9":
+		return fmtpValue(aFmtp, "profile-id", "0") == fmtpValue(bFmtp, "profile-id", "0")
+	case "video/av1":
+		return fmtpValue(aFmtp, "profile", "0") == fmtpValue(bFmtp, "profile", "0")
+	}
+	return true
+}
+
+// profile_idc and profile-iop of profile-level-id, the level doesn't need to match
+func h264Profile(fmtp map[string]string) string {
+	profileLevelID := fmtpValue(fmtp, "profile-level-id", "420010")
+	if len(profileLevelID) != 6 {
+		return profileLevelID
+	}
+	return profileLevelID[:4]
+}
+
+func fmtpValue(fmtp map[string]string, key string, defaultValue string) string {
+	if value, ok := fmtp[key]; ok {
+		return strings.ToLower(value)
+	}
+	return defaultValue
+}
+
+func parseFmtpLine(line string) map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(line, ";") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+	}
+	return params
+}
+
 func (t ntpTime) Duration() time.Duration {
 	sec := (t >> 32) * 1e9
 	frac := (t & 0xffffffff) * 1e9
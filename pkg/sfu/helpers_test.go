@@ -3,6 +3,9 @@ package sfu
 import (
 	"testing"
 	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
 )
 
 func Test_timeToNtp(t *testing.T) {
@@ -32,3 +35,72 @@ func Test_timeToNtp(t *testing.T) {
 		})
 	}
 }
+
+func TestCodecsCompatible(t *testing.T) {
+	h264 := func(fmtp string) webrtc.RTPCodecCapability {
+		return webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000, SDPFmtpLine: fmtp}
+	}
+	vp9 := func(fmtp string) webrtc.RTPCodecCapability {
+		return webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP9, ClockRate: 90000, SDPFmtpLine: fmtp}
+	}
+
+	// level and unrelated parameters don't matter
+	require.True(t, CodecsCompatible(
+		h264("level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f"),
+		h264("packetization-mode=1;profile-level-id=42E034"),
+	))
+	require.False(t, CodecsCompatible(
+		h264("packetization-mode=1;profile-level-id=640032"),
+		h264("packetization-mode=1;profile-level-id=42e01f"),
+	))
+	require.False(t, CodecsCompatible(
+		h264("packetization-mode=1;profile-level-id=42e01f"),
+		h264("packetization-mode=0;profile-level-id=42e01f"),
+	))
+	// packetization-mode defaults to 0
+	require.True(t, CodecsCompatible(h264("profile-level-id=42e01f"), h264("packetization-mode=0;profile-level-id=42e01f")))
+
+	require.True(t, CodecsCompatible(vp9(""), vp9("profile-id=0")))
+	require.False(t, CodecsCompatible(vp9("profile-id=0"), vp9("profile-id=2")))
+
+	av1 := func(fmtp string) webrtc.RTPCodecCapability {
+		return webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeAV1, ClockRate: 90000, SDPFmtpLine: fmtp}
+	}
+	require.True(t, CodecsCompatible(av1(""), av1("profile=0;level-idx=5;tier=0")))
+	require.False(t, CodecsCompatible(av1("profile=0"), av1("profile=1")))
+
+	// opus parameters don't change what a receiver can decode
+	require.True(t, CodecsCompatible(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, SDPFmtpLine: "minptime=10;useinbandfec=1;stereo=1;usedtx=1"},
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, SDPFmtpLine: "minptime=10;useinbandfec=1"},
+	))
+
+	require.True(t, CodecsCompatible(webrtc.RTPCodecCapability{MimeType: "video/vp8"}, webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}))
+	require.False(t, CodecsCompatible(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, h264("")))
+}
+
+func TestCodecParametersFuzzySearch(t *testing.T) {
+	baseline := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f"},
+		PayloadType:        125,
+	}
+	high := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640032"},
+		PayloadType:        123,
+	}
+	needle := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, SDPFmtpLine: "packetization-mode=1;profile-level-id=64001f"},
+	}
+
+	// a compatible profile is preferred over the first codec with the same mime type
+	codec, err := codecParametersFuzzySearch(needle, []webrtc.RTPCodecParameters{baseline, high}, true)
+	require.NoError(t, err)
+	require.Equal(t, high.PayloadType, codec.PayloadType)
+
+	// without a compatible profile, only lenient matching falls back to the mime type
+	_, err = codecParametersFuzzySearch(needle, []webrtc.RTPCodecParameters{baseline}, true)
+	require.ErrorIs(t, err, webrtc.ErrCodecNotFound)
+	codec, err = codecParametersFuzzySearch(needle, []webrtc.RTPCodecParameters{baseline}, false)
+	require.NoError(t, err)
+	require.Equal(t, baseline.PayloadType, codec.PayloadType)
+}
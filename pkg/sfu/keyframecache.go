@@ -0,0 +1,121 @@
+package sfu
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+// KeyFrameCache holds the most recent complete key frame of a layer so that new subscribers
+// can be started without waiting for the publisher to send one.
+//
+// Packets of a frame are collected as they are forwarded and the frame is published only once it is
+// complete, i.e. it starts with a key frame packet, has contiguous sequence numbers, ends with a marker
+// and, for H.264, carries the parameter sets needed to decode it. Anything else is discarded.
+type KeyFrameCache struct {
+	mime         string
+	maxFrameSize int
+
+	// accessed only from the forwarding goroutine of the layer
+	building     []*buffer.ExtPacket
+	buildingSize int
+	lastSN       uint16
+	lastTS       uint32
+
+	lock   sync.RWMutex
+	cached []*buffer.ExtPacket
+}
+
+func NewKeyFrameCache(mime string, maxFrameSize int) *KeyFrameCache {
+	return &KeyFrameCache{
+		mime:         strings.ToLower(mime),
+		maxFrameSize: maxFrameSize,
+	}
+}
+
+// IsKeyFrameCacheSupported returns true for codecs whose key frames can be validated by the cache
+func IsKeyFrameCacheSupported(mime string) bool {
+	mime = strings.ToLower(mime)
+	return mime == strings.ToLower(webrtc.MimeTypeVP8) || mime == strings.ToLower(webrtc.MimeTypeH264)
+}
+
+func (k *KeyFrameCache) Add(extPkt *buffer.ExtPacket) {
+	if len(extPkt.Packet.Payload) == 0 {
+		// padding does not belong to any frame
+		return
+	}
+
+	switch {
+	case extPkt.KeyFrame && (k.building == nil || extPkt.Packet.Timestamp != k.lastTS):
+		k.building = make([]*buffer.ExtPacket, 0, 16)
+		k.buildingSize = 0
+	case k.building == nil:
+		return
+	case extPkt.Packet.Timestamp != k.lastTS || extPkt.Packet.SequenceNumber != k.lastSN+1:
+		// lost, re-ordered or incomplete frame, wait for the next key frame
+		k.abandon()
+		return
+	}
+
+	k.buildingSize += extPkt.Packet.MarshalSize()
+	if k.buildingSize > k.maxFrameSize {
+		k.abandon()
+		return
+	}
+
+	// packets handed out by the buffer go back to the pool once forwarded, a cached packet needs its own
+	k.building = append(k.building, extPkt.Clone())
+	k.lastSN = extPkt.Packet.SequenceNumber
+	k.lastTS = extPkt.Packet.Timestamp
+
+	if extPkt.Packet.Marker {
+		if k.isComplete() {
+			k.lock.Lock()
+			k.cached = k.building
+			k.lock.Unlock()
+		}
+		k.abandon()
+	}
+}
+
+// Get returns the packets of the cached key frame, the returned packets must not be modified
+func (k *KeyFrameCache) Get() []*buffer.ExtPacket {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+
+	return k.cached
+}
+
+func (k *KeyFrameCache) abandon() {
+	k.building = nil
+	k.buildingSize = 0
+}
+
+func (k *KeyFrameCache) isComplete() bool {
+	if len(k.building) == 0 || !k.building[0].KeyFrame {
+		return false
+	}
+
+	if k.mime != strings.ToLower(webrtc.MimeTypeH264) {
+		return true
+	}
+
+	// an IDR slice cannot be decoded by a new subscriber without the parameter sets
+	var hasSPS, hasPPS, hasIDR bool
+	for _, pkt := range k.building {
+		for _, nalu := range buffer.H264NALUnitTypes(pkt.Packet.Payload) {
+			switch nalu {
+			case 5:
+				hasIDR = true
+			case 7:
+				hasSPS = true
+			case 8:
+				hasPPS = true
+			}
+		}
+	}
+	return hasSPS && hasPPS && hasIDR
+}
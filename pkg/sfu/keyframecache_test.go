@@ -0,0 +1,120 @@
+package sfu
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/sfu/testutils"
+)
+
+func newKeyFrameCachePacket(t *testing.T, sn uint16, ts uint32, keyFrame bool, marker bool, payload []byte) *buffer.ExtPacket {
+	extPkt, err := testutils.GetTestExtPacket(&testutils.TestExtPacketParams{
+		IsKeyFrame:     keyFrame,
+		SetMarker:      marker,
+		SequenceNumber: sn,
+		Timestamp:      ts,
+		SSRC:           0x12345678,
+	})
+	require.NoError(t, err)
+	extPkt.Packet.Payload = payload
+	return extPkt
+}
+
+func TestKeyFrameCacheVP8(t *testing.T) {
+	payload := []byte{0x10, 0x00, 0x9d, 0x01, 0x2a}
+
+	t.Run("caches a complete key frame", func(t *testing.T) {
+		k := NewKeyFrameCache(webrtc.MimeTypeVP8, 1500)
+		require.Nil(t, k.Get())
+
+		k.Add(newKeyFrameCachePacket(t, 10, 1000, true, false, payload))
+		k.Add(newKeyFrameCachePacket(t, 11, 1000, false, false, payload))
+		// not complete yet
+		require.Nil(t, k.Get())
+		k.Add(newKeyFrameCachePacket(t, 12, 1000, false, true, payload))
+
+		pkts := k.Get()
+		require.Len(t, pkts, 3)
+		require.True(t, pkts[0].KeyFrame)
+		require.Equal(t, uint16(12), pkts[2].Packet.SequenceNumber)
+
+		// delta frames do not replace it
+		k.Add(newKeyFrameCachePacket(t, 13, 4000, false, true, payload))
+		require.Len(t, k.Get(), 3)
+	})
+
+	t.Run("packets are copied", func(t *testing.T) {
+		k := NewKeyFrameCache(webrtc.MimeTypeVP8, 1500)
+		extPkt := newKeyFrameCachePacket(t, 10, 1000, true, true, []byte{0x10, 0x00, 0x9d})
+		k.Add(extPkt)
+		extPkt.Packet.Payload[2] = 0xff
+		require.Equal(t, []byte{0x10, 0x00, 0x9d}, k.Get()[0].Packet.Payload)
+	})
+
+	t.Run("frame with a lost packet is not cached", func(t *testing.T) {
+		k := NewKeyFrameCache(webrtc.MimeTypeVP8, 1500)
+		k.Add(newKeyFrameCachePacket(t, 10, 1000, true, false, payload))
+		k.Add(newKeyFrameCachePacket(t, 12, 1000, false, true, payload))
+		require.Nil(t, k.Get())
+	})
+
+	t.Run("frame without marker is not cached", func(t *testing.T) {
+		k := NewKeyFrameCache(webrtc.MimeTypeVP8, 1500)
+		k.Add(newKeyFrameCachePacket(t, 10, 1000, true, false, payload))
+		k.Add(newKeyFrameCachePacket(t, 11, 4000, false, true, payload))
+		require.Nil(t, k.Get())
+	})
+
+	t.Run("frame over size limit is not cached", func(t *testing.T) {
+		k := NewKeyFrameCache(webrtc.MimeTypeVP8, 40)
+		k.Add(newKeyFrameCachePacket(t, 10, 1000, true, false, payload))
+		k.Add(newKeyFrameCachePacket(t, 11, 1000, false, false, payload))
+		k.Add(newKeyFrameCachePacket(t, 12, 1000, false, true, payload))
+		require.Nil(t, k.Get())
+	})
+
+	t.Run("newer key frame replaces cached one", func(t *testing.T) {
+		k := NewKeyFrameCache(webrtc.MimeTypeVP8, 1500)
+		k.Add(newKeyFrameCachePacket(t, 10, 1000, true, true, payload))
+		k.Add(newKeyFrameCachePacket(t, 20, 9000, true, true, payload))
+		require.Len(t, k.Get(), 1)
+		require.Equal(t, uint32(9000), k.Get()[0].Packet.Timestamp)
+	})
+}
+
+func TestKeyFrameCacheH264(t *testing.T) {
+	stapSPSPPS := []byte{0x78, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xce}
+	idr := []byte{0x65, 0x88, 0x84}
+	fuIDRStart := []byte{0x7c, 0x85, 0x88}
+	fuIDREnd := []byte{0x7c, 0x45, 0x84}
+
+	t.Run("parameter sets and IDR", func(t *testing.T) {
+		k := NewKeyFrameCache(webrtc.MimeTypeH264, 1500)
+		k.Add(newKeyFrameCachePacket(t, 10, 1000, true, false, stapSPSPPS))
+		k.Add(newKeyFrameCachePacket(t, 11, 1000, true, false, fuIDRStart))
+		k.Add(newKeyFrameCachePacket(t, 12, 1000, false, true, fuIDREnd))
+		require.Len(t, k.Get(), 3)
+	})
+
+	t.Run("IDR without parameter sets is not cached", func(t *testing.T) {
+		k := NewKeyFrameCache(webrtc.MimeTypeH264, 1500)
+		k.Add(newKeyFrameCachePacket(t, 10, 1000, true, true, idr))
+		require.Nil(t, k.Get())
+	})
+
+	t.Run("parameter sets without IDR are not cached", func(t *testing.T) {
+		k := NewKeyFrameCache(webrtc.MimeTypeH264, 1500)
+		k.Add(newKeyFrameCachePacket(t, 10, 1000, true, true, stapSPSPPS))
+		require.Nil(t, k.Get())
+	})
+}
+
+func TestIsKeyFrameCacheSupported(t *testing.T) {
+	require.True(t, IsKeyFrameCacheSupported(webrtc.MimeTypeVP8))
+	require.True(t, IsKeyFrameCacheSupported("video/h264"))
+	require.False(t, IsKeyFrameCacheSupported(webrtc.MimeTypeVP9))
+	require.False(t, IsKeyFrameCacheSupported(webrtc.MimeTypeOpus))
+}
@@ -0,0 +1,379 @@
+//
+// Design of Pacer
+//
+// Media forwarded to a subscriber goes out as soon as it arrives from the
+// publisher. That is mostly fine as publishers pace their own sends, but a
+// key frame requested on a layer switch is forwarded as a burst. Large key
+// frames overflow shallow buffers on consumer routers, causing loss right
+// after every layer switch.
+//
+// The pacer sits on the send path of a subscriber peer connection. Packets
+// are queued by priority (RTCP, audio, retransmissions, video, padding) and
+// sent every interval against a byte budget, which refills at a multiple of
+// the estimated channel capacity and is capped at the burst size. RTCP and
+// audio do not wait for the budget, but use it up.
+//
+// To address the scalability concern noted in the prober, the pacer goroutine
+// only runs the interval loop while there are packets queued, an idle pacer
+// sends the first packets right away.
+//
+// Video waiting longer than the max queue delay means more is forwarded than
+// the channel carries. That is signalled so that the stream allocator can
+// downgrade layers.
+//
+package sfu
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gammazero/deque"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+const (
+	PacerIntervalDefault       = 5 * time.Millisecond
+	PacerBurstSizeDefault      = 15000
+	PacerPacingFactorDefault   = 2.5
+	PacerInitialBitrateDefault = 10 * 1000 * 1000
+	PacerMaxQueueDelayDefault  = 500 * time.Millisecond
+
+	// packets queued beyond this are dropped, bounds memory used per peer connection
+	PacerMaxQueuedBytes = 4 * 1000 * 1000
+)
+
+var (
+	ErrPacerQueueFull = errors.New("pacer queue full")
+)
+
+type PacerPriority int
+
+const (
+	PacerPriorityRTCP PacerPriority = iota
+	PacerPriorityAudio
+	PacerPriorityRetransmission
+	PacerPriorityVideo
+	PacerPriorityPadding
+	numPacerPriorities
+)
+
+func (p PacerPriority) String() string {
+	switch p {
+	case PacerPriorityRTCP:
+		return "rtcp"
+	case PacerPriorityAudio:
+		return "audio"
+	case PacerPriorityRetransmission:
+		return "retransmission"
+	case PacerPriorityVideo:
+		return "video"
+	case PacerPriorityPadding:
+		return "padding"
+	default:
+		return "unknown"
+	}
+}
+
+type PacerParams struct {
+	Config config.PacerConfig
+	Logger logger.Logger
+}
+
+type pacerPacket struct {
+	size     int
+	queuedAt time.Time
+	write    func()
+}
+
+type Pacer struct {
+	params PacerParams
+
+	interval       time.Duration
+	burstSize      int
+	pacingFactor   float64
+	initialBitrate int64
+	maxQueueDelay  time.Duration
+
+	lock        sync.Mutex
+	queues      [numPacerPriorities]deque.Deque
+	queuedBytes int
+	pacingRate  int64
+	budget      int
+	lastRefill  time.Time
+	lastBuildup time.Time
+	isStopped   bool
+
+	wake   chan struct{}
+	closed chan struct{}
+
+	onQueueBuildup func(queueDelay time.Duration)
+	onPacketSent   func(priority PacerPriority, delay time.Duration)
+	onQueueDepth   func(depth int)
+}
+
+func NewPacer(params PacerParams) *Pacer {
+	p := &Pacer{
+		params:         params,
+		interval:       params.Config.Interval.Duration(),
+		burstSize:      params.Config.BurstSize,
+		pacingFactor:   params.Config.PacingFactor,
+		initialBitrate: int64(params.Config.InitialBitrate),
+		maxQueueDelay:  params.Config.MaxQueueDelay.Duration(),
+		wake:           make(chan struct{}, 1),
+		closed:         make(chan struct{}),
+	}
+	if p.interval == 0 {
+		p.interval = PacerIntervalDefault
+	}
+	if p.burstSize == 0 {
+		p.burstSize = PacerBurstSizeDefault
+	}
+	if p.pacingFactor == 0 {
+		p.pacingFactor = PacerPacingFactorDefault
+	}
+	if p.initialBitrate == 0 {
+		p.initialBitrate = PacerInitialBitrateDefault
+	}
+	if p.maxQueueDelay == 0 {
+		p.maxQueueDelay = PacerMaxQueueDelayDefault
+	}
+	p.pacingRate = p.initialBitrate
+	p.budget = p.burstSize
+	for i := range p.queues {
+		p.queues[i].SetMinCapacity(4)
+	}
+	return p
+}
+
+func (p *Pacer) Start() {
+	go p.run()
+}
+
+// Stop drops queued packets, packets enqueued after are rejected
+func (p *Pacer) Stop() {
+	p.lock.Lock()
+	if p.isStopped {
+		p.lock.Unlock()
+		return
+	}
+	p.isStopped = true
+	for i := range p.queues {
+		p.queues[i].Clear()
+	}
+	p.queuedBytes = 0
+	p.lock.Unlock()
+
+	close(p.closed)
+}
+
+// OnQueueBuildup is called from the pacer goroutine when video has been waiting longer than the max queue delay,
+// at most once per max queue delay
+func (p *Pacer) OnQueueBuildup(f func(queueDelay time.Duration)) {
+	p.onQueueBuildup = f
+}
+
+// OnPacketSent is called from the pacer goroutine after a packet is written, with the time it was queued for
+func (p *Pacer) OnPacketSent(f func(priority PacerPriority, delay time.Duration)) {
+	p.onPacketSent = f
+}
+
+// OnQueueDepth is called from the pacer goroutine with the number of packets left queued after each send, and 0 once stopped
+func (p *Pacer) OnQueueDepth(f func(depth int)) {
+	p.onQueueDepth = f
+}
+
+// SetChannelCapacity paces at the pacing factor over the estimated channel capacity in bps,
+// 0 or infinity, i.e. not estimated, paces at the initial bitrate
+func (p *Pacer) SetChannelCapacity(channelCapacity int64) {
+	pacingRate := p.initialBitrate
+	if channelCapacity > 0 && channelCapacity < ChannelCapacityInfinity {
+		pacingRate = int64(float64(channelCapacity) * p.pacingFactor)
+	}
+
+	p.lock.Lock()
+	p.pacingRate = pacingRate
+	p.lock.Unlock()
+}
+
+func (p *Pacer) PacingRate() int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.pacingRate
+}
+
+// Enqueue queues a packet of size bytes, write is called from the pacer goroutine when it is its turn.
+// Returns io.ErrClosedPipe once stopped, as writes on a closed peer connection do
+func (p *Pacer) Enqueue(priority PacerPriority, size int, write func()) error {
+	p.lock.Lock()
+	if p.isStopped {
+		p.lock.Unlock()
+		return io.ErrClosedPipe
+	}
+	if p.queuedBytes+size > PacerMaxQueuedBytes && priority > PacerPriorityAudio {
+		p.lock.Unlock()
+		return ErrPacerQueueFull
+	}
+
+	p.queues[priority].PushBack(&pacerPacket{
+		size:     size,
+		queuedAt: time.Now(),
+		write:    write,
+	})
+	p.queuedBytes += size
+	p.lock.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (p *Pacer) QueueDepth() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.queueDepthLocked()
+}
+
+func (p *Pacer) DebugInfo() map[string]interface{} {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	depth := map[string]interface{}{}
+	for priority := PacerPriority(0); priority < numPacerPriorities; priority++ {
+		depth[priority.String()] = p.queues[priority].Len()
+	}
+
+	return map[string]interface{}{
+		"PacingRate":  p.pacingRate,
+		"Budget":      p.budget,
+		"QueuedBytes": p.queuedBytes,
+		"QueueDepth":  depth,
+		"QueueDelay":  p.videoQueueDelayLocked(time.Now()).String(),
+		"LastBuildup": p.lastBuildup,
+	}
+}
+
+func (p *Pacer) run() {
+	timer := time.NewTimer(p.interval)
+	if !timer.Stop() {
+		<-timer.C
+	}
+
+	for {
+		if p.QueueDepth() == 0 {
+			select {
+			case <-p.wake:
+			case <-p.closed:
+				p.reportQueueDepth(0)
+				return
+			}
+		} else {
+			timer.Reset(p.interval)
+			select {
+			case <-timer.C:
+			case <-p.closed:
+				timer.Stop()
+				p.reportQueueDepth(0)
+				return
+			}
+		}
+
+		p.process(time.Now())
+	}
+}
+
+// process sends what the budget allows, highest priority first
+func (p *Pacer) process(now time.Time) {
+	p.lock.Lock()
+	p.refillLocked(now)
+
+	var buildup time.Duration
+	if queueDelay := p.videoQueueDelayLocked(now); queueDelay > p.maxQueueDelay && now.Sub(p.lastBuildup) > p.maxQueueDelay {
+		p.lastBuildup = now
+		buildup = queueDelay
+	}
+
+	for !p.isStopped {
+		priority, pkt := p.popLocked()
+		if pkt == nil {
+			break
+		}
+		p.budget -= pkt.size
+		p.queuedBytes -= pkt.size
+		p.lock.Unlock()
+
+		pkt.write()
+		if p.onPacketSent != nil {
+			p.onPacketSent(priority, now.Sub(pkt.queuedAt))
+		}
+
+		p.lock.Lock()
+	}
+	depth := p.queueDepthLocked()
+	p.lock.Unlock()
+
+	p.reportQueueDepth(depth)
+
+	if buildup != 0 {
+		p.params.Logger.Debugw("pacer queue building up", "queueDelay", buildup, "pacingRate", p.PacingRate())
+		if p.onQueueBuildup != nil {
+			p.onQueueBuildup(buildup)
+		}
+	}
+}
+
+func (p *Pacer) reportQueueDepth(depth int) {
+	if p.onQueueDepth != nil {
+		p.onQueueDepth(depth)
+	}
+}
+
+func (p *Pacer) refillLocked(now time.Time) {
+	if !p.lastRefill.IsZero() {
+		p.budget += int(float64(p.pacingRate) * now.Sub(p.lastRefill).Seconds() / 8)
+		if p.budget > p.burstSize {
+			p.budget = p.burstSize
+		}
+	}
+	p.lastRefill = now
+}
+
+// popLocked returns the next packet to send, RTCP and audio go out regardless of the budget
+func (p *Pacer) popLocked() (PacerPriority, *pacerPacket) {
+	for priority := PacerPriority(0); priority < numPacerPriorities; priority++ {
+		if p.queues[priority].Len() == 0 {
+			continue
+		}
+		if priority > PacerPriorityAudio && p.budget <= 0 {
+			return priority, nil
+		}
+
+		return priority, p.queues[priority].PopFront().(*pacerPacket)
+	}
+
+	return numPacerPriorities, nil
+}
+
+func (p *Pacer) queueDepthLocked() int {
+	depth := 0
+	for i := range p.queues {
+		depth += p.queues[i].Len()
+	}
+	return depth
+}
+
+// videoQueueDelayLocked returns how long the oldest video packet has been waiting
+func (p *Pacer) videoQueueDelayLocked(now time.Time) time.Duration {
+	if p.queues[PacerPriorityVideo].Len() == 0 {
+		return 0
+	}
+
+	return now.Sub(p.queues[PacerPriorityVideo].Front().(*pacerPacket).queuedAt)
+}
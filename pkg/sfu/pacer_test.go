@@ -0,0 +1,135 @@
+package sfu
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func newPacerForTest() *Pacer {
+	return NewPacer(PacerParams{
+		Config: config.PacerConfig{
+			Enabled:       true,
+			Interval:      config.Duration(5 * time.Millisecond),
+			BurstSize:     15000,
+			MaxQueueDelay: config.Duration(100 * time.Millisecond),
+		},
+		Logger: logger.Logger(logger.GetLogger()),
+	})
+}
+
+func TestPacerKeyFrame(t *testing.T) {
+	const (
+		keyFrameSize = 300 * 1000
+		packetSize   = 1200
+	)
+
+	p := newPacerForTest()
+	// paced at 5 Mbps, 3125 bytes per interval
+	p.SetChannelCapacity(2 * 1000 * 1000)
+	require.Equal(t, int64(5*1000*1000), p.PacingRate())
+
+	sent := 0
+	for i := 0; i < keyFrameSize/packetSize; i++ {
+		require.NoError(t, p.Enqueue(PacerPriorityVideo, packetSize, func() { sent++ }))
+	}
+
+	var sendsPerInterval []int
+	now := time.Now()
+	for p.QueueDepth() > 0 {
+		before := sent
+		p.process(now)
+		sendsPerInterval = append(sendsPerInterval, sent-before)
+		now = now.Add(5 * time.Millisecond)
+	}
+	require.Equal(t, keyFrameSize/packetSize, sent)
+
+	// the burst goes out right away, the rest of the key frame over ~450ms at the pacing rate
+	require.LessOrEqual(t, sendsPerInterval[0], 15000/packetSize+1)
+	require.GreaterOrEqual(t, len(sendsPerInterval), 85)
+	require.LessOrEqual(t, len(sendsPerInterval), 100)
+	for _, sends := range sendsPerInterval[1:] {
+		require.LessOrEqual(t, sends, 3125/packetSize+1)
+	}
+}
+
+func TestPacerPriority(t *testing.T) {
+	p := newPacerForTest()
+	p.SetChannelCapacity(1000 * 1000)
+
+	var order []PacerPriority
+	enqueue := func(priority PacerPriority, size int) {
+		require.NoError(t, p.Enqueue(priority, size, func() { order = append(order, priority) }))
+	}
+
+	// uses up the burst
+	enqueue(PacerPriorityVideo, 15000)
+	now := time.Now()
+	p.process(now)
+	require.Equal(t, []PacerPriority{PacerPriorityVideo}, order)
+
+	order = nil
+	enqueue(PacerPriorityPadding, 100)
+	enqueue(PacerPriorityVideo, 1000)
+	enqueue(PacerPriorityRetransmission, 1000)
+	enqueue(PacerPriorityAudio, 100)
+	enqueue(PacerPriorityRTCP, 100)
+
+	// no budget left, RTCP and audio are not held back
+	p.process(now)
+	require.Equal(t, []PacerPriority{PacerPriorityRTCP, PacerPriorityAudio}, order)
+
+	order = nil
+	p.process(now.Add(200 * time.Millisecond))
+	require.Equal(t, []PacerPriority{PacerPriorityRetransmission, PacerPriorityVideo, PacerPriorityPadding}, order)
+}
+
+func TestPacerQueueBuildup(t *testing.T) {
+	p := newPacerForTest()
+	p.SetChannelCapacity(100 * 1000)
+
+	var buildups []time.Duration
+	p.OnQueueBuildup(func(queueDelay time.Duration) {
+		buildups = append(buildups, queueDelay)
+	})
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, p.Enqueue(PacerPriorityVideo, 1200, func() {}))
+	}
+
+	p.process(start.Add(50 * time.Millisecond))
+	require.Empty(t, buildups)
+
+	p.process(start.Add(150 * time.Millisecond))
+	require.Len(t, buildups, 1)
+	require.GreaterOrEqual(t, buildups[0], 100*time.Millisecond)
+
+	// signalled at most once per max queue delay
+	p.process(start.Add(200 * time.Millisecond))
+	require.Len(t, buildups, 1)
+
+	p.process(start.Add(300 * time.Millisecond))
+	require.Len(t, buildups, 2)
+}
+
+func TestPacerStop(t *testing.T) {
+	p := newPacerForTest()
+	p.Start()
+
+	written := make(chan struct{}, 1)
+	require.NoError(t, p.Enqueue(PacerPriorityAudio, 100, func() { written <- struct{}{} }))
+	select {
+	case <-written:
+	case <-time.After(time.Second):
+		t.Fatal("packet not written")
+	}
+
+	p.Stop()
+	require.Equal(t, io.ErrClosedPipe, p.Enqueue(PacerPriorityAudio, 100, func() {}))
+}
@@ -79,6 +79,10 @@
 // and SFU will be receiving the media packets with a good spread and
 // not clumped together.
 //
+// NOTE: With rtc.pacer enabled, subscriber peer connections do have a
+// pacer (see pacer.go) and probe padding goes through it at the lowest
+// priority. The pacer only runs its loop while packets are queued.
+//
 // Given those assumptions, this module monitors media send rate and
 // adjusts probing packet sends accordingly. Although the probing may
 // have a high enough wake up frequency, it is for short windows.
@@ -118,10 +122,13 @@ import (
 
 type ProberParams struct {
 	Logger logger.Logger
+	// upper bound of the probe (padding) rate of clusters, 0 for none
+	MaxProbeRateBps int
 }
 
 type Prober struct {
-	logger logger.Logger
+	logger          logger.Logger
+	maxProbeRateBps int
 
 	clusterId atomic.Uint32
 
@@ -135,7 +142,8 @@ type Prober struct {
 
 func NewProber(params ProberParams) *Prober {
 	p := &Prober{
-		logger: params.Logger,
+		logger:          params.Logger,
+		maxProbeRateBps: params.MaxProbeRateBps,
 	}
 	p.clusters.SetMinCapacity(2)
 	return p
@@ -180,7 +188,7 @@ func (p *Prober) AddCluster(desiredRateBps int, expectedRateBps int, minDuration
 	}
 
 	clusterId := ProbeClusterId(p.clusterId.Inc())
-	cluster := NewCluster(clusterId, desiredRateBps, expectedRateBps, minDuration, maxDuration)
+	cluster := NewCluster(clusterId, desiredRateBps, expectedRateBps, p.maxProbeRateBps, minDuration, maxDuration)
 	p.logger.Debugw("cluster added", "cluster", cluster.String())
 
 	p.pushBackClusterAndMaybeStart(cluster)
@@ -306,10 +314,11 @@ type Cluster struct {
 	// LK-TODO-END
 	lock sync.RWMutex
 
-	id           ProbeClusterId
-	desiredBytes int
-	minDuration  time.Duration
-	maxDuration  time.Duration
+	id            ProbeClusterId
+	desiredBytes  int
+	maxProbeBytes int
+	minDuration   time.Duration
+	maxDuration   time.Duration
 
 	sleepDuration time.Duration
 
@@ -318,10 +327,17 @@ type Cluster struct {
 	startTime         time.Time
 }
 
-func NewCluster(id ProbeClusterId, desiredRateBps int, expectedRateBps int, minDuration time.Duration, maxDuration time.Duration) *Cluster {
+// NewCluster creates a cluster that brings the send rate up to desiredRateBps, expecting media to provide expectedRateBps.
+// With a non-zero maxProbeRateBps, probes are sent at no more than that rate, even if media falls short
+func NewCluster(id ProbeClusterId, desiredRateBps int, expectedRateBps int, maxProbeRateBps int, minDuration time.Duration, maxDuration time.Duration) *Cluster {
+	if maxProbeRateBps > 0 && desiredRateBps-expectedRateBps > maxProbeRateBps {
+		desiredRateBps = expectedRateBps + maxProbeRateBps
+	}
+
 	minDurationMs := minDuration.Milliseconds()
 	desiredBytes := int((int64(desiredRateBps)*minDurationMs/time.Second.Milliseconds() + 7) / 8)
 	expectedBytes := int((int64(expectedRateBps)*minDurationMs/time.Second.Milliseconds() + 7) / 8)
+	maxProbeBytes := int((int64(maxProbeRateBps)*minDurationMs/time.Second.Milliseconds() + 7) / 8)
 
 	// pace based on sending approximately 1000 bytes per probe
 	numProbes := (desiredBytes - expectedBytes + 999) / 1000
@@ -329,6 +345,7 @@ func NewCluster(id ProbeClusterId, desiredRateBps int, expectedRateBps int, minD
 	c := &Cluster{
 		id:            id,
 		desiredBytes:  desiredBytes,
+		maxProbeBytes: maxProbeBytes,
 		minDuration:   minDuration,
 		maxDuration:   maxDuration,
 		sleepDuration: time.Duration(sleepDurationMicroSeconds) * time.Microsecond,
@@ -417,6 +434,16 @@ func (c *Cluster) Process(onSendProbe func(bytesToSend int)) {
 	if bytesShortFall < 0 {
 		bytesShortFall = 0
 	}
+	// media falling short is not made up beyond the probe rate bound
+	if c.maxProbeBytes > 0 {
+		probeBytesAllowed := int(windowDone*float64(c.maxProbeBytes)) - c.bytesSentProbe
+		if bytesShortFall > probeBytesAllowed {
+			bytesShortFall = probeBytesAllowed
+		}
+		if bytesShortFall < 0 {
+			bytesShortFall = 0
+		}
+	}
 	// cap short fall to limit to 8 packets in an iteration
 	// 275 bytes per packet (255 max RTP padding payload + 20 bytes RTP header)
 	if bytesShortFall > (275 * 8) {
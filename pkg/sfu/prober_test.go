@@ -0,0 +1,58 @@
+package sfu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestProberMaxProbeRate(t *testing.T) {
+	const (
+		desiredRateBps  = 3_000_000
+		expectedRateBps = 1_000_000
+		duration        = 500 * time.Millisecond
+	)
+
+	// runs a cluster where media sends nothing of the expected rate, returns the padding rate generated
+	probeRate := func(t *testing.T, maxProbeRateBps int) int {
+		p := NewProber(ProberParams{
+			Logger:          logger.Logger(logger.GetLogger()),
+			MaxProbeRateBps: maxProbeRateBps,
+		})
+
+		var probeBytes atomic.Int64
+		done := make(chan ProbeClusterInfo, 1)
+		p.OnSendProbe(func(bytesToSend int) {
+			probeBytes.Add(int64(bytesToSend))
+			p.ProbeSent(bytesToSend)
+		})
+		p.OnProbeClusterDone(func(info ProbeClusterInfo) {
+			done <- info
+		})
+
+		start := time.Now()
+		require.NotEqual(t, ProbeClusterIdInvalid, p.AddCluster(desiredRateBps, expectedRateBps, duration, duration+100*time.Millisecond))
+
+		select {
+		case <-done:
+		case <-time.After(5 * duration):
+			t.Fatal("probe cluster did not finish")
+		}
+		elapsed := time.Since(start)
+		return int(float64(probeBytes.Load()*8) / elapsed.Seconds())
+	}
+
+	unbounded := probeRate(t, 0)
+	// makes up for the missing media, up to the desired rate
+	require.Greater(t, unbounded, 2_000_000)
+
+	for _, maxProbeRateBps := range []int{800_000, 200_000} {
+		rate := probeRate(t, maxProbeRateBps)
+		// allow for the last burst of up to 8 padding packets
+		require.LessOrEqual(t, rate, maxProbeRateBps+int(float64(8*275*8)/duration.Seconds()), "max probe rate %d", maxProbeRateBps)
+		require.Greater(t, rate, maxProbeRateBps/2, "max probe rate %d", maxProbeRateBps)
+	}
+}
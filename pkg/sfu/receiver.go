@@ -23,10 +23,24 @@ import (
 var (
 	ErrReceiverClosed        = errors.New("receiver closed")
 	ErrDownTrackAlreadyExist = errors.New("DownTrack already exist")
+	ErrInvalidLayer          = errors.New("invalid layer")
 )
 
 type AudioLevelHandle func(level uint8, duration uint32)
-type Bitrates [DefaultMaxLayerSpatial + 1][DefaultMaxLayerTemporal + 1]int64
+
+// Bitrates are indexed by spatial and temporal layer, layers beyond its size have no bitrate
+type Bitrates [][DefaultMaxLayerTemporal + 1]int64
+
+func NewBitrates(numSpatialLayers int32) Bitrates {
+	return make(Bitrates, numSpatialLayers)
+}
+
+func (b Bitrates) get(spatial int32, temporal int32) int64 {
+	if spatial < 0 || int(spatial) >= len(b) || temporal < 0 || temporal > DefaultMaxLayerTemporal {
+		return 0
+	}
+	return b[spatial][temporal]
+}
 
 // TrackReceiver defines an interface receive media from remote peer
 type TrackReceiver interface {
@@ -37,8 +51,11 @@ type TrackReceiver interface {
 	ReadRTP(buf []byte, layer uint8, sn uint16) (int, error)
 	GetSenderReportTime(layer int32) (rtpTS uint32, ntpTS uint64)
 	GetBitrateTemporalCumulative() Bitrates
+	// highest spatial layer that can be published
+	MaxSpatialLayer() int32
 
 	SendPLI(layer int32)
+	GetCachedKeyFrame(layer int32) []*buffer.ExtPacket
 
 	SetUpTrackPaused(paused bool)
 	SetMaxExpectedSpatialLayer(layer int32)
@@ -65,16 +82,24 @@ type WebRTCReceiver struct {
 	onCloseHandler func()
 	closeOnce      sync.Once
 	closed         atomic.Bool
-	useTrackers    bool
+
+	useTrackers         bool
+	streamTrackerConfig config.StreamTrackerConfig
 
 	rtcpCh chan []rtcp.Packet
 
+	// layer indexed slices below are sized by it
+	numSpatialLayers int32
+	onInvalidLayer   func(layer int32)
+
+	onStreamIssue func(layer int32, issue StreamIssue)
+
 	bufferMu sync.RWMutex
-	buffers  [DefaultMaxLayerSpatial + 1]*buffer.Buffer
+	buffers  []*buffer.Buffer
 	rtt      uint32
 
 	upTrackMu sync.RWMutex
-	upTracks  [DefaultMaxLayerSpatial + 1]*webrtc.TrackRemote
+	upTracks  []*webrtc.TrackRemote
 
 	downTrackMu sync.RWMutex
 	downTracks  []TrackSender
@@ -85,6 +110,14 @@ type WebRTCReceiver struct {
 
 	streamTrackerManager *StreamTrackerManager
 
+	// 0 when caching of key frames is disabled
+	keyFrameCacheSize int
+	keyFrameCacheMu   sync.RWMutex
+	keyFrameCaches    []*KeyFrameCache
+
+	// set for RED tracks
+	redUnwrapper *REDUnwrapper
+
 	connectionStats *connectionquality.ConnectionStats
 
 	// update stats
@@ -104,7 +137,8 @@ func RidToLayer(rid string) int32 {
 
 type ReceiverOpts func(w *WebRTCReceiver) *WebRTCReceiver
 
-// WithPliThrottle indicates minimum time(ms) between sending PLIs
+// WithPliThrottle indicates minimum time(ms) between sending PLIs.
+// Per source overrides are expected to be resolved already, see config.PLIThrottleConfig.WithOverride
 func WithPliThrottle(pliThrottleConfig config.PLIThrottleConfig) ReceiverOpts {
 	return func(w *WebRTCReceiver) *WebRTCReceiver {
 		w.pliThrottleConfig = pliThrottleConfig
@@ -112,10 +146,12 @@ func WithPliThrottle(pliThrottleConfig config.PLIThrottleConfig) ReceiverOpts {
 	}
 }
 
-// WithStreamTrackers enables StreamTracker use for simulcast
-func WithStreamTrackers() ReceiverOpts {
+// WithStreamTrackers enables StreamTracker use for simulcast.
+// Screen share overrides are expected to be resolved already, see config.StreamTrackerConfig.WithOverride
+func WithStreamTrackers(streamTrackerConfig config.StreamTrackerConfig) ReceiverOpts {
 	return func(w *WebRTCReceiver) *WebRTCReceiver {
 		w.useTrackers = true
+		w.streamTrackerConfig = streamTrackerConfig
 		return w
 	}
 }
@@ -132,6 +168,27 @@ func WithLoadBalanceThreshold(downTracks int) ReceiverOpts {
 	}
 }
 
+// WithKeyFrameCache keeps the most recent key frame of each layer, up to maxFrameSize bytes,
+// to start new subscribers without waiting for the next key frame from the publisher.
+// Only used for codecs whose key frames can be validated, see IsKeyFrameCacheSupported
+func WithKeyFrameCache(maxFrameSize int) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		w.keyFrameCacheSize = maxFrameSize
+		return w
+	}
+}
+
+// WithMaxSpatialLayers sets the number of spatial layers that can be published,
+// packets of higher layers are dropped. Defaults to DefaultMaxLayerSpatial + 1
+func WithMaxSpatialLayers(numSpatialLayers int32) ReceiverOpts {
+	return func(w *WebRTCReceiver) *WebRTCReceiver {
+		if numSpatialLayers > 0 {
+			w.numSpatialLayers = numSpatialLayers
+		}
+		return w
+	}
+}
+
 // NewWebRTCReceiver creates a new webrtc track receiver
 func NewWebRTCReceiver(
 	receiver *webrtc.RTPReceiver,
@@ -149,14 +206,17 @@ func NewWebRTCReceiver(
 		codec:    track.Codec(),
 		kind:     track.Kind(),
 		// LK-TODO: this should be based on VideoLayers protocol message rather than RID based
-		isSimulcast:          len(track.RID()) > 0,
-		downTracks:           make([]TrackSender, 0),
-		index:                make(map[livekit.ParticipantID]int),
-		free:                 make(map[int]struct{}),
-		numProcs:             runtime.NumCPU(),
-		streamTrackerManager: NewStreamTrackerManager(logger),
+		isSimulcast: len(track.RID()) > 0,
+		downTracks:  make([]TrackSender, 0),
+		index:       make(map[livekit.ParticipantID]int),
+		free:        make(map[int]struct{}),
+		numProcs:    runtime.NumCPU(),
+
+		numSpatialLayers: DefaultMaxLayerSpatial + 1,
+	}
+	if IsREDCodec(w.codec.MimeType) {
+		w.redUnwrapper = NewREDUnwrapper()
 	}
-	w.streamTrackerManager.OnAvailableLayersChanged(w.downTrackLayerChange)
 
 	if runtime.GOMAXPROCS(0) < w.numProcs {
 		w.numProcs = runtime.GOMAXPROCS(0)
@@ -166,10 +226,24 @@ func NewWebRTCReceiver(
 		w = opt(w)
 	}
 
+	w.buffers = make([]*buffer.Buffer, w.numSpatialLayers)
+	w.upTracks = make([]*webrtc.TrackRemote, w.numSpatialLayers)
+	w.keyFrameCaches = make([]*KeyFrameCache, w.numSpatialLayers)
+
+	streamTrackerConfig := w.streamTrackerConfig
+	if w.kind != webrtc.RTPCodecTypeVideo {
+		// there are no key frames to wait for
+		streamTrackerConfig.FrozenTimeout = 0
+	}
+	w.streamTrackerManager = NewStreamTrackerManager(logger, w.numSpatialLayers, streamTrackerConfig)
+	w.streamTrackerManager.OnAvailableLayersChanged(w.downTrackLayerChange)
+	w.streamTrackerManager.OnMaxTemporalLayerChanged(w.maxTemporalLayerChange)
+	w.streamTrackerManager.OnStreamIssue(w.streamIssue)
+
 	w.connectionStats = connectionquality.NewConnectionStats(connectionquality.ConnectionStatsParams{
 		CodecType:     w.kind,
 		ClockRate:     w.codec.ClockRate,
-		GetTrackStats: w.getTrackStats,
+		GetTrackStats: w.GetTrackStats,
 		GetIsReducedQuality: func() bool {
 			return w.streamTrackerManager.IsReducedQuality()
 		},
@@ -189,6 +263,21 @@ func (w *WebRTCReceiver) OnStatsUpdate(fn func(w *WebRTCReceiver, stat *livekit.
 	w.onStatsUpdate = fn
 }
 
+// OnLayerFlap is called when a published layer that was available stops and resumes
+func (w *WebRTCReceiver) OnLayerFlap(fn func(layer int32, flap LayerFlap)) {
+	w.streamTrackerManager.OnLayerFlap(fn)
+}
+
+// GetLayerBitrates returns the measured bitrate of each spatial layer in bits per second
+func (w *WebRTCReceiver) GetLayerBitrates() []uint64 {
+	return w.streamTrackerManager.GetLayerBitrates()
+}
+
+// GetAvailableSpatialLayers returns the published spatial layers that are currently flowing
+func (w *WebRTCReceiver) GetAvailableSpatialLayers() []int32 {
+	return w.streamTrackerManager.GetAvailableSpatialLayers()
+}
+
 func (w *WebRTCReceiver) GetConnectionScore() float32 {
 	return w.connectionStats.GetScore()
 }
@@ -227,6 +316,10 @@ func (w *WebRTCReceiver) TrackID() livekit.TrackID {
 }
 
 func (w *WebRTCReceiver) SSRC(layer int) uint32 {
+	if !w.isValidLayer(int32(layer)) {
+		return 0
+	}
+
 	w.upTrackMu.RLock()
 	defer w.upTrackMu.RUnlock()
 
@@ -244,27 +337,42 @@ func (w *WebRTCReceiver) Kind() webrtc.RTPCodecType {
 	return w.kind
 }
 
+func (w *WebRTCReceiver) MaxSpatialLayer() int32 {
+	return w.numSpatialLayers - 1
+}
+
+// OnInvalidLayer is called when media of a spatial layer beyond the configured number of layers is dropped
+func (w *WebRTCReceiver) OnInvalidLayer(fn func(layer int32)) {
+	w.onInvalidLayer = fn
+}
+
+func (w *WebRTCReceiver) isValidLayer(layer int32) bool {
+	return layer >= 0 && layer < w.numSpatialLayers
+}
+
+func (w *WebRTCReceiver) invalidLayer(layer int32) {
+	if w.onInvalidLayer != nil {
+		w.onInvalidLayer(layer)
+	}
+}
+
 func (w *WebRTCReceiver) AddUpTrack(track *webrtc.TrackRemote, buff *buffer.Buffer) {
 	if w.closed.Load() {
 		return
 	}
 
 	layer := RidToLayer(track.RID())
+	if !w.isValidLayer(layer) {
+		w.logger.Warnw("dropping up track of unsupported layer", nil, "layer", layer, "rid", track.RID())
+		w.invalidLayer(layer)
+		return
+	}
+
 	buff.SetLogger(logger.Logger(logr.Logger(w.logger).WithValues("layer", layer)))
 	buff.OnFeedback(w.sendRTCP)
 
-	var duration time.Duration
-	switch track.RID() {
-	case FullResolution:
-		duration = w.pliThrottleConfig.HighQuality
-	case HalfResolution:
-		duration = w.pliThrottleConfig.MidQuality
-	case QuarterResolution:
-		duration = w.pliThrottleConfig.LowQuality
-	default:
-		duration = w.pliThrottleConfig.MidQuality
-	}
-	if duration != 0 {
+	// throttle is kept per layer in the buffer, so key frame requests from all subscribers of a layer share it
+	if duration := w.pliThrottle(track.RID()); duration != 0 {
 		buff.SetPLIThrottle(duration.Nanoseconds())
 	}
 
@@ -281,6 +389,12 @@ func (w *WebRTCReceiver) AddUpTrack(track *webrtc.TrackRemote, buff *buffer.Buff
 	if w.Kind() == webrtc.RTPCodecTypeVideo && w.useTrackers {
 		w.streamTrackerManager.AddTracker(layer)
 	}
+
+	if w.keyFrameCacheSize > 0 && IsKeyFrameCacheSupported(w.codec.MimeType) {
+		w.keyFrameCacheMu.Lock()
+		w.keyFrameCaches[layer] = NewKeyFrameCache(w.codec.MimeType, w.keyFrameCacheSize)
+		w.keyFrameCacheMu.Unlock()
+	}
 	go w.forwardRTP(layer)
 }
 
@@ -305,7 +419,7 @@ func (w *WebRTCReceiver) AddDownTrack(track TrackSender) error {
 
 	if w.Kind() == webrtc.RTPCodecTypeVideo {
 		// notify added down track of available layers
-		layers := w.streamTrackerManager.GetAvailableLayers()
+		layers := w.streamTrackerManager.GetAvailableSpatialLayers()
 		if len(layers) != 0 {
 			track.UpTrackLayersChange(layers)
 		}
@@ -331,9 +445,33 @@ func (w *WebRTCReceiver) downTrackLayerChange(layers []int32) {
 	}
 }
 
+// OnStreamIssue is called when a published layer keeps flowing but cannot be rendered.
+// A key frame has already been requested from the publisher when it is called
+func (w *WebRTCReceiver) OnStreamIssue(fn func(layer int32, issue StreamIssue)) {
+	w.onStreamIssue = fn
+}
+
+func (w *WebRTCReceiver) streamIssue(layer int32, issue StreamIssue) {
+	w.logger.Infow("stream issue", "layer", layer, "reason", issue.Reason, "persistent", issue.Persistent)
+
+	// throttled by the buffer like subscriber requests
+	w.SendPLI(layer)
+
+	if w.onStreamIssue != nil {
+		w.onStreamIssue(layer, issue)
+	}
+}
+
+func (w *WebRTCReceiver) maxTemporalLayerChange(layer int32, maxTemporalLayer int32) {
+	w.logger.Debugw("max temporal layer changed", "layer", layer, "maxTemporalLayer", maxTemporalLayer)
+
+	// bitrates of layers no longer produced drop out, let down tracks re-allocate
+	w.downTrackLayerChange(w.streamTrackerManager.GetAvailableSpatialLayers())
+}
+
 func (w *WebRTCReceiver) GetBitrateTemporalCumulative() Bitrates {
 	// LK-TODO: For SVC tracks, need to accumulate across spatial layers also
-	var br Bitrates
+	br := NewBitrates(w.numSpatialLayers)
 	w.bufferMu.RLock()
 	defer w.bufferMu.RUnlock()
 	for i, buff := range w.buffers {
@@ -343,7 +481,12 @@ func (w *WebRTCReceiver) GetBitrateTemporalCumulative() Bitrates {
 				tls = buff.BitrateTemporalCumulative()
 			}
 
+			// a temporal layer that stopped still shows its decaying bitrate for a while
+			maxTemporalLayer := w.streamTrackerManager.GetMaxTemporalLayer(int32(i))
 			for j := 0; j < len(br[i]); j++ {
+				if int32(j) > maxTemporalLayer {
+					break
+				}
 				br[i][j] = tls[j]
 			}
 		}
@@ -391,7 +534,24 @@ func (w *WebRTCReceiver) sendRTCP(packets []rtcp.Packet) {
 	}
 }
 
+func (w *WebRTCReceiver) pliThrottle(rid string) time.Duration {
+	switch rid {
+	case FullResolution:
+		return w.pliThrottleConfig.HighQuality.Duration()
+	case HalfResolution:
+		return w.pliThrottleConfig.MidQuality.Duration()
+	case QuarterResolution:
+		return w.pliThrottleConfig.LowQuality.Duration()
+	default:
+		return w.pliThrottleConfig.MidQuality.Duration()
+	}
+}
+
 func (w *WebRTCReceiver) SendPLI(layer int32) {
+	if !w.isValidLayer(layer) {
+		return
+	}
+
 	w.bufferMu.RLock()
 	buff := w.buffers[layer]
 	w.bufferMu.RUnlock()
@@ -402,11 +562,32 @@ func (w *WebRTCReceiver) SendPLI(layer int32) {
 	buff.SendPLI()
 }
 
+// GetCachedKeyFrame returns the packets of the most recent complete key frame of a layer,
+// nil if caching is disabled or no key frame has been seen yet
+func (w *WebRTCReceiver) GetCachedKeyFrame(layer int32) []*buffer.ExtPacket {
+	if !w.isValidLayer(layer) {
+		return nil
+	}
+
+	w.keyFrameCacheMu.RLock()
+	cache := w.keyFrameCaches[layer]
+	w.keyFrameCacheMu.RUnlock()
+	if cache == nil {
+		return nil
+	}
+
+	return cache.Get()
+}
+
 func (w *WebRTCReceiver) SetRTCPCh(ch chan []rtcp.Packet) {
 	w.rtcpCh = ch
 }
 
 func (w *WebRTCReceiver) GetSenderReportTime(layer int32) (rtpTS uint32, ntpTS uint64) {
+	if !w.isValidLayer(layer) {
+		return
+	}
+
 	w.bufferMu.RLock()
 	defer w.bufferMu.RUnlock()
 	if w.buffers[layer] != nil {
@@ -416,13 +597,21 @@ func (w *WebRTCReceiver) GetSenderReportTime(layer int32) (rtpTS uint32, ntpTS u
 }
 
 func (w *WebRTCReceiver) ReadRTP(buf []byte, layer uint8, sn uint16) (int, error) {
+	if !w.isValidLayer(int32(layer)) {
+		return 0, ErrInvalidLayer
+	}
+
 	w.bufferMu.RLock()
 	buff := w.buffers[layer]
 	w.bufferMu.RUnlock()
+	if buff == nil {
+		return 0, ErrInvalidLayer
+	}
 	return buff.GetPacket(buf, sn)
 }
 
-func (w *WebRTCReceiver) getTrackStats() map[uint32]*buffer.StreamStatsWithLayers {
+// GetTrackStats returns the cumulative stats of each published layer, by SSRC
+func (w *WebRTCReceiver) GetTrackStats() map[uint32]*buffer.StreamStatsWithLayers {
 	w.bufferMu.RLock()
 	defer w.bufferMu.RUnlock()
 
@@ -451,6 +640,10 @@ func (w *WebRTCReceiver) getTrackStats() map[uint32]*buffer.StreamStatsWithLayer
 func (w *WebRTCReceiver) forwardRTP(layer int32) {
 	tracker := w.streamTrackerManager.GetTracker(layer)
 
+	w.keyFrameCacheMu.RLock()
+	keyFrameCache := w.keyFrameCaches[layer]
+	w.keyFrameCacheMu.RUnlock()
+
 	defer func() {
 		w.closeOnce.Do(func() {
 			w.closed.Store(true)
@@ -469,8 +662,26 @@ func (w *WebRTCReceiver) forwardRTP(layer int32) {
 			return
 		}
 
-		if tracker != nil {
-			tracker.Observe(pkt.Packet.SequenceNumber)
+		if pkt.SpatialLayer >= w.numSpatialLayers {
+			// malformed or beyond what is supported
+			w.invalidLayer(pkt.SpatialLayer)
+			pkt.Release()
+			continue
+		}
+
+		// an SVC stream carries all spatial layers on one SSRC, the tracker follows its base layer
+		if tracker != nil && pkt.SpatialLayer == 0 {
+			tracker.Observe(pkt.Packet.SequenceNumber, pkt.TemporalLayer, len(pkt.Packet.Payload), pkt.KeyFrame)
+		}
+
+		if keyFrameCache != nil {
+			keyFrameCache.Add(pkt)
+		}
+
+		// unwrapped once for all subscribers that didn't negotiate RED
+		var primaryPkts []*buffer.ExtPacket
+		if w.redUnwrapper != nil {
+			primaryPkts = w.redUnwrapper.Unwrap(pkt)
 		}
 
 		w.downTrackMu.RLock()
@@ -481,7 +692,7 @@ func (w *WebRTCReceiver) forwardRTP(layer int32) {
 			// serial - not enough down tracks for parallelization to outweigh overhead
 			for _, dt := range downTracks {
 				if dt != nil {
-					w.writeRTP(layer, dt, pkt)
+					w.writeRTP(layer, dt, pkt, primaryPkts)
 				}
 			}
 		} else {
@@ -506,7 +717,7 @@ func (w *WebRTCReceiver) forwardRTP(layer int32) {
 
 						for i := n - step; i < n && i < end; i++ {
 							if dt := downTracks[i]; dt != nil {
-								w.writeRTP(layer, dt, pkt)
+								w.writeRTP(layer, dt, pkt, primaryPkts)
 							}
 						}
 					}
@@ -514,10 +725,22 @@ func (w *WebRTCReceiver) forwardRTP(layer int32) {
 			}
 			wg.Wait()
 		}
+
+		// down tracks are done with the packet, the key frame cache and the pacer keep copies
+		pkt.Release()
 	}
 }
 
-func (w *WebRTCReceiver) writeRTP(layer int32, dt TrackSender, pkt *buffer.ExtPacket) {
+func (w *WebRTCReceiver) writeRTP(layer int32, dt TrackSender, pkt *buffer.ExtPacket, primaryPkts []*buffer.ExtPacket) {
+	if w.redUnwrapper != nil && dt.ForwardsREDPrimary() {
+		for _, primaryPkt := range primaryPkts {
+			if err := dt.WriteRTP(primaryPkt, layer); err != nil {
+				log.Error().Err(err).Str("id", dt.ID()).Msg("Error writing to down track")
+			}
+		}
+		return
+	}
+
 	if err := dt.WriteRTP(pkt, layer); err != nil {
 		log.Error().Err(err).Str("id", dt.ID()).Msg("Error writing to down track")
 	}
@@ -538,6 +761,8 @@ func (w *WebRTCReceiver) closeTracks() {
 	w.free = make(map[int]struct{})
 	w.downTrackMu.Unlock()
 
+	w.streamTrackerManager.RemoveAllTrackers()
+
 	if w.onCloseHandler != nil {
 		w.onCloseHandler()
 	}
@@ -563,20 +788,24 @@ func (w *WebRTCReceiver) DebugInfo() map[string]interface{} {
 		"Simulcast": w.isSimulcast,
 	}
 
+	bitrates := w.streamTrackerManager.GetLayerBitrates()
 	w.upTrackMu.RLock()
 	upTrackInfo := make([]map[string]interface{}, 0, len(w.upTracks))
 	for layer, ut := range w.upTracks {
 		if ut != nil {
 			upTrackInfo = append(upTrackInfo, map[string]interface{}{
-				"Layer": layer,
-				"SSRC":  ut.SSRC(),
-				"Msid":  ut.Msid(),
-				"RID":   ut.RID(),
+				"Layer":            layer,
+				"SSRC":             ut.SSRC(),
+				"Msid":             ut.Msid(),
+				"RID":              ut.RID(),
+				"MaxTemporalLayer": w.streamTrackerManager.GetMaxTemporalLayer(int32(layer)),
+				"Bitrate":          bitrates[layer],
 			})
 		}
 	}
 	w.upTrackMu.RUnlock()
 	info["UpTracks"] = upTrackInfo
+	info["StreamTrackers"] = w.streamTrackerManager.DebugInfo()
 
 	return info
 }
@@ -7,10 +7,18 @@ import (
 	"runtime"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/gammazero/workerpool"
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/testutils"
 )
 
 func TestWebRTCReceiver_OnCloseHandler(t *testing.T) {
@@ -38,6 +46,109 @@ func TestWebRTCReceiver_OnCloseHandler(t *testing.T) {
 	}
 }
 
+func TestWebRTCReceiver_PLIThrottle(t *testing.T) {
+	pool := &sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, 1500)
+			return &b
+		},
+	}
+	codec := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+		PayloadType:        96,
+	}
+
+	var plis [DefaultMaxLayerSpatial + 1]atomic.Int32
+	w := &WebRTCReceiver{
+		numSpatialLayers: DefaultMaxLayerSpatial + 1,
+		buffers:          make([]*buffer.Buffer, DefaultMaxLayerSpatial+1),
+		pliThrottleConfig: config.PLIThrottleConfig{
+			LowQuality:  config.Duration(time.Minute),
+			HighQuality: config.Duration(time.Minute),
+		},
+	}
+	for layer, rid := range []string{QuarterResolution, HalfResolution, FullResolution} {
+		layer := layer
+		buff := buffer.NewBuffer(uint32(100+layer), pool, pool)
+		buff.OnFeedback(func(pkts []rtcp.Packet) {
+			for _, pkt := range pkts {
+				if _, ok := pkt.(*rtcp.PictureLossIndication); ok {
+					plis[layer].Inc()
+				}
+			}
+		})
+		buff.Bind(webrtc.RTPParameters{Codecs: []webrtc.RTPCodecParameters{codec}}, codec.RTPCodecCapability, buffer.Options{})
+		buff.SetPLIThrottle(w.pliThrottle(rid).Nanoseconds())
+		w.buffers[layer] = buff
+	}
+
+	// two subscribers requesting a key frame on the same layer within the throttle window
+	w.SendPLI(2)
+	w.SendPLI(2)
+	// throttle state is per layer
+	w.SendPLI(0)
+
+	testutils.WithTimeout(t, func() string {
+		if plis[2].Load() != 1 {
+			return fmt.Sprintf("expected 1 PLI on layer 2, got %d", plis[2].Load())
+		}
+		if plis[0].Load() != 1 {
+			return fmt.Sprintf("expected 1 PLI on layer 0, got %d", plis[0].Load())
+		}
+		return ""
+	})
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), plis[2].Load())
+	assert.Equal(t, int32(0), plis[1].Load())
+}
+
+func TestWebRTCReceiver_StreamIssue(t *testing.T) {
+	pool := &sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, 1500)
+			return &b
+		},
+	}
+	codec := webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+		PayloadType:        96,
+	}
+
+	w := &WebRTCReceiver{
+		logger:           logger.Logger(logger.GetLogger()),
+		numSpatialLayers: DefaultMaxLayerSpatial + 1,
+		buffers:          make([]*buffer.Buffer, DefaultMaxLayerSpatial+1),
+	}
+	var plis atomic.Int32
+	buff := buffer.NewBuffer(100, pool, pool)
+	buff.OnFeedback(func(pkts []rtcp.Packet) {
+		for _, pkt := range pkts {
+			if _, ok := pkt.(*rtcp.PictureLossIndication); ok {
+				plis.Inc()
+			}
+		}
+	})
+	buff.Bind(webrtc.RTPParameters{Codecs: []webrtc.RTPCodecParameters{codec}}, codec.RTPCodecCapability, buffer.Options{})
+	w.buffers[1] = buff
+
+	var reported []StreamIssue
+	w.OnStreamIssue(func(layer int32, issue StreamIssue) {
+		assert.Equal(t, int32(1), layer)
+		reported = append(reported, issue)
+	})
+
+	// a key frame is requested before listeners are notified
+	issue := StreamIssue{Reason: StreamIssueReasonNoKeyFrame}
+	w.streamIssue(1, issue)
+	assert.Equal(t, []StreamIssue{issue}, reported)
+	testutils.WithTimeout(t, func() string {
+		if plis.Load() != 1 {
+			return fmt.Sprintf("expected 1 PLI, got %d", plis.Load())
+		}
+		return ""
+	})
+}
+
 func BenchmarkWriteRTP(b *testing.B) {
 	cases := []int{1, 2, 5, 10, 100, 250, 500}
 	workers := runtime.NumCPU()
@@ -0,0 +1,199 @@
+package sfu
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/pion/rtp"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+const (
+	MimeTypeAudioRED = "audio/red"
+
+	// size of the history of forwarded sequence numbers kept by REDUnwrapper
+	redHistorySize = 64
+)
+
+var (
+	ErrInvalidREDPayload = errors.New("invalid RED payload")
+	ErrREDBufferTooSmall = errors.New("buffer too small for RED payload")
+)
+
+// REDBlock is one encoding carried in a RED payload
+type REDBlock struct {
+	PayloadType     uint8
+	TimestampOffset uint32
+	Payload         []byte
+}
+
+// ParseREDPayload splits a RED payload (RFC 2198) into its redundant blocks, oldest first, and the primary block.
+// The returned payloads point into the given one
+/*
+	Block header, F bit set on all but the last (primary) one
+
+	 0                   1                    2                   3
+	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+	|F|   block PT  |  timestamp offset         |   block length    |
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+	 0 1 2 3 4 5 6 7
+	+-+-+-+-+-+-+-+-+
+	|0|   Block PT  |
+	+-+-+-+-+-+-+-+-+
+*/
+func ParseREDPayload(payload []byte) (redundant []REDBlock, primary REDBlock, err error) {
+	var lengths []int
+	idx := 0
+	for {
+		if idx >= len(payload) {
+			return nil, REDBlock{}, ErrInvalidREDPayload
+		}
+		if payload[idx]&0x80 == 0 {
+			primary.PayloadType = payload[idx] & 0x7f
+			idx++
+			break
+		}
+		if idx+4 > len(payload) {
+			return nil, REDBlock{}, ErrInvalidREDPayload
+		}
+		redundant = append(redundant, REDBlock{
+			PayloadType:     payload[idx] & 0x7f,
+			TimestampOffset: uint32(payload[idx+1])<<6 | uint32(payload[idx+2])>>2,
+		})
+		lengths = append(lengths, int(payload[idx+2]&0x03)<<8|int(payload[idx+3]))
+		idx += 4
+	}
+
+	for i, length := range lengths {
+		if idx+length > len(payload) {
+			return nil, REDBlock{}, ErrInvalidREDPayload
+		}
+		redundant[i].Payload = payload[idx : idx+length]
+		idx += length
+	}
+	primary.Payload = payload[idx:]
+	return redundant, primary, nil
+}
+
+// RewriteREDPayloadType sets the payload type of every block in a RED payload. The payload is copied into buf
+// only when a block has a different payload type
+func RewriteREDPayloadType(payload []byte, payloadType uint8, buf []byte) ([]byte, error) {
+	var headers []int
+	rewrite := false
+	for idx := 0; ; idx += 4 {
+		if idx >= len(payload) {
+			return nil, ErrInvalidREDPayload
+		}
+		headers = append(headers, idx)
+		if payload[idx]&0x7f != payloadType {
+			rewrite = true
+		}
+		if payload[idx]&0x80 == 0 {
+			break
+		}
+	}
+	if !rewrite {
+		return payload, nil
+	}
+
+	if len(buf) < len(payload) {
+		return nil, ErrREDBufferTooSmall
+	}
+	out := buf[:len(payload)]
+	copy(out, payload)
+	for _, idx := range headers {
+		out[idx] = out[idx]&0x80 | payloadType
+	}
+	return out, nil
+}
+
+// REDUnwrapper turns a RED stream into its primary encoding, for subscribers that didn't negotiate RED.
+// Packets lost upstream are recovered from the redundant blocks of the packets that follow them. As the sender
+// protects the packets right before each one, a redundant block is mapped to a sequence number by its position,
+// the last one belonging to the previous packet
+type REDUnwrapper struct {
+	initialized bool
+	highestSN   uint16
+	// bit i is set when highestSN - i has been forwarded
+	forwarded uint64
+}
+
+func NewREDUnwrapper() *REDUnwrapper {
+	return &REDUnwrapper{}
+}
+
+func IsREDCodec(mimeType string) bool {
+	return strings.EqualFold(mimeType, MimeTypeAudioRED)
+}
+
+// Unwrap returns the primary encoding of a RED packet, preceded by packets recovered from its redundant blocks.
+// Not safe for concurrent use, it's driven by the goroutine forwarding the track
+func (r *REDUnwrapper) Unwrap(extPkt *buffer.ExtPacket) []*buffer.ExtPacket {
+	if len(extPkt.Packet.Payload) == 0 {
+		// padding only
+		return nil
+	}
+	redundant, primary, err := ParseREDPayload(extPkt.Packet.Payload)
+	if err != nil {
+		return nil
+	}
+
+	sn := extPkt.Packet.SequenceNumber
+	if !r.initialized {
+		// nothing before the first packet is considered lost
+		r.initialized = true
+		r.highestSN = sn - 1
+		r.forwarded = ^uint64(0)
+	}
+
+	diff := sn - r.highestSN
+	if diff == 0 || diff > 1<<15 {
+		// out-of-order, forward it unless it was recovered already
+		age := r.highestSN - sn
+		if age >= redHistorySize || r.forwarded&(1<<age) != 0 {
+			return nil
+		}
+		r.forwarded |= 1 << age
+		return []*buffer.ExtPacket{r.packet(extPkt, sn, extPkt.Packet.Timestamp, primary.Payload, extPkt.Packet.Marker, false)}
+	}
+
+	var pkts []*buffer.ExtPacket
+	for i, block := range redundant {
+		recoveredSN := sn - uint16(len(redundant)-i)
+		if d := recoveredSN - r.highestSN; d == 0 || d > 1<<15 {
+			// not in the gap
+			continue
+		}
+		pkts = append(pkts, r.packet(extPkt, recoveredSN, extPkt.Packet.Timestamp-block.TimestampOffset, block.Payload, false, true))
+	}
+	pkts = append(pkts, r.packet(extPkt, sn, extPkt.Packet.Timestamp, primary.Payload, extPkt.Packet.Marker, true))
+
+	if diff >= redHistorySize {
+		r.forwarded = 0
+	} else {
+		r.forwarded <<= diff
+	}
+	r.highestSN = sn
+	for _, pkt := range pkts {
+		r.forwarded |= 1 << (sn - pkt.Packet.SequenceNumber)
+	}
+	return pkts
+}
+
+func (r *REDUnwrapper) packet(extPkt *buffer.ExtPacket, sn uint16, ts uint32, payload []byte, marker bool, head bool) *buffer.ExtPacket {
+	pkt := &rtp.Packet{
+		Header:  extPkt.Packet.Header,
+		Payload: payload,
+	}
+	pkt.Header.SequenceNumber = sn
+	pkt.Header.Timestamp = ts
+	pkt.Header.Marker = marker
+	return &buffer.ExtPacket{
+		Head:    head,
+		Arrival: extPkt.Arrival,
+		Packet:  pkt,
+	}
+}
@@ -0,0 +1,220 @@
+package sfu
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+const (
+	testOpusPT       = 111
+	testOpusDuration = 960
+)
+
+// continuous across the sequence number wrap around at 0
+func testOpusTimestamp(sn uint16) uint32 {
+	return uint32(int32(int16(sn)) * testOpusDuration)
+}
+
+func testOpusPayload(sn uint16) []byte {
+	return []byte{byte(sn >> 8), byte(sn), 0xaa, 0xbb}
+}
+
+// builds a RED payload protecting up to distance previous packets
+func testREDPayload(sn uint16, distance int) []byte {
+	var headers, data []byte
+	for i := distance; i > 0; i-- {
+		block := testOpusPayload(sn - uint16(i))
+		offset := uint32(i * testOpusDuration)
+		headers = append(headers, 0x80|testOpusPT, byte(offset>>6), byte(offset<<2)|byte(len(block)>>8), byte(len(block)))
+		data = append(data, block...)
+	}
+	headers = append(headers, testOpusPT)
+	data = append(data, testOpusPayload(sn)...)
+	return append(headers, data...)
+}
+
+func testREDPacket(sn uint16, distance int) *buffer.ExtPacket {
+	return &buffer.ExtPacket{
+		Head: true,
+		Packet: &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    63,
+				SequenceNumber: sn,
+				Timestamp:      testOpusTimestamp(sn),
+				SSRC:           1234,
+			},
+			Payload: testREDPayload(sn, distance),
+		},
+	}
+}
+
+func TestParseREDPayload(t *testing.T) {
+	redundant, primary, err := ParseREDPayload(testREDPayload(10, 2))
+	require.NoError(t, err)
+	require.Len(t, redundant, 2)
+	require.Equal(t, uint8(testOpusPT), redundant[0].PayloadType)
+	require.Equal(t, uint32(2*testOpusDuration), redundant[0].TimestampOffset)
+	require.Equal(t, testOpusPayload(8), redundant[0].Payload)
+	require.Equal(t, uint32(testOpusDuration), redundant[1].TimestampOffset)
+	require.Equal(t, testOpusPayload(9), redundant[1].Payload)
+	require.Equal(t, uint8(testOpusPT), primary.PayloadType)
+	require.Equal(t, testOpusPayload(10), primary.Payload)
+
+	// primary only
+	redundant, primary, err = ParseREDPayload(testREDPayload(10, 0))
+	require.NoError(t, err)
+	require.Empty(t, redundant)
+	require.Equal(t, testOpusPayload(10), primary.Payload)
+
+	// truncated
+	payload := testREDPayload(10, 2)
+	_, _, err = ParseREDPayload(payload[:3])
+	require.ErrorIs(t, err, ErrInvalidREDPayload)
+	_, _, err = ParseREDPayload(payload[:10])
+	require.ErrorIs(t, err, ErrInvalidREDPayload)
+	_, _, err = ParseREDPayload(nil)
+	require.ErrorIs(t, err, ErrInvalidREDPayload)
+}
+
+func TestRewriteREDPayloadType(t *testing.T) {
+	payload := testREDPayload(10, 2)
+	buf := make([]byte, 1500)
+
+	// same payload type, no copy
+	out, err := RewriteREDPayloadType(payload, testOpusPT, buf)
+	require.NoError(t, err)
+	require.Equal(t, &payload[0], &out[0])
+
+	out, err = RewriteREDPayloadType(payload, 109, buf)
+	require.NoError(t, err)
+	require.Equal(t, &buf[0], &out[0])
+	redundant, primary, err := ParseREDPayload(out)
+	require.NoError(t, err)
+	for _, block := range redundant {
+		require.Equal(t, uint8(109), block.PayloadType)
+	}
+	require.Equal(t, uint8(109), primary.PayloadType)
+	require.Equal(t, testOpusPayload(10), primary.Payload)
+	// source is left alone, it's shared by all subscribers
+	require.Equal(t, testREDPayload(10, 2), payload)
+
+	_, err = RewriteREDPayloadType(payload, 109, buf[:4])
+	require.ErrorIs(t, err, ErrREDBufferTooSmall)
+}
+
+func TestREDUnwrapper(t *testing.T) {
+	type lossPattern struct {
+		name      string
+		start     uint16
+		distance  int
+		lost      map[uint16]bool
+		recovered []uint16
+		missing   []uint16
+	}
+	patterns := []lossPattern{
+		{
+			name:     "no loss",
+			start:    100,
+			distance: 2,
+		},
+		{
+			name:      "single losses",
+			start:     100,
+			distance:  2,
+			lost:      map[uint16]bool{103: true, 110: true, 112: true},
+			recovered: []uint16{103, 110, 112},
+		},
+		{
+			name:      "burst within redundancy",
+			start:     100,
+			distance:  2,
+			lost:      map[uint16]bool{105: true, 106: true},
+			recovered: []uint16{105, 106},
+		},
+		{
+			name:      "burst beyond redundancy",
+			start:     100,
+			distance:  2,
+			lost:      map[uint16]bool{105: true, 106: true, 107: true},
+			recovered: []uint16{106, 107},
+			missing:   []uint16{105},
+		},
+		{
+			name:      "single redundancy",
+			start:     100,
+			distance:  1,
+			lost:      map[uint16]bool{105: true, 106: true},
+			recovered: []uint16{106},
+			missing:   []uint16{105},
+		},
+		{
+			name:      "sequence number wrap around",
+			start:     65530,
+			distance:  2,
+			lost:      map[uint16]bool{65535: true, 0: true, 3: true},
+			recovered: []uint16{65535, 0, 3},
+		},
+	}
+
+	for _, p := range patterns {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			r := NewREDUnwrapper()
+			var forwarded []uint16
+			recovered := make(map[uint16]bool)
+			for i := 0; i < 20; i++ {
+				sn := p.start + uint16(i)
+				if p.lost[sn] {
+					continue
+				}
+				for _, pkt := range r.Unwrap(testREDPacket(sn, p.distance)) {
+					require.True(t, pkt.Head)
+					require.Equal(t, testOpusPayload(pkt.Packet.SequenceNumber), pkt.Packet.Payload)
+					require.Equal(t, testOpusTimestamp(pkt.Packet.SequenceNumber), pkt.Packet.Timestamp)
+					if pkt.Packet.SequenceNumber != sn {
+						recovered[pkt.Packet.SequenceNumber] = true
+					}
+					forwarded = append(forwarded, pkt.Packet.SequenceNumber)
+				}
+			}
+
+			// in order without duplicates
+			for i := 1; i < len(forwarded); i++ {
+				require.Less(t, uint16(forwarded[i-1]-p.start), uint16(forwarded[i]-p.start))
+			}
+			require.Len(t, recovered, len(p.recovered))
+			for _, sn := range p.recovered {
+				require.True(t, recovered[sn], "packet %d not recovered", sn)
+			}
+			require.Len(t, forwarded, 20-len(p.missing))
+			for _, sn := range p.missing {
+				require.NotContains(t, forwarded, sn)
+			}
+		})
+	}
+
+	t.Run("late packets are not forwarded twice", func(t *testing.T) {
+		r := NewREDUnwrapper()
+		require.Len(t, r.Unwrap(testREDPacket(10, 2)), 1)
+		// 11 lost, recovered from 12
+		pkts := r.Unwrap(testREDPacket(12, 2))
+		require.Len(t, pkts, 2)
+		require.Equal(t, uint16(11), pkts[0].Packet.SequenceNumber)
+
+		// 11 arrives late
+		require.Empty(t, r.Unwrap(testREDPacket(11, 2)))
+
+		// 14 before 13, both forwarded once
+		pkts = r.Unwrap(testREDPacket(14, 0))
+		require.Len(t, pkts, 1)
+		pkts = r.Unwrap(testREDPacket(13, 0))
+		require.Len(t, pkts, 1)
+		require.False(t, pkts[0].Head)
+		require.Empty(t, r.Unwrap(testREDPacket(13, 0)))
+	})
+}
@@ -0,0 +1,87 @@
+package sfu
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"go.uber.org/atomic"
+)
+
+const (
+	MimeTypeRTX = "video/rtx"
+
+	// original sequence number prepended to the payload of a retransmission
+	rtxOSNSize = 2
+)
+
+var (
+	ErrRTXBufferTooSmall = errors.New("buffer too small for RTX payload")
+)
+
+// RTXPayloadType returns the payload type of the RTX codec associated (apt) with the given payload type
+func RTXPayloadType(codecs []webrtc.RTPCodecParameters, payloadType webrtc.PayloadType) (webrtc.PayloadType, bool) {
+	for _, codec := range codecs {
+		if !strings.EqualFold(codec.MimeType, MimeTypeRTX) {
+			continue
+		}
+		if apt, err := strconv.Atoi(parseFmtpLine(codec.SDPFmtpLine)["apt"]); err == nil && webrtc.PayloadType(apt) == payloadType {
+			return codec.PayloadType, true
+		}
+	}
+	return 0, false
+}
+
+// BuildRTXPayload writes the payload of a retransmission (RFC 4588) into buf, the original sequence number
+// followed by the original payload
+/*
+	 0                   1                   2                   3
+	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+	|            OSN                |                               |
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+                               |
+	|                  Original RTP Packet Payload                  |
+	|                                                               |
+	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+*/
+func BuildRTXPayload(osn uint16, payload []byte, buf []byte) ([]byte, error) {
+	if len(buf) < rtxOSNSize+len(payload) {
+		return nil, ErrRTXBufferTooSmall
+	}
+	out := buf[:rtxOSNSize+len(payload)]
+	out[0] = byte(osn >> 8)
+	out[1] = byte(osn)
+	copy(out[rtxOSNSize:], payload)
+	return out, nil
+}
+
+// rtxStream is the retransmission stream associated with a DownTrack. It has its own SSRC, payload type and
+// sequence number space, so retransmissions and padding don't disturb the sequence numbers of the media stream
+type rtxStream struct {
+	ssrc           uint32
+	payloadType    uint8
+	sequenceNumber atomic.Uint32
+}
+
+func newRTXStream(ssrc uint32, payloadType uint8, startSN uint16) *rtxStream {
+	r := &rtxStream{
+		ssrc:        ssrc,
+		payloadType: payloadType,
+	}
+	// the counter holds the next sequence number minus one, truncating to 16 bits handles wrap around
+	r.sequenceNumber.Store(uint32(startSN - 1))
+	return r
+}
+
+// header returns the header of the next packet on the stream, based on the one of the media packet it carries.
+// Safe for concurrent use, retransmissions and padding are sent from different goroutines
+func (r *rtxStream) header(hdr *rtp.Header) rtp.Header {
+	rtxHdr := *hdr
+	rtxHdr.Padding = false
+	rtxHdr.PayloadType = r.payloadType
+	rtxHdr.SSRC = r.ssrc
+	rtxHdr.SequenceNumber = uint16(r.sequenceNumber.Inc())
+	return rtxHdr
+}
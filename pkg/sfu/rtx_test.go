@@ -0,0 +1,98 @@
+package sfu
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTXPayloadType(t *testing.T) {
+	codecs := []webrtc.RTPCodecParameters{
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, PayloadType: 96},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: MimeTypeRTX, ClockRate: 90000, SDPFmtpLine: "apt=96"}, PayloadType: 97},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000}, PayloadType: 125},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: "video/RTX", ClockRate: 90000, SDPFmtpLine: "apt=125"}, PayloadType: 107},
+		{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP9, ClockRate: 90000}, PayloadType: 98},
+	}
+
+	pt, ok := RTXPayloadType(codecs, 96)
+	require.True(t, ok)
+	require.Equal(t, webrtc.PayloadType(97), pt)
+
+	pt, ok = RTXPayloadType(codecs, 125)
+	require.True(t, ok)
+	require.Equal(t, webrtc.PayloadType(107), pt)
+
+	// not negotiated
+	_, ok = RTXPayloadType(codecs, 98)
+	require.False(t, ok)
+}
+
+func TestBuildRTXPayload(t *testing.T) {
+	buf := make([]byte, 1500)
+	payload := []byte{0x01, 0x02, 0x03}
+
+	out, err := BuildRTXPayload(0xabcd, payload, buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xab, 0xcd, 0x01, 0x02, 0x03}, out)
+	require.Equal(t, &buf[0], &out[0])
+
+	// empty payload still carries the original sequence number
+	out, err = BuildRTXPayload(1, nil, buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00, 0x01}, out)
+
+	_, err = BuildRTXPayload(1, payload, buf[:4])
+	require.ErrorIs(t, err, ErrRTXBufferTooSmall)
+}
+
+func TestRTXStream(t *testing.T) {
+	r := newRTXStream(5678, 97, 65534)
+
+	media := rtp.Header{
+		Version:        2,
+		Padding:        true,
+		Marker:         true,
+		PayloadType:    96,
+		SequenceNumber: 1000,
+		Timestamp:      90000,
+		SSRC:           1234,
+	}
+
+	// sequence numbers are consecutive across the wrap around, independent of the media ones
+	var sns []uint16
+	for i := 0; i < 4; i++ {
+		hdr := r.header(&media)
+		require.Equal(t, uint8(97), hdr.PayloadType)
+		require.Equal(t, uint32(5678), hdr.SSRC)
+		require.Equal(t, media.Timestamp, hdr.Timestamp)
+		require.True(t, hdr.Marker)
+		require.False(t, hdr.Padding)
+		sns = append(sns, hdr.SequenceNumber)
+	}
+	require.Equal(t, []uint16{65534, 65535, 0, 1}, sns)
+
+	// media header is left alone
+	require.Equal(t, uint16(1000), media.SequenceNumber)
+	require.Equal(t, uint32(1234), media.SSRC)
+	require.Equal(t, uint8(96), media.PayloadType)
+	require.True(t, media.Padding)
+
+	// a retransmission of a packet sent right before the media sequence number wrapped around
+	media.SequenceNumber = 65535
+	hdr := r.header(&media)
+	out, err := BuildRTXPayload(media.SequenceNumber, []byte{0xaa}, make([]byte, 1500))
+	require.NoError(t, err)
+	pkt := rtp.Packet{Header: hdr, Payload: out}
+	raw, err := pkt.Marshal()
+	require.NoError(t, err)
+
+	var parsed rtp.Packet
+	require.NoError(t, parsed.Unmarshal(raw))
+	require.Equal(t, uint16(2), parsed.SequenceNumber)
+	require.Equal(t, uint32(5678), parsed.SSRC)
+	require.Equal(t, uint16(65535), uint16(parsed.Payload[0])<<8|uint16(parsed.Payload[1]))
+	require.Equal(t, []byte{0xaa}, parsed.Payload[rtxOSNSize:])
+}
@@ -0,0 +1,62 @@
+package sendsidebwe
+
+import (
+	"time"
+
+	"github.com/gammazero/deque"
+)
+
+const (
+	ackedBitrateWindow = 500 * time.Millisecond
+)
+
+type ackedSample struct {
+	arrival time.Duration
+	size    int
+}
+
+// ackedBitrate measures the rate at which the remote end receives packets,
+// using remote arrival times over a sliding window
+type ackedBitrate struct {
+	samples deque.Deque
+	bytes   int
+
+	firstArrival  time.Duration
+	hasFirst      bool
+	latestArrival time.Duration
+}
+
+func newAckedBitrate() *ackedBitrate {
+	return &ackedBitrate{}
+}
+
+func (a *ackedBitrate) add(arrival time.Duration, size int) {
+	if !a.hasFirst {
+		a.firstArrival = arrival
+		a.hasFirst = true
+	}
+	if arrival > a.latestArrival {
+		a.latestArrival = arrival
+	}
+
+	a.samples.PushBack(ackedSample{arrival: arrival, size: size})
+	a.bytes += size
+
+	for a.samples.Len() > 0 {
+		oldest := a.samples.Front().(ackedSample)
+		if a.latestArrival-oldest.arrival <= ackedBitrateWindow {
+			break
+		}
+		a.samples.PopFront()
+		a.bytes -= oldest.size
+	}
+}
+
+// bitrate returns the acknowledged bitrate, 0 till a full window has been observed
+func (a *ackedBitrate) bitrate() int64 {
+	if !a.hasFirst || a.latestArrival-a.firstArrival < ackedBitrateWindow {
+		return 0
+	}
+
+	return int64(a.bytes) * 8 * int64(time.Second) / int64(ackedBitrateWindow)
+}
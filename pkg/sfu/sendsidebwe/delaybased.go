@@ -0,0 +1,342 @@
+package sendsidebwe
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// packets sent within this interval of the first packet of a group belong to the same group
+	burstInterval = 5 * time.Millisecond
+
+	trendlineWindowSize     = 20
+	trendlineSmoothingCoeff = 0.9
+	trendlineThresholdGain  = 4.0
+	trendlineMaxNumDeltas   = 60
+
+	overuseTimeThreshold = 10 * time.Millisecond
+
+	thresholdInitial       = 12.5
+	thresholdMin           = 6.0
+	thresholdMax           = 600.0
+	thresholdKUp           = 0.0087
+	thresholdKDown         = 0.039
+	thresholdMaxDeltaMs    = 100.0
+	thresholdMaxTrendDelta = 15.0
+
+	decreaseFactor          = 0.85
+	increaseFactorPerSecond = 1.08
+	minIncreaseBps          = 1000
+	ackedBitrateHeadroom    = 1.5
+	ackedBitrateHeadroomBps = 10_000
+	minDecreaseInterval     = 200 * time.Millisecond
+	maxIncreaseInterval     = time.Second
+)
+
+type bandwidthUsage int
+
+const (
+	bandwidthUsageNormal bandwidthUsage = iota
+	bandwidthUsageOveruse
+	bandwidthUsageUnderuse
+)
+
+func (b bandwidthUsage) String() string {
+	switch b {
+	case bandwidthUsageNormal:
+		return "NORMAL"
+	case bandwidthUsageOveruse:
+		return "OVERUSE"
+	case bandwidthUsageUnderuse:
+		return "UNDERUSE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+type rateControlState int
+
+const (
+	rateControlStateHold rateControlState = iota
+	rateControlStateIncrease
+	rateControlStateDecrease
+)
+
+// ------------------------------------------------
+
+type packetGroup struct {
+	firstSent   time.Time
+	lastSent    time.Time
+	lastArrival time.Duration
+}
+
+type trendlinePoint struct {
+	x float64
+	y float64
+}
+
+// trendlineEstimator fits a line to the smoothed accumulated delay over arrival time,
+// a positive slope indicates a building queue
+type trendlineEstimator struct {
+	numDeltas        int
+	firstArrivalMs   float64
+	hasFirstArrival  bool
+	accumulatedDelay float64
+	smoothedDelay    float64
+	points           []trendlinePoint
+	slope            float64
+}
+
+func (t *trendlineEstimator) update(delayDeltaMs float64, arrivalMs float64) {
+	if t.numDeltas < trendlineMaxNumDeltas {
+		t.numDeltas++
+	}
+	if !t.hasFirstArrival {
+		t.firstArrivalMs = arrivalMs
+		t.hasFirstArrival = true
+	}
+
+	t.accumulatedDelay += delayDeltaMs
+	t.smoothedDelay = trendlineSmoothingCoeff*t.smoothedDelay + (1-trendlineSmoothingCoeff)*t.accumulatedDelay
+
+	t.points = append(t.points, trendlinePoint{x: arrivalMs - t.firstArrivalMs, y: t.smoothedDelay})
+	if len(t.points) > trendlineWindowSize {
+		t.points = t.points[1:]
+	}
+	if len(t.points) == trendlineWindowSize {
+		if slope, ok := linearFitSlope(t.points); ok {
+			t.slope = slope
+		}
+	}
+}
+
+func (t *trendlineEstimator) modifiedTrend() float64 {
+	return float64(t.numDeltas) * t.slope * trendlineThresholdGain
+}
+
+func linearFitSlope(points []trendlinePoint) (float64, bool) {
+	sumX, sumY := 0.0, 0.0
+	for _, p := range points {
+		sumX += p.x
+		sumY += p.y
+	}
+	avgX := sumX / float64(len(points))
+	avgY := sumY / float64(len(points))
+
+	numerator, denominator := 0.0, 0.0
+	for _, p := range points {
+		numerator += (p.x - avgX) * (p.y - avgY)
+		denominator += (p.x - avgX) * (p.x - avgX)
+	}
+	if denominator == 0 {
+		return 0, false
+	}
+	return numerator / denominator, true
+}
+
+// ------------------------------------------------
+
+type delayBasedEstimator struct {
+	minBitrate int64
+	maxBitrate int64
+
+	currentGroup    *packetGroup
+	previousGroup   *packetGroup
+	trendline       trendlineEstimator
+	prevTrend       float64
+	delayThreshold  float64
+	lastThresholdMs float64
+	hasThresholdMs  bool
+	timeOverusing   time.Duration
+	overuseCount    int
+	hypothesis      bandwidthUsage
+
+	bitrate      int64
+	state        rateControlState
+	lastUpdate   time.Time
+	lastDecrease time.Time
+}
+
+func newDelayBasedEstimator(initialBitrate, minBitrate, maxBitrate int64) *delayBasedEstimator {
+	return &delayBasedEstimator{
+		minBitrate:     minBitrate,
+		maxBitrate:     maxBitrate,
+		delayThreshold: thresholdInitial,
+		timeOverusing:  -1,
+		bitrate:        initialBitrate,
+		state:          rateControlStateIncrease,
+	}
+}
+
+func (d *delayBasedEstimator) update(now time.Time, results []packetResult, ackedBitrate int64) int64 {
+	for _, r := range results {
+		if r.received {
+			d.addPacket(r)
+		}
+	}
+
+	d.updateRate(now, ackedBitrate)
+	return d.bitrate
+}
+
+func (d *delayBasedEstimator) trend() float64 {
+	return d.trendline.modifiedTrend()
+}
+
+func (d *delayBasedEstimator) threshold() float64 {
+	return d.delayThreshold
+}
+
+func (d *delayBasedEstimator) usage() bandwidthUsage {
+	return d.hypothesis
+}
+
+func (d *delayBasedEstimator) addPacket(r packetResult) {
+	if d.currentGroup == nil {
+		d.currentGroup = &packetGroup{firstSent: r.sentAt, lastSent: r.sentAt, lastArrival: r.arrival}
+		return
+	}
+
+	if r.sentAt.Before(d.currentGroup.firstSent) {
+		// re-ordered on send, cannot be attributed to a group
+		return
+	}
+
+	if r.sentAt.Sub(d.currentGroup.firstSent) <= burstInterval {
+		if r.sentAt.After(d.currentGroup.lastSent) {
+			d.currentGroup.lastSent = r.sentAt
+		}
+		if r.arrival > d.currentGroup.lastArrival {
+			d.currentGroup.lastArrival = r.arrival
+		}
+		return
+	}
+
+	if d.previousGroup != nil {
+		d.onGroupDelta(d.previousGroup, d.currentGroup)
+	}
+	d.previousGroup = d.currentGroup
+	d.currentGroup = &packetGroup{firstSent: r.sentAt, lastSent: r.sentAt, lastArrival: r.arrival}
+}
+
+func (d *delayBasedEstimator) onGroupDelta(prev *packetGroup, curr *packetGroup) {
+	sendDelta := curr.lastSent.Sub(prev.lastSent)
+	arrivalDelta := curr.lastArrival - prev.lastArrival
+	if arrivalDelta < 0 {
+		// re-ordered in the network
+		return
+	}
+
+	delayDeltaMs := float64(arrivalDelta-sendDelta) / float64(time.Millisecond)
+	arrivalMs := float64(curr.lastArrival) / float64(time.Millisecond)
+	d.trendline.update(delayDeltaMs, arrivalMs)
+	d.detect(sendDelta, arrivalMs)
+}
+
+func (d *delayBasedEstimator) detect(sendDelta time.Duration, arrivalMs float64) {
+	trend := d.trendline.modifiedTrend()
+
+	switch {
+	case trend > d.delayThreshold:
+		if d.timeOverusing < 0 {
+			d.timeOverusing = sendDelta / 2
+		} else {
+			d.timeOverusing += sendDelta
+		}
+		d.overuseCount++
+		if d.timeOverusing > overuseTimeThreshold && d.overuseCount > 1 && trend >= d.prevTrend {
+			d.timeOverusing = 0
+			d.overuseCount = 0
+			d.hypothesis = bandwidthUsageOveruse
+		}
+	case trend < -d.delayThreshold:
+		d.timeOverusing = -1
+		d.overuseCount = 0
+		d.hypothesis = bandwidthUsageUnderuse
+	default:
+		d.timeOverusing = -1
+		d.overuseCount = 0
+		d.hypothesis = bandwidthUsageNormal
+	}
+	d.prevTrend = trend
+
+	d.updateThreshold(trend, arrivalMs)
+}
+
+func (d *delayBasedEstimator) updateThreshold(trend float64, arrivalMs float64) {
+	if !d.hasThresholdMs {
+		d.lastThresholdMs = arrivalMs
+		d.hasThresholdMs = true
+	}
+
+	absTrend := math.Abs(trend)
+	if absTrend > d.delayThreshold+thresholdMaxTrendDelta {
+		// do not adapt to spikes
+		d.lastThresholdMs = arrivalMs
+		return
+	}
+
+	k := thresholdKUp
+	if absTrend < d.delayThreshold {
+		k = thresholdKDown
+	}
+	deltaMs := math.Min(arrivalMs-d.lastThresholdMs, thresholdMaxDeltaMs)
+	d.delayThreshold += k * (absTrend - d.delayThreshold) * deltaMs
+	d.delayThreshold = math.Max(thresholdMin, math.Min(d.delayThreshold, thresholdMax))
+	d.lastThresholdMs = arrivalMs
+}
+
+func (d *delayBasedEstimator) updateRate(now time.Time, ackedBitrate int64) {
+	switch d.hypothesis {
+	case bandwidthUsageOveruse:
+		d.state = rateControlStateDecrease
+	case bandwidthUsageUnderuse:
+		d.state = rateControlStateHold
+	case bandwidthUsageNormal:
+		if d.state == rateControlStateHold {
+			d.state = rateControlStateIncrease
+		}
+	}
+
+	switch d.state {
+	case rateControlStateIncrease:
+		if !d.lastUpdate.IsZero() {
+			elapsed := now.Sub(d.lastUpdate)
+			if elapsed > maxIncreaseInterval {
+				elapsed = maxIncreaseInterval
+			}
+			factor := math.Pow(increaseFactorPerSecond, elapsed.Seconds())
+			increase := math.Max(float64(d.bitrate)*(factor-1), minIncreaseBps*elapsed.Seconds())
+			bitrate := d.bitrate + int64(increase)
+
+			// do not run too far ahead of what is getting through
+			if ackedBitrate > 0 {
+				limit := int64(ackedBitrateHeadroom*float64(ackedBitrate)) + ackedBitrateHeadroomBps
+				if bitrate > limit {
+					if limit > d.bitrate {
+						bitrate = limit
+					} else {
+						bitrate = d.bitrate
+					}
+				}
+			}
+			d.bitrate = bitrate
+		}
+
+	case rateControlStateDecrease:
+		if now.Sub(d.lastDecrease) >= minDecreaseInterval {
+			reference := d.bitrate
+			if ackedBitrate > 0 {
+				reference = ackedBitrate
+			}
+			if bitrate := int64(decreaseFactor * float64(reference)); bitrate < d.bitrate {
+				d.bitrate = bitrate
+			}
+			d.lastDecrease = now
+		}
+		d.state = rateControlStateHold
+	}
+
+	d.bitrate = clamp(d.bitrate, d.minBitrate, d.maxBitrate)
+	d.lastUpdate = now
+}
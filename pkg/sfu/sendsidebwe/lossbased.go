@@ -0,0 +1,85 @@
+package sendsidebwe
+
+import (
+	"time"
+)
+
+const (
+	lossUpdateInterval     = 200 * time.Millisecond
+	lossHighThreshold      = 0.1
+	lossLowThreshold       = 0.02
+	lossIncreaseFactor     = 1.05
+	lossDecreaseAttenuator = 0.5
+)
+
+// lossBasedEstimator backs off in proportion to loss when loss is high,
+// and grows the estimate when loss is low.
+// Loss is evaluated over intervals so that it reacts at a steady pace irrespective of feedback frequency.
+type lossBasedEstimator struct {
+	minBitrate int64
+	maxBitrate int64
+
+	bitrate int64
+
+	numLost    int
+	numTotal   int
+	lastUpdate time.Time
+	lastRatio  float64
+}
+
+func newLossBasedEstimator(initialBitrate, minBitrate, maxBitrate int64) *lossBasedEstimator {
+	return &lossBasedEstimator{
+		minBitrate: minBitrate,
+		maxBitrate: maxBitrate,
+		bitrate:    initialBitrate,
+	}
+}
+
+// update accounts for the results of a feedback, reference is the bitrate being used currently
+// which is the base for back off
+func (l *lossBasedEstimator) update(now time.Time, results []packetResult, ackedBitrate int64, reference int64) int64 {
+	for _, r := range results {
+		l.numTotal++
+		if !r.received {
+			l.numLost++
+		}
+	}
+
+	if l.lastUpdate.IsZero() {
+		l.lastUpdate = now
+	}
+	if now.Sub(l.lastUpdate) < lossUpdateInterval || l.numTotal == 0 {
+		return l.bitrate
+	}
+
+	l.lastRatio = float64(l.numLost) / float64(l.numTotal)
+	l.numLost = 0
+	l.numTotal = 0
+	l.lastUpdate = now
+
+	switch {
+	case l.lastRatio > lossHighThreshold:
+		l.bitrate = int64(float64(reference) * (1.0 - lossDecreaseAttenuator*l.lastRatio))
+
+	case l.lastRatio < lossLowThreshold:
+		bitrate := int64(float64(l.bitrate) * lossIncreaseFactor)
+		if ackedBitrate > 0 {
+			limit := int64(ackedBitrateHeadroom*float64(ackedBitrate)) + ackedBitrateHeadroomBps
+			if bitrate > limit {
+				if limit > l.bitrate {
+					bitrate = limit
+				} else {
+					bitrate = l.bitrate
+				}
+			}
+		}
+		l.bitrate = bitrate
+	}
+
+	l.bitrate = clamp(l.bitrate, l.minBitrate, l.maxBitrate)
+	return l.bitrate
+}
+
+func (l *lossBasedEstimator) lossRatio() float64 {
+	return l.lastRatio
+}
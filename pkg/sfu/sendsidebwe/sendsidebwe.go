@@ -0,0 +1,397 @@
+package sendsidebwe
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+)
+
+//
+// Send side bandwidth estimation
+//
+// Estimates the channel capacity of a subscriber peer connection from
+// transport wide congestion control feedback
+// (https://tools.ietf.org/html/draft-holmer-rmcat-transport-wide-cc-extensions-01).
+//
+// Every outgoing packet carrying the transport wide sequence number
+// header extension is recorded with its send time and size. Feedback
+// from the remote end reports which of those packets arrived and when.
+// Two estimates are run off of that
+//   - delay based: inter-group delay variation is fed into a trendline
+//     filter, the slope of which is compared against an adaptive threshold
+//     to detect over/under use of the channel. An AIMD controller adjusts
+//     the estimate based on that signal.
+//   - loss based: the estimate is cut back when the reported loss is high
+//     and allowed to grow when loss is low.
+//
+// The target bitrate is the lower of the two, bounded by configured minimum
+// and maximum. It implements pion's cc.BandwidthEstimator so that it can be
+// plugged into the cc interceptor.
+//
+
+const (
+	DefaultInitialBitrate = 1_000_000
+	DefaultMinBitrate     = 30_000
+	DefaultMaxBitrate     = 100_000_000
+
+	// a new target is reported at most this often unless it drops
+	EstimateReportInterval = 250 * time.Millisecond
+
+	packetHistorySize = 1 << 12
+	packetHistoryMask = packetHistorySize - 1
+
+	referenceTimeUnit = 64 * time.Millisecond
+	referenceTimeWrap = 1 << 24
+)
+
+type Estimate struct {
+	Target       int64
+	DelayBased   int64
+	LossBased    int64
+	Acknowledged int64
+	LossRatio    float64
+}
+
+type SendSideBWEParams struct {
+	Logger         logger.Logger
+	InitialBitrate int64
+	MinBitrate     int64
+	MaxBitrate     int64
+
+	// called after every feedback with the latest estimate and on close with a zero estimate.
+	// It is called with the estimator locked to keep updates ordered, so it must not call back into the estimator.
+	OnEstimate func(estimate Estimate)
+}
+
+type packetInfo struct {
+	sn           uint16
+	valid        bool
+	reported     bool
+	reportedLost bool
+	sentAt       time.Time
+	size         int
+}
+
+// packetResult is the outcome of a sent packet as reported by feedback,
+// arrival is in the remote clock and is only valid if received is true
+type packetResult struct {
+	sentAt   time.Time
+	size     int
+	received bool
+	arrival  time.Duration
+}
+
+type SendSideBWE struct {
+	params SendSideBWEParams
+
+	now func() time.Time
+
+	lock sync.Mutex
+
+	history [packetHistorySize]packetInfo
+
+	refTimeInitialized bool
+	lastRefTime        uint32
+	refTimeCycles      int64
+
+	acked *ackedBitrate
+	delay *delayBasedEstimator
+	loss  *lossBasedEstimator
+
+	estimate           Estimate
+	lastReportedTarget int64
+	lastReportedAt     time.Time
+	closed             bool
+
+	onTargetBitrateChange func(bitrate int)
+}
+
+func NewSendSideBWE(params SendSideBWEParams) *SendSideBWE {
+	if params.MinBitrate <= 0 {
+		params.MinBitrate = DefaultMinBitrate
+	}
+	if params.MaxBitrate <= 0 {
+		params.MaxBitrate = DefaultMaxBitrate
+	}
+	if params.MaxBitrate < params.MinBitrate {
+		params.MaxBitrate = params.MinBitrate
+	}
+	if params.InitialBitrate <= 0 {
+		params.InitialBitrate = DefaultInitialBitrate
+	}
+	params.InitialBitrate = clamp(params.InitialBitrate, params.MinBitrate, params.MaxBitrate)
+
+	e := &SendSideBWE{
+		params: params,
+		now:    time.Now,
+		acked:  newAckedBitrate(),
+		delay:  newDelayBasedEstimator(params.InitialBitrate, params.MinBitrate, params.MaxBitrate),
+		loss:   newLossBasedEstimator(params.InitialBitrate, params.MinBitrate, params.MaxBitrate),
+	}
+	e.estimate = Estimate{
+		Target:     params.InitialBitrate,
+		DelayBased: params.InitialBitrate,
+		LossBased:  params.InitialBitrate,
+	}
+	return e
+}
+
+// AddStream records send time and size of packets carrying a transport wide sequence number
+func (e *SendSideBWE) AddStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	var extID uint8
+	for _, ext := range info.RTPHeaderExtensions {
+		if ext.URI == sdp.TransportCCURI {
+			extID = uint8(ext.ID)
+			break
+		}
+	}
+	if extID == 0 {
+		return writer
+	}
+
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		if ext := header.GetExtension(extID); ext != nil {
+			var tcc rtp.TransportCCExtension
+			if err := tcc.Unmarshal(ext); err == nil {
+				e.onPacketSent(tcc.TransportSequence, header.MarshalSize()+len(payload))
+			}
+		}
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+// WriteRTCP consumes transport wide feedback, any other packets are ignored
+func (e *SendSideBWE) WriteRTCP(pkts []rtcp.Packet, _ interceptor.Attributes) error {
+	for _, pkt := range pkts {
+		if fb, ok := pkt.(*rtcp.TransportLayerCC); ok {
+			e.onFeedback(fb)
+		}
+	}
+	return nil
+}
+
+func (e *SendSideBWE) GetTargetBitrate() int {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return int(e.estimate.Target)
+}
+
+func (e *SendSideBWE) GetEstimate() Estimate {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.estimate
+}
+
+func (e *SendSideBWE) OnTargetBitrateChange(f func(bitrate int)) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.onTargetBitrateChange = f
+}
+
+func (e *SendSideBWE) GetStats() map[string]interface{} {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return map[string]interface{}{
+		"targetBitrate":       e.estimate.Target,
+		"delayBasedBitrate":   e.estimate.DelayBased,
+		"lossBasedBitrate":    e.estimate.LossBased,
+		"acknowledgedBitrate": e.estimate.Acknowledged,
+		"lossRatio":           e.estimate.LossRatio,
+		"delayTrend":          e.delay.trend(),
+		"delayThreshold":      e.delay.threshold(),
+		"usage":               e.delay.usage().String(),
+	}
+}
+
+func (e *SendSideBWE) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	e.estimate = Estimate{}
+	if e.params.OnEstimate != nil {
+		e.params.OnEstimate(e.estimate)
+	}
+	return nil
+}
+
+func (e *SendSideBWE) onPacketSent(sn uint16, size int) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.history[sn&packetHistoryMask] = packetInfo{
+		sn:     sn,
+		valid:  true,
+		sentAt: e.now(),
+		size:   size,
+	}
+}
+
+func (e *SendSideBWE) onFeedback(fb *rtcp.TransportLayerCC) {
+	e.lock.Lock()
+	if e.closed {
+		e.lock.Unlock()
+		return
+	}
+
+	results := e.parseFeedback(fb)
+	if len(results) == 0 {
+		e.lock.Unlock()
+		return
+	}
+
+	now := e.now()
+	for _, r := range results {
+		if r.received {
+			e.acked.add(r.arrival, r.size)
+		}
+	}
+	ackedBitrate := e.acked.bitrate()
+
+	prevDelayBased := e.estimate.DelayBased
+	delayBased := e.delay.update(now, results, ackedBitrate)
+	if delayBased < prevDelayBased && e.delay.usage() == bandwidthUsageOveruse {
+		e.params.Logger.Debugw(
+			"send side bwe: overuse detected",
+			"old(bps)", prevDelayBased,
+			"new(bps)", delayBased,
+			"acked(bps)", ackedBitrate,
+			"trend", e.delay.trend(),
+			"threshold", e.delay.threshold(),
+		)
+	}
+	lossBased := e.loss.update(now, results, ackedBitrate, minInt64(delayBased, e.estimate.Target))
+
+	e.estimate = Estimate{
+		Target:       clamp(minInt64(delayBased, lossBased), e.params.MinBitrate, e.params.MaxBitrate),
+		DelayBased:   delayBased,
+		LossBased:    lossBased,
+		Acknowledged: ackedBitrate,
+		LossRatio:    e.loss.lossRatio(),
+	}
+	estimate := e.estimate
+
+	var onTargetBitrateChange func(bitrate int)
+	if estimate.Target != e.lastReportedTarget &&
+		(estimate.Target < e.lastReportedTarget || now.Sub(e.lastReportedAt) >= EstimateReportInterval) {
+		e.lastReportedTarget = estimate.Target
+		e.lastReportedAt = now
+		onTargetBitrateChange = e.onTargetBitrateChange
+	}
+	if e.params.OnEstimate != nil {
+		e.params.OnEstimate(estimate)
+	}
+	e.lock.Unlock()
+
+	if onTargetBitrateChange != nil {
+		onTargetBitrateChange(int(estimate.Target))
+	}
+}
+
+// parseFeedback matches packet status of a feedback against sent packets.
+// Packets not found in history (too old or not sent by this end) and those
+// already reported by an earlier feedback are skipped.
+func (e *SendSideBWE) parseFeedback(fb *rtcp.TransportLayerCC) []packetResult {
+	arrival := e.unwrapReferenceTime(fb.ReferenceTime)
+
+	results := make([]packetResult, 0, fb.PacketStatusCount)
+	sn := fb.BaseSequenceNumber
+	remaining := int(fb.PacketStatusCount)
+	deltaIdx := 0
+
+	onStatus := func(symbol uint16) {
+		received := symbol != rtcp.TypeTCCPacketNotReceived
+		hasArrival := false
+		if symbol == rtcp.TypeTCCPacketReceivedSmallDelta || symbol == rtcp.TypeTCCPacketReceivedLargeDelta {
+			if deltaIdx < len(fb.RecvDeltas) {
+				arrival += time.Duration(fb.RecvDeltas[deltaIdx].Delta) * time.Microsecond
+				deltaIdx++
+				hasArrival = true
+			}
+		}
+
+		pi := &e.history[sn&packetHistoryMask]
+		// a packet reported lost could still be reported as received by a later feedback
+		if pi.valid && pi.sn == sn && !pi.reported && (received || !pi.reportedLost) {
+			if received {
+				pi.reported = true
+			} else {
+				pi.reportedLost = true
+			}
+			results = append(results, packetResult{
+				sentAt:   pi.sentAt,
+				size:     pi.size,
+				received: received && hasArrival,
+				arrival:  arrival,
+			})
+		}
+
+		sn++
+		remaining--
+	}
+
+	for _, chunk := range fb.PacketChunks {
+		switch c := chunk.(type) {
+		case *rtcp.RunLengthChunk:
+			for i := uint16(0); i < c.RunLength && remaining > 0; i++ {
+				onStatus(c.PacketStatusSymbol)
+			}
+		case *rtcp.StatusVectorChunk:
+			for _, symbol := range c.SymbolList {
+				if remaining <= 0 {
+					break
+				}
+				onStatus(symbol)
+			}
+		}
+	}
+
+	return results
+}
+
+func (e *SendSideBWE) unwrapReferenceTime(refTime uint32) time.Duration {
+	if e.refTimeInitialized {
+		diff := int64(refTime) - int64(e.lastRefTime)
+		if diff < -referenceTimeWrap/2 {
+			e.refTimeCycles++
+		} else if diff > referenceTimeWrap/2 {
+			e.refTimeCycles--
+		}
+	}
+	e.refTimeInitialized = true
+	e.lastRefTime = refTime
+
+	return time.Duration(e.refTimeCycles*referenceTimeWrap+int64(refTime)) * referenceTimeUnit
+}
+
+// ------------------------------------------------
+
+func clamp(value, min, max int64) int64 {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,418 @@
+package sendsidebwe
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPacketSize       = 1200
+	testPropagationDelay = 20 * time.Millisecond
+	testFeedbackInterval = 50 * time.Millisecond
+	testMaxQueueDelay    = 300 * time.Millisecond
+	testTick             = time.Millisecond
+	testSendInterval     = 5 * time.Millisecond
+)
+
+type simPacket struct {
+	sn      uint16
+	arrival time.Time
+	lost    bool
+}
+
+type simFeedback struct {
+	deliverAt time.Time
+	fb        *rtcp.TransportLayerCC
+}
+
+// linkSimulator sends at the estimated target over a bottleneck link with a FIFO queue
+// and feeds back transport wide feedback the way a receiver would
+type linkSimulator struct {
+	t *testing.T
+
+	now   time.Time
+	start time.Time
+	bwe   *SendSideBWE
+
+	capacityBps int64
+	randomLoss  float64
+	rng         *rand.Rand
+
+	nextSN       uint16
+	sendCredit   float64
+	lastSend     time.Time
+	linkFreeAt   time.Time
+	inFlight     []simPacket
+	lastFeedback time.Time
+	fbCount      uint8
+	feedbacks    []simFeedback
+}
+
+func newLinkSimulator(t *testing.T, initialBitrate int64, capacityBps int64) *linkSimulator {
+	start := time.Unix(1000, 0)
+	s := &linkSimulator{
+		t:           t,
+		now:         start,
+		start:       start,
+		capacityBps: capacityBps,
+		rng:         rand.New(rand.NewSource(1)),
+		lastSend:    start,
+		linkFreeAt:  start,
+	}
+	s.bwe = NewSendSideBWE(SendSideBWEParams{
+		Logger:         logger.Logger(logger.GetLogger()),
+		InitialBitrate: initialBitrate,
+	})
+	s.bwe.now = func() time.Time { return s.now }
+	return s
+}
+
+func (s *linkSimulator) run(d time.Duration) {
+	end := s.now.Add(d)
+	for s.now.Before(end) {
+		s.now = s.now.Add(testTick)
+		s.send()
+		s.receive()
+		s.deliverFeedback()
+	}
+}
+
+func (s *linkSimulator) send() {
+	if s.now.Sub(s.lastSend) < testSendInterval {
+		return
+	}
+	elapsed := s.now.Sub(s.lastSend)
+	s.lastSend = s.now
+
+	s.sendCredit += float64(s.bwe.GetTargetBitrate()) * elapsed.Seconds() / 8
+	for s.sendCredit >= testPacketSize {
+		s.sendCredit -= testPacketSize
+
+		sn := s.nextSN
+		s.nextSN++
+		s.bwe.onPacketSent(sn, testPacketSize)
+
+		pkt := simPacket{sn: sn}
+		if s.randomLoss > 0 && s.rng.Float64() < s.randomLoss {
+			pkt.lost = true
+			s.inFlight = append(s.inFlight, pkt)
+			continue
+		}
+
+		departAt := s.linkFreeAt
+		if departAt.Before(s.now) {
+			departAt = s.now
+		}
+		if departAt.Sub(s.now) > testMaxQueueDelay {
+			// tail drop
+			pkt.lost = true
+			s.inFlight = append(s.inFlight, pkt)
+			continue
+		}
+		departAt = departAt.Add(time.Duration(testPacketSize * 8 * int64(time.Second) / s.capacityBps))
+		s.linkFreeAt = departAt
+		pkt.arrival = departAt.Add(testPropagationDelay)
+		s.inFlight = append(s.inFlight, pkt)
+	}
+}
+
+func (s *linkSimulator) receive() {
+	if s.now.Sub(s.lastFeedback) < testFeedbackInterval {
+		return
+	}
+	s.lastFeedback = s.now
+
+	// FIFO link, so packets arrive in sequence number order, report up to the last one that arrived
+	last := -1
+	for i, pkt := range s.inFlight {
+		if !pkt.lost && pkt.arrival.After(s.now) {
+			break
+		}
+		if !pkt.lost {
+			last = i
+		}
+	}
+	if last < 0 {
+		return
+	}
+
+	fb := buildFeedback(s.start, s.inFlight[:last+1], s.fbCount)
+	s.fbCount++
+	s.inFlight = s.inFlight[last+1:]
+	s.feedbacks = append(s.feedbacks, simFeedback{deliverAt: s.now.Add(testPropagationDelay), fb: fb})
+}
+
+func (s *linkSimulator) deliverFeedback() {
+	for len(s.feedbacks) > 0 && !s.feedbacks[0].deliverAt.After(s.now) {
+		require.NoError(s.t, s.bwe.WriteRTCP([]rtcp.Packet{s.feedbacks[0].fb}, nil))
+		s.feedbacks = s.feedbacks[1:]
+	}
+}
+
+// averageTarget runs for the given duration and returns the average target bitrate
+func (s *linkSimulator) averageTarget(d time.Duration) int64 {
+	sum, count := int64(0), int64(0)
+	end := s.now.Add(d)
+	for s.now.Before(end) {
+		s.run(100 * time.Millisecond)
+		sum += int64(s.bwe.GetTargetBitrate())
+		count++
+	}
+	return sum / count
+}
+
+// buildFeedback builds transport wide feedback using two bit status vector chunks, arrival times relative to epoch
+func buildFeedback(epoch time.Time, packets []simPacket, fbCount uint8) *rtcp.TransportLayerCC {
+	fb := &rtcp.TransportLayerCC{
+		BaseSequenceNumber: packets[0].sn,
+		PacketStatusCount:  uint16(len(packets)),
+		FbPktCount:         fbCount,
+	}
+
+	var ref time.Duration
+	for _, pkt := range packets {
+		if !pkt.lost {
+			ref = pkt.arrival.Sub(epoch) / referenceTimeUnit * referenceTimeUnit
+			break
+		}
+	}
+	fb.ReferenceTime = uint32(ref / referenceTimeUnit)
+
+	last := ref
+	var symbols []uint16
+	for _, pkt := range packets {
+		if pkt.lost {
+			symbols = append(symbols, rtcp.TypeTCCPacketNotReceived)
+			continue
+		}
+
+		arrival := pkt.arrival.Sub(epoch)
+		delta := (arrival - last) / (rtcp.TypeTCCDeltaScaleFactor * time.Microsecond) * (rtcp.TypeTCCDeltaScaleFactor * time.Microsecond)
+		last += delta
+
+		symbol := rtcp.TypeTCCPacketReceivedSmallDelta
+		if delta < 0 || delta > 255*rtcp.TypeTCCDeltaScaleFactor*time.Microsecond {
+			symbol = rtcp.TypeTCCPacketReceivedLargeDelta
+		}
+		symbols = append(symbols, symbol)
+		fb.RecvDeltas = append(fb.RecvDeltas, &rtcp.RecvDelta{Type: symbol, Delta: delta.Microseconds()})
+	}
+
+	for len(symbols) > 0 {
+		n := 7
+		if len(symbols) < n {
+			n = len(symbols)
+		}
+		fb.PacketChunks = append(fb.PacketChunks, &rtcp.StatusVectorChunk{
+			Type:       rtcp.TypeTCCStatusVectorChunk,
+			SymbolSize: rtcp.TypeTCCSymbolSizeTwoBit,
+			SymbolList: append([]uint16{}, symbols[:n]...),
+		})
+		symbols = symbols[n:]
+	}
+
+	return fb
+}
+
+func TestSendSideBWEConverges(t *testing.T) {
+	capacity := int64(1_500_000)
+	s := newLinkSimulator(t, 500_000, capacity)
+
+	s.run(25 * time.Second)
+	average := s.averageTarget(10 * time.Second)
+	require.Greater(t, average, capacity*7/10)
+	require.Less(t, average, capacity*11/10)
+
+	estimate := s.bwe.GetEstimate()
+	require.Greater(t, estimate.Acknowledged, capacity*6/10)
+	require.LessOrEqual(t, estimate.Acknowledged, capacity*11/10)
+}
+
+func TestSendSideBWEOverestimate(t *testing.T) {
+	// starting way above capacity should back off to the channel capacity quickly
+	capacity := int64(800_000)
+	s := newLinkSimulator(t, 5_000_000, capacity)
+
+	s.run(3 * time.Second)
+	require.Less(t, int64(s.bwe.GetTargetBitrate()), capacity*11/10)
+
+	average := s.averageTarget(10 * time.Second)
+	require.Greater(t, average, capacity*7/10)
+	require.Less(t, average, capacity*11/10)
+}
+
+func TestSendSideBWECapacityDrop(t *testing.T) {
+	s := newLinkSimulator(t, 1_500_000, 2_000_000)
+	s.run(15 * time.Second)
+	require.Greater(t, int64(s.bwe.GetTargetBitrate()), int64(1_400_000))
+
+	capacity := int64(700_000)
+	s.capacityBps = capacity
+	s.run(2 * time.Second)
+	require.Less(t, int64(s.bwe.GetTargetBitrate()), capacity*11/10)
+
+	average := s.averageTarget(10 * time.Second)
+	require.Greater(t, average, capacity*7/10)
+	require.Less(t, average, capacity*11/10)
+}
+
+func TestSendSideBWERandomLoss(t *testing.T) {
+	// capacity is not the limit, loss is
+	s := newLinkSimulator(t, 2_000_000, 50_000_000)
+	s.randomLoss = 0.25
+	s.run(10 * time.Second)
+
+	estimate := s.bwe.GetEstimate()
+	require.Less(t, estimate.Target, int64(500_000))
+	require.Equal(t, estimate.Target, estimate.LossBased)
+	require.Less(t, estimate.LossBased, estimate.DelayBased)
+
+	// recovers once loss goes away
+	s.randomLoss = 0
+	s.run(10 * time.Second)
+	require.Greater(t, int64(s.bwe.GetTargetBitrate()), 4*estimate.Target)
+}
+
+func TestSendSideBWECallbacks(t *testing.T) {
+	var estimates []Estimate
+	var targets []int
+	bwe := NewSendSideBWE(SendSideBWEParams{
+		Logger:         logger.Logger(logger.GetLogger()),
+		InitialBitrate: 1_000_000,
+		OnEstimate: func(estimate Estimate) {
+			estimates = append(estimates, estimate)
+		},
+	})
+	bwe.OnTargetBitrateChange(func(bitrate int) {
+		targets = append(targets, bitrate)
+	})
+
+	now := time.Unix(1000, 0)
+	bwe.now = func() time.Time { return now }
+
+	var packets []simPacket
+	for i := 0; i < 10; i++ {
+		bwe.onPacketSent(uint16(65530+i), testPacketSize)
+		packets = append(packets, simPacket{sn: uint16(65530 + i), arrival: now.Add(testPropagationDelay)})
+		now = now.Add(10 * time.Millisecond)
+	}
+	require.NoError(t, bwe.WriteRTCP([]rtcp.Packet{buildFeedback(time.Unix(1000, 0), packets, 0)}, nil))
+	require.Len(t, estimates, 1)
+	require.Len(t, targets, 1)
+
+	// same feedback again is not counted again
+	require.NoError(t, bwe.WriteRTCP([]rtcp.Packet{buildFeedback(time.Unix(1000, 0), packets, 0)}, nil))
+	require.Len(t, estimates, 1)
+
+	require.NoError(t, bwe.Close())
+	require.Len(t, estimates, 2)
+	require.Equal(t, Estimate{}, estimates[1])
+}
+
+func TestSendSideBWEParseFeedback(t *testing.T) {
+	bwe := NewSendSideBWE(SendSideBWEParams{Logger: logger.Logger(logger.GetLogger())})
+	now := time.Unix(1000, 0)
+	bwe.now = func() time.Time { return now }
+	for sn := uint16(100); sn < 110; sn++ {
+		bwe.onPacketSent(sn, 100+int(sn))
+	}
+
+	fb := &rtcp.TransportLayerCC{
+		BaseSequenceNumber: 100,
+		PacketStatusCount:  9,
+		ReferenceTime:      10,
+		PacketChunks: []rtcp.PacketStatusChunk{
+			&rtcp.RunLengthChunk{
+				Type:               rtcp.TypeTCCRunLengthChunk,
+				PacketStatusSymbol: rtcp.TypeTCCPacketReceivedSmallDelta,
+				RunLength:          3,
+			},
+			&rtcp.StatusVectorChunk{
+				Type:       rtcp.TypeTCCStatusVectorChunk,
+				SymbolSize: rtcp.TypeTCCSymbolSizeTwoBit,
+				SymbolList: []uint16{
+					rtcp.TypeTCCPacketNotReceived,
+					rtcp.TypeTCCPacketReceivedLargeDelta,
+					rtcp.TypeTCCPacketNotReceived,
+					rtcp.TypeTCCPacketReceivedSmallDelta,
+					rtcp.TypeTCCPacketReceivedSmallDelta,
+					rtcp.TypeTCCPacketReceivedSmallDelta,
+					rtcp.TypeTCCPacketReceivedSmallDelta, // beyond status count
+				},
+			},
+		},
+		RecvDeltas: []*rtcp.RecvDelta{
+			{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 1000},
+			{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 250},
+			{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 500},
+			{Type: rtcp.TypeTCCPacketReceivedLargeDelta, Delta: -2000},
+			{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 250},
+			{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 250},
+			{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 250},
+		},
+	}
+
+	results := bwe.parseFeedback(fb)
+	require.Len(t, results, 9)
+
+	base := 10 * referenceTimeUnit
+	expectedReceived := []bool{true, true, true, false, true, false, true, true, true}
+	expectedArrival := []time.Duration{
+		base + 1000*time.Microsecond,
+		base + 1250*time.Microsecond,
+		base + 1750*time.Microsecond,
+		0,
+		base - 250*time.Microsecond,
+		0,
+		base,
+		base + 250*time.Microsecond,
+		base + 500*time.Microsecond,
+	}
+	for i, r := range results {
+		require.Equal(t, expectedReceived[i], r.received, "packet %d", i)
+		require.Equal(t, 200+i, r.size)
+		if r.received {
+			require.Equal(t, expectedArrival[i], r.arrival, "packet %d", i)
+		}
+	}
+}
+
+func TestSendSideBWEAddStream(t *testing.T) {
+	bwe := NewSendSideBWE(SendSideBWEParams{Logger: logger.Logger(logger.GetLogger())})
+
+	written := 0
+	writer := interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		written++
+		return len(payload), nil
+	})
+
+	// not using transport wide sequence numbers, writer passes through
+	require.NotNil(t, bwe.AddStream(&interceptor.StreamInfo{SSRC: 1}, writer))
+
+	w := bwe.AddStream(&interceptor.StreamInfo{
+		SSRC:                2,
+		RTPHeaderExtensions: []interceptor.RTPHeaderExtension{{URI: sdp.TransportCCURI, ID: 3}},
+	}, writer)
+
+	ext, err := (&rtp.TransportCCExtension{TransportSequence: 42}).Marshal()
+	require.NoError(t, err)
+	header := &rtp.Header{Version: 2, SSRC: 2}
+	require.NoError(t, header.SetExtension(3, ext))
+
+	_, err = w.Write(header, make([]byte, 100), nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, written)
+
+	pi := bwe.history[42&packetHistoryMask]
+	require.True(t, pi.valid)
+	require.Equal(t, uint16(42), pi.sn)
+	require.Equal(t, header.MarshalSize()+100, pi.size)
+}
@@ -39,10 +39,19 @@ const (
 	ProbeMinDuration = 20 * time.Second
 	ProbeMaxDuration = 21 * time.Second
 
+	// share of the expected usage allocated when the pacer queue builds up
+	PacerQueueBuildupAttenuation = 0.8
+
+	// wait for the event loop to report state for debug info
+	DebugInfoTimeout = 500 * time.Millisecond
+
 	PriorityMin                = uint8(1)
 	PriorityMax                = uint8(255)
 	PriorityDefaultScreenshare = PriorityMax
 	PriorityDefaultVideo       = PriorityMin
+
+	// reserved for an audio track until its bitrate is measured
+	AudioBitrateDefault = 32 * 1000
 )
 
 type State int
@@ -77,6 +86,9 @@ const (
 	SignalPeriodicPing
 	SignalSendProbe
 	SignalProbeClusterDone
+	SignalSetMaxChannelCapacity
+	SignalPacerQueueBuildup
+	SignalDebugInfo
 )
 
 func (s Signal) String() string {
@@ -101,6 +113,12 @@ func (s Signal) String() string {
 		return "SEND_PROBE"
 	case SignalProbeClusterDone:
 		return "PROBE_CLUSTER_DONE"
+	case SignalSetMaxChannelCapacity:
+		return "SET_MAX_CHANNEL_CAPACITY"
+	case SignalPacerQueueBuildup:
+		return "PACER_QUEUE_BUILDUP"
+	case SignalDebugInfo:
+		return "DEBUG_INFO"
 	default:
 		return fmt.Sprintf("%d", int(s))
 	}
@@ -129,9 +147,14 @@ type StreamAllocator struct {
 	rembTrackingSSRC uint32
 
 	bwe cc.BandwidthEstimator
+	// paces the peer connection at a multiple of the channel capacity, nil when not pacing
+	pacer *Pacer
+	// set once the send side estimator starts reporting, REMB is ignored from then on
+	sendSideBWEActive bool
 
 	lastReceivedEstimate     int64
 	committedChannelCapacity int64
+	maxChannelCapacity       int64
 
 	probeInterval         time.Duration
 	lastProbeStartTime    time.Time
@@ -147,6 +170,7 @@ type StreamAllocator struct {
 	channelObserver *ChannelObserver
 
 	videoTracks map[livekit.TrackID]*Track
+	audioTracks map[livekit.TrackID]*Track
 
 	state State
 
@@ -160,10 +184,12 @@ func NewStreamAllocator(params StreamAllocatorParams) *StreamAllocator {
 	s := &StreamAllocator{
 		params: params,
 		prober: NewProber(ProberParams{
-			Logger: params.Logger,
+			Logger:          params.Logger,
+			MaxProbeRateBps: int(params.Config.ProbeMaxBitrate),
 		}),
 		channelObserver: NewChannelObserver("non-probe", params.Logger, NumRequiredEstimatesNonProbe, NackRatioThresholdNonProbe),
 		videoTracks:     make(map[livekit.TrackID]*Track),
+		audioTracks:     make(map[livekit.TrackID]*Track),
 		eventCh:         make(chan Event, 20),
 	}
 
@@ -202,6 +228,14 @@ func (s *StreamAllocator) SetBandwidthEstimator(bwe cc.BandwidthEstimator) {
 	s.bwe = bwe
 }
 
+// SetPacer keeps the pacing rate in line with the channel capacity and downgrades when the pacer queue builds up
+func (s *StreamAllocator) SetPacer(pacer *Pacer) {
+	if pacer != nil {
+		pacer.OnQueueBuildup(s.onPacerQueueBuildup)
+	}
+	s.pacer = pacer
+}
+
 type AddTrackParams struct {
 	Source      livekit.TrackSource
 	Priority    uint8
@@ -241,6 +275,35 @@ func (s *StreamAllocator) SetTrackPriority(downTrack *DownTrack, priority uint8)
 	})
 }
 
+// SetMaxChannelCapacity limits the bitrate distributed across tracks, irrespective of the channel capacity estimate.
+// Tracks are re-allocated on change. A value of 0 removes the limit.
+func (s *StreamAllocator) SetMaxChannelCapacity(maxChannelCapacity int64) {
+	s.postEvent(Event{
+		Signal: SignalSetMaxChannelCapacity,
+		Data:   maxChannelCapacity,
+	})
+}
+
+// DebugInfo returns the channel capacity and probe state, nil if the allocator does not respond in time
+func (s *StreamAllocator) DebugInfo() map[string]interface{} {
+	if s.isStopped.Load() {
+		return nil
+	}
+
+	infoCh := make(chan map[string]interface{}, 1)
+	s.postEvent(Event{
+		Signal: SignalDebugInfo,
+		Data:   infoCh,
+	})
+
+	select {
+	case info := <-infoCh:
+		return info
+	case <-time.After(DebugInfoTimeout):
+		return nil
+	}
+}
+
 func (s *StreamAllocator) resetState() {
 	s.channelObserver.Reset()
 	s.resetProbe()
@@ -272,6 +335,14 @@ func (s *StreamAllocator) onTargetBitrateChange(bitrate int) {
 	})
 }
 
+// called when video has been waiting in the pacer longer than its max queue delay
+func (s *StreamAllocator) onPacerQueueBuildup(queueDelay time.Duration) {
+	s.postEvent(Event{
+		Signal: SignalPacerQueueBuildup,
+		Data:   queueDelay,
+	})
+}
+
 // called when feeding track's layer availability changes
 func (s *StreamAllocator) onAvailableLayersChanged(downTrack *DownTrack) {
 	s.postEvent(Event{
@@ -374,30 +445,38 @@ func (s *StreamAllocator) handleEvent(event *Event) {
 		s.handleSignalSendProbe(event)
 	case SignalProbeClusterDone:
 		s.handleSignalProbeClusterDone(event)
+	case SignalSetMaxChannelCapacity:
+		s.handleSignalSetMaxChannelCapacity(event)
+	case SignalPacerQueueBuildup:
+		s.handleSignalPacerQueueBuildup(event)
+	case SignalDebugInfo:
+		s.handleSignalDebugInfo(event)
 	}
 }
 
 func (s *StreamAllocator) handleSignalAddTrack(event *Event) {
-	if event.DownTrack.Kind() != webrtc.RTPCodecTypeVideo {
-		return
-	}
-
 	params, _ := event.Data.(AddTrackParams)
 	track := newTrack(event.DownTrack, params.Source, params.IsSimulcast, params.PublisherID, s.params.Logger)
 	track.SetPriority(params.Priority)
 
 	trackID := livekit.TrackID(event.DownTrack.ID())
+	if event.DownTrack.Kind() != webrtc.RTPCodecTypeVideo {
+		// audio is not managed, its bandwidth is only set aside ahead of video
+		s.audioTracks[trackID] = track
+		return
+	}
 	s.videoTracks[trackID] = track
 
 	s.allocateTrack(track)
 }
 
 func (s *StreamAllocator) handleSignalRemoveTrack(event *Event) {
+	trackID := livekit.TrackID(event.DownTrack.ID())
 	if event.DownTrack.Kind() != webrtc.RTPCodecTypeVideo {
+		delete(s.audioTracks, trackID)
 		return
 	}
 
-	trackID := livekit.TrackID(event.DownTrack.ID())
 	delete(s.videoTracks, trackID)
 
 	// re-initialize estimate if all managed tracks are removed, let it get a fresh start
@@ -460,6 +539,11 @@ func (s *StreamAllocator) handleSignalEstimate(event *Event) {
 		return
 	}
 
+	// subscriber negotiated transport-cc, estimates come from the send side estimator
+	if s.sendSideBWEActive {
+		return
+	}
+
 	remb, _ := event.Data.(*rtcp.ReceiverEstimatedMaximumBitrate)
 
 	found := false
@@ -497,6 +581,11 @@ func (s *StreamAllocator) handleSignalEstimate(event *Event) {
 }
 
 func (s *StreamAllocator) handleSignalTargetBitrate(event *Event) {
+	if !s.sendSideBWEActive {
+		s.params.Logger.Debugw("send side bandwidth estimation active, ignoring REMB")
+		s.sendSideBWEActive = true
+	}
+
 	receivedEstimate, _ := event.Data.(int)
 	s.handleNewEstimate(int64(receivedEstimate))
 }
@@ -540,6 +629,11 @@ func (s *StreamAllocator) handleSignalPeriodicPing(event *Event) {
 	// catch up on all optimistically streamed tracks
 	s.finalizeTracks()
 
+	now := time.Now()
+	for _, track := range s.audioTracks {
+		track.UpdateBitrate(now)
+	}
+
 	// probe if necessary and timing is right
 	if s.state == StateDeficient {
 		s.maybeProbe()
@@ -591,6 +685,115 @@ func (s *StreamAllocator) handleSignalProbeClusterDone(event *Event) {
 	s.probeEndTime = s.lastProbeStartTime.Add(queueWait)
 }
 
+func (s *StreamAllocator) handleSignalSetMaxChannelCapacity(event *Event) {
+	maxChannelCapacity, _ := event.Data.(int64)
+	if maxChannelCapacity < 0 {
+		maxChannelCapacity = 0
+	}
+	if s.maxChannelCapacity == maxChannelCapacity {
+		return
+	}
+
+	s.params.Logger.Infow(
+		"updating max channel capacity",
+		"old(bps)", s.maxChannelCapacity,
+		"new(bps)", maxChannelCapacity,
+	)
+	s.maxChannelCapacity = maxChannelCapacity
+
+	s.abortProbe()
+
+	if s.maxChannelCapacity == 0 && (!s.params.Config.Enabled || s.committedChannelCapacity == 0) {
+		// no limit and no estimate to go by, free pass allocate all tracks
+		s.updatePacingRate()
+		update := NewStreamStateUpdate()
+		for _, track := range s.videoTracks {
+			allocation := track.Allocate(ChannelCapacityInfinity, s.params.Config.AllowPause)
+			update.HandleStreamingChange(allocation.change, track)
+		}
+		s.maybeSendUpdate(update)
+
+		s.adjustState()
+		return
+	}
+
+	s.allocateAllTracks()
+}
+
+func (s *StreamAllocator) handleSignalPacerQueueBuildup(event *Event) {
+	if !s.params.Config.Enabled {
+		return
+	}
+
+	// more is forwarded than the channel carries, allocate below what is expected to be sent
+	queueDelay, _ := event.Data.(time.Duration)
+	expectedBandwidthUsage := s.getExpectedBandwidthUsage()
+	channelCapacity := int64(float64(expectedBandwidthUsage) * PacerQueueBuildupAttenuation)
+	if channelCapacity == 0 || (s.committedChannelCapacity != 0 && channelCapacity >= s.committedChannelCapacity) {
+		return
+	}
+
+	s.params.Logger.Infow(
+		"pacer queue building up, updating channel capacity",
+		"old(bps)", s.committedChannelCapacity,
+		"new(bps)", channelCapacity,
+		"expectedUsage(bps)", expectedBandwidthUsage,
+		"queueDelay", queueDelay,
+	)
+	s.committedChannelCapacity = channelCapacity
+
+	// reset to get new set of samples for next trend
+	s.channelObserver.Reset()
+
+	// stop probing and ensure it does not start too soon after a downgrade
+	s.stopProbe()
+	s.resetProbe()
+
+	s.allocateAllTracks()
+}
+
+func (s *StreamAllocator) handleSignalDebugInfo(event *Event) {
+	infoCh, _ := event.Data.(chan map[string]interface{})
+	if infoCh == nil {
+		return
+	}
+
+	minDuration, maxDuration := s.getProbeDuration()
+	probe := map[string]interface{}{
+		"Active":          s.isInProbe(),
+		"ClusterID":       s.probeClusterId,
+		"GoalBps":         s.probeGoalBps,
+		"Interval":        s.probeInterval.String(),
+		"LastStart":       s.lastProbeStartTime,
+		"MinDuration":     minDuration.String(),
+		"MaxDuration":     maxDuration.String(),
+		"MaxProbeRateBps": s.params.Config.ProbeMaxBitrate,
+		"ProberRunning":   s.prober.IsRunning(),
+	}
+	if s.probeChannelObserver != nil {
+		probe["HighestEstimate"] = s.probeChannelObserver.GetHighestEstimate()
+	}
+
+	estimator := map[string]interface{}{
+		"SendSideBWEActive": s.sendSideBWEActive,
+	}
+	if s.bwe != nil && s.sendSideBWEActive {
+		estimator["SendSideBWE"] = s.bwe.GetStats()
+	}
+
+	infoCh <- map[string]interface{}{
+		"State":                    s.state.String(),
+		"Estimator":                estimator,
+		"CommittedChannelCapacity": s.committedChannelCapacity,
+		"MaxChannelCapacity":       s.maxChannelCapacity,
+		"LastReceivedEstimate":     s.lastReceivedEstimate,
+		"ExpectedBandwidthUsage":   s.getExpectedBandwidthUsage(),
+		"AudioBandwidth":           s.getAudioBandwidth(),
+		"AllocationStrategy":       s.getAllocationStrategy(),
+		"Probe":                    probe,
+	}
+}
+
 func (s *StreamAllocator) setState(state State) {
 	if s.state == state {
 		return
@@ -701,6 +904,12 @@ func (s *StreamAllocator) allocateTrack(track *Track) {
 
 	// if not deficient, free pass allocate track
 	if !s.params.Config.Enabled || s.state == StateStable || !track.IsManaged() {
+		if s.maxChannelCapacity > 0 && track.IsManaged() {
+			// capped, the track has to fit in with the others
+			s.allocateAllTracks()
+			return
+		}
+
 		update := NewStreamStateUpdate()
 		allocation := track.Allocate(ChannelCapacityInfinity, s.params.Config.AllowPause)
 		update.HandleStreamingChange(allocation.change, track)
@@ -827,7 +1036,7 @@ func (s *StreamAllocator) finalizeProbe() {
 	)
 	s.committedChannelCapacity = highestEstimateInProbe
 
-	availableChannelCapacity := s.committedChannelCapacity - s.getExpectedBandwidthUsage()
+	availableChannelCapacity := s.getChannelCapacity() - s.getExpectedBandwidthUsage()
 	if availableChannelCapacity <= 0 {
 		return
 	}
@@ -854,7 +1063,9 @@ func (s *StreamAllocator) finalizeProbe() {
 }
 
 func (s *StreamAllocator) allocateAllTracks() {
-	if !s.params.Config.Enabled {
+	s.updatePacingRate()
+
+	if !s.params.Config.Enabled && s.maxChannelCapacity == 0 {
 		// nothing else to do when disabled
 		return
 	}
@@ -864,16 +1075,19 @@ func (s *StreamAllocator) allocateAllTracks() {
 	//   1. Stream as many tracks as possible, i.e. no pauses.
 	//   2. Try to give fair allocation to all track.
 	//
+	// Audio is served first by setting aside what audio tracks are sending, then screen share.
+	//
 	// Start with the lowest layers and give each track a chance at that layer and keep going up.
 	// As long as there is enough bandwidth for tracks to stream at the lowest layers, the first goal is achieved.
 	//
-	// Tracks that have higher subscribed layers can use any additional available bandwidth. This tried to achieve the second goal.
+	// Tracks that have higher subscribed layers can use any additional available bandwidth. How that is shared
+	// depends on the allocation strategy, see provisionalAllocate. This tries to achieve the second goal.
 	//
 	// If there is not enough bandwidth even for the lowest layers, tracks at lower priorities will be paused.
 	//
 	update := NewStreamStateUpdate()
 
-	availableChannelCapacity := s.committedChannelCapacity
+	availableChannelCapacity := s.getChannelCapacity() - s.getAudioBandwidth()
 
 	//
 	// This pass is find out if there is any leftover channel capacity after allocating exempt tracks.
@@ -910,22 +1124,7 @@ func (s *StreamAllocator) allocateAllTracks() {
 			track.ProvisionalAllocatePrepare()
 		}
 
-		for spatial := int32(0); spatial <= DefaultMaxLayerSpatial; spatial++ {
-			for temporal := int32(0); temporal <= DefaultMaxLayerTemporal; temporal++ {
-				layers := VideoLayers{
-					spatial:  spatial,
-					temporal: temporal,
-				}
-
-				for _, track := range sorted {
-					usedChannelCapacity := track.ProvisionalAllocate(availableChannelCapacity, layers, s.params.Config.AllowPause)
-					availableChannelCapacity -= usedChannelCapacity
-					if availableChannelCapacity < 0 {
-						availableChannelCapacity = 0
-					}
-				}
-			}
-		}
+		s.provisionalAllocate(sorted, availableChannelCapacity)
 
 		for _, track := range sorted {
 			allocation := track.ProvisionalAllocateCommit()
@@ -938,6 +1137,85 @@ func (s *StreamAllocator) allocateAllTracks() {
 	s.adjustState()
 }
 
+// provisionalAllocate distributes the available channel capacity across the sorted managed tracks
+// according to the allocation strategy
+func (s *StreamAllocator) provisionalAllocate(sorted TrackSorter, availableChannelCapacity int64) {
+	switch s.getAllocationStrategy() {
+	case config.CongestionControlAllocationStrategyPriorityBySize:
+		// everybody streams before larger tracks get their full layers
+		availableChannelCapacity = s.provisionalAllocateLayers(sorted, availableChannelCapacity, VideoLayers{spatial: 0, temporal: 0})
+
+		sizeSorter := make(SizeSorter, len(sorted))
+		copy(sizeSorter, sorted)
+		sort.Sort(sizeSorter)
+		for _, track := range sizeSorter {
+			availableChannelCapacity = s.provisionalAllocateAllLayers([]*Track{track}, availableChannelCapacity)
+		}
+
+	case config.CongestionControlAllocationStrategyPinned:
+		// everybody streams before pinned tracks get their full layers, the rest share what is left
+		availableChannelCapacity = s.provisionalAllocateLayers(sorted, availableChannelCapacity, VideoLayers{spatial: 0, temporal: 0})
+
+		var others []*Track
+		for _, track := range sorted {
+			if !track.IsPinned() {
+				others = append(others, track)
+				continue
+			}
+
+			availableChannelCapacity = s.provisionalAllocateAllLayers([]*Track{track}, availableChannelCapacity)
+		}
+		s.provisionalAllocateAllLayers(others, availableChannelCapacity)
+
+	default:
+		s.provisionalAllocateAllLayers(sorted, availableChannelCapacity)
+	}
+}
+
+// provisionalAllocateAllLayers goes up the layers, giving each track a chance at a layer before moving on to the next one.
+// Returns the channel capacity left.
+func (s *StreamAllocator) provisionalAllocateAllLayers(tracks []*Track, availableChannelCapacity int64) int64 {
+	maxSpatial := int32(DefaultMaxLayerSpatial)
+	for _, track := range tracks {
+		if track.maxLayers.spatial > maxSpatial {
+			maxSpatial = track.maxLayers.spatial
+		}
+	}
+
+	for spatial := int32(0); spatial <= maxSpatial; spatial++ {
+		for temporal := int32(0); temporal <= DefaultMaxLayerTemporal; temporal++ {
+			layers := VideoLayers{
+				spatial:  spatial,
+				temporal: temporal,
+			}
+
+			availableChannelCapacity = s.provisionalAllocateLayers(tracks, availableChannelCapacity, layers)
+		}
+	}
+
+	return availableChannelCapacity
+}
+
+func (s *StreamAllocator) provisionalAllocateLayers(tracks []*Track, availableChannelCapacity int64, layers VideoLayers) int64 {
+	for _, track := range tracks {
+		usedChannelCapacity := track.ProvisionalAllocate(availableChannelCapacity, layers, s.params.Config.AllowPause)
+		availableChannelCapacity -= usedChannelCapacity
+		if availableChannelCapacity < 0 {
+			availableChannelCapacity = 0
+		}
+	}
+
+	return availableChannelCapacity
+}
+
+func (s *StreamAllocator) getAllocationStrategy() config.CongestionControlAllocationStrategy {
+	if s.params.Config.AllocationStrategy == "" {
+		return config.CongestionControlAllocationStrategyEqual
+	}
+
+	return s.params.Config.AllocationStrategy
+}
+
 func (s *StreamAllocator) maybeSendUpdate(update *StreamStateUpdate) {
 	if update.Empty() {
 		return
@@ -960,8 +1238,33 @@ func (s *StreamAllocator) finalizeTracks() {
 	s.adjustState()
 }
 
+// getChannelCapacity returns the channel capacity to allocate against, the committed estimate limited by the max
+func (s *StreamAllocator) getChannelCapacity() int64 {
+	if s.maxChannelCapacity == 0 {
+		return s.committedChannelCapacity
+	}
+
+	if !s.params.Config.Enabled || s.committedChannelCapacity == 0 || s.committedChannelCapacity > s.maxChannelCapacity {
+		return s.maxChannelCapacity
+	}
+
+	return s.committedChannelCapacity
+}
+
+func (s *StreamAllocator) updatePacingRate() {
+	if s.pacer == nil {
+		return
+	}
+
+	if !s.params.Config.Enabled && s.maxChannelCapacity == 0 {
+		s.pacer.SetChannelCapacity(0)
+		return
+	}
+	s.pacer.SetChannelCapacity(s.getChannelCapacity())
+}
+
 func (s *StreamAllocator) getExpectedBandwidthUsage() int64 {
-	expected := int64(0)
+	expected := s.getAudioBandwidth()
 	for _, track := range s.videoTracks {
 		expected += track.BandwidthRequested()
 	}
@@ -969,6 +1272,16 @@ func (s *StreamAllocator) getExpectedBandwidthUsage() int64 {
 	return expected
 }
 
+// getAudioBandwidth returns the bandwidth set aside for audio tracks, which is what they were last measured sending
+func (s *StreamAllocator) getAudioBandwidth() int64 {
+	audioBandwidth := int64(0)
+	for _, track := range s.audioTracks {
+		audioBandwidth += track.Bitrate()
+	}
+
+	return audioBandwidth
+}
+
 func (s *StreamAllocator) getNackDelta() (uint32, uint32) {
 	aggPacketDelta := uint32(0)
 	aggRepeatedNackDelta := uint32(0)
@@ -1016,14 +1329,38 @@ func (s *StreamAllocator) clearProbe() {
 }
 
 func (s *StreamAllocator) backoffProbeInterval() {
-	s.probeInterval = time.Duration(s.probeInterval.Seconds()*ProbeBackoffFactor) * time.Second
-	if s.probeInterval > ProbeWaitMax {
-		s.probeInterval = ProbeWaitMax
+	backoffFactor := s.params.Config.ProbeBackoffFactor
+	if backoffFactor == 0 {
+		backoffFactor = ProbeBackoffFactor
+	}
+	probeWaitMax := ProbeWaitMax
+	if probeWaitMax < s.getProbeWaitBase() {
+		probeWaitMax = s.getProbeWaitBase()
+	}
+
+	s.probeInterval = time.Duration(float64(s.probeInterval) * backoffFactor)
+	if s.probeInterval > probeWaitMax {
+		s.probeInterval = probeWaitMax
 	}
 }
 
 func (s *StreamAllocator) resetProbeInterval() {
-	s.probeInterval = ProbeWaitBase
+	s.probeInterval = s.getProbeWaitBase()
+}
+
+func (s *StreamAllocator) getProbeWaitBase() time.Duration {
+	if probeInterval := s.params.Config.ProbeInterval.Duration(); probeInterval > 0 {
+		return probeInterval
+	}
+	return ProbeWaitBase
+}
+
+// getProbeDuration returns the min and max duration of a probe cluster
+func (s *StreamAllocator) getProbeDuration() (time.Duration, time.Duration) {
+	if probeDuration := s.params.Config.ProbeDuration.Duration(); probeDuration > 0 {
+		return probeDuration, probeDuration + ProbeMaxDuration - ProbeMinDuration
+	}
+	return ProbeMinDuration, ProbeMaxDuration
 }
 
 func (s *StreamAllocator) stopProbe() {
@@ -1044,6 +1381,11 @@ func (s *StreamAllocator) maybeProbe() {
 		return
 	}
 
+	if s.maxChannelCapacity > 0 && s.getChannelCapacity() >= s.maxChannelCapacity {
+		// deficient because of the cap, probing for more would not help
+		return
+	}
+
 	switch s.params.Config.ProbeMode {
 	case config.CongestionControlProbeModeMedia:
 		s.maybeProbeWithMedia()
@@ -1083,12 +1425,13 @@ func (s *StreamAllocator) maybeProbeWithPadding() {
 			probeRateBps = ProbeMinBps
 		}
 
+		minDuration, maxDuration := s.getProbeDuration()
 		s.initProbe(s.getExpectedBandwidthUsage() + probeRateBps)
 		s.probeClusterId = s.prober.AddCluster(
 			int(s.committedChannelCapacity+probeRateBps),
 			int(s.getExpectedBandwidthUsage()),
-			ProbeMinDuration,
-			ProbeMaxDuration,
+			minDuration,
+			maxDuration,
 		)
 		break
 	}
@@ -1197,6 +1540,11 @@ type Track struct {
 
 	totalPackets       uint32
 	totalRepeatedNacks uint32
+
+	// measured send rate, for tracks that are not allocated
+	bytesSent   uint64
+	bitrate     int64
+	bitrateTime time.Time
 }
 
 func newTrack(
@@ -1247,6 +1595,15 @@ func (t *Track) DownTrack() *DownTrack {
 	return t.downTrack
 }
 
+func (t *Track) IsScreenshare() bool {
+	return t.source == livekit.TrackSource_SCREEN_SHARE || t.source == livekit.TrackSource_SCREEN_SHARE_AUDIO
+}
+
+// IsPinned returns true when the priority of a video track has been raised above the default
+func (t *Track) IsPinned() bool {
+	return !t.IsScreenshare() && t.priority > PriorityDefaultVideo
+}
+
 func (t *Track) IsManaged() bool {
 	return (t.source != livekit.TrackSource_SCREEN_SHARE && t.source != livekit.TrackSource_SCREEN_SHARE_AUDIO) || t.isSimulcast
 }
@@ -1319,6 +1676,28 @@ func (t *Track) DistanceToDesired() int32 {
 	return t.downTrack.DistanceToDesired()
 }
 
+// UpdateBitrate measures the bitrate sent since the previous update
+func (t *Track) UpdateBitrate(now time.Time) {
+	bytesSent := t.downTrack.GetBytesSent()
+	if !t.bitrateTime.IsZero() {
+		if elapsed := now.Sub(t.bitrateTime); elapsed > 0 {
+			t.bitrate = int64(float64((bytesSent-t.bytesSent)*8) / elapsed.Seconds())
+		}
+	}
+
+	t.bytesSent = bytesSent
+	t.bitrateTime = now
+}
+
+// Bitrate returns the last measured bitrate, AudioBitrateDefault till the track has sent anything
+func (t *Track) Bitrate() int64 {
+	if t.bytesSent == 0 {
+		return AudioBitrateDefault
+	}
+
+	return t.bitrate
+}
+
 func (t *Track) GetNackDelta() (uint32, uint32) {
 	totalPackets, totalRepeatedNacks := t.downTrack.GetNackStats()
 
@@ -1361,6 +1740,38 @@ func (t TrackSorter) Less(i, j int) bool {
 
 // ------------------------------------------------
 
+type SizeSorter []*Track
+
+func (s SizeSorter) Len() int {
+	return len(s)
+}
+
+func (s SizeSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s SizeSorter) Less(i, j int) bool {
+	//
+	// SizeSorter is used to allocate track-by-track.
+	// Screen share comes first, then larger tracks, priority only breaks ties
+	//
+	if s[i].IsScreenshare() != s[j].IsScreenshare() {
+		return s[i].IsScreenshare()
+	}
+
+	if s[i].maxLayers.spatial != s[j].maxLayers.spatial {
+		return s[i].maxLayers.spatial > s[j].maxLayers.spatial
+	}
+
+	if s[i].maxLayers.temporal != s[j].maxLayers.temporal {
+		return s[i].maxLayers.temporal > s[j].maxLayers.temporal
+	}
+
+	return s[i].priority > s[j].priority
+}
+
+// ------------------------------------------------
+
 type MaxDistanceSorter []*Track
 
 func (m MaxDistanceSorter) Len() int {
@@ -0,0 +1,191 @@
+package sfu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/sfu/testutils"
+)
+
+// layer bitrates of the simulcast tracks in the allocation tests
+var allocationTestBitrates = Bitrates{
+	{100_000, 150_000, 200_000},
+	{300_000, 400_000, 500_000},
+	{800_000, 1_000_000, 1_200_000},
+}
+
+type allocationTestReceiver struct {
+	trackID  livekit.TrackID
+	bitrates Bitrates
+}
+
+func (r *allocationTestReceiver) TrackID() livekit.TrackID                     { return r.trackID }
+func (r *allocationTestReceiver) StreamID() string                             { return string(r.trackID) }
+func (r *allocationTestReceiver) Codec() webrtc.RTPCodecCapability             { return testutils.TestVP8Codec }
+func (r *allocationTestReceiver) GetBitrateTemporalCumulative() Bitrates       { return r.bitrates }
+func (r *allocationTestReceiver) MaxSpatialLayer() int32                       { return DefaultMaxLayerSpatial }
+func (r *allocationTestReceiver) SendPLI(layer int32)                          {}
+func (r *allocationTestReceiver) SetUpTrackPaused(paused bool)                 {}
+func (r *allocationTestReceiver) SetMaxExpectedSpatialLayer(layer int32)       {}
+func (r *allocationTestReceiver) AddDownTrack(track TrackSender) error         { return nil }
+func (r *allocationTestReceiver) DeleteDownTrack(peerID livekit.ParticipantID) {}
+func (r *allocationTestReceiver) DebugInfo() map[string]interface{}            { return nil }
+
+func (r *allocationTestReceiver) ReadRTP(buf []byte, layer uint8, sn uint16) (int, error) {
+	return 0, nil
+}
+
+func (r *allocationTestReceiver) GetSenderReportTime(layer int32) (uint32, uint64) {
+	return 0, 0
+}
+
+func (r *allocationTestReceiver) GetCachedKeyFrame(layer int32) []*buffer.ExtPacket {
+	return nil
+}
+
+func newStreamAllocatorForTest(strategy config.CongestionControlAllocationStrategy) *StreamAllocator {
+	return NewStreamAllocator(StreamAllocatorParams{
+		Config: config.CongestionControlConfig{
+			Enabled:            true,
+			AllowPause:         true,
+			AllocationStrategy: strategy,
+		},
+		Logger: logger.Logger(logger.GetLogger()),
+	})
+}
+
+func addAllocationTestTrack(t *testing.T, s *StreamAllocator, trackID livekit.TrackID, params AddTrackParams, maxSpatial int32) *DownTrack {
+	codec := testutils.TestVP8Codec
+	if params.Source == livekit.TrackSource_MICROPHONE {
+		codec = testutils.TestOpusCodec
+	}
+	dt, err := NewDownTrack(codec, &allocationTestReceiver{trackID: trackID, bitrates: allocationTestBitrates}, nil, "sub", 1500, logger.Logger(logger.GetLogger()))
+	require.NoError(t, err)
+	dt.SetMaxSpatialLayer(maxSpatial)
+
+	s.handleEvent(&Event{
+		Signal:    SignalAddTrack,
+		DownTrack: dt,
+		Data:      params,
+	})
+	return dt
+}
+
+func allocateForTest(s *StreamAllocator, channelCapacity int64) {
+	s.committedChannelCapacity = channelCapacity
+	s.allocateAllTracks()
+}
+
+func TestStreamAllocatorAllocationStrategy(t *testing.T) {
+	camera := AddTrackParams{Source: livekit.TrackSource_CAMERA, IsSimulcast: true, PublisherID: "pub"}
+
+	t.Run("equal", func(t *testing.T) {
+		s := newStreamAllocatorForTest(config.CongestionControlAllocationStrategyEqual)
+		large := addAllocationTestTrack(t, s, "large", camera, 2)
+		small := addAllocationTestTrack(t, s, "small", camera, 1)
+
+		allocateForTest(s, 900_000)
+		require.Equal(t, VideoLayers{spatial: 1, temporal: 2}, large.forwarder.TargetLayers())
+		require.Equal(t, VideoLayers{spatial: 1, temporal: 1}, small.forwarder.TargetLayers())
+	})
+
+	t.Run("priority by size", func(t *testing.T) {
+		s := newStreamAllocatorForTest(config.CongestionControlAllocationStrategyPriorityBySize)
+		large := addAllocationTestTrack(t, s, "large", camera, 2)
+		small := addAllocationTestTrack(t, s, "small", camera, 1)
+
+		allocateForTest(s, 900_000)
+		require.Equal(t, VideoLayers{spatial: 2, temporal: 0}, large.forwarder.TargetLayers())
+		require.Equal(t, VideoLayers{spatial: 0, temporal: 0}, small.forwarder.TargetLayers())
+	})
+
+	t.Run("pinned", func(t *testing.T) {
+		s := newStreamAllocatorForTest(config.CongestionControlAllocationStrategyPinned)
+		large := addAllocationTestTrack(t, s, "large", camera, 2)
+		pinned := addAllocationTestTrack(t, s, "pinned", camera, 1)
+		s.handleEvent(&Event{
+			Signal:    SignalSetTrackPriority,
+			DownTrack: pinned,
+			Data:      uint8(10),
+		})
+
+		allocateForTest(s, 900_000)
+		require.Equal(t, VideoLayers{spatial: 1, temporal: 2}, pinned.forwarder.TargetLayers())
+		require.Equal(t, VideoLayers{spatial: 1, temporal: 1}, large.forwarder.TargetLayers())
+	})
+
+	t.Run("screen share before larger cameras", func(t *testing.T) {
+		s := newStreamAllocatorForTest(config.CongestionControlAllocationStrategyPriorityBySize)
+		large := addAllocationTestTrack(t, s, "large", camera, 2)
+		screen := addAllocationTestTrack(t, s, "screen", AddTrackParams{Source: livekit.TrackSource_SCREEN_SHARE, IsSimulcast: true, PublisherID: "pub"}, 1)
+
+		allocateForTest(s, 700_000)
+		require.Equal(t, VideoLayers{spatial: 1, temporal: 2}, screen.forwarder.TargetLayers())
+		require.Equal(t, VideoLayers{spatial: 0, temporal: 2}, large.forwarder.TargetLayers())
+	})
+
+	t.Run("audio is set aside", func(t *testing.T) {
+		s := newStreamAllocatorForTest(config.CongestionControlAllocationStrategyEqual)
+		large := addAllocationTestTrack(t, s, "large", camera, 2)
+		small := addAllocationTestTrack(t, s, "small", camera, 1)
+		addAllocationTestTrack(t, s, "audio", AddTrackParams{Source: livekit.TrackSource_MICROPHONE, PublisherID: "pub"}, 0)
+		require.Equal(t, int64(AudioBitrateDefault), s.getAudioBandwidth())
+
+		allocateForTest(s, 900_000+AudioBitrateDefault)
+		require.Equal(t, VideoLayers{spatial: 1, temporal: 2}, large.forwarder.TargetLayers())
+		require.Equal(t, VideoLayers{spatial: 1, temporal: 1}, small.forwarder.TargetLayers())
+
+		allocateForTest(s, 900_000)
+		require.Equal(t, VideoLayers{spatial: 1, temporal: 1}, large.forwarder.TargetLayers())
+	})
+
+	t.Run("pacer queue buildup downgrades", func(t *testing.T) {
+		s := newStreamAllocatorForTest(config.CongestionControlAllocationStrategyEqual)
+		large := addAllocationTestTrack(t, s, "large", camera, 2)
+		small := addAllocationTestTrack(t, s, "small", camera, 1)
+
+		allocateForTest(s, 2_000_000)
+		require.Equal(t, VideoLayers{spatial: 2, temporal: 2}, large.forwarder.TargetLayers())
+		require.Equal(t, VideoLayers{spatial: 1, temporal: 2}, small.forwarder.TargetLayers())
+
+		// 1.7 Mbps expected, allocated at 1.36 Mbps
+		s.handleEvent(&Event{
+			Signal: SignalPacerQueueBuildup,
+			Data:   time.Second,
+		})
+		require.Equal(t, int64(1_360_000), s.committedChannelCapacity)
+		require.Equal(t, VideoLayers{spatial: 2, temporal: 0}, large.forwarder.TargetLayers())
+		require.Equal(t, VideoLayers{spatial: 1, temporal: 2}, small.forwarder.TargetLayers())
+	})
+
+	t.Run("pause is the last resort", func(t *testing.T) {
+		s := newStreamAllocatorForTest(config.CongestionControlAllocationStrategyPriorityBySize)
+		large := addAllocationTestTrack(t, s, "large", camera, 2)
+		small := addAllocationTestTrack(t, s, "small", camera, 1)
+
+		var updates []*StreamStateUpdate
+		s.OnStreamStateChange(func(update *StreamStateUpdate) error {
+			updates = append(updates, update)
+			return nil
+		})
+
+		allocateForTest(s, 150_000)
+		require.Equal(t, VideoLayers{spatial: 0, temporal: 1}, large.forwarder.TargetLayers())
+		require.Equal(t, InvalidLayers, small.forwarder.TargetLayers())
+		require.Len(t, updates, 1)
+		require.Equal(t, []*StreamStateInfo{{ParticipantID: "pub", TrackID: "small", State: StreamStatePaused}}, updates[0].StreamStates)
+
+		allocateForTest(s, 200_000)
+		require.Equal(t, VideoLayers{spatial: 0, temporal: 0}, large.forwarder.TargetLayers())
+		require.Equal(t, VideoLayers{spatial: 0, temporal: 0}, small.forwarder.TargetLayers())
+		require.Len(t, updates, 2)
+		require.Equal(t, StreamStateActive, updates[1].StreamStates[0].State)
+	})
+}
@@ -27,6 +27,44 @@ const (
 	StreamStatusActive  StreamStatus = 1
 )
 
+// StreamIssueReason is why a stream that keeps flowing cannot be rendered by subscribers
+type StreamIssueReason int
+
+const (
+	// no key frame within the frozen timeout, e.g. an encoder sending delta frames after losing its reference
+	StreamIssueReasonNoKeyFrame StreamIssueReason = iota
+)
+
+func (r StreamIssueReason) String() string {
+	switch r {
+	case StreamIssueReasonNoKeyFrame:
+		return "no_key_frame"
+	default:
+		return "unknown"
+	}
+}
+
+// StreamIssue is reported once per timeout for as long as the issue lasts
+type StreamIssue struct {
+	Reason StreamIssueReason
+	// the issue was reported before and has not been resolved since
+	Persistent bool
+}
+
+// StreamTrackerDiagnostics is a snapshot of the detection state, for debugging
+type StreamTrackerDiagnostics struct {
+	Status StreamStatus
+	Paused bool
+	// zero if no packet has been seen
+	LastPacketAt time.Time
+	// packets seen in the cycle in progress
+	PacketsInCycle uint32
+	// cycles evaluated since the stream started
+	CyclesCompleted uint64
+	// consecutive cycles with enough packets, the stream is declared active once it reaches the cycles required
+	ActiveCycles uint64
+}
+
 // StreamTracker keeps track of packet flow and ensures a particular up track is consistently producing
 // It runs its own goroutine for detection, and fires OnStatusChanged callback
 type StreamTracker struct {
@@ -35,38 +73,67 @@ type StreamTracker struct {
 	// number of cycles needed to be active
 	cyclesRequired uint64
 	cycleDuration  time.Duration
+	// disabled when zero
+	frozenTimeout time.Duration
 
-	onStatusChanged func(status StreamStatus)
+	onStatusChanged           func(status StreamStatus)
+	onMaxTemporalLayerChanged func(maxTemporalLayer int32)
+	onBitrateAvailable        func(bitrate uint64)
+	onStreamIssue             func(issue StreamIssue)
 
 	paused         atomic.Bool
 	countSinceLast atomic.Uint32 // number of packets received since last check
 	generation     atomic.Uint32
 
+	// number of packets received per temporal layer since last check
+	temporalCountSinceLast [DefaultMaxLayerTemporal + 1]atomic.Uint32
+	maxTemporalLayer       atomic.Int32
+
+	// unix nanos of the last key frame, or of the last report of missing key frames
+	keyFrameWaitStart atomic.Int64
+	isFrozen          atomic.Bool
+
 	initMu      sync.Mutex
 	initialized bool
 
 	statusMu sync.RWMutex
 	status   StreamStatus
 
-	// only access within detectWorker
-	cycleCount uint64
+	// updated by detectWorker, reset by Reset while the worker of the previous generation may still be running
+	cycleCount      atomic.Uint64
+	cyclesCompleted atomic.Uint64
+
+	// unix nanos
+	lastPacketAt atomic.Int64
 
 	// only access by the same goroutine as Observe
 	lastSN uint16
 
+	bitrate *slidingBitrate
+
 	callbacksQueue *utils.OpsQueue
 
 	isStopped atomic.Bool
 }
 
-func NewStreamTracker(logger logger.Logger, samplesRequired uint32, cyclesRequired uint64, cycleDuration time.Duration) *StreamTracker {
+func NewStreamTracker(
+	logger logger.Logger,
+	samplesRequired uint32,
+	cyclesRequired uint64,
+	cycleDuration time.Duration,
+	bitrateWindow time.Duration,
+	frozenTimeout time.Duration,
+) *StreamTracker {
 	s := &StreamTracker{
 		samplesRequired: samplesRequired,
 		cyclesRequired:  cyclesRequired,
 		cycleDuration:   cycleDuration,
+		frozenTimeout:   frozenTimeout,
 		status:          StreamStatusStopped,
 		callbacksQueue:  utils.NewOpsQueue(logger),
+		bitrate:         newSlidingBitrate(bitrateWindow),
 	}
+	s.maxTemporalLayer.Store(DefaultMaxLayerTemporal)
 	return s
 }
 
@@ -74,6 +141,33 @@ func (s *StreamTracker) OnStatusChanged(f func(status StreamStatus)) {
 	s.onStatusChanged = f
 }
 
+// OnMaxTemporalLayerChanged is called when the highest temporal layer being produced changes
+func (s *StreamTracker) OnMaxTemporalLayerChanged(f func(maxTemporalLayer int32)) {
+	s.onMaxTemporalLayerChanged = f
+}
+
+// OnBitrateAvailable is called with the bitrate once it has been measured over a full window,
+// i.e. when the measurement has stabilized after the stream started
+func (s *StreamTracker) OnBitrateAvailable(f func(bitrate uint64)) {
+	s.onBitrateAvailable = f
+}
+
+// OnStreamIssue is called when the stream keeps flowing but cannot be rendered, see StreamIssue
+func (s *StreamTracker) OnStreamIssue(f func(issue StreamIssue)) {
+	s.onStreamIssue = f
+}
+
+// Bitrate returns the bitrate of media, excluding padding, over the measurement window in bits per second
+func (s *StreamTracker) Bitrate() uint64 {
+	return s.bitrate.get(time.Now())
+}
+
+// MaxTemporalLayer returns the highest temporal layer being produced.
+// Streams without temporal layer information are assumed to produce all layers
+func (s *StreamTracker) MaxTemporalLayer() int32 {
+	return s.maxTemporalLayer.Load()
+}
+
 func (s *StreamTracker) Status() StreamStatus {
 	s.statusMu.RLock()
 	defer s.statusMu.RUnlock()
@@ -81,6 +175,21 @@ func (s *StreamTracker) Status() StreamStatus {
 	return s.status
 }
 
+// Diagnostics returns the detection state
+func (s *StreamTracker) Diagnostics() StreamTrackerDiagnostics {
+	d := StreamTrackerDiagnostics{
+		Status:          s.Status(),
+		Paused:          s.paused.Load(),
+		PacketsInCycle:  s.countSinceLast.Load(),
+		CyclesCompleted: s.cyclesCompleted.Load(),
+		ActiveCycles:    s.cycleCount.Load(),
+	}
+	if lastPacketAt := s.lastPacketAt.Load(); lastPacketAt != 0 {
+		d.LastPacketAt = time.Unix(0, lastPacketAt)
+	}
+	return d
+}
+
 func (s *StreamTracker) maybeSetStatus(status StreamStatus) {
 	changed := false
 	s.statusMu.Lock()
@@ -97,6 +206,18 @@ func (s *StreamTracker) maybeSetStatus(status StreamStatus) {
 	}
 }
 
+func (s *StreamTracker) maybeSetMaxTemporalLayer(maxTemporalLayer int32) {
+	if s.maxTemporalLayer.Swap(maxTemporalLayer) == maxTemporalLayer {
+		return
+	}
+
+	if s.onMaxTemporalLayerChanged != nil {
+		s.callbacksQueue.Enqueue(func() {
+			s.onMaxTemporalLayerChanged(maxTemporalLayer)
+		})
+	}
+}
+
 func (s *StreamTracker) maybeSetActive() {
 	s.maybeSetStatus(StreamStatusActive)
 }
@@ -106,9 +227,20 @@ func (s *StreamTracker) maybeSetStopped() {
 }
 
 func (s *StreamTracker) init() {
+	// load generation before checking for stop so that a concurrent stop either
+	// is seen here or bumps the generation to end the worker
+	generation := s.generation.Load()
+	if s.isStopped.Load() {
+		return
+	}
+
+	// the stream is expected to start with a key frame, no need to wait for one before that
+	s.keyFrameWaitStart.Store(time.Now().UnixNano())
+	s.isFrozen.Store(false)
+
 	s.maybeSetActive()
 
-	go s.detectWorker(s.generation.Load())
+	go s.detectWorker(generation)
 }
 
 func (s *StreamTracker) Start() {
@@ -135,7 +267,10 @@ func (s *StreamTracker) Reset() {
 	s.generation.Inc()
 
 	s.countSinceLast.Store(0)
-	s.cycleCount = 0
+	s.resetTemporalCounts()
+	s.cycleCount.Store(0)
+	s.cyclesCompleted.Store(0)
+	s.bitrate.reset()
 
 	s.initMu.Lock()
 	s.initialized = false
@@ -150,12 +285,36 @@ func (s *StreamTracker) SetPaused(paused bool) {
 	s.paused.Store(paused)
 }
 
-// Observe a packet that's received
-func (s *StreamTracker) Observe(sn uint16) {
+// Observe a packet that's received, temporalLayer is -1 if the stream does not carry temporal layer information
+func (s *StreamTracker) Observe(sn uint16, temporalLayer int32, payloadSize int, isKeyFrame bool) {
 	if s.paused.Load() {
 		return
 	}
 
+	now := time.Now()
+	s.lastPacketAt.Store(now.UnixNano())
+
+	if isKeyFrame && s.frozenTimeout > 0 {
+		s.keyFrameWaitStart.Store(now.UnixNano())
+		s.isFrozen.Store(false)
+	}
+
+	// padding only packets are probes, not media
+	if payloadSize > 0 {
+		if bitrate, available := s.bitrate.add(now, payloadSize); available && s.onBitrateAvailable != nil {
+			s.callbacksQueue.Enqueue(func() {
+				s.onBitrateAvailable(bitrate)
+			})
+		}
+	}
+
+	if temporalLayer >= 0 {
+		if temporalLayer > DefaultMaxLayerTemporal {
+			temporalLayer = DefaultMaxLayerTemporal
+		}
+		s.temporalCountSinceLast[temporalLayer].Inc()
+	}
+
 	s.initMu.Lock()
 	if !s.initialized {
 		// first packet
@@ -198,19 +357,185 @@ func (s *StreamTracker) detectChanges() {
 		return
 	}
 
+	s.cyclesCompleted.Inc()
+
 	if s.countSinceLast.Load() >= s.samplesRequired {
-		s.cycleCount += 1
+		s.cycleCount.Inc()
 	} else {
-		s.cycleCount = 0
+		s.cycleCount.Store(0)
 	}
 
-	if s.cycleCount == 0 {
+	cycleCount := s.cycleCount.Load()
+	if cycleCount == 0 {
 		// flip to stopped
 		s.maybeSetStopped()
-	} else if s.cycleCount >= s.cyclesRequired {
+	} else if cycleCount >= s.cyclesRequired {
 		// flip to active
 		s.maybeSetActive()
 	}
 
+	if cycleCount != 0 {
+		s.detectTemporalChanges()
+		s.detectFrozen()
+	}
+
 	s.countSinceLast.Store(0)
+	s.resetTemporalCounts()
+}
+
+// detectTemporalChanges declares the highest temporal layer seen in the cycle as the highest available,
+// e.g. an encoder under CPU pressure drops the upper temporal layers to halve the frame rate
+func (s *StreamTracker) detectTemporalChanges() {
+	maxTemporalLayer := int32(-1)
+	for layer := int32(DefaultMaxLayerTemporal); layer >= 0; layer-- {
+		if s.temporalCountSinceLast[layer].Load() != 0 {
+			maxTemporalLayer = layer
+			break
+		}
+	}
+	if maxTemporalLayer < 0 {
+		// no temporal layer information
+		maxTemporalLayer = DefaultMaxLayerTemporal
+	}
+
+	s.maybeSetMaxTemporalLayer(maxTemporalLayer)
+}
+
+// detectFrozen reports a stream that keeps flowing without a key frame for the frozen timeout,
+// subscribers that lost the reference cannot decode it till the next key frame
+func (s *StreamTracker) detectFrozen() {
+	if s.frozenTimeout <= 0 {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	waitStart := s.keyFrameWaitStart.Load()
+	if now-waitStart < s.frozenTimeout.Nanoseconds() {
+		return
+	}
+
+	// report again after another timeout unless a key frame arrives in the meantime
+	if !s.keyFrameWaitStart.CAS(waitStart, now) {
+		return
+	}
+	issue := StreamIssue{
+		Reason:     StreamIssueReasonNoKeyFrame,
+		Persistent: s.isFrozen.Swap(true),
+	}
+	if s.onStreamIssue != nil {
+		s.callbacksQueue.Enqueue(func() {
+			s.onStreamIssue(issue)
+		})
+	}
+}
+
+func (s *StreamTracker) resetTemporalCounts() {
+	for layer := range s.temporalCountSinceLast {
+		s.temporalCountSinceLast[layer].Store(0)
+	}
+}
+
+// ------------------------------------------------
+
+const (
+	slidingBitrateBuckets       = 10
+	slidingBitrateDefaultWindow = 2 * time.Second
+)
+
+// slidingBitrate counts bytes in buckets covering a window which slides one bucket at a time
+type slidingBitrate struct {
+	window         time.Duration
+	bucketDuration time.Duration
+
+	lock      sync.Mutex
+	buckets   [slidingBitrateBuckets]uint64
+	head      int
+	headStart time.Time
+	// start of measurement, a window is only partially covered till a full window has elapsed since
+	start     time.Time
+	available bool
+}
+
+func newSlidingBitrate(window time.Duration) *slidingBitrate {
+	if window < slidingBitrateBuckets {
+		window = slidingBitrateDefaultWindow
+	}
+	return &slidingBitrate{
+		window:         window,
+		bucketDuration: window / slidingBitrateBuckets,
+	}
+}
+
+// add accounts for bytes, returns the bitrate and true when the first full window of measurement completes
+func (b *slidingBitrate) add(now time.Time, bytes int) (uint64, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.start.IsZero() {
+		b.start = now
+		b.headStart = now
+	}
+	b.advance(now)
+	b.buckets[b.head] += uint64(bytes)
+
+	if b.available || now.Sub(b.start) < b.window {
+		return 0, false
+	}
+	b.available = true
+	return b.bitrate(now), true
+}
+
+func (b *slidingBitrate) get(now time.Time) uint64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.start.IsZero() {
+		return 0
+	}
+	b.advance(now)
+	return b.bitrate(now)
+}
+
+func (b *slidingBitrate) reset() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.buckets = [slidingBitrateBuckets]uint64{}
+	b.head = 0
+	b.start = time.Time{}
+	b.headStart = time.Time{}
+	b.available = false
+}
+
+func (b *slidingBitrate) advance(now time.Time) {
+	elapsed := int(now.Sub(b.headStart) / b.bucketDuration)
+	if elapsed <= 0 {
+		return
+	}
+
+	if elapsed >= slidingBitrateBuckets {
+		b.buckets = [slidingBitrateBuckets]uint64{}
+	} else {
+		for i := 0; i < elapsed; i++ {
+			b.head = (b.head + 1) % slidingBitrateBuckets
+			b.buckets[b.head] = 0
+		}
+	}
+	b.headStart = b.headStart.Add(time.Duration(elapsed) * b.bucketDuration)
+}
+
+func (b *slidingBitrate) bitrate(now time.Time) uint64 {
+	covered := time.Duration(slidingBitrateBuckets-1)*b.bucketDuration + now.Sub(b.headStart)
+	if sinceStart := now.Sub(b.start); sinceStart < covered {
+		covered = sinceStart
+	}
+	if covered <= 0 {
+		return 0
+	}
+
+	var bytes uint64
+	for _, count := range b.buckets {
+		bytes += count
+	}
+	return uint64(float64(bytes*8) / covered.Seconds())
 }
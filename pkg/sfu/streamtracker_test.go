@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
 	"github.com/livekit/livekit-server/pkg/testutils"
 	"github.com/livekit/protocol/logger"
 )
@@ -15,7 +16,7 @@ import (
 func TestStreamTracker(t *testing.T) {
 	t.Run("flips to active on first observe", func(t *testing.T) {
 		callbackCalled := atomic.NewBool(false)
-		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 5, 60, 500*time.Millisecond)
+		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 5, 60, 500*time.Millisecond, time.Second, 0)
 		tracker.Start()
 		tracker.OnStatusChanged(func(status StreamStatus) {
 			callbackCalled.Store(true)
@@ -23,7 +24,7 @@ func TestStreamTracker(t *testing.T) {
 		require.Equal(t, StreamStatusStopped, tracker.Status())
 
 		// observe first packet
-		tracker.Observe(1)
+		tracker.Observe(1, -1, 1000, false)
 
 		testutils.WithTimeout(t, func() string {
 			if callbackCalled.Load() {
@@ -40,11 +41,11 @@ func TestStreamTracker(t *testing.T) {
 	})
 
 	t.Run("flips to inactive immediately", func(t *testing.T) {
-		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 5, 60, 500*time.Millisecond)
+		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 5, 60, 500*time.Millisecond, time.Second, 0)
 		tracker.Start()
 		require.Equal(t, StreamStatusStopped, tracker.Status())
 
-		tracker.Observe(1)
+		tracker.Observe(1, -1, 1000, false)
 		testutils.WithTimeout(t, func() string {
 			if tracker.Status() == StreamStatusActive {
 				return ""
@@ -76,11 +77,11 @@ func TestStreamTracker(t *testing.T) {
 	})
 
 	t.Run("flips back to active after iterations", func(t *testing.T) {
-		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 1, 2, 500*time.Millisecond)
+		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 1, 2, 500*time.Millisecond, time.Second, 0)
 		tracker.Start()
 		require.Equal(t, StreamStatusStopped, tracker.Status())
 
-		tracker.Observe(1)
+		tracker.Observe(1, -1, 1000, false)
 		testutils.WithTimeout(t, func() string {
 			if tracker.Status() == StreamStatusActive {
 				return ""
@@ -91,11 +92,11 @@ func TestStreamTracker(t *testing.T) {
 
 		tracker.maybeSetStopped()
 
-		tracker.Observe(2)
+		tracker.Observe(2, -1, 1000, false)
 		tracker.detectChanges()
 		require.Equal(t, StreamStatusStopped, tracker.Status())
 
-		tracker.Observe(3)
+		tracker.Observe(3, -1, 1000, false)
 		tracker.detectChanges()
 		require.Equal(t, StreamStatusActive, tracker.Status())
 
@@ -103,9 +104,9 @@ func TestStreamTracker(t *testing.T) {
 	})
 
 	t.Run("does not change to inactive when paused", func(t *testing.T) {
-		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 5, 60, 500*time.Millisecond)
+		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 5, 60, 500*time.Millisecond, time.Second, 0)
 		tracker.Start()
-		tracker.Observe(1)
+		tracker.Observe(1, -1, 1000, false)
 		testutils.WithTimeout(t, func() string {
 			if tracker.Status() == StreamStatusActive {
 				return ""
@@ -123,7 +124,7 @@ func TestStreamTracker(t *testing.T) {
 
 	t.Run("flips back to active on first observe after reset", func(t *testing.T) {
 		callbackCalled := atomic.NewUint32(0)
-		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 5, 60, 500*time.Millisecond)
+		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 5, 60, 500*time.Millisecond, time.Second, 0)
 		tracker.Start()
 		tracker.OnStatusChanged(func(status StreamStatus) {
 			callbackCalled.Inc()
@@ -131,7 +132,7 @@ func TestStreamTracker(t *testing.T) {
 		require.Equal(t, StreamStatusStopped, tracker.Status())
 
 		// observe first packet
-		tracker.Observe(1)
+		tracker.Observe(1, -1, 1000, false)
 
 		testutils.WithTimeout(t, func() string {
 			if callbackCalled.Load() == 1 {
@@ -145,10 +146,10 @@ func TestStreamTracker(t *testing.T) {
 		require.Equal(t, uint32(1), callbackCalled.Load())
 
 		// observe a few more
-		tracker.Observe(2)
-		tracker.Observe(3)
-		tracker.Observe(4)
-		tracker.Observe(5)
+		tracker.Observe(2, -1, 1000, false)
+		tracker.Observe(3, -1, 1000, false)
+		tracker.Observe(4, -1, 1000, false)
+		tracker.Observe(5, -1, 1000, false)
 		tracker.detectChanges()
 
 		// should still be active
@@ -159,7 +160,7 @@ func TestStreamTracker(t *testing.T) {
 		require.Equal(t, StreamStatusStopped, tracker.Status())
 
 		// first packet after reset
-		tracker.Observe(1)
+		tracker.Observe(1, -1, 1000, false)
 
 		testutils.WithTimeout(t, func() string {
 			if callbackCalled.Load() == 2 {
@@ -174,4 +175,248 @@ func TestStreamTracker(t *testing.T) {
 
 		tracker.Stop()
 	})
+
+	t.Run("tracks highest temporal layer", func(t *testing.T) {
+		var maxTemporalLayer atomic.Int32
+		maxTemporalLayer.Store(-1)
+		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 1, 1, 500*time.Millisecond, time.Second, 0)
+		tracker.Start()
+		tracker.OnMaxTemporalLayerChanged(func(layer int32) {
+			maxTemporalLayer.Store(layer)
+		})
+		require.Equal(t, DefaultMaxLayerTemporal, tracker.MaxTemporalLayer())
+
+		tracker.Observe(1, 0, 1000, false)
+		tracker.Observe(2, 2, 1000, false)
+		tracker.Observe(3, 1, 1000, false)
+		tracker.detectChanges()
+		require.Equal(t, int32(2), tracker.MaxTemporalLayer())
+		testutils.WithTimeout(t, func() string {
+			if maxTemporalLayer.Load() == 2 {
+				return ""
+			}
+			return fmt.Sprintf("expected max temporal layer 2, actual: %d", maxTemporalLayer.Load())
+		})
+
+		// upper layer stops
+		tracker.Observe(4, 0, 1000, false)
+		tracker.Observe(5, 1, 1000, false)
+		tracker.detectChanges()
+		require.Equal(t, int32(1), tracker.MaxTemporalLayer())
+
+		// no temporal layer information, assume all layers
+		tracker.Observe(6, -1, 1000, false)
+		tracker.detectChanges()
+		require.Equal(t, DefaultMaxLayerTemporal, tracker.MaxTemporalLayer())
+
+		tracker.Stop()
+	})
+}
+
+func TestSlidingBitrate(t *testing.T) {
+	now := time.Now()
+	b := newSlidingBitrate(time.Second)
+	require.Zero(t, b.get(now))
+
+	// 1000 bytes every 10ms, 800 kbps
+	available := 0
+	for i := 0; i < 150; i++ {
+		if bitrate, ok := b.add(now.Add(time.Duration(i)*10*time.Millisecond), 1000); ok {
+			available++
+			require.InDelta(t, 800_000, bitrate, 20_000)
+		}
+	}
+	require.Equal(t, 1, available)
+	require.InDelta(t, 800_000, b.get(now.Add(1500*time.Millisecond)), 20_000)
+
+	// rate halves, the window follows
+	for i := 150; i < 300; i += 2 {
+		b.add(now.Add(time.Duration(i)*10*time.Millisecond), 1000)
+	}
+	require.InDelta(t, 400_000, b.get(now.Add(3*time.Second)), 20_000)
+
+	// stream stops, the window drains
+	require.InDelta(t, 200_000, b.get(now.Add(3500*time.Millisecond)), 40_000)
+	require.Zero(t, b.get(now.Add(5*time.Second)))
+
+	// a restart is measured afresh
+	b.reset()
+	require.Zero(t, b.get(now))
+	_, ok := b.add(now, 1000)
+	require.False(t, ok)
+	_, ok = b.add(now.Add(time.Second), 1000)
+	require.True(t, ok)
+}
+
+func TestStreamTrackerDiagnostics(t *testing.T) {
+	tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 5, 60, 500*time.Millisecond, time.Second, 0)
+	tracker.Start()
+	defer tracker.Stop()
+
+	require.Equal(t, StreamTrackerDiagnostics{Status: StreamStatusStopped}, tracker.Diagnostics())
+
+	before := time.Now()
+	tracker.Observe(1, -1, 1000, false)
+	tracker.Observe(2, -1, 1000, false)
+	testutils.WithTimeout(t, func() string {
+		if tracker.Status() != StreamStatusActive {
+			return "first packet did not declare the stream active"
+		}
+		return ""
+	})
+
+	d := tracker.Diagnostics()
+	require.Equal(t, StreamStatusActive, d.Status)
+	require.False(t, d.LastPacketAt.Before(before))
+	require.Equal(t, uint32(2), d.PacketsInCycle)
+	require.Zero(t, d.CyclesCompleted)
+
+	for sn := uint16(3); sn < 8; sn++ {
+		tracker.Observe(sn, -1, 1000, false)
+	}
+	tracker.detectChanges()
+	d = tracker.Diagnostics()
+	require.Zero(t, d.PacketsInCycle)
+	require.Equal(t, uint64(1), d.CyclesCompleted)
+	require.Equal(t, uint64(1), d.ActiveCycles)
+
+	tracker.SetPaused(true)
+	require.True(t, tracker.Diagnostics().Paused)
+}
+
+func TestStreamTrackerBitrate(t *testing.T) {
+	tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 1, 1, 500*time.Millisecond, 200*time.Millisecond, 0)
+	tracker.Start()
+	defer tracker.Stop()
+
+	bitrateAvailable := make(chan uint64, 2)
+	tracker.OnBitrateAvailable(func(bitrate uint64) {
+		bitrateAvailable <- bitrate
+	})
+
+	// padding is not media
+	tracker.Observe(1, -1, 0, false)
+	time.Sleep(250 * time.Millisecond)
+	tracker.Observe(2, -1, 0, false)
+	require.Zero(t, tracker.Bitrate())
+	require.Empty(t, bitrateAvailable)
+
+	sn := uint16(3)
+	deadline := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		tracker.Observe(sn, -1, 1000, false)
+		sn++
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NotZero(t, tracker.Bitrate())
+
+	select {
+	case bitrate := <-bitrateAvailable:
+		require.NotZero(t, bitrate)
+	case <-time.After(time.Second):
+		t.Fatal("bitrate not available")
+	}
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(t, bitrateAvailable)
+}
+
+// observeVP8 feeds a packet carrying the start of a VP8 frame, the key frame flag is parsed from the payload
+func observeVP8(t *testing.T, tracker *StreamTracker, sn uint16, keyFrame bool) {
+	// payload descriptor with the start of partition 0, followed by the payload header with the P bit cleared for key frames
+	payload := []byte{0x10, 0x01, 0x00, 0x00, 0x9d, 0x01, 0x2a}
+	if keyFrame {
+		payload[1] = 0x00
+	}
+
+	var vp8 buffer.VP8
+	require.NoError(t, vp8.Unmarshal(payload))
+	require.Equal(t, keyFrame, vp8.IsKeyFrame)
+
+	tracker.Observe(sn, -1, len(payload), vp8.IsKeyFrame)
+}
+
+// feedVP8 observes a frame every 10ms with a key frame every keyFrameInterval frames, none when zero
+func feedVP8(t *testing.T, tracker *StreamTracker, duration time.Duration, sn *uint16, keyFrameInterval int) {
+	deadline := time.Now().Add(duration)
+	for frames := 0; time.Now().Before(deadline); frames++ {
+		observeVP8(t, tracker, *sn, keyFrameInterval != 0 && frames%keyFrameInterval == 0)
+		*sn++
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func waitStreamIssue(t *testing.T, issues chan StreamIssue) StreamIssue {
+	select {
+	case issue := <-issues:
+		return issue
+	case <-time.After(time.Second):
+		t.Fatal("frozen stream not reported")
+		return StreamIssue{}
+	}
+}
+
+func TestStreamTrackerFrozen(t *testing.T) {
+	newTracker := func() (*StreamTracker, chan StreamIssue) {
+		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 1, 1, 50*time.Millisecond, time.Second, 200*time.Millisecond)
+		issues := make(chan StreamIssue, 10)
+		tracker.OnStreamIssue(func(issue StreamIssue) {
+			issues <- issue
+		})
+		tracker.Start()
+		return tracker, issues
+	}
+
+	t.Run("delta frames only are reported till a key frame arrives", func(t *testing.T) {
+		tracker, issues := newTracker()
+		defer tracker.Stop()
+
+		sn := uint16(1)
+		observeVP8(t, tracker, sn, true)
+		sn++
+		feedVP8(t, tracker, 500*time.Millisecond, &sn, 0)
+
+		require.Equal(t, StreamIssue{Reason: StreamIssueReasonNoKeyFrame}, waitStreamIssue(t, issues))
+		require.Equal(t, StreamIssue{Reason: StreamIssueReasonNoKeyFrame, Persistent: true}, waitStreamIssue(t, issues))
+
+		// a key frame resolves it, the next report starts a new issue
+		feedVP8(t, tracker, 100*time.Millisecond, &sn, 1)
+		for len(issues) != 0 {
+			<-issues
+		}
+		feedVP8(t, tracker, 300*time.Millisecond, &sn, 0)
+		require.False(t, waitStreamIssue(t, issues).Persistent)
+	})
+
+	t.Run("regular key frames are not reported", func(t *testing.T) {
+		tracker, issues := newTracker()
+		defer tracker.Stop()
+
+		sn := uint16(1)
+		feedVP8(t, tracker, 600*time.Millisecond, &sn, 5)
+		require.Empty(t, issues)
+	})
+
+	t.Run("stopped stream is not reported", func(t *testing.T) {
+		tracker, issues := newTracker()
+		defer tracker.Stop()
+
+		sn := uint16(1)
+		observeVP8(t, tracker, sn, true)
+		time.Sleep(500 * time.Millisecond)
+		require.Empty(t, issues)
+	})
+
+	t.Run("disabled without a timeout", func(t *testing.T) {
+		tracker := NewStreamTracker(logger.Logger(logger.GetLogger()), 1, 1, 50*time.Millisecond, time.Second, 0)
+		issues := make(chan StreamIssue, 10)
+		tracker.OnStreamIssue(func(issue StreamIssue) {
+			issues <- issue
+		})
+		tracker.Start()
+		defer tracker.Stop()
+
+		sn := uint16(1)
+		feedVP8(t, tracker, 400*time.Millisecond, &sn, 0)
+		require.Empty(t, issues)
+	})
 }
@@ -6,62 +6,261 @@ import (
 	"time"
 
 	"github.com/livekit/protocol/logger"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/utils"
 )
 
+// ExpectedLayerRampUpPeriod is the time a publisher is given to start layers that are expected again,
+// before missing ones count as reduced quality
+const ExpectedLayerRampUpPeriod = 5 * time.Second
+
+// AvailableLayers describes the layers an up track is producing
+type AvailableLayers struct {
+	// in ascending order
+	Spatial []int32
+	// highest temporal layer being produced, per spatial layer
+	MaxTemporal []int32
+	// incremented on every change, a view with a lower generation is stale
+	Generation uint64
+}
+
+// LayerFlap is the outcome of a layer that was available stopping and resuming
+type LayerFlap int
+
+const (
+	// layer made available again after it was removed
+	LayerFlapReAdded LayerFlap = iota
+	// layer stopped again before it was made available again
+	LayerFlapReAddSuppressed
+	// layer resumed before it was removed
+	LayerFlapRemovalSuppressed
+)
+
+func (f LayerFlap) String() string {
+	switch f {
+	case LayerFlapReAdded:
+		return "readded"
+	case LayerFlapReAddSuppressed:
+		return "readd_suppressed"
+	case LayerFlapRemovalSuppressed:
+		return "removal_suppressed"
+	default:
+		return "unknown"
+	}
+}
+
+// layerHysteresis holds the pending changes of a layer, see config.StreamTrackerHysteresisConfig
+type layerHysteresis struct {
+	// layer was removed after being available, adding it back is a flap
+	removed     bool
+	addTimer    *time.Timer
+	removeTimer *time.Timer
+}
+
+func (h *layerHysteresis) reset() {
+	h.removed = false
+	h.cancelAdd()
+	h.cancelRemove()
+}
+
+func (h *layerHysteresis) cancelAdd() bool {
+	if h.addTimer == nil {
+		return false
+	}
+	h.addTimer.Stop()
+	h.addTimer = nil
+	return true
+}
+
+func (h *layerHysteresis) cancelRemove() bool {
+	if h.removeTimer == nil {
+		return false
+	}
+	h.removeTimer.Stop()
+	h.removeTimer = nil
+	return true
+}
+
+func (a AvailableLayers) HasSpatial(layer int32) bool {
+	for _, l := range a.Spatial {
+		if l == layer {
+			return true
+		}
+	}
+
+	return false
+}
+
 type StreamTrackerManager struct {
 	logger logger.Logger
+	config config.StreamTrackerConfig
 
 	lock sync.RWMutex
 
-	trackers [DefaultMaxLayerSpatial + 1]*StreamTracker
+	// layer indexed slices are sized by the number of spatial layers and never resized
+	trackers []*StreamTracker
 
-	availableLayers  []int32
+	// *AvailableLayers, replaced as a whole under lock on every change and never modified once stored,
+	// so that it can be read without locking
+	availableLayers  atomic.Value
 	maxExpectedLayer int32
-
-	onAvailableLayersChanged func(availableLayers []int32)
+	// while ramping up, layers above rampUpFromLayer are not counted as missing
+	rampUpFromLayer int32
+	rampUpDeadline  time.Time
+	hysteresis      []layerHysteresis
+	// paused layers keep their availability, see SetPausedLayer
+	paused []bool
+
+	// listeners are notified in order of change on a dedicated goroutine so that
+	// a slow listener does not hold up layer detection
+	callbacksQueue            *utils.OpsQueue
+	callbacksMu               sync.RWMutex
+	isClosed                  bool
+	onAvailableLayersChanged  []func(availableLayers []int32)
+	onMaxTemporalLayerChanged func(layer int32, maxTemporalLayer int32)
+	onLayerFlap               func(layer int32, flap LayerFlap)
+	onBitrateAvailable        func(layer int32, bitrate uint64)
+	onStreamIssue             func(layer int32, issue StreamIssue)
 }
 
-func NewStreamTrackerManager(logger logger.Logger) *StreamTrackerManager {
-	return &StreamTrackerManager{
+func NewStreamTrackerManager(logger logger.Logger, numSpatialLayers int32, config config.StreamTrackerConfig) *StreamTrackerManager {
+	s := &StreamTrackerManager{
 		logger:           logger,
-		maxExpectedLayer: DefaultMaxLayerSpatial,
+		config:           config,
+		trackers:         make([]*StreamTracker, numSpatialLayers),
+		maxExpectedLayer: numSpatialLayers - 1,
+		hysteresis:       make([]layerHysteresis, numSpatialLayers),
+		paused:           make([]bool, numSpatialLayers),
+		callbacksQueue:   utils.NewOpsQueue(logger),
 	}
+	initial := &AvailableLayers{
+		MaxTemporal: make([]int32, numSpatialLayers),
+	}
+	for layer := range initial.MaxTemporal {
+		initial.MaxTemporal[layer] = DefaultMaxLayerTemporal
+	}
+	s.availableLayers.Store(initial)
+	s.callbacksQueue.Start()
+	return s
 }
 
+// OnAvailableLayersChanged adds a listener which is called with the available spatial layers in ascending order,
+// the slice must not be modified
 func (s *StreamTrackerManager) OnAvailableLayersChanged(f func(availableLayers []int32)) {
-	s.onAvailableLayersChanged = f
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.onAvailableLayersChanged = append(s.onAvailableLayersChanged, f)
+}
+
+// OnMaxTemporalLayerChanged is called when the highest temporal layer produced in a spatial layer changes
+func (s *StreamTrackerManager) OnMaxTemporalLayerChanged(f func(layer int32, maxTemporalLayer int32)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.onMaxTemporalLayerChanged = f
+}
+
+// OnLayerFlap is called when a layer that was available stops and resumes, to gauge the hysteresis
+func (s *StreamTrackerManager) OnLayerFlap(f func(layer int32, flap LayerFlap)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.onLayerFlap = f
+}
+
+// OnBitrateAvailable is called once the bitrate of a layer has been measured over a full window after it started
+func (s *StreamTrackerManager) OnBitrateAvailable(f func(layer int32, bitrate uint64)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.onBitrateAvailable = f
+}
+
+// OnStreamIssue is called when a layer keeps flowing but cannot be rendered, see StreamIssue
+func (s *StreamTrackerManager) OnStreamIssue(f func(layer int32, issue StreamIssue)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.onStreamIssue = f
 }
 
 func (s *StreamTrackerManager) AddTracker(layer int32) {
-	cycleDuration := 500 * time.Millisecond
-	samplesRequired := uint32(5)
-	cyclesRequired := uint64(60) // 30s of continuous stream
+	if !s.isValidLayer(layer) {
+		s.logger.Warnw("cannot track invalid layer", nil, "layer", layer)
+		return
+	}
+
+	layerConfig := s.config.UpperLayers
 	if layer == 0 {
-		// be very forgiving for base layer to account for cases like static screen share where there could be only one packet per second
-		samplesRequired = 1
-		cyclesRequired = 1 // 1 packet in 2 seconds
-		cycleDuration = 2 * time.Second
+		layerConfig = s.config.BaseLayer
 	}
-	tracker := NewStreamTracker(s.logger, samplesRequired, cyclesRequired, cycleDuration)
+	tracker := NewStreamTracker(
+		s.logger,
+		layerConfig.SamplesRequired,
+		layerConfig.CyclesRequired,
+		layerConfig.CycleDuration.Duration(),
+		s.config.BitrateWindow.Duration(),
+		s.config.FrozenTimeout.Duration(),
+	)
 	tracker.OnStatusChanged(func(status StreamStatus) {
 		if status == StreamStatusStopped {
-			s.removeAvailableLayer(layer)
+			s.onLayerStopped(layer)
 		} else {
-			s.addAvailableLayer(layer)
+			s.onLayerActive(layer)
+		}
+	})
+	tracker.OnMaxTemporalLayerChanged(func(maxTemporalLayer int32) {
+		s.setMaxTemporalLayer(layer, maxTemporalLayer)
+	})
+	tracker.OnBitrateAvailable(func(bitrate uint64) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if onBitrateAvailable := s.onBitrateAvailable; onBitrateAvailable != nil {
+			s.enqueueLocked(func() {
+				onBitrateAvailable(layer, bitrate)
+			})
+		}
+	})
+
+	tracker.OnStreamIssue(func(issue StreamIssue) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if onStreamIssue := s.onStreamIssue; onStreamIssue != nil {
+			s.enqueueLocked(func() {
+				onStreamIssue(layer, issue)
+			})
 		}
 	})
 
 	s.lock.Lock()
 	s.trackers[layer] = tracker
+	if s.paused[layer] {
+		tracker.SetPaused(true)
+	}
 	s.lock.Unlock()
 
 	tracker.Start()
 }
 
 func (s *StreamTrackerManager) RemoveTracker(layer int32) {
+	if !s.isValidLayer(layer) {
+		return
+	}
+
 	s.lock.Lock()
 	tracker := s.trackers[layer]
 	s.trackers[layer] = nil
+	s.hysteresis[layer].reset()
+	if curr := s.loadAvailableLayers(); curr.MaxTemporal[layer] != DefaultMaxLayerTemporal {
+		maxTemporal := append([]int32(nil), curr.MaxTemporal...)
+		maxTemporal[layer] = DefaultMaxLayerTemporal
+		s.storeAvailableLayersLocked(curr.Spatial, maxTemporal)
+	}
 	s.lock.Unlock()
 
 	if tracker != nil {
@@ -69,14 +268,22 @@ func (s *StreamTrackerManager) RemoveTracker(layer int32) {
 	}
 }
 
+// RemoveAllTrackers stops all trackers, no notifications are delivered once it returns.
+// It waits for a notification in progress and hence must not be called from a listener.
 func (s *StreamTrackerManager) RemoveAllTrackers() {
 	s.lock.Lock()
-	trackers := s.trackers
+	trackers := append([]*StreamTracker(nil), s.trackers...)
 	for layer := range s.trackers {
 		s.trackers[layer] = nil
+		s.hysteresis[layer].reset()
 	}
+	s.callbacksQueue.Stop()
 	s.lock.Unlock()
 
+	s.callbacksMu.Lock()
+	s.isClosed = true
+	s.callbacksMu.Unlock()
+
 	for _, tracker := range trackers {
 		if tracker != nil {
 			tracker.Stop()
@@ -85,29 +292,62 @@ func (s *StreamTrackerManager) RemoveAllTrackers() {
 }
 
 func (s *StreamTrackerManager) GetTracker(layer int32) *StreamTracker {
+	if !s.isValidLayer(layer) {
+		return nil
+	}
+
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
 	return s.trackers[layer]
 }
 
+// SetPaused pauses or resumes all layers, see SetPausedLayer
 func (s *StreamTrackerManager) SetPaused(paused bool) {
+	for layer := range s.trackers {
+		s.SetPausedLayer(int32(layer), paused)
+	}
+}
+
+// SetPausedLayer pauses detection on a layer that is not expected to be sent, e.g. when the publisher
+// is constrained, while other layers keep being tracked. A paused layer does not become available or stopped.
+// On resume, the layer starts afresh as after a reset and is declared available on the first packet.
+func (s *StreamTrackerManager) SetPausedLayer(layer int32, paused bool) {
+	if !s.isValidLayer(layer) {
+		return
+	}
+
 	s.lock.Lock()
-	trackers := s.trackers
-	s.lock.Unlock()
+	defer s.lock.Unlock()
 
-	for _, tracker := range trackers {
-		if tracker != nil {
-			tracker.SetPaused(paused)
-		}
+	if s.paused[layer] == paused {
+		return
+	}
+	s.paused[layer] = paused
+
+	// pending changes were decided on packets from before the pause
+	s.hysteresis[layer].reset()
+
+	tracker := s.trackers[layer]
+	if tracker == nil {
+		return
+	}
+	if !paused {
+		tracker.Reset()
 	}
+	tracker.SetPaused(paused)
 }
 
 func (s *StreamTrackerManager) SetMaxExpectedSpatialLayer(layer int32) {
+	if maxLayer := int32(len(s.trackers)) - 1; layer > maxLayer {
+		layer = maxLayer
+	}
+
 	s.lock.Lock()
 	if layer <= s.maxExpectedLayer {
 		// some higher layer(s) expected to stop, nothing else to do
 		s.maxExpectedLayer = layer
+		s.rampUpDeadline = time.Time{}
 		s.lock.Unlock()
 		return
 	}
@@ -123,15 +363,28 @@ func (s *StreamTrackerManager) SetMaxExpectedSpatialLayer(layer int32) {
 	// But, those conditions should be rare. In those cases, the restart will
 	// take longer.
 	//
+	starting := false
 	var trackersToReset []*StreamTracker
+	availableLayers := s.loadAvailableLayers()
 	for l := s.maxExpectedLayer + 1; l <= layer; l++ {
-		if s.hasSpatialLayerLocked(l) {
+		if availableLayers.HasSpatial(l) {
 			continue
 		}
 
+		// an expected start is not a flap
+		s.hysteresis[l].reset()
 		if s.trackers[l] != nil {
 			trackersToReset = append(trackersToReset, s.trackers[l])
 		}
+		starting = true
+	}
+	if starting {
+		// the publisher needs time to start encoding again, extend a ramp up in progress from where it started
+		now := time.Now()
+		if !now.Before(s.rampUpDeadline) {
+			s.rampUpFromLayer = s.maxExpectedLayer
+		}
+		s.rampUpDeadline = now.Add(ExpectedLayerRampUpPeriod)
 	}
 	s.maxExpectedLayer = layer
 	s.lock.Unlock()
@@ -141,75 +394,309 @@ func (s *StreamTrackerManager) SetMaxExpectedSpatialLayer(layer int32) {
 	}
 }
 
+// DebugInfo reports the detection state of all layers
+func (s *StreamTrackerManager) DebugInfo() map[string]interface{} {
+	s.lock.RLock()
+	trackers := append([]*StreamTracker(nil), s.trackers...)
+	maxExpectedLayer := s.maxExpectedLayer
+	rampingUp := time.Now().Before(s.rampUpDeadline)
+	s.lock.RUnlock()
+
+	availableLayers := s.loadAvailableLayers()
+	layerInfo := make([]map[string]interface{}, 0, len(trackers))
+	for layer, tracker := range trackers {
+		if tracker == nil {
+			continue
+		}
+
+		d := tracker.Diagnostics()
+		layerInfo = append(layerInfo, map[string]interface{}{
+			"Layer":            layer,
+			"Status":           d.Status.String(),
+			"Paused":           d.Paused,
+			"LastPacketAt":     d.LastPacketAt,
+			"PacketsInCycle":   d.PacketsInCycle,
+			"CyclesCompleted":  d.CyclesCompleted,
+			"ActiveCycles":     d.ActiveCycles,
+			"MaxTemporalLayer": availableLayers.MaxTemporal[layer],
+			"Bitrate":          tracker.Bitrate(),
+		})
+	}
+
+	return map[string]interface{}{
+		"MaxExpectedLayer": maxExpectedLayer,
+		"RampingUp":        rampingUp,
+		"AvailableLayers":  availableLayers.Spatial,
+		"Generation":       availableLayers.Generation,
+		"Layers":           layerInfo,
+	}
+}
+
 func (s *StreamTrackerManager) IsReducedQuality() bool {
 	s.lock.RLock()
-	defer s.lock.RUnlock()
+	maxExpectedLayer := s.maxExpectedLayer
+	if time.Now().Before(s.rampUpDeadline) {
+		maxExpectedLayer = s.rampUpFromLayer
+	}
+	s.lock.RUnlock()
+
+	return int32(len(s.loadAvailableLayers().Spatial)) < (maxExpectedLayer + 1)
+}
+
+// GetAvailableLayers returns a copy of the available layers
+func (s *StreamTrackerManager) GetAvailableLayers() AvailableLayers {
+	availableLayers := *s.loadAvailableLayers()
+	availableLayers.Spatial = append([]int32(nil), availableLayers.Spatial...)
+	availableLayers.MaxTemporal = append([]int32(nil), availableLayers.MaxTemporal...)
+	return availableLayers
+}
 
-	return int32(len(s.availableLayers)) < (s.maxExpectedLayer + 1)
+// GetAvailableSpatialLayers returns a copy of the available spatial layers in ascending order
+func (s *StreamTrackerManager) GetAvailableSpatialLayers() []int32 {
+	return append([]int32(nil), s.loadAvailableLayers().Spatial...)
 }
 
-func (s *StreamTrackerManager) GetAvailableLayers() []int32 {
+// GetLayerBitrates returns the measured bitrate of each spatial layer in bits per second, zero for layers not tracked
+func (s *StreamTrackerManager) GetLayerBitrates() []uint64 {
 	s.lock.RLock()
-	defer s.lock.RUnlock()
+	trackers := append([]*StreamTracker(nil), s.trackers...)
+	s.lock.RUnlock()
+
+	bitrates := make([]uint64, len(trackers))
+	for layer, tracker := range trackers {
+		if tracker != nil {
+			bitrates[layer] = tracker.Bitrate()
+		}
+	}
+	return bitrates
+}
+
+func (s *StreamTrackerManager) GetMaxTemporalLayer(layer int32) int32 {
+	if !s.isValidLayer(layer) {
+		return InvalidLayerTemporal
+	}
+	return s.loadAvailableLayers().MaxTemporal[layer]
+}
 
-	return s.availableLayers
+// isValidLayer is true for layers within the number of spatial layers the manager was created with
+func (s *StreamTrackerManager) isValidLayer(layer int32) bool {
+	return layer >= 0 && int(layer) < len(s.trackers)
 }
 
 func (s *StreamTrackerManager) HasSpatialLayer(layer int32) bool {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+	return s.loadAvailableLayers().HasSpatial(layer)
+}
 
-	return s.hasSpatialLayerLocked(layer)
+func (s *StreamTrackerManager) loadAvailableLayers() *AvailableLayers {
+	return s.availableLayers.Load().(*AvailableLayers)
 }
 
-func (s *StreamTrackerManager) hasSpatialLayerLocked(layer int32) bool {
-	for _, l := range s.availableLayers {
-		if l == layer {
-			return true
-		}
+// storeAvailableLayersLocked publishes a new view, the slices must not be modified afterwards
+func (s *StreamTrackerManager) storeAvailableLayersLocked(spatial []int32, maxTemporal []int32) *AvailableLayers {
+	availableLayers := &AvailableLayers{
+		Spatial:     spatial,
+		MaxTemporal: maxTemporal,
+		Generation:  s.loadAvailableLayers().Generation + 1,
 	}
+	s.availableLayers.Store(availableLayers)
+	return availableLayers
+}
 
-	return false
+func (s *StreamTrackerManager) setMaxTemporalLayer(layer int32, maxTemporalLayer int32) {
+	s.lock.Lock()
+	curr := s.loadAvailableLayers()
+	if curr.MaxTemporal[layer] == maxTemporalLayer {
+		s.lock.Unlock()
+		return
+	}
+	maxTemporal := append([]int32(nil), curr.MaxTemporal...)
+	maxTemporal[layer] = maxTemporalLayer
+	s.storeAvailableLayersLocked(curr.Spatial, maxTemporal)
+
+	if onMaxTemporalLayerChanged := s.onMaxTemporalLayerChanged; onMaxTemporalLayerChanged != nil {
+		s.enqueueLocked(func() {
+			onMaxTemporalLayerChanged(layer, maxTemporalLayer)
+		})
+	}
+	s.lock.Unlock()
 }
 
-func (s *StreamTrackerManager) addAvailableLayer(layer int32) {
+func (s *StreamTrackerManager) onLayerActive(layer int32) {
 	s.lock.Lock()
-	hasLayer := false
-	for _, l := range s.availableLayers {
-		if l == layer {
-			hasLayer = true
-			break
+	defer s.lock.Unlock()
+
+	if s.paused[layer] {
+		return
+	}
+
+	h := &s.hysteresis[layer]
+	if h.cancelRemove() {
+		s.notifyLayerFlapLocked(layer, LayerFlapRemovalSuppressed)
+		return
+	}
+
+	reAddAfter := s.config.Hysteresis.ReAddAfter.Duration()
+	if !h.removed || reAddAfter <= 0 {
+		s.addAvailableLayerLocked(layer)
+		return
+	}
+
+	if h.addTimer != nil {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(reAddAfter, func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if h.addTimer != timer {
+			// cancelled
+			return
 		}
+		h.addTimer = nil
+		s.addAvailableLayerLocked(layer)
+	})
+	h.addTimer = timer
+}
+
+func (s *StreamTrackerManager) onLayerStopped(layer int32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.paused[layer] {
+		return
 	}
-	if hasLayer {
-		s.lock.Unlock()
+
+	h := &s.hysteresis[layer]
+	if h.cancelAdd() {
+		s.notifyLayerFlapLocked(layer, LayerFlapReAddSuppressed)
 		return
 	}
 
-	s.availableLayers = append(s.availableLayers, layer)
-	sort.Slice(s.availableLayers, func(i, j int) bool { return s.availableLayers[i] < s.availableLayers[j] })
-	layers := s.availableLayers
-	s.lock.Unlock()
+	if !s.loadAvailableLayers().HasSpatial(layer) {
+		return
+	}
+
+	trackSilent := s.isTrackSilentLocked()
+	removalGrace := s.config.Hysteresis.RemovalGrace.Duration()
+	if removalGrace > 0 && !trackSilent {
+		if h.removeTimer != nil {
+			return
+		}
+		var timer *time.Timer
+		timer = time.AfterFunc(removalGrace, func() {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+
+			if h.removeTimer != timer {
+				// cancelled
+				return
+			}
+			h.removeTimer = nil
+			s.removeAvailableLayerLocked(layer)
+		})
+		h.removeTimer = timer
+		return
+	}
+
+	s.removeAvailableLayerLocked(layer)
+	if trackSilent {
+		// nothing to wait for on other layers either
+		for l := range s.hysteresis {
+			if s.hysteresis[l].cancelRemove() {
+				s.removeAvailableLayerLocked(int32(l))
+			}
+		}
+	}
+}
+
+func (s *StreamTrackerManager) isTrackSilentLocked() bool {
+	for _, tracker := range s.trackers {
+		if tracker != nil && tracker.Status() == StreamStatusActive {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *StreamTrackerManager) notifyLayerFlapLocked(layer int32, flap LayerFlap) {
+	if onLayerFlap := s.onLayerFlap; onLayerFlap != nil {
+		s.enqueueLocked(func() {
+			onLayerFlap(layer, flap)
+		})
+	}
+}
+
+func (s *StreamTrackerManager) addAvailableLayer(layer int32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.addAvailableLayerLocked(layer)
+}
+
+func (s *StreamTrackerManager) addAvailableLayerLocked(layer int32) {
+	curr := s.loadAvailableLayers()
+	if curr.HasSpatial(layer) {
+		return
+	}
 
-	if s.onAvailableLayersChanged != nil {
-		s.onAvailableLayersChanged(layers)
+	if h := &s.hysteresis[layer]; h.removed {
+		h.removed = false
+		s.notifyLayerFlapLocked(layer, LayerFlapReAdded)
 	}
+
+	newLayers := make([]int32, 0, len(curr.Spatial)+1)
+	newLayers = append(newLayers, curr.Spatial...)
+	newLayers = append(newLayers, layer)
+	sort.Slice(newLayers, func(i, j int) bool { return newLayers[i] < newLayers[j] })
+	s.storeAvailableLayersLocked(newLayers, curr.MaxTemporal)
+	s.notifyAvailableLayersChangedLocked(newLayers)
 }
 
 func (s *StreamTrackerManager) removeAvailableLayer(layer int32) {
 	s.lock.Lock()
-	newLayers := make([]int32, 0, DefaultMaxLayerSpatial+1)
-	for _, l := range s.availableLayers {
+	defer s.lock.Unlock()
+
+	s.removeAvailableLayerLocked(layer)
+}
+
+func (s *StreamTrackerManager) removeAvailableLayerLocked(layer int32) {
+	curr := s.loadAvailableLayers()
+	if !curr.HasSpatial(layer) {
+		return
+	}
+	s.hysteresis[layer].removed = true
+
+	newLayers := make([]int32, 0, len(s.trackers))
+	for _, l := range curr.Spatial {
 		if l != layer {
 			newLayers = append(newLayers, l)
 		}
 	}
-	sort.Slice(newLayers, func(i, j int) bool { return newLayers[i] < newLayers[j] })
-	s.availableLayers = newLayers
-	s.lock.Unlock()
-
+	s.storeAvailableLayersLocked(newLayers, curr.MaxTemporal)
 	// need to immediately switch off unavailable layers
-	if s.onAvailableLayersChanged != nil {
-		s.onAvailableLayersChanged(newLayers)
+	s.notifyAvailableLayersChangedLocked(newLayers)
+}
+
+func (s *StreamTrackerManager) notifyAvailableLayersChangedLocked(availableLayers []int32) {
+	for _, f := range s.onAvailableLayersChanged {
+		onAvailableLayersChanged := f
+		s.enqueueLocked(func() {
+			onAvailableLayersChanged(availableLayers)
+		})
 	}
 }
+
+// enqueueLocked queues a notification, queueing under lock keeps notifications in order of change
+func (s *StreamTrackerManager) enqueueLocked(f func()) {
+	s.callbacksQueue.Enqueue(func() {
+		s.callbacksMu.RLock()
+		defer s.callbacksMu.RUnlock()
+
+		// drop notifications queued before close
+		if s.isClosed {
+			return
+		}
+		f()
+	})
+}
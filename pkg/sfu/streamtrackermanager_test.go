@@ -0,0 +1,658 @@
+package sfu
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+func TestStreamTrackerManagerAvailableLayers(t *testing.T) {
+	s := NewStreamTrackerManager(logger.Logger(logger.GetLogger()), DefaultMaxLayerSpatial+1, config.StreamTrackerConfig{})
+
+	defer s.RemoveAllTrackers()
+
+	changed := make(chan [2]int32, 1)
+	s.OnMaxTemporalLayerChanged(func(layer int32, maxTemporalLayer int32) {
+		changed <- [2]int32{layer, maxTemporalLayer}
+	})
+
+	s.addAvailableLayer(1)
+	s.addAvailableLayer(0)
+	require.Equal(t, []int32{0, 1}, s.GetAvailableSpatialLayers())
+
+	s.setMaxTemporalLayer(1, 1)
+	select {
+	case c := <-changed:
+		require.Equal(t, [2]int32{1, 1}, c)
+	case <-time.After(time.Second):
+		t.Fatal("max temporal layer change not notified")
+	}
+
+	layers := s.GetAvailableLayers()
+	require.Equal(t, []int32{0, 1}, layers.Spatial)
+	require.True(t, layers.HasSpatial(1))
+	require.False(t, layers.HasSpatial(2))
+	require.Equal(t, DefaultMaxLayerTemporal, layers.MaxTemporal[0])
+	require.Equal(t, int32(1), layers.MaxTemporal[1])
+	require.Equal(t, int32(1), s.GetMaxTemporalLayer(1))
+
+	// a removed layer starts afresh
+	s.RemoveTracker(1)
+	require.Equal(t, DefaultMaxLayerTemporal, s.GetMaxTemporalLayer(1))
+
+	// returned layers are copies
+	layers = s.GetAvailableLayers()
+	layers.Spatial[0] = 2
+	require.Equal(t, []int32{0, 1}, s.GetAvailableSpatialLayers())
+	require.Equal(t, layers.Generation, s.GetAvailableLayers().Generation)
+	s.removeAvailableLayer(1)
+	require.Greater(t, s.GetAvailableLayers().Generation, layers.Generation)
+}
+
+func TestStreamTrackerManagerMoreSpatialLayers(t *testing.T) {
+	s := NewStreamTrackerManager(logger.Logger(logger.GetLogger()), 5, config.StreamTrackerConfig{})
+	defer s.RemoveAllTrackers()
+
+	s.addAvailableLayer(4)
+	s.addAvailableLayer(3)
+	require.Equal(t, []int32{3, 4}, s.GetAvailableSpatialLayers())
+	require.Equal(t, DefaultMaxLayerTemporal, s.GetMaxTemporalLayer(4))
+	require.Len(t, s.GetLayerBitrates(), 5)
+
+	// out of range layers are ignored
+	s.AddTracker(5)
+	require.Nil(t, s.GetTracker(5))
+	require.Equal(t, InvalidLayerTemporal, s.GetMaxTemporalLayer(5))
+	s.RemoveTracker(5)
+}
+
+func TestStreamTrackerManagerDebugInfo(t *testing.T) {
+	s := NewStreamTrackerManager(logger.Logger(logger.GetLogger()), DefaultMaxLayerSpatial+1, config.StreamTrackerConfig{
+		BaseLayer: config.StreamTrackerLayerConfig{
+			SamplesRequired: 1,
+			CyclesRequired:  1,
+			CycleDuration:   config.Duration(time.Second),
+		},
+	})
+	defer s.RemoveAllTrackers()
+
+	s.AddTracker(0)
+	s.GetTracker(0).Observe(1, -1, 1000, false)
+	require.Eventually(t, func() bool { return s.HasSpatialLayer(0) }, time.Second, 10*time.Millisecond)
+	s.SetMaxExpectedSpatialLayer(1)
+
+	info := s.DebugInfo()
+	require.Equal(t, int32(1), info["MaxExpectedLayer"])
+	require.Equal(t, []int32{0}, info["AvailableLayers"])
+
+	layers := info["Layers"].([]map[string]interface{})
+	require.Len(t, layers, 1)
+	require.Equal(t, 0, layers[0]["Layer"])
+	require.Equal(t, "active", layers[0]["Status"])
+	require.Equal(t, uint32(1), layers[0]["PacketsInCycle"])
+	require.False(t, layers[0]["LastPacketAt"].(time.Time).IsZero())
+
+	// served as JSON by the debug endpoint
+	_, err := json.Marshal(info)
+	require.NoError(t, err)
+}
+
+func TestStreamTrackerManagerExpectedLayerRampUp(t *testing.T) {
+	s := NewStreamTrackerManager(logger.Logger(logger.GetLogger()), DefaultMaxLayerSpatial+1, config.StreamTrackerConfig{})
+	defer s.RemoveAllTrackers()
+
+	s.addAvailableLayer(0)
+	require.True(t, s.IsReducedQuality())
+
+	// higher layers paused as nobody needs them
+	s.SetMaxExpectedSpatialLayer(0)
+	require.False(t, s.IsReducedQuality())
+
+	// not degraded while the publisher starts the layers again
+	s.SetMaxExpectedSpatialLayer(1)
+	require.False(t, s.IsReducedQuality())
+	require.Equal(t, true, s.DebugInfo()["RampingUp"])
+	s.SetMaxExpectedSpatialLayer(2)
+	require.False(t, s.IsReducedQuality())
+	require.Equal(t, int32(0), s.rampUpFromLayer)
+
+	// layers that do not start in time are missing
+	s.lock.Lock()
+	s.rampUpDeadline = time.Now().Add(-time.Millisecond)
+	s.lock.Unlock()
+	require.True(t, s.IsReducedQuality())
+
+	s.addAvailableLayer(1)
+	s.addAvailableLayer(2)
+	require.False(t, s.IsReducedQuality())
+}
+
+func TestStreamTrackerManagerAvailableLayersConcurrent(t *testing.T) {
+	s := NewStreamTrackerManager(logger.Logger(logger.GetLogger()), DefaultMaxLayerSpatial+1, config.StreamTrackerConfig{})
+	defer s.RemoveAllTrackers()
+
+	const iterations = 2000
+	var writers, readers sync.WaitGroup
+	done := make(chan struct{})
+	for layer := int32(0); layer <= DefaultMaxLayerSpatial; layer++ {
+		writers.Add(1)
+		go func(layer int32) {
+			defer writers.Done()
+			for i := 0; i < iterations; i++ {
+				s.addAvailableLayer(layer)
+				s.setMaxTemporalLayer(layer, int32(i)%(DefaultMaxLayerTemporal+1))
+				s.removeAvailableLayer(layer)
+			}
+		}(layer)
+	}
+
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			var generation uint64
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				layers := s.GetAvailableLayers()
+				if layers.Generation < generation {
+					t.Errorf("generation went back from %d to %d", generation, layers.Generation)
+				}
+				generation = layers.Generation
+				for i := 1; i < len(layers.Spatial); i++ {
+					if layers.Spatial[i-1] >= layers.Spatial[i] {
+						t.Errorf("unordered layers: %v", layers.Spatial)
+					}
+				}
+				// callers are free to modify their copy
+				for i := range layers.Spatial {
+					layers.Spatial[i] = -1
+				}
+
+				s.HasSpatialLayer(1)
+				s.IsReducedQuality()
+				s.GetMaxTemporalLayer(2)
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	layers := s.GetAvailableLayers()
+	require.Empty(t, layers.Spatial)
+	// every add and remove is a change
+	require.GreaterOrEqual(t, layers.Generation, uint64((DefaultMaxLayerSpatial+1)*iterations*2))
+}
+
+type layerEvent struct {
+	available bool
+	at        time.Time
+}
+
+// feedLayer observes packets of a layer at a steady cadence till stopped
+func feedLayer(s *StreamTrackerManager, layer int32, interval time.Duration) func() {
+	done := make(chan struct{})
+	tracker := s.GetTracker(layer)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		sn := uint16(0)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sn++
+				tracker.Observe(sn, -1, 1000, false)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func newTestStreamTrackerManager(conf config.StreamTrackerConfig, layer int32) (*StreamTrackerManager, chan layerEvent) {
+	events := make(chan layerEvent, 100)
+	s := NewStreamTrackerManager(logger.Logger(logger.GetLogger()), DefaultMaxLayerSpatial+1, conf)
+	s.OnAvailableLayersChanged(func(availableLayers []int32) {
+		available := false
+		for _, l := range availableLayers {
+			if l == layer {
+				available = true
+			}
+		}
+		events <- layerEvent{available: available, at: time.Now()}
+	})
+	s.AddTracker(layer)
+	return s, events
+}
+
+func waitLayerEvent(t *testing.T, events chan layerEvent, available bool, timeout time.Duration) time.Time {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case ev := <-events:
+			if ev.available == available {
+				return ev.at
+			}
+		case <-deadline:
+			t.Fatalf("layer did not become available: %v within %s", available, timeout)
+			return time.Time{}
+		}
+	}
+}
+
+func TestStreamTrackerManagerDetection(t *testing.T) {
+	t.Run("low frame rate base layer stays available with a longer cycle", func(t *testing.T) {
+		s, events := newTestStreamTrackerManager(config.StreamTrackerConfig{
+			BaseLayer: config.StreamTrackerLayerConfig{
+				SamplesRequired: 1,
+				CyclesRequired:  1,
+				CycleDuration:   config.Duration(500 * time.Millisecond),
+			},
+		}, 0)
+		defer s.RemoveAllTrackers()
+
+		// 5 fps
+		stop := feedLayer(s, 0, 200*time.Millisecond)
+		defer stop()
+		waitLayerEvent(t, events, true, time.Second)
+
+		select {
+		case ev := <-events:
+			t.Fatalf("unexpected change, available: %v", ev.available)
+		case <-time.After(1500 * time.Millisecond):
+		}
+		require.True(t, s.HasSpatialLayer(0))
+	})
+
+	t.Run("same cadence is declared stopped on a shorter cycle", func(t *testing.T) {
+		s, events := newTestStreamTrackerManager(config.StreamTrackerConfig{
+			UpperLayers: config.StreamTrackerLayerConfig{
+				SamplesRequired: 1,
+				CyclesRequired:  1,
+				CycleDuration:   config.Duration(100 * time.Millisecond),
+			},
+		}, 1)
+		defer s.RemoveAllTrackers()
+
+		stop := feedLayer(s, 1, 200*time.Millisecond)
+		defer stop()
+		waitLayerEvent(t, events, true, time.Second)
+		waitLayerEvent(t, events, false, time.Second)
+	})
+
+	t.Run("stop is detected within two cycles", func(t *testing.T) {
+		cycle := 100 * time.Millisecond
+		s, events := newTestStreamTrackerManager(config.StreamTrackerConfig{
+			BaseLayer: config.StreamTrackerLayerConfig{
+				SamplesRequired: 1,
+				CyclesRequired:  1,
+				CycleDuration:   config.Duration(cycle),
+			},
+		}, 0)
+		defer s.RemoveAllTrackers()
+
+		stop := feedLayer(s, 0, 10*time.Millisecond)
+		waitLayerEvent(t, events, true, time.Second)
+		time.Sleep(3 * cycle)
+
+		stoppedAt := time.Now()
+		stop()
+		latency := waitLayerEvent(t, events, false, 10*cycle).Sub(stoppedAt)
+		require.LessOrEqual(t, latency, 2*cycle+50*time.Millisecond)
+	})
+
+	t.Run("restart is declared after the required cycles", func(t *testing.T) {
+		cycle := 50 * time.Millisecond
+		cyclesRequired := 6
+		s, events := newTestStreamTrackerManager(config.StreamTrackerConfig{
+			UpperLayers: config.StreamTrackerLayerConfig{
+				SamplesRequired: 1,
+				CyclesRequired:  uint64(cyclesRequired),
+				CycleDuration:   config.Duration(cycle),
+			},
+		}, 1)
+		defer s.RemoveAllTrackers()
+
+		// first packet declares the layer available right away
+		stop := feedLayer(s, 1, 5*time.Millisecond)
+		waitLayerEvent(t, events, true, time.Second)
+		stop()
+		waitLayerEvent(t, events, false, 10*cycle)
+
+		restartedAt := time.Now()
+		stop = feedLayer(s, 1, 5*time.Millisecond)
+		defer stop()
+		latency := waitLayerEvent(t, events, true, time.Duration(cyclesRequired+10)*cycle).Sub(restartedAt)
+		require.GreaterOrEqual(t, latency, time.Duration(cyclesRequired-1)*cycle)
+		require.LessOrEqual(t, latency, time.Duration(cyclesRequired+1)*cycle+50*time.Millisecond)
+	})
+}
+
+func TestStreamTrackerManagerPausedLayer(t *testing.T) {
+	layerConfig := config.StreamTrackerLayerConfig{
+		SamplesRequired: 1,
+		CyclesRequired:  1,
+		CycleDuration:   config.Duration(50 * time.Millisecond),
+	}
+
+	t.Run("paused layer is not declared stopped while others are", func(t *testing.T) {
+		s, events := newTestStreamTrackerManager(config.StreamTrackerConfig{
+			BaseLayer:   layerConfig,
+			UpperLayers: layerConfig,
+		}, 1)
+		defer s.RemoveAllTrackers()
+		s.AddTracker(0)
+
+		stop0 := feedLayer(s, 0, 5*time.Millisecond)
+		stop1 := feedLayer(s, 1, 5*time.Millisecond)
+		waitLayerEvent(t, events, true, time.Second)
+		require.Eventually(t, func() bool { return s.HasSpatialLayer(0) }, time.Second, 10*time.Millisecond)
+
+		s.SetPausedLayer(1, true)
+		stop1()
+		stop0()
+
+		require.Eventually(t, func() bool { return !s.HasSpatialLayer(0) }, time.Second, 10*time.Millisecond)
+		select {
+		case ev := <-events:
+			require.True(t, ev.available, "paused layer declared stopped")
+		case <-time.After(300 * time.Millisecond):
+		}
+		require.True(t, s.HasSpatialLayer(1))
+	})
+
+	t.Run("resumed layer is available on the first packet", func(t *testing.T) {
+		s, events := newTestStreamTrackerManager(config.StreamTrackerConfig{
+			UpperLayers: config.StreamTrackerLayerConfig{
+				SamplesRequired: 1,
+				CyclesRequired:  20,
+				CycleDuration:   config.Duration(50 * time.Millisecond),
+			},
+		}, 1)
+		defer s.RemoveAllTrackers()
+
+		stop := feedLayer(s, 1, 5*time.Millisecond)
+		waitLayerEvent(t, events, true, time.Second)
+		stop()
+		waitLayerEvent(t, events, false, time.Second)
+
+		// a stopped layer needs 20 cycles to restart, a resumed one does not
+		s.SetPaused(true)
+		s.SetPaused(false)
+		stop = feedLayer(s, 1, 5*time.Millisecond)
+		defer stop()
+		waitLayerEvent(t, events, true, 300*time.Millisecond)
+	})
+
+	t.Run("tracker added while paused starts paused", func(t *testing.T) {
+		s, events := newTestStreamTrackerManager(config.StreamTrackerConfig{
+			UpperLayers: layerConfig,
+		}, 1)
+		defer s.RemoveAllTrackers()
+
+		s.SetPausedLayer(1, true)
+		s.RemoveTracker(1)
+		s.AddTracker(1)
+
+		stop := feedLayer(s, 1, 5*time.Millisecond)
+		defer stop()
+		select {
+		case ev := <-events:
+			t.Fatalf("unexpected change, available: %v", ev.available)
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		s.SetPausedLayer(1, false)
+		waitLayerEvent(t, events, true, time.Second)
+	})
+}
+
+func TestStreamTrackerManagerListeners(t *testing.T) {
+	t.Run("all listeners are notified in order", func(t *testing.T) {
+		s := NewStreamTrackerManager(logger.Logger(logger.GetLogger()), DefaultMaxLayerSpatial+1, config.StreamTrackerConfig{})
+		defer s.RemoveAllTrackers()
+
+		first := make(chan []int32, 10)
+		second := make(chan []int32, 10)
+		s.OnAvailableLayersChanged(func(availableLayers []int32) { first <- availableLayers })
+		s.OnAvailableLayersChanged(func(availableLayers []int32) { second <- availableLayers })
+
+		s.addAvailableLayer(0)
+		s.addAvailableLayer(2)
+		s.removeAvailableLayer(0)
+
+		for _, listener := range []chan []int32{first, second} {
+			for _, expected := range [][]int32{{0}, {0, 2}, {2}} {
+				select {
+				case availableLayers := <-listener:
+					require.Equal(t, expected, availableLayers)
+				case <-time.After(time.Second):
+					t.Fatal("listener not notified")
+				}
+			}
+		}
+	})
+
+	t.Run("slow listener does not hold up detection", func(t *testing.T) {
+		cycle := 50 * time.Millisecond
+		s, events := newTestStreamTrackerManager(config.StreamTrackerConfig{
+			BaseLayer: config.StreamTrackerLayerConfig{
+				SamplesRequired: 1,
+				CyclesRequired:  1,
+				CycleDuration:   config.Duration(cycle),
+			},
+		}, 0)
+		defer s.RemoveAllTrackers()
+
+		s.OnAvailableLayersChanged(func(availableLayers []int32) {
+			time.Sleep(20 * cycle)
+		})
+
+		stop := feedLayer(s, 0, 5*time.Millisecond)
+		waitLayerEvent(t, events, true, time.Second)
+		require.True(t, s.HasSpatialLayer(0))
+
+		// the slow listener is still busy with the first change
+		stop()
+		require.Eventually(t, func() bool {
+			return s.GetTracker(0).Status() == StreamStatusStopped && !s.HasSpatialLayer(0)
+		}, 5*cycle, 5*time.Millisecond)
+
+		// and the change gets to listeners once it is done
+		waitLayerEvent(t, events, false, 30*cycle)
+	})
+
+	t.Run("no notifications after removing all trackers", func(t *testing.T) {
+		s := NewStreamTrackerManager(logger.Logger(logger.GetLogger()), DefaultMaxLayerSpatial+1, config.StreamTrackerConfig{})
+
+		var notified atomic.Int32
+		s.OnAvailableLayersChanged(func(availableLayers []int32) {
+			time.Sleep(50 * time.Millisecond)
+			notified.Inc()
+		})
+
+		s.addAvailableLayer(0)
+		s.addAvailableLayer(1)
+		s.addAvailableLayer(2)
+		time.Sleep(10 * time.Millisecond)
+
+		// waits for the notification in progress and drops the rest
+		s.RemoveAllTrackers()
+		require.Equal(t, int32(1), notified.Load())
+
+		s.removeAvailableLayer(0)
+		time.Sleep(200 * time.Millisecond)
+		require.Equal(t, int32(1), notified.Load())
+	})
+}
+
+func TestStreamTrackerManagerHysteresis(t *testing.T) {
+	newManager := func(hysteresis config.StreamTrackerHysteresisConfig) (*StreamTrackerManager, func() map[LayerFlap]int) {
+		s := NewStreamTrackerManager(logger.Logger(logger.GetLogger()), DefaultMaxLayerSpatial+1, config.StreamTrackerConfig{
+			BaseLayer: config.StreamTrackerLayerConfig{
+				SamplesRequired: 1,
+				CyclesRequired:  1,
+				CycleDuration:   config.Duration(time.Second),
+			},
+			Hysteresis: hysteresis,
+		})
+
+		var lock sync.Mutex
+		flaps := make(map[LayerFlap]int)
+		s.OnLayerFlap(func(layer int32, flap LayerFlap) {
+			lock.Lock()
+			defer lock.Unlock()
+			flaps[flap]++
+		})
+		return s, func() map[LayerFlap]int {
+			lock.Lock()
+			defer lock.Unlock()
+			counts := make(map[LayerFlap]int)
+			for flap, count := range flaps {
+				counts[flap] = count
+			}
+			return counts
+		}
+	}
+
+	// layer 2 hovering at the bandwidth limit
+	oscillate := func(s *StreamTrackerManager, cycles int, period time.Duration, endActive bool) {
+		for i := 0; i < cycles; i++ {
+			s.onLayerStopped(2)
+			time.Sleep(period / 2)
+			if i == cycles-1 && !endActive {
+				return
+			}
+			s.onLayerActive(2)
+			time.Sleep(period / 2)
+		}
+	}
+
+	t.Run("layer is re-added only after being active continuously", func(t *testing.T) {
+		s, flaps := newManager(config.StreamTrackerHysteresisConfig{
+			ReAddAfter: config.Duration(200 * time.Millisecond),
+		})
+		defer s.RemoveAllTrackers()
+
+		s.onLayerActive(2)
+		require.True(t, s.HasSpatialLayer(2))
+
+		oscillate(s, 5, 100*time.Millisecond, false)
+		require.False(t, s.HasSpatialLayer(2))
+		require.Eventually(t, func() bool {
+			return flaps()[LayerFlapReAddSuppressed] == 4
+		}, time.Second, 10*time.Millisecond)
+
+		s.onLayerActive(2)
+		time.Sleep(100 * time.Millisecond)
+		require.False(t, s.HasSpatialLayer(2))
+		require.Eventually(t, func() bool {
+			return s.HasSpatialLayer(2) && flaps()[LayerFlapReAdded] == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("layer is kept through short stops", func(t *testing.T) {
+		s, flaps := newManager(config.StreamTrackerHysteresisConfig{
+			RemovalGrace: config.Duration(200 * time.Millisecond),
+		})
+		defer s.RemoveAllTrackers()
+
+		// base layer keeps the track from being silent
+		s.AddTracker(0)
+		stop := feedLayer(s, 0, 10*time.Millisecond)
+		defer stop()
+		require.Eventually(t, func() bool {
+			return s.HasSpatialLayer(0)
+		}, time.Second, 10*time.Millisecond)
+
+		s.onLayerActive(2)
+		generation := s.GetAvailableLayers().Generation
+
+		oscillate(s, 5, 100*time.Millisecond, true)
+		require.True(t, s.HasSpatialLayer(2))
+		require.Equal(t, generation, s.GetAvailableLayers().Generation)
+		require.Eventually(t, func() bool {
+			return flaps()[LayerFlapRemovalSuppressed] == 5
+		}, time.Second, 10*time.Millisecond)
+
+		s.onLayerStopped(2)
+		time.Sleep(100 * time.Millisecond)
+		require.True(t, s.HasSpatialLayer(2))
+		require.Eventually(t, func() bool {
+			return !s.HasSpatialLayer(2)
+		}, time.Second, 10*time.Millisecond)
+		require.Zero(t, flaps()[LayerFlapReAdded])
+	})
+
+	t.Run("layer is removed right away when the track goes silent", func(t *testing.T) {
+		s, _ := newManager(config.StreamTrackerHysteresisConfig{
+			RemovalGrace: config.Duration(time.Second),
+		})
+		defer s.RemoveAllTrackers()
+
+		s.onLayerActive(1)
+		s.onLayerActive(2)
+		s.onLayerStopped(1)
+		s.onLayerStopped(2)
+		require.Empty(t, s.GetAvailableSpatialLayers())
+	})
+
+	t.Run("flaps go through without hysteresis", func(t *testing.T) {
+		s, flaps := newManager(config.StreamTrackerHysteresisConfig{})
+		defer s.RemoveAllTrackers()
+
+		s.onLayerActive(2)
+		oscillate(s, 5, 20*time.Millisecond, true)
+		require.True(t, s.HasSpatialLayer(2))
+		require.Eventually(t, func() bool {
+			return flaps()[LayerFlapReAdded] == 5
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestStreamTrackerManagerLayerBitrates(t *testing.T) {
+	s := NewStreamTrackerManager(logger.Logger(logger.GetLogger()), DefaultMaxLayerSpatial+1, config.StreamTrackerConfig{
+		BaseLayer: config.StreamTrackerLayerConfig{
+			SamplesRequired: 1,
+			CyclesRequired:  1,
+			CycleDuration:   config.Duration(time.Second),
+		},
+		BitrateWindow: config.Duration(200 * time.Millisecond),
+	})
+	defer s.RemoveAllTrackers()
+
+	bitrateAvailable := make(chan int32, 1)
+	s.OnBitrateAvailable(func(layer int32, bitrate uint64) {
+		bitrateAvailable <- layer
+	})
+
+	s.AddTracker(0)
+	stop := feedLayer(s, 0, 10*time.Millisecond)
+	defer stop()
+
+	select {
+	case layer := <-bitrateAvailable:
+		require.Equal(t, int32(0), layer)
+	case <-time.After(time.Second):
+		t.Fatal("bitrate not available")
+	}
+
+	bitrates := s.GetLayerBitrates()
+	require.NotZero(t, bitrates[0])
+	require.Zero(t, bitrates[1])
+	require.Zero(t, bitrates[2])
+}
@@ -14,6 +14,7 @@ import (
 type AnalyticsService interface {
 	SendStats(ctx context.Context, stats []*livekit.AnalyticsStat)
 	SendEvent(ctx context.Context, events *livekit.AnalyticsEvent)
+	SendTrackQuality(ctx context.Context, batch *TrackQualityBatch)
 }
 
 type analyticsService struct {
@@ -57,3 +58,7 @@ func (a *analyticsService) SendEvent(_ context.Context, event *livekit.Analytics
 		logger.Errorw("failed to send event", err, "eventType", event.Type.String())
 	}
 }
+
+// SendTrackQuality drops the samples, the analytics recorder has no message for them
+func (a *analyticsService) SendTrackQuality(_ context.Context, _ *TrackQualityBatch) {
+}
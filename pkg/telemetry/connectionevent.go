@@ -0,0 +1,45 @@
+package telemetry
+
+import (
+	"strings"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	ConnectionEventICEConnected    = "ice_connected"
+	ConnectionEventICEDisconnected = "ice_disconnected"
+	ConnectionEventICEFailed       = "ice_failed"
+	ConnectionEventICERestarted    = "ice_restarted"
+	ConnectionEventResumed         = "resumed"
+)
+
+type ICECandidate struct {
+	// host, srflx, prflx or relay
+	Type string
+	// udp or tcp
+	Protocol string
+	// address with port
+	Address string
+}
+
+// ConnectionEvent is a change of the ICE connection of a participant's transport
+type ConnectionEvent struct {
+	Type   string
+	Target livekit.SignalTarget
+	// ICE connection states
+	PrevState string
+	State     string
+	// selected candidate pair, nil when none was selected
+	Local  *ICECandidate
+	Remote *ICECandidate
+	// how long the transport was in PrevState. For ice_restarted, since the restart began, and for resumed,
+	// how long the transport has been in State
+	Duration time.Duration
+}
+
+// TargetLabel is the transport's signal target, lower cased
+func (e *ConnectionEvent) TargetLabel() string {
+	return strings.ToLower(e.Target.String())
+}
@@ -0,0 +1,91 @@
+package telemetry
+
+import (
+	"strings"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+const (
+	JoinStageOffer         = "offer"
+	JoinStageAnswer        = "answer"
+	JoinStageICEConnected  = "ice_connected"
+	JoinStageDTLSConnected = "dtls_connected"
+)
+
+// JoinTiming is when a participant's transport reached each stage of its first connection, zero for stages
+// that are unknown. The request and token times are taken on the signal node, the others on the RTC node
+type JoinTiming struct {
+	Target livekit.SignalTarget
+	// connected through a TURN relay
+	TURN bool
+
+	// when the join request reached the signal node, and its token was validated
+	StartedAt        time.Time
+	TokenValidatedAt time.Time
+	// first offer and answer of the transport, sent or received depending on the side offering
+	OfferAt         time.Time
+	AnswerAt        time.Time
+	ICEConnectedAt  time.Time
+	DTLSConnectedAt time.Time
+}
+
+type JoinStage struct {
+	Name string
+	// since the previous stage
+	Duration time.Duration
+}
+
+// Stages returns the stages of the transport connecting, each timed since the previous one
+func (j *JoinTiming) Stages() []JoinStage {
+	var stages []JoinStage
+	prev := j.TokenValidatedAt
+	for _, stage := range []struct {
+		name string
+		at   time.Time
+	}{
+		{JoinStageOffer, j.OfferAt},
+		{JoinStageAnswer, j.AnswerAt},
+		{JoinStageICEConnected, j.ICEConnectedAt},
+		{JoinStageDTLSConnected, j.DTLSConnectedAt},
+	} {
+		if stage.at.IsZero() {
+			continue
+		}
+		if !prev.IsZero() {
+			stages = append(stages, JoinStage{Name: stage.name, Duration: nonNegative(stage.at.Sub(prev))})
+		}
+		prev = stage.at
+	}
+	return stages
+}
+
+// TokenValidation is the time from the join request to its token being validated, 0 when unknown
+func (j *JoinTiming) TokenValidation() time.Duration {
+	if j.StartedAt.IsZero() || j.TokenValidatedAt.IsZero() {
+		return 0
+	}
+	return nonNegative(j.TokenValidatedAt.Sub(j.StartedAt))
+}
+
+// Duration is the time from the join request to the transport being connected, 0 when unknown
+func (j *JoinTiming) Duration() time.Duration {
+	if j.StartedAt.IsZero() || j.DTLSConnectedAt.IsZero() {
+		return 0
+	}
+	return nonNegative(j.DTLSConnectedAt.Sub(j.StartedAt))
+}
+
+// TargetLabel is the transport's signal target, lower cased
+func (j *JoinTiming) TargetLabel() string {
+	return strings.ToLower(j.Target.String())
+}
+
+// stages of the signal and RTC node can come out negative when their clocks are apart
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,425 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/routing"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+	"github.com/livekit/livekit-server/version"
+)
+
+const (
+	otlpScopeName    = "github.com/livekit/livekit-server/pkg/telemetry"
+	otlpSeverityInfo = 9
+
+	otlpDroppedQueueFull    = "queue_full"
+	otlpDroppedExportFailed = "export_failed"
+)
+
+// OTLPExporter is an AnalyticsService exporting events and stats to an OpenTelemetry collector as OTLP/HTTP log
+// records, in the JSON encoding. Records are batched, and dropped when the queue is full or their export fails
+type OTLPExporter struct {
+	conf     config.OTLPConfig
+	url      string
+	client   *http.Client
+	resource otlpResource
+	queue    chan *otlpLogRecord
+
+	// cancelled when flushing on Stop runs out of time
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock    sync.RWMutex
+	stopped bool
+	done    chan struct{}
+}
+
+func NewOTLPExporter(conf config.OTLPConfig, currentNode routing.LocalNode) *OTLPExporter {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &OTLPExporter{
+		conf:   conf,
+		url:    otlpLogsURL(conf),
+		client: &http.Client{Timeout: conf.Timeout.Duration()},
+		resource: otlpResource{Attributes: otlpAttributes{}.
+			str("service.name", "livekit-server").
+			str("service.version", version.Version).
+			str("service.instance.id", currentNode.Id).
+			str("livekit.region", currentNode.Region),
+		},
+		queue:  make(chan *otlpLogRecord, conf.MaxQueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// otlpLogsURL completes the endpoint with a scheme and the logs path when they're left out
+func otlpLogsURL(conf config.OTLPConfig) string {
+	endpoint := conf.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		if conf.Insecure {
+			endpoint = "http://" + endpoint
+		} else {
+			endpoint = "https://" + endpoint
+		}
+	}
+	if u, err := url.Parse(endpoint); err == nil && (u.Path == "" || u.Path == "/") {
+		u.Path = "/v1/logs"
+		endpoint = u.String()
+	}
+	return endpoint
+}
+
+func (e *OTLPExporter) SendStats(_ context.Context, stats []*livekit.AnalyticsStat) {
+	for _, stat := range stats {
+		e.enqueue(otlpStatRecord(stat))
+	}
+}
+
+func (e *OTLPExporter) SendEvent(_ context.Context, event *livekit.AnalyticsEvent) {
+	e.enqueue(otlpEventRecord(event))
+}
+
+func (e *OTLPExporter) SendTrackQuality(_ context.Context, batch *TrackQualityBatch) {
+	for _, sample := range batch.Samples {
+		e.enqueue(otlpTrackQualityRecord(batch, sample))
+	}
+}
+
+func (e *OTLPExporter) enqueue(record *otlpLogRecord) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	if e.stopped {
+		return
+	}
+
+	select {
+	case e.queue <- record:
+	default:
+		prometheus.IncrementOTLPDropped(otlpDroppedQueueFull, 1)
+	}
+}
+
+// Stop exports the queued records, giving up on them after the export timeout
+func (e *OTLPExporter) Stop() {
+	e.lock.Lock()
+	if e.stopped {
+		e.lock.Unlock()
+		return
+	}
+	e.stopped = true
+	close(e.queue)
+	e.lock.Unlock()
+
+	select {
+	case <-e.done:
+	case <-time.After(e.conf.Timeout.Duration()):
+		logger.Warnw("timed out exporting queued analytics", nil)
+		e.cancel()
+		<-e.done
+	}
+	e.cancel()
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.conf.BatchTimeout.Duration())
+	defer ticker.Stop()
+
+	batch := make([]*otlpLogRecord, 0, e.conf.BatchSize)
+	for {
+		select {
+		case record, ok := <-e.queue:
+			if !ok {
+				e.export(batch)
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) < e.conf.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		e.export(batch)
+		batch = batch[:0]
+	}
+}
+
+func (e *OTLPExporter) export(records []*otlpLogRecord) {
+	if len(records) == 0 {
+		return
+	}
+	if err := e.post(records); err != nil {
+		logger.Warnw("could not export analytics", err, "url", e.url, "records", len(records))
+		prometheus.IncrementOTLPDropped(otlpDroppedExportFailed, len(records))
+		return
+	}
+	prometheus.IncrementOTLPExported(len(records))
+}
+
+func (e *OTLPExporter) post(records []*otlpLogRecord) error {
+	payload, err := json.Marshal(&otlpLogsRequest{
+		ResourceLogs: []otlpResourceLogs{{
+			Resource: e.resource,
+			ScopeLogs: []otlpScopeLogs{{
+				Scope:      otlpScope{Name: otlpScopeName, Version: version.Version},
+				LogRecords: records,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequestWithContext(e.ctx, http.MethodPost, e.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.conf.Headers {
+		r.Header.Set(k, v)
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	res, err := e.client.Do(r)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("collector responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+func otlpEventRecord(event *livekit.AnalyticsEvent) *otlpLogRecord {
+	name := strings.ToLower(event.Type.String())
+	attrs := otlpAttributes{}.
+		str("livekit.event", name).
+		str("livekit.room.sid", firstNonEmpty(event.RoomId, event.Room.GetSid())).
+		str("livekit.room.name", event.Room.GetName()).
+		str("livekit.participant.sid", firstNonEmpty(event.ParticipantId, event.Participant.GetSid())).
+		str("livekit.participant.identity", event.Participant.GetIdentity()).
+		str("livekit.track.sid", firstNonEmpty(event.TrackId, event.Track.GetSid())).
+		str("livekit.egress.id", event.EgressId).
+		str("livekit.publisher.identity", event.Publisher.GetIdentity())
+	if event.Track != nil {
+		attrs = attrs.
+			str("livekit.track.type", strings.ToLower(event.Track.Type.String())).
+			str("livekit.track.source", strings.ToLower(event.Track.Source.String())).
+			str("livekit.track.mime_type", event.Track.MimeType)
+	}
+	if event.ClientInfo != nil {
+		attrs = attrs.
+			str("livekit.client.sdk", strings.ToLower(event.ClientInfo.Sdk.String())).
+			str("livekit.client.version", event.ClientInfo.Version).
+			str("livekit.client.os", event.ClientInfo.Os).
+			str("livekit.client.browser", event.ClientInfo.Browser)
+	}
+	if event.ClientMeta != nil {
+		attrs = attrs.
+			str("livekit.client.region", event.ClientMeta.Region).
+			str("livekit.client.node", event.ClientMeta.Node).
+			str("livekit.client.address", event.ClientMeta.ClientAddr)
+		if event.ClientMeta.ClientConnectTime != 0 {
+			attrs = attrs.int("livekit.client.connect_time_ms", int64(event.ClientMeta.ClientConnectTime))
+		}
+	}
+	if event.Type == livekit.AnalyticsEventType_TRACK_MAX_SUBSCRIBED_VIDEO_QUALITY {
+		attrs = attrs.str("livekit.track.max_subscribed_quality", strings.ToLower(event.MaxSubscribedVideoQuality.String()))
+	}
+
+	return newOTLPLogRecord(event.Timestamp.AsTime(), name, attrs)
+}
+
+func otlpStatRecord(stat *livekit.AnalyticsStat) *otlpLogRecord {
+	var packets, packetsLost, nacks, plis, firs, frames int64
+	var bytes uint64
+	var rtt, jitter uint32
+	for _, stream := range stat.Streams {
+		packets += int64(stream.TotalPrimaryPackets) + int64(stream.TotalRetransmitPackets) + int64(stream.TotalPaddingPackets)
+		bytes += stream.TotalPrimaryBytes + stream.TotalRetransmitBytes + stream.TotalPaddingBytes
+		packetsLost += int64(stream.TotalPacketsLost)
+		nacks += int64(stream.TotalNacks)
+		plis += int64(stream.TotalPlis)
+		firs += int64(stream.TotalFirs)
+		frames += int64(stream.TotalFrames)
+		if stream.Rtt > rtt {
+			rtt = stream.Rtt
+		}
+		if stream.Jitter > jitter {
+			jitter = stream.Jitter
+		}
+	}
+
+	attrs := otlpAttributes{}.
+		str("livekit.event", "track_stats").
+		str("livekit.stream_type", strings.ToLower(stat.Kind.String())).
+		str("livekit.room.sid", stat.RoomId).
+		str("livekit.room.name", stat.RoomName).
+		str("livekit.participant.sid", stat.ParticipantId).
+		str("livekit.track.sid", stat.TrackId).
+		double("livekit.score", float64(stat.Score)).
+		int("livekit.packets", packets).
+		int("livekit.bytes", int64(bytes)).
+		int("livekit.packets_lost", packetsLost).
+		int("livekit.nacks", nacks).
+		int("livekit.plis", plis).
+		int("livekit.firs", firs).
+		int("livekit.frames", frames).
+		int("livekit.rtt", int64(rtt)).
+		int("livekit.jitter", int64(jitter))
+
+	at := time.Now()
+	if stat.TimeStamp != nil {
+		at = stat.TimeStamp.AsTime()
+	}
+	return newOTLPLogRecord(at, "track_stats", attrs)
+}
+
+func otlpTrackQualityRecord(batch *TrackQualityBatch, sample *TrackQualitySample) *otlpLogRecord {
+	activeLayers := make([]int64, 0, len(sample.ActiveLayers))
+	for _, layer := range sample.ActiveLayers {
+		activeLayers = append(activeLayers, int64(layer))
+	}
+	layerBitrates := make([]int64, 0, len(sample.LayerBitrates))
+	for _, bitrate := range sample.LayerBitrates {
+		layerBitrates = append(layerBitrates, int64(bitrate))
+	}
+
+	attrs := otlpAttributes{}.
+		str("livekit.event", "track_quality").
+		str("livekit.room.sid", string(batch.RoomID)).
+		str("livekit.room.name", string(batch.RoomName)).
+		str("livekit.participant.sid", string(batch.ParticipantID)).
+		str("livekit.participant.identity", string(batch.Identity)).
+		str("livekit.track.sid", string(sample.TrackID)).
+		int("livekit.interval_ms", sample.Interval.Milliseconds()).
+		int("livekit.bitrate", int64(sample.Bitrate)).
+		int("livekit.packets", int64(sample.Packets)).
+		int("livekit.packets_lost", int64(sample.PacketsLost)).
+		double("livekit.loss_rate", sample.LossRate()).
+		double("livekit.jitter", sample.Jitter).
+		double("livekit.fps", sample.FPS).
+		int("livekit.key_frames", int64(sample.KeyFrames)).
+		ints("livekit.active_layers", activeLayers).
+		ints("livekit.layer_bitrates", layerBitrates)
+
+	return newOTLPLogRecord(sample.At, "track_quality", attrs)
+}
+
+func newOTLPLogRecord(at time.Time, body string, attrs otlpAttributes) *otlpLogRecord {
+	return &otlpLogRecord{
+		TimeUnixNano:         strconv.FormatInt(at.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber:       otlpSeverityInfo,
+		SeverityText:         "INFO",
+		Body:                 otlpAnyValue{StringValue: &body},
+		Attributes:           attrs,
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// OTLP logs in the protobuf JSON mapping, where 64 bit integers are strings
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes otlpAttributes `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope        `json:"scope"`
+	LogRecords []*otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           otlpAttributes `json:"attributes"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+// otlpAttributes leaves out empty strings, so that fields an event doesn't have aren't exported
+type otlpAttributes []otlpKeyValue
+
+func (a otlpAttributes) str(key, value string) otlpAttributes {
+	if value == "" {
+		return a
+	}
+	return append(a, otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}})
+}
+
+func (a otlpAttributes) int(key string, value int64) otlpAttributes {
+	s := strconv.FormatInt(value, 10)
+	return append(a, otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}})
+}
+
+func (a otlpAttributes) double(key string, value float64) otlpAttributes {
+	return append(a, otlpKeyValue{Key: key, Value: otlpAnyValue{DoubleValue: &value}})
+}
+
+func (a otlpAttributes) ints(key string, values []int64) otlpAttributes {
+	array := &otlpArrayValue{Values: make([]otlpAnyValue, 0, len(values))}
+	for _, v := range values {
+		s := strconv.FormatInt(v, 10)
+		array.Values = append(array.Values, otlpAnyValue{IntValue: &s})
+	}
+	return append(a, otlpKeyValue{Key: key, Value: otlpAnyValue{ArrayValue: array}})
+}
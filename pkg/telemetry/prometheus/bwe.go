@@ -0,0 +1,52 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BWEEstimate is a snapshot of a connection's send side bandwidth estimate, a zero Target means no estimate
+type BWEEstimate struct {
+	Target       int64
+	DelayBased   int64
+	LossBased    int64
+	Acknowledged int64
+}
+
+var (
+	promSendSideBWEConnections prometheus.Gauge
+	promSendSideBWEBitrate     *prometheus.GaugeVec
+)
+
+func initBWEStats(nodeID string) {
+	promSendSideBWEConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "send_side_bwe",
+		Name:        "connections",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+	// summed over connections, divide by connections for a per connection average
+	promSendSideBWEBitrate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "send_side_bwe",
+		Name:        "bitrate",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"estimate"})
+
+	prometheus.MustRegister(promSendSideBWEConnections)
+	prometheus.MustRegister(promSendSideBWEBitrate)
+}
+
+// UpdateSendSideBWE moves a connection's contribution to the send side bandwidth estimation gauges from prev to curr
+func UpdateSendSideBWE(prev, curr BWEEstimate) {
+	switch {
+	case prev.Target == 0 && curr.Target != 0:
+		promSendSideBWEConnections.Add(1)
+	case prev.Target != 0 && curr.Target == 0:
+		promSendSideBWEConnections.Sub(1)
+	}
+
+	promSendSideBWEBitrate.WithLabelValues("target").Add(float64(curr.Target - prev.Target))
+	promSendSideBWEBitrate.WithLabelValues("delay_based").Add(float64(curr.DelayBased - prev.DelayBased))
+	promSendSideBWEBitrate.WithLabelValues("loss_based").Add(float64(curr.LossBased - prev.LossBased))
+	promSendSideBWEBitrate.WithLabelValues("acknowledged").Add(float64(curr.Acknowledged - prev.Acknowledged))
+}
@@ -0,0 +1,43 @@
+package prometheus
+
+import (
+	"github.com/livekit/protocol/livekit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	promParticipantConnectionQuality *prometheus.GaugeVec
+	promConnectionQualityChanges     *prometheus.CounterVec
+)
+
+func initConnectionQualityStats(nodeID string) {
+	// participants by their current connection quality rating
+	promParticipantConnectionQuality = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "participant",
+		Name:        "connection_quality",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"quality"})
+	// rating transitions, by the rating moved to
+	promConnectionQualityChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "participant",
+		Name:        "connection_quality_changes",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"quality"})
+
+	prometheus.MustRegister(promParticipantConnectionQuality)
+	prometheus.MustRegister(promConnectionQualityChanges)
+}
+
+func AddConnectionQuality(quality livekit.ConnectionQuality) {
+	promParticipantConnectionQuality.WithLabelValues(quality.String()).Inc()
+}
+
+func SubConnectionQuality(quality livekit.ConnectionQuality) {
+	promParticipantConnectionQuality.WithLabelValues(quality.String()).Dec()
+}
+
+func IncrementConnectionQualityChange(quality livekit.ConnectionQuality) {
+	promConnectionQualityChanges.WithLabelValues(quality.String()).Inc()
+}
@@ -0,0 +1,57 @@
+package prometheus
+
+import (
+	"github.com/livekit/protocol/livekit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DataSourceParticipant = "participant"
+	DataSourceServer      = "server"
+
+	DataDropBufferFull = "buffer_full"
+	DataDropTimeout    = "timeout"
+)
+
+var (
+	promDataPacketTotal *prometheus.CounterVec
+	promDataPacketBytes *prometheus.CounterVec
+	promDataPacketDrops *prometheus.CounterVec
+)
+
+func initDataStats(nodeID string) {
+	// user data packets received by rooms, by who sent them and delivery kind
+	promDataPacketTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "data_packet",
+		Name:        "total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"source", "kind"})
+	promDataPacketBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "data_packet",
+		Name:        "bytes",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"source", "kind"})
+
+	// data packets not sent to a participant, by delivery kind and why
+	promDataPacketDrops = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "data_packet",
+		Name:        "dropped",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"kind", "reason"})
+
+	prometheus.MustRegister(promDataPacketTotal)
+	prometheus.MustRegister(promDataPacketBytes)
+	prometheus.MustRegister(promDataPacketDrops)
+}
+
+func IncrementDataPacket(source string, kind livekit.DataPacket_Kind, size int) {
+	promDataPacketTotal.WithLabelValues(source, kind.String()).Inc()
+	promDataPacketBytes.WithLabelValues(source, kind.String()).Add(float64(size))
+}
+
+func IncrementDataPacketDropped(kind livekit.DataPacket_Kind, reason string) {
+	promDataPacketDrops.WithLabelValues(kind.String(), reason).Inc()
+}
@@ -0,0 +1,34 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	promNodeDraining        prometheus.Gauge
+	promDrainRoomsRemaining prometheus.Gauge
+)
+
+func initDrainStats(nodeID string) {
+	// 1 while the node drains ahead of shutting down, with the rooms it is still waiting on
+	promNodeDraining = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "draining",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+	promDrainRoomsRemaining = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "drain_rooms_remaining",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+
+	prometheus.MustRegister(promNodeDraining)
+	prometheus.MustRegister(promDrainRoomsRemaining)
+}
+
+func SetDraining(roomsRemaining int) {
+	promNodeDraining.Set(1)
+	promDrainRoomsRemaining.Set(float64(roomsRemaining))
+}
@@ -0,0 +1,93 @@
+package prometheus
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultJoinLatencyBuckets are the buckets of the join histograms, in seconds, unless set with SetJoinLatencyBuckets
+var DefaultJoinLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30}
+
+var (
+	joinStatsLock        sync.RWMutex
+	joinNodeID           string
+	promTokenValidation  prometheus.Histogram
+	promJoinStageLatency *prometheus.HistogramVec
+	promJoinLatency      *prometheus.HistogramVec
+)
+
+func initJoinStats(nodeID string) {
+	joinNodeID = nodeID
+	registerJoinStats(DefaultJoinLatencyBuckets)
+}
+
+// SetJoinLatencyBuckets replaces the join histograms with ones using the given buckets, in seconds.
+// Nil restores the defaults
+func SetJoinLatencyBuckets(buckets []float64) {
+	if len(buckets) == 0 {
+		buckets = DefaultJoinLatencyBuckets
+	}
+
+	joinStatsLock.Lock()
+	defer joinStatsLock.Unlock()
+
+	prometheus.Unregister(promTokenValidation)
+	prometheus.Unregister(promJoinStageLatency)
+	prometheus.Unregister(promJoinLatency)
+	registerJoinStats(buckets)
+}
+
+func registerJoinStats(buckets []float64) {
+	// from the join request reaching the signal node to its token being validated
+	promTokenValidation = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "join",
+		Name:        "token_validation_seconds",
+		ConstLabels: prometheus.Labels{"node_id": joinNodeID},
+		Buckets:     buckets,
+	})
+	// time of each stage of a transport connecting, since the previous stage
+	promJoinStageLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "join",
+		Name:        "stage_seconds",
+		ConstLabels: prometheus.Labels{"node_id": joinNodeID},
+		Buckets:     buckets,
+	}, []string{"stage", "target", "turn"})
+	// from the join request to the transport being connected
+	promJoinLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "join",
+		Name:        "duration_seconds",
+		ConstLabels: prometheus.Labels{"node_id": joinNodeID},
+		Buckets:     buckets,
+	}, []string{"target", "turn"})
+
+	prometheus.MustRegister(promTokenValidation)
+	prometheus.MustRegister(promJoinStageLatency)
+	prometheus.MustRegister(promJoinLatency)
+}
+
+func ObserveTokenValidation(d time.Duration) {
+	joinStatsLock.RLock()
+	defer joinStatsLock.RUnlock()
+
+	promTokenValidation.Observe(d.Seconds())
+}
+
+func ObserveJoinStage(stage string, target string, turn bool, d time.Duration) {
+	joinStatsLock.RLock()
+	defer joinStatsLock.RUnlock()
+
+	promJoinStageLatency.WithLabelValues(stage, target, strconv.FormatBool(turn)).Observe(d.Seconds())
+}
+
+func ObserveJoin(target string, turn bool, d time.Duration) {
+	joinStatsLock.RLock()
+	defer joinStatsLock.RUnlock()
+
+	promJoinLatency.WithLabelValues(target, strconv.FormatBool(turn)).Observe(d.Seconds())
+}
@@ -1,11 +1,14 @@
 package prometheus
 
 import (
+	"runtime"
 	"time"
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/utils"
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/livekit/livekit-server/pkg/config"
 )
 
 const livekitNamespace string = "livekit"
@@ -13,6 +16,7 @@ const livekitNamespace string = "livekit"
 var (
 	MessageCounter          *prometheus.CounterVec
 	ServiceOperationCounter *prometheus.CounterVec
+	KeyLimitRejectedCounter *prometheus.CounterVec
 )
 
 func init() {
@@ -37,50 +41,124 @@ func init() {
 		[]string{"type", "status", "error_type"},
 	)
 
+	KeyLimitRejectedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   livekitNamespace,
+			Subsystem:   "node",
+			Name:        "key_limit_rejected",
+			ConstLabels: prometheus.Labels{"node_id": nodeID},
+		},
+		[]string{"api_key", "limit"},
+	)
+
 	prometheus.MustRegister(MessageCounter)
 	prometheus.MustRegister(ServiceOperationCounter)
+	prometheus.MustRegister(KeyLimitRejectedCounter)
 
 	initPacketStats(nodeID)
 	initRoomStats(nodeID)
+	initBWEStats(nodeID)
+	initStreamTrackerStats(nodeID)
+	initPacerStats(nodeID)
+	initConnectionQualityStats(nodeID)
+	initDataStats(nodeID)
+	initWebhookStats(nodeID)
+	initDrainStats(nodeID)
+	initJoinStats(nodeID)
+	initOTLPStats(nodeID)
+	initTURNStats(nodeID)
+	initNodeSelectorStats(nodeID)
+	initNodeStats(nodeID)
 }
 
-func GetUpdatedNodeStats(prev *livekit.NodeStats) (*livekit.NodeStats, error) {
-	numCPUs, avg1Min, avg5Min, avg15Min, err := getSystemStats()
-	if err != nil {
-		return nil, err
+// GetUpdatedNodeStats samples the stats of this node included in conf, updating its Prometheus gauges. Stats that
+// aren't included are left zero
+func GetUpdatedNodeStats(prev *livekit.NodeStats, conf *config.NodeStatsConfig) (*livekit.NodeStats, *NodeStatsReport, error) {
+	stats := &livekit.NodeStats{
+		StartedAt: prev.StartedAt,
+		UpdatedAt: time.Now().Unix(),
+	}
+	report := &NodeStatsReport{Version: NodeStatsVersion}
+
+	if conf.Includes(config.NodeStatCPU) {
+		numCPUs, avg1Min, avg5Min, avg15Min, err := getSystemStats()
+		if err != nil {
+			return nil, nil, err
+		}
+		stats.NumCpus = numCPUs
+		stats.LoadAvgLast1Min = avg1Min
+		stats.LoadAvgLast5Min = avg5Min
+		stats.LoadAvgLast15Min = avg15Min
+		if numCPUs > 0 {
+			promCPULoad.Set(float64(avg1Min / float32(numCPUs)))
+		}
+		report.Reported = append(report.Reported, config.NodeStatCPU)
+	}
+
+	if conf.Includes(config.NodeStatMemory) {
+		var memStats runtime.MemStats
+		runtime.ReadMemStats(&memStats)
+		total, err := getMemoryTotal()
+		if err != nil {
+			return nil, nil, err
+		}
+		report.MemoryUsed = memStats.Sys
+		report.MemoryTotal = total
+		promMemoryUsed.Set(float64(report.MemoryUsed))
+		promMemoryTotal.Set(float64(report.MemoryTotal))
+		report.Reported = append(report.Reported, config.NodeStatMemory)
+	}
+
+	if conf.Includes(config.NodeStatGoroutines) {
+		report.NumGoroutines = int32(runtime.NumGoroutine())
+		promGoroutines.Set(float64(report.NumGoroutines))
+		report.Reported = append(report.Reported, config.NodeStatGoroutines)
+	}
+
+	if conf.Includes(config.NodeStatPeerConnections) {
+		report.NumPeerConnections = peerConnectionTotal.Load()
+		promPeerConnections.Set(float64(report.NumPeerConnections))
+		report.Reported = append(report.Reported, config.NodeStatPeerConnections)
+	}
+
+	if conf.Includes(config.NodeStatParticipants) {
+		stats.NumRooms = roomTotal.Load()
+		stats.NumClients = participantTotal.Load()
+		report.Reported = append(report.Reported, config.NodeStatParticipants)
+	}
+
+	if conf.Includes(config.NodeStatTracks) {
+		stats.NumTracksIn = trackPublishedTotal.Load()
+		stats.NumTracksOut = trackSubscribedTotal.Load()
+		report.Reported = append(report.Reported, config.NodeStatTracks)
+	}
+
+	if conf.Includes(config.NodeStatBandwidth) {
+		// rates need at least a second between samples, until then the previous ones are kept
+		if elapsed := stats.UpdatedAt - prev.UpdatedAt; elapsed > 0 {
+			// relayed traffic loads the node too, and counts towards limit.bytes_per_sec in node selection
+			stats.BytesIn = bytesIn.Load() + turnBytesIn.Load()
+			stats.BytesOut = bytesOut.Load() + turnBytesOut.Load()
+			stats.PacketsIn = packetsIn.Load()
+			stats.PacketsOut = packetsOut.Load()
+			stats.NackTotal = nackTotal.Load()
+			stats.BytesInPerSec = perSec(prev.BytesIn, stats.BytesIn, elapsed)
+			stats.BytesOutPerSec = perSec(prev.BytesOut, stats.BytesOut, elapsed)
+			stats.PacketsInPerSec = perSec(prev.PacketsIn, stats.PacketsIn, elapsed)
+			stats.PacketsOutPerSec = perSec(prev.PacketsOut, stats.PacketsOut, elapsed)
+			stats.NackPerSec = perSec(prev.NackTotal, stats.NackTotal, elapsed)
+		} else {
+			stats.BytesIn, stats.BytesOut = prev.BytesIn, prev.BytesOut
+			stats.PacketsIn, stats.PacketsOut = prev.PacketsIn, prev.PacketsOut
+			stats.NackTotal = prev.NackTotal
+			stats.BytesInPerSec, stats.BytesOutPerSec = prev.BytesInPerSec, prev.BytesOutPerSec
+			stats.PacketsInPerSec, stats.PacketsOutPerSec = prev.PacketsInPerSec, prev.PacketsOutPerSec
+			stats.NackPerSec = prev.NackPerSec
+		}
+		report.Reported = append(report.Reported, config.NodeStatBandwidth)
 	}
 
-	updatedAt := time.Now().Unix()
-	elapsed := updatedAt - prev.UpdatedAt
-
-	bytesInNow := bytesIn.Load()
-	bytesOutNow := bytesOut.Load()
-	packetsInNow := packetsIn.Load()
-	packetsOutNow := packetsOut.Load()
-	nackTotalNow := nackTotal.Load()
-
-	return &livekit.NodeStats{
-		StartedAt:        prev.StartedAt,
-		UpdatedAt:        updatedAt,
-		NumRooms:         roomTotal.Load(),
-		NumClients:       participantTotal.Load(),
-		NumTracksIn:      trackPublishedTotal.Load(),
-		NumTracksOut:     trackSubscribedTotal.Load(),
-		BytesIn:          bytesInNow,
-		BytesOut:         bytesOutNow,
-		PacketsIn:        packetsInNow,
-		PacketsOut:       packetsOutNow,
-		NackTotal:        nackTotalNow,
-		BytesInPerSec:    perSec(prev.BytesIn, bytesInNow, elapsed),
-		BytesOutPerSec:   perSec(prev.BytesOut, bytesOutNow, elapsed),
-		PacketsInPerSec:  perSec(prev.PacketsIn, packetsInNow, elapsed),
-		PacketsOutPerSec: perSec(prev.PacketsOut, packetsOutNow, elapsed),
-		NackPerSec:       perSec(prev.NackTotal, nackTotalNow, elapsed),
-		NumCpus:          numCPUs,
-		LoadAvgLast1Min:  avg1Min,
-		LoadAvgLast5Min:  avg5Min,
-		LoadAvgLast15Min: avg15Min,
-	}, nil
+	return stats, report, nil
 }
 
 func perSec(prev, curr uint64, secs int64) float32 {
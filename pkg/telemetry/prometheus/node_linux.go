@@ -24,3 +24,12 @@ func getSystemStats() (numCPUs uint32, avg1Min, avg5Min, avg15Min float32, err e
 	avg15Min = float32(loadAvg.Last15Min)
 	return
 }
+
+func getMemoryTotal() (uint64, error) {
+	memInfo, err := linuxproc.ReadMemInfo("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	// in kB
+	return memInfo.MemTotal * 1024, nil
+}
@@ -6,3 +6,7 @@ package prometheus
 func getSystemStats() (numCPUs uint32, avg1Min, avg5Min, avg15Min float32, err error) {
 	return
 }
+
+func getMemoryTotal() (uint64, error) {
+	return 0, nil
+}
@@ -0,0 +1,101 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/atomic"
+
+	"github.com/livekit/livekit-server/pkg/config"
+)
+
+// NodeStatsVersion is the version of the NodeStatsReport published by this node. Versions only add fields, so that
+// nodes read the fields they know in reports of other versions during rolling upgrades
+const NodeStatsVersion = 1
+
+// NodeStatsReport is published with the node's registration, next to its livekit.NodeStats. It lists the stats the
+// node reports, and holds those livekit.NodeStats has no field for
+type NodeStatsReport struct {
+	Version  int      `json:"version"`
+	Reported []string `json:"reported"`
+	// bytes obtained from the OS by the process
+	MemoryUsed uint64 `json:"memoryUsed,omitempty"`
+	// bytes of memory of the machine, 0 when unknown
+	MemoryTotal        uint64 `json:"memoryTotal,omitempty"`
+	NumGoroutines      int32  `json:"numGoroutines,omitempty"`
+	NumPeerConnections int32  `json:"numPeerConnections,omitempty"`
+}
+
+// Reports returns whether the node reports stat. Nodes from before reports were published have none, they report
+// every stat in livekit.NodeStats and none of the others
+func (r *NodeStatsReport) Reports(stat string) bool {
+	if r == nil {
+		switch stat {
+		case config.NodeStatCPU, config.NodeStatParticipants, config.NodeStatTracks, config.NodeStatBandwidth:
+			return true
+		}
+		return false
+	}
+	for _, s := range r.Reported {
+		if s == stat {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	peerConnectionTotal atomic.Int32
+
+	promCPULoad         prometheus.Gauge
+	promMemoryUsed      prometheus.Gauge
+	promMemoryTotal     prometheus.Gauge
+	promGoroutines      prometheus.Gauge
+	promPeerConnections prometheus.Gauge
+)
+
+func initNodeStats(nodeID string) {
+	// load average of the last minute, per CPU
+	promCPULoad = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "cpu_load",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+	promMemoryUsed = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "memory_used_bytes",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+	promMemoryTotal = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "memory_total_bytes",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+	promGoroutines = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "goroutines",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+	promPeerConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node",
+		Name:        "peer_connections",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+
+	prometheus.MustRegister(promCPULoad)
+	prometheus.MustRegister(promMemoryUsed)
+	prometheus.MustRegister(promMemoryTotal)
+	prometheus.MustRegister(promGoroutines)
+	prometheus.MustRegister(promPeerConnections)
+}
+
+func AddPeerConnection() {
+	peerConnectionTotal.Inc()
+}
+
+func SubPeerConnection() {
+	peerConnectionTotal.Dec()
+}
@@ -0,0 +1,38 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	promOTLPExported prometheus.Counter
+	promOTLPDropped  *prometheus.CounterVec
+)
+
+func initOTLPStats(nodeID string) {
+	// analytics records accepted by the OpenTelemetry collector, and records dropped by reason
+	promOTLPExported = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "otlp",
+		Name:        "exported_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+	promOTLPDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "otlp",
+		Name:        "dropped_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"reason"})
+
+	prometheus.MustRegister(promOTLPExported)
+	prometheus.MustRegister(promOTLPDropped)
+}
+
+func IncrementOTLPExported(records int) {
+	promOTLPExported.Add(float64(records))
+}
+
+// IncrementOTLPDropped counts records dropped because the queue was full, or the export failed
+func IncrementOTLPDropped(reason string, records int) {
+	promOTLPDropped.WithLabelValues(reason).Add(float64(records))
+}
@@ -0,0 +1,44 @@
+package prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	promPacerQueueDepth prometheus.Gauge
+	promPacerDelay      *prometheus.HistogramVec
+)
+
+func initPacerStats(nodeID string) {
+	// packets waiting in subscriber pacers, summed over connections
+	promPacerQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "pacer",
+		Name:        "queue_depth",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+	// time packets spend in subscriber pacers
+	promPacerDelay = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "pacer",
+		Name:        "delay_ms",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+		Buckets:     []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2000},
+	}, []string{"priority"})
+
+	prometheus.MustRegister(promPacerQueueDepth)
+	prometheus.MustRegister(promPacerDelay)
+}
+
+// UpdatePacerQueueDepth moves a connection's contribution to the pacer queue depth gauge from prev to curr
+func UpdatePacerQueueDepth(prev, curr int) {
+	if curr != prev {
+		promPacerQueueDepth.Add(float64(curr - prev))
+	}
+}
+
+func ObservePacerDelay(priority string, delay time.Duration) {
+	promPacerDelay.WithLabelValues(priority).Observe(float64(delay) / float64(time.Millisecond))
+}
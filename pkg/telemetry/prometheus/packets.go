@@ -26,6 +26,14 @@ var (
 	promNackTotal   *prometheus.CounterVec
 	promPliTotal    *prometheus.CounterVec
 	promFirTotal    *prometheus.CounterVec
+
+	promInvalidLayerTotal prometheus.Counter
+	promICEFailureTotal   *prometheus.CounterVec
+
+	promICEConnectedTotal    *prometheus.CounterVec
+	promICEDisconnectedTotal *prometheus.CounterVec
+	promICERestartTotal      *prometheus.CounterVec
+	promResumeTotal          prometheus.Counter
 )
 
 func initPacketStats(nodeID string) {
@@ -59,12 +67,59 @@ func initPacketStats(nodeID string) {
 		Name:        "total",
 		ConstLabels: prometheus.Labels{"node_id": nodeID},
 	}, promPacketLabels)
+	// incoming media of spatial layers beyond rtc.max_spatial_layers, dropped
+	promInvalidLayerTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "packet",
+		Name:        "invalid_layer_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
 
 	prometheus.MustRegister(promPacketTotal)
 	prometheus.MustRegister(promPacketBytes)
 	prometheus.MustRegister(promNackTotal)
 	prometheus.MustRegister(promPliTotal)
 	prometheus.MustRegister(promFirTotal)
+	promICEFailureTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "ice",
+		Name:        "failure_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"target"})
+	// ICE connections by the type and protocol of the remote candidate, i.e. relay for TURN, tcp for ICE over TCP
+	promICEConnectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "ice",
+		Name:        "connected_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"target", "candidate_type", "protocol"})
+	promICEDisconnectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "ice",
+		Name:        "disconnected_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"target"})
+	// ICE restarts that connected again
+	promICERestartTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "ice",
+		Name:        "restart_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"target"})
+	// participants resuming their session after reconnecting signaling
+	promResumeTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "participant",
+		Name:        "resume_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
+
+	prometheus.MustRegister(promInvalidLayerTotal)
+	prometheus.MustRegister(promICEFailureTotal)
+	prometheus.MustRegister(promICEConnectedTotal)
+	prometheus.MustRegister(promICEDisconnectedTotal)
+	prometheus.MustRegister(promICERestartTotal)
+	prometheus.MustRegister(promResumeTotal)
 }
 
 func IncrementPackets(direction Direction, count uint64) {
@@ -97,3 +152,28 @@ func IncrementRTCP(direction Direction, nack, pli, fir uint32) {
 		promFirTotal.WithLabelValues(string(direction)).Add(float64(fir))
 	}
 }
+
+func IncrementInvalidLayer() {
+	promInvalidLayerTotal.Inc()
+}
+
+// IncrementICEFailure counts peer connections whose ICE connection failed, target is publisher or subscriber
+func IncrementICEFailure(target string) {
+	promICEFailureTotal.WithLabelValues(target).Inc()
+}
+
+func IncrementICEConnected(target string, candidateType string, protocol string) {
+	promICEConnectedTotal.WithLabelValues(target, candidateType, protocol).Inc()
+}
+
+func IncrementICEDisconnected(target string) {
+	promICEDisconnectedTotal.WithLabelValues(target).Inc()
+}
+
+func IncrementICERestart(target string) {
+	promICERestartTotal.WithLabelValues(target).Inc()
+}
+
+func IncrementParticipantResume() {
+	promResumeTotal.Inc()
+}
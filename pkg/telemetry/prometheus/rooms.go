@@ -1,6 +1,8 @@
 package prometheus
 
 import (
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -18,6 +20,17 @@ var (
 	promParticipantTotal     prometheus.Gauge
 	promTrackPublishedTotal  *prometheus.GaugeVec
 	promTrackSubscribedTotal *prometheus.GaugeVec
+	promRoomParticipants     *prometheus.GaugeVec
+	promRoomReassigned       prometheus.Counter
+
+	// participants currently in each room of the node, by room name
+	roomsLock        sync.Mutex
+	roomParticipants = make(map[string]int)
+	// rooms given their own series in promRoomParticipants, up to maxLabeledRooms
+	labeledRooms    = make(map[string]bool)
+	maxLabeledRooms int
+
+	participantsPerRoomBuckets = []float64{0, 1, 2, 5, 10, 25, 50, 100, 250, 500, 1000}
 )
 
 func initRoomStats(nodeID string) {
@@ -46,60 +59,173 @@ func initRoomStats(nodeID string) {
 		Subsystem:   "track",
 		Name:        "published_total",
 		ConstLabels: prometheus.Labels{"node_id": nodeID},
-	}, []string{"kind"})
+	}, []string{"kind", "codec"})
 	promTrackSubscribedTotal = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace:   livekitNamespace,
 		Subsystem:   "track",
 		Name:        "subscribed_total",
 		ConstLabels: prometheus.Labels{"node_id": nodeID},
-	}, []string{"kind"})
+	}, []string{"kind", "codec"})
+	// only for rooms within prometheus.max_room_labels
+	promRoomParticipants = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "room",
+		Name:        "participants",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"room"})
+	// rooms this node moved to another node, because theirs was gone
+	promRoomReassigned = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "room",
+		Name:        "reassigned_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	})
 
 	prometheus.MustRegister(promRoomTotal)
 	prometheus.MustRegister(promRoomDuration)
 	prometheus.MustRegister(promParticipantTotal)
 	prometheus.MustRegister(promTrackPublishedTotal)
 	prometheus.MustRegister(promTrackSubscribedTotal)
+	prometheus.MustRegister(promRoomParticipants)
+	prometheus.MustRegister(promRoomReassigned)
+	prometheus.MustRegister(&participantsPerRoomCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(livekitNamespace, "room", "participants_per_room"),
+			"participants currently in each room of the node",
+			nil,
+			prometheus.Labels{"node_id": nodeID},
+		),
+	})
+}
+
+// participantsPerRoomCollector reports the participant count of the node's rooms as a histogram, built when scraped
+type participantsPerRoomCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c *participantsPerRoomCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *participantsPerRoomCollector) Collect(ch chan<- prometheus.Metric) {
+	roomsLock.Lock()
+	buckets := make(map[float64]uint64, len(participantsPerRoomBuckets))
+	for _, bound := range participantsPerRoomBuckets {
+		buckets[bound] = 0
+	}
+	var sum float64
+	for _, count := range roomParticipants {
+		sum += float64(count)
+		for _, bound := range participantsPerRoomBuckets {
+			if float64(count) <= bound {
+				buckets[bound]++
+			}
+		}
+	}
+	rooms := uint64(len(roomParticipants))
+	roomsLock.Unlock()
+
+	ch <- prometheus.MustNewConstHistogram(c.desc, rooms, sum, buckets)
 }
 
-func RoomStarted() {
+// SetMaxLabeledRooms sets how many rooms get their own series in per-room metrics, 0 disables them
+func SetMaxLabeledRooms(max int) {
+	roomsLock.Lock()
+	defer roomsLock.Unlock()
+
+	maxLabeledRooms = max
+}
+
+func RoomStarted(roomName string) {
 	promRoomTotal.Add(1)
 	roomTotal.Inc()
+
+	roomsLock.Lock()
+	defer roomsLock.Unlock()
+	if _, ok := roomParticipants[roomName]; !ok {
+		roomParticipants[roomName] = 0
+	}
+	if !labeledRooms[roomName] && len(labeledRooms) < maxLabeledRooms {
+		labeledRooms[roomName] = true
+		promRoomParticipants.WithLabelValues(roomName).Set(float64(roomParticipants[roomName]))
+	}
+}
+
+func RoomReassigned() {
+	promRoomReassigned.Inc()
 }
 
-func RoomEnded(startedAt time.Time) {
+func RoomEnded(roomName string, startedAt time.Time) {
 	if !startedAt.IsZero() {
 		promRoomDuration.Observe(float64(time.Since(startedAt)) / float64(time.Second))
 	}
 	promRoomTotal.Sub(1)
 	roomTotal.Dec()
+
+	roomsLock.Lock()
+	defer roomsLock.Unlock()
+	delete(roomParticipants, roomName)
+	if labeledRooms[roomName] {
+		delete(labeledRooms, roomName)
+		promRoomParticipants.DeleteLabelValues(roomName)
+	}
 }
 
-func AddParticipant() {
+func AddParticipant(roomName string) {
 	promParticipantTotal.Add(1)
 	participantTotal.Inc()
+	updateRoomParticipants(roomName, 1)
 }
 
-func SubParticipant() {
+func SubParticipant(roomName string) {
 	promParticipantTotal.Sub(1)
 	participantTotal.Dec()
+	updateRoomParticipants(roomName, -1)
 }
 
-func AddPublishedTrack(kind string) {
-	promTrackPublishedTotal.WithLabelValues(kind).Add(1)
+func updateRoomParticipants(roomName string, delta int) {
+	roomsLock.Lock()
+	defer roomsLock.Unlock()
+
+	count, ok := roomParticipants[roomName]
+	if !ok {
+		// room already ended
+		return
+	}
+	count += delta
+	if count < 0 {
+		count = 0
+	}
+	roomParticipants[roomName] = count
+	if labeledRooms[roomName] {
+		promRoomParticipants.WithLabelValues(roomName).Set(float64(count))
+	}
+}
+
+func AddPublishedTrack(kind string, mimeType string) {
+	promTrackPublishedTotal.WithLabelValues(kind, codecLabel(mimeType)).Add(1)
 	trackPublishedTotal.Inc()
 }
 
-func SubPublishedTrack(kind string) {
-	promTrackPublishedTotal.WithLabelValues(kind).Sub(1)
+func SubPublishedTrack(kind string, mimeType string) {
+	promTrackPublishedTotal.WithLabelValues(kind, codecLabel(mimeType)).Sub(1)
 	trackPublishedTotal.Dec()
 }
 
-func AddSubscribedTrack(kind string) {
-	promTrackSubscribedTotal.WithLabelValues(kind).Add(1)
+func AddSubscribedTrack(kind string, mimeType string) {
+	promTrackSubscribedTotal.WithLabelValues(kind, codecLabel(mimeType)).Add(1)
 	trackSubscribedTotal.Inc()
 }
 
-func SubSubscribedTrack(kind string) {
-	promTrackSubscribedTotal.WithLabelValues(kind).Sub(1)
+func SubSubscribedTrack(kind string, mimeType string) {
+	promTrackSubscribedTotal.WithLabelValues(kind, codecLabel(mimeType)).Sub(1)
 	trackSubscribedTotal.Dec()
 }
+
+// codecLabel turns a mime type such as video/VP8 into vp8
+func codecLabel(mimeType string) string {
+	if i := strings.IndexByte(mimeType, '/'); i >= 0 {
+		mimeType = mimeType[i+1:]
+	}
+	return strings.ToLower(mimeType)
+}
@@ -0,0 +1,23 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var promNodeSelectorFallbacks *prometheus.CounterVec
+
+func initNodeSelectorStats(nodeID string) {
+	// rooms placed outside the region nearest to the client, because it had no capacity
+	promNodeSelectorFallbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "node_selector",
+		Name:        "region_fallbacks_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"from_region", "to_region"})
+
+	prometheus.MustRegister(promNodeSelectorFallbacks)
+}
+
+func IncrementRegionFallback(fromRegion, toRegion string) {
+	promNodeSelectorFallbacks.WithLabelValues(fromRegion, toRegion).Inc()
+}
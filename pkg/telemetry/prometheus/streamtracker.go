@@ -0,0 +1,66 @@
+package prometheus
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	promStreamTrackerLayerFlaps *prometheus.CounterVec
+	promPublishedLayerBitrate   *prometheus.GaugeVec
+	promStreamIssues            *prometheus.CounterVec
+)
+
+func initStreamTrackerStats(nodeID string) {
+	// outcomes of published layers stopping and resuming, used to tune the stream tracker hysteresis
+	promStreamTrackerLayerFlaps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "stream_tracker",
+		Name:        "layer_flaps",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"layer", "outcome"})
+	// measured bitrate of published layers summed over tracks
+	promPublishedLayerBitrate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "track",
+		Name:        "published_layer_bitrate",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"layer"})
+	// published layers that keep flowing but cannot be rendered even after requesting a key frame
+	promStreamIssues = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "track",
+		Name:        "stream_issues",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"reason"})
+
+	prometheus.MustRegister(promStreamTrackerLayerFlaps)
+	prometheus.MustRegister(promPublishedLayerBitrate)
+	prometheus.MustRegister(promStreamIssues)
+}
+
+func IncrementStreamTrackerLayerFlap(layer int32, outcome string) {
+	promStreamTrackerLayerFlaps.WithLabelValues(strconv.Itoa(int(layer)), outcome).Inc()
+}
+
+func IncrementStreamIssue(reason string) {
+	promStreamIssues.WithLabelValues(reason).Inc()
+}
+
+// UpdatePublishedLayerBitrates moves a published track's contribution to the layer bitrate gauge from prev to curr,
+// both indexed by spatial layer, missing layers have no bitrate
+func UpdatePublishedLayerBitrates(prev, curr []uint64) {
+	for layer := 0; layer < len(prev) || layer < len(curr); layer++ {
+		var delta float64
+		if layer < len(curr) {
+			delta += float64(curr[layer])
+		}
+		if layer < len(prev) {
+			delta -= float64(prev[layer])
+		}
+		if delta != 0 {
+			promPublishedLayerBitrate.WithLabelValues(strconv.Itoa(layer)).Add(delta)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/atomic"
+)
+
+var (
+	turnAllocations        atomic.Int64
+	turnAllocationsCreated atomic.Uint64
+	turnAllocationsExpired atomic.Uint64
+	turnBytesIn            atomic.Uint64
+	turnBytesOut           atomic.Uint64
+	turnAuthFailures       atomic.Uint64
+
+	promTURNAllocations        *prometheus.GaugeVec
+	promTURNAllocationsCreated *prometheus.CounterVec
+	promTURNAllocationsExpired *prometheus.CounterVec
+	promTURNRelayedBytes       *prometheus.CounterVec
+	promTURNAuthFailures       *prometheus.CounterVec
+)
+
+// TURNStats are the totals of the embedded TURN server since the node started
+type TURNStats struct {
+	Allocations        int64
+	AllocationsCreated uint64
+	AllocationsExpired uint64
+	BytesIn            uint64
+	BytesOut           uint64
+	AuthFailures       uint64
+}
+
+func initTURNStats(nodeID string) {
+	// relays allocated by the embedded TURN server, by the protocol clients reach it with (udp or tls)
+	promTURNAllocations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "turn",
+		Name:        "allocations",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"protocol"})
+	promTURNAllocationsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "turn",
+		Name:        "allocations_created_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"protocol"})
+	// released by the client or timed out without a refresh
+	promTURNAllocationsExpired = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "turn",
+		Name:        "allocations_expired_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"protocol"})
+	// bytes relayed from (incoming) and to (outgoing) peers
+	promTURNRelayedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "turn",
+		Name:        "relayed_bytes_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"direction", "protocol"})
+	promTURNAuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "turn",
+		Name:        "auth_failures_total",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"reason"})
+
+	prometheus.MustRegister(promTURNAllocations)
+	prometheus.MustRegister(promTURNAllocationsCreated)
+	prometheus.MustRegister(promTURNAllocationsExpired)
+	prometheus.MustRegister(promTURNRelayedBytes)
+	prometheus.MustRegister(promTURNAuthFailures)
+}
+
+func AddTURNAllocation(protocol string) {
+	turnAllocations.Inc()
+	turnAllocationsCreated.Inc()
+	promTURNAllocations.WithLabelValues(protocol).Inc()
+	promTURNAllocationsCreated.WithLabelValues(protocol).Inc()
+}
+
+func SubTURNAllocation(protocol string) {
+	turnAllocations.Dec()
+	turnAllocationsExpired.Inc()
+	promTURNAllocations.WithLabelValues(protocol).Dec()
+	promTURNAllocationsExpired.WithLabelValues(protocol).Inc()
+}
+
+// IncrementTURNRelayedBytes counts relayed bytes, which are also part of the node's bytes in and out
+func IncrementTURNRelayedBytes(protocol string, direction Direction, count int) {
+	switch direction {
+	case Incoming:
+		turnBytesIn.Add(uint64(count))
+	case Outgoing:
+		turnBytesOut.Add(uint64(count))
+	}
+	promTURNRelayedBytes.WithLabelValues(string(direction), protocol).Add(float64(count))
+}
+
+func IncrementTURNAuthFailure(reason string) {
+	turnAuthFailures.Inc()
+	promTURNAuthFailures.WithLabelValues(reason).Inc()
+}
+
+func GetTURNStats() TURNStats {
+	return TURNStats{
+		Allocations:        turnAllocations.Load(),
+		AllocationsCreated: turnAllocationsCreated.Load(),
+		AllocationsExpired: turnAllocationsExpired.Load(),
+		BytesIn:            turnBytesIn.Load(),
+		BytesOut:           turnBytesOut.Load(),
+		AuthFailures:       turnAuthFailures.Load(),
+	}
+}
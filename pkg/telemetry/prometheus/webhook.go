@@ -0,0 +1,49 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	promWebhookDelivered *prometheus.CounterVec
+	promWebhookRetries   *prometheus.CounterVec
+	promWebhookFailed    *prometheus.CounterVec
+)
+
+func initWebhookStats(nodeID string) {
+	// webhook events accepted by a URL, retries of failed posts, and events given up on
+	promWebhookDelivered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "webhook",
+		Name:        "delivered",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"url"})
+	promWebhookRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "webhook",
+		Name:        "retries",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"url"})
+	promWebhookFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   livekitNamespace,
+		Subsystem:   "webhook",
+		Name:        "failed",
+		ConstLabels: prometheus.Labels{"node_id": nodeID},
+	}, []string{"url"})
+
+	prometheus.MustRegister(promWebhookDelivered)
+	prometheus.MustRegister(promWebhookRetries)
+	prometheus.MustRegister(promWebhookFailed)
+}
+
+func IncrementWebhookDelivered(url string) {
+	promWebhookDelivered.WithLabelValues(url).Inc()
+}
+
+func IncrementWebhookRetry(url string) {
+	promWebhookRetries.WithLabelValues(url).Inc()
+}
+
+func IncrementWebhookFailed(url string) {
+	promWebhookFailed.WithLabelValues(url).Inc()
+}
@@ -2,6 +2,7 @@ package telemetry
 
 import (
 	"context"
+	"sync"
 
 	"google.golang.org/protobuf/proto"
 
@@ -68,12 +69,17 @@ type StatsWorker struct {
 	roomID        livekit.RoomID
 	roomName      livekit.RoomName
 	participantID livekit.ParticipantID
+	identity      livekit.ParticipantIdentity
 
 	outgoingPerTrack map[livekit.TrackID]Stats
 	incomingPerTrack map[livekit.TrackID]Stats
 
 	// clean up stats for unpublished tracks
 	closedTracks map[livekit.TrackID]bool
+
+	// quality samples are added by the track samplers' goroutines
+	qualityLock    sync.Mutex
+	qualitySamples []*TrackQualitySample
 }
 
 func newStatsWorker(
@@ -82,6 +88,7 @@ func newStatsWorker(
 	roomID livekit.RoomID,
 	roomName livekit.RoomName,
 	participantID livekit.ParticipantID,
+	identity livekit.ParticipantIdentity,
 ) *StatsWorker {
 	s := &StatsWorker{
 		ctx:           ctx,
@@ -89,6 +96,7 @@ func newStatsWorker(
 		roomID:        roomID,
 		roomName:      roomName,
 		participantID: participantID,
+		identity:      identity,
 
 		outgoingPerTrack: make(map[livekit.TrackID]Stats),
 		incomingPerTrack: make(map[livekit.TrackID]Stats),
@@ -117,6 +125,12 @@ func (s *StatsWorker) OnTrackStat(trackID livekit.TrackID, direction livekit.Str
 	}
 }
 
+func (s *StatsWorker) OnTrackQualitySample(sample *TrackQualitySample) {
+	s.qualityLock.Lock()
+	s.qualitySamples = append(s.qualitySamples, sample)
+	s.qualityLock.Unlock()
+}
+
 func (s *StatsWorker) CleanUpTrackStats() {
 	if len(s.closedTracks) > 0 {
 		for trackID := range s.closedTracks {
@@ -137,6 +151,25 @@ func (s *StatsWorker) Update() {
 		s.t.Report(s.ctx, stats)
 	}
 	s.CleanUpTrackStats()
+	s.sendTrackQuality()
+}
+
+func (s *StatsWorker) sendTrackQuality() {
+	s.qualityLock.Lock()
+	samples := s.qualitySamples
+	s.qualitySamples = nil
+	s.qualityLock.Unlock()
+
+	if len(samples) == 0 {
+		return
+	}
+	s.t.ReportTrackQuality(s.ctx, &TrackQualityBatch{
+		RoomID:        s.roomID,
+		RoomName:      s.roomName,
+		ParticipantID: s.participantID,
+		Identity:      s.identity,
+		Samples:       samples,
+	})
 }
 
 func (s *StatsWorker) collectDownstreamStats(ts *timestamppb.Timestamp, stats []*livekit.AnalyticsStat) []*livekit.AnalyticsStat {
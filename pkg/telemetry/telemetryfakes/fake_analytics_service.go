@@ -22,6 +22,12 @@ type FakeAnalyticsService struct {
 		arg1 context.Context
 		arg2 []*livekit.AnalyticsStat
 	}
+	SendTrackQualityStub        func(context.Context, *telemetry.TrackQualityBatch)
+	sendTrackQualityMutex       sync.RWMutex
+	sendTrackQualityArgsForCall []struct {
+		arg1 context.Context
+		arg2 *telemetry.TrackQualityBatch
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -97,6 +103,39 @@ func (fake *FakeAnalyticsService) SendStatsArgsForCall(i int) (context.Context,
 	return argsForCall.arg1, argsForCall.arg2
 }
 
+func (fake *FakeAnalyticsService) SendTrackQuality(arg1 context.Context, arg2 *telemetry.TrackQualityBatch) {
+	fake.sendTrackQualityMutex.Lock()
+	fake.sendTrackQualityArgsForCall = append(fake.sendTrackQualityArgsForCall, struct {
+		arg1 context.Context
+		arg2 *telemetry.TrackQualityBatch
+	}{arg1, arg2})
+	stub := fake.SendTrackQualityStub
+	fake.recordInvocation("SendTrackQuality", []interface{}{arg1, arg2})
+	fake.sendTrackQualityMutex.Unlock()
+	if stub != nil {
+		fake.SendTrackQualityStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeAnalyticsService) SendTrackQualityCallCount() int {
+	fake.sendTrackQualityMutex.RLock()
+	defer fake.sendTrackQualityMutex.RUnlock()
+	return len(fake.sendTrackQualityArgsForCall)
+}
+
+func (fake *FakeAnalyticsService) SendTrackQualityCalls(stub func(context.Context, *telemetry.TrackQualityBatch)) {
+	fake.sendTrackQualityMutex.Lock()
+	defer fake.sendTrackQualityMutex.Unlock()
+	fake.SendTrackQualityStub = stub
+}
+
+func (fake *FakeAnalyticsService) SendTrackQualityArgsForCall(i int) (context.Context, *telemetry.TrackQualityBatch) {
+	fake.sendTrackQualityMutex.RLock()
+	defer fake.sendTrackQualityMutex.RUnlock()
+	argsForCall := fake.sendTrackQualityArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
 func (fake *FakeAnalyticsService) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -104,6 +143,8 @@ func (fake *FakeAnalyticsService) Invocations() map[string][][]interface{} {
 	defer fake.sendEventMutex.RUnlock()
 	fake.sendStatsMutex.RLock()
 	defer fake.sendStatsMutex.RUnlock()
+	fake.sendTrackQualityMutex.RLock()
+	defer fake.sendTrackQualityMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -10,6 +10,28 @@ import (
 )
 
 type FakeTelemetryService struct {
+	AddTrackQualitySourceStub        func(livekit.ParticipantID, livekit.TrackID, telemetry.TrackQualitySource)
+	addTrackQualitySourceMutex       sync.RWMutex
+	addTrackQualitySourceArgsForCall []struct {
+		arg1 livekit.ParticipantID
+		arg2 livekit.TrackID
+		arg3 telemetry.TrackQualitySource
+	}
+	ConnectionChangedStub        func(context.Context, livekit.ParticipantID, *telemetry.ConnectionEvent)
+	connectionChangedMutex       sync.RWMutex
+	connectionChangedArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *telemetry.ConnectionEvent
+	}
+	ConnectionQualityChangedStub        func(context.Context, livekit.ParticipantID, livekit.ConnectionQuality, *livekit.ConnectionQualityInfo)
+	connectionQualityChangedMutex       sync.RWMutex
+	connectionQualityChangedArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 livekit.ConnectionQuality
+		arg4 *livekit.ConnectionQualityInfo
+	}
 	EgressEndedStub        func(context.Context, *livekit.EgressInfo)
 	egressEndedMutex       sync.RWMutex
 	egressEndedArgsForCall []struct {
@@ -22,12 +44,13 @@ type FakeTelemetryService struct {
 		arg1 context.Context
 		arg2 *livekit.EgressInfo
 	}
-	ParticipantActiveStub        func(context.Context, livekit.ParticipantID, *livekit.AnalyticsClientMeta)
+	ParticipantActiveStub        func(context.Context, livekit.ParticipantID, *livekit.AnalyticsClientMeta, *telemetry.JoinTiming)
 	participantActiveMutex       sync.RWMutex
 	participantActiveArgsForCall []struct {
 		arg1 context.Context
 		arg2 livekit.ParticipantID
 		arg3 *livekit.AnalyticsClientMeta
+		arg4 *telemetry.JoinTiming
 	}
 	ParticipantJoinedStub        func(context.Context, *livekit.Room, *livekit.ParticipantInfo, *livekit.ClientInfo, *livekit.AnalyticsClientMeta)
 	participantJoinedMutex       sync.RWMutex
@@ -45,6 +68,21 @@ type FakeTelemetryService struct {
 		arg2 *livekit.Room
 		arg3 *livekit.ParticipantInfo
 	}
+	ParticipantPendingStub        func(context.Context, *livekit.Room, *livekit.ParticipantInfo)
+	participantPendingMutex       sync.RWMutex
+	participantPendingArgsForCall []struct {
+		arg1 context.Context
+		arg2 *livekit.Room
+		arg3 *livekit.ParticipantInfo
+	}
+	ParticipantUpdatedStub        func(context.Context, *livekit.Room, *livekit.ParticipantInfo, *livekit.ParticipantPermission)
+	participantUpdatedMutex       sync.RWMutex
+	participantUpdatedArgsForCall []struct {
+		arg1 context.Context
+		arg2 *livekit.Room
+		arg3 *livekit.ParticipantInfo
+		arg4 *livekit.ParticipantPermission
+	}
 	RecordingEndedStub        func(context.Context, *livekit.RecordingInfo)
 	recordingEndedMutex       sync.RWMutex
 	recordingEndedArgsForCall []struct {
@@ -63,6 +101,18 @@ type FakeTelemetryService struct {
 		arg1 context.Context
 		arg2 *livekit.Room
 	}
+	RoomMetadataChangedStub        func(context.Context, *livekit.Room)
+	roomMetadataChangedMutex       sync.RWMutex
+	roomMetadataChangedArgsForCall []struct {
+		arg1 context.Context
+		arg2 *livekit.Room
+	}
+	RoomReassignedStub        func(context.Context, *livekit.Room)
+	roomReassignedMutex       sync.RWMutex
+	roomReassignedArgsForCall []struct {
+		arg1 context.Context
+		arg2 *livekit.Room
+	}
 	RoomStartedStub        func(context.Context, *livekit.Room)
 	roomStartedMutex       sync.RWMutex
 	roomStartedArgsForCall []struct {
@@ -77,6 +127,14 @@ type FakeTelemetryService struct {
 		arg3 *livekit.TrackInfo
 		arg4 livekit.VideoQuality
 	}
+	TrackMuteChangedStub        func(context.Context, livekit.ParticipantID, *livekit.TrackInfo, bool)
+	trackMuteChangedMutex       sync.RWMutex
+	trackMuteChangedArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *livekit.TrackInfo
+		arg4 bool
+	}
 	TrackPublishedStub        func(context.Context, livekit.ParticipantID, *livekit.TrackInfo)
 	trackPublishedMutex       sync.RWMutex
 	trackPublishedArgsForCall []struct {
@@ -91,6 +149,14 @@ type FakeTelemetryService struct {
 		arg2 livekit.ParticipantID
 		arg3 *livekit.TrackInfo
 	}
+	TrackSilenceChangedStub        func(context.Context, livekit.ParticipantID, *livekit.TrackInfo, bool)
+	trackSilenceChangedMutex       sync.RWMutex
+	trackSilenceChangedArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *livekit.TrackInfo
+		arg4 bool
+	}
 	TrackStatsStub        func(livekit.StreamType, livekit.ParticipantID, livekit.TrackID, *livekit.AnalyticsStat)
 	trackStatsMutex       sync.RWMutex
 	trackStatsArgsForCall []struct {
@@ -99,6 +165,15 @@ type FakeTelemetryService struct {
 		arg3 livekit.TrackID
 		arg4 *livekit.AnalyticsStat
 	}
+	TrackStreamIssueStub        func(context.Context, livekit.ParticipantID, *livekit.TrackInfo, int32, string)
+	trackStreamIssueMutex       sync.RWMutex
+	trackStreamIssueArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *livekit.TrackInfo
+		arg4 int32
+		arg5 string
+	}
 	TrackSubscribedStub        func(context.Context, livekit.ParticipantID, *livekit.TrackInfo, *livekit.ParticipantInfo)
 	trackSubscribedMutex       sync.RWMutex
 	trackSubscribedArgsForCall []struct {
@@ -122,10 +197,120 @@ type FakeTelemetryService struct {
 		arg2 livekit.ParticipantID
 		arg3 *livekit.TrackInfo
 	}
+	TransportConnectedStub        func(context.Context, livekit.ParticipantID, *telemetry.JoinTiming)
+	transportConnectedMutex       sync.RWMutex
+	transportConnectedArgsForCall []struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *telemetry.JoinTiming
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeTelemetryService) AddTrackQualitySource(arg1 livekit.ParticipantID, arg2 livekit.TrackID, arg3 telemetry.TrackQualitySource) {
+	fake.addTrackQualitySourceMutex.Lock()
+	fake.addTrackQualitySourceArgsForCall = append(fake.addTrackQualitySourceArgsForCall, struct {
+		arg1 livekit.ParticipantID
+		arg2 livekit.TrackID
+		arg3 telemetry.TrackQualitySource
+	}{arg1, arg2, arg3})
+	stub := fake.AddTrackQualitySourceStub
+	fake.recordInvocation("AddTrackQualitySource", []interface{}{arg1, arg2, arg3})
+	fake.addTrackQualitySourceMutex.Unlock()
+	if stub != nil {
+		fake.AddTrackQualitySourceStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *FakeTelemetryService) AddTrackQualitySourceCallCount() int {
+	fake.addTrackQualitySourceMutex.RLock()
+	defer fake.addTrackQualitySourceMutex.RUnlock()
+	return len(fake.addTrackQualitySourceArgsForCall)
+}
+
+func (fake *FakeTelemetryService) AddTrackQualitySourceCalls(stub func(livekit.ParticipantID, livekit.TrackID, telemetry.TrackQualitySource)) {
+	fake.addTrackQualitySourceMutex.Lock()
+	defer fake.addTrackQualitySourceMutex.Unlock()
+	fake.AddTrackQualitySourceStub = stub
+}
+
+func (fake *FakeTelemetryService) AddTrackQualitySourceArgsForCall(i int) (livekit.ParticipantID, livekit.TrackID, telemetry.TrackQualitySource) {
+	fake.addTrackQualitySourceMutex.RLock()
+	defer fake.addTrackQualitySourceMutex.RUnlock()
+	argsForCall := fake.addTrackQualitySourceArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeTelemetryService) ConnectionChanged(arg1 context.Context, arg2 livekit.ParticipantID, arg3 *telemetry.ConnectionEvent) {
+	fake.connectionChangedMutex.Lock()
+	fake.connectionChangedArgsForCall = append(fake.connectionChangedArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *telemetry.ConnectionEvent
+	}{arg1, arg2, arg3})
+	stub := fake.ConnectionChangedStub
+	fake.recordInvocation("ConnectionChanged", []interface{}{arg1, arg2, arg3})
+	fake.connectionChangedMutex.Unlock()
+	if stub != nil {
+		fake.ConnectionChangedStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *FakeTelemetryService) ConnectionChangedCallCount() int {
+	fake.connectionChangedMutex.RLock()
+	defer fake.connectionChangedMutex.RUnlock()
+	return len(fake.connectionChangedArgsForCall)
+}
+
+func (fake *FakeTelemetryService) ConnectionChangedCalls(stub func(context.Context, livekit.ParticipantID, *telemetry.ConnectionEvent)) {
+	fake.connectionChangedMutex.Lock()
+	defer fake.connectionChangedMutex.Unlock()
+	fake.ConnectionChangedStub = stub
+}
+
+func (fake *FakeTelemetryService) ConnectionChangedArgsForCall(i int) (context.Context, livekit.ParticipantID, *telemetry.ConnectionEvent) {
+	fake.connectionChangedMutex.RLock()
+	defer fake.connectionChangedMutex.RUnlock()
+	argsForCall := fake.connectionChangedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeTelemetryService) ConnectionQualityChanged(arg1 context.Context, arg2 livekit.ParticipantID, arg3 livekit.ConnectionQuality, arg4 *livekit.ConnectionQualityInfo) {
+	fake.connectionQualityChangedMutex.Lock()
+	fake.connectionQualityChangedArgsForCall = append(fake.connectionQualityChangedArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 livekit.ConnectionQuality
+		arg4 *livekit.ConnectionQualityInfo
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.ConnectionQualityChangedStub
+	fake.recordInvocation("ConnectionQualityChanged", []interface{}{arg1, arg2, arg3, arg4})
+	fake.connectionQualityChangedMutex.Unlock()
+	if stub != nil {
+		fake.ConnectionQualityChangedStub(arg1, arg2, arg3, arg4)
+	}
+}
+
+func (fake *FakeTelemetryService) ConnectionQualityChangedCallCount() int {
+	fake.connectionQualityChangedMutex.RLock()
+	defer fake.connectionQualityChangedMutex.RUnlock()
+	return len(fake.connectionQualityChangedArgsForCall)
+}
+
+func (fake *FakeTelemetryService) ConnectionQualityChangedCalls(stub func(context.Context, livekit.ParticipantID, livekit.ConnectionQuality, *livekit.ConnectionQualityInfo)) {
+	fake.connectionQualityChangedMutex.Lock()
+	defer fake.connectionQualityChangedMutex.Unlock()
+	fake.ConnectionQualityChangedStub = stub
+}
+
+func (fake *FakeTelemetryService) ConnectionQualityChangedArgsForCall(i int) (context.Context, livekit.ParticipantID, livekit.ConnectionQuality, *livekit.ConnectionQualityInfo) {
+	fake.connectionQualityChangedMutex.RLock()
+	defer fake.connectionQualityChangedMutex.RUnlock()
+	argsForCall := fake.connectionQualityChangedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
 func (fake *FakeTelemetryService) EgressEnded(arg1 context.Context, arg2 *livekit.EgressInfo) {
 	fake.egressEndedMutex.Lock()
 	fake.egressEndedArgsForCall = append(fake.egressEndedArgsForCall, struct {
@@ -192,18 +377,19 @@ func (fake *FakeTelemetryService) EgressStartedArgsForCall(i int) (context.Conte
 	return argsForCall.arg1, argsForCall.arg2
 }
 
-func (fake *FakeTelemetryService) ParticipantActive(arg1 context.Context, arg2 livekit.ParticipantID, arg3 *livekit.AnalyticsClientMeta) {
+func (fake *FakeTelemetryService) ParticipantActive(arg1 context.Context, arg2 livekit.ParticipantID, arg3 *livekit.AnalyticsClientMeta, arg4 *telemetry.JoinTiming) {
 	fake.participantActiveMutex.Lock()
 	fake.participantActiveArgsForCall = append(fake.participantActiveArgsForCall, struct {
 		arg1 context.Context
 		arg2 livekit.ParticipantID
 		arg3 *livekit.AnalyticsClientMeta
-	}{arg1, arg2, arg3})
+		arg4 *telemetry.JoinTiming
+	}{arg1, arg2, arg3, arg4})
 	stub := fake.ParticipantActiveStub
-	fake.recordInvocation("ParticipantActive", []interface{}{arg1, arg2, arg3})
+	fake.recordInvocation("ParticipantActive", []interface{}{arg1, arg2, arg3, arg4})
 	fake.participantActiveMutex.Unlock()
 	if stub != nil {
-		fake.ParticipantActiveStub(arg1, arg2, arg3)
+		fake.ParticipantActiveStub(arg1, arg2, arg3, arg4)
 	}
 }
 
@@ -213,17 +399,17 @@ func (fake *FakeTelemetryService) ParticipantActiveCallCount() int {
 	return len(fake.participantActiveArgsForCall)
 }
 
-func (fake *FakeTelemetryService) ParticipantActiveCalls(stub func(context.Context, livekit.ParticipantID, *livekit.AnalyticsClientMeta)) {
+func (fake *FakeTelemetryService) ParticipantActiveCalls(stub func(context.Context, livekit.ParticipantID, *livekit.AnalyticsClientMeta, *telemetry.JoinTiming)) {
 	fake.participantActiveMutex.Lock()
 	defer fake.participantActiveMutex.Unlock()
 	fake.ParticipantActiveStub = stub
 }
 
-func (fake *FakeTelemetryService) ParticipantActiveArgsForCall(i int) (context.Context, livekit.ParticipantID, *livekit.AnalyticsClientMeta) {
+func (fake *FakeTelemetryService) ParticipantActiveArgsForCall(i int) (context.Context, livekit.ParticipantID, *livekit.AnalyticsClientMeta, *telemetry.JoinTiming) {
 	fake.participantActiveMutex.RLock()
 	defer fake.participantActiveMutex.RUnlock()
 	argsForCall := fake.participantActiveArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
 func (fake *FakeTelemetryService) ParticipantJoined(arg1 context.Context, arg2 *livekit.Room, arg3 *livekit.ParticipantInfo, arg4 *livekit.ClientInfo, arg5 *livekit.AnalyticsClientMeta) {
@@ -296,6 +482,75 @@ func (fake *FakeTelemetryService) ParticipantLeftArgsForCall(i int) (context.Con
 	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
+func (fake *FakeTelemetryService) ParticipantPending(arg1 context.Context, arg2 *livekit.Room, arg3 *livekit.ParticipantInfo) {
+	fake.participantPendingMutex.Lock()
+	fake.participantPendingArgsForCall = append(fake.participantPendingArgsForCall, struct {
+		arg1 context.Context
+		arg2 *livekit.Room
+		arg3 *livekit.ParticipantInfo
+	}{arg1, arg2, arg3})
+	stub := fake.ParticipantPendingStub
+	fake.recordInvocation("ParticipantPending", []interface{}{arg1, arg2, arg3})
+	fake.participantPendingMutex.Unlock()
+	if stub != nil {
+		fake.ParticipantPendingStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *FakeTelemetryService) ParticipantPendingCallCount() int {
+	fake.participantPendingMutex.RLock()
+	defer fake.participantPendingMutex.RUnlock()
+	return len(fake.participantPendingArgsForCall)
+}
+
+func (fake *FakeTelemetryService) ParticipantPendingCalls(stub func(context.Context, *livekit.Room, *livekit.ParticipantInfo)) {
+	fake.participantPendingMutex.Lock()
+	defer fake.participantPendingMutex.Unlock()
+	fake.ParticipantPendingStub = stub
+}
+
+func (fake *FakeTelemetryService) ParticipantPendingArgsForCall(i int) (context.Context, *livekit.Room, *livekit.ParticipantInfo) {
+	fake.participantPendingMutex.RLock()
+	defer fake.participantPendingMutex.RUnlock()
+	argsForCall := fake.participantPendingArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeTelemetryService) ParticipantUpdated(arg1 context.Context, arg2 *livekit.Room, arg3 *livekit.ParticipantInfo, arg4 *livekit.ParticipantPermission) {
+	fake.participantUpdatedMutex.Lock()
+	fake.participantUpdatedArgsForCall = append(fake.participantUpdatedArgsForCall, struct {
+		arg1 context.Context
+		arg2 *livekit.Room
+		arg3 *livekit.ParticipantInfo
+		arg4 *livekit.ParticipantPermission
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.ParticipantUpdatedStub
+	fake.recordInvocation("ParticipantUpdated", []interface{}{arg1, arg2, arg3, arg4})
+	fake.participantUpdatedMutex.Unlock()
+	if stub != nil {
+		fake.ParticipantUpdatedStub(arg1, arg2, arg3, arg4)
+	}
+}
+
+func (fake *FakeTelemetryService) ParticipantUpdatedCallCount() int {
+	fake.participantUpdatedMutex.RLock()
+	defer fake.participantUpdatedMutex.RUnlock()
+	return len(fake.participantUpdatedArgsForCall)
+}
+
+func (fake *FakeTelemetryService) ParticipantUpdatedCalls(stub func(context.Context, *livekit.Room, *livekit.ParticipantInfo, *livekit.ParticipantPermission)) {
+	fake.participantUpdatedMutex.Lock()
+	defer fake.participantUpdatedMutex.Unlock()
+	fake.ParticipantUpdatedStub = stub
+}
+
+func (fake *FakeTelemetryService) ParticipantUpdatedArgsForCall(i int) (context.Context, *livekit.Room, *livekit.ParticipantInfo, *livekit.ParticipantPermission) {
+	fake.participantUpdatedMutex.RLock()
+	defer fake.participantUpdatedMutex.RUnlock()
+	argsForCall := fake.participantUpdatedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
 func (fake *FakeTelemetryService) RecordingEnded(arg1 context.Context, arg2 *livekit.RecordingInfo) {
 	fake.recordingEndedMutex.Lock()
 	fake.recordingEndedArgsForCall = append(fake.recordingEndedArgsForCall, struct {
@@ -395,6 +650,72 @@ func (fake *FakeTelemetryService) RoomEndedArgsForCall(i int) (context.Context,
 	return argsForCall.arg1, argsForCall.arg2
 }
 
+func (fake *FakeTelemetryService) RoomMetadataChanged(arg1 context.Context, arg2 *livekit.Room) {
+	fake.roomMetadataChangedMutex.Lock()
+	fake.roomMetadataChangedArgsForCall = append(fake.roomMetadataChangedArgsForCall, struct {
+		arg1 context.Context
+		arg2 *livekit.Room
+	}{arg1, arg2})
+	stub := fake.RoomMetadataChangedStub
+	fake.recordInvocation("RoomMetadataChanged", []interface{}{arg1, arg2})
+	fake.roomMetadataChangedMutex.Unlock()
+	if stub != nil {
+		fake.RoomMetadataChangedStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeTelemetryService) RoomMetadataChangedCallCount() int {
+	fake.roomMetadataChangedMutex.RLock()
+	defer fake.roomMetadataChangedMutex.RUnlock()
+	return len(fake.roomMetadataChangedArgsForCall)
+}
+
+func (fake *FakeTelemetryService) RoomMetadataChangedCalls(stub func(context.Context, *livekit.Room)) {
+	fake.roomMetadataChangedMutex.Lock()
+	defer fake.roomMetadataChangedMutex.Unlock()
+	fake.RoomMetadataChangedStub = stub
+}
+
+func (fake *FakeTelemetryService) RoomMetadataChangedArgsForCall(i int) (context.Context, *livekit.Room) {
+	fake.roomMetadataChangedMutex.RLock()
+	defer fake.roomMetadataChangedMutex.RUnlock()
+	argsForCall := fake.roomMetadataChangedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeTelemetryService) RoomReassigned(arg1 context.Context, arg2 *livekit.Room) {
+	fake.roomReassignedMutex.Lock()
+	fake.roomReassignedArgsForCall = append(fake.roomReassignedArgsForCall, struct {
+		arg1 context.Context
+		arg2 *livekit.Room
+	}{arg1, arg2})
+	stub := fake.RoomReassignedStub
+	fake.recordInvocation("RoomReassigned", []interface{}{arg1, arg2})
+	fake.roomReassignedMutex.Unlock()
+	if stub != nil {
+		fake.RoomReassignedStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeTelemetryService) RoomReassignedCallCount() int {
+	fake.roomReassignedMutex.RLock()
+	defer fake.roomReassignedMutex.RUnlock()
+	return len(fake.roomReassignedArgsForCall)
+}
+
+func (fake *FakeTelemetryService) RoomReassignedCalls(stub func(context.Context, *livekit.Room)) {
+	fake.roomReassignedMutex.Lock()
+	defer fake.roomReassignedMutex.Unlock()
+	fake.RoomReassignedStub = stub
+}
+
+func (fake *FakeTelemetryService) RoomReassignedArgsForCall(i int) (context.Context, *livekit.Room) {
+	fake.roomReassignedMutex.RLock()
+	defer fake.roomReassignedMutex.RUnlock()
+	argsForCall := fake.roomReassignedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
 func (fake *FakeTelemetryService) RoomStarted(arg1 context.Context, arg2 *livekit.Room) {
 	fake.roomStartedMutex.Lock()
 	fake.roomStartedArgsForCall = append(fake.roomStartedArgsForCall, struct {
@@ -463,6 +784,41 @@ func (fake *FakeTelemetryService) TrackMaxSubscribedVideoQualityArgsForCall(i in
 	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
+func (fake *FakeTelemetryService) TrackMuteChanged(arg1 context.Context, arg2 livekit.ParticipantID, arg3 *livekit.TrackInfo, arg4 bool) {
+	fake.trackMuteChangedMutex.Lock()
+	fake.trackMuteChangedArgsForCall = append(fake.trackMuteChangedArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *livekit.TrackInfo
+		arg4 bool
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.TrackMuteChangedStub
+	fake.recordInvocation("TrackMuteChanged", []interface{}{arg1, arg2, arg3, arg4})
+	fake.trackMuteChangedMutex.Unlock()
+	if stub != nil {
+		fake.TrackMuteChangedStub(arg1, arg2, arg3, arg4)
+	}
+}
+
+func (fake *FakeTelemetryService) TrackMuteChangedCallCount() int {
+	fake.trackMuteChangedMutex.RLock()
+	defer fake.trackMuteChangedMutex.RUnlock()
+	return len(fake.trackMuteChangedArgsForCall)
+}
+
+func (fake *FakeTelemetryService) TrackMuteChangedCalls(stub func(context.Context, livekit.ParticipantID, *livekit.TrackInfo, bool)) {
+	fake.trackMuteChangedMutex.Lock()
+	defer fake.trackMuteChangedMutex.Unlock()
+	fake.TrackMuteChangedStub = stub
+}
+
+func (fake *FakeTelemetryService) TrackMuteChangedArgsForCall(i int) (context.Context, livekit.ParticipantID, *livekit.TrackInfo, bool) {
+	fake.trackMuteChangedMutex.RLock()
+	defer fake.trackMuteChangedMutex.RUnlock()
+	argsForCall := fake.trackMuteChangedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
 func (fake *FakeTelemetryService) TrackPublished(arg1 context.Context, arg2 livekit.ParticipantID, arg3 *livekit.TrackInfo) {
 	fake.trackPublishedMutex.Lock()
 	fake.trackPublishedArgsForCall = append(fake.trackPublishedArgsForCall, struct {
@@ -531,6 +887,41 @@ func (fake *FakeTelemetryService) TrackPublishedUpdateArgsForCall(i int) (contex
 	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
+func (fake *FakeTelemetryService) TrackSilenceChanged(arg1 context.Context, arg2 livekit.ParticipantID, arg3 *livekit.TrackInfo, arg4 bool) {
+	fake.trackSilenceChangedMutex.Lock()
+	fake.trackSilenceChangedArgsForCall = append(fake.trackSilenceChangedArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *livekit.TrackInfo
+		arg4 bool
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.TrackSilenceChangedStub
+	fake.recordInvocation("TrackSilenceChanged", []interface{}{arg1, arg2, arg3, arg4})
+	fake.trackSilenceChangedMutex.Unlock()
+	if stub != nil {
+		fake.TrackSilenceChangedStub(arg1, arg2, arg3, arg4)
+	}
+}
+
+func (fake *FakeTelemetryService) TrackSilenceChangedCallCount() int {
+	fake.trackSilenceChangedMutex.RLock()
+	defer fake.trackSilenceChangedMutex.RUnlock()
+	return len(fake.trackSilenceChangedArgsForCall)
+}
+
+func (fake *FakeTelemetryService) TrackSilenceChangedCalls(stub func(context.Context, livekit.ParticipantID, *livekit.TrackInfo, bool)) {
+	fake.trackSilenceChangedMutex.Lock()
+	defer fake.trackSilenceChangedMutex.Unlock()
+	fake.TrackSilenceChangedStub = stub
+}
+
+func (fake *FakeTelemetryService) TrackSilenceChangedArgsForCall(i int) (context.Context, livekit.ParticipantID, *livekit.TrackInfo, bool) {
+	fake.trackSilenceChangedMutex.RLock()
+	defer fake.trackSilenceChangedMutex.RUnlock()
+	argsForCall := fake.trackSilenceChangedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
 func (fake *FakeTelemetryService) TrackStats(arg1 livekit.StreamType, arg2 livekit.ParticipantID, arg3 livekit.TrackID, arg4 *livekit.AnalyticsStat) {
 	fake.trackStatsMutex.Lock()
 	fake.trackStatsArgsForCall = append(fake.trackStatsArgsForCall, struct {
@@ -566,6 +957,42 @@ func (fake *FakeTelemetryService) TrackStatsArgsForCall(i int) (livekit.StreamTy
 	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
 }
 
+func (fake *FakeTelemetryService) TrackStreamIssue(arg1 context.Context, arg2 livekit.ParticipantID, arg3 *livekit.TrackInfo, arg4 int32, arg5 string) {
+	fake.trackStreamIssueMutex.Lock()
+	fake.trackStreamIssueArgsForCall = append(fake.trackStreamIssueArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *livekit.TrackInfo
+		arg4 int32
+		arg5 string
+	}{arg1, arg2, arg3, arg4, arg5})
+	stub := fake.TrackStreamIssueStub
+	fake.recordInvocation("TrackStreamIssue", []interface{}{arg1, arg2, arg3, arg4, arg5})
+	fake.trackStreamIssueMutex.Unlock()
+	if stub != nil {
+		fake.TrackStreamIssueStub(arg1, arg2, arg3, arg4, arg5)
+	}
+}
+
+func (fake *FakeTelemetryService) TrackStreamIssueCallCount() int {
+	fake.trackStreamIssueMutex.RLock()
+	defer fake.trackStreamIssueMutex.RUnlock()
+	return len(fake.trackStreamIssueArgsForCall)
+}
+
+func (fake *FakeTelemetryService) TrackStreamIssueCalls(stub func(context.Context, livekit.ParticipantID, *livekit.TrackInfo, int32, string)) {
+	fake.trackStreamIssueMutex.Lock()
+	defer fake.trackStreamIssueMutex.Unlock()
+	fake.TrackStreamIssueStub = stub
+}
+
+func (fake *FakeTelemetryService) TrackStreamIssueArgsForCall(i int) (context.Context, livekit.ParticipantID, *livekit.TrackInfo, int32, string) {
+	fake.trackStreamIssueMutex.RLock()
+	defer fake.trackStreamIssueMutex.RUnlock()
+	argsForCall := fake.trackStreamIssueArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
 func (fake *FakeTelemetryService) TrackSubscribed(arg1 context.Context, arg2 livekit.ParticipantID, arg3 *livekit.TrackInfo, arg4 *livekit.ParticipantInfo) {
 	fake.trackSubscribedMutex.Lock()
 	fake.trackSubscribedArgsForCall = append(fake.trackSubscribedArgsForCall, struct {
@@ -670,9 +1097,49 @@ func (fake *FakeTelemetryService) TrackUnsubscribedArgsForCall(i int) (context.C
 	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
 }
 
+func (fake *FakeTelemetryService) TransportConnected(arg1 context.Context, arg2 livekit.ParticipantID, arg3 *telemetry.JoinTiming) {
+	fake.transportConnectedMutex.Lock()
+	fake.transportConnectedArgsForCall = append(fake.transportConnectedArgsForCall, struct {
+		arg1 context.Context
+		arg2 livekit.ParticipantID
+		arg3 *telemetry.JoinTiming
+	}{arg1, arg2, arg3})
+	stub := fake.TransportConnectedStub
+	fake.recordInvocation("TransportConnected", []interface{}{arg1, arg2, arg3})
+	fake.transportConnectedMutex.Unlock()
+	if stub != nil {
+		fake.TransportConnectedStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *FakeTelemetryService) TransportConnectedCallCount() int {
+	fake.transportConnectedMutex.RLock()
+	defer fake.transportConnectedMutex.RUnlock()
+	return len(fake.transportConnectedArgsForCall)
+}
+
+func (fake *FakeTelemetryService) TransportConnectedCalls(stub func(context.Context, livekit.ParticipantID, *telemetry.JoinTiming)) {
+	fake.transportConnectedMutex.Lock()
+	defer fake.transportConnectedMutex.Unlock()
+	fake.TransportConnectedStub = stub
+}
+
+func (fake *FakeTelemetryService) TransportConnectedArgsForCall(i int) (context.Context, livekit.ParticipantID, *telemetry.JoinTiming) {
+	fake.transportConnectedMutex.RLock()
+	defer fake.transportConnectedMutex.RUnlock()
+	argsForCall := fake.transportConnectedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
 func (fake *FakeTelemetryService) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.addTrackQualitySourceMutex.RLock()
+	defer fake.addTrackQualitySourceMutex.RUnlock()
+	fake.connectionChangedMutex.RLock()
+	defer fake.connectionChangedMutex.RUnlock()
+	fake.connectionQualityChangedMutex.RLock()
+	defer fake.connectionQualityChangedMutex.RUnlock()
 	fake.egressEndedMutex.RLock()
 	defer fake.egressEndedMutex.RUnlock()
 	fake.egressStartedMutex.RLock()
@@ -683,28 +1150,44 @@ func (fake *FakeTelemetryService) Invocations() map[string][][]interface{} {
 	defer fake.participantJoinedMutex.RUnlock()
 	fake.participantLeftMutex.RLock()
 	defer fake.participantLeftMutex.RUnlock()
+	fake.participantPendingMutex.RLock()
+	defer fake.participantPendingMutex.RUnlock()
+	fake.participantUpdatedMutex.RLock()
+	defer fake.participantUpdatedMutex.RUnlock()
 	fake.recordingEndedMutex.RLock()
 	defer fake.recordingEndedMutex.RUnlock()
 	fake.recordingStartedMutex.RLock()
 	defer fake.recordingStartedMutex.RUnlock()
 	fake.roomEndedMutex.RLock()
 	defer fake.roomEndedMutex.RUnlock()
+	fake.roomMetadataChangedMutex.RLock()
+	defer fake.roomMetadataChangedMutex.RUnlock()
+	fake.roomReassignedMutex.RLock()
+	defer fake.roomReassignedMutex.RUnlock()
 	fake.roomStartedMutex.RLock()
 	defer fake.roomStartedMutex.RUnlock()
 	fake.trackMaxSubscribedVideoQualityMutex.RLock()
 	defer fake.trackMaxSubscribedVideoQualityMutex.RUnlock()
+	fake.trackMuteChangedMutex.RLock()
+	defer fake.trackMuteChangedMutex.RUnlock()
 	fake.trackPublishedMutex.RLock()
 	defer fake.trackPublishedMutex.RUnlock()
 	fake.trackPublishedUpdateMutex.RLock()
 	defer fake.trackPublishedUpdateMutex.RUnlock()
+	fake.trackSilenceChangedMutex.RLock()
+	defer fake.trackSilenceChangedMutex.RUnlock()
 	fake.trackStatsMutex.RLock()
 	defer fake.trackStatsMutex.RUnlock()
+	fake.trackStreamIssueMutex.RLock()
+	defer fake.trackStreamIssueMutex.RUnlock()
 	fake.trackSubscribedMutex.RLock()
 	defer fake.trackSubscribedMutex.RUnlock()
 	fake.trackUnpublishedMutex.RLock()
 	defer fake.trackUnpublishedMutex.RUnlock()
 	fake.trackUnsubscribedMutex.RLock()
 	defer fake.trackUnsubscribedMutex.RUnlock()
+	fake.transportConnectedMutex.RLock()
+	defer fake.transportConnectedMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
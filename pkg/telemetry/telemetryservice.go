@@ -6,6 +6,8 @@ import (
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/webhook"
+
+	"github.com/livekit/livekit-server/pkg/config"
 )
 
 const updateFrequency = time.Second * 10
@@ -14,21 +16,34 @@ const updateFrequency = time.Second * 10
 type TelemetryService interface {
 	// stats
 	TrackStats(streamType livekit.StreamType, participantID livekit.ParticipantID, trackID livekit.TrackID, stat *livekit.AnalyticsStat)
+	// samples the quality of a published track until it's unpublished, when enabled
+	AddTrackQualitySource(participantID livekit.ParticipantID, trackID livekit.TrackID, source TrackQualitySource)
 
 	// events
 	RoomStarted(ctx context.Context, room *livekit.Room)
 	RoomEnded(ctx context.Context, room *livekit.Room)
+	RoomMetadataChanged(ctx context.Context, room *livekit.Room)
+	// the room's node is gone, it was moved to another node
+	RoomReassigned(ctx context.Context, room *livekit.Room)
 	ParticipantJoined(ctx context.Context, room *livekit.Room, participant *livekit.ParticipantInfo, clientInfo *livekit.ClientInfo, clientMeta *livekit.AnalyticsClientMeta)
 	ParticipantLeft(ctx context.Context, room *livekit.Room, participant *livekit.ParticipantInfo)
+	ParticipantUpdated(ctx context.Context, room *livekit.Room, participant *livekit.ParticipantInfo, permission *livekit.ParticipantPermission)
+	ParticipantPending(ctx context.Context, room *livekit.Room, participant *livekit.ParticipantInfo)
 	TrackPublished(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo)
 	TrackUnpublished(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo, ssrc uint32)
 	TrackSubscribed(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo, publisher *livekit.ParticipantInfo)
 	TrackUnsubscribed(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo)
 	TrackPublishedUpdate(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo)
 	TrackMaxSubscribedVideoQuality(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo, maxQuality livekit.VideoQuality)
+	TrackStreamIssue(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo, layer int32, reason string)
+	TrackSilenceChanged(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo, silent bool)
+	TrackMuteChanged(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo, muted bool)
+	ConnectionQualityChanged(ctx context.Context, participantID livekit.ParticipantID, prev livekit.ConnectionQuality, info *livekit.ConnectionQualityInfo)
 	RecordingStarted(ctx context.Context, ri *livekit.RecordingInfo)
 	RecordingEnded(ctx context.Context, ri *livekit.RecordingInfo)
-	ParticipantActive(ctx context.Context, participantID livekit.ParticipantID, clientMeta *livekit.AnalyticsClientMeta)
+	ParticipantActive(ctx context.Context, participantID livekit.ParticipantID, clientMeta *livekit.AnalyticsClientMeta, timing *JoinTiming)
+	TransportConnected(ctx context.Context, participantID livekit.ParticipantID, timing *JoinTiming)
+	ConnectionChanged(ctx context.Context, participantID livekit.ParticipantID, event *ConnectionEvent)
 	EgressStarted(ctx context.Context, info *livekit.EgressInfo)
 	EgressEnded(ctx context.Context, info *livekit.EgressInfo)
 }
@@ -42,9 +57,9 @@ type telemetryService struct {
 
 const jobQueueBufferSize = 100
 
-func NewTelemetryService(notifier webhook.Notifier, analytics AnalyticsService) TelemetryService {
+func NewTelemetryService(conf *config.Config, notifier webhook.Notifier, analytics AnalyticsService) TelemetryService {
 	t := &telemetryService{
-		internalService: NewTelemetryServiceInternal(notifier, analytics),
+		internalService: NewTelemetryServiceInternal(conf, notifier, analytics),
 		jobQueue:        make(chan doWorkFunc, jobQueueBufferSize),
 	}
 
@@ -74,6 +89,12 @@ func (t *telemetryService) TrackStats(streamType livekit.StreamType, participant
 	}
 }
 
+func (t *telemetryService) AddTrackQualitySource(participantID livekit.ParticipantID, trackID livekit.TrackID, source TrackQualitySource) {
+	t.jobQueue <- func() {
+		t.internalService.AddTrackQualitySource(participantID, trackID, source)
+	}
+}
+
 func (t *telemetryService) RoomStarted(ctx context.Context, room *livekit.Room) {
 	t.jobQueue <- func() {
 		t.internalService.RoomStarted(ctx, room)
@@ -86,6 +107,18 @@ func (t *telemetryService) RoomEnded(ctx context.Context, room *livekit.Room) {
 	}
 }
 
+func (t *telemetryService) RoomMetadataChanged(ctx context.Context, room *livekit.Room) {
+	t.jobQueue <- func() {
+		t.internalService.RoomMetadataChanged(ctx, room)
+	}
+}
+
+func (t *telemetryService) RoomReassigned(ctx context.Context, room *livekit.Room) {
+	t.jobQueue <- func() {
+		t.internalService.RoomReassigned(ctx, room)
+	}
+}
+
 func (t *telemetryService) ParticipantJoined(ctx context.Context, room *livekit.Room, participant *livekit.ParticipantInfo,
 	clientInfo *livekit.ClientInfo, clientMeta *livekit.AnalyticsClientMeta) {
 	t.jobQueue <- func() {
@@ -99,6 +132,19 @@ func (t *telemetryService) ParticipantLeft(ctx context.Context, room *livekit.Ro
 	}
 }
 
+func (t *telemetryService) ParticipantUpdated(ctx context.Context, room *livekit.Room, participant *livekit.ParticipantInfo,
+	permission *livekit.ParticipantPermission) {
+	t.jobQueue <- func() {
+		t.internalService.ParticipantUpdated(ctx, room, participant, permission)
+	}
+}
+
+func (t *telemetryService) ParticipantPending(ctx context.Context, room *livekit.Room, participant *livekit.ParticipantInfo) {
+	t.jobQueue <- func() {
+		t.internalService.ParticipantPending(ctx, room, participant)
+	}
+}
+
 func (t *telemetryService) TrackPublished(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo) {
 	t.jobQueue <- func() {
 		t.internalService.TrackPublished(ctx, participantID, track)
@@ -141,9 +187,15 @@ func (t *telemetryService) TrackPublishedUpdate(ctx context.Context, participant
 	}
 }
 
-func (t *telemetryService) ParticipantActive(ctx context.Context, participantID livekit.ParticipantID, clientMeta *livekit.AnalyticsClientMeta) {
+func (t *telemetryService) ParticipantActive(ctx context.Context, participantID livekit.ParticipantID, clientMeta *livekit.AnalyticsClientMeta, timing *JoinTiming) {
 	t.jobQueue <- func() {
-		t.internalService.ParticipantActive(ctx, participantID, clientMeta)
+		t.internalService.ParticipantActive(ctx, participantID, clientMeta, timing)
+	}
+}
+
+func (t *telemetryService) TransportConnected(ctx context.Context, participantID livekit.ParticipantID, timing *JoinTiming) {
+	t.jobQueue <- func() {
+		t.internalService.TransportConnected(ctx, participantID, timing)
 	}
 }
 
@@ -153,12 +205,43 @@ func (t *telemetryService) TrackMaxSubscribedVideoQuality(ctx context.Context, p
 	}
 }
 
+func (t *telemetryService) TrackStreamIssue(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo, layer int32, reason string) {
+	t.jobQueue <- func() {
+		t.internalService.TrackStreamIssue(ctx, participantID, track, layer, reason)
+	}
+}
+
+func (t *telemetryService) TrackSilenceChanged(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo, silent bool) {
+	t.jobQueue <- func() {
+		t.internalService.TrackSilenceChanged(ctx, participantID, track, silent)
+	}
+}
+
+func (t *telemetryService) TrackMuteChanged(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo, muted bool) {
+	t.jobQueue <- func() {
+		t.internalService.TrackMuteChanged(ctx, participantID, track, muted)
+	}
+}
+
+func (t *telemetryService) ConnectionQualityChanged(ctx context.Context, participantID livekit.ParticipantID, prev livekit.ConnectionQuality,
+	info *livekit.ConnectionQualityInfo) {
+	t.jobQueue <- func() {
+		t.internalService.ConnectionQualityChanged(ctx, participantID, prev, info)
+	}
+}
+
 func (t *telemetryService) EgressStarted(ctx context.Context, info *livekit.EgressInfo) {
 	t.jobQueue <- func() {
 		t.internalService.EgressStarted(ctx, info)
 	}
 }
 
+func (t *telemetryService) ConnectionChanged(ctx context.Context, participantID livekit.ParticipantID, event *ConnectionEvent) {
+	t.jobQueue <- func() {
+		t.internalService.ConnectionChanged(ctx, participantID, event)
+	}
+}
+
 func (t *telemetryService) EgressEnded(ctx context.Context, info *livekit.EgressInfo) {
 	t.jobQueue <- func() {
 		t.internalService.EgressEnded(ctx, info)
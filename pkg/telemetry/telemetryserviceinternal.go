@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/gammazero/workerpool"
+	"github.com/livekit/livekit-server/pkg/config"
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/webhook"
@@ -16,6 +17,7 @@ type TelemetryServiceInternal interface {
 
 type TelemetryReporter interface {
 	Report(ctx context.Context, stats []*livekit.AnalyticsStat)
+	ReportTrackQuality(ctx context.Context, batch *TrackQualityBatch)
 }
 
 type telemetryServiceInternal struct {
@@ -25,15 +27,21 @@ type telemetryServiceInternal struct {
 	// one worker per participant
 	workers map[livekit.ParticipantID]*StatsWorker
 
+	trackQuality config.TrackQualityConfig
+	// one sampler per published track, when track quality is enabled
+	trackSamplers map[livekit.TrackID]*trackQualitySampler
+
 	analytics AnalyticsService
 }
 
-func NewTelemetryServiceInternal(notifier webhook.Notifier, analytics AnalyticsService) TelemetryServiceInternal {
+func NewTelemetryServiceInternal(conf *config.Config, notifier webhook.Notifier, analytics AnalyticsService) TelemetryServiceInternal {
 	return &telemetryServiceInternal{
-		notifier:    notifier,
-		webhookPool: workerpool.New(1),
-		workers:     make(map[livekit.ParticipantID]*StatsWorker),
-		analytics:   analytics,
+		notifier:      notifier,
+		webhookPool:   workerpool.New(1),
+		workers:       make(map[livekit.ParticipantID]*StatsWorker),
+		trackQuality:  conf.Telemetry.TrackQuality,
+		trackSamplers: make(map[livekit.TrackID]*trackQualitySampler),
+		analytics:     analytics,
 	}
 }
 
@@ -84,6 +92,41 @@ func (t *telemetryServiceInternal) Report(ctx context.Context, stats []*livekit.
 	t.analytics.SendStats(ctx, stats)
 }
 
+func (t *telemetryServiceInternal) ReportTrackQuality(ctx context.Context, batch *TrackQualityBatch) {
+	t.analytics.SendTrackQuality(ctx, batch)
+}
+
+// AddTrackQualitySource starts sampling a published track, its samples are batched with its participant's stats
+func (t *telemetryServiceInternal) AddTrackQualitySource(participantID livekit.ParticipantID, trackID livekit.TrackID, source TrackQualitySource) {
+	if !t.trackQuality.Enabled {
+		return
+	}
+	w := t.workers[participantID]
+	if w == nil {
+		return
+	}
+
+	t.stopTrackSampler(trackID)
+	t.trackSamplers[trackID] = newTrackQualitySampler(participantID, trackID, source, t.trackQuality.Interval.Duration(),
+		w.OnTrackQualitySample)
+}
+
+func (t *telemetryServiceInternal) stopTrackSampler(trackID livekit.TrackID) {
+	if s := t.trackSamplers[trackID]; s != nil {
+		s.Stop()
+		delete(t.trackSamplers, trackID)
+	}
+}
+
+func (t *telemetryServiceInternal) stopParticipantSamplers(participantID livekit.ParticipantID) {
+	for trackID, s := range t.trackSamplers {
+		if s.participantID == participantID {
+			s.Stop()
+			delete(t.trackSamplers, trackID)
+		}
+	}
+}
+
 func (t *telemetryServiceInternal) SendAnalytics() {
 	for _, worker := range t.workers {
 		worker.Update()
@@ -13,8 +13,22 @@ import (
 	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
 )
 
+// webhook events that are not defined by the protocol
+const (
+	EventTrackSilenced       = "track_silenced"
+	EventTrackUnsilenced     = "track_unsilenced"
+	EventRoomMetadataChanged = "room_metadata_changed"
+	EventParticipantUpdated  = "participant_updated"
+	EventTrackMuted          = "track_muted"
+	EventTrackUnmuted        = "track_unmuted"
+	EventParticipantPending  = "participant_pending"
+	EventTrackPublished      = "track_published"
+	EventTrackUnpublished    = "track_unpublished"
+	EventRoomReassigned      = "room_reassigned"
+)
+
 func (t *telemetryServiceInternal) RoomStarted(ctx context.Context, room *livekit.Room) {
-	prometheus.RoomStarted()
+	prometheus.RoomStarted(room.Name)
 
 	t.notifyEvent(ctx, &livekit.WebhookEvent{
 		Event: webhook.EventRoomStarted,
@@ -29,7 +43,7 @@ func (t *telemetryServiceInternal) RoomStarted(ctx context.Context, room *liveki
 }
 
 func (t *telemetryServiceInternal) RoomEnded(ctx context.Context, room *livekit.Room) {
-	prometheus.RoomEnded(time.Unix(room.CreationTime, 0))
+	prometheus.RoomEnded(room.Name, time.Unix(room.CreationTime, 0))
 
 	t.notifyEvent(ctx, &livekit.WebhookEvent{
 		Event: webhook.EventRoomFinished,
@@ -44,11 +58,30 @@ func (t *telemetryServiceInternal) RoomEnded(ctx context.Context, room *livekit.
 	})
 }
 
+// RoomMetadataChanged notifies webhooks of metadata updated through the room service
+func (t *telemetryServiceInternal) RoomMetadataChanged(ctx context.Context, room *livekit.Room) {
+	t.notifyEvent(ctx, &livekit.WebhookEvent{
+		Event: EventRoomMetadataChanged,
+		Room:  room,
+	})
+}
+
+// RoomReassigned notifies webhooks of rooms moved to another node after theirs was gone. Participants rejoin it
+func (t *telemetryServiceInternal) RoomReassigned(ctx context.Context, room *livekit.Room) {
+	prometheus.RoomReassigned()
+
+	t.notifyEvent(ctx, &livekit.WebhookEvent{
+		Event: EventRoomReassigned,
+		Room:  room,
+	})
+}
+
 func (t *telemetryServiceInternal) ParticipantJoined(ctx context.Context, room *livekit.Room,
 	participant *livekit.ParticipantInfo, clientInfo *livekit.ClientInfo, clientMeta *livekit.AnalyticsClientMeta) {
-	t.workers[livekit.ParticipantID(participant.Sid)] = newStatsWorker(ctx, t, livekit.RoomID(room.Sid), livekit.RoomName(room.Name), livekit.ParticipantID(participant.Sid))
+	t.workers[livekit.ParticipantID(participant.Sid)] = newStatsWorker(ctx, t, livekit.RoomID(room.Sid), livekit.RoomName(room.Name),
+		livekit.ParticipantID(participant.Sid), livekit.ParticipantIdentity(participant.Identity))
 
-	prometheus.AddParticipant()
+	prometheus.AddParticipant(room.Name)
 
 	t.notifyEvent(ctx, &livekit.WebhookEvent{
 		Event:       webhook.EventParticipantJoined,
@@ -69,12 +102,13 @@ func (t *telemetryServiceInternal) ParticipantJoined(ctx context.Context, room *
 }
 
 func (t *telemetryServiceInternal) ParticipantLeft(ctx context.Context, room *livekit.Room, participant *livekit.ParticipantInfo) {
+	t.stopParticipantSamplers(livekit.ParticipantID(participant.Sid))
 	if w := t.workers[livekit.ParticipantID(participant.Sid)]; w != nil {
 		w.Close()
 		delete(t.workers, livekit.ParticipantID(participant.Sid))
 	}
 
-	prometheus.SubParticipant()
+	prometheus.SubParticipant(room.Name)
 
 	t.notifyEvent(ctx, &livekit.WebhookEvent{
 		Event:       webhook.EventParticipantLeft,
@@ -91,8 +125,41 @@ func (t *telemetryServiceInternal) ParticipantLeft(ctx context.Context, room *li
 	})
 }
 
+// ParticipantUpdated notifies webhooks of metadata or permissions updated through the room service. Permissions
+// are not part of the participant info, they are logged instead, nil when unchanged
+func (t *telemetryServiceInternal) ParticipantUpdated(ctx context.Context, room *livekit.Room, participant *livekit.ParticipantInfo,
+	permission *livekit.ParticipantPermission) {
+
+	if permission != nil {
+		logger.Infow("participant permission updated",
+			"roomID", room.Sid,
+			"room", room.Name,
+			"participantID", participant.Sid,
+			"participant", participant.Identity,
+			"canPublish", permission.CanPublish,
+			"canSubscribe", permission.CanSubscribe,
+			"canPublishData", permission.CanPublishData,
+		)
+	}
+
+	t.notifyEvent(ctx, &livekit.WebhookEvent{
+		Event:       EventParticipantUpdated,
+		Room:        room,
+		Participant: participant,
+	})
+}
+
+// ParticipantPending notifies webhooks of a participant waiting for a moderator to approve it into the room
+func (t *telemetryServiceInternal) ParticipantPending(ctx context.Context, room *livekit.Room, participant *livekit.ParticipantInfo) {
+	t.notifyEvent(ctx, &livekit.WebhookEvent{
+		Event:       EventParticipantPending,
+		Room:        room,
+		Participant: participant,
+	})
+}
+
 func (t *telemetryServiceInternal) TrackPublished(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo) {
-	prometheus.AddPublishedTrack(track.Type.String())
+	prometheus.AddPublishedTrack(track.Type.String(), track.MimeType)
 
 	roomID, roomName := t.getRoomDetails(participantID)
 	t.analytics.SendEvent(ctx, &livekit.AnalyticsEvent{
@@ -103,10 +170,18 @@ func (t *telemetryServiceInternal) TrackPublished(ctx context.Context, participa
 		Track:         track,
 		Room:          &livekit.Room{Name: string(roomName)},
 	})
+
+	room, participant := t.getTrackEventDetails(participantID)
+	t.notifyEvent(ctx, &livekit.WebhookEvent{
+		Event:       EventTrackPublished,
+		Room:        room,
+		Participant: participant,
+		Track:       track,
+	})
 }
 
 func (t *telemetryServiceInternal) TrackPublishedUpdate(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo) {
-	prometheus.AddPublishedTrack(track.Type.String())
+	prometheus.AddPublishedTrack(track.Type.String(), track.MimeType)
 
 	roomID, roomName := t.getRoomDetails(participantID)
 	t.analytics.SendEvent(ctx, &livekit.AnalyticsEvent{
@@ -134,9 +209,83 @@ func (t *telemetryServiceInternal) TrackMaxSubscribedVideoQuality(ctx context.Co
 	})
 }
 
+// TrackStreamIssue records a published layer that cannot be rendered by subscribers.
+// There is no analytics event for it, it is logged with the room details instead
+func (t *telemetryServiceInternal) TrackStreamIssue(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo,
+	layer int32, reason string) {
+
+	prometheus.IncrementStreamIssue(reason)
+
+	roomID, roomName := t.getRoomDetails(participantID)
+	logger.Infow("published track stream issue",
+		"roomID", roomID,
+		"room", roomName,
+		"participantID", participantID,
+		"trackID", track.GetSid(),
+		"layer", layer,
+		"reason", reason,
+	)
+}
+
+// TrackSilenceChanged notifies webhooks of a published audio track that went silent while packets keep flowing,
+// e.g. a dead mic, and of it being active again
+func (t *telemetryServiceInternal) TrackSilenceChanged(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo,
+	silent bool) {
+
+	event := EventTrackUnsilenced
+	if silent {
+		event = EventTrackSilenced
+	}
+	room, participant := t.getTrackEventDetails(participantID)
+	t.notifyEvent(ctx, &livekit.WebhookEvent{
+		Event:       event,
+		Room:        room,
+		Participant: participant,
+		Track:       track,
+	})
+}
+
+// TrackMuteChanged notifies webhooks of a published track muted or unmuted, by its participant or through the room service
+func (t *telemetryServiceInternal) TrackMuteChanged(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo,
+	muted bool) {
+
+	event := EventTrackUnmuted
+	if muted {
+		event = EventTrackMuted
+	}
+	room, participant := t.getTrackEventDetails(participantID)
+	t.notifyEvent(ctx, &livekit.WebhookEvent{
+		Event:       event,
+		Room:        room,
+		Participant: participant,
+		Track:       track,
+	})
+}
+
+// ConnectionQualityChanged records a participant's connection quality moving to another rating.
+// There is no analytics event for it, it is logged with the room details instead
+func (t *telemetryServiceInternal) ConnectionQualityChanged(ctx context.Context, participantID livekit.ParticipantID,
+	prev livekit.ConnectionQuality, info *livekit.ConnectionQualityInfo) {
+
+	prometheus.IncrementConnectionQualityChange(info.Quality)
+
+	roomID, roomName := t.getRoomDetails(participantID)
+	logger.Infow("connection quality changed",
+		"roomID", roomID,
+		"room", roomName,
+		"participantID", participantID,
+		"prev", prev.String(),
+		"quality", info.Quality.String(),
+		"score", info.Score,
+	)
+}
+
 func (t *telemetryServiceInternal) TrackUnpublished(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo, ssrc uint32) {
+	t.stopTrackSampler(livekit.TrackID(track.GetSid()))
+
 	roomID := livekit.RoomID("")
 	roomName := livekit.RoomName("")
+	room, participant := t.getTrackEventDetails(participantID)
 	w := t.workers[participantID]
 	if w != nil {
 		roomID = w.roomID
@@ -144,7 +293,7 @@ func (t *telemetryServiceInternal) TrackUnpublished(ctx context.Context, partici
 		w.RemoveStats(livekit.TrackID(track.GetSid()))
 	}
 
-	prometheus.SubPublishedTrack(track.Type.String())
+	prometheus.SubPublishedTrack(track.Type.String(), track.MimeType)
 
 	t.analytics.SendEvent(ctx, &livekit.AnalyticsEvent{
 		Type:          livekit.AnalyticsEventType_TRACK_UNPUBLISHED,
@@ -154,11 +303,18 @@ func (t *telemetryServiceInternal) TrackUnpublished(ctx context.Context, partici
 		TrackId:       track.Sid,
 		Room:          &livekit.Room{Name: string(roomName)},
 	})
+
+	t.notifyEvent(ctx, &livekit.WebhookEvent{
+		Event:       EventTrackUnpublished,
+		Room:        room,
+		Participant: participant,
+		Track:       track,
+	})
 }
 
 func (t *telemetryServiceInternal) TrackSubscribed(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo,
 	publisher *livekit.ParticipantInfo) {
-	prometheus.AddSubscribedTrack(track.Type.String())
+	prometheus.AddSubscribedTrack(track.Type.String(), track.MimeType)
 
 	roomID, roomName := t.getRoomDetails(participantID)
 	t.analytics.SendEvent(ctx, &livekit.AnalyticsEvent{
@@ -173,7 +329,7 @@ func (t *telemetryServiceInternal) TrackSubscribed(ctx context.Context, particip
 }
 
 func (t *telemetryServiceInternal) TrackUnsubscribed(ctx context.Context, participantID livekit.ParticipantID, track *livekit.TrackInfo) {
-	prometheus.SubSubscribedTrack(track.Type.String())
+	prometheus.SubSubscribedTrack(track.Type.String(), track.MimeType)
 
 	roomID, roomName := t.getRoomDetails(participantID)
 	t.analytics.SendEvent(ctx, &livekit.AnalyticsEvent{
@@ -222,6 +378,19 @@ func (t *telemetryServiceInternal) getRoomDetails(participantID livekit.Particip
 	return "", ""
 }
 
+// getTrackEventDetails returns the room and participant of a track webhook, with the room name and participant
+// identity filled in so that receivers don't have to look them up
+func (t *telemetryServiceInternal) getTrackEventDetails(participantID livekit.ParticipantID) (*livekit.Room, *livekit.ParticipantInfo) {
+	room := &livekit.Room{}
+	participant := &livekit.ParticipantInfo{Sid: string(participantID)}
+	if w := t.workers[participantID]; w != nil {
+		room.Sid = string(w.roomID)
+		room.Name = string(w.roomName)
+		participant.Identity = string(w.identity)
+	}
+	return room, participant
+}
+
 func (t *telemetryServiceInternal) notifyEvent(ctx context.Context, event *livekit.WebhookEvent) {
 	if t.notifier == nil {
 		return
@@ -237,9 +406,29 @@ func (t *telemetryServiceInternal) notifyEvent(ctx context.Context, event *livek
 	})
 }
 
-func (t *telemetryServiceInternal) ParticipantActive(ctx context.Context, participantID livekit.ParticipantID, clientMeta *livekit.AnalyticsClientMeta) {
+// ParticipantActive reports the participant's primary transport connecting, with the time it took to join in
+// client_connect_time, in ms. The analytics event has no fields for the stages of joining, they are logged instead
+func (t *telemetryServiceInternal) ParticipantActive(ctx context.Context, participantID livekit.ParticipantID, clientMeta *livekit.AnalyticsClientMeta,
+	timing *JoinTiming) {
 	roomID, roomName := t.getRoomDetails(participantID)
 
+	if timing != nil {
+		values := []interface{}{
+			"roomID", roomID,
+			"room", roomName,
+			"participantID", participantID,
+			"region", clientMeta.GetRegion(),
+			"target", timing.TargetLabel(),
+			"turn", timing.TURN,
+			"join", timing.Duration(),
+			"tokenValidation", timing.TokenValidation(),
+		}
+		for _, stage := range timing.Stages() {
+			values = append(values, stage.Name, stage.Duration)
+		}
+		logger.Infow("participant join timing", values...)
+	}
+
 	t.analytics.SendEvent(ctx, &livekit.AnalyticsEvent{
 		Type:          livekit.AnalyticsEventType_PARTICIPANT_ACTIVE,
 		Timestamp:     timestamppb.Now(),
@@ -250,6 +439,58 @@ func (t *telemetryServiceInternal) ParticipantActive(ctx context.Context, partic
 	})
 }
 
+// TransportConnected records the time a transport of a participant took to connect, by stage
+func (t *telemetryServiceInternal) TransportConnected(_ context.Context, _ livekit.ParticipantID, timing *JoinTiming) {
+	target := timing.TargetLabel()
+	for _, stage := range timing.Stages() {
+		prometheus.ObserveJoinStage(stage.Name, target, timing.TURN, stage.Duration)
+	}
+	if d := timing.Duration(); d != 0 {
+		prometheus.ObserveJoin(target, timing.TURN, d)
+	}
+}
+
+// ConnectionChanged records a change of the ICE connection of a participant's transport. There is no analytics
+// event for it, it is logged with the room details instead
+func (t *telemetryServiceInternal) ConnectionChanged(_ context.Context, participantID livekit.ParticipantID, event *ConnectionEvent) {
+	target := event.TargetLabel()
+	switch event.Type {
+	case ConnectionEventICEConnected, ConnectionEventICERestarted:
+		if event.Remote != nil {
+			prometheus.IncrementICEConnected(target, event.Remote.Type, event.Remote.Protocol)
+		}
+		if event.Type == ConnectionEventICERestarted {
+			prometheus.IncrementICERestart(target)
+		}
+	case ConnectionEventICEDisconnected:
+		prometheus.IncrementICEDisconnected(target)
+	case ConnectionEventICEFailed:
+		prometheus.IncrementICEFailure(target)
+	case ConnectionEventResumed:
+		prometheus.IncrementParticipantResume()
+	}
+
+	room, participant := t.getTrackEventDetails(participantID)
+	values := []interface{}{
+		"roomID", room.Sid,
+		"room", room.Name,
+		"participantID", participantID,
+		"participant", participant.Identity,
+		"event", event.Type,
+		"target", target,
+		"prevState", event.PrevState,
+		"state", event.State,
+		"duration", event.Duration,
+	}
+	if event.Local != nil {
+		values = append(values, "localCandidate", event.Local.Type+" "+event.Local.Protocol+" "+event.Local.Address)
+	}
+	if event.Remote != nil {
+		values = append(values, "remoteCandidate", event.Remote.Type+" "+event.Remote.Protocol+" "+event.Remote.Address)
+	}
+	logger.Infow("participant connection changed", values...)
+}
+
 func (t *telemetryServiceInternal) EgressStarted(ctx context.Context, info *livekit.EgressInfo) {
 	t.notifyEvent(ctx, &livekit.WebhookEvent{
 		Event:      webhook.EventEgressStarted,
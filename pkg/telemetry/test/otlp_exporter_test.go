@@ -0,0 +1,234 @@
+package telemetrytest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+)
+
+type otlpCollector struct {
+	lock     sync.Mutex
+	paths    []string
+	headers  []http.Header
+	requests []map[string]interface{}
+	// responses are held until it's closed, when set
+	block  chan struct{}
+	status int
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if c.block != nil {
+		<-c.block
+	}
+	body := map[string]interface{}{}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.paths = append(c.paths, req.URL.Path)
+	c.headers = append(c.headers, req.Header)
+	c.requests = append(c.requests, body)
+	if c.status != 0 {
+		w.WriteHeader(c.status)
+	}
+}
+
+// records returns the log records received, with the resource attributes of the last request
+func (c *otlpCollector) records() ([]map[string]interface{}, map[string]interface{}) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var records []map[string]interface{}
+	var resource map[string]interface{}
+	for _, request := range c.requests {
+		for _, rl := range request["resourceLogs"].([]interface{}) {
+			rl := rl.(map[string]interface{})
+			resource = otlpAttributes(rl["resource"].(map[string]interface{}))
+			for _, sl := range rl["scopeLogs"].([]interface{}) {
+				for _, record := range sl.(map[string]interface{})["logRecords"].([]interface{}) {
+					records = append(records, record.(map[string]interface{}))
+				}
+			}
+		}
+	}
+	return records, resource
+}
+
+// otlpAttributes flattens attributes to their key and value
+func otlpAttributes(m map[string]interface{}) map[string]interface{} {
+	attrs := map[string]interface{}{}
+	list, _ := m["attributes"].([]interface{})
+	for _, kv := range list {
+		kv := kv.(map[string]interface{})
+		for _, v := range kv["value"].(map[string]interface{}) {
+			attrs[kv["key"].(string)] = v
+		}
+	}
+	return attrs
+}
+
+func otlpTestConfig(endpoint string) config.OTLPConfig {
+	return config.OTLPConfig{
+		Endpoint:     endpoint,
+		Headers:      map[string]string{"Authorization": "Bearer token"},
+		BatchSize:    2,
+		BatchTimeout: config.Duration(20 * time.Millisecond),
+		MaxQueueSize: 4,
+		Timeout:      config.Duration(time.Second),
+	}
+}
+
+func otlpDropped(t *testing.T, reason string) int {
+	m := regexp.MustCompile(`(?m)^livekit_otlp_dropped_total\{[^}]*reason="` + reason + `"[^}]*\} (\d+)$`).
+		FindStringSubmatch(scrapeMetrics(t))
+	if m == nil {
+		return 0
+	}
+	n, err := strconv.Atoi(m[1])
+	require.NoError(t, err)
+	return n
+}
+
+func Test_OTLPExporter(t *testing.T) {
+	collector := &otlpCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	node := &livekit.Node{Id: "ND_otlp", Region: "us-west"}
+	exporter := telemetry.NewOTLPExporter(otlpTestConfig(server.URL), node)
+	ctx := context.Background()
+
+	at := time.Unix(1700000000, 0)
+	exporter.SendEvent(ctx, &livekit.AnalyticsEvent{
+		Type:        livekit.AnalyticsEventType_TRACK_PUBLISHED,
+		Timestamp:   timestamppb.New(at),
+		RoomId:      "RM_otlp",
+		Room:        &livekit.Room{Sid: "RM_otlp", Name: "otlp-room"},
+		Participant: &livekit.ParticipantInfo{Sid: "PA_otlp", Identity: "otlp-user"},
+		Track:       &livekit.TrackInfo{Sid: "TR_otlp", Type: livekit.TrackType_VIDEO, MimeType: "video/VP8"},
+		ClientInfo:  &livekit.ClientInfo{Sdk: livekit.ClientInfo_JS, Os: "macOS"},
+	})
+	exporter.SendStats(ctx, []*livekit.AnalyticsStat{{
+		Kind:          livekit.StreamType_UPSTREAM,
+		TimeStamp:     timestamppb.New(at),
+		RoomId:        "RM_otlp",
+		ParticipantId: "PA_otlp",
+		TrackId:       "TR_otlp",
+		Score:         4.5,
+		Streams: []*livekit.AnalyticsStream{
+			{TotalPrimaryPackets: 10, TotalPrimaryBytes: 1000, TotalNacks: 1, Rtt: 20},
+			{TotalPrimaryPackets: 5, TotalPrimaryBytes: 500, TotalNacks: 2, Rtt: 40},
+		},
+	}})
+	exporter.SendTrackQuality(ctx, &telemetry.TrackQualityBatch{
+		RoomID:        "RM_otlp",
+		ParticipantID: "PA_otlp",
+		Samples: []*telemetry.TrackQualitySample{{
+			TrackID:       "TR_otlp",
+			At:            at,
+			Bitrate:       800000,
+			FPS:           30,
+			KeyFrames:     2,
+			ActiveLayers:  []int32{0, 1},
+			LayerBitrates: []uint64{200000, 600000},
+		}},
+	})
+	exporter.Stop()
+
+	records, resource := collector.records()
+	require.Len(t, records, 3)
+	require.Equal(t, "/v1/logs", collector.paths[0])
+	require.Equal(t, "Bearer token", collector.headers[0].Get("Authorization"))
+	require.Equal(t, "application/json", collector.headers[0].Get("Content-Type"))
+	require.Equal(t, "livekit-server", resource["service.name"])
+	require.Equal(t, "ND_otlp", resource["service.instance.id"])
+	require.Equal(t, "us-west", resource["livekit.region"])
+
+	event := records[0]
+	require.Equal(t, strconv.FormatInt(at.UnixNano(), 10), event["timeUnixNano"])
+	require.Equal(t, "track_published", event["body"].(map[string]interface{})["stringValue"])
+	attrs := otlpAttributes(event)
+	require.Equal(t, "track_published", attrs["livekit.event"])
+	require.Equal(t, "RM_otlp", attrs["livekit.room.sid"])
+	require.Equal(t, "otlp-room", attrs["livekit.room.name"])
+	require.Equal(t, "otlp-user", attrs["livekit.participant.identity"])
+	require.Equal(t, "video", attrs["livekit.track.type"])
+	require.Equal(t, "js", attrs["livekit.client.sdk"])
+	require.NotContains(t, attrs, "livekit.egress.id")
+
+	stat := otlpAttributes(records[1])
+	require.Equal(t, "upstream", stat["livekit.stream_type"])
+	require.Equal(t, "TR_otlp", stat["livekit.track.sid"])
+	require.Equal(t, 4.5, stat["livekit.score"])
+	// 64 bit integers are strings in OTLP JSON
+	require.Equal(t, "15", stat["livekit.packets"])
+	require.Equal(t, "1500", stat["livekit.bytes"])
+	require.Equal(t, "3", stat["livekit.nacks"])
+	require.Equal(t, "40", stat["livekit.rtt"])
+
+	quality := otlpAttributes(records[2])
+	require.Equal(t, "track_quality", quality["livekit.event"])
+	require.Equal(t, "800000", quality["livekit.bitrate"])
+	require.Equal(t, 30.0, quality["livekit.fps"])
+	require.Equal(t, "2", quality["livekit.key_frames"])
+	require.Equal(t, map[string]interface{}{
+		"values": []interface{}{
+			map[string]interface{}{"intValue": "0"},
+			map[string]interface{}{"intValue": "1"},
+		},
+	}, quality["livekit.active_layers"])
+}
+
+func Test_OTLPExporterDrops(t *testing.T) {
+	t.Run("queue full", func(t *testing.T) {
+		collector := &otlpCollector{block: make(chan struct{})}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+
+		before := otlpDropped(t, "queue_full")
+		exporter := telemetry.NewOTLPExporter(otlpTestConfig(server.URL), &livekit.Node{Id: "ND_otlp"})
+		// the first batch is held by the collector, the queue holds the next 4
+		for i := 0; i < 10; i++ {
+			exporter.SendEvent(context.Background(), &livekit.AnalyticsEvent{Type: livekit.AnalyticsEventType_ROOM_CREATED})
+			time.Sleep(time.Millisecond)
+		}
+		require.Eventually(t, func() bool {
+			return otlpDropped(t, "queue_full")-before >= 4
+		}, time.Second, 10*time.Millisecond)
+
+		close(collector.block)
+		exporter.Stop()
+		records, _ := collector.records()
+		require.Equal(t, 10, len(records)+otlpDropped(t, "queue_full")-before)
+	})
+
+	t.Run("export failed", func(t *testing.T) {
+		collector := &otlpCollector{status: http.StatusServiceUnavailable}
+		server := httptest.NewServer(collector)
+		defer server.Close()
+
+		before := otlpDropped(t, "export_failed")
+		exporter := telemetry.NewOTLPExporter(otlpTestConfig(server.URL), &livekit.Node{Id: "ND_otlp"})
+		for i := 0; i < 3; i++ {
+			exporter.SendEvent(context.Background(), &livekit.AnalyticsEvent{Type: livekit.AnalyticsEventType_ROOM_CREATED})
+		}
+		exporter.Stop()
+		require.Equal(t, 3, otlpDropped(t, "export_failed")-before)
+	})
+}
@@ -0,0 +1,218 @@
+package telemetrytest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+func scrapeMetrics(t *testing.T) string {
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+// requireSample checks a sample of the family with labels, given in the order they're exposed, next to node_id
+func requireSample(t *testing.T, metrics string, family string, labels string, value string) {
+	pattern := `(?m)^` + regexp.QuoteMeta(family) + `\{[^}]*` + regexp.QuoteMeta(labels) + `[^}]*\} ` + regexp.QuoteMeta(value) + `$`
+	require.Regexp(t, pattern, metrics)
+}
+
+func Test_PrometheusMetrics(t *testing.T) {
+	prometheus.SetMaxLabeledRooms(1)
+	defer prometheus.SetMaxLabeledRooms(0)
+
+	fixture := createFixture()
+	ctx := context.Background()
+
+	labeled := &livekit.Room{Sid: "RM_metrics1", Name: "metrics-labeled"}
+	unlabeled := &livekit.Room{Sid: "RM_metrics2", Name: "metrics-unlabeled"}
+	fixture.sut.RoomStarted(ctx, labeled)
+	fixture.sut.RoomStarted(ctx, unlabeled)
+
+	publisher := &livekit.ParticipantInfo{Sid: "PA_pub", Identity: "publisher"}
+	subscriber := &livekit.ParticipantInfo{Sid: "PA_sub", Identity: "subscriber"}
+	fixture.sut.ParticipantJoined(ctx, labeled, publisher, &livekit.ClientInfo{}, nil)
+	fixture.sut.ParticipantJoined(ctx, labeled, subscriber, &livekit.ClientInfo{}, nil)
+	fixture.sut.ParticipantJoined(ctx, unlabeled, &livekit.ParticipantInfo{Sid: "PA_other"}, &livekit.ClientInfo{}, nil)
+
+	track := &livekit.TrackInfo{Sid: "TR_video", Type: livekit.TrackType_VIDEO, MimeType: "video/VP8"}
+	fixture.sut.TrackPublished(ctx, livekit.ParticipantID(publisher.Sid), track)
+	fixture.sut.TrackSubscribed(ctx, livekit.ParticipantID(subscriber.Sid), track, publisher)
+	fixture.sut.TrackStats(livekit.StreamType_UPSTREAM, livekit.ParticipantID(publisher.Sid), livekit.TrackID(track.Sid),
+		&livekit.AnalyticsStat{Streams: []*livekit.AnalyticsStream{{TotalNacks: 3, TotalPlis: 2, TotalFirs: 1}}})
+	prometheus.IncrementPackets(prometheus.Incoming, 10)
+	prometheus.IncrementBytes(prometheus.Incoming, 1000)
+	prometheus.IncrementICEFailure("subscriber")
+
+	metrics := scrapeMetrics(t)
+	for _, family := range []string{
+		"livekit_room_total",
+		"livekit_participant_total",
+		"livekit_room_participants_per_room",
+		"livekit_track_published_total",
+		"livekit_track_subscribed_total",
+		"livekit_packet_total",
+		"livekit_packet_bytes",
+		"livekit_nack_total",
+		"livekit_pli_total",
+		"livekit_fir_total",
+		"livekit_ice_failure_total",
+	} {
+		require.Contains(t, metrics, "# TYPE "+family+" ", "missing family %s", family)
+	}
+
+	// per-room series only for rooms within the cap, and never per participant
+	requireSample(t, metrics, "livekit_room_participants", `room="metrics-labeled"`, "2")
+	require.NotContains(t, metrics, `room="metrics-unlabeled"`)
+	require.NotContains(t, metrics, publisher.Identity)
+	require.NotContains(t, metrics, publisher.Sid)
+
+	requireSample(t, metrics, "livekit_track_published_total", `codec="vp8",kind="VIDEO"`, "1")
+	requireSample(t, metrics, "livekit_track_subscribed_total", `codec="vp8",kind="VIDEO"`, "1")
+	requireSample(t, metrics, "livekit_ice_failure_total", `target="subscriber"`, "1")
+	requireSample(t, metrics, "livekit_room_participants_per_room_bucket", `le="0"`, "0")
+	requireSample(t, metrics, "livekit_room_participants_per_room_bucket", `le="1"`, "1")
+	requireSample(t, metrics, "livekit_room_participants_per_room_bucket", `le="2"`, "2")
+	requireSample(t, metrics, "livekit_room_participants_per_room_sum", "", "3")
+	requireSample(t, metrics, "livekit_room_participants_per_room_count", "", "2")
+
+	fixture.sut.TrackUnsubscribed(ctx, livekit.ParticipantID(subscriber.Sid), track)
+	fixture.sut.TrackUnpublished(ctx, livekit.ParticipantID(publisher.Sid), track, 0)
+	fixture.sut.ParticipantLeft(ctx, labeled, subscriber)
+	fixture.sut.ParticipantLeft(ctx, labeled, publisher)
+	fixture.sut.RoomEnded(ctx, labeled)
+
+	metrics = scrapeMetrics(t)
+	require.NotContains(t, metrics, `room="metrics-labeled"`)
+	requireSample(t, metrics, "livekit_track_published_total", `codec="vp8",kind="VIDEO"`, "0")
+	requireSample(t, metrics, "livekit_room_participants_per_room_count", "", "1")
+
+	fixture.sut.ParticipantLeft(ctx, unlabeled, &livekit.ParticipantInfo{Sid: "PA_other"})
+	fixture.sut.RoomEnded(ctx, unlabeled)
+}
+
+func Test_JoinLatencyMetrics(t *testing.T) {
+	prometheus.SetJoinLatencyBuckets([]float64{0.1, 0.3, 1})
+	defer prometheus.SetJoinLatencyBuckets(nil)
+
+	fixture := createFixture()
+	start := time.Now()
+	timing := &telemetry.JoinTiming{
+		Target:           livekit.SignalTarget_SUBSCRIBER,
+		TURN:             true,
+		StartedAt:        start,
+		TokenValidatedAt: start.Add(10 * time.Millisecond),
+		OfferAt:          start.Add(60 * time.Millisecond),
+		AnswerAt:         start.Add(260 * time.Millisecond),
+		ICEConnectedAt:   start.Add(460 * time.Millisecond),
+		DTLSConnectedAt:  start.Add(500 * time.Millisecond),
+	}
+	require.Equal(t, []telemetry.JoinStage{
+		{Name: telemetry.JoinStageOffer, Duration: 50 * time.Millisecond},
+		{Name: telemetry.JoinStageAnswer, Duration: 200 * time.Millisecond},
+		{Name: telemetry.JoinStageICEConnected, Duration: 200 * time.Millisecond},
+		{Name: telemetry.JoinStageDTLSConnected, Duration: 40 * time.Millisecond},
+	}, timing.Stages())
+	fixture.sut.TransportConnected(context.Background(), "PA_join", timing)
+
+	// buckets are the configured ones
+	metrics := scrapeMetrics(t)
+	requireSample(t, metrics, "livekit_join_duration_seconds_bucket", `target="subscriber",turn="true",le="0.3"`, "0")
+	requireSample(t, metrics, "livekit_join_duration_seconds_bucket", `target="subscriber",turn="true",le="1"`, "1")
+	requireSample(t, metrics, "livekit_join_stage_seconds_bucket", `stage="offer",target="subscriber",turn="true",le="0.1"`, "1")
+	requireSample(t, metrics, "livekit_join_stage_seconds_bucket", `stage="answer",target="subscriber",turn="true",le="0.1"`, "0")
+	require.NotContains(t, metrics, `le="0.05"`)
+}
+
+func Test_ConnectionEventMetrics(t *testing.T) {
+	fixture := createFixture()
+	ctx := context.Background()
+	pID := livekit.ParticipantID("PA_connection")
+
+	relay := &telemetry.ICECandidate{Type: "relay", Protocol: "udp", Address: "10.0.0.1:3478"}
+	fixture.sut.ConnectionChanged(ctx, pID, &telemetry.ConnectionEvent{
+		Type:   telemetry.ConnectionEventICEConnected,
+		Target: livekit.SignalTarget_SUBSCRIBER,
+		Remote: relay,
+	})
+	fixture.sut.ConnectionChanged(ctx, pID, &telemetry.ConnectionEvent{
+		Type:   telemetry.ConnectionEventICEDisconnected,
+		Target: livekit.SignalTarget_SUBSCRIBER,
+	})
+	fixture.sut.ConnectionChanged(ctx, pID, &telemetry.ConnectionEvent{
+		Type:     telemetry.ConnectionEventICERestarted,
+		Target:   livekit.SignalTarget_SUBSCRIBER,
+		Remote:   relay,
+		Duration: time.Second,
+	})
+	fixture.sut.ConnectionChanged(ctx, pID, &telemetry.ConnectionEvent{
+		Type:   telemetry.ConnectionEventResumed,
+		Target: livekit.SignalTarget_PUBLISHER,
+	})
+
+	// a restart counts as a connection too
+	metrics := scrapeMetrics(t)
+	requireSample(t, metrics, "livekit_ice_connected_total", `candidate_type="relay",`, "2")
+	requireSample(t, metrics, "livekit_ice_connected_total", `protocol="udp",target="subscriber"`, "2")
+	requireSample(t, metrics, "livekit_ice_disconnected_total", `target="subscriber"`, "1")
+	requireSample(t, metrics, "livekit_ice_restart_total", `target="subscriber"`, "1")
+	requireSample(t, metrics, "livekit_participant_resume_total", "", "1")
+}
+
+func Test_TURNNodeStats(t *testing.T) {
+	conf := &config.NodeStatsConfig{}
+	before, _, err := prometheus.GetUpdatedNodeStats(&livekit.NodeStats{}, conf)
+	require.NoError(t, err)
+
+	// relayed bytes are part of the node's traffic
+	prometheus.IncrementTURNRelayedBytes("udp", prometheus.Incoming, 100)
+	prometheus.IncrementTURNRelayedBytes("tls", prometheus.Outgoing, 40)
+	after, _, err := prometheus.GetUpdatedNodeStats(&livekit.NodeStats{}, conf)
+	require.NoError(t, err)
+	require.Equal(t, before.BytesIn+100, after.BytesIn)
+	require.Equal(t, before.BytesOut+40, after.BytesOut)
+
+	metrics := scrapeMetrics(t)
+	requireSample(t, metrics, "livekit_turn_relayed_bytes_total", `direction="incoming",`, "100")
+	requireSample(t, metrics, "livekit_turn_relayed_bytes_total", `protocol="tls"`, "40")
+}
+
+func Test_NodeStats(t *testing.T) {
+	prometheus.AddPeerConnection()
+	defer prometheus.SubPeerConnection()
+
+	conf := &config.NodeStatsConfig{Include: []string{config.NodeStatPeerConnections, config.NodeStatBandwidth}}
+	prev := &livekit.NodeStats{StartedAt: 1, UpdatedAt: time.Now().Unix() - 2}
+	stats, report, err := prometheus.GetUpdatedNodeStats(prev, conf)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.StartedAt)
+	require.Equal(t, float32(stats.BytesIn)/2, stats.BytesInPerSec)
+	require.Zero(t, stats.NumCpus)
+	require.Equal(t, []string{config.NodeStatPeerConnections, config.NodeStatBandwidth}, report.Reported)
+	require.Equal(t, int32(1), report.NumPeerConnections)
+	requireSample(t, scrapeMetrics(t), "livekit_node_peer_connections", "", "1")
+
+	// rates need a second between samples, the previous ones are kept until then
+	again, _, err := prometheus.GetUpdatedNodeStats(stats, conf)
+	require.NoError(t, err)
+	if again.UpdatedAt == stats.UpdatedAt {
+		require.Equal(t, stats.BytesIn, again.BytesIn)
+		require.Equal(t, stats.BytesInPerSec, again.BytesInPerSec)
+	}
+}
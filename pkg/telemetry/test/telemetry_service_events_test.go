@@ -2,10 +2,16 @@ package telemetrytest
 
 import (
 	"context"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/livekit/protocol/livekit"
 	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/telemetry/telemetryfakes"
 )
 
 func Test_OnParticipantJoin_EventIsSent(t *testing.T) {
@@ -145,7 +151,7 @@ func Test_OnParticipantActive_EventIsSent(t *testing.T) {
 	clientMetaConnect := &livekit.AnalyticsClientMeta{
 		ClientConnectTime: 420,
 	}
-	fixture.sut.ParticipantActive(context.Background(), livekit.ParticipantID(partSID), clientMetaConnect)
+	fixture.sut.ParticipantActive(context.Background(), livekit.ParticipantID(partSID), clientMetaConnect, nil)
 
 	require.Equal(t, 2, fixture.analytics.SendEventCallCount())
 	_, eventActive := fixture.analytics.SendEventArgsForCall(1)
@@ -205,3 +211,58 @@ func Test_OnTrackSubscribed_EventIsSent(t *testing.T) {
 	require.Equal(t, publisherInfo.Identity, eventTrackSubscribed.Publisher.Identity)
 
 }
+
+type recordingNotifier struct {
+	lock   sync.Mutex
+	events []*livekit.WebhookEvent
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, payload interface{}) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.events = append(n.events, payload.(*livekit.WebhookEvent))
+	return nil
+}
+
+func (n *recordingNotifier) received() []*livekit.WebhookEvent {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	return append([]*livekit.WebhookEvent{}, n.events...)
+}
+
+func Test_TrackWebhooks_CarryRoomAndIdentity(t *testing.T) {
+	notifier := &recordingNotifier{}
+	sut := telemetry.NewTelemetryServiceInternal(&config.Config{}, notifier, &telemetryfakes.FakeAnalyticsService{})
+
+	room := &livekit.Room{Sid: "RoomSid", Name: "RoomName"}
+	participant := &livekit.ParticipantInfo{Sid: "part1", Identity: "alice"}
+	sut.ParticipantJoined(context.Background(), room, participant, &livekit.ClientInfo{}, nil)
+
+	partID := livekit.ParticipantID(participant.Sid)
+	track := &livekit.TrackInfo{
+		Sid:    "track1",
+		Type:   livekit.TrackType_VIDEO,
+		Source: livekit.TrackSource_SCREEN_SHARE,
+		Width:  1280,
+		Height: 720,
+	}
+	sut.TrackPublished(context.Background(), partID, track)
+	sut.TrackMuteChanged(context.Background(), partID, track, true)
+	sut.TrackMuteChanged(context.Background(), partID, track, false)
+	sut.TrackUnpublished(context.Background(), partID, track, 0)
+
+	require.Eventually(t, func() bool {
+		return len(notifier.received()) == 5
+	}, time.Second, 10*time.Millisecond)
+
+	events := notifier.received()[1:]
+	expected := []string{telemetry.EventTrackPublished, telemetry.EventTrackMuted, telemetry.EventTrackUnmuted, telemetry.EventTrackUnpublished}
+	for i, event := range events {
+		require.Equal(t, expected[i], event.Event)
+		require.Equal(t, "RoomName", event.Room.Name)
+		require.Equal(t, "alice", event.Participant.Identity)
+		require.Equal(t, "track1", event.Track.Sid)
+		require.Equal(t, livekit.TrackSource_SCREEN_SHARE, event.Track.Source)
+		require.Equal(t, uint32(1280), event.Track.Width)
+	}
+}
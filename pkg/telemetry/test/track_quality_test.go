@@ -0,0 +1,131 @@
+package telemetrytest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+	"github.com/livekit/livekit-server/pkg/telemetry/telemetryfakes"
+)
+
+// qualitySource adds the same traffic to its stats every time they're read
+type qualitySource struct {
+	lock  sync.Mutex
+	stats buffer.StreamStats
+}
+
+func (s *qualitySource) GetTrackStats() map[uint32]*buffer.StreamStatsWithLayers {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.stats.TotalPrimaryPackets += 100
+	s.stats.TotalPrimaryBytes += 100000
+	s.stats.TotalPacketsLost += 5
+	s.stats.TotalFrames += 30
+	s.stats.TotalKeyFrames++
+	s.stats.Jitter = 90
+	return map[uint32]*buffer.StreamStatsWithLayers{1: {StreamStats: s.stats}}
+}
+
+func (s *qualitySource) GetLayerBitrates() []uint64 {
+	return []uint64{150000, 500000}
+}
+
+func (s *qualitySource) GetAvailableSpatialLayers() []int32 {
+	return []int32{0, 1}
+}
+
+func createTrackQualityFixture(enabled bool) *telemetryServiceFixture {
+	conf := &config.Config{}
+	conf.Telemetry.TrackQuality = config.TrackQualityConfig{
+		Enabled:  enabled,
+		Interval: config.Duration(20 * time.Millisecond),
+	}
+	fixture := &telemetryServiceFixture{analytics: &telemetryfakes.FakeAnalyticsService{}}
+	fixture.sut = telemetry.NewTelemetryServiceInternal(conf, nil, fixture.analytics)
+	return fixture
+}
+
+func Test_TrackQualitySamples(t *testing.T) {
+	fixture := createTrackQualityFixture(true)
+	ctx := context.Background()
+	room := &livekit.Room{Sid: "RM_quality", Name: "quality"}
+	participant := &livekit.ParticipantInfo{Sid: "PA_quality", Identity: "publisher"}
+	fixture.sut.ParticipantJoined(ctx, room, participant, &livekit.ClientInfo{}, nil)
+	ignore := goleak.IgnoreCurrent()
+
+	track := &livekit.TrackInfo{Sid: "TR_quality", Type: livekit.TrackType_VIDEO}
+	fixture.sut.TrackPublished(ctx, livekit.ParticipantID(participant.Sid), track)
+	fixture.sut.AddTrackQualitySource(livekit.ParticipantID(participant.Sid), livekit.TrackID(track.Sid), &qualitySource{})
+	time.Sleep(110 * time.Millisecond)
+
+	// the sampler's goroutine is gone once the track is unpublished
+	fixture.sut.TrackUnpublished(ctx, livekit.ParticipantID(participant.Sid), track, 0)
+	goleak.VerifyNone(t, ignore)
+
+	// samples are batched per participant, and sent with its stats
+	fixture.sut.SendAnalytics()
+	require.Equal(t, 1, fixture.analytics.SendTrackQualityCallCount())
+	_, batch := fixture.analytics.SendTrackQualityArgsForCall(0)
+	require.Equal(t, livekit.RoomID(room.Sid), batch.RoomID)
+	require.Equal(t, livekit.ParticipantIdentity(participant.Identity), batch.Identity)
+	require.GreaterOrEqual(t, len(batch.Samples), 2)
+
+	sample := batch.Samples[0]
+	require.Equal(t, livekit.TrackID(track.Sid), sample.TrackID)
+	require.EqualValues(t, 100, sample.Packets)
+	require.EqualValues(t, 5, sample.PacketsLost)
+	require.InDelta(t, 5.0/105, sample.LossRate(), 0.0001)
+	require.EqualValues(t, 1, sample.KeyFrames)
+	require.Equal(t, 90.0, sample.Jitter)
+	require.InDelta(t, 100000*8/sample.Interval.Seconds(), float64(sample.Bitrate), 1)
+	require.InDelta(t, 30/sample.Interval.Seconds(), sample.FPS, 0.01)
+	require.Equal(t, []int32{0, 1}, sample.ActiveLayers)
+	require.Equal(t, []uint64{150000, 500000}, sample.LayerBitrates)
+
+	// nothing sampled after the track is gone
+	fixture.sut.SendAnalytics()
+	require.Equal(t, 1, fixture.analytics.SendTrackQualityCallCount())
+}
+
+func Test_TrackQualitySamplersStopWithParticipant(t *testing.T) {
+	fixture := createTrackQualityFixture(true)
+	ctx := context.Background()
+	room := &livekit.Room{Sid: "RM_quality", Name: "quality"}
+	participant := &livekit.ParticipantInfo{Sid: "PA_quality"}
+	fixture.sut.ParticipantJoined(ctx, room, participant, &livekit.ClientInfo{}, nil)
+	ignore := goleak.IgnoreCurrent()
+
+	fixture.sut.AddTrackQualitySource(livekit.ParticipantID(participant.Sid), "TR_audio", &qualitySource{})
+	fixture.sut.AddTrackQualitySource(livekit.ParticipantID(participant.Sid), "TR_video", &qualitySource{})
+	time.Sleep(50 * time.Millisecond)
+
+	// the last samples are sent as the participant leaves
+	fixture.sut.ParticipantLeft(ctx, room, participant)
+	goleak.VerifyNone(t, ignore)
+	require.Equal(t, 1, fixture.analytics.SendTrackQualityCallCount())
+}
+
+func Test_TrackQualityDisabled(t *testing.T) {
+	fixture := createTrackQualityFixture(false)
+	ctx := context.Background()
+	room := &livekit.Room{Sid: "RM_quality", Name: "quality"}
+	participant := &livekit.ParticipantInfo{Sid: "PA_quality"}
+	fixture.sut.ParticipantJoined(ctx, room, participant, &livekit.ClientInfo{}, nil)
+	ignore := goleak.IgnoreCurrent()
+
+	fixture.sut.AddTrackQualitySource(livekit.ParticipantID(participant.Sid), "TR_video", &qualitySource{})
+	goleak.VerifyNone(t, ignore)
+
+	time.Sleep(50 * time.Millisecond)
+	fixture.sut.SendAnalytics()
+	require.Equal(t, 0, fixture.analytics.SendTrackQualityCallCount())
+}
@@ -0,0 +1,144 @@
+package telemetrytest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/webhook"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry"
+)
+
+const (
+	webhookAPIKey    = "key"
+	webhookAPISecret = "secret"
+)
+
+var testRetryConfig = config.WebHookRetryConfig{
+	MaxAttempts:    3,
+	MaxAge:         config.Duration(time.Minute),
+	InitialBackoff: config.Duration(time.Millisecond),
+	MaxBackoff:     config.Duration(5 * time.Millisecond),
+	Timeout:        config.Duration(time.Second),
+	DrainTimeout:   config.Duration(time.Second),
+}
+
+type webhookReceiver struct {
+	lock     sync.Mutex
+	failures int
+	events   []string
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	data, err := webhook.Receive(req, auth.NewFileBasedKeyProviderFromMap(map[string]string{webhookAPIKey: webhookAPISecret}))
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	event := &livekit.WebhookEvent{}
+	if err := protojson.Unmarshal(data, event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.events = append(r.events, event.Event)
+}
+
+func (r *webhookReceiver) received() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string{}, r.events...)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	t.Run("retries failed posts in order", func(t *testing.T) {
+		receiver := &webhookReceiver{failures: 2}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		n := telemetry.NewWebhookNotifier(webhookAPIKey, webhookAPISecret, []config.WebHookURL{{URL: server.URL}}, testRetryConfig)
+		require.NoError(t, n.Notify(context.Background(), &livekit.WebhookEvent{Event: webhook.EventRoomStarted}))
+		require.NoError(t, n.Notify(context.Background(), &livekit.WebhookEvent{Event: webhook.EventParticipantJoined}))
+		n.Stop()
+
+		require.Equal(t, []string{webhook.EventRoomStarted, webhook.EventParticipantJoined}, receiver.received())
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		receiver := &webhookReceiver{failures: 3}
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		n := telemetry.NewWebhookNotifier(webhookAPIKey, webhookAPISecret, []config.WebHookURL{{URL: server.URL}}, testRetryConfig)
+		require.NoError(t, n.Notify(context.Background(), &livekit.WebhookEvent{Event: webhook.EventRoomStarted}))
+		require.NoError(t, n.Notify(context.Background(), &livekit.WebhookEvent{Event: webhook.EventRoomFinished}))
+		n.Stop()
+
+		require.Equal(t, []string{webhook.EventRoomFinished}, receiver.received())
+	})
+
+	t.Run("filters events and sets headers per url", func(t *testing.T) {
+		all := &webhookReceiver{}
+		allServer := httptest.NewServer(all)
+		defer allServer.Close()
+		tracks := &webhookReceiver{}
+		var route string
+		tracksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route = r.Header.Get("X-Route")
+			tracks.ServeHTTP(w, r)
+		}))
+		defer tracksServer.Close()
+
+		n := telemetry.NewWebhookNotifier(webhookAPIKey, webhookAPISecret, []config.WebHookURL{
+			{URL: allServer.URL},
+			{
+				URL:     tracksServer.URL,
+				Events:  []string{telemetry.EventTrackPublished},
+				Headers: map[string]string{"X-Route": "tracks"},
+			},
+		}, testRetryConfig)
+		require.NoError(t, n.Notify(context.Background(), &livekit.WebhookEvent{Event: webhook.EventRoomStarted}))
+		require.NoError(t, n.Notify(context.Background(), &livekit.WebhookEvent{Event: telemetry.EventTrackPublished}))
+		n.Stop()
+
+		require.Equal(t, []string{webhook.EventRoomStarted, telemetry.EventTrackPublished}, all.received())
+		require.Equal(t, []string{telemetry.EventTrackPublished}, tracks.received())
+		require.Equal(t, "tracks", route)
+	})
+
+	t.Run("stop gives up on undeliverable events after drain timeout", func(t *testing.T) {
+		unblock := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-unblock
+		}))
+		defer server.Close()
+		defer close(unblock)
+
+		conf := testRetryConfig
+		conf.Timeout = 0
+		conf.DrainTimeout = config.Duration(100 * time.Millisecond)
+		n := telemetry.NewWebhookNotifier(webhookAPIKey, webhookAPISecret, []config.WebHookURL{{URL: server.URL}}, conf)
+		require.NoError(t, n.Notify(context.Background(), &livekit.WebhookEvent{Event: webhook.EventRoomStarted}))
+
+		start := time.Now()
+		n.Stop()
+		require.Less(t, time.Since(start), time.Second)
+		require.ErrorIs(t, n.Notify(context.Background(), &livekit.WebhookEvent{Event: webhook.EventRoomFinished}), telemetry.ErrWebhookNotifierStopped)
+	})
+}
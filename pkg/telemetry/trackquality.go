@@ -0,0 +1,180 @@
+package telemetry
+
+import (
+	"time"
+
+	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-server/pkg/sfu/buffer"
+)
+
+// TrackQualitySource is the receiver of a published track, sampled for its quality
+type TrackQualitySource interface {
+	// cumulative stats of each published layer, by SSRC
+	GetTrackStats() map[uint32]*buffer.StreamStatsWithLayers
+	// measured bitrate of each spatial layer in bits per second
+	GetLayerBitrates() []uint64
+	GetAvailableSpatialLayers() []int32
+}
+
+// TrackQualitySample is the quality of a published track over a sampling interval
+type TrackQualitySample struct {
+	TrackID  livekit.TrackID
+	At       time.Time
+	Interval time.Duration
+
+	// in bits per second, of all layers
+	Bitrate     uint64
+	Packets     uint32
+	PacketsLost uint32
+	// highest of all layers, in RTP timestamp units
+	Jitter float64
+	// of the layer with the most frames
+	FPS       float64
+	KeyFrames uint32
+
+	ActiveLayers  []int32
+	LayerBitrates []uint64
+}
+
+// LossRate is the fraction of packets lost in the interval
+func (s *TrackQualitySample) LossRate() float64 {
+	if s.Packets+s.PacketsLost == 0 {
+		return 0
+	}
+	return float64(s.PacketsLost) / float64(s.Packets+s.PacketsLost)
+}
+
+// TrackQualityBatch is the samples of a participant's tracks since its previous batch
+type TrackQualityBatch struct {
+	RoomID        livekit.RoomID
+	RoomName      livekit.RoomName
+	ParticipantID livekit.ParticipantID
+	Identity      livekit.ParticipantIdentity
+	Samples       []*TrackQualitySample
+}
+
+// trackQualitySampler samples a track on its own goroutine until stopped
+type trackQualitySampler struct {
+	participantID livekit.ParticipantID
+	trackID       livekit.TrackID
+	source        TrackQualitySource
+	interval      time.Duration
+	onSample      func(sample *TrackQualitySample)
+
+	prev   map[uint32]buffer.StreamStats
+	prevAt time.Time
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newTrackQualitySampler(
+	participantID livekit.ParticipantID,
+	trackID livekit.TrackID,
+	source TrackQualitySource,
+	interval time.Duration,
+	onSample func(sample *TrackQualitySample),
+) *trackQualitySampler {
+	s := &trackQualitySampler{
+		participantID: participantID,
+		trackID:       trackID,
+		source:        source,
+		interval:      interval,
+		onSample:      onSample,
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Stop returns once the sampler's goroutine has exited
+func (s *trackQualitySampler) Stop() {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	<-s.stopped
+}
+
+func (s *trackQualitySampler) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	// the first interval is measured from the stats when the sampler started
+	s.sample(time.Now())
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			if sample := s.sample(now); sample != nil {
+				s.onSample(sample)
+			}
+		}
+	}
+}
+
+// sample returns the quality since the previous sample, nil for the first one
+func (s *trackQualitySampler) sample(now time.Time) *TrackQualitySample {
+	stats := s.source.GetTrackStats()
+	prev, prevAt := s.prev, s.prevAt
+	s.prev = make(map[uint32]buffer.StreamStats, len(stats))
+	for ssrc, stat := range stats {
+		s.prev[ssrc] = stat.StreamStats
+	}
+	s.prevAt = now
+	if prevAt.IsZero() {
+		return nil
+	}
+
+	sample := &TrackQualitySample{
+		TrackID:       s.trackID,
+		At:            now,
+		Interval:      now.Sub(prevAt),
+		ActiveLayers:  s.source.GetAvailableSpatialLayers(),
+		LayerBitrates: s.source.GetLayerBitrates(),
+	}
+	var bytes uint64
+	var maxFrames uint32
+	for ssrc, stat := range stats {
+		cur := stat.StreamStats
+		// a stream first seen in this interval counts from zero
+		last := prev[ssrc]
+		bytes += delta64(cur.TotalPrimaryBytes+cur.TotalRetransmitBytes+cur.TotalPaddingBytes,
+			last.TotalPrimaryBytes+last.TotalRetransmitBytes+last.TotalPaddingBytes)
+		sample.Packets += delta32(cur.TotalPrimaryPackets, last.TotalPrimaryPackets)
+		sample.PacketsLost += delta32(cur.TotalPacketsLost, last.TotalPacketsLost)
+		sample.KeyFrames += delta32(cur.TotalKeyFrames, last.TotalKeyFrames)
+		if frames := delta32(cur.TotalFrames, last.TotalFrames); frames > maxFrames {
+			maxFrames = frames
+		}
+		if cur.Jitter > sample.Jitter {
+			sample.Jitter = cur.Jitter
+		}
+	}
+	if seconds := sample.Interval.Seconds(); seconds > 0 {
+		sample.Bitrate = uint64(float64(bytes*8) / seconds)
+		sample.FPS = float64(maxFrames) / seconds
+	}
+	return sample
+}
+
+// counters can go back, e.g. packets lost when late packets arrive
+func delta32(cur, prev uint32) uint32 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
+
+func delta64(cur, prev uint64) uint64 {
+	if cur < prev {
+		return 0
+	}
+	return cur - prev
+}
@@ -0,0 +1,261 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/livekit/protocol/logger"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/livekit-server/pkg/config"
+	"github.com/livekit/livekit-server/pkg/telemetry/prometheus"
+)
+
+const (
+	webhookQueueSize     = 1000
+	webhookTokenValidFor = 5 * time.Minute
+)
+
+var ErrWebhookNotifierStopped = errors.New("webhook notifier is stopped")
+
+// WebhookNotifier posts webhook events like the protocol's notifier, but checks the response
+// and retries failed posts with exponential backoff. Each URL has its own queue, delivered in
+// order, so events of a room reach a URL in the order they were sent. URLs configured with events
+// only receive those.
+type WebhookNotifier struct {
+	apiKey    string
+	apiSecret string
+	conf      config.WebHookRetryConfig
+	client    *http.Client
+	queues    []*webhookQueue
+
+	// cancelled when draining on Stop runs out of time
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock    sync.RWMutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+type webhookQueue struct {
+	url     string
+	filter  map[string]bool
+	headers map[string]string
+	events  chan *queuedWebhook
+}
+
+func (q *webhookQueue) wants(event string) bool {
+	return len(q.filter) == 0 || q.filter[event]
+}
+
+type queuedWebhook struct {
+	name     string
+	payload  []byte
+	queuedAt time.Time
+}
+
+func NewWebhookNotifier(apiKey, apiSecret string, urls []config.WebHookURL, conf config.WebHookRetryConfig) *WebhookNotifier {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &WebhookNotifier{
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		conf:      conf,
+		client:    &http.Client{},
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	for _, u := range urls {
+		q := &webhookQueue{
+			url:     u.URL,
+			headers: u.Headers,
+			events:  make(chan *queuedWebhook, webhookQueueSize),
+		}
+		if len(u.Events) != 0 {
+			q.filter = make(map[string]bool, len(u.Events))
+			for _, event := range u.Events {
+				q.filter[event] = true
+			}
+		}
+		n.queues = append(n.queues, q)
+		n.wg.Add(1)
+		go n.deliver(q)
+	}
+	return n
+}
+
+// Notify queues the payload for each URL and returns without waiting for delivery
+func (n *WebhookNotifier) Notify(_ context.Context, payload interface{}) error {
+	var encoded []byte
+	var err error
+	if message, ok := payload.(proto.Message); ok {
+		// use proto marshaler to ensure lowerCaseCamel
+		encoded, err = protojson.Marshal(message)
+	} else {
+		encoded, err = json.Marshal(payload)
+	}
+	if err != nil {
+		return err
+	}
+
+	event := &queuedWebhook{
+		payload:  encoded,
+		queuedAt: time.Now(),
+	}
+	if e, ok := payload.(*livekit.WebhookEvent); ok {
+		event.name = e.Event
+	}
+
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	if n.stopped {
+		return ErrWebhookNotifierStopped
+	}
+	for _, q := range n.queues {
+		if !q.wants(event.name) {
+			continue
+		}
+		select {
+		case q.events <- event:
+		default:
+			logger.Warnw("webhook queue is full, dropping event", nil, "url", q.url, "event", event.name)
+			prometheus.IncrementWebhookFailed(q.url)
+		}
+	}
+	return nil
+}
+
+// Stop stops accepting events and waits up to drain_timeout for the queued ones to be delivered.
+// Events still queued after that are dropped.
+func (n *WebhookNotifier) Stop() {
+	n.lock.Lock()
+	if n.stopped {
+		n.lock.Unlock()
+		return
+	}
+	n.stopped = true
+	for _, q := range n.queues {
+		close(q.events)
+	}
+	n.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(n.conf.DrainTimeout.Duration()):
+		logger.Warnw("timed out delivering queued webhooks", nil)
+		n.cancel()
+		<-done
+	}
+	n.cancel()
+}
+
+func (n *WebhookNotifier) deliver(q *webhookQueue) {
+	defer n.wg.Done()
+	for event := range q.events {
+		n.deliverEvent(q, event)
+	}
+}
+
+func (n *WebhookNotifier) deliverEvent(q *webhookQueue, event *queuedWebhook) {
+	url := q.url
+	backoff := n.conf.InitialBackoff.Duration()
+	maxAge := n.conf.MaxAge.Duration()
+	for attempt := 1; ; attempt++ {
+		err := n.post(q, event.payload)
+		if err == nil {
+			prometheus.IncrementWebhookDelivered(url)
+			return
+		}
+
+		if attempt >= n.conf.MaxAttempts || (maxAge > 0 && time.Since(event.queuedAt)+backoff > maxAge) || n.ctx.Err() != nil {
+			logger.Warnw("could not deliver webhook", err, "url", url, "event", event.name, "attempts", attempt)
+			prometheus.IncrementWebhookFailed(url)
+			return
+		}
+
+		logger.Debugw("retrying webhook", "error", err, "url", url, "event", event.name, "attempt", attempt)
+		select {
+		case <-time.After(jitter(backoff)):
+		case <-n.ctx.Done():
+		}
+		prometheus.IncrementWebhookRetry(url)
+
+		backoff *= 2
+		if max := n.conf.MaxBackoff.Duration(); backoff > max {
+			backoff = max
+		}
+	}
+}
+
+func (n *WebhookNotifier) post(q *webhookQueue, payload []byte) error {
+	if err := n.ctx.Err(); err != nil {
+		return err
+	}
+
+	// sign payload, on every attempt so retries don't outlive the token
+	sum := sha256.Sum256(payload)
+	b64 := base64.StdEncoding.EncodeToString(sum[:])
+	token, err := auth.NewAccessToken(n.apiKey, n.apiSecret).
+		SetValidFor(webhookTokenValidFor).
+		SetSha256(b64).
+		ToJWT()
+	if err != nil {
+		return err
+	}
+
+	ctx := n.ctx
+	if timeout := n.conf.Timeout.Duration(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	r, err := http.NewRequestWithContext(ctx, "POST", q.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for k, v := range q.headers {
+		r.Header.Set(k, v)
+	}
+	r.Header.Set("Authorization", token)
+	r.Header.Set("content-type", "application/json")
+
+	res, err := n.client.Do(r)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	_ = res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// jitter spreads retries between half and all of the backoff so that nodes don't retry in lockstep
+func jitter(backoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return 0
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1))
+}
@@ -447,3 +447,58 @@ func TestSingleNodeUpdateSubscriptionPermissions(t *testing.T) {
 		}
 	})
 }
+
+func TestSingleNodeDrain(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+		return
+	}
+	logger.Infow("----------------STARTING TEST----------------", "test", "TestSingleNodeDrain")
+	s := createSingleNodeServer(func(conf *config.Config) {
+		conf.DrainTimeout = config.Duration(2 * time.Second)
+		conf.Admin = config.AdminConfig{APIKey: "admin", APISecret: "adminsecret"}
+	})
+	stopped := make(chan struct{})
+	go func() {
+		_ = s.Start()
+		close(stopped)
+	}()
+	waitForServerToStart(s)
+	defer s.Stop(true)
+
+	c1 := createRTCClient("c1", defaultServerPort, nil)
+	waitUntilConnected(t, c1)
+	defer stopClients(c1)
+
+	drain := func(authorize func(r *http.Request)) int {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://localhost:%d/drain", s.HTTPPort()), nil)
+		require.NoError(t, err)
+		authorize(req)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		return res.StatusCode
+	}
+	// any app backend holds a token allowed to create rooms
+	require.Equal(t, http.StatusUnauthorized, drain(func(r *http.Request) {}))
+	require.Equal(t, http.StatusUnauthorized, drain(func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+createRoomToken())
+	}))
+	require.False(t, s.IsDraining())
+
+	require.Equal(t, http.StatusOK, drain(func(r *http.Request) { r.SetBasicAuth("admin", "adminsecret") }))
+	require.True(t, s.IsDraining())
+
+	// new participants are sent elsewhere
+	res, err := http.Get(fmt.Sprintf("http://localhost:%d/rtc/validate?access_token=%s", s.HTTPPort(), joinToken(testRoom, "c2")))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+	require.Equal(t, "1", res.Header.Get("Retry-After"))
+
+	// the room is still open after drain_timeout, so the node closes it and shuts down
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("server did not shut down after drain_timeout")
+	}
+}
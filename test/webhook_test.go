@@ -94,7 +94,7 @@ func setupServerWithWebhook() (server *service.LivekitServer, testServer *webhoo
 	if err != nil {
 		panic(fmt.Sprintf("could not create config: %v", err))
 	}
-	conf.WebHook.URLs = []string{"http://localhost:7890"}
+	conf.WebHook.URLs = []config.WebHookURL{{URL: "http://localhost:7890"}}
 	conf.WebHook.APIKey = testApiKey
 	conf.Development = true
 	conf.Keys = map[string]string{testApiKey: testApiSecret}